@@ -67,7 +67,6 @@ func startCompliance(stopper restart.Stopper, apiCl *apiserver.APIClient, isLead
 	// setup the pipeline provider that provides pairs of processor and sender
 	pipelineProvider := pipeline.NewProvider(config.NumberOfPipelines, auditor, nil, endpoints, destinationsCtx)
 	pipelineProvider.Start()
-	stopper.Add(pipelineProvider)
 
 	logSource := config.NewLogSource("compliance-agent", &config.LogsConfig{
 		Type:    "compliance",
@@ -76,6 +75,27 @@ func startCompliance(stopper restart.Stopper, apiCl *apiserver.APIClient, isLead
 	})
 
 	reporter := event.NewReporter(logSource, pipelineProvider.NextPipelineChan())
+	batchEvents := coreconfig.Datadog.GetBool("compliance_config.batch_events.enabled")
+	if batchEvents && !endpoints.UseHTTP {
+		log.Warn("The batches of compliance events are only sent over HTTP, sending the events one at a time")
+		batchEvents = false
+	}
+	if batchEvents {
+		// the batches are sent directly, to fall back to the events when the intake rejects them
+		destination := http.NewDestination(endpoints.Main, http.JSONContentType, destinationsCtx)
+		batchReporter := event.NewBatchReporter(
+			destination.Send,
+			reporter,
+			coreconfig.Datadog.GetInt("compliance_config.batch_events.max_events"),
+			coreconfig.Datadog.GetDuration("compliance_config.batch_events.flush_interval"),
+		)
+		batchReporter.Start()
+		// the pending events are flushed before the pipeline stops
+		stopper.Add(restart.NewSerialStopper(batchReporter, pipelineProvider))
+		reporter = batchReporter
+	} else {
+		stopper.Add(pipelineProvider)
+	}
 
 	runner := runner.NewRunner()
 	stopper.Add(runner)
@@ -101,6 +121,7 @@ func startCompliance(stopper restart.Stopper, apiCl *apiserver.APIClient, isLead
 		}),
 		checks.WithKubernetesClient(apiCl.DynamicCl),
 		checks.WithIsLeader(isLeader),
+		checks.WithConfigParameters(),
 	)
 	if err != nil {
 		return err
@@ -10,6 +10,7 @@ package agent
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/cmd/agent/common"
 	"github.com/DataDog/datadog-agent/cmd/agent/common/signals"
+	compagent "github.com/DataDog/datadog-agent/pkg/compliance/agent"
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/flare"
 	secagent "github.com/DataDog/datadog-agent/pkg/security/agent"
@@ -28,13 +30,15 @@ import (
 
 // Agent handles REST API calls
 type Agent struct {
-	runtimeAgent *secagent.RuntimeSecurityAgent
+	runtimeAgent    *secagent.RuntimeSecurityAgent
+	complianceAgent *compagent.Agent
 }
 
 // NewAgent returns a new Agent
-func NewAgent(runtimeAgent *secagent.RuntimeSecurityAgent) *Agent {
+func NewAgent(runtimeAgent *secagent.RuntimeSecurityAgent, complianceAgent *compagent.Agent) *Agent {
 	return &Agent{
-		runtimeAgent: runtimeAgent,
+		runtimeAgent:    runtimeAgent,
+		complianceAgent: complianceAgent,
 	}
 }
 
@@ -47,6 +51,7 @@ func (a *Agent) SetupHandlers(r *mux.Router) {
 	r.HandleFunc("/status", a.getStatus).Methods("GET")
 	r.HandleFunc("/status/health", a.getHealth).Methods("GET")
 	r.HandleFunc("/config", a.getRuntimeConfig).Methods("GET")
+	r.HandleFunc("/compliance/scan", a.runComplianceScan).Methods("POST")
 }
 
 func (a *Agent) stopAgent(w http.ResponseWriter, r *http.Request) {
@@ -160,3 +165,40 @@ func (a *Agent) getRuntimeConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Write(scrubbed)
 }
+
+func (a *Agent) runComplianceScan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if a.complianceAgent == nil {
+		body, _ := json.Marshal(map[string]string{"error": "compliance agent is not enabled"})
+		http.Error(w, string(body), http.StatusServiceUnavailable)
+		return
+	}
+
+	var req compagent.ScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("invalid scan request: %v", err)})
+		http.Error(w, string(body), http.StatusBadRequest)
+		return
+	}
+
+	result, err := a.complianceAgent.Scan(&req)
+	if err != nil {
+		log.Errorf("Compliance scan failed: %v", err)
+		code := http.StatusInternalServerError
+		if err == compagent.ErrEmptyScanRequest {
+			code = http.StatusBadRequest
+		}
+		body, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(body), code)
+		return
+	}
+
+	j, err := json.Marshal(result)
+	if err != nil {
+		log.Errorf("Unable to marshal compliance scan result: %v", err)
+		body, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(body), 500)
+		return
+	}
+	w.Write(j)
+}
@@ -227,7 +227,8 @@ func start(cmd *cobra.Command, args []string) error {
 		return log.Criticalf("Error creating statsd Client: %s", err)
 	}
 
-	if err = startCompliance(hostname, endpoints, dstContext, stopper, statsdClient); err != nil {
+	complianceAgent, err := startCompliance(hostname, endpoints, dstContext, stopper, statsdClient)
+	if err != nil {
 		return err
 	}
 
@@ -237,7 +238,7 @@ func start(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	srv, err := api.NewServer(runtimeAgent)
+	srv, err := api.NewServer(runtimeAgent, complianceAgent)
 	if err != nil {
 		return log.Errorf("Error while creating api server, exiting: %v", err)
 	}
@@ -6,21 +6,27 @@
 package app
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 
 	secagentcommon "github.com/DataDog/datadog-agent/cmd/security-agent/common"
+	apiutil "github.com/DataDog/datadog-agent/pkg/api/util"
 	"github.com/DataDog/datadog-agent/pkg/collector/runner"
 	"github.com/DataDog/datadog-agent/pkg/collector/scheduler"
+	"github.com/DataDog/datadog-agent/pkg/compliance"
 	"github.com/DataDog/datadog-agent/pkg/compliance/agent"
 	"github.com/DataDog/datadog-agent/pkg/compliance/checks"
 	"github.com/DataDog/datadog-agent/pkg/compliance/event"
 	coreconfig "github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/http"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
 	"github.com/DataDog/datadog-agent/pkg/logs/restart"
@@ -47,6 +53,29 @@ var (
 		event      event.Event
 		data       []string
 	}{}
+
+	scanCmd = &cobra.Command{
+		Use:   "scan [rule ID...]",
+		Short: "Run compliance rules selected by framework, tag or ID on the running Security Agent",
+		RunE:  scanRun,
+	}
+
+	scanArgs = struct {
+		frameworks []string
+		tags       []string
+		json       bool
+	}{}
+
+	coverageCmd = &cobra.Command{
+		Use:   "coverage",
+		Short: "Report the benchmark sections checked by the compliance suites",
+		RunE:  coverageRun,
+	}
+
+	coverageArgs = struct {
+		file string
+		json bool
+	}{}
 )
 
 func init() {
@@ -58,6 +87,119 @@ func init() {
 	eventCmd.Flags().StringVarP(&eventArgs.event.ResourceType, "resource-type", "", "", "Resource type")
 	eventCmd.Flags().StringSliceVarP(&eventArgs.event.Tags, "tags", "t", []string{"security:compliance"}, "Tags")
 	eventCmd.Flags().StringSliceVarP(&eventArgs.data, "data", "d", []string{}, "Data KV fields")
+
+	complianceCmd.AddCommand(scanCmd)
+	scanCmd.Flags().StringSliceVarP(&scanArgs.frameworks, "framework", "", []string{}, "Framework to run the rules from")
+	scanCmd.Flags().StringSliceVarP(&scanArgs.tags, "tag", "t", []string{}, "Tag of the compliance suites to run the rules from")
+	scanCmd.Flags().BoolVarP(&scanArgs.json, "json", "j", false, "print out raw json")
+
+	complianceCmd.AddCommand(coverageCmd)
+	coverageCmd.Flags().StringVarP(&coverageArgs.file, "file", "f", "", "Compliance suite file to report the coverage of")
+	coverageCmd.Flags().BoolVarP(&coverageArgs.json, "json", "j", false, "print out raw json")
+}
+
+func scanRun(cmd *cobra.Command, args []string) error {
+	// Read configuration files received from the command line arguments '-c'
+	if err := secagentcommon.MergeConfigurationFiles("datadog", confPathArray); err != nil {
+		return err
+	}
+
+	req := &agent.ScanRequest{
+		Frameworks: scanArgs.frameworks,
+		Tags:       scanArgs.tags,
+		RuleIDs:    args,
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	// Set session token
+	if err := apiutil.SetAuthToken(); err != nil {
+		return err
+	}
+
+	c := apiutil.GetClient(false) // FIX: get certificates right then make this true
+	urlstr := fmt.Sprintf("https://localhost:%v/agent/compliance/scan", coreconfig.Datadog.GetInt("security_agent.cmd_port"))
+	r, err := apiutil.DoPost(c, urlstr, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		var errMap = make(map[string]string)
+		json.Unmarshal(r, &errMap) //nolint:errcheck
+		// If the error has been marshalled into a json object, check it and return it properly
+		if e, found := errMap["error"]; found {
+			err = fmt.Errorf(e)
+		}
+		return fmt.Errorf("compliance scan failed: %v", err)
+	}
+
+	if scanArgs.json {
+		fmt.Println(string(r))
+		return nil
+	}
+
+	var result agent.ScanResult
+	if err := json.Unmarshal(r, &result); err != nil {
+		return err
+	}
+	if len(result.Events) == 0 {
+		fmt.Println("No compliance rule matched")
+		return nil
+	}
+	for _, e := range result.Events {
+		fmt.Printf("%s: %s\n", e.AgentRuleID, e.Result)
+	}
+	return nil
+}
+
+func coverageRun(cmd *cobra.Command, args []string) error {
+	// Read configuration files received from the command line arguments '-c'
+	if err := secagentcommon.MergeConfigurationFiles("datadog", confPathArray); err != nil {
+		return err
+	}
+
+	files := []string{coverageArgs.file}
+	if coverageArgs.file == "" {
+		pattern := filepath.Join(coreconfig.Datadog.GetString("compliance_config.dir"), "*.yaml")
+		var err error
+		if files, err = filepath.Glob(pattern); err != nil {
+			return err
+		}
+	}
+
+	reports := make([]*compliance.CoverageReport, 0, len(files))
+	for _, file := range files {
+		suite, err := compliance.ParseSuite(file)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, compliance.NewCoverageReport(suite))
+	}
+
+	if coverageArgs.json {
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for _, r := range reports {
+		fmt.Printf("%s (%s %s)\n", r.Name, r.Framework, r.Version)
+		if r.Coverage > 0 || len(r.Unimplemented) > 0 {
+			fmt.Printf("  Coverage: %.1f%% of the benchmark sections\n", r.Coverage*100)
+		}
+		for _, s := range r.Sections {
+			fmt.Printf("  [x] %s (%s)\n", strings.TrimSpace(s.ID+" "+s.Title), strings.Join(s.Rules, ", "))
+		}
+		for _, s := range r.Unimplemented {
+			fmt.Printf("  [ ] %s\n", strings.TrimSpace(s.ID+" "+s.Title))
+		}
+		if len(r.Unlisted) > 0 {
+			fmt.Printf("  Rules not mapped to a benchmark section: %s\n", strings.Join(r.Unlisted, ", "))
+		}
+	}
+	return nil
 }
 
 func eventRun(cmd *cobra.Command, args []string) error {
@@ -105,7 +247,6 @@ func newComplianceReporter(stopper restart.Stopper, sourceName, sourceType strin
 	// setup the pipeline provider that provides pairs of processor and sender
 	pipelineProvider := pipeline.NewProvider(config.NumberOfPipelines, auditor, nil, endpoints, context)
 	pipelineProvider.Start()
-	stopper.Add(pipelineProvider)
 
 	logSource := config.NewLogSource(
 		sourceName,
@@ -115,18 +256,41 @@ func newComplianceReporter(stopper restart.Stopper, sourceName, sourceType strin
 			Source:  sourceName,
 		},
 	)
-	return event.NewReporter(logSource, pipelineProvider.NextPipelineChan()), nil
+
+	reporter := event.NewReporter(logSource, pipelineProvider.NextPipelineChan())
+	batchEvents := coreconfig.Datadog.GetBool("compliance_config.batch_events.enabled")
+	if batchEvents && !endpoints.UseHTTP {
+		log.Warn("The batches of compliance events are only sent over HTTP, sending the events one at a time")
+		batchEvents = false
+	}
+	if !batchEvents {
+		stopper.Add(pipelineProvider)
+		return reporter, nil
+	}
+
+	// the batches are sent directly, to fall back to the events when the intake rejects them
+	destination := http.NewDestination(endpoints.Main, http.JSONContentType, context)
+	batchReporter := event.NewBatchReporter(
+		destination.Send,
+		reporter,
+		coreconfig.Datadog.GetInt("compliance_config.batch_events.max_events"),
+		coreconfig.Datadog.GetDuration("compliance_config.batch_events.flush_interval"),
+	)
+	batchReporter.Start()
+	// the pending events are flushed before the pipeline stops
+	stopper.Add(restart.NewSerialStopper(batchReporter, pipelineProvider))
+	return batchReporter, nil
 }
 
-func startCompliance(hostname string, endpoints *config.Endpoints, context *client.DestinationsContext, stopper restart.Stopper, statsdClient *ddgostatsd.Client) error {
+func startCompliance(hostname string, endpoints *config.Endpoints, context *client.DestinationsContext, stopper restart.Stopper, statsdClient *ddgostatsd.Client) (*agent.Agent, error) {
 	enabled := coreconfig.Datadog.GetBool("compliance_config.enabled")
 	if !enabled {
-		return nil
+		return nil, nil
 	}
 
 	reporter, err := newComplianceReporter(stopper, "compliance-agent", "compliance", endpoints, context)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	runner := runner.NewRunner()
@@ -144,6 +308,15 @@ func startCompliance(hostname string, endpoints *config.Endpoints, context *clie
 		checks.WithHostRootMount(os.Getenv("HOST_ROOT")),
 		checks.MayFail(checks.WithDocker()),
 		checks.MayFail(checks.WithAudit()),
+		checks.WithConfigParameters(),
+	}
+
+	if pid := coreconfig.Datadog.GetInt("compliance_config.host_namespace_pid"); pid > 0 {
+		options = append(options, checks.WithHostNamespace(pid))
+	}
+
+	if socket := coreconfig.Datadog.GetString("compliance_config.osquery.socket"); socket != "" {
+		options = append(options, checks.MayFail(checks.WithOsquery(socket)))
 	}
 
 	if coreconfig.IsKubernetes() {
@@ -163,20 +336,29 @@ func startCompliance(hostname string, endpoints *config.Endpoints, context *clie
 	)
 	if err != nil {
 		log.Errorf("Compliance agent failed to initialize: %v", err)
-		return err
+		return nil, err
 	}
 	err = agent.Run()
 	if err != nil {
 		log.Errorf("Error starting compliance agent, exiting: %v", err)
-		return err
+		return nil, err
 	}
 	stopper.Add(agent)
 
+	if port := coreconfig.Datadog.GetInt("compliance_config.results_port"); port > 0 {
+		server, err := agent.ServeResults(fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			log.Errorf("Failed to serve compliance results: %v", err)
+		} else {
+			stopper.Add(server)
+		}
+	}
+
 	log.Infof("Running compliance checks every %s", checkInterval.String())
 
 	// Send the compliance 'running' metrics periodically
 	ticker := sendRunningMetrics(statsdClient, "compliance")
 	stopper.Add(ticker)
 
-	return nil
+	return agent, nil
 }
@@ -10,6 +10,9 @@ package app
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -26,6 +29,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/security/policy"
 	sprobe "github.com/DataDog/datadog-agent/pkg/security/probe"
 	"github.com/DataDog/datadog-agent/pkg/security/rules"
+	"github.com/DataDog/datadog-agent/pkg/security/secl/eval"
 	"github.com/DataDog/datadog-agent/pkg/status/health"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 	ddgostatsd "github.com/DataDog/datadog-go/statsd"
@@ -46,11 +50,46 @@ var (
 	checkPoliciesArgs = struct {
 		dir string
 	}{}
+
+	explainRuleCmd = &cobra.Command{
+		Use:   "explain-rule",
+		Short: "Evaluate a rule against an event and report the result of each of its predicates",
+		RunE:  explainRule,
+	}
+
+	explainRuleArgs = struct {
+		dir    string
+		ruleID string
+		event  string
+	}{}
+
+	simulatePoliciesCmd = &cobra.Command{
+		Use:   "simulate-policies",
+		Short: "Replay the events of an activity dump through policies and report the rules which would have matched them",
+		RunE:  simulatePolicies,
+	}
+
+	simulatePoliciesArgs = struct {
+		dir     string
+		archive string
+	}{}
 )
 
 func init() {
 	runtimeCmd.AddCommand(checkPoliciesCmd)
 	checkPoliciesCmd.Flags().StringVar(&checkPoliciesArgs.dir, "policies-dir", coreconfig.DefaultRuntimePoliciesDir, "Path to policies directory")
+
+	runtimeCmd.AddCommand(explainRuleCmd)
+	explainRuleCmd.Flags().StringVar(&explainRuleArgs.dir, "policies-dir", coreconfig.DefaultRuntimePoliciesDir, "Path to policies directory")
+	explainRuleCmd.Flags().StringVar(&explainRuleArgs.ruleID, "rule-id", "", "ID of the rule to evaluate")
+	explainRuleCmd.Flags().StringVar(&explainRuleArgs.event, "event", "", "Path to a JSON file holding the values of the event fields, such as {\"open.filename\": \"/etc/shadow\"}")
+	explainRuleCmd.MarkFlagRequired("rule-id") //nolint:errcheck
+	explainRuleCmd.MarkFlagRequired("event")   //nolint:errcheck
+
+	runtimeCmd.AddCommand(simulatePoliciesCmd)
+	simulatePoliciesCmd.Flags().StringVar(&simulatePoliciesArgs.dir, "policies-dir", coreconfig.DefaultRuntimePoliciesDir, "Path to the directory of the policies to simulate")
+	simulatePoliciesCmd.Flags().StringVar(&simulatePoliciesArgs.archive, "archive", "", "Path to an activity dump of the runtime security agent")
+	simulatePoliciesCmd.MarkFlagRequired("archive") //nolint:errcheck
 }
 
 func checkPolicies(cmd *cobra.Command, args []string) error {
@@ -84,7 +123,99 @@ func checkPolicies(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func newRuntimeReporter(stopper restart.Stopper, sourceName, sourceType string, endpoints *config.Endpoints, context *client.DestinationsContext) (event.Reporter, error) {
+func explainRule(cmd *cobra.Command, args []string) error {
+	cfg := &secconfig.Config{
+		PoliciesDir: explainRuleArgs.dir,
+	}
+
+	probe, err := sprobe.NewProbe(cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	ruleSet := probe.NewRuleSet(rules.NewOptsWithParams(sprobe.SECLConstants, sprobe.SupportedDiscarders))
+	if err := policy.LoadPolicies(cfg, ruleSet); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(explainRuleArgs.event)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return errors.Wrapf(err, "invalid event file %s", explainRuleArgs.event)
+	}
+
+	event := ruleSet.NewEvent()
+	for field, value := range values {
+		kind, err := event.GetFieldType(field)
+		if err != nil {
+			return errors.Wrapf(err, "invalid event field `%s`", field)
+		}
+
+		// JSON numbers are decoded as float64
+		if number, ok := value.(float64); ok && kind == reflect.Int {
+			value = int(number)
+		}
+
+		if err := event.SetFieldValue(field, value); err != nil {
+			return errors.Wrapf(err, "invalid value for event field `%s`", field)
+		}
+	}
+
+	// fields which aren't set in the event file may require resolvers which are only available
+	// when the runtime security agent is running
+	isSet := func(field eval.Field) bool {
+		_, exists := values[field]
+		return exists
+	}
+
+	explanation, err := ruleSet.Explain(explainRuleArgs.ruleID, event, isSet)
+	if err != nil {
+		return errors.Wrap(err, "make sure all the fields used by the rule are set in the event file")
+	}
+
+	content, _ := json.MarshalIndent(explanation, "", "\t")
+	fmt.Printf("%s\n", string(content))
+
+	return nil
+}
+
+func simulatePolicies(cmd *cobra.Command, args []string) error {
+	cfg := &secconfig.Config{
+		PoliciesDir: simulatePoliciesArgs.dir,
+	}
+
+	probe, err := sprobe.NewProbe(cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	ruleSet := probe.NewRuleSet(rules.NewOptsWithParams(sprobe.SECLConstants, sprobe.SupportedDiscarders))
+	if err := policy.LoadPolicies(cfg, ruleSet); err != nil {
+		return err
+	}
+
+	archive, err := os.Open(simulatePoliciesArgs.archive)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	report, err := sprobe.SimulateRuleSet(ruleSet, archive)
+	if err != nil {
+		return err
+	}
+
+	content, _ := json.MarshalIndent(report, "", "\t")
+	fmt.Printf("%s\n", string(content))
+
+	return nil
+}
+
+func newRuntimeSink(stopper restart.Stopper, sourceName, sourceType string, endpoints *config.Endpoints, context *client.DestinationsContext) (secagent.EventSink, error) {
 	health := health.RegisterLiveness("runtime-security")
 
 	// setup the auditor
@@ -92,10 +223,17 @@ func newRuntimeReporter(stopper restart.Stopper, sourceName, sourceType string,
 	auditor.Start()
 	stopper.Add(auditor)
 
+	// the queued events are acknowledged up to the last one reported as delivered by the auditor,
+	// which requires them to be delivered in order through a single pipeline
+	queueEnabled := coreconfig.Datadog.GetBool("runtime_security_config.event_queue.enabled")
+	numberOfPipelines := config.NumberOfPipelines
+	if queueEnabled {
+		numberOfPipelines = 1
+	}
+
 	// setup the pipeline provider that provides pairs of processor and sender
-	pipelineProvider := pipeline.NewProvider(config.NumberOfPipelines, auditor, nil, endpoints, context)
+	pipelineProvider := pipeline.NewProvider(numberOfPipelines, auditor, nil, endpoints, context)
 	pipelineProvider.Start()
-	stopper.Add(pipelineProvider)
 
 	logSource := config.NewLogSource(
 		sourceName,
@@ -105,7 +243,27 @@ func newRuntimeReporter(stopper restart.Stopper, sourceName, sourceType string,
 			Source:  sourceName,
 		},
 	)
-	return event.NewReporter(logSource, pipelineProvider.NextPipelineChan()), nil
+
+	if !queueEnabled {
+		stopper.Add(pipelineProvider)
+		return secagent.NewReporterSink(event.NewReporter(logSource, pipelineProvider.NextPipelineChan())), nil
+	}
+
+	sink, err := secagent.NewQueueSink(
+		coreconfig.Datadog.GetString("runtime_security_config.event_queue.dir"),
+		coreconfig.Datadog.GetInt64("runtime_security_config.event_queue.max_size"),
+		logSource,
+		pipelineProvider.NextPipelineChan(),
+		auditor,
+	)
+	if err != nil {
+		pipelineProvider.Stop()
+		return nil, errors.Wrap(err, "unable to open the runtime security event queue")
+	}
+	// the sink must stop sending events before the pipelines are stopped
+	stopper.Add(sink)
+	stopper.Add(pipelineProvider)
+	return sink, nil
 }
 
 func startRuntimeSecurity(hostname string, endpoints *config.Endpoints, context *client.DestinationsContext, stopper restart.Stopper, statsdClient *ddgostatsd.Client) (*secagent.RuntimeSecurityAgent, error) {
@@ -115,12 +273,13 @@ func startRuntimeSecurity(hostname string, endpoints *config.Endpoints, context
 		return nil, nil
 	}
 
-	reporter, err := newRuntimeReporter(stopper, "runtime-security-agent", "runtime-security", endpoints, context)
+	sink, err := newRuntimeSink(stopper, "runtime-security-agent", "runtime-security", endpoints, context)
 	if err != nil {
 		return nil, err
 	}
+	sink.Start()
 
-	agent, err := secagent.NewRuntimeSecurityAgent(hostname, reporter)
+	agent, err := secagent.NewRuntimeSecurityAgent(hostname, sink)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create a runtime security agent instance")
 	}
@@ -13,8 +13,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/DataDog/datadog-agent/pkg/compliance"
 	"github.com/DataDog/datadog-agent/pkg/compliance/agent"
 	"github.com/DataDog/datadog-agent/pkg/compliance/checks"
 	"github.com/DataDog/datadog-agent/pkg/compliance/event"
@@ -33,6 +35,8 @@ var (
 		framework string
 		file      string
 		verbose   bool
+		validate  bool
+		snapshot  []string
 	}{}
 )
 
@@ -40,6 +44,8 @@ func setupCheckCmd(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&checkArgs.framework, "framework", "", "", "Framework to run the checks from")
 	cmd.Flags().StringVarP(&checkArgs.file, "file", "f", "", "Compliance suite file to read rules from")
 	cmd.Flags().BoolVarP(&checkArgs.verbose, "verbose", "v", false, "Include verbose details")
+	cmd.Flags().BoolVarP(&checkArgs.validate, "validate", "", false, "Validate the compliance suite files against the schema instead of running the checks")
+	cmd.Flags().StringSliceVarP(&checkArgs.snapshot, "snapshot", "", nil, "Host paths to copy before running the checks of each suite, so that all of them observe the same content (e.g. /etc/kubernetes,/proc/sys)")
 }
 
 // CheckCmd returns a cobra command to run security agent checks
@@ -73,6 +79,10 @@ func runCheck(cmd *cobra.Command, confPathArray []string, args []string) error {
 		return err
 	}
 
+	if checkArgs.validate {
+		return validateSuites()
+	}
+
 	options := []checks.BuilderOption{}
 
 	if flavor.GetFlavor() == flavor.ClusterAgent {
@@ -91,6 +101,14 @@ func runCheck(cmd *cobra.Command, confPathArray []string, args []string) error {
 			checks.MayFail(checks.WithAudit()),
 		}...)
 
+		if pid := config.Datadog.GetInt("compliance_config.host_namespace_pid"); pid > 0 {
+			options = append(options, checks.WithHostNamespace(pid))
+		}
+
+		if socket := config.Datadog.GetString("compliance_config.osquery.socket"); socket != "" {
+			options = append(options, checks.MayFail(checks.WithOsquery(socket)))
+		}
+
 		if config.IsKubernetes() {
 			nodeLabels, err := agent.WaitGetNodeLabels()
 			if err != nil {
@@ -111,7 +129,11 @@ func runCheck(cmd *cobra.Command, confPathArray []string, args []string) error {
 		return err
 	}
 
-	options = append(options, checks.WithHostname(hostname))
+	options = append(options, checks.WithHostname(hostname), checks.WithConfigParameters())
+
+	if len(checkArgs.snapshot) != 0 {
+		options = append(options, checks.WithSnapshot(checkArgs.snapshot...))
+	}
 
 	reporter := &runCheckReporter{}
 
@@ -139,6 +161,42 @@ func runCheck(cmd *cobra.Command, confPathArray []string, args []string) error {
 	return nil
 }
 
+// validateSuites validates the suite given on the command line, or all the suites of the
+// compliance configuration directory, and prints the issues found
+func validateSuites() error {
+	files := []string{checkArgs.file}
+	if checkArgs.file == "" {
+		pattern := filepath.Join(config.Datadog.GetString("compliance_config.dir"), "*.yaml")
+		var err error
+		if files, err = filepath.Glob(pattern); err != nil {
+			return err
+		}
+	}
+
+	invalid := 0
+	for _, file := range files {
+		issues, err := compliance.ValidateSuiteFile(file)
+		if err != nil {
+			return err
+		}
+		if compliance.HasErrors(issues) {
+			invalid++
+		}
+		if len(issues) == 0 {
+			fmt.Printf("%s: OK\n", file)
+			continue
+		}
+		for _, issue := range issues {
+			fmt.Printf("%s: %s\n", file, issue)
+		}
+	}
+
+	if invalid != 0 {
+		return fmt.Errorf("%d of %d compliance suites are invalid", invalid, len(files))
+	}
+	return nil
+}
+
 func configureLogger() error {
 	var (
 		logFormat = "%LEVEL | %Msg%n"
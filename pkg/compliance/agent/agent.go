@@ -11,6 +11,7 @@ import (
 	"expvar"
 	"path"
 	"path/filepath"
+	"sync"
 
 	"github.com/DataDog/datadog-agent/pkg/collector/check"
 	"github.com/DataDog/datadog-agent/pkg/compliance"
@@ -21,6 +22,9 @@ import (
 
 var status = expvar.NewMap("compliance")
 
+// maxStatusRuleUsage is the number of most expensive rules reported in the status
+const maxStatusRuleUsage = 10
+
 // Scheduler abstracts the collector.Scheduler interface
 type Scheduler interface {
 	Enter(check check.Check) error
@@ -37,6 +41,11 @@ type Agent struct {
 	telemetry *telemetry
 	configDir string
 	cancel    context.CancelFunc
+
+	// reporter and options are used to build the checks of on-demand scans
+	reporter event.Reporter
+	options  []checks.BuilderOption
+	scanMu   sync.Mutex
 }
 
 // New creates a new instance of Agent
@@ -49,7 +58,7 @@ func New(reporter event.Reporter, scheduler Scheduler, configDir string, options
 		return nil, err
 	}
 
-	telemetry, err := newTelemtry()
+	telemetry, err := newTelemtry(builder)
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +68,8 @@ func New(reporter event.Reporter, scheduler Scheduler, configDir string, options
 		scheduler: scheduler,
 		configDir: configDir,
 		telemetry: telemetry,
+		reporter:  reporter,
+		options:   options,
 	}, nil
 }
 
@@ -116,6 +127,22 @@ func (a *Agent) Run() error {
 			return a.builder.GetCheckStatus()
 		}),
 	)
+	defer status.Set(
+		"Backends",
+		expvar.Func(func() interface{} {
+			return a.builder.GetBackendStatus()
+		}),
+	)
+	defer status.Set(
+		"RuleUsage",
+		expvar.Func(func() interface{} {
+			usage := a.builder.GetRuleUsage()
+			if len(usage) > maxStatusRuleUsage {
+				usage = usage[:maxStatusRuleUsage]
+			}
+			return usage
+		}),
+	)
 
 	onCheck := func(rule *compliance.Rule, check compliance.Check, err error) bool {
 		if err != nil {
@@ -131,7 +158,11 @@ func (a *Agent) Run() error {
 
 		return true
 	}
-	return a.buildChecks(onCheck)
+	if err := a.buildChecks(onCheck); err != nil {
+		return err
+	}
+	a.reportCoverage()
+	return nil
 }
 
 func runCheck(rule *compliance.Rule, check compliance.Check, err error) bool {
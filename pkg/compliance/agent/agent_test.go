@@ -16,6 +16,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/aggregator"
 	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	"github.com/DataDog/datadog-agent/pkg/compliance"
 	"github.com/DataDog/datadog-agent/pkg/compliance/checks"
 	"github.com/DataDog/datadog-agent/pkg/compliance/event"
 	"github.com/DataDog/datadog-agent/pkg/compliance/mocks"
@@ -128,6 +129,16 @@ func TestRun(t *testing.T) {
 		),
 	).Once()
 
+	for _, framework := range []string{"cis-docker/1.2.0", "cis-kubernetes/1.5.0"} {
+		framework := framework
+		reporter.On(
+			"Report",
+			mock.MatchedBy(func(e *event.Event) bool {
+				return e.AgentRuleID == compliance.CoverageRuleID && e.ResourceID == framework
+			}),
+		).Once()
+	}
+
 	defer reporter.AssertExpectations(t)
 
 	scheduler := &mocks.Scheduler{}
@@ -0,0 +1,34 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"path"
+	"path/filepath"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// reportCoverage reports the benchmark coverage of the compliance suites of the configuration
+// directory, so that the checks performed by the agent can be audited
+func (a *Agent) reportCoverage() {
+	files, err := filepath.Glob(path.Join(a.configDir, "*.yaml"))
+	if err != nil {
+		log.Errorf("Failed to list compliance suites: %v", err)
+		return
+	}
+	for _, file := range files {
+		suite, err := compliance.ParseSuite(file)
+		if err != nil {
+			// already logged when loading the rules
+			continue
+		}
+		report := compliance.NewCoverageReport(suite)
+		log.Debugf("%s/%s: %d benchmark sections checked, %d unimplemented", suite.Meta.Name, suite.Meta.Version, len(report.Sections), len(report.Unimplemented))
+		a.reporter.Report(report.Event())
+	}
+}
@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// RuleResult holds the latest result of a compliance rule
+type RuleResult struct {
+	RuleID       string `json:"rule_id"`
+	Name         string `json:"name"`
+	Framework    string `json:"framework,omitempty"`
+	Version      string `json:"version,omitempty"`
+	Result       string `json:"result,omitempty"`
+	ResourceType string `json:"resource_type,omitempty"`
+	ResourceID   string `json:"resource_id,omitempty"`
+}
+
+const (
+	// StatusCompliant is the status of a node on which rules passed and none failed
+	StatusCompliant = "compliant"
+	// StatusNonCompliant is the status of a node on which at least a rule failed
+	StatusNonCompliant = "non_compliant"
+	// StatusUnknown is the status of a node on which no rule passed or failed yet, because
+	// they have not run yet or all their runs errored
+	StatusUnknown = "unknown"
+)
+
+// Results holds the latest results of the compliance rules run by the agent. The node is
+// compliant when rules passed and none failed, and its status is unknown when no rule
+// passed or failed. Rules which have not run yet have no result.
+type Results struct {
+	Status    string        `json:"status"`
+	Compliant bool          `json:"compliant"`
+	Rules     []*RuleResult `json:"rules"`
+}
+
+// Results returns the latest results of the rules scheduled by the agent
+func (a *Agent) Results() *Results {
+	results := &Results{
+		Status: StatusUnknown,
+		Rules:  []*RuleResult{},
+	}
+	for _, status := range a.builder.GetCheckStatus() {
+		if status.InitError != nil {
+			// the rule does not apply to this node, or failed to load
+			continue
+		}
+		rule := &RuleResult{
+			RuleID:    status.RuleID,
+			Name:      status.Name,
+			Framework: status.Framework,
+			Version:   status.Version,
+		}
+		if e := status.LastEvent; e != nil {
+			rule.Result = e.Result
+			rule.ResourceType = e.ResourceType
+			rule.ResourceID = e.ResourceID
+			switch {
+			case e.Result == event.Failed:
+				results.Status = StatusNonCompliant
+			case e.Result == event.Passed && results.Status == StatusUnknown:
+				results.Status = StatusCompliant
+			}
+		}
+		results.Rules = append(results.Rules, rule)
+	}
+	results.Compliant = results.Status == StatusCompliant
+	return results
+}
+
+// ResultsServer serves the latest results of the compliance rules in JSON, so that tools
+// running on the node can act on them without going through the backend
+type ResultsServer struct {
+	server *http.Server
+}
+
+// ServeResults starts serving the results of the agent on addr, which should be a loopback
+// address as the results are served without authentication
+func (a *Agent) ServeResults(addr string) (*ResultsServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/compliance/results", a.handleResults)
+
+	s := &ResultsServer{
+		server: &http.Server{
+			Handler:      mux,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		},
+	}
+	log.Infof("Serving compliance results on http://%s/compliance/results", listener.Addr())
+	go s.server.Serve(listener) //nolint:errcheck
+	return s, nil
+}
+
+// Stop stops serving the results
+func (s *ResultsServer) Stop() {
+	if err := s.server.Close(); err != nil {
+		log.Errorf("Failed to stop the compliance results server: %v", err)
+	}
+}
+
+func (a *Agent) handleResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	j, err := json.Marshal(a.Results())
+	if err != nil {
+		log.Errorf("Unable to marshal compliance results: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(j)
+}
@@ -0,0 +1,147 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+	"github.com/DataDog/datadog-agent/pkg/compliance/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResults(t *testing.T) {
+	assert := assert.New(t)
+
+	builder := &mocks.Builder{}
+	defer builder.AssertExpectations(t)
+	builder.On("GetCheckStatus").Return(compliance.CheckStatusList{
+		{
+			RuleID:    "cis-docker-1",
+			Name:      "cis-docker:1",
+			Framework: "cis-docker",
+			Version:   "1.2.0",
+			LastEvent: &event.Event{
+				AgentRuleID:  "cis-docker-1",
+				Result:       event.Passed,
+				ResourceType: "docker_daemon",
+				ResourceID:   "host_daemon",
+			},
+		},
+		{
+			RuleID:    "cis-kubernetes-1",
+			Name:      "cis-kubernetes:1",
+			Framework: "cis-kubernetes",
+			LastEvent: &event.Event{
+				AgentRuleID:  "cis-kubernetes-1",
+				Result:       event.Failed,
+				ResourceType: "kubernetes_worker_node",
+				ResourceID:   "node",
+			},
+		},
+		{
+			RuleID:    "cis-kubernetes-2",
+			Name:      "cis-kubernetes:2",
+			Framework: "cis-kubernetes",
+		},
+		{
+			RuleID:    "cis-kubernetes-3",
+			Framework: "cis-kubernetes",
+			InitError: errors.New("rule does not apply"),
+		},
+	})
+	agent := &Agent{builder: builder}
+
+	server := httptest.NewServer(http.HandlerFunc(agent.handleResults))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("application/json", resp.Header.Get("Content-Type"))
+
+	var results Results
+	assert.NoError(json.NewDecoder(resp.Body).Decode(&results))
+	assert.False(results.Compliant)
+	assert.Equal(StatusNonCompliant, results.Status)
+	assert.Equal([]*RuleResult{
+		{
+			RuleID:       "cis-docker-1",
+			Name:         "cis-docker:1",
+			Framework:    "cis-docker",
+			Version:      "1.2.0",
+			Result:       event.Passed,
+			ResourceType: "docker_daemon",
+			ResourceID:   "host_daemon",
+		},
+		{
+			RuleID:       "cis-kubernetes-1",
+			Name:         "cis-kubernetes:1",
+			Framework:    "cis-kubernetes",
+			Result:       event.Failed,
+			ResourceType: "kubernetes_worker_node",
+			ResourceID:   "node",
+		},
+		{
+			RuleID:    "cis-kubernetes-2",
+			Name:      "cis-kubernetes:2",
+			Framework: "cis-kubernetes",
+		},
+	}, results.Rules)
+
+	resp, err = http.Post(server.URL, "application/json", nil)
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestResultsStatus(t *testing.T) {
+	passed := &event.Event{AgentRuleID: "cis-docker-1", Result: event.Passed}
+	errored := &event.Event{AgentRuleID: "cis-docker-2", Result: event.Error}
+	tests := map[string]struct {
+		statuses  compliance.CheckStatusList
+		status    string
+		compliant bool
+	}{
+		"no-rules": {
+			status: StatusUnknown,
+		},
+		"not-run": {
+			statuses: compliance.CheckStatusList{{RuleID: "cis-docker-1"}},
+			status:   StatusUnknown,
+		},
+		"all-errored": {
+			statuses: compliance.CheckStatusList{{RuleID: "cis-docker-2", LastEvent: errored}},
+			status:   StatusUnknown,
+		},
+		"passed": {
+			statuses: compliance.CheckStatusList{
+				{RuleID: "cis-docker-1", LastEvent: passed},
+				{RuleID: "cis-docker-2", LastEvent: errored},
+			},
+			status:    StatusCompliant,
+			compliant: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			builder := &mocks.Builder{}
+			defer builder.AssertExpectations(t)
+			builder.On("GetCheckStatus").Return(test.statuses)
+
+			results := (&Agent{builder: builder}).Results()
+			assert.Equal(t, test.status, results.Status)
+			assert.Equal(t, test.compliant, results.Compliant)
+		})
+	}
+}
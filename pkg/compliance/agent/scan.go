@@ -0,0 +1,146 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks"
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// ErrEmptyScanRequest is returned when a scan is requested without any rule selection
+var ErrEmptyScanRequest = errors.New("scan request does not select any rule")
+
+// ScanRequest selects the rules to run in an on-demand scan. A rule is selected when it
+// matches every non-empty criterion, and a criterion matches if any of its values matches.
+type ScanRequest struct {
+	Frameworks []string `json:"frameworks,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	RuleIDs    []string `json:"rule_ids,omitempty"`
+}
+
+// ScanResult holds the events reported by the checks run in an on-demand scan
+type ScanResult struct {
+	Events []*event.Event `json:"events"`
+}
+
+func (r *ScanRequest) isEmpty() bool {
+	return len(r.Frameworks) == 0 && len(r.Tags) == 0 && len(r.RuleIDs) == 0
+}
+
+func (r *ScanRequest) matchSuite(s *compliance.SuiteMeta) bool {
+	if len(r.Frameworks) != 0 && !matchAny(s, r.Frameworks, checks.IsFramework) {
+		return false
+	}
+	if len(r.Tags) != 0 && !matchAny(s, r.Tags, checks.HasTag) {
+		return false
+	}
+	return true
+}
+
+func (r *ScanRequest) matchRule(rule *compliance.Rule) bool {
+	if len(r.RuleIDs) == 0 {
+		return true
+	}
+	for _, id := range r.RuleIDs {
+		if checks.IsRuleID(id)(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(s *compliance.SuiteMeta, values []string, matcher func(string) checks.SuiteMatcher) bool {
+	for _, v := range values {
+		if matcher(v)(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanReporter collects the events reported during a scan, and forwards them to the
+// reporter of the agent
+type scanReporter struct {
+	sync.Mutex
+	reporter event.Reporter
+	events   []*event.Event
+}
+
+func (r *scanReporter) Report(e *event.Event) {
+	r.record(e)
+	if r.reporter != nil {
+		r.reporter.Report(e)
+	}
+}
+
+// ReportWithManifest implements event.ManifestReporter, so that the events of the scan are
+// batched like the others when the reporter of the agent batches them
+func (r *scanReporter) ReportWithManifest(m event.Manifest, e *event.Event) {
+	r.record(e)
+	if mr, ok := r.reporter.(event.ManifestReporter); ok {
+		mr.ReportWithManifest(m, e)
+	} else if r.reporter != nil {
+		r.reporter.Report(e)
+	}
+}
+
+func (r *scanReporter) record(e *event.Event) {
+	r.Lock()
+	r.events = append(r.events, e)
+	r.Unlock()
+}
+
+// Scan runs the rules selected by the request right away and returns the reported events.
+// The events are also sent through the reporter of the agent. Scans run one at a time.
+func (a *Agent) Scan(req *ScanRequest) (*ScanResult, error) {
+	if req.isEmpty() {
+		return nil, ErrEmptyScanRequest
+	}
+
+	a.scanMu.Lock()
+	defer a.scanMu.Unlock()
+
+	reporter := &scanReporter{
+		reporter: a.reporter,
+		events:   []*event.Event{},
+	}
+
+	options := make([]checks.BuilderOption, 0, len(a.options)+2)
+	options = append(options, a.options...)
+	options = append(options,
+		checks.WithMatchSuite(req.matchSuite),
+		checks.WithMatchRule(req.matchRule),
+	)
+
+	builder, err := checks.NewBuilder(reporter, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := builder.Close(); err != nil {
+			log.Errorf("Scan builder failed to close: %v", err)
+		}
+	}()
+
+	log.Infof("Running on-demand scan (frameworks=%v tags=%v rules=%v)", req.Frameworks, req.Tags, req.RuleIDs)
+
+	scan := &Agent{
+		builder:   builder,
+		configDir: a.configDir,
+	}
+	if err := scan.RunChecks(); err != nil {
+		return nil, err
+	}
+
+	reporter.Lock()
+	defer reporter.Unlock()
+	return &ScanResult{Events: reporter.events}, nil
+}
@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build !windows
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks"
+	"github.com/DataDog/datadog-agent/pkg/compliance/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestScan(t *testing.T) {
+	aggregator.InitAggregator(nil, "foo")
+
+	e := enterTempEnv(t)
+	defer e.leave()
+
+	tests := []struct {
+		name    string
+		req     ScanRequest
+		ruleIDs []string
+		err     error
+	}{
+		{
+			name: "empty request",
+			err:  ErrEmptyScanRequest,
+		},
+		{
+			name:    "by framework",
+			req:     ScanRequest{Frameworks: []string{"cis-docker"}},
+			ruleIDs: []string{"cis-docker-1"},
+		},
+		{
+			name:    "by rule ID",
+			req:     ScanRequest{RuleIDs: []string{"cis-kubernetes-1", "unknown"}},
+			ruleIDs: []string{"cis-kubernetes-1"},
+		},
+		{
+			name:    "by tag",
+			req:     ScanRequest{Tags: []string{"cis"}},
+			ruleIDs: []string{"cis-docker-1", "cis-kubernetes-1"},
+		},
+		{
+			name:    "by tag and framework",
+			req:     ScanRequest{Tags: []string{"cis"}, Frameworks: []string{"cis-kubernetes"}},
+			ruleIDs: []string{"cis-kubernetes-1"},
+		},
+		{
+			name:    "no match",
+			req:     ScanRequest{Tags: []string{"pci"}},
+			ruleIDs: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			reporter := &mocks.Reporter{}
+			reporter.On("Report", mock.Anything).Times(len(test.ruleIDs))
+			defer reporter.AssertExpectations(t)
+
+			dockerClient := &mocks.DockerClient{}
+			dockerClient.On("Close").Return(nil)
+
+			agent, err := New(
+				reporter,
+				&mocks.Scheduler{},
+				e.dir,
+				checks.WithHostname("the-host"),
+				checks.WithHostRootMount(e.dir),
+				checks.WithDockerClient(dockerClient),
+				checks.WithNodeLabels(map[string]string{
+					"node-role.kubernetes.io/worker": "",
+				}),
+			)
+			assert.NoError(err)
+
+			result, err := agent.Scan(&test.req)
+			if test.err != nil {
+				assert.Equal(test.err, err)
+				return
+			}
+			assert.NoError(err)
+
+			ruleIDs := []string{}
+			for _, e := range result.Events {
+				assert.Equal("the-host", e.ResourceID)
+				ruleIDs = append(ruleIDs, e.AgentRuleID)
+			}
+			assert.ElementsMatch(test.ruleIDs, ruleIDs)
+
+			// checks scheduled by the agent are left untouched
+			assert.Empty(agent.builder.GetCheckStatus())
+		})
+	}
+}
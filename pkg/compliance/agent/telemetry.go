@@ -10,29 +10,44 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks"
 	"github.com/DataDog/datadog-agent/pkg/util/containers/collectors"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
 const (
 	containersCountMetricName = "datadog.security_agent.compliance.containers_running"
+
+	ruleRunsMetricName         = "datadog.security_agent.compliance.rule.runs"
+	ruleCPUTimeMetricName      = "datadog.security_agent.compliance.rule.cpu_time"
+	ruleWallTimeMetricName     = "datadog.security_agent.compliance.rule.wall_time"
+	ruleFilesOpenedMetricName  = "datadog.security_agent.compliance.rule.files_opened"
+	ruleSubprocessesMetricName = "datadog.security_agent.compliance.rule.subprocesses"
 )
 
-// telemetry reports environment information (e.g containers running) when the compliance component is running
+// telemetry reports environment information (e.g containers running) and the resources used
+// by the rules when the compliance component is running
 type telemetry struct {
 	sender   aggregator.Sender
 	detector collectors.DetectorInterface
+	builder  checks.Builder
+
+	// lastUsage holds the resource usage of the rules at the previous report, by rule ID
+	lastUsage map[string]*compliance.RuleUsage
 }
 
-func newTelemtry() (*telemetry, error) {
+func newTelemtry(builder checks.Builder) (*telemetry, error) {
 	sender, err := aggregator.GetDefaultSender()
 	if err != nil {
 		return nil, err
 	}
 
 	return &telemetry{
-		sender:   sender,
-		detector: collectors.NewDetector(""),
+		sender:    sender,
+		detector:  collectors.NewDetector(""),
+		builder:   builder,
+		lastUsage: make(map[string]*compliance.RuleUsage),
 	}, nil
 }
 
@@ -51,6 +66,7 @@ func (t *telemetry) run(ctx context.Context) {
 			if err := t.reportContainers(); err != nil {
 				log.Debugf("Couldn't report containers: %v", err)
 			}
+			t.reportRuleUsage()
 		}
 	}
 }
@@ -74,3 +90,24 @@ func (t *telemetry) reportContainers() error {
 
 	return nil
 }
+
+// reportRuleUsage reports the resources used by each rule since the previous report. The
+// times are reported in milliseconds.
+func (t *telemetry) reportRuleUsage() {
+	for _, usage := range t.builder.GetRuleUsage() {
+		last, ok := t.lastUsage[usage.RuleID]
+		if !ok {
+			last = &compliance.RuleUsage{}
+		}
+		t.lastUsage[usage.RuleID] = usage
+
+		tags := []string{"rule_id:" + usage.RuleID}
+		t.sender.Count(ruleRunsMetricName, float64(usage.Runs-last.Runs), "", tags)
+		t.sender.Count(ruleCPUTimeMetricName, usage.CPUTimeMs-last.CPUTimeMs, "", tags)
+		t.sender.Count(ruleWallTimeMetricName, usage.WallTimeMs-last.WallTimeMs, "", tags)
+		t.sender.Count(ruleFilesOpenedMetricName, float64(usage.FilesOpened-last.FilesOpened), "", tags)
+		t.sender.Count(ruleSubprocessesMetricName, float64(usage.Subprocesses-last.Subprocesses), "", tags)
+	}
+
+	t.sender.Commit()
+}
@@ -6,6 +6,8 @@
 package compliance
 
 import (
+	"time"
+
 	"github.com/DataDog/datadog-agent/pkg/collector/check"
 	"github.com/DataDog/datadog-agent/pkg/compliance/event"
 )
@@ -28,5 +30,38 @@ type CheckStatus struct {
 // CheckStatusList describes status for all configured checks
 type CheckStatusList []*CheckStatus
 
+// BackendStatus describes the health of a backend resolving the resources of the checks,
+// such as the docker daemon or the Kubernetes API server
+type BackendStatus struct {
+	Name string
+	// Quarantined is set while the resources of the backend are not resolved because it
+	// failed repeatedly, until QuarantinedUntil
+	Quarantined         bool
+	QuarantinedUntil    time.Time
+	ConsecutiveFailures int
+	LastError           string
+}
+
+// BackendStatusList describes status for all the backends used by the checks
+type BackendStatusList []*BackendStatus
+
 // CheckVisitor defines a visitor func for compliance checks
 type CheckVisitor func(rule *Rule, check Check, err error) bool
+
+// RuleUsage describes the resources consumed by the runs of a rule
+type RuleUsage struct {
+	RuleID string
+	Name   string
+	Runs   int64
+	// CPU times include the CPU time of the subprocesses run by the rule
+	CPUTimeMs     float64
+	AvgCPUTimeMs  float64
+	WallTimeMs    float64
+	AvgWallTimeMs float64
+	// FilesOpened is the number of files opened by the runs of the rule
+	FilesOpened  int64
+	Subprocesses int64
+}
+
+// RuleUsageList describes the resources consumed by the rules, most expensive first
+type RuleUsageList []*RuleUsage
@@ -9,6 +9,7 @@ package checks
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -43,6 +44,8 @@ const (
 type Builder interface {
 	ChecksFromFile(file string, onCheck compliance.CheckVisitor) error
 	GetCheckStatus() compliance.CheckStatusList
+	GetBackendStatus() compliance.BackendStatusList
+	GetRuleUsage() compliance.RuleUsageList
 	Close() error
 }
 
@@ -76,6 +79,39 @@ func WithHostRootMount(hostRootMount string) BuilderOption {
 	}
 }
 
+// WithHostNamespace configures checks to run against the host when the agent is containerized,
+// using the namespaces of the host process with the given PID. Files are read from the root
+// filesystem of the process, which replaces the host root mount, commands are run from it in
+// the host network, UTS and IPC namespaces, and only processes of its PID namespace are matched.
+// The host PID namespace must be shared with the container.
+func WithHostNamespace(pid int) BuilderOption {
+	return func(b *builder) error {
+		ns, err := newHostNamespace(pid)
+		if err != nil {
+			return err
+		}
+		log.Infof("Checks will run in the namespaces of host process %d", pid)
+		b.hostNamespace = ns
+		b.pathMapper = &pathMapper{
+			hostMountPath: ns.Root(),
+		}
+		return nil
+	}
+}
+
+// WithSnapshot configures the checks to read the given host paths from a copy taken before the
+// checks of each suite are visited, so that all the checks of a suite run observe the same content
+// even when files change during the run. Paths of procfs, such as /proc/sys, can be included. The
+// copy is removed once the checks of the suite are visited, so the option is meant for the builders
+// of one-off runs, of which the checks are run while visited. The copies are kept in a private
+// directory of the run path.
+func WithSnapshot(paths ...string) BuilderOption {
+	return func(b *builder) error {
+		b.snapshotPaths = append(b.snapshotPaths, paths...)
+		return nil
+	}
+}
+
 // WithDocker configures using docker
 func WithDocker() BuilderOption {
 	return func(b *builder) error {
@@ -114,6 +150,25 @@ func WithAuditClient(cli env.AuditClient) BuilderOption {
 	}
 }
 
+// WithOsquery configures using osquery checks, running queries through the extension socket of osqueryd
+func WithOsquery(socketPath string) BuilderOption {
+	return func(b *builder) error {
+		cli, err := newOsqueryClient(socketPath)
+		if err == nil {
+			b.osqueryClient = cli
+		}
+		return err
+	}
+}
+
+// WithOsqueryClient configures using specific osquery client
+func WithOsqueryClient(cli env.OsqueryClient) BuilderOption {
+	return func(b *builder) error {
+		b.osqueryClient = cli
+		return nil
+	}
+}
+
 // WithKubernetesClient allows specific Kubernetes client
 func WithKubernetesClient(cli env.KubeClient) BuilderOption {
 	return func(b *builder) error {
@@ -122,6 +177,15 @@ func WithKubernetesClient(cli env.KubeClient) BuilderOption {
 	}
 }
 
+// WithKubernetesNode configures a builder to run node scoped rules even when the host is not
+// detected as a Kubernetes node
+func WithKubernetesNode() BuilderOption {
+	return func(b *builder) error {
+		b.kubernetesNode = true
+		return nil
+	}
+}
+
 // WithIsLeader allows check runner to know if its a leader instance or not (DCA)
 func WithIsLeader(isLeader func() bool) BuilderOption {
 	return func(b *builder) error {
@@ -130,6 +194,15 @@ func WithIsLeader(isLeader func() bool) BuilderOption {
 	}
 }
 
+// WithParameterResolvers configures the resolvers used for rule parameters.
+// Values are taken from the first resolver knowing about a parameter.
+func WithParameterResolvers(resolvers ...ParameterResolver) BuilderOption {
+	return func(b *builder) error {
+		b.parameterResolver = parameterResolvers(resolvers)
+		return nil
+	}
+}
+
 // SuiteMatcher checks if a compliance suite is included
 type SuiteMatcher func(*compliance.SuiteMeta) bool
 
@@ -181,6 +254,18 @@ func IsFramework(framework string) SuiteMatcher {
 	}
 }
 
+// HasTag matches a compliance suite by one of its tags
+func HasTag(tag string) SuiteMatcher {
+	return func(s *compliance.SuiteMeta) bool {
+		for _, t := range s.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 // IsRuleID matches a compliance rule by ID
 func IsRuleID(ruleID string) RuleMatcher {
 	return func(r *compliance.Rule) bool {
@@ -195,6 +280,8 @@ func NewBuilder(reporter event.Reporter, options ...BuilderOption) (Builder, err
 		checkInterval: 20 * time.Minute,
 		etcGroupPath:  "/etc/group",
 		status:        newStatus(),
+		quarantine:    newBackendQuarantine(),
+		usage:         newUsageTracker(),
 	}
 
 	for _, o := range options {
@@ -204,6 +291,16 @@ func NewBuilder(reporter event.Reporter, options ...BuilderOption) (Builder, err
 
 	}
 
+	if len(b.snapshotPaths) != 0 {
+		if err := validateSnapshotPaths(b.snapshotPaths); err != nil {
+			return nil, err
+		}
+		b.snapshotDir = filepath.Join(config.Datadog.GetString("run_path"), "compliance-snapshots")
+		if err := prepareSnapshotDir(b.snapshotDir); err != nil {
+			return nil, fmt.Errorf("failed to prepare snapshot directory: %w", err)
+		}
+	}
+
 	b.valueCache = cache.New(
 		b.checkInterval/2,
 		b.checkInterval/4,
@@ -217,23 +314,58 @@ type builder struct {
 	reporter   event.Reporter
 	valueCache *cache.Cache
 
-	hostname     string
-	pathMapper   *pathMapper
-	etcGroupPath string
-	nodeLabels   map[string]string
+	hostname      string
+	pathMapper    *pathMapper
+	hostNamespace env.Namespace
+	snapshotPaths []string
+	snapshotDir   string
+	snapshot      *snapshot // nil if no snapshot is taken
+	etcGroupPath  string
+	nodeLabels    map[string]string
+
+	kubernetesNode bool
 
 	suiteMatcher SuiteMatcher
 	ruleMatcher  RuleMatcher
 
-	dockerClient env.DockerClient
-	auditClient  env.AuditClient
-	kubeClient   env.KubeClient
-	isLeaderFunc func() bool
+	dockerClient  env.DockerClient
+	auditClient   env.AuditClient
+	kubeClient    env.KubeClient
+	osqueryClient env.OsqueryClient
+	isLeaderFunc  func() bool
 
-	status *status
+	parameterResolver ParameterResolver
+
+	status     *status
+	quarantine *backendQuarantine
+	usage      *usageTracker
+}
+
+// takeSnapshot takes a snapshot of the configured paths, if any, from which the checks read
+// them until release is called
+func (b *builder) takeSnapshot() (release func(), err error) {
+	if len(b.snapshotPaths) == 0 {
+		return func() {}, nil
+	}
+	b.snapshot, err = newSnapshot(b.snapshotDir, b.pathMapper, b.snapshotPaths)
+	if err != nil {
+		return nil, err
+	}
+	return b.removeSnapshot, nil
+}
+
+func (b *builder) removeSnapshot() {
+	if b.snapshot == nil {
+		return
+	}
+	if err := b.snapshot.remove(); err != nil {
+		log.Errorf("Failed to remove snapshot %s: %v", b.snapshot.root, err)
+	}
+	b.snapshot = nil
 }
 
 func (b *builder) Close() error {
+	b.removeSnapshot()
 	if b.dockerClient != nil {
 		if err := b.dockerClient.Close(); err != nil {
 			return err
@@ -244,6 +376,11 @@ func (b *builder) Close() error {
 			return err
 		}
 	}
+	if b.osqueryClient != nil {
+		if err := b.osqueryClient.Close(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -265,6 +402,12 @@ func (b *builder) ChecksFromFile(file string, onCheck compliance.CheckVisitor) e
 
 	log.Infof("%s/%s: loading suite from %s", suite.Meta.Name, suite.Meta.Version, file)
 
+	release, err := b.takeSnapshot()
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	matchedCount := 0
 	for _, r := range suite.Rules {
 		if b.ruleMatcher != nil {
@@ -324,6 +467,19 @@ func (b *builder) GetCheckStatus() compliance.CheckStatusList {
 	return compliance.CheckStatusList{}
 }
 
+func (b *builder) GetBackendStatus() compliance.BackendStatusList {
+	return b.quarantine.status()
+}
+
+// GetRuleUsage returns the resource usage of the rules, most expensive first
+func (b *builder) GetRuleUsage() compliance.RuleUsageList {
+	return b.usage.getRuleUsage()
+}
+
+func (b *builder) backendQuarantine() *backendQuarantine {
+	return b.quarantine
+}
+
 func (b *builder) checkFromRule(meta *compliance.SuiteMeta, rule *compliance.Rule) (compliance.Check, error) {
 	ruleScope, err := getRuleScope(meta, rule)
 	if err != nil {
@@ -369,7 +525,7 @@ func (b *builder) hostMatcher(scope compliance.RuleScope, rule *compliance.Rule)
 			return false, nil
 		}
 	case compliance.KubernetesNodeScope:
-		if config.IsKubernetes() {
+		if b.kubernetesNode || config.IsKubernetes() {
 			return b.isKubernetesNodeEligible(rule.HostSelector)
 		}
 		log.Infof("rule %s skipped - not running on a Kubernetes node", rule.ID)
@@ -447,7 +603,7 @@ func (b *builder) nodeLabelKeys() []string {
 }
 
 func (b *builder) newCheck(meta *compliance.SuiteMeta, ruleScope compliance.RuleScope, rule *compliance.Rule) (compliance.Check, error) {
-	checkable, err := newResourceCheckList(b, rule.ID, rule.Resources)
+	checkable, err := newResourceCheckList(b, rule.ID, rule.Resources, rule.Parameters)
 
 	if err != nil {
 		return nil, err
@@ -458,6 +614,11 @@ func (b *builder) newCheck(meta *compliance.SuiteMeta, ruleScope compliance.Rule
 		notify = b.status.updateCheck
 	}
 
+	var sampler *hostSampler
+	if rule.Sampling != nil {
+		sampler = newHostSampler(b.hostname, rule.ID, rule.Sampling.Rate, b.checkInterval)
+	}
+
 	// We capture err as configuration error but do not prevent check creation
 	return &complianceCheck{
 		Env: b,
@@ -472,6 +633,8 @@ func (b *builder) newCheck(meta *compliance.SuiteMeta, ruleScope compliance.Rule
 		resourceType: string(ruleScope),
 		resourceID:   b.hostname,
 		checkable:    checkable,
+		sampler:      sampler,
+		usage:        b.usage.addRule(rule.ID, compliance.CheckName(rule.ID, rule.Description)),
 
 		eventNotify: notify,
 	}, nil
@@ -493,6 +656,10 @@ func (b *builder) KubeClient() env.KubeClient {
 	return b.kubeClient
 }
 
+func (b *builder) OsqueryClient() env.OsqueryClient {
+	return b.osqueryClient
+}
+
 func (b *builder) Hostname() string {
 	return b.hostname
 }
@@ -502,6 +669,9 @@ func (b *builder) EtcGroupPath() string {
 }
 
 func (b *builder) NormalizeToHostRoot(path string) string {
+	if b.snapshot != nil && b.snapshot.contains(path) {
+		return b.snapshot.normalize(path)
+	}
 	if b.pathMapper == nil {
 		return path
 	}
@@ -509,12 +679,21 @@ func (b *builder) NormalizeToHostRoot(path string) string {
 }
 
 func (b *builder) RelativeToHostRoot(path string) string {
+	if b.snapshot != nil {
+		if p, ok := b.snapshot.relative(path); ok {
+			return p
+		}
+	}
 	if b.pathMapper == nil {
 		return path
 	}
 	return b.pathMapper.relativeToHostRoot(path)
 }
 
+func (b *builder) HostNamespace() env.Namespace {
+	return b.hostNamespace
+}
+
 func (b *builder) IsLeader() bool {
 	if b.isLeaderFunc != nil {
 		return b.isLeaderFunc()
@@ -522,12 +701,19 @@ func (b *builder) IsLeader() bool {
 	return true
 }
 
+func (b *builder) ResolveParameter(name string) (interface{}, bool, error) {
+	if b.parameterResolver == nil {
+		return nil, false, nil
+	}
+	return b.parameterResolver.ResolveParameter(name)
+}
+
 func (b *builder) EvaluateFromCache(ev eval.Evaluatable) (interface{}, error) {
 	instance := &eval.Instance{
 		Functions: eval.FunctionMap{
-			builderFuncShell:       b.withValueCache(builderFuncShell, evalCommandShell),
-			builderFuncExec:        b.withValueCache(builderFuncExec, evalCommandExec),
-			builderFuncProcessFlag: b.withValueCache(builderFuncProcessFlag, evalProcessFlag),
+			builderFuncShell:       b.withValueCache(builderFuncShell, b.evalCommandShell),
+			builderFuncExec:        b.withValueCache(builderFuncExec, b.evalCommandExec),
+			builderFuncProcessFlag: b.withValueCache(builderFuncProcessFlag, b.evalProcessFlag),
 			builderFuncJSON:        b.withValueCache(builderFuncJSON, b.evalValueFromFile(jsonGetter)),
 			builderFuncYAML:        b.withValueCache(builderFuncYAML, b.evalValueFromFile(yamlGetter)),
 		},
@@ -554,7 +740,7 @@ func (b *builder) withValueCache(funcName string, fn eval.Function) eval.Functio
 	}
 }
 
-func evalCommandShell(_ *eval.Instance, args ...interface{}) (interface{}, error) {
+func (b *builder) evalCommandShell(_ *eval.Instance, args ...interface{}) (interface{}, error) {
 	if len(args) == 0 {
 		return nil, errors.New(`expecting at least one argument`)
 	}
@@ -574,10 +760,10 @@ func evalCommandShell(_ *eval.Instance, args ...interface{}) (interface{}, error
 			shellAndArgs = append(shellAndArgs, s)
 		}
 	}
-	return valueFromShellCommand(command, shellAndArgs...)
+	return valueFromShellCommand(b.hostNamespace, command, shellAndArgs...)
 }
 
-func valueFromShellCommand(command string, shellAndArgs ...string) (interface{}, error) {
+func valueFromShellCommand(ns env.Namespace, command string, shellAndArgs ...string) (interface{}, error) {
 	log.Debugf("Resolving value from shell command: %s, args [%s]", command, strings.Join(shellAndArgs, ","))
 
 	shellCmd := &compliance.ShellCmd{
@@ -590,14 +776,14 @@ func valueFromShellCommand(command string, shellAndArgs ...string) (interface{},
 		}
 	}
 	execCommand := shellCmdToBinaryCmd(shellCmd)
-	exitCode, stdout, err := runBinaryCmd(execCommand, defaultTimeout)
+	exitCode, stdout, err := runBinaryCmd(ns, execCommand, defaultTimeout)
 	if exitCode != 0 || err != nil {
 		return nil, fmt.Errorf("command '%v' execution failed, error: %v", command, err)
 	}
 	return stdout, nil
 }
 
-func evalCommandExec(_ *eval.Instance, args ...interface{}) (interface{}, error) {
+func (b *builder) evalCommandExec(_ *eval.Instance, args ...interface{}) (interface{}, error) {
 	if len(args) == 0 {
 		return nil, errors.New(`expecting at least one argument`)
 	}
@@ -612,23 +798,23 @@ func evalCommandExec(_ *eval.Instance, args ...interface{}) (interface{}, error)
 		cmdArgs = append(cmdArgs, s)
 	}
 
-	return valueFromBinaryCommand(cmdArgs[0], cmdArgs[1:]...)
+	return valueFromBinaryCommand(b.hostNamespace, cmdArgs[0], cmdArgs[1:]...)
 }
 
-func valueFromBinaryCommand(name string, args ...string) (interface{}, error) {
+func valueFromBinaryCommand(ns env.Namespace, name string, args ...string) (interface{}, error) {
 	log.Debugf("Resolving value from command: %s, args [%s]", name, strings.Join(args, ","))
 	execCommand := &compliance.BinaryCmd{
 		Name: name,
 		Args: args,
 	}
-	exitCode, stdout, err := runBinaryCmd(execCommand, defaultTimeout)
+	exitCode, stdout, err := runBinaryCmd(ns, execCommand, defaultTimeout)
 	if exitCode != 0 || err != nil {
 		return nil, fmt.Errorf("command '%v' execution failed, error: %v", execCommand, err)
 	}
 	return stdout, nil
 }
 
-func evalProcessFlag(_ *eval.Instance, args ...interface{}) (interface{}, error) {
+func (b *builder) evalProcessFlag(_ *eval.Instance, args ...interface{}) (interface{}, error) {
 	if len(args) != 2 {
 		return nil, errors.New(`expecting two arguments`)
 	}
@@ -640,10 +826,10 @@ func evalProcessFlag(_ *eval.Instance, args ...interface{}) (interface{}, error)
 	if !ok {
 		return nil, fmt.Errorf(`expecting string value for process flag argument`)
 	}
-	return valueFromProcessFlag(name, flag)
+	return valueFromProcessFlag(b.hostNamespace, name, flag)
 }
 
-func valueFromProcessFlag(name string, flag string) (interface{}, error) {
+func valueFromProcessFlag(ns env.Namespace, name string, flag string) (interface{}, error) {
 	log.Debugf("Resolving value from process: %s, flag %s", name, flag)
 
 	processes, err := getProcesses(cacheValidity)
@@ -651,7 +837,7 @@ func valueFromProcessFlag(name string, flag string) (interface{}, error) {
 		return "", fmt.Errorf("unable to fetch processes: %w", err)
 	}
 
-	matchedProcesses := processes.findProcessesByName(name)
+	matchedProcesses := processes.inNamespace(ns).findProcessesByName(name)
 	for _, mp := range matchedProcesses {
 		flagValues := parseProcessCmdLine(mp.Cmdline)
 		return flagValues[flag], nil
@@ -675,6 +861,6 @@ func (b *builder) evalValueFromFile(get getter) eval.Function {
 		if !ok {
 			return nil, fmt.Errorf(`expecting string value for query argument`)
 		}
-		return queryValueFromFile(path, query, get)
+		return queryValueFromFile(nil, path, query, get)
 	}
 }
@@ -121,7 +121,7 @@ func TestResolveValueFrom(t *testing.T) {
 			name:       "from shell command",
 			expression: `shell("cat /home/root/hiya-buddy.txt", "/bin/bash")`,
 			setup: func(t *testing.T) {
-				commandRunner = func(ctx context.Context, name string, args []string, captureStdout bool) (int, []byte, error) {
+				commandRunner = func(ctx context.Context, _ env.Namespace, name string, args []string, captureStdout bool) (int, []byte, error) {
 					assert.Equal("/bin/bash", name)
 					assert.Equal([]string{"cat /home/root/hiya-buddy.txt"}, args)
 					return 0, []byte("hiya buddy"), nil
@@ -133,7 +133,7 @@ func TestResolveValueFrom(t *testing.T) {
 			name:       "from binary command",
 			expression: `exec("/bin/buddy", "/home/root/hiya-buddy.txt")`,
 			setup: func(t *testing.T) {
-				commandRunner = func(ctx context.Context, name string, args []string, captureStdout bool) (int, []byte, error) {
+				commandRunner = func(ctx context.Context, _ env.Namespace, name string, args []string, captureStdout bool) (int, []byte, error) {
 					assert.Equal("/bin/buddy", name)
 					assert.Equal([]string{"/home/root/hiya-buddy.txt"}, args)
 					return 0, []byte("hiya buddy"), nil
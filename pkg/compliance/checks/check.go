@@ -34,6 +34,12 @@ type complianceCheck struct {
 
 	checkable checkable
 
+	// sampler is set for rules evaluated on a fraction of the hosts at each interval
+	sampler *hostSampler
+
+	// usage accounts the resources consumed by the runs of the check
+	usage *ruleUsage
+
 	eventNotify eventNotify
 }
 
@@ -84,23 +90,37 @@ func (c *complianceCheck) Run() error {
 		return nil
 	}
 
-	report, err := c.checkable.check(c)
+	if c.sampler != nil && !c.sampler.sampled(time.Now()) {
+		log.Debugf("%s: skipped, host not sampled during this interval", c.ruleID)
+		return nil
+	}
+
+	var (
+		report *compliance.Report
+		err    error
+	)
+	start := time.Now()
+	cpuTime := measureCPU(func() {
+		report, err = c.checkable.check(c)
+	})
+	c.usage.addRun(cpuTime, time.Since(start))
 	if err != nil {
 		log.Warnf("%s: check run failed: %v", c.ruleID, err)
 	}
 	data, result := reportToEventData(report, err)
 
 	e := &event.Event{
-		AgentRuleID:  c.ruleID,
-		ResourceID:   c.resourceID,
-		ResourceType: c.resourceType,
-		Result:       result,
-		Data:         data,
+		AgentRuleID:      c.ruleID,
+		ResourceID:       c.resourceID,
+		ResourceType:     c.resourceType,
+		ResourceIdentity: c.resourceIdentity(report),
+		Result:           result,
+		Data:             data,
 	}
 
 	log.Debugf("%s: reporting [%s]", c.ruleID, e.Result)
 
-	c.Reporter().Report(e)
+	c.report(e)
 	if c.eventNotify != nil {
 		c.eventNotify(c.ruleID, e)
 	}
@@ -108,6 +128,36 @@ func (c *complianceCheck) Run() error {
 	return err
 }
 
+// ruleUsage implements usageEnv
+func (c *complianceCheck) ruleUsage() *ruleUsage {
+	return c.usage
+}
+
+// report reports e, along with the manifest of the check when the reporter batches the events
+// per manifest.
+func (c *complianceCheck) report(e *event.Event) {
+	reporter := c.Reporter()
+	if r, ok := reporter.(event.ManifestReporter); ok {
+		r.ReportWithManifest(event.Manifest{
+			SuiteName:    c.suiteMeta.Name,
+			SuiteVersion: c.suiteMeta.Version,
+			Framework:    c.suiteMeta.Framework,
+			Hostname:     c.Hostname(),
+		}, e)
+		return
+	}
+	reporter.Report(e)
+}
+
+// resourceIdentity returns the identity of the resource evaluated in report, falling back
+// to the resource the check runs against when the evaluated resource has no identity.
+func (c *complianceCheck) resourceIdentity(report *compliance.Report) string {
+	if report != nil && report.Identity != "" {
+		return report.Identity
+	}
+	return c.resourceType + ":" + c.resourceID
+}
+
 func reportToEventData(report *compliance.Report, err error) (event.Data, string) {
 	var (
 		data   event.Data
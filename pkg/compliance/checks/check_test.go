@@ -39,12 +39,14 @@ func TestCheckRun(t *testing.T) {
 				Data: event.Data{
 					"file.permissions": 0644,
 				},
+				Identity: "file:2049:/etc/passwd",
 			},
 			expectEvent: &event.Event{
-				AgentRuleID:  ruleID,
-				ResourceType: resourceType,
-				ResourceID:   resourceID,
-				Result:       "passed",
+				AgentRuleID:      ruleID,
+				ResourceType:     resourceType,
+				ResourceID:       resourceID,
+				ResourceIdentity: "file:2049:/etc/passwd",
+				Result:           "passed",
 				Data: event.Data{
 					"file.permissions": 0644,
 				},
@@ -59,10 +61,11 @@ func TestCheckRun(t *testing.T) {
 				},
 			},
 			expectEvent: &event.Event{
-				AgentRuleID:  ruleID,
-				ResourceType: resourceType,
-				ResourceID:   resourceID,
-				Result:       "failed",
+				AgentRuleID:      ruleID,
+				ResourceType:     resourceType,
+				ResourceID:       resourceID,
+				ResourceIdentity: "resource-type:resource-id",
+				Result:           "failed",
 				Data: event.Data{
 					"file.permissions": 0644,
 				},
@@ -72,10 +75,11 @@ func TestCheckRun(t *testing.T) {
 			name:     "check error",
 			checkErr: errors.New("check error"),
 			expectEvent: &event.Event{
-				AgentRuleID:  ruleID,
-				ResourceType: resourceType,
-				ResourceID:   resourceID,
-				Result:       "error",
+				AgentRuleID:      ruleID,
+				ResourceType:     resourceType,
+				ResourceID:       resourceID,
+				ResourceIdentity: "resource-type:resource-id",
+				Result:           "error",
 				Data: event.Data{
 					"error": "check error",
 				},
@@ -24,7 +24,7 @@ var commandReportedFields = []string{
 	compliance.CommandFieldExitCode,
 }
 
-func resolveCommand(ctx context.Context, _ env.Env, ruleID string, res compliance.Resource) (interface{}, error) {
+func resolveCommand(ctx context.Context, e env.Env, ruleID string, res compliance.Resource) (interface{}, error) {
 	if res.Command == nil {
 		return nil, fmt.Errorf("%s: expecting command resource in command check", ruleID)
 	}
@@ -53,7 +53,7 @@ func resolveCommand(ctx context.Context, _ env.Env, ruleID string, res complianc
 	context, cancel := context.WithTimeout(ctx, commandTimeout)
 	defer cancel()
 
-	exitCode, stdout, err := commandRunner(context, execCommand.Name, execCommand.Args, true)
+	exitCode, stdout, err := commandRunner(context, e.HostNamespace(), execCommand.Name, execCommand.Args, true)
 	if exitCode == -1 && err != nil {
 		return nil, fmt.Errorf("command '%v' execution failed, error: %v", command, err)
 	}
@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
 	"github.com/DataDog/datadog-agent/pkg/compliance/event"
 	"github.com/DataDog/datadog-agent/pkg/compliance/mocks"
 
@@ -37,7 +38,7 @@ type commandFixture struct {
 }
 
 func (f *commandFixture) mockRunCommand(t *testing.T) commandRunnerFunc {
-	return func(ctx context.Context, name string, args []string, captureStdout bool) (int, []byte, error) {
+	return func(ctx context.Context, _ env.Namespace, name string, args []string, captureStdout bool) (int, []byte, error) {
 		assert.Equal(t, f.expectCommandName, name)
 		assert.Equal(t, f.expectCommandArgs, args)
 		return f.commandExitCode, []byte(f.commandOutput), f.commandError
@@ -51,6 +52,7 @@ func (f *commandFixture) run(t *testing.T) {
 	commandRunner = f.mockRunCommand(t)
 
 	env := &mocks.Env{}
+	env.On("HostNamespace").Return(nil).Maybe()
 	defer env.AssertExpectations(t)
 
 	commandCheck, err := newResourceCheck(env, "rule-id", f.resource)
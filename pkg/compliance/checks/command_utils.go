@@ -15,9 +15,10 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
 )
 
-type commandRunnerFunc func(context.Context, string, []string, bool) (int, []byte, error)
+type commandRunnerFunc func(context.Context, env.Namespace, string, []string, bool) (int, []byte, error)
 
 var (
 	commandRunner commandRunnerFunc = runCommand
@@ -50,25 +51,32 @@ func shellCmdToBinaryCmd(shellCmd *compliance.ShellCmd) *compliance.BinaryCmd {
 	return execCmd
 }
 
-func runBinaryCmd(execCommand *compliance.BinaryCmd, timeout time.Duration) (int, string, error) {
+func runBinaryCmd(ns env.Namespace, execCommand *compliance.BinaryCmd, timeout time.Duration) (int, string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	exitCode, stdout, err := commandRunner(ctx, execCommand.Name, execCommand.Args, true)
+	exitCode, stdout, err := commandRunner(ctx, ns, execCommand.Name, execCommand.Args, true)
 	return exitCode, string(stdout), err
 }
 
-func runCommand(ctx context.Context, name string, args []string, captureStdout bool) (int, []byte, error) {
+// runCommand runs a command, from the root filesystem and in the namespaces of ns when not nil
+func runCommand(ctx context.Context, ns env.Namespace, name string, args []string, captureStdout bool) (int, []byte, error) {
 	if len(name) == 0 {
 		return 0, nil, errors.New("cannot run empty command")
 	}
 
-	_, err := exec.LookPath(name)
-	if err != nil {
-		return 0, nil, fmt.Errorf("command '%s' not found, err: %v", name, err)
+	var cmd *exec.Cmd
+	if ns != nil {
+		var err error
+		if cmd, err = namespaceCommand(ctx, ns, name, args); err != nil {
+			return 0, nil, err
+		}
+	} else {
+		if _, err := exec.LookPath(name); err != nil {
+			return 0, nil, fmt.Errorf("command '%s' not found, err: %v", name, err)
+		}
+		cmd = exec.CommandContext(ctx, name, args...)
 	}
-
-	cmd := exec.CommandContext(ctx, name, args...)
 	if cmd == nil {
 		return 0, nil, errors.New("unable to create command context")
 	}
@@ -78,7 +86,15 @@ func runCommand(ctx context.Context, name string, args []string, captureStdout b
 		cmd.Stdout = &stdoutBuffer
 	}
 
-	err = cmd.Run()
+	var err error
+	if ns != nil {
+		// the command inherits the namespaces of the thread it is started from
+		if err = ns.Run(cmd.Start); err == nil {
+			err = cmd.Wait()
+		}
+	} else {
+		err = cmd.Run()
+	}
 
 	// We expect ExitError as commands may have an exitCode != 0
 	// It's not a failure for a compliance command
@@ -88,6 +104,7 @@ func runCommand(ctx context.Context, name string, args []string, captureStdout b
 	}
 
 	if cmd.ProcessState != nil {
+		ruleUsageFromContext(ctx).addSubprocess(cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime())
 		return cmd.ProcessState.ExitCode(), stdoutBuffer.Bytes(), err
 	}
 	return -1, nil, fmt.Errorf("unable to retrieve exit code, err: %v", err)
@@ -22,6 +22,7 @@ type Clients interface {
 	DockerClient() DockerClient
 	AuditClient() AuditClient
 	KubeClient() KubeClient
+	OsqueryClient() OsqueryClient
 }
 
 // Configuration provides an abstraction for various environment methods used by checks
@@ -32,4 +33,16 @@ type Configuration interface {
 	RelativeToHostRoot(path string) string
 	EvaluateFromCache(e eval.Evaluatable) (interface{}, error)
 	IsLeader() bool
+	ResolveParameter(name string) (interface{}, bool, error)
+	HostNamespace() Namespace
+}
+
+// Namespace provides an abstraction for running checks in the namespaces of another process
+type Namespace interface {
+	// Root returns the path of the root filesystem of the namespace
+	Root() string
+	// Run runs f on a thread moved into the namespaces
+	Run(f func() error) error
+	// ContainsProcess returns whether a process belongs to the PID namespace
+	ContainsProcess(pid int32) bool
 }
@@ -0,0 +1,14 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package env
+
+import "context"
+
+// OsqueryClient defines the interface for running queries through osqueryd
+type OsqueryClient interface {
+	Query(ctx context.Context, sql string) ([]map[string]string, error)
+	Close() error
+}
@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/eval"
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	evidenceField          = "evidence"
+	evidenceTruncatedField = "evidence_truncated"
+	evidenceRedacted       = "<redacted>"
+)
+
+// defaultEvidenceRedaction removes common secrets (e.g. "password = hunter2") from all evidences
+var defaultEvidenceRedaction = regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api_?key)\s*[=:]\s*)\S+`)
+
+// evidenceCollector attaches an excerpt of the evaluated resource to reports
+type evidenceCollector struct {
+	expression *eval.Expression
+	redact     []*regexp.Regexp
+	maxSize    int
+}
+
+func newEvidenceCollector(evidence *compliance.Evidence) (*evidenceCollector, error) {
+	expression, err := eval.Cache.ParseExpression(evidence.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid evidence expression %q: %w", evidence.Expression, err)
+	}
+
+	maxSize := evidence.MaxSize
+	if maxSize <= 0 {
+		maxSize = compliance.DefaultEvidenceSize
+	} else if maxSize > compliance.MaxEvidenceSize {
+		maxSize = compliance.MaxEvidenceSize
+	}
+
+	c := &evidenceCollector{
+		expression: expression,
+		maxSize:    maxSize,
+	}
+	for _, expr := range evidence.Redact {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid evidence redaction %q: %w", expr, err)
+		}
+		c.redact = append(c.redact, re)
+	}
+	return c, nil
+}
+
+// attach evaluates the evidence against instance and adds it to report. Failing to
+// collect the evidence does not fail the check.
+func (c *evidenceCollector) attach(instance *eval.Instance, report *compliance.Report) {
+	if instance == nil || report == nil {
+		return
+	}
+
+	v, err := c.expression.Evaluate(instance)
+	if err != nil {
+		log.Warnf("failed to collect evidence: %v", err)
+		return
+	}
+
+	evidence, truncated := c.format(v)
+	if evidence == "" {
+		return
+	}
+	if report.Data == nil {
+		report.Data = event.Data{}
+	}
+	report.Data[evidenceField] = evidence
+	report.Data[evidenceTruncatedField] = truncated
+}
+
+// format converts an evaluated value to a redacted evidence, truncated to the maximum size
+func (c *evidenceCollector) format(v interface{}) (string, bool) {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		s = v
+	case []string:
+		s = strings.Join(v, "\n")
+	case []interface{}:
+		lines := make([]string, len(v))
+		for i, item := range v {
+			lines[i] = fmt.Sprint(item)
+		}
+		s = strings.Join(lines, "\n")
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	s = defaultEvidenceRedaction.ReplaceAllString(s, "${1}"+evidenceRedacted)
+	for _, re := range c.redact {
+		s = re.ReplaceAllString(s, evidenceRedacted)
+	}
+
+	if len(s) <= c.maxSize {
+		return s, false
+	}
+	// do not cut a multi-byte character in half
+	n := c.maxSize
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n], true
+}
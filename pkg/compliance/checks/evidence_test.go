@@ -0,0 +1,129 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/eval"
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestEvidenceCollector(t *testing.T) {
+	tests := []struct {
+		name              string
+		evidence          compliance.Evidence
+		vars              eval.VarMap
+		expectEvidence    interface{}
+		expectTruncated   interface{}
+		expectCompileFail bool
+	}{
+		{
+			name:            "string",
+			evidence:        compliance.Evidence{Expression: "command.stdout"},
+			vars:            eval.VarMap{"command.stdout": "PermitRootLogin no"},
+			expectEvidence:  "PermitRootLogin no",
+			expectTruncated: false,
+		},
+		{
+			name:            "array",
+			evidence:        compliance.Evidence{Expression: "process.cmdLine"},
+			vars:            eval.VarMap{"process.cmdLine": []interface{}{"dockerd", "--icc=false"}},
+			expectEvidence:  "dockerd\n--icc=false",
+			expectTruncated: false,
+		},
+		{
+			name:            "default redaction",
+			evidence:        compliance.Evidence{Expression: "command.stdout"},
+			vars:            eval.VarMap{"command.stdout": "user=admin\npassword = hunter2\napi_key: abcd"},
+			expectEvidence:  "user=admin\npassword = <redacted>\napi_key: <redacted>",
+			expectTruncated: false,
+		},
+		{
+			name: "custom redaction",
+			evidence: compliance.Evidence{
+				Expression: "command.stdout",
+				Redact:     []string{`[0-9]{1,3}(\.[0-9]{1,3}){3}`},
+			},
+			vars:            eval.VarMap{"command.stdout": "listening on 10.0.0.1:8080"},
+			expectEvidence:  "listening on <redacted>:8080",
+			expectTruncated: false,
+		},
+		{
+			name: "multi-line redaction",
+			evidence: compliance.Evidence{
+				Expression: "command.stdout",
+				Redact:     []string{`(?m)^hostkey .*$`},
+			},
+			vars:            eval.VarMap{"command.stdout": "port 22\nhostkey /etc/ssh/ssh_host_rsa_key\npermitrootlogin no\nhostkey /etc/ssh/ssh_host_ed25519_key"},
+			expectEvidence:  "port 22\n<redacted>\npermitrootlogin no\n<redacted>",
+			expectTruncated: false,
+		},
+		{
+			name: "truncated",
+			evidence: compliance.Evidence{
+				Expression: "command.stdout",
+				MaxSize:    4,
+			},
+			vars:            eval.VarMap{"command.stdout": "abcdef"},
+			expectEvidence:  "abcd",
+			expectTruncated: true,
+		},
+		{
+			name: "truncated at rune boundary",
+			evidence: compliance.Evidence{
+				Expression: "command.stdout",
+				MaxSize:    4,
+			},
+			vars:            eval.VarMap{"command.stdout": "abcé"},
+			expectEvidence:  "abc",
+			expectTruncated: true,
+		},
+		{
+			name: "maximum size",
+			evidence: compliance.Evidence{
+				Expression: "command.stdout",
+				MaxSize:    compliance.MaxEvidenceSize * 2,
+			},
+			vars:            eval.VarMap{"command.stdout": strings.Repeat("a", compliance.MaxEvidenceSize+1)},
+			expectEvidence:  strings.Repeat("a", compliance.MaxEvidenceSize),
+			expectTruncated: true,
+		},
+		{
+			name:     "evaluation error",
+			evidence: compliance.Evidence{Expression: "command.unknown"},
+			vars:     eval.VarMap{"command.stdout": "abc"},
+		},
+		{
+			name:              "invalid redaction",
+			evidence:          compliance.Evidence{Expression: "command.stdout", Redact: []string{"("}},
+			expectCompileFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c, err := newEvidenceCollector(&test.evidence)
+			if test.expectCompileFail {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+
+			report := &compliance.Report{Data: event.Data{}, Passed: true}
+			c.attach(&eval.Instance{Vars: test.vars}, report)
+			assert.Equal(test.expectEvidence, report.Data[evidenceField])
+			assert.Equal(test.expectTruncated, report.Data[evidenceTruncatedField])
+			assert.True(report.Passed)
+		})
+	}
+}
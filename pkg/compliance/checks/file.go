@@ -46,3 +46,20 @@ func getFileGroup(fi os.FileInfo) (string, error) {
 	}
 	return g, nil
 }
+
+func getFileDevice(fi os.FileInfo) (uint64, error) {
+	statt, err := getFileStatt(fi)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(statt.Dev), nil
+}
+
+// copyFileOwner sets the owner and group of the file at path to those described by fi
+func copyFileOwner(path string, fi os.FileInfo) error {
+	statt, err := getFileStatt(fi)
+	if err != nil {
+		return err
+	}
+	return os.Lchown(path, int(statt.Uid), int(statt.Gid))
+}
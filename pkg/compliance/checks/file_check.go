@@ -7,7 +7,10 @@ package checks
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -44,6 +47,7 @@ func resolveFile(_ context.Context, e env.Env, ruleID string, res compliance.Res
 	}
 
 	var instances []*eval.Instance
+	usage := usageFromEnv(e)
 
 	for _, path := range paths {
 		// Re-computing relative after glob filtering
@@ -61,9 +65,11 @@ func resolveFile(_ context.Context, e env.Env, ruleID string, res compliance.Res
 				compliance.FileFieldPermissions: uint64(fi.Mode() & os.ModePerm),
 			},
 			Functions: eval.FunctionMap{
-				compliance.FileFuncJQ:     fileJQ(path),
-				compliance.FileFuncYAML:   fileYAML(path),
-				compliance.FileFuncRegexp: fileRegexp(path),
+				compliance.FileFuncJQ:     fileJQ(usage, path),
+				compliance.FileFuncYAML:   fileYAML(usage, path),
+				compliance.FileFuncRegexp: fileRegexp(usage, path),
+				compliance.FileFuncLines:  fileLines(usage, path),
+				compliance.FileFuncSHA256: fileSHA256(usage, path),
 			},
 		}
 
@@ -77,6 +83,11 @@ func resolveFile(_ context.Context, e env.Env, ruleID string, res compliance.Res
 			instance.Vars[compliance.FileFieldGroup] = group
 		}
 
+		device, err := getFileDevice(fi)
+		if err == nil {
+			instance.Vars[compliance.FileFieldDevice] = device
+		}
+
 		instances = append(instances, instance)
 	}
 
@@ -89,7 +100,7 @@ func resolveFile(_ context.Context, e env.Env, ruleID string, res compliance.Res
 	}, nil
 }
 
-func fileQuery(path string, get getter) eval.Function {
+func fileQuery(usage *ruleUsage, path string, get getter) eval.Function {
 	return func(_ *eval.Instance, args ...interface{}) (interface{}, error) {
 		if len(args) != 1 {
 			return nil, fmt.Errorf(`invalid number of arguments, expecting 1 got %d`, len(args))
@@ -98,18 +109,40 @@ func fileQuery(path string, get getter) eval.Function {
 		if !ok {
 			return nil, fmt.Errorf(`expecting string value for query argument`)
 		}
-		return queryValueFromFile(path, query, get)
+		return queryValueFromFile(usage, path, query, get)
 	}
 }
 
-func fileJQ(path string) eval.Function {
-	return fileQuery(path, jsonGetter)
+func fileJQ(usage *ruleUsage, path string) eval.Function {
+	return fileQuery(usage, path, jsonGetter)
+}
+
+func fileYAML(usage *ruleUsage, path string) eval.Function {
+	return fileQuery(usage, path, yamlGetter)
+}
+
+func fileRegexp(usage *ruleUsage, path string) eval.Function {
+	return fileQuery(usage, path, regexpGetter)
 }
 
-func fileYAML(path string) eval.Function {
-	return fileQuery(path, yamlGetter)
+func fileLines(usage *ruleUsage, path string) eval.Function {
+	return fileQuery(usage, path, linesGetter)
 }
 
-func fileRegexp(path string) eval.Function {
-	return fileQuery(path, regexpGetter)
+func fileSHA256(usage *ruleUsage, path string) eval.Function {
+	return func(_ *eval.Instance, args ...interface{}) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf(`invalid number of arguments, expecting 0 got %d`, len(args))
+		}
+		f, err := openFile(usage, path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
 }
@@ -275,6 +275,48 @@ func TestFileCheck(t *testing.T) {
 				assert.NotEmpty(report.Data["file.group"])
 			},
 		},
+		{
+			name: "lines with evidence",
+			resource: compliance.Resource{
+				File: &compliance.File{
+					Path: "/etc/docker/daemon.json",
+				},
+				Condition: `file.lines("\"(icc|iptables)\"") != ""`,
+				Evidence: &compliance.Evidence{
+					Expression: `file.lines("\"(icc|iptables)\"")`,
+				},
+			},
+			setup: func(t *testing.T, env *mocks.Env, file *compliance.File) {
+				env.On("NormalizeToHostRoot", file.Path).Return("./testdata/file/daemon.json")
+				env.On("RelativeToHostRoot", "./testdata/file/daemon.json").Return(file.Path)
+			},
+			validate: func(t *testing.T, file *compliance.File, report *compliance.Report) {
+				assert.True(report.Passed)
+				assert.Equal("/etc/docker/daemon.json", report.Data["file.path"])
+				assert.Equal("    \"iptables\": false,\n    \"icc\": false,", report.Data["evidence"])
+				assert.Equal(false, report.Data["evidence_truncated"])
+			},
+		},
+		{
+			name: "sha256",
+			resource: compliance.Resource{
+				File: &compliance.File{
+					Path: "/etc/docker/daemon.json",
+				},
+				Condition: `file.sha256() == "0000000000000000000000000000000000000000000000000000000000000000"`,
+				Evidence: &compliance.Evidence{
+					Expression: `file.sha256()`,
+				},
+			},
+			setup: func(t *testing.T, env *mocks.Env, file *compliance.File) {
+				env.On("NormalizeToHostRoot", file.Path).Return("./testdata/file/daemon.json")
+				env.On("RelativeToHostRoot", "./testdata/file/daemon.json").Return(file.Path)
+			},
+			validate: func(t *testing.T, file *compliance.File, report *compliance.Report) {
+				assert.False(report.Passed)
+				assert.Equal("d08190ad49cab6425453cea9b142737a8e376d6c34d2086f120d398136940ae9", report.Data["evidence"])
+			},
+		},
 	}
 
 	for _, test := range tests {
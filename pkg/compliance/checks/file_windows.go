@@ -19,3 +19,11 @@ func getFileUser(fi os.FileInfo) (string, error) {
 func getFileGroup(fi os.FileInfo) (string, error) {
 	return "", errors.New("retrieving file group not supported in windows")
 }
+
+func getFileDevice(fi os.FileInfo) (uint64, error) {
+	return 0, errors.New("retrieving file device not supported in windows")
+}
+
+func copyFileOwner(path string, fi os.FileInfo) error {
+	return nil
+}
@@ -9,7 +9,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"regexp"
 	"strings"
 	"text/template"
@@ -61,9 +60,26 @@ func regexpGetter(data []byte, expr string) (string, error) {
 	return string(match), nil
 }
 
+// linesGetter retrieves all the lines matching regexp
+func linesGetter(data []byte, expr string) (string, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if re.MatchString(line) {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
 // queryValueFromFile retrieves a value from a file with the provided getter func
-func queryValueFromFile(filePath string, query string, get getter) (string, error) {
-	f, err := os.Open(filePath)
+func queryValueFromFile(usage *ruleUsage, filePath string, query string, get getter) (string, error) {
+	f, err := openFile(usage, filePath)
 	if err != nil {
 		return "", err
 	}
@@ -128,8 +144,9 @@ func instanceToReport(instance *eval.Instance, passed bool, allowedFields []stri
 	}
 
 	return &compliance.Report{
-		Passed: passed,
-		Data:   data,
+		Passed:   passed,
+		Data:     data,
+		Identity: resourceIdentity(instance),
 	}
 }
 
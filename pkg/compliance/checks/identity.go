@@ -0,0 +1,50 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/eval"
+)
+
+// resourceIdentity returns a key identifying the resource evaluated as instance, which does not
+// change when the other attributes of the resource (permissions, owner, name, ...) do, so that
+// its compliance can be tracked over time. An empty string is returned for resources without
+// such a key.
+func resourceIdentity(instance *eval.Instance) string {
+	if instance == nil {
+		return ""
+	}
+	vars := instance.Vars
+
+	if path, ok := vars[compliance.FileFieldPath].(string); ok {
+		if device, ok := vars[compliance.FileFieldDevice].(uint64); ok {
+			return fmt.Sprintf("file:%d:%s", device, path)
+		}
+		return "file:" + path
+	}
+	if id, ok := vars[compliance.DockerContainerFieldID].(string); ok {
+		return "container:" + id
+	}
+	if id, ok := vars[compliance.DockerImageFieldID].(string); ok {
+		return "image:" + id
+	}
+	if id, ok := vars[compliance.DockerNetworkFieldID].(string); ok {
+		return "network:" + id
+	}
+	if cmdLine, ok := vars[compliance.ProcessFieldCmdLine].([]string); ok {
+		name, _ := vars[compliance.ProcessFieldName].(string)
+		// the command line may hold secrets, only its hash is reported
+		h := sha256.Sum256([]byte(strings.Join(cmdLine, "\x00")))
+		return "process:" + name + ":" + hex.EncodeToString(h[:8])
+	}
+	return ""
+}
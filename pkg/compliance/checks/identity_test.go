@@ -0,0 +1,87 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance/eval"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestResourceIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		vars     eval.VarMap
+		expected string
+	}{
+		{
+			name: "file",
+			vars: eval.VarMap{
+				"file.path":        "/etc/passwd",
+				"file.device":      uint64(2049),
+				"file.permissions": uint64(0644),
+			},
+			expected: "file:2049:/etc/passwd",
+		},
+		{
+			name:     "file without device",
+			vars:     eval.VarMap{"file.path": "/etc/passwd"},
+			expected: "file:/etc/passwd",
+		},
+		{
+			name: "container",
+			vars: eval.VarMap{
+				"container.id":   "3c4bd9d35d42",
+				"container.name": "/sharp_cori",
+			},
+			expected: "container:3c4bd9d35d42",
+		},
+		{
+			name: "process",
+			vars: eval.VarMap{
+				"process.name":    "dockerd",
+				"process.exe":     "/usr/bin/dockerd",
+				"process.cmdLine": []string{"dockerd", "--tlsverify"},
+			},
+			expected: "process:dockerd:3910f93b9f62cb4a",
+		},
+		{
+			name:     "no identity",
+			vars:     eval.VarMap{"group.name": "docker"},
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, resourceIdentity(&eval.Instance{Vars: test.vars}))
+		})
+	}
+}
+
+func TestResourceIdentityStable(t *testing.T) {
+	assert := assert.New(t)
+
+	before := resourceIdentity(&eval.Instance{Vars: eval.VarMap{
+		"process.name":    "dockerd",
+		"process.exe":     "/usr/bin/dockerd",
+		"process.cmdLine": []string{"dockerd", "--tlsverify"},
+	}})
+	after := resourceIdentity(&eval.Instance{Vars: eval.VarMap{
+		"process.name":    "dockerd",
+		"process.exe":     "/usr/local/bin/dockerd",
+		"process.cmdLine": []string{"dockerd", "--tlsverify"},
+	}})
+	assert.Equal(before, after)
+
+	changed := resourceIdentity(&eval.Instance{Vars: eval.VarMap{
+		"process.name":    "dockerd",
+		"process.cmdLine": []string{"dockerd", "--tlsverify=false"},
+	}})
+	assert.NotEqual(before, changed)
+	assert.Empty(resourceIdentity(nil))
+}
@@ -73,7 +73,7 @@ func resolveKubeapiserver(ctx context.Context, e env.Env, ruleID string, res com
 		}
 		resource, err := resourceAPI.Get(kubeResource.APIRequest.ResourceName, metav1.GetOptions{})
 		if err != nil {
-			return nil, fmt.Errorf("unable to get Kube resource:'%v', ns:'%s' name:'%s', err: %v", resourceSchema, kubeResource.Namespace, api.ResourceName, err)
+			return nil, fmt.Errorf("unable to get Kube resource:'%v', ns:'%s' name:'%s', err: %w", resourceSchema, kubeResource.Namespace, api.ResourceName, err)
 		}
 		resources = []unstructured.Unstructured{*resource}
 	case "list":
@@ -82,7 +82,7 @@ func resolveKubeapiserver(ctx context.Context, e env.Env, ruleID string, res com
 			FieldSelector: kubeResource.FieldSelector,
 		})
 		if err != nil {
-			return nil, fmt.Errorf("unable to list Kube resources:'%v', ns:'%s' name:'%s', err: %v", resourceSchema, kubeResource.Namespace, api.ResourceName, err)
+			return nil, fmt.Errorf("unable to list Kube resources:'%v', ns:'%s' name:'%s', err: %w", resourceSchema, kubeResource.Namespace, api.ResourceName, err)
 		}
 		resources = list.Items
 	}
@@ -0,0 +1,170 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package checks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"golang.org/x/sys/unix"
+)
+
+// hostCommandPath is the PATH used to look up commands run in the host namespaces
+const hostCommandPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// namespaceTypes lists the namespaces entered by hostNamespace.Run. A multi-threaded process
+// can not enter another mount namespace, the host filesystem is accessed through the procfs root
+// of the host process instead.
+var namespaceTypes = []struct {
+	name string
+	flag int
+}{
+	{"net", unix.CLONE_NEWNET},
+	{"uts", unix.CLONE_NEWUTS},
+	{"ipc", unix.CLONE_NEWIPC},
+}
+
+// hostNamespace implements env.Namespace for the namespaces of a host process
+type hostNamespace struct {
+	pid   int
+	pidNS string
+}
+
+func newHostNamespace(pid int) (env.Namespace, error) {
+	pidNS, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", pid))
+	if err != nil {
+		return nil, fmt.Errorf("namespaces of process %d are not reachable, the host PID namespace must be shared: %w", pid, err)
+	}
+	return &hostNamespace{
+		pid:   pid,
+		pidNS: pidNS,
+	}, nil
+}
+
+// Root implements env.Namespace
+func (ns *hostNamespace) Root() string {
+	return fmt.Sprintf("/proc/%d/root", ns.pid)
+}
+
+// Run implements env.Namespace. f is run from a dedicated goroutine, whose thread is terminated
+// when it can not be moved back to its original namespaces.
+func (ns *hostNamespace) Run(f func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		restore, err := ns.enter()
+		if err == nil {
+			err = f()
+		}
+		if restoreErr := restore(); restoreErr != nil {
+			log.Errorf("Failed to restore namespaces: %v", restoreErr)
+		} else {
+			runtime.UnlockOSThread()
+		}
+		errCh <- err
+	}()
+	return <-errCh
+}
+
+// enter moves the current thread into the namespaces of the host process. It returns a function
+// moving the thread back to its original namespaces, even when only some of them were entered.
+func (ns *hostNamespace) enter() (func() error, error) {
+	var origins []int
+	restore := func() error {
+		defer closeAll(origins)
+		for i := len(origins) - 1; i >= 0; i-- {
+			if err := unix.Setns(origins[i], namespaceTypes[i].flag); err != nil {
+				return fmt.Errorf("failed to restore %s namespace: %w", namespaceTypes[i].name, err)
+			}
+		}
+		return nil
+	}
+
+	tid := unix.Gettid()
+	for _, nsType := range namespaceTypes {
+		origin, err := unix.Open(fmt.Sprintf("/proc/self/task/%d/ns/%s", tid, nsType.name), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return restore, fmt.Errorf("failed to open %s namespace: %w", nsType.name, err)
+		}
+
+		target, err := unix.Open(fmt.Sprintf("/proc/%d/ns/%s", ns.pid, nsType.name), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			unix.Close(origin)
+			return restore, fmt.Errorf("failed to open host %s namespace: %w", nsType.name, err)
+		}
+		err = unix.Setns(target, nsType.flag)
+		unix.Close(target)
+		if err != nil {
+			unix.Close(origin)
+			return restore, fmt.Errorf("failed to enter host %s namespace: %w", nsType.name, err)
+		}
+		origins = append(origins, origin)
+	}
+	return restore, nil
+}
+
+// ContainsProcess implements env.Namespace
+func (ns *hostNamespace) ContainsProcess(pid int32) bool {
+	pidNS, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", pid))
+	return err == nil && pidNS == ns.pidNS
+}
+
+func closeAll(fds []int) {
+	for _, fd := range fds {
+		unix.Close(fd)
+	}
+}
+
+// namespaceCommand returns a command run from the root filesystem of ns. The command must be
+// started with ns.Run to inherit the other namespaces.
+func namespaceCommand(ctx context.Context, ns env.Namespace, name string, args []string) (*exec.Cmd, error) {
+	path, err := lookPathIn(ns.Root(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = "/"
+	cmd.Env = []string{"PATH=" + hostCommandPath}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Chroot: ns.Root(),
+	}
+	return cmd, nil
+}
+
+// lookPathIn searches for an executable in the directories of hostCommandPath under root. The
+// returned path is relative to root.
+func lookPathIn(root string, name string) (string, error) {
+	if strings.Contains(name, "/") {
+		if isExecutable(filepath.Join(root, name)) {
+			return name, nil
+		}
+		return "", fmt.Errorf("command '%s' not found in host root filesystem", name)
+	}
+	for _, dir := range filepath.SplitList(hostCommandPath) {
+		path := filepath.Join(dir, name)
+		if isExecutable(filepath.Join(root, path)) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("command '%s' not found in host PATH", name)
+}
+
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir() && fi.Mode()&0111 != 0
+}
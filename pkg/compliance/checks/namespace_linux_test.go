@@ -0,0 +1,82 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package checks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/gopsutil/process"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestHostNamespace(t *testing.T) {
+	assert := assert.New(t)
+
+	ns, err := newHostNamespace(os.Getpid())
+	assert.NoError(err)
+	assert.Equal(fmt.Sprintf("/proc/%d/root", os.Getpid()), ns.Root())
+	assert.True(ns.ContainsProcess(int32(os.Getpid())))
+
+	_, err = newHostNamespace(-1)
+	assert.Error(err)
+
+	if os.Geteuid() != 0 {
+		t.Skip("entering namespaces requires root privileges")
+	}
+	called := false
+	err = ns.Run(func() error {
+		called = true
+		return nil
+	})
+	assert.NoError(err)
+	assert.True(called)
+}
+
+func TestProcessesInNamespace(t *testing.T) {
+	assert := assert.New(t)
+
+	ns, err := newHostNamespace(os.Getpid())
+	assert.NoError(err)
+
+	procs := processes{
+		int32(os.Getpid()): {Name: "self"},
+		-1:                 {Name: "gone"},
+	}
+	assert.Equal(processes{int32(os.Getpid()): &process.FilledProcess{Name: "self"}}, procs.inNamespace(ns))
+	assert.Equal(procs, procs.inNamespace(nil))
+}
+
+func TestLookPathIn(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "host-root")
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	assert.NoError(os.MkdirAll(filepath.Join(root, "usr/bin"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "usr/bin/kubelet"), nil, 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "usr/bin/config"), nil, 0644))
+
+	path, err := lookPathIn(root, "kubelet")
+	assert.NoError(err)
+	assert.Equal("/usr/bin/kubelet", path)
+
+	path, err = lookPathIn(root, "/usr/bin/kubelet")
+	assert.NoError(err)
+	assert.Equal("/usr/bin/kubelet", path)
+
+	_, err = lookPathIn(root, "config")
+	assert.Error(err)
+
+	_, err = lookPathIn(root, "dockerd")
+	assert.Error(err)
+}
@@ -0,0 +1,26 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build !linux
+
+package checks
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+)
+
+var errNamespaceNotSupported = errors.New("host namespaces are only supported on Linux")
+
+func newHostNamespace(pid int) (env.Namespace, error) {
+	return nil, errNamespaceNotSupported
+}
+
+func namespaceCommand(ctx context.Context, ns env.Namespace, name string, args []string) (*exec.Cmd, error) {
+	return nil, errNamespaceNotSupported
+}
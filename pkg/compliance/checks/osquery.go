@@ -0,0 +1,419 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+)
+
+// osqueryClient runs queries through the extension manager of osqueryd, which is exposed on a
+// unix socket using the Thrift binary protocol. Only the "query" call is implemented.
+type osqueryClient struct {
+	socketPath string
+	timeout    time.Duration
+
+	mu    sync.Mutex
+	seqID int32
+}
+
+const (
+	defaultOsqueryTimeout = 30 * time.Second
+
+	// maxOsqueryStringSize bounds the size of the strings read from osqueryd
+	maxOsqueryStringSize = 16 * 1024 * 1024
+
+	// maxOsqueryPrealloc bounds the number of rows and columns allocated ahead of reading them,
+	// as their count is read from the reply
+	maxOsqueryPrealloc = 1024
+)
+
+// Thrift binary protocol constants
+const (
+	thriftVersion1     = 0x80010000
+	thriftVersionMask  = 0xffff0000
+	thriftMessageCall  = 1
+	thriftMessageReply = 2
+	thriftMessageError = 3
+
+	thriftStop   = 0
+	thriftBool   = 2
+	thriftByte   = 3
+	thriftDouble = 4
+	thriftI16    = 6
+	thriftI32    = 8
+	thriftI64    = 10
+	thriftString = 11
+	thriftStruct = 12
+	thriftMap    = 13
+	thriftSet    = 14
+	thriftList   = 15
+)
+
+func newOsqueryClient(socketPath string) (env.OsqueryClient, error) {
+	if socketPath == "" {
+		return nil, errors.New("osquery extension socket not configured")
+	}
+	return &osqueryClient{
+		socketPath: socketPath,
+		timeout:    defaultOsqueryTimeout,
+	}, nil
+}
+
+// Query runs a SQL query and returns the resulting rows
+func (c *osqueryClient) Query(ctx context.Context, sql string) ([]map[string]string, error) {
+	c.mu.Lock()
+	c.seqID++
+	seqID := c.seqID
+	c.mu.Unlock()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to osquery extension socket: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	w := &thriftWriter{w: bufio.NewWriter(conn)}
+	w.messageBegin("query", thriftMessageCall, seqID)
+	w.fieldBegin(thriftString, 1)
+	w.string(sql)
+	w.byte(thriftStop)
+	if err := w.flush(); err != nil {
+		return nil, fmt.Errorf("failed to send osquery query: %w", err)
+	}
+
+	r := &thriftReader{r: bufio.NewReader(conn)}
+	rows, err := readOsqueryQueryReply(r, seqID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read osquery reply: %w", err)
+	}
+	return rows, nil
+}
+
+// Close implements env.OsqueryClient, connections are opened for each query
+func (c *osqueryClient) Close() error {
+	return nil
+}
+
+// readOsqueryQueryReply reads the reply of ExtensionManager.query, which holds an
+// ExtensionResponse:
+//
+//	struct ExtensionStatus { 1: i32 code, 2: string message, 3: i64 uuid }
+//	struct ExtensionResponse { 1: ExtensionStatus status, 2: list<map<string, string>> response }
+func readOsqueryQueryReply(r *thriftReader, seqID int32) ([]map[string]string, error) {
+	name, typ, replySeqID := r.messageBegin()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if typ == thriftMessageError {
+		return nil, readThriftException(r)
+	}
+	if typ != thriftMessageReply || name != "query" || replySeqID != seqID {
+		return nil, fmt.Errorf("unexpected message %q of type %d", name, typ)
+	}
+
+	var (
+		rows       []map[string]string
+		code       int32
+		message    string
+		hasSuccess bool
+	)
+	// query_result struct, the response is field 0
+	r.readStruct(func(id int16, typ byte) bool {
+		if id != 0 || typ != thriftStruct {
+			return false
+		}
+		hasSuccess = true
+		r.readStruct(func(id int16, typ byte) bool {
+			switch {
+			case id == 1 && typ == thriftStruct:
+				r.readStruct(func(id int16, typ byte) bool {
+					switch {
+					case id == 1 && typ == thriftI32:
+						code = r.i32()
+					case id == 2 && typ == thriftString:
+						message = r.string()
+					default:
+						return false
+					}
+					return true
+				})
+			case id == 2 && typ == thriftList:
+				rows = readOsqueryRows(r)
+			default:
+				return false
+			}
+			return true
+		})
+		return true
+	})
+	if r.err != nil {
+		return nil, r.err
+	}
+	if !hasSuccess {
+		return nil, errors.New("missing query result")
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("query failed: %s (code %d)", message, code)
+	}
+	return rows, nil
+}
+
+func readOsqueryRows(r *thriftReader) []map[string]string {
+	elemType, size := r.listBegin()
+	if r.err != nil {
+		return nil
+	}
+	if elemType != thriftMap {
+		for i := int32(0); i < size && r.err == nil; i++ {
+			r.skip(elemType)
+		}
+		return nil
+	}
+	rows := make([]map[string]string, 0, osqueryPrealloc(size))
+	for i := int32(0); i < size && r.err == nil; i++ {
+		keyType, valueType, n := r.mapBegin()
+		if keyType != thriftString || valueType != thriftString {
+			r.err = fmt.Errorf("unexpected row types %d/%d", keyType, valueType)
+			return nil
+		}
+		row := make(map[string]string, osqueryPrealloc(n))
+		for j := int32(0); j < n && r.err == nil; j++ {
+			k := r.string()
+			row[k] = r.string()
+		}
+		rows = append(rows, row)
+	}
+	if r.err != nil {
+		return nil
+	}
+	return rows
+}
+
+// osqueryPrealloc returns the capacity to allocate for n elements announced by a reply
+func osqueryPrealloc(n int32) int {
+	if n > maxOsqueryPrealloc {
+		return maxOsqueryPrealloc
+	}
+	return int(n)
+}
+
+// readThriftException reads a TApplicationException
+func readThriftException(r *thriftReader) error {
+	var message string
+	r.readStruct(func(id int16, typ byte) bool {
+		if id == 1 && typ == thriftString {
+			message = r.string()
+			return true
+		}
+		return false
+	})
+	if r.err != nil {
+		return r.err
+	}
+	return fmt.Errorf("osquery exception: %s", message)
+}
+
+// thriftWriter writes values using the Thrift binary protocol
+type thriftWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *thriftWriter) write(b []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(b)
+	}
+}
+
+func (w *thriftWriter) byte(v byte) {
+	w.write([]byte{v})
+}
+
+func (w *thriftWriter) i16(v int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	w.write(b[:])
+}
+
+func (w *thriftWriter) i32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	w.write(b[:])
+}
+
+func (w *thriftWriter) string(v string) {
+	w.i32(int32(len(v)))
+	w.write([]byte(v))
+}
+
+func (w *thriftWriter) messageBegin(name string, typ int32, seqID int32) {
+	w.i32(int32(thriftVersion1 | uint32(typ)))
+	w.string(name)
+	w.i32(seqID)
+}
+
+func (w *thriftWriter) fieldBegin(typ byte, id int16) {
+	w.byte(typ)
+	w.i16(id)
+}
+
+func (w *thriftWriter) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// thriftReader reads values using the Thrift binary protocol. The first error is kept in
+// err, after which all reads return zero values.
+type thriftReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (r *thriftReader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		r.err = err
+		return nil
+	}
+	return b
+}
+
+func (r *thriftReader) byte() byte {
+	if b := r.read(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *thriftReader) i16() int16 {
+	if b := r.read(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *thriftReader) i32() int32 {
+	if b := r.read(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *thriftReader) string() string {
+	n := r.i32()
+	if n < 0 || n > maxOsqueryStringSize {
+		if r.err == nil {
+			r.err = fmt.Errorf("invalid string size %d", n)
+		}
+		return ""
+	}
+	return string(r.read(int(n)))
+}
+
+func (r *thriftReader) messageBegin() (string, int32, int32) {
+	version := uint32(r.i32())
+	if r.err == nil && version&thriftVersionMask != thriftVersion1 {
+		r.err = fmt.Errorf("unsupported protocol version %#x", version)
+		return "", 0, 0
+	}
+	name := r.string()
+	seqID := r.i32()
+	return name, int32(version & 0xff), seqID
+}
+
+func (r *thriftReader) listBegin() (byte, int32) {
+	elemType := r.byte()
+	size := r.i32()
+	if size < 0 && r.err == nil {
+		r.err = fmt.Errorf("invalid list size %d", size)
+	}
+	return elemType, size
+}
+
+func (r *thriftReader) mapBegin() (byte, byte, int32) {
+	keyType := r.byte()
+	valueType := r.byte()
+	size := r.i32()
+	if size < 0 && r.err == nil {
+		r.err = fmt.Errorf("invalid map size %d", size)
+	}
+	return keyType, valueType, size
+}
+
+// readStruct reads the fields of a struct, calling onField for each of them. Fields for which
+// onField returns false are skipped.
+func (r *thriftReader) readStruct(onField func(id int16, typ byte) bool) {
+	for r.err == nil {
+		typ := r.byte()
+		if typ == thriftStop {
+			return
+		}
+		id := r.i16()
+		if r.err != nil {
+			return
+		}
+		if !onField(id, typ) {
+			r.skip(typ)
+		}
+	}
+}
+
+// skip skips a value of the given type
+func (r *thriftReader) skip(typ byte) {
+	switch typ {
+	case thriftBool, thriftByte:
+		r.read(1)
+	case thriftI16:
+		r.read(2)
+	case thriftI32:
+		r.read(4)
+	case thriftDouble, thriftI64:
+		r.read(8)
+	case thriftString:
+		r.string()
+	case thriftStruct:
+		r.readStruct(func(int16, byte) bool { return false })
+	case thriftMap:
+		keyType, valueType, n := r.mapBegin()
+		for i := int32(0); i < n && r.err == nil; i++ {
+			r.skip(keyType)
+			r.skip(valueType)
+		}
+	case thriftSet, thriftList:
+		elemType, n := r.listBegin()
+		for i := int32(0); i < n && r.err == nil; i++ {
+			r.skip(elemType)
+		}
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown type %d", typ)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+	"github.com/DataDog/datadog-agent/pkg/compliance/eval"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var osqueryReportedFields = []string{
+	compliance.OsqueryFieldName,
+}
+
+func resolveOsquery(ctx context.Context, e env.Env, ruleID string, res compliance.Resource) (interface{}, error) {
+	if res.Osquery == nil {
+		return nil, fmt.Errorf("%s: expecting osquery resource in osquery check", ruleID)
+	}
+
+	osquery := res.Osquery
+	if err := osquery.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", ruleID, err)
+	}
+
+	client := e.OsqueryClient()
+	if client == nil {
+		return nil, fmt.Errorf("osquery client not configured")
+	}
+
+	log.Debugf("%s: running osquery query %s", ruleID, osquery.Name)
+
+	rows, err := client.Query(ctx, osquery.Query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: osquery query %s failed: %w", ruleID, osquery.Name, err)
+	}
+
+	instances := make([]*eval.Instance, 0, len(rows))
+	for _, row := range rows {
+		vars := eval.VarMap{
+			compliance.OsqueryFieldName: osquery.Name,
+		}
+		for column, value := range row {
+			vars[compliance.OsqueryFieldPrefix+column] = osqueryValue(value)
+		}
+		instances = append(instances, &eval.Instance{
+			Vars: vars,
+		})
+	}
+
+	return &instanceIterator{
+		instances: instances,
+	}, nil
+}
+
+// osqueryValue converts integer columns, which osquery returns as strings, so that they can be
+// compared with numbers in conditions. Values with leading zeros, such as file modes, are kept as is.
+func osqueryValue(value string) interface{} {
+	if i, err := strconv.Atoi(value); err == nil && strconv.Itoa(i) == value {
+		return i
+	}
+	return value
+}
+
+func osqueryResourceReportedFields(osquery *compliance.Osquery) []string {
+	fields := make([]string, 0, len(osqueryReportedFields)+len(osquery.Report))
+	fields = append(fields, osqueryReportedFields...)
+	for _, column := range osquery.Report {
+		fields = append(fields, compliance.OsqueryFieldPrefix+column)
+	}
+	return fields
+}
@@ -0,0 +1,237 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+	"github.com/DataDog/datadog-agent/pkg/compliance/mocks"
+
+	"github.com/stretchr/testify/mock"
+	assert "github.com/stretchr/testify/require"
+)
+
+const testSSHConfigsQuery = `SELECT value FROM ssh_configs WHERE option = 'permitrootlogin'`
+
+func TestOsqueryCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		rows         []map[string]string
+		queryErr     error
+		resource     compliance.Resource
+		expectReport *compliance.Report
+		expectError  error
+	}{
+		{
+			name: "no rows",
+			rows: []map[string]string{},
+			resource: compliance.Resource{
+				Osquery: &compliance.Osquery{
+					Name:  "sshd-root-login",
+					Query: testSSHConfigsQuery,
+				},
+				Condition: `osquery.value == "no"`,
+			},
+			expectReport: &compliance.Report{
+				Passed: false,
+			},
+		},
+		{
+			name: "reported columns",
+			rows: []map[string]string{
+				{"value": "no", "block": "Match User admin"},
+			},
+			resource: compliance.Resource{
+				Osquery: &compliance.Osquery{
+					Name:   "sshd-root-login",
+					Query:  testSSHConfigsQuery,
+					Report: []string{"value"},
+				},
+				Condition: `osquery.value == "no"`,
+			},
+			expectReport: &compliance.Report{
+				Passed: true,
+				Data: event.Data{
+					"osquery.name":  "sshd-root-login",
+					"osquery.value": "no",
+				},
+			},
+		},
+		{
+			name: "integer columns",
+			rows: []map[string]string{
+				{"path": "/etc/shadow", "mode": "0640", "uid": "0"},
+			},
+			resource: compliance.Resource{
+				Osquery: &compliance.Osquery{
+					Name:   "shadow-owner",
+					Query:  `SELECT path, mode, uid FROM file WHERE path = '/etc/shadow'`,
+					Report: []string{"path", "uid"},
+				},
+				Condition: `osquery.uid == 0 && osquery.mode == "0640"`,
+			},
+			expectReport: &compliance.Report{
+				Passed: true,
+				Data: event.Data{
+					"osquery.name": "shadow-owner",
+					"osquery.path": "/etc/shadow",
+					"osquery.uid":  0,
+				},
+			},
+		},
+		{
+			name:     "query failure",
+			queryErr: errors.New("no such table: ssh_configs"),
+			resource: compliance.Resource{
+				Osquery: &compliance.Osquery{
+					Name:  "sshd-root-login",
+					Query: testSSHConfigsQuery,
+				},
+				Condition: `osquery.value == "no"`,
+			},
+			expectError: errors.New("rule-id: osquery query sshd-root-login failed: no such table: ssh_configs"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			client := &mocks.OsqueryClient{}
+			defer client.AssertExpectations(t)
+			client.On("Query", mock.Anything, test.resource.Osquery.Query).Return(test.rows, test.queryErr)
+
+			env := &mocks.Env{}
+			defer env.AssertExpectations(t)
+			env.On("OsqueryClient").Return(client)
+
+			osqueryCheck, err := newResourceCheck(env, "rule-id", test.resource)
+			assert.NoError(err)
+
+			result, err := osqueryCheck.check(env)
+			if test.expectError != nil {
+				assert.EqualError(err, test.expectError.Error())
+			} else {
+				assert.NoError(err)
+			}
+			assert.Equal(test.expectReport, result)
+		})
+	}
+}
+
+// serveOsquery replies to a single ExtensionManager.query call
+func serveOsquery(t *testing.T, ln net.Listener, status int32, message string, rows []map[string]string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := &thriftReader{r: bufio.NewReader(conn)}
+	name, typ, seqID := r.messageBegin()
+	var sql string
+	r.readStruct(func(id int16, typ byte) bool {
+		if id == 1 && typ == thriftString {
+			sql = r.string()
+			return true
+		}
+		return false
+	})
+	if r.err != nil || name != "query" || typ != thriftMessageCall || sql == "" {
+		t.Errorf("unexpected call %q of type %d: %v", name, typ, r.err)
+		return
+	}
+
+	w := &thriftWriter{w: bufio.NewWriter(conn)}
+	w.messageBegin("query", thriftMessageReply, seqID)
+	w.fieldBegin(thriftStruct, 0)
+	// status
+	w.fieldBegin(thriftStruct, 1)
+	w.fieldBegin(thriftI32, 1)
+	w.i32(status)
+	w.fieldBegin(thriftString, 2)
+	w.string(message)
+	w.fieldBegin(thriftI64, 3)
+	w.i32(0)
+	w.i32(42)
+	w.byte(thriftStop)
+	// response
+	w.fieldBegin(thriftList, 2)
+	w.byte(thriftMap)
+	w.i32(int32(len(rows)))
+	for _, row := range rows {
+		w.byte(thriftString)
+		w.byte(thriftString)
+		w.i32(int32(len(row)))
+		for k, v := range row {
+			w.string(k)
+			w.string(v)
+		}
+	}
+	w.byte(thriftStop)
+	w.byte(thriftStop)
+	assert.NoError(t, w.flush())
+}
+
+func TestOsqueryClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osquery")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "osquery.em")
+	ln, err := net.Listen("unix", socketPath)
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	client, err := newOsqueryClient(socketPath)
+	assert.NoError(t, err)
+	defer client.Close()
+
+	rows := []map[string]string{
+		{"name": "sshd", "pid": "1234"},
+		{"name": "cron", "pid": "42"},
+	}
+	go serveOsquery(t, ln, 0, "OK", rows)
+	result, err := client.Query(context.Background(), "SELECT name, pid FROM processes")
+	assert.NoError(t, err)
+	assert.Equal(t, rows, result)
+
+	go serveOsquery(t, ln, 1, "no such table: foo", nil)
+	_, err = client.Query(context.Background(), "SELECT * FROM foo")
+	assert.EqualError(t, err, "failed to read osquery reply: query failed: no such table: foo (code 1)")
+
+	_, err = newOsqueryClient("")
+	assert.Error(t, err)
+}
+
+func TestOsqueryRowsTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	w := &thriftWriter{w: bufio.NewWriter(&buf)}
+	// a reply announcing more rows and columns than it holds must not be allocated upfront
+	w.byte(thriftMap)
+	w.i32(math.MaxInt32)
+	w.byte(thriftString)
+	w.byte(thriftString)
+	w.i32(math.MaxInt32)
+	w.string("name")
+	assert.NoError(t, w.flush())
+
+	r := &thriftReader{r: bufio.NewReader(&buf)}
+	rows := readOsqueryRows(r)
+	assert.Nil(t, rows)
+	assert.Error(t, r.err)
+}
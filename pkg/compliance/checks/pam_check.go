@@ -0,0 +1,164 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+	"github.com/DataDog/datadog-agent/pkg/compliance/eval"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var pamReportedFields = []string{
+	compliance.PAMFieldService,
+	compliance.PAMFieldFile,
+	compliance.PAMFieldType,
+	compliance.PAMFieldControl,
+	compliance.PAMFieldModule,
+	compliance.PAMFieldArguments,
+}
+
+// pamMaxIncludeDepth is the maximum depth of nested includes
+const pamMaxIncludeDepth = 16
+
+func resolvePAM(_ context.Context, e env.Env, ruleID string, res compliance.Resource) (interface{}, error) {
+	if res.PAM == nil {
+		return nil, fmt.Errorf("%s: expecting pam resource in pam check", ruleID)
+	}
+
+	pam := res.PAM
+	if err := pam.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", ruleID, err)
+	}
+
+	dir := pam.Dir
+	if dir == "" {
+		dir = compliance.DefaultPAMDir
+	}
+
+	log.Debugf("%s: running pam check for service %q", ruleID, pam.Service)
+
+	p := &pamParser{env: e, dir: dir, service: pam.Service}
+	if err := p.parseService(pam.Service, "", 0); err != nil {
+		return nil, wrapErrorWithID(ruleID, err)
+	}
+
+	return &instanceIterator{
+		instances: p.instances,
+	}, nil
+}
+
+// pamParser parses the PAM configuration of a service into instances, one for each rule.
+// Rules including the configuration of other services are replaced by the rules they include.
+type pamParser struct {
+	env       env.Env
+	dir       string
+	service   string
+	instances []*eval.Instance
+}
+
+// parseService parses the configuration of a service. When ruleType is set, only the rules
+// of this type are kept, as done for the "include" and "substack" controls.
+func (p *pamParser) parseService(service, ruleType string, depth int) error {
+	if depth > pamMaxIncludeDepth {
+		return fmt.Errorf("too many levels of includes in pam service %s", service)
+	}
+
+	path := filepath.Join(p.dir, service)
+	if filepath.IsAbs(service) {
+		path = service
+	}
+
+	f, err := openFile(usageFromEnv(p.env), p.env.NormalizeToHostRoot(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+
+		if include := strings.TrimPrefix(line, "@include"); include != line {
+			if err := p.parseService(strings.TrimSpace(include), ruleType, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fields := splitPAMFields(line)
+		if len(fields) < 3 {
+			log.Debugf("Skipping unexpected line in %s: %s", path, line)
+			continue
+		}
+		typ, control, module := strings.TrimPrefix(fields[0], "-"), fields[1], fields[2]
+		if ruleType != "" && typ != ruleType {
+			continue
+		}
+
+		if control == "include" || control == "substack" {
+			if err := p.parseService(module, typ, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		p.instances = append(p.instances, &eval.Instance{
+			Vars: eval.VarMap{
+				compliance.PAMFieldService:   p.service,
+				compliance.PAMFieldFile:      path,
+				compliance.PAMFieldType:      typ,
+				compliance.PAMFieldControl:   control,
+				compliance.PAMFieldModule:    filepath.Base(module),
+				compliance.PAMFieldArguments: fields[3:],
+			},
+		})
+	}
+	return scanner.Err()
+}
+
+// splitPAMFields splits a PAM rule on whitespace, keeping bracketed values such as
+// "[success=1 default=ignore]" in a single field
+func splitPAMFields(line string) []string {
+	var (
+		fields   []string
+		start    = -1
+		brackets bool
+	)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '[' && start < 0:
+			start = i
+			brackets = true
+		case c == ']' && brackets:
+			brackets = false
+		case (c == ' ' || c == '\t') && !brackets:
+			if start >= 0 {
+				fields = append(fields, line[start:i])
+				start = -1
+			}
+		case start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, line[start:])
+	}
+	return fields
+}
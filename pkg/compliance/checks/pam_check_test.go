@@ -0,0 +1,101 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestPAMCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		service      string
+		condition    string
+		expectPassed bool
+		expectData   event.Data
+	}{
+		{
+			name:         "password quality",
+			service:      "common-password",
+			condition:    `count(pam.type == "password" && pam.module == "pam_pwquality.so" && "minlen=14" in pam.arguments) == 1`,
+			expectPassed: true,
+		},
+		{
+			name:         "password reuse",
+			service:      "common-password",
+			condition:    `none(pam.module == "pam_unix.so" && "remember=5" in pam.arguments)`,
+			expectPassed: false,
+			expectData: event.Data{
+				"pam.service":   "common-password",
+				"pam.file":      "/etc/pam.d/common-password",
+				"pam.type":      "password",
+				"pam.control":   "[success=1 default=ignore]",
+				"pam.module":    "pam_unix.so",
+				"pam.arguments": []string{"obscure", "use_authtok", "try_first_pass", "sha512", "remember=5"},
+			},
+		},
+		{
+			name:         "included services",
+			service:      "sshd",
+			condition:    `count(_) == 9`,
+			expectPassed: true,
+		},
+		{
+			name:         "included rules of a type",
+			service:      "sshd",
+			condition:    `count(pam.file == "/etc/pam.d/system-auth") == 1`,
+			expectPassed: true,
+		},
+		{
+			name:         "excluded rules of other types",
+			service:      "sshd",
+			condition:    `none(pam.module == "pam_env.so" || pam.module == "pam_systemd.so")`,
+			expectPassed: true,
+		},
+		{
+			name:         "module path",
+			service:      "sshd",
+			condition:    `count(pam.module == "pam_limits.so" && pam.control == "required") == 1`,
+			expectPassed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			env := hostRootEnv("./testdata/pam")
+			resource := compliance.Resource{
+				PAM: &compliance.PAM{
+					Service: test.service,
+				},
+				Condition: test.condition,
+			}
+
+			pamCheck, err := newResourceCheck(env, "rule-id", resource)
+			assert.NoError(err)
+
+			report, err := pamCheck.check(env)
+			assert.NoError(err)
+			assert.Equal(test.expectPassed, report.Passed)
+			if test.expectData != nil {
+				assert.Equal(test.expectData, report.Data)
+			}
+		})
+	}
+}
+
+func TestSplitPAMFields(t *testing.T) {
+	assert.Equal(t,
+		[]string{"auth", "[success=1 default=ignore]", "pam_unix.so", "nullok"},
+		splitPAMFields("auth	[success=1 default=ignore]  pam_unix.so nullok"),
+	)
+}
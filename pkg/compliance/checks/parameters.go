@@ -0,0 +1,228 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+	"github.com/DataDog/datadog-agent/pkg/compliance/eval"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// ParameterResolver resolves values of rule parameters (e.g. a list of approved registries
+// or allowed sysctl values) by name
+type ParameterResolver interface {
+	ResolveParameter(name string) (interface{}, bool, error)
+}
+
+// StaticParameters is a ParameterResolver using a fixed set of values, typically coming
+// from the local configuration
+type StaticParameters map[string]interface{}
+
+// ResolveParameter implements ParameterResolver
+func (p StaticParameters) ResolveParameter(name string) (interface{}, bool, error) {
+	v, ok := p[name]
+	return v, ok, nil
+}
+
+// configParameters is a ParameterResolver using the values set in "compliance_config.parameters".
+// The configuration lowercases their names, so parameters are looked up by their lowercased name.
+type configParameters map[string]interface{}
+
+// ResolveParameter implements ParameterResolver
+func (p configParameters) ResolveParameter(name string) (interface{}, bool, error) {
+	v, ok := p[strings.ToLower(name)]
+	return v, ok, nil
+}
+
+// parameterResolvers is a ParameterResolver returning the value from the first resolver
+// knowing about a parameter
+type parameterResolvers []ParameterResolver
+
+// ResolveParameter implements ParameterResolver
+func (list parameterResolvers) ResolveParameter(name string) (interface{}, bool, error) {
+	for _, r := range list {
+		v, ok, err := r.ResolveParameter(name)
+		if err != nil || ok {
+			return v, ok, err
+		}
+	}
+	return nil, false, nil
+}
+
+// remoteParameters is a ParameterResolver fetching values from a constants service
+type remoteParameters struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	sync.Mutex
+	values    map[string]interface{}
+	fetchedAt time.Time
+}
+
+// NewRemoteParameters returns a ParameterResolver fetching values from the constants service at url.
+// The service is expected to reply to GET requests with a JSON object mapping parameter names to
+// their values. Values are kept for the duration of ttl, and the last fetched values keep being used
+// when the service can not be reached.
+func NewRemoteParameters(url string, ttl time.Duration) ParameterResolver {
+	return &remoteParameters{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// ResolveParameter implements ParameterResolver
+func (p *remoteParameters) ResolveParameter(name string) (interface{}, bool, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.values == nil || time.Since(p.fetchedAt) > p.ttl {
+		values, err := p.fetch()
+		if err != nil {
+			if p.values == nil {
+				return nil, false, err
+			}
+			log.Warnf("Failed to refresh compliance parameters from %s, using last known values: %v", p.url, err)
+		} else {
+			p.values = values
+		}
+		// either way, wait for ttl before trying again
+		p.fetchedAt = time.Now()
+	}
+
+	v, ok := p.values[name]
+	return v, ok, nil
+}
+
+func (p *remoteParameters) fetch() (map[string]interface{}, error) {
+	resp, err := p.client.Get(p.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from constants service", resp.StatusCode)
+	}
+
+	var values map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&values); err != nil {
+		return nil, fmt.Errorf("failed to decode response from constants service: %w", err)
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	return values, nil
+}
+
+// resolveParameters returns variables holding the current values of the given rule parameters
+func resolveParameters(e env.Env, params []compliance.Parameter) (eval.VarMap, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	vars := make(eval.VarMap, len(params))
+	for _, p := range params {
+		v, ok, err := e.ResolveParameter(p.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve parameter %q: %w", p.Name, err)
+		}
+		if !ok {
+			if p.Default == nil {
+				return nil, fmt.Errorf("no value for parameter %q", p.Name)
+			}
+			v = p.Default
+		}
+		vars[compliance.ParameterVarPrefix+p.Name] = normalizeParameter(v)
+	}
+	return vars, nil
+}
+
+// normalizeParameter converts values obtained from YAML or JSON to the types used in expressions
+func normalizeParameter(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case float64:
+		// JSON numbers are decoded as float64
+		if v == math.Trunc(v) && v >= math.MinInt64 && v <= math.MaxInt64 {
+			return int64(v)
+		}
+		return v
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = normalizeParameter(item)
+		}
+		return values
+	case []string:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = item
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+// withVars returns the instance with the given vars added
+func withVars(instance *eval.Instance, vars eval.VarMap) *eval.Instance {
+	if instance == nil || len(vars) == 0 {
+		return instance
+	}
+	if instance.Vars == nil {
+		instance.Vars = make(eval.VarMap, len(vars))
+	}
+	for k, v := range vars {
+		instance.Vars[k] = v
+	}
+	return instance
+}
+
+// varsIterator adds a set of vars to all instances of an iterator
+type varsIterator struct {
+	eval.Iterator
+	vars eval.VarMap
+}
+
+// Next implements eval.Iterator
+func (it *varsIterator) Next() (*eval.Instance, error) {
+	instance, err := it.Iterator.Next()
+	if err != nil {
+		return nil, err
+	}
+	return withVars(instance, it.vars), nil
+}
+
+// WithConfigParameters configures the builder to resolve rule parameters from the agent configuration:
+// values set in "compliance_config.parameters" take precedence over the ones provided by the
+// constants service configured with "compliance_config.parameters_url", if any. The names of the
+// parameters set in the configuration are case insensitive.
+func WithConfigParameters() BuilderOption {
+	return func(b *builder) error {
+		var resolvers []ParameterResolver
+		if values := config.Datadog.GetStringMap("compliance_config.parameters"); len(values) > 0 {
+			resolvers = append(resolvers, configParameters(values))
+		}
+		if url := config.Datadog.GetString("compliance_config.parameters_url"); url != "" {
+			ttl := config.Datadog.GetDuration("compliance_config.parameters_ttl")
+			resolvers = append(resolvers, NewRemoteParameters(url, ttl))
+		}
+		return WithParameterResolvers(resolvers...)(b)
+	}
+}
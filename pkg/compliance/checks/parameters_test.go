@@ -0,0 +1,207 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+	"github.com/DataDog/datadog-agent/pkg/compliance/eval"
+	"github.com/DataDog/datadog-agent/pkg/compliance/mocks"
+	"github.com/DataDog/datadog-agent/pkg/config"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestResolveParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	e := &mocks.Env{}
+	e.On("ResolveParameter", "registries").Return([]interface{}{"docker.io", "gcr.io"}, true, nil)
+	e.On("ResolveParameter", "max").Return(float64(3), true, nil)
+	e.On("ResolveParameter", "unset").Return(nil, false, nil)
+	e.On("ResolveParameter", "broken").Return(nil, false, errors.New("unreachable"))
+	defer e.AssertExpectations(t)
+
+	vars, err := resolveParameters(e, []compliance.Parameter{
+		{Name: "registries"},
+		{Name: "max"},
+		{Name: "unset", Default: 5},
+	})
+	assert.NoError(err)
+	assert.Equal(eval.VarMap{
+		"param.registries": []interface{}{"docker.io", "gcr.io"},
+		"param.max":        int64(3),
+		"param.unset":      int64(5),
+	}, vars)
+
+	_, err = resolveParameters(e, []compliance.Parameter{{Name: "unset"}})
+	assert.EqualError(err, `no value for parameter "unset"`)
+
+	_, err = resolveParameters(e, []compliance.Parameter{{Name: "broken", Default: 1}})
+	assert.EqualError(err, `failed to resolve parameter "broken": unreachable`)
+}
+
+func TestResourceCheckParameters(t *testing.T) {
+	e := &mocks.Env{}
+	e.On("ResolveParameter", "registries").Return([]string{"docker.io", "gcr.io"}, true, nil)
+	e.On("ResolveParameter", "max").Return(nil, false, nil)
+	defer e.AssertExpectations(t)
+
+	tests := []struct {
+		name      string
+		condition string
+		resolved  interface{}
+		passed    bool
+	}{
+		{
+			name:      "instance",
+			condition: "image.registry in param.registries",
+			resolved: &eval.Instance{
+				Vars: eval.VarMap{"image.registry": "gcr.io"},
+			},
+			passed: true,
+		},
+		{
+			name:      "iterator",
+			condition: "count(image.registry in param.registries) <= param.max",
+			resolved: &instanceIterator{
+				instances: []*eval.Instance{
+					{Vars: eval.VarMap{"image.registry": "gcr.io"}},
+					{Vars: eval.VarMap{"image.registry": "quay.io"}},
+				},
+			},
+			passed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			c := &resourceCheck{
+				ruleID:   "rule-id",
+				resource: compliance.Resource{Condition: test.condition},
+				resolve: func(_ context.Context, _ env.Env, _ string, _ compliance.Resource) (interface{}, error) {
+					return test.resolved, nil
+				},
+				parameters: []compliance.Parameter{
+					{Name: "registries"},
+					{Name: "max", Default: 1},
+				},
+			}
+			report, err := c.check(e)
+			assert.NoError(err)
+			assert.Equal(test.passed, report.Passed)
+		})
+	}
+}
+
+func TestRemoteParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		hits int32
+		fail int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&fail) != 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"registries": ["docker.io"], "max": 2}`))
+	}))
+	defer srv.Close()
+
+	r := NewRemoteParameters(srv.URL, time.Hour).(*remoteParameters)
+
+	v, ok, err := r.ResolveParameter("registries")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal([]interface{}{"docker.io"}, v)
+
+	_, ok, err = r.ResolveParameter("unknown")
+	assert.NoError(err)
+	assert.False(ok)
+	assert.EqualValues(1, atomic.LoadInt32(&hits), "values must be cached")
+
+	// expire the values, last known values are used when the service fails
+	atomic.StoreInt32(&fail, 1)
+	r.fetchedAt = time.Time{}
+	v, ok, err = r.ResolveParameter("max")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(float64(2), v)
+	assert.EqualValues(2, atomic.LoadInt32(&hits))
+
+	// without any known values, the error is reported
+	r = NewRemoteParameters(srv.URL, time.Hour).(*remoteParameters)
+	_, _, err = r.ResolveParameter("max")
+	assert.Error(err)
+}
+
+func TestParameterResolvers(t *testing.T) {
+	assert := assert.New(t)
+
+	r := parameterResolvers{
+		StaticParameters{"a": 1},
+		StaticParameters{"a": 2, "b": 3},
+	}
+	v, ok, err := r.ResolveParameter("a")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(1, v)
+
+	v, ok, err = r.ResolveParameter("b")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(3, v)
+
+	_, ok, err = r.ResolveParameter("c")
+	assert.NoError(err)
+	assert.False(ok)
+}
+
+func TestConfigParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	mockConfig := config.Mock()
+	mockConfig.SetConfigType("yaml")
+	assert.NoError(mockConfig.ReadConfig(strings.NewReader(`
+compliance_config:
+  parameters:
+    approvedRegistries:
+      - docker.io
+    max_depth: 3
+`)))
+
+	b := &builder{}
+	assert.NoError(WithConfigParameters()(b))
+
+	for _, name := range []string{"approvedRegistries", "approvedregistries", "APPROVEDREGISTRIES"} {
+		v, ok, err := b.parameterResolver.ResolveParameter(name)
+		assert.NoError(err)
+		assert.True(ok, name)
+		assert.Equal([]interface{}{"docker.io"}, v)
+	}
+
+	v, ok, err := b.parameterResolver.ResolveParameter("max_depth")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(3, v)
+
+	_, ok, err = b.parameterResolver.ResolveParameter("unset")
+	assert.NoError(err)
+	assert.False(ok)
+}
@@ -42,7 +42,7 @@ func resolveProcess(_ context.Context, e env.Env, id string, res compliance.Reso
 		return nil, log.Errorf("%s: Unable to fetch processes: %v", id, err)
 	}
 
-	matchedProcesses := processes.findProcessesByName(process.Name)
+	matchedProcesses := processes.inNamespace(e.HostNamespace()).findProcessesByName(process.Name)
 
 	var instances []*eval.Instance
 	for _, mp := range matchedProcesses {
@@ -37,6 +37,7 @@ func (f *processFixture) run(t *testing.T) {
 	}
 
 	env := &mocks.Env{}
+	env.On("HostNamespace").Return(nil).Maybe()
 	defer env.AssertExpectations(t)
 
 	processCheck, err := newResourceCheck(env, "rule-id", f.resource)
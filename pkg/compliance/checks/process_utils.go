@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
 	"github.com/DataDog/datadog-agent/pkg/util/cache"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 	"github.com/DataDog/gopsutil/process"
@@ -41,6 +42,21 @@ func (p processes) findProcesses(matchFunc func(*process.FilledProcess) bool) []
 	return results
 }
 
+// inNamespace returns the processes belonging to the PID namespace of ns, or all processes when
+// ns is nil
+func (p processes) inNamespace(ns env.Namespace) processes {
+	if ns == nil {
+		return p
+	}
+	results := make(processes)
+	for pid, process := range p {
+		if ns.ContainsProcess(pid) {
+			results[pid] = process
+		}
+	}
+	return results
+}
+
 func fetchProcesses() (processes, error) {
 	return process.AllProcesses()
 }
@@ -0,0 +1,183 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	docker "github.com/docker/docker/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// quarantineThreshold is the number of consecutive failures after which a backend is quarantined
+	quarantineThreshold = 3
+	// quarantineMinBackoff and quarantineMaxBackoff bound the quarantine period of a backend,
+	// doubling every time it fails again right after a quarantine
+	quarantineMinBackoff = time.Minute
+	quarantineMaxBackoff = 30 * time.Minute
+)
+
+// ErrBackendUnavailable is returned when resolving a resource of a quarantined backend
+var ErrBackendUnavailable = errors.New("backend unavailable")
+
+// resourceBackend returns the name of the backend resolving the resources of the given kind,
+// or an empty string if they are not resolved by a backend which can be quarantined.
+func resourceBackend(kind compliance.ResourceKind) string {
+	switch kind {
+	case compliance.KindDocker:
+		return "docker"
+	case compliance.KindKubernetes:
+		return "kubernetes"
+	default:
+		return ""
+	}
+}
+
+// backendUnavailable returns whether err shows that a backend could not be reached, such as a
+// connection error or a timeout. The errors returned by a responsive backend, such as a missing
+// resource or a denied request, don't count towards its quarantine.
+func backendUnavailable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if docker.IsErrConnectionFailed(err) {
+		return true
+	}
+	var statusErr *apierrors.StatusError
+	if errors.As(err, &statusErr) {
+		return apierrors.IsTimeout(statusErr) || apierrors.IsServerTimeout(statusErr) || apierrors.IsServiceUnavailable(statusErr)
+	}
+	return false
+}
+
+// backendQuarantiner is implemented by the environments tracking the health of the backends
+type backendQuarantiner interface {
+	backendQuarantine() *backendQuarantine
+}
+
+type backendHealth struct {
+	failures    int // consecutive failures
+	quarantines int // consecutive quarantines
+	until       time.Time
+	lastError   error
+}
+
+// backendQuarantine tracks the health of the backends resolving resources. A backend which
+// fails repeatedly, such as the docker daemon or the Kubernetes API server when unreachable,
+// is quarantined for a backoff period, during which the checks depending on it report an
+// error right away instead of timing out on every run.
+type backendQuarantine struct {
+	sync.Mutex
+	backends map[string]*backendHealth
+	now      func() time.Time
+}
+
+func newBackendQuarantine() *backendQuarantine {
+	return &backendQuarantine{
+		backends: make(map[string]*backendHealth),
+		now:      time.Now,
+	}
+}
+
+// wrap returns a resolveFunc calling resolve unless the backend is quarantined, and
+// recording the result of the calls.
+func (q *backendQuarantine) wrap(backend string, resolve resolveFunc) resolveFunc {
+	return func(ctx context.Context, e env.Env, ruleID string, resource compliance.Resource) (interface{}, error) {
+		if until, quarantined := q.quarantined(backend); quarantined {
+			return nil, fmt.Errorf("%w: %s quarantined until %s", ErrBackendUnavailable, backend, until.Format(time.RFC3339))
+		}
+		resolved, err := resolve(ctx, e, ruleID, resource)
+		q.record(backend, err)
+		return resolved, err
+	}
+}
+
+func (q *backendQuarantine) health(backend string) *backendHealth {
+	h, ok := q.backends[backend]
+	if !ok {
+		h = &backendHealth{}
+		q.backends[backend] = h
+	}
+	return h
+}
+
+// quarantined returns whether the backend is quarantined, and until when.
+func (q *backendQuarantine) quarantined(backend string) (time.Time, bool) {
+	q.Lock()
+	defer q.Unlock()
+	h := q.health(backend)
+	return h.until, q.now().Before(h.until)
+}
+
+// record records the result of a call to the backend, quarantining it after
+// quarantineThreshold consecutive failures to reach it, or right away if it fails again after a
+// quarantine.
+func (q *backendQuarantine) record(backend string, err error) {
+	q.Lock()
+	defer q.Unlock()
+	h := q.health(backend)
+	if err == nil || !backendUnavailable(err) {
+		if h.quarantines > 0 {
+			log.Infof("Compliance backend %s recovered", backend)
+		}
+		*h = backendHealth{}
+		return
+	}
+	h.failures++
+	h.lastError = err
+	if h.failures < quarantineThreshold && h.quarantines == 0 {
+		return
+	}
+	backoff := quarantineMinBackoff
+	for i := 0; i < h.quarantines && backoff < quarantineMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > quarantineMaxBackoff {
+		backoff = quarantineMaxBackoff
+	}
+	h.quarantines++
+	h.until = q.now().Add(backoff)
+	log.Warnf("Compliance backend %s quarantined for %s after %d consecutive failures: %v", backend, backoff, h.failures, err)
+}
+
+// status returns the status of the backends, sorted by name.
+func (q *backendQuarantine) status() compliance.BackendStatusList {
+	q.Lock()
+	defer q.Unlock()
+	now := q.now()
+	list := make(compliance.BackendStatusList, 0, len(q.backends))
+	for name, h := range q.backends {
+		s := &compliance.BackendStatus{
+			Name:                name,
+			Quarantined:         now.Before(h.until),
+			ConsecutiveFailures: h.failures,
+		}
+		if s.Quarantined {
+			s.QuarantinedUntil = h.until
+		}
+		if h.lastError != nil {
+			s.LastError = h.lastError.Error()
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,118 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestBackendQuarantine(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := newBackendQuarantine()
+	q.now = func() time.Time { return now }
+
+	var calls int
+	var resolveErr error
+	resolve := q.wrap("docker", func(ctx context.Context, e env.Env, ruleID string, resource compliance.Resource) (interface{}, error) {
+		calls++
+		return nil, resolveErr
+	})
+	run := func() error {
+		_, err := resolve(context.Background(), nil, "rule-id", compliance.Resource{})
+		return err
+	}
+
+	// quarantined after 3 consecutive failures
+	resolveErr = &net.OpError{Op: "dial", Net: "unix", Err: syscall.ECONNREFUSED}
+	for i := 0; i < quarantineThreshold; i++ {
+		assert.Equal(resolveErr, run())
+	}
+	assert.Equal(quarantineThreshold, calls)
+	assert.True(errors.Is(run(), ErrBackendUnavailable))
+	assert.Equal(quarantineThreshold, calls)
+	assert.Equal(compliance.BackendStatusList{{
+		Name:                "docker",
+		Quarantined:         true,
+		QuarantinedUntil:    now.Add(quarantineMinBackoff),
+		ConsecutiveFailures: quarantineThreshold,
+		LastError:           "dial unix: connection refused",
+	}}, q.status())
+
+	// failing again once the quarantine is over doubles the backoff
+	now = now.Add(quarantineMinBackoff)
+	assert.Equal(resolveErr, run())
+	assert.Equal(quarantineThreshold+1, calls)
+	_, quarantined := q.quarantined("docker")
+	assert.True(quarantined)
+	now = now.Add(quarantineMinBackoff)
+	assert.True(errors.Is(run(), ErrBackendUnavailable))
+
+	// recovered on success
+	now = now.Add(quarantineMinBackoff)
+	resolveErr = nil
+	assert.NoError(run())
+	assert.Equal(compliance.BackendStatusList{{Name: "docker"}}, q.status())
+	resolveErr = context.DeadlineExceeded
+	assert.Equal(resolveErr, run())
+	_, quarantined = q.quarantined("docker")
+	assert.False(quarantined)
+}
+
+func TestBackendQuarantineMaxBackoff(t *testing.T) {
+	now := time.Now()
+	q := newBackendQuarantine()
+	q.now = func() time.Time { return now }
+	for i := 0; i < 100; i++ {
+		q.record("kubernetes", apierrors.NewServiceUnavailable("etcd unavailable"))
+	}
+	until, quarantined := q.quarantined("kubernetes")
+	assert.True(t, quarantined)
+	assert.Equal(t, now.Add(quarantineMaxBackoff), until)
+}
+
+func TestBackendUnavailable(t *testing.T) {
+	q := newBackendQuarantine()
+	for name, err := range map[string]error{
+		"unsupported": errors.New("unsupported docker object kind 'volume'"),
+		"not-found":   fmt.Errorf("unable to get Kube resource, err: %w", apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "etcd")),
+		"forbidden":   apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "etcd", errors.New("denied")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.False(t, backendUnavailable(err))
+			for i := 0; i < 2*quarantineThreshold; i++ {
+				q.record(name, err)
+			}
+			_, quarantined := q.quarantined(name)
+			assert.False(t, quarantined)
+		})
+	}
+
+	for name, err := range map[string]error{
+		"deadline":     fmt.Errorf("docker: %w", context.DeadlineExceeded),
+		"dial":         &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+		"url":          &url.Error{Op: "Get", URL: "https://10.0.0.1:6443", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.EHOSTUNREACH}},
+		"kube-wrapped": fmt.Errorf("unable to list Kube resources, err: %w", apierrors.NewTimeoutError("slow etcd", 1)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.True(t, backendUnavailable(err))
+		})
+	}
+}
@@ -39,21 +39,32 @@ type resourceCheck struct {
 	fallback checkable
 
 	reportedFields []string
+
+	parameters []compliance.Parameter
+
+	evidence *evidenceCollector
 }
 
 func (c *resourceCheck) check(env env.Env) (*compliance.Report, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
+	ctx = withRuleUsage(ctx, usageFromEnv(env))
 
 	resolved, err := c.resolve(ctx, env, c.ruleID, c.resource)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.evaluate(env, resolved)
+	// Parameters are resolved on every run so that updated values are taken into account
+	params, err := resolveParameters(env, c.parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.evaluate(env, resolved, params)
 }
 
-func (c *resourceCheck) evaluate(env env.Env, resolved interface{}) (*compliance.Report, error) {
+func (c *resourceCheck) evaluate(env env.Env, resolved interface{}, params eval.VarMap) (*compliance.Report, error) {
 	conditionExpression, err := eval.Cache.ParseIterable(c.resource.Condition)
 	if err != nil {
 		return nil, err
@@ -61,6 +72,8 @@ func (c *resourceCheck) evaluate(env env.Env, resolved interface{}) (*compliance
 
 	switch resolved := resolved.(type) {
 	case *eval.Instance:
+		resolved = withVars(resolved, params)
+
 		if c.resource.Fallback != nil {
 			if c.fallback == nil {
 				return nil, ErrResourceFallbackMissing
@@ -84,24 +97,43 @@ func (c *resourceCheck) evaluate(env env.Env, resolved interface{}) (*compliance
 		if err != nil {
 			return nil, err
 		}
-		return instanceToReport(resolved, passed, c.reportedFields), nil
+		report := instanceToReport(resolved, passed, c.reportedFields)
+		if c.evidence != nil {
+			c.evidence.attach(resolved, report)
+		}
+		return report, nil
 
 	case eval.Iterator:
 		if c.resource.Fallback != nil {
 			return nil, ErrResourceCannotUseFallback
 		}
 
-		result, err := conditionExpression.EvaluateIterator(resolved, globalInstance)
+		global := globalInstance
+		if len(params) != 0 {
+			resolved = &varsIterator{Iterator: resolved, vars: params}
+			global = &eval.Instance{
+				Vars:      eval.VarMap{},
+				Functions: globalFunctions,
+			}
+			withVars(global, globalVars)
+			withVars(global, params)
+		}
+
+		result, err := conditionExpression.EvaluateIterator(resolved, global)
 		if err != nil {
 			return nil, err
 		}
-		return instanceResultToReport(result, c.reportedFields), nil
+		report := instanceResultToReport(result, c.reportedFields)
+		if c.evidence != nil {
+			c.evidence.attach(result.Instance, report)
+		}
+		return report, nil
 	default:
 		return nil, ErrResourceFailedToResolve
 	}
 }
 
-func newResourceCheck(env env.Env, ruleID string, resource compliance.Resource) (checkable, error) {
+func newResourceCheck(env env.Env, ruleID string, resource compliance.Resource, parameters ...compliance.Parameter) (checkable, error) {
 	// TODO: validate resource here
 	kind := resource.Kind()
 
@@ -120,27 +152,49 @@ func newResourceCheck(env env.Env, ruleID string, resource compliance.Resource)
 		if env.KubeClient() == nil {
 			return nil, log.Errorf("%s: kube client not initialized", ruleID)
 		}
+	case compliance.KindOsquery:
+		if env.OsqueryClient() == nil {
+			return nil, log.Errorf("%s: osquery client not initialized", ruleID)
+		}
 	}
 
 	resolve, reportedFields, err := resourceKindToResolverAndFields(kind)
 	if err != nil {
 		return nil, log.Errorf("%s: failed to find resource resolver for resource kind: %s", ruleID, kind)
 	}
+	if resource.Osquery != nil {
+		reportedFields = osqueryResourceReportedFields(resource.Osquery)
+	}
+	if q, ok := env.(backendQuarantiner); ok {
+		if backend := resourceBackend(kind); backend != "" {
+			resolve = q.backendQuarantine().wrap(backend, resolve)
+		}
+	}
 
 	var fallback checkable
 	if resource.Fallback != nil {
-		fallback, err = newResourceCheck(env, ruleID, resource.Fallback.Resource)
+		fallback, err = newResourceCheck(env, ruleID, resource.Fallback.Resource, parameters...)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	var evidence *evidenceCollector
+	if resource.Evidence != nil {
+		evidence, err = newEvidenceCollector(resource.Evidence)
+		if err != nil {
+			return nil, wrapErrorWithID(ruleID, err)
+		}
+	}
+
 	return &resourceCheck{
 		ruleID:         ruleID,
 		resource:       resource,
 		resolve:        resolve,
 		fallback:       fallback,
 		reportedFields: reportedFields,
+		parameters:     parameters,
+		evidence:       evidence,
 	}, nil
 }
 
@@ -160,15 +214,21 @@ func resourceKindToResolverAndFields(kind compliance.ResourceKind) (resolveFunc,
 		return resolveDocker, dockerReportedFields, nil
 	case compliance.KindKubernetes:
 		return resolveKubeapiserver, kubeResourceReportedFields, nil
+	case compliance.KindOsquery:
+		return resolveOsquery, osqueryReportedFields, nil
+	case compliance.KindSudoers:
+		return resolveSudoers, sudoersReportedFields, nil
+	case compliance.KindPAM:
+		return resolvePAM, pamReportedFields, nil
 	default:
 		return nil, nil, ErrResourceKindNotSupported
 	}
 }
 
-func newResourceCheckList(env env.Env, ruleID string, resources []compliance.Resource) (checkable, error) {
+func newResourceCheckList(env env.Env, ruleID string, resources []compliance.Resource, parameters []compliance.Parameter) (checkable, error) {
 	var checks checkableList
 	for _, resource := range resources {
-		c, err := newResourceCheck(env, ruleID, resource)
+		c, err := newResourceCheck(env, ruleID, resource, parameters...)
 		if err != nil {
 			return nil, err
 		}
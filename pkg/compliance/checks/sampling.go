@@ -0,0 +1,46 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"hash/fnv"
+	"math"
+	"time"
+)
+
+// hostSampler decides whether a sampled rule is evaluated on the host during a check interval.
+//
+// Intervals are numbered from the epoch, so that all hosts agree on the current one. Each host is
+// given a position in [0, 1) from a hash of its hostname and the rule ID, which moves forward by
+// rate every interval: the host is sampled when its position falls in [0, rate). This way a fraction
+// rate of the hosts is sampled at each interval, and every host is sampled once every 1/rate intervals.
+type hostSampler struct {
+	rate     float64
+	offset   float64
+	interval time.Duration
+}
+
+func newHostSampler(hostname, ruleID string, rate float64, interval time.Duration) *hostSampler {
+	h := fnv.New64a()
+	h.Write([]byte(hostname))
+	h.Write([]byte{0})
+	h.Write([]byte(ruleID))
+	return &hostSampler{
+		rate:     rate,
+		offset:   float64(h.Sum64()>>11) / (1 << 53),
+		interval: interval,
+	}
+}
+
+// sampled reports whether the rule is to be evaluated during the check interval including now
+func (s *hostSampler) sampled(now time.Time) bool {
+	if s.rate >= 1 || s.interval <= 0 {
+		return true
+	}
+	cycle := now.UnixNano() / int64(s.interval)
+	_, position := math.Modf(s.offset + math.Mod(float64(cycle)*s.rate, 1))
+	return position < s.rate
+}
@@ -0,0 +1,55 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestHostSampler(t *testing.T) {
+	assert := assert.New(t)
+
+	const (
+		hosts    = 1000
+		rate     = 0.1
+		interval = 20 * time.Minute
+	)
+
+	start := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	runs := make(map[string]int)
+	for cycle := 0; cycle < 10; cycle++ {
+		now := start.Add(time.Duration(cycle) * interval)
+		sampled := 0
+		for i := 0; i < hosts; i++ {
+			hostname := fmt.Sprintf("host-%d", i)
+			if newHostSampler(hostname, "cis-docker-1", rate, interval).sampled(now) {
+				sampled++
+				runs[hostname]++
+			}
+		}
+		// about 10% of the hosts are sampled at each interval
+		assert.InDelta(hosts*rate, sampled, 40, "cycle %d", cycle)
+	}
+
+	// each host is sampled exactly once over 1/rate intervals
+	assert.Len(runs, hosts)
+	for hostname, n := range runs {
+		assert.Equal(1, n, hostname)
+	}
+
+	// the decision is the same for the whole interval
+	s := newHostSampler("host-1", "cis-docker-1", rate, interval)
+	for i := 0; i < 10; i++ {
+		now := start.Add(time.Duration(i) * interval)
+		assert.Equal(s.sampled(now), s.sampled(now.Add(interval-time.Second)))
+	}
+
+	assert.True(newHostSampler("host-1", "cis-docker-1", 1, interval).sampled(start))
+}
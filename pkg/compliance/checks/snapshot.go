@@ -0,0 +1,301 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// maxSnapshotLinks is the number of symbolic links followed to resolve a snapshot path
+const maxSnapshotLinks = 40
+
+// snapshot is a read-only copy of host paths taken before the checks of a suite are run, so
+// that all of them observe the same content even when the files change during the run. The
+// paths outside of the snapshot are read from the host.
+type snapshot struct {
+	root   string      // directory holding the copies
+	paths  []string    // absolute host paths copied under root
+	mapper *pathMapper // nil if the host paths are read as is
+}
+
+// prepareSnapshotDir creates the private directory holding the snapshots, and removes the
+// snapshots left over by a previous process which could not remove them, such as when it
+// crashed.
+func prepareSnapshotDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return err
+	}
+	stale, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range stale {
+		if err := os.RemoveAll(filepath.Join(dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSnapshotPaths returns an error if any of the given snapshot paths is not absolute
+func validateSnapshotPaths(paths []string) error {
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("snapshot path %q is not absolute", path)
+		}
+	}
+	return nil
+}
+
+// newSnapshot copies the given host paths, read through mapper when not nil, to a new
+// directory of dir. Missing paths are skipped. The files of procfs and sysfs can be copied,
+// their content is read at once.
+func newSnapshot(dir string, mapper *pathMapper, paths []string) (*snapshot, error) {
+	if err := validateSnapshotPaths(paths); err != nil {
+		return nil, err
+	}
+	root, err := ioutil.TempDir(dir, "snapshot-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	s := &snapshot{root: root, mapper: mapper}
+
+	// the parents are listed first, so that the paths they contain are skipped, and all the
+	// paths are known before copying so that the links between them can be resolved
+	sorted := make([]string, len(paths))
+	for i, path := range paths {
+		sorted[i] = filepath.Clean(path)
+	}
+	sort.Strings(sorted)
+	for _, path := range sorted {
+		if s.contains(path) {
+			continue
+		}
+		if _, err := os.Lstat(s.hostPath(path)); os.IsNotExist(err) {
+			log.Debugf("Snapshot path %s does not exist, skipping", path)
+			continue
+		}
+		s.paths = append(s.paths, path)
+	}
+
+	for _, path := range s.paths {
+		// a snapshot path which is a symbolic link is copied from its target
+		src, err := s.resolve(path)
+		if err != nil {
+			s.remove()
+			return nil, fmt.Errorf("failed to snapshot %s: %w", path, err)
+		}
+		if err := s.copyTree(src, path); err != nil {
+			s.remove()
+			return nil, fmt.Errorf("failed to snapshot %s: %w", path, err)
+		}
+	}
+	log.Infof("Snapshot of %s taken in %s", strings.Join(s.paths, ", "), root)
+	return s, nil
+}
+
+// hostPath returns the path at which the host path is read
+func (s *snapshot) hostPath(path string) string {
+	if s.mapper != nil {
+		return s.mapper.normalizeToHostRoot(path)
+	}
+	return path
+}
+
+// resolve follows the symbolic links of the host path, within the host root, and returns the
+// host path they point to.
+func (s *snapshot) resolve(path string) (string, error) {
+	for i := 0; i < maxSnapshotLinks; i++ {
+		fi, err := os.Lstat(s.hostPath(path))
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			return path, nil
+		}
+		link, err := os.Readlink(s.hostPath(path))
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		path = filepath.Clean(link)
+	}
+	return "", fmt.Errorf("too many levels of symbolic links")
+}
+
+// contains returns whether the host path is part of the snapshot
+func (s *snapshot) contains(path string) bool {
+	path = filepath.Clean(path)
+	for _, p := range s.paths {
+		if path == p || strings.HasPrefix(path, p+string(os.PathSeparator)) || p == string(os.PathSeparator) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize returns the path of the copy of the host path
+func (s *snapshot) normalize(path string) string {
+	return filepath.Join(s.root, path)
+}
+
+// relative returns the host path of a path of the snapshot, and whether path belongs to it
+func (s *snapshot) relative(path string) (string, bool) {
+	if !filepath.HasPrefix(path, s.root) {
+		return path, false
+	}
+	p, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return path, false
+	}
+	return string(os.PathSeparator) + p, true
+}
+
+// remove deletes the copies
+func (s *snapshot) remove() error {
+	return os.RemoveAll(s.root)
+}
+
+// linkTarget returns the target of the copy of a symbolic link pointing to link, found at the
+// host path path of the tree copied from the host path src to the snapshot path dst. The links
+// to paths of the snapshot point to their copy, and the others to the host paths, so that the
+// copy resolves to the same content as the original.
+func (s *snapshot) linkTarget(src, dst, path, link string) string {
+	target := link
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	if target == src || strings.HasPrefix(target, src+string(os.PathSeparator)) {
+		if !filepath.IsAbs(link) {
+			// the relative links within the tree keep resolving within its copy
+			return link
+		}
+		rel, _ := filepath.Rel(src, target)
+		return s.normalize(filepath.Join(dst, rel))
+	}
+	if s.contains(target) {
+		return s.normalize(target)
+	}
+	return s.hostPath(target)
+}
+
+// copyTree copies the file or directory at the host path src to the snapshot path dst,
+// preserving the modes, owners and modification times. The symbolic links are resolved by
+// linkTarget. Sockets, pipes, devices and the files that can not be read, such as the
+// write-only files of procfs, are skipped, as well as the files which can't be walked.
+func (s *snapshot) copyTree(src, dst string) error {
+	srcRoot := s.hostPath(src)
+	dstRoot := s.normalize(dst)
+	if err := os.MkdirAll(filepath.Dir(dstRoot), 0700); err != nil {
+		return err
+	}
+
+	// the attributes of the directories are set once their content is copied
+	type dirInfo struct {
+		path string
+		fi   os.FileInfo
+	}
+	var dirs []dirInfo
+
+	err := filepath.Walk(srcRoot, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			log.Debugf("Skipping %s from snapshot: %v", path, err)
+			if fi != nil && fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(srcRoot, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dstRoot, rel)
+
+		switch mode := fi.Mode(); {
+		case mode.IsDir():
+			if err := os.Mkdir(target, 0700); err != nil && !os.IsExist(err) {
+				return err
+			}
+			dirs = append(dirs, dirInfo{target, fi})
+			return nil
+		case mode.IsRegular():
+			in, err := os.Open(path)
+			if err != nil {
+				log.Debugf("Skipping unreadable file %s from snapshot: %v", path, err)
+				return nil
+			}
+			defer in.Close()
+			if err := copyFile(in, target); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				log.Debugf("Skipping unreadable link %s from snapshot: %v", path, err)
+				return nil
+			}
+			if err := os.Symlink(s.linkTarget(src, dst, filepath.Join(src, rel), link), target); err != nil {
+				return err
+			}
+			return copyFileOwner(target, fi)
+		default:
+			return nil
+		}
+		return copyFileAttributes(target, fi)
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := copyFileAttributes(dirs[i].path, dirs[i].fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(in io.Reader, dst string) error {
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// copyFileAttributes sets the owner, mode and modification time of the file at path to those
+// described by fi
+func copyFileAttributes(path string, fi os.FileInfo) error {
+	if err := copyFileOwner(path, fi); err != nil {
+		return err
+	}
+	mode := fi.Mode()
+	if err := os.Chmod(path, mode.Perm()|mode&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return err
+	}
+	return os.Chtimes(path, fi.ModTime(), fi.ModTime())
+}
@@ -0,0 +1,158 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+// setSnapshotRunPath sets the run path holding the snapshots for the duration of a test
+func setSnapshotRunPath(t *testing.T) string {
+	runPath, err := ioutil.TempDir("", "run-path-")
+	require.NoError(t, err)
+	old := config.Datadog.GetString("run_path")
+	config.Datadog.Set("run_path", runPath)
+	t.Cleanup(func() {
+		config.Datadog.Set("run_path", old)
+		os.RemoveAll(runPath)
+	})
+	return runPath
+}
+
+func TestSnapshot(t *testing.T) {
+	assert := require.New(t)
+	runPath := setSnapshotRunPath(t)
+
+	// the snapshots left over by a previous process are removed
+	stale := filepath.Join(runPath, "compliance-snapshots", "snapshot-stale")
+	assert.NoError(os.MkdirAll(stale, 0755))
+
+	hostRoot, err := ioutil.TempDir("", "host-root-")
+	assert.NoError(err)
+	defer os.RemoveAll(hostRoot)
+
+	assert.NoError(os.MkdirAll(filepath.Join(hostRoot, "etc/app/conf.d"), 0755))
+	assert.NoError(os.MkdirAll(filepath.Join(hostRoot, "var"), 0755))
+	assert.NoError(os.MkdirAll(filepath.Join(hostRoot, "opt/kube"), 0755))
+	hostFile := filepath.Join(hostRoot, "etc/app/conf.d/app.conf")
+	assert.NoError(ioutil.WriteFile(hostFile, []byte("enabled: true"), 0640))
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(os.Chtimes(hostFile, modTime, modTime))
+	assert.NoError(os.Symlink("conf.d/app.conf", filepath.Join(hostRoot, "etc/app/current.conf")))
+	assert.NoError(os.Symlink("/etc/app/conf.d/app.conf", filepath.Join(hostRoot, "etc/app/absolute.conf")))
+	assert.NoError(os.Symlink("/var/state", filepath.Join(hostRoot, "etc/app/state")))
+	assert.NoError(ioutil.WriteFile(filepath.Join(hostRoot, "var/state"), []byte("1"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(hostRoot, "opt/kube/config"), []byte("kube"), 0600))
+	assert.NoError(os.Symlink("/opt/kube", filepath.Join(hostRoot, "etc/kubernetes")))
+
+	b, err := NewBuilder(nil,
+		WithHostRootMount(hostRoot),
+		WithSnapshot("/etc/app/conf.d", "/etc/app/", "/etc/kubernetes", "/missing"),
+	)
+	assert.NoError(err)
+	env := b.(*builder)
+	_, err = os.Stat(stale)
+	assert.True(os.IsNotExist(err))
+	fi, err := os.Stat(env.snapshotDir)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0700), fi.Mode().Perm())
+
+	release, err := env.takeSnapshot()
+	assert.NoError(err)
+	snapshotRoot := env.snapshot.root
+	assert.Equal(env.snapshotDir, filepath.Dir(snapshotRoot))
+	assert.Equal([]string{"/etc/app", "/etc/kubernetes"}, env.snapshot.paths)
+
+	// the files changed during the run are read from the snapshot
+	assert.NoError(ioutil.WriteFile(hostFile, []byte("enabled: false"), 0600))
+
+	path := env.NormalizeToHostRoot("/etc/app/conf.d/app.conf")
+	assert.Equal(filepath.Join(snapshotRoot, "etc/app/conf.d/app.conf"), path)
+	assert.Equal("/etc/app/conf.d/app.conf", env.RelativeToHostRoot(path))
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal("enabled: true", string(content))
+
+	fi, err = os.Stat(path)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0640), fi.Mode().Perm())
+	assert.True(modTime.Equal(fi.ModTime()))
+
+	// the links resolve within the snapshot, or else the host root
+	link, err := os.Readlink(env.NormalizeToHostRoot("/etc/app/current.conf"))
+	assert.NoError(err)
+	assert.Equal("conf.d/app.conf", link)
+	for _, p := range []string{"/etc/app/current.conf", "/etc/app/absolute.conf"} {
+		content, err = ioutil.ReadFile(env.NormalizeToHostRoot(p))
+		assert.NoError(err)
+		assert.Equal("enabled: true", string(content))
+	}
+	content, err = ioutil.ReadFile(env.NormalizeToHostRoot("/etc/app/state"))
+	assert.NoError(err)
+	assert.Equal("1", string(content))
+
+	// a snapshot path which is a link is copied from its target
+	content, err = ioutil.ReadFile(env.NormalizeToHostRoot("/etc/kubernetes/config"))
+	assert.NoError(err)
+	assert.Equal("kube", string(content))
+
+	// the other paths are read from the host
+	path = env.NormalizeToHostRoot("/var/state")
+	assert.Equal(filepath.Join(hostRoot, "var/state"), path)
+	assert.Equal("/var/state", env.RelativeToHostRoot(path))
+
+	// the snapshot is removed once the suite is run, and taken again for the next one
+	release()
+	_, err = os.Stat(snapshotRoot)
+	assert.True(os.IsNotExist(err))
+	assert.Equal(filepath.Join(hostRoot, "etc/app/conf.d/app.conf"), env.NormalizeToHostRoot("/etc/app/conf.d/app.conf"))
+
+	release, err = env.takeSnapshot()
+	assert.NoError(err)
+	content, err = ioutil.ReadFile(env.NormalizeToHostRoot("/etc/app/conf.d/app.conf"))
+	assert.NoError(err)
+	assert.Equal("enabled: false", string(content))
+	assert.NoError(b.Close())
+	release()
+}
+
+func TestSnapshotUnreadable(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root can read any directory")
+	}
+	assert := require.New(t)
+	setSnapshotRunPath(t)
+
+	hostRoot, err := ioutil.TempDir("", "host-root-")
+	assert.NoError(err)
+	defer os.RemoveAll(hostRoot)
+	assert.NoError(os.MkdirAll(filepath.Join(hostRoot, "etc/app/private"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(hostRoot, "etc/app/app.conf"), []byte("ok"), 0644))
+	assert.NoError(os.Chmod(filepath.Join(hostRoot, "etc/app/private"), 0))
+	defer os.Chmod(filepath.Join(hostRoot, "etc/app/private"), 0755)
+
+	b, err := NewBuilder(nil, WithHostRootMount(hostRoot), WithSnapshot("/etc/app"))
+	assert.NoError(err)
+	defer b.Close()
+	release, err := b.(*builder).takeSnapshot()
+	assert.NoError(err)
+	defer release()
+	content, err := ioutil.ReadFile(b.(*builder).NormalizeToHostRoot("/etc/app/app.conf"))
+	assert.NoError(err)
+	assert.Equal("ok", string(content))
+}
+
+func TestSnapshotRelativePath(t *testing.T) {
+	_, err := NewBuilder(nil, WithSnapshot("etc"))
+	require.Error(t, err)
+}
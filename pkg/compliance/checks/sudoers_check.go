@@ -0,0 +1,362 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+	"github.com/DataDog/datadog-agent/pkg/compliance/eval"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var sudoersReportedFields = []string{
+	compliance.SudoersFieldFile,
+	compliance.SudoersFieldType,
+	compliance.SudoersFieldUsers,
+	compliance.SudoersFieldCommands,
+	compliance.SudoersFieldTags,
+	compliance.SudoersFieldOption,
+	compliance.SudoersFieldValue,
+}
+
+// sudoersMaxIncludeDepth is the maximum depth of nested includes, as enforced by sudo
+const sudoersMaxIncludeDepth = 128
+
+// sudoersTags are the tags which can precede the commands of a user specification
+var sudoersTags = map[string]bool{
+	"NOPASSWD":     true,
+	"PASSWD":       true,
+	"NOEXEC":       true,
+	"EXEC":         true,
+	"SETENV":       true,
+	"NOSETENV":     true,
+	"LOG_INPUT":    true,
+	"NOLOG_INPUT":  true,
+	"LOG_OUTPUT":   true,
+	"NOLOG_OUTPUT": true,
+	"MAIL":         true,
+	"NOMAIL":       true,
+	"FOLLOW":       true,
+	"NOFOLLOW":     true,
+	"INTERCEPT":    true,
+	"NOINTERCEPT":  true,
+}
+
+func resolveSudoers(_ context.Context, e env.Env, ruleID string, res compliance.Resource) (interface{}, error) {
+	if res.Sudoers == nil {
+		return nil, fmt.Errorf("%s: expecting sudoers resource in sudoers check", ruleID)
+	}
+
+	path := res.Sudoers.Path
+	if path == "" {
+		path = compliance.DefaultSudoersPath
+	}
+
+	log.Debugf("%s: running sudoers check for %q", ruleID, path)
+
+	p := &sudoersParser{env: e, visited: make(map[string]bool)}
+	if err := p.parseFile(path, 0); err != nil {
+		return nil, wrapErrorWithID(ruleID, err)
+	}
+
+	return &instanceIterator{
+		instances: p.instances,
+	}, nil
+}
+
+// sudoersParser parses a sudoers file and the files it includes into instances. Paths are
+// relative to the host root.
+type sudoersParser struct {
+	env       env.Env
+	visited   map[string]bool
+	instances []*eval.Instance
+}
+
+func (p *sudoersParser) parseFile(path string, depth int) error {
+	if depth > sudoersMaxIncludeDepth {
+		return fmt.Errorf("too many levels of includes in %s", path)
+	}
+	if p.visited[path] {
+		return nil
+	}
+	p.visited[path] = true
+
+	f, err := openFile(usageFromEnv(p.env), p.env.NormalizeToHostRoot(path))
+	if err != nil {
+		if depth > 0 && os.IsNotExist(err) {
+			log.Debugf("Ignoring missing sudoers include %s", path)
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var lines []string
+	var continued string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, "\\") {
+			continued += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		lines = append(lines, continued+line)
+		continued = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if continued != "" {
+		lines = append(lines, continued)
+	}
+
+	for _, line := range lines {
+		if err := p.parseLine(path, line, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *sudoersParser) parseLine(path, line string, depth int) error {
+	for _, prefix := range []string{"#includedir", "@includedir"} {
+		if dir := strings.TrimPrefix(line, prefix); dir != line {
+			return p.parseDir(p.includePath(path, dir), depth+1)
+		}
+	}
+	for _, prefix := range []string{"#include", "@include"} {
+		if include := strings.TrimPrefix(line, prefix); include != line {
+			return p.parseFile(p.includePath(path, include), depth+1)
+		}
+	}
+
+	line = stripSudoersComment(line)
+	if line == "" {
+		return nil
+	}
+
+	keyword := line
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		keyword = line[:i]
+	}
+	switch {
+	case strings.HasPrefix(keyword, "Defaults"):
+		p.addDefaults(path, line)
+	case strings.HasSuffix(keyword, "_Alias"):
+		// aliases are not expanded, entries report them as they are used
+	default:
+		p.addUserSpec(path, line)
+	}
+	return nil
+}
+
+// includePath returns the path of an included file. Relative paths are relative to the
+// directory of the including file.
+func (p *sudoersParser) includePath(path, include string) string {
+	include = strings.Trim(strings.TrimSpace(include), `"`)
+	if !filepath.IsAbs(include) {
+		include = filepath.Join(filepath.Dir(path), include)
+	}
+	return include
+}
+
+// parseDir parses the files of an included directory. As done by sudo, files whose name
+// ends with "~" or contains a "." are skipped.
+func (p *sudoersParser) parseDir(dir string, depth int) error {
+	files, err := ioutil.ReadDir(p.env.NormalizeToHostRoot(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Debugf("Ignoring missing sudoers include directory %s", dir)
+			return nil
+		}
+		return err
+	}
+	var names []string
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() || strings.HasSuffix(name, "~") || strings.Contains(name, ".") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := p.parseFile(filepath.Join(dir, name), depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDefaults adds an instance for each option of a "Defaults" line, such as
+// `Defaults:%admin !lecture, logfile="/var/log/sudo.log"`
+func (p *sudoersParser) addDefaults(path, line string) {
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return
+	}
+	for _, option := range splitSudoersList(line[i+1:]) {
+		var value string
+		if j := strings.IndexByte(option, '='); j >= 0 {
+			option, value = strings.TrimRight(option[:j], " \t+-"), strings.Trim(strings.TrimSpace(option[j+1:]), `"`)
+		}
+		instance := newSudoersInstance(path, compliance.SudoersTypeDefaults)
+		instance.Vars[compliance.SudoersFieldOption] = option
+		instance.Vars[compliance.SudoersFieldValue] = value
+		p.instances = append(p.instances, instance)
+	}
+}
+
+// addUserSpec adds an instance for a user specification, such as
+// `%admin ALL = (root) NOPASSWD: /usr/bin/apt, PASSWD: /usr/bin/vi`. Runas lists and tags
+// apply to the command they precede and to the following ones, and the entry is reported
+// as allowing commands without password when any of them does.
+func (p *sudoersParser) addUserSpec(path, line string) {
+	i := strings.IndexAny(line, " \t")
+	eq := strings.IndexByte(line, '=')
+	if i < 0 || eq < i {
+		log.Debugf("Skipping unexpected line in %s: %s", path, line)
+		return
+	}
+
+	var (
+		users    = splitSudoersList(line[:i])
+		hosts    = splitSudoersList(line[i+1 : eq])
+		runAs    []string
+		commands []string
+		tags     []string
+		noPasswd bool
+	)
+
+	current := make(map[string]bool)
+	for _, cmd := range splitSudoersList(line[eq+1:]) {
+		if strings.HasPrefix(cmd, "(") {
+			if end := strings.IndexByte(cmd, ')'); end > 0 {
+				runAs = append(runAs, strings.TrimSpace(cmd[1:end]))
+				cmd = strings.TrimSpace(cmd[end+1:])
+			}
+		}
+		for {
+			j := strings.IndexByte(cmd, ':')
+			if j < 0 || !sudoersTags[cmd[:j]] {
+				break
+			}
+			tag := cmd[:j]
+			if strings.HasPrefix(tag, "NO") {
+				delete(current, tag[2:])
+			} else {
+				delete(current, "NO"+tag)
+			}
+			current[tag] = true
+			if !containsString(tags, tag) {
+				tags = append(tags, tag)
+			}
+			cmd = strings.TrimSpace(cmd[j+1:])
+		}
+		if current["NOPASSWD"] {
+			noPasswd = true
+		}
+		commands = append(commands, cmd)
+	}
+
+	instance := newSudoersInstance(path, compliance.SudoersTypeUser)
+	instance.Vars[compliance.SudoersFieldUsers] = users
+	instance.Vars[compliance.SudoersFieldHosts] = hosts
+	instance.Vars[compliance.SudoersFieldRunAs] = strings.Join(runAs, ", ")
+	instance.Vars[compliance.SudoersFieldCommands] = commands
+	instance.Vars[compliance.SudoersFieldTags] = tags
+	instance.Vars[compliance.SudoersFieldNoPasswd] = noPasswd
+	p.instances = append(p.instances, instance)
+}
+
+// newSudoersInstance returns an instance with all the sudoers fields set, so that conditions
+// can refer to the fields of both types of entries
+func newSudoersInstance(path, entryType string) *eval.Instance {
+	return &eval.Instance{
+		Vars: eval.VarMap{
+			compliance.SudoersFieldFile:     path,
+			compliance.SudoersFieldType:     entryType,
+			compliance.SudoersFieldUsers:    []string{},
+			compliance.SudoersFieldHosts:    []string{},
+			compliance.SudoersFieldRunAs:    "",
+			compliance.SudoersFieldCommands: []string{},
+			compliance.SudoersFieldTags:     []string{},
+			compliance.SudoersFieldNoPasswd: false,
+			compliance.SudoersFieldOption:   "",
+			compliance.SudoersFieldValue:    "",
+		},
+	}
+}
+
+// stripSudoersComment removes the comment ending a line. A "#" followed by a digit is a
+// user or group ID rather than a comment.
+func stripSudoersComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] != '#' {
+			continue
+		}
+		if i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9' {
+			continue
+		}
+		if i > 0 && line[i-1] == '\\' {
+			continue
+		}
+		return strings.TrimSpace(line[:i])
+	}
+	return line
+}
+
+// splitSudoersList splits a comma-separated list, ignoring escaped commas and the commas of
+// quoted strings and runas lists
+func splitSudoersList(s string) []string {
+	var (
+		items  []string
+		start  int
+		quoted bool
+		parens int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case '(':
+			parens++
+		case ')':
+			if parens > 0 {
+				parens--
+			}
+		case ',':
+			if !quoted && parens == 0 {
+				items = append(items, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
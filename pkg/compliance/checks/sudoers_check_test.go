@@ -0,0 +1,109 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+	"github.com/DataDog/datadog-agent/pkg/compliance/mocks"
+
+	"github.com/stretchr/testify/mock"
+	assert "github.com/stretchr/testify/require"
+)
+
+func hostRootEnv(root string) *mocks.Env {
+	env := &mocks.Env{}
+	env.On("NormalizeToHostRoot", mock.AnythingOfType("string")).Return(func(path string) string {
+		return filepath.Join(root, path)
+	})
+	return env
+}
+
+func TestSudoersCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		condition    string
+		expectPassed bool
+		expectData   event.Data
+	}{
+		{
+			name:         "no entries without password",
+			condition:    `none(sudoers.noPasswd)`,
+			expectPassed: false,
+			expectData: event.Data{
+				"sudoers.file":     "/etc/sudoers.d/deploy",
+				"sudoers.type":     "user",
+				"sudoers.users":    []string{"deploy"},
+				"sudoers.commands": []string{"/usr/bin/systemctl restart app", "/usr/bin/vi"},
+				"sudoers.tags":     []string{"NOPASSWD", "PASSWD"},
+				"sudoers.option":   "",
+				"sudoers.value":    "",
+			},
+		},
+		{
+			name:         "use pty",
+			condition:    `count(sudoers.type == "defaults" && sudoers.option == "use_pty") > 0`,
+			expectPassed: true,
+		},
+		{
+			name:         "log file",
+			condition:    `count(sudoers.option == "logfile" && sudoers.value == "/var/log/sudo.log") == 1`,
+			expectPassed: true,
+		},
+		{
+			name:         "included files",
+			condition:    `count(sudoers.type == "user") == 3`,
+			expectPassed: true,
+		},
+		{
+			name:         "admin group",
+			condition:    `count("%admin" in sudoers.users && sudoers.runAs == "ALL") == 1`,
+			expectPassed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			env := hostRootEnv("./testdata/sudoers")
+			resource := compliance.Resource{
+				Sudoers:   &compliance.Sudoers{},
+				Condition: test.condition,
+			}
+
+			sudoersCheck, err := newResourceCheck(env, "rule-id", resource)
+			assert.NoError(err)
+
+			report, err := sudoersCheck.check(env)
+			assert.NoError(err)
+			assert.Equal(test.expectPassed, report.Passed)
+			if test.expectData != nil {
+				assert.Equal(test.expectData, report.Data)
+			}
+		})
+	}
+}
+
+func TestSudoersMissingFile(t *testing.T) {
+	env := hostRootEnv("./testdata/sudoers")
+	_, err := resolveSudoers(context.Background(), env, "rule-id", compliance.Resource{
+		Sudoers: &compliance.Sudoers{Path: "/etc/sudoers.missing"},
+	})
+	assert.Error(t, err)
+}
+
+func TestSplitSudoersList(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"(root, operator) /bin/ls", "/bin/kill"}, splitSudoersList("(root, operator) /bin/ls, /bin/kill"))
+	assert.Equal([]string{`secure_path="/a,/b"`, "use_pty"}, splitSudoersList(`secure_path="/a,/b", use_pty`))
+	assert.Equal([]string{`/bin/echo a\,b`}, splitSudoersList(`/bin/echo a\,b`))
+	assert.Equal("#1000 ALL=(ALL) ALL", stripSudoersComment("#1000 ALL=(ALL) ALL # uid"))
+}
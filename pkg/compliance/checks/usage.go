@@ -0,0 +1,168 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"context"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+)
+
+// ruleUsage accumulates the resources consumed by the runs of a rule. A nil ruleUsage
+// accounts nothing.
+type ruleUsage struct {
+	runs         int64
+	cpuTime      int64
+	wallTime     int64
+	filesOpened  int64
+	subprocesses int64
+}
+
+// addRun accounts a run of the rule
+func (u *ruleUsage) addRun(cpuTime, wallTime time.Duration) {
+	if u == nil {
+		return
+	}
+	atomic.AddInt64(&u.runs, 1)
+	atomic.AddInt64(&u.cpuTime, int64(cpuTime))
+	atomic.AddInt64(&u.wallTime, int64(wallTime))
+}
+
+// addFileOpened accounts a file opened by the rule
+func (u *ruleUsage) addFileOpened() {
+	if u == nil {
+		return
+	}
+	atomic.AddInt64(&u.filesOpened, 1)
+}
+
+// addSubprocess accounts a subprocess run by the rule, along with its CPU time
+func (u *ruleUsage) addSubprocess(cpuTime time.Duration) {
+	if u == nil {
+		return
+	}
+	atomic.AddInt64(&u.subprocesses, 1)
+	atomic.AddInt64(&u.cpuTime, int64(cpuTime))
+}
+
+func (u *ruleUsage) snapshot(ruleID, name string) *compliance.RuleUsage {
+	runs := atomic.LoadInt64(&u.runs)
+	cpuTime := durationMs(atomic.LoadInt64(&u.cpuTime))
+	wallTime := durationMs(atomic.LoadInt64(&u.wallTime))
+	s := &compliance.RuleUsage{
+		RuleID:       ruleID,
+		Name:         name,
+		Runs:         runs,
+		CPUTimeMs:    cpuTime,
+		WallTimeMs:   wallTime,
+		FilesOpened:  atomic.LoadInt64(&u.filesOpened),
+		Subprocesses: atomic.LoadInt64(&u.subprocesses),
+	}
+	if runs != 0 {
+		s.AvgCPUTimeMs = cpuTime / float64(runs)
+		s.AvgWallTimeMs = wallTime / float64(runs)
+	}
+	return s
+}
+
+func durationMs(d int64) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// usageEnv is implemented by the environments of the checks accounting their resource usage
+type usageEnv interface {
+	ruleUsage() *ruleUsage
+}
+
+// usageFromEnv returns the resource usage of the rule checked in e, if any
+func usageFromEnv(e env.Env) *ruleUsage {
+	if ue, ok := e.(usageEnv); ok {
+		return ue.ruleUsage()
+	}
+	return nil
+}
+
+type ruleUsageKey struct{}
+
+// withRuleUsage returns a context accounting the subprocesses run with it in u
+func withRuleUsage(ctx context.Context, u *ruleUsage) context.Context {
+	if u == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, ruleUsageKey{}, u)
+}
+
+// ruleUsageFromContext returns the resource usage accounting of ctx, if any
+func ruleUsageFromContext(ctx context.Context) *ruleUsage {
+	u, _ := ctx.Value(ruleUsageKey{}).(*ruleUsage)
+	return u
+}
+
+// openFile opens a file for reading, accounting it in u
+func openFile(u *ruleUsage, path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err == nil {
+		u.addFileOpened()
+	}
+	return f, err
+}
+
+// usageTracker maintains the resource usage of all configured checks
+type usageTracker struct {
+	sync.RWMutex
+	ruleIDs []string
+	names   map[string]string
+	rules   map[string]*ruleUsage
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{
+		names: make(map[string]string),
+		rules: make(map[string]*ruleUsage),
+	}
+}
+
+// addRule returns the resource usage accounting of a rule
+func (t *usageTracker) addRule(ruleID, name string) *ruleUsage {
+	t.Lock()
+	defer t.Unlock()
+
+	if u, ok := t.rules[ruleID]; ok {
+		return u
+	}
+	u := &ruleUsage{}
+	t.ruleIDs = append(t.ruleIDs, ruleID)
+	t.names[ruleID] = name
+	t.rules[ruleID] = u
+	return u
+}
+
+// getRuleUsage returns the resource usage of the rules that ran, by decreasing CPU time
+func (t *usageTracker) getRuleUsage() compliance.RuleUsageList {
+	t.RLock()
+	defer t.RUnlock()
+
+	usage := compliance.RuleUsageList{}
+	for _, ruleID := range t.ruleIDs {
+		s := t.rules[ruleID].snapshot(ruleID, t.names[ruleID])
+		if s.Runs != 0 {
+			usage = append(usage, s)
+		}
+	}
+	sort.SliceStable(usage, func(i, j int) bool {
+		if usage[i].CPUTimeMs != usage[j].CPUTimeMs {
+			return usage[i].CPUTimeMs > usage[j].CPUTimeMs
+		}
+		return usage[i].WallTimeMs > usage[j].WallTimeMs
+	})
+	return usage
+}
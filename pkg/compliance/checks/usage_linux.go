@@ -0,0 +1,41 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package checks
+
+import (
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// measureCPU runs f locked to its thread and returns the CPU time consumed by the thread.
+// The work done by f in other goroutines is not accounted.
+func measureCPU(f func()) time.Duration {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	start, err := threadCPUTime()
+	f()
+	if err != nil {
+		return 0
+	}
+	end, err := threadCPUTime()
+	if err != nil || end < start {
+		return 0
+	}
+	return end - start
+}
+
+func threadCPUTime() (time.Duration, error) {
+	var ru unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_THREAD, &ru); err != nil {
+		return 0, err
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano()), nil
+}
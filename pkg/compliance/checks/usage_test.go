@@ -0,0 +1,123 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUsageTracker(t *testing.T) {
+	assert := assert.New(t)
+
+	tracker := newUsageTracker()
+	cheap := tracker.addRule("rule-1", "rule one")
+	expensive := tracker.addRule("rule-2", "rule two")
+	tracker.addRule("rule-3", "rule three")
+	assert.Equal(cheap, tracker.addRule("rule-1", "rule one"))
+
+	cheap.addRun(time.Millisecond, 2*time.Millisecond)
+	expensive.addRun(10*time.Millisecond, 20*time.Millisecond)
+	expensive.addRun(20*time.Millisecond, 40*time.Millisecond)
+	expensive.addSubprocess(30 * time.Millisecond)
+	expensive.addFileOpened()
+
+	// rules which did not run are not reported
+	assert.Equal(compliance.RuleUsageList{
+		{
+			RuleID:        "rule-2",
+			Name:          "rule two",
+			Runs:          2,
+			CPUTimeMs:     60,
+			AvgCPUTimeMs:  30,
+			WallTimeMs:    60,
+			AvgWallTimeMs: 30,
+			FilesOpened:   1,
+			Subprocesses:  1,
+		},
+		{
+			RuleID:        "rule-1",
+			Name:          "rule one",
+			Runs:          1,
+			CPUTimeMs:     1,
+			AvgCPUTimeMs:  1,
+			WallTimeMs:    2,
+			AvgWallTimeMs: 2,
+		},
+	}, tracker.getRuleUsage())
+
+	// a nil usage accounts nothing
+	var u *ruleUsage
+	u.addRun(time.Second, time.Second)
+	u.addFileOpened()
+	u.addSubprocess(time.Second)
+}
+
+func TestCheckRunUsage(t *testing.T) {
+	assert := assert.New(t)
+
+	env := &mocks.Env{}
+	defer env.AssertExpectations(t)
+
+	reporter := &mocks.Reporter{}
+	defer reporter.AssertExpectations(t)
+
+	checkable := &mockCheckable{}
+	defer checkable.AssertExpectations(t)
+
+	usage := &ruleUsage{}
+	check := &complianceCheck{
+		Env: env,
+
+		ruleID:    "rule-id",
+		checkable: checkable,
+		usage:     usage,
+	}
+
+	env.On("IsLeader").Return(true)
+	env.On("Reporter").Return(reporter)
+	reporter.On("Report", mock.Anything).Once()
+	checkable.On("check", check).Run(func(args mock.Arguments) {
+		u := usageFromEnv(args.Get(0).(*complianceCheck))
+		f, err := openFile(u, "./testdata/file/daemon.json")
+		assert.NoError(err)
+		f.Close()
+		_, err = openFile(u, "./testdata/file/missing.json")
+		assert.Error(err)
+	}).Return(&compliance.Report{Passed: true}, nil)
+
+	assert.NoError(check.Run())
+
+	s := usage.snapshot("rule-id", "")
+	assert.EqualValues(1, s.Runs)
+	assert.EqualValues(1, s.FilesOpened)
+	assert.NotZero(s.WallTimeMs)
+}
+
+func TestRunCommandUsage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	usage := &ruleUsage{}
+	ctx := withRuleUsage(context.Background(), usage)
+	exitCode, _, err := runCommand(ctx, nil, "sh", []string{"-c", "exit 1"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, exitCode)
+	assert.EqualValues(t, 1, usage.subprocesses)
+
+	// commands run without accounting
+	_, _, err = runCommand(context.Background(), nil, "sh", []string{"-c", "exit 0"}, false)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, usage.subprocesses)
+}
@@ -0,0 +1,118 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package compliance
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+)
+
+const (
+	// CoverageRuleID is the rule ID of the events reporting the coverage of a compliance suite
+	CoverageRuleID = "compliance_coverage"
+	// CoverageResourceType is the resource type of the events reporting the coverage of a compliance suite
+	CoverageResourceType = "compliance_suite"
+)
+
+// BenchmarkSection declares a section of the benchmark implemented by a compliance suite
+type BenchmarkSection struct {
+	ID    string `yaml:"id" json:"id"`
+	Title string `yaml:"title,omitempty" json:"title,omitempty"`
+}
+
+// BenchmarkSection returns the ID of the benchmark section checked by the rule. Unless the
+// rule declares it, it is the rule ID without the framework prefix (e.g. "1.2.3" for the rule
+// "cis-docker-1.2.3" of the "cis-docker" framework).
+func (r *Rule) BenchmarkSection(framework string) string {
+	if r.Section != "" {
+		return r.Section
+	}
+	return strings.TrimPrefix(r.ID, framework+"-")
+}
+
+// SectionCoverage lists the rules checking a benchmark section
+type SectionCoverage struct {
+	ID    string   `json:"id"`
+	Title string   `json:"title,omitempty"`
+	Rules []string `json:"rules"`
+}
+
+// CoverageReport maps the rules of a compliance suite to the sections of its benchmark
+type CoverageReport struct {
+	Name      string `json:"name"`
+	Framework string `json:"framework"`
+	Version   string `json:"version"`
+	// Sections lists the sections checked by at least one rule, in the order of the benchmark
+	Sections []SectionCoverage `json:"sections"`
+	// Unimplemented lists the sections of the benchmark not checked by any rule
+	Unimplemented []BenchmarkSection `json:"unimplemented"`
+	// Unlisted lists the rules checking a section which is not part of the benchmark
+	Unlisted []string `json:"unlisted,omitempty"`
+	// Coverage is the ratio of sections of the benchmark checked by at least one rule, 0 when
+	// the suite doesn't list its benchmark sections
+	Coverage float64 `json:"coverage"`
+}
+
+// NewCoverageReport returns the coverage report of a compliance suite. When the suite doesn't
+// list the sections of its benchmark, the report only includes the sections its rules check.
+func NewCoverageReport(s *Suite) *CoverageReport {
+	report := &CoverageReport{
+		Name:          s.Meta.Name,
+		Framework:     s.Meta.Framework,
+		Version:       s.Meta.Version,
+		Sections:      []SectionCoverage{},
+		Unimplemented: []BenchmarkSection{},
+	}
+
+	rules := make(map[string][]string)
+	for _, rule := range s.Rules {
+		section := rule.BenchmarkSection(s.Meta.Framework)
+		rules[section] = append(rules[section], rule.ID)
+	}
+
+	listed := make(map[string]bool, len(s.Benchmark))
+	for _, section := range s.Benchmark {
+		listed[section.ID] = true
+		ids, ok := rules[section.ID]
+		if !ok {
+			report.Unimplemented = append(report.Unimplemented, section)
+			continue
+		}
+		report.Sections = append(report.Sections, SectionCoverage{ID: section.ID, Title: section.Title, Rules: ids})
+	}
+	if len(s.Benchmark) > 0 {
+		report.Coverage = float64(len(report.Sections)) / float64(len(s.Benchmark))
+	}
+
+	var unlisted []string
+	for section := range rules {
+		if !listed[section] {
+			unlisted = append(unlisted, section)
+		}
+	}
+	sort.Strings(unlisted)
+	for _, section := range unlisted {
+		if len(s.Benchmark) > 0 {
+			report.Unlisted = append(report.Unlisted, rules[section]...)
+			continue
+		}
+		report.Sections = append(report.Sections, SectionCoverage{ID: section, Rules: rules[section]})
+	}
+	return report
+}
+
+// Event returns the event reporting the coverage
+func (r *CoverageReport) Event() *event.Event {
+	return &event.Event{
+		AgentRuleID:  CoverageRuleID,
+		ResourceType: CoverageResourceType,
+		ResourceID:   r.Framework + "/" + r.Version,
+		Tags:         []string{"framework:" + r.Framework, "version:" + r.Version},
+		Data:         r,
+	}
+}
@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package compliance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCoverageReport(t *testing.T) {
+	suite := &Suite{
+		Meta: SuiteMeta{
+			Name:      "CIS Docker Generic",
+			Framework: "cis-docker",
+			Version:   "1.2.0",
+		},
+		Rules: []Rule{
+			{ID: "cis-docker-1.1.1"},
+			{ID: "cis-docker-2.1"},
+			{ID: "daemon-config-permissions", Section: "1.1.1"},
+			{ID: "cis-docker-9.9"},
+		},
+	}
+
+	t.Run("benchmark", func(t *testing.T) {
+		assert := assert.New(t)
+		s := *suite
+		s.Benchmark = []BenchmarkSection{
+			{ID: "1.1.1", Title: "Ensure a separate partition for containers has been created"},
+			{ID: "1.1.2"},
+			{ID: "2.1", Title: "Ensure network traffic is restricted between containers"},
+			{ID: "2.2"},
+		}
+		report := NewCoverageReport(&s)
+		assert.Equal([]SectionCoverage{
+			{ID: "1.1.1", Title: "Ensure a separate partition for containers has been created", Rules: []string{"cis-docker-1.1.1", "daemon-config-permissions"}},
+			{ID: "2.1", Title: "Ensure network traffic is restricted between containers", Rules: []string{"cis-docker-2.1"}},
+		}, report.Sections)
+		assert.Equal([]BenchmarkSection{{ID: "1.1.2"}, {ID: "2.2"}}, report.Unimplemented)
+		assert.Equal([]string{"cis-docker-9.9"}, report.Unlisted)
+		assert.Equal(0.5, report.Coverage)
+
+		e := report.Event()
+		assert.Equal(CoverageRuleID, e.AgentRuleID)
+		assert.Equal(CoverageResourceType, e.ResourceType)
+		assert.Equal("cis-docker/1.2.0", e.ResourceID)
+		assert.Equal(report, e.Data)
+	})
+
+	t.Run("no benchmark", func(t *testing.T) {
+		assert := assert.New(t)
+		report := NewCoverageReport(suite)
+		assert.Equal([]SectionCoverage{
+			{ID: "1.1.1", Rules: []string{"cis-docker-1.1.1", "daemon-config-permissions"}},
+			{ID: "2.1", Rules: []string{"cis-docker-2.1"}},
+			{ID: "9.9", Rules: []string{"cis-docker-9.9"}},
+		}, report.Sections)
+		assert.Empty(report.Unimplemented)
+		assert.Empty(report.Unlisted)
+		assert.Zero(report.Coverage)
+	})
+}
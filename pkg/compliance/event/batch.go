@@ -0,0 +1,236 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package event
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// BatchEncodingGzip is the encoding of the events of a batch compressed with gzip
+const BatchEncodingGzip = "gzip"
+
+// DefaultBatchFlushInterval is the flush interval of a BatchReporter configured without a
+// valid one
+const DefaultBatchFlushInterval = 10 * time.Second
+
+// Manifest identifies the suite which reported a set of events, and the host it ran on
+type Manifest struct {
+	SuiteName    string `json:"suite_name,omitempty"`
+	SuiteVersion string `json:"suite_version,omitempty"`
+	Framework    string `json:"framework,omitempty"`
+	Hostname     string `json:"hostname,omitempty"`
+}
+
+// ManifestReporter is implemented by the reporters grouping the events by manifest
+type ManifestReporter interface {
+	ReportWithManifest(manifest Manifest, event *Event)
+}
+
+// Batch is the payload of a batch of events sent at once. The events are encoded as a
+// JSON array compressed with Encoding. The format is experimental and may change.
+type Batch struct {
+	Manifest   Manifest `json:"manifest"`
+	EventCount int      `json:"event_count"`
+	Timestamp  int64    `json:"timestamp"`
+	Encoding   string   `json:"encoding"`
+	Events     []byte   `json:"events"`
+}
+
+// NewBatch returns the batch of the events reported with manifest
+func NewBatch(manifest Manifest, events []*Event) (*Batch, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return &Batch{
+		Manifest:   manifest,
+		EventCount: len(events),
+		Timestamp:  time.Now().Unix(),
+		Encoding:   BatchEncodingGzip,
+		Events:     buf.Bytes(),
+	}, nil
+}
+
+// DecodeEvents returns the events of the batch
+func (b *Batch) DecodeEvents() ([]*Event, error) {
+	if b.Encoding != BatchEncodingGzip {
+		return nil, fmt.Errorf("unsupported batch encoding %q", b.Encoding)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b.Events))
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var events []*Event
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// BatchReporter is a Reporter sending the events in compressed batches, one per manifest,
+// rather than one at a time. The events of a manifest are sent once maxEvents of them are
+// pending, or at the latest after the flush interval, so that the events of a check run
+// are usually sent together.
+//
+// As the intake may not support batches yet, the events of a batch which can't be sent are
+// reported one at a time by the fallback reporter instead. Once the intake rejects a batch,
+// all the events are reported by the fallback reporter.
+type BatchReporter struct {
+	sendBatch     func(payload []byte) error
+	fallback      Reporter
+	maxEvents     int
+	flushInterval time.Duration
+
+	mu       sync.Mutex
+	pending  map[Manifest][]*Event
+	rejected bool // whether the intake rejected a batch
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBatchReporter returns a BatchReporter sending the batches of events with sendBatch, which
+// returns an error when a batch isn't accepted by the intake, as the Send method of the HTTP
+// destinations of the logs does. A maxEvents of zero or less doesn't limit the size of the
+// batches, and a flushInterval of zero or less falls back to DefaultBatchFlushInterval.
+func NewBatchReporter(sendBatch func(payload []byte) error, fallback Reporter, maxEvents int, flushInterval time.Duration) *BatchReporter {
+	if flushInterval <= 0 {
+		log.Warnf("Invalid flush interval %s for the batches of rule events, using %s", flushInterval, DefaultBatchFlushInterval)
+		flushInterval = DefaultBatchFlushInterval
+	}
+	return &BatchReporter{
+		sendBatch:     sendBatch,
+		fallback:      fallback,
+		maxEvents:     maxEvents,
+		flushInterval: flushInterval,
+		pending:       make(map[Manifest][]*Event),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start starts flushing the pending events periodically
+func (r *BatchReporter) Start() {
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.Flush()
+			case <-r.stop:
+				r.Flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop flushes the pending events and stops the reporter
+func (r *BatchReporter) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// Report implements Reporter, batching the events reported without manifest together
+func (r *BatchReporter) Report(event *Event) {
+	r.ReportWithManifest(Manifest{}, event)
+}
+
+// ReportWithManifest implements ManifestReporter
+func (r *BatchReporter) ReportWithManifest(manifest Manifest, event *Event) {
+	r.mu.Lock()
+	if r.rejected {
+		r.mu.Unlock()
+		r.fallback.Report(event)
+		return
+	}
+	events := append(r.pending[manifest], event)
+	if r.maxEvents > 0 && len(events) >= r.maxEvents {
+		delete(r.pending, manifest)
+	} else {
+		r.pending[manifest] = events
+		events = nil
+	}
+	r.mu.Unlock()
+
+	if events != nil {
+		r.send(manifest, events)
+	}
+}
+
+// Flush sends the pending events
+func (r *BatchReporter) Flush() {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[Manifest][]*Event)
+	r.mu.Unlock()
+
+	for manifest, events := range pending {
+		r.send(manifest, events)
+	}
+}
+
+func (r *BatchReporter) send(manifest Manifest, events []*Event) {
+	r.mu.Lock()
+	rejected := r.rejected
+	r.mu.Unlock()
+	if rejected {
+		r.reportEach(events)
+		return
+	}
+
+	batch, err := NewBatch(manifest, events)
+	if err != nil {
+		log.Errorf("Failed to compress a batch of %d rule events of suite %s: %v", len(events), manifest.SuiteName, err)
+		r.reportEach(events)
+		return
+	}
+	buf, err := json.Marshal(batch)
+	if err != nil {
+		log.Errorf("Failed to serialize a batch of %d rule events of suite %s: %v", len(events), manifest.SuiteName, err)
+		r.reportEach(events)
+		return
+	}
+	log.Debugf("Sending a batch of %d rule events of suite %s (%d bytes)", len(events), manifest.SuiteName, len(buf))
+
+	if err := r.sendBatch(buf); err != nil {
+		if _, ok := err.(*client.RetryableError); ok {
+			log.Warnf("Could not send a batch of %d rule events of suite %s, sending them one at a time: %v", len(events), manifest.SuiteName, err)
+		} else {
+			log.Warnf("The intake rejected a batch of rule events of suite %s, sending all the events one at a time from now on: %v", manifest.SuiteName, err)
+			r.mu.Lock()
+			r.rejected = true
+			r.mu.Unlock()
+		}
+		r.reportEach(events)
+	}
+}
+
+// reportEach reports the events one at a time with the fallback reporter.
+func (r *BatchReporter) reportEach(events []*Event) {
+	for _, event := range events {
+		r.fallback.Report(event)
+	}
+}
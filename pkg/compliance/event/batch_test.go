@@ -0,0 +1,158 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// sendBatchTo returns a function sending the batches to batchChan.
+func sendBatchTo(batchChan chan []byte) func([]byte) error {
+	return func(payload []byte) error {
+		batchChan <- payload
+		return nil
+	}
+}
+
+// testReporter records the events reported one at a time.
+type testReporter struct {
+	sync.Mutex
+	events []*Event
+}
+
+func (r *testReporter) Report(event *Event) {
+	r.Lock()
+	r.events = append(r.events, event)
+	r.Unlock()
+}
+
+func decodeBatch(t *testing.T, payload []byte) (*Batch, []*Event) {
+	var batch Batch
+	if err := json.Unmarshal(payload, &batch); err != nil {
+		t.Fatal(err)
+	}
+	events, err := batch.DecodeEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &batch, events
+}
+
+func TestBatchReporter(t *testing.T) {
+	assert := assert.New(t)
+
+	batchChan := make(chan []byte, 10)
+	fallback := &testReporter{}
+	r := NewBatchReporter(sendBatchTo(batchChan), fallback, 3, time.Hour)
+	r.Start()
+
+	cis := Manifest{SuiteName: "CIS Docker", SuiteVersion: "1.2.0", Framework: "cis-docker", Hostname: "host"}
+	pci := Manifest{SuiteName: "PCI", SuiteVersion: "1.0.0", Framework: "pci", Hostname: "host"}
+	for i := 0; i < 4; i++ {
+		r.ReportWithManifest(cis, &Event{AgentRuleID: fmt.Sprintf("cis-docker-%d", i), Result: Passed})
+	}
+	r.ReportWithManifest(pci, &Event{AgentRuleID: "pci-1", Result: Failed})
+
+	// the first 3 events of the CIS suite are sent as soon as the batch is full
+	if !assert.Len(batchChan, 1) {
+		return
+	}
+	batch, events := decodeBatch(t, <-batchChan)
+	assert.Equal(cis, batch.Manifest)
+	assert.Equal(3, batch.EventCount)
+	assert.Equal(BatchEncodingGzip, batch.Encoding)
+	if assert.Len(events, 3) {
+		assert.Equal("cis-docker-0", events[0].AgentRuleID)
+		assert.Equal(Passed, events[0].Result)
+	}
+
+	// the others when the reporter stops
+	r.Stop()
+	assert.Len(batchChan, 2)
+	for i := 0; i < 2; i++ {
+		batch, events := decodeBatch(t, <-batchChan)
+		assert.Equal(1, batch.EventCount)
+		switch batch.Manifest {
+		case cis:
+			assert.Equal("cis-docker-3", events[0].AgentRuleID)
+		case pci:
+			assert.Equal("pci-1", events[0].AgentRuleID)
+		default:
+			t.Errorf("unexpected manifest %+v", batch.Manifest)
+		}
+	}
+	assert.Empty(fallback.events)
+}
+
+func TestBatchReporterFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	var sendErr error
+	var sent int
+	fallback := &testReporter{}
+	r := NewBatchReporter(func([]byte) error {
+		sent++
+		return sendErr
+	}, fallback, 2, time.Hour)
+
+	// the events of a batch which couldn't be sent are reported one at a time, and the next
+	// events are batched again
+	sendErr = client.NewRetryableError(errors.New("server error"))
+	r.Report(&Event{AgentRuleID: "rule-1"})
+	r.Report(&Event{AgentRuleID: "rule-2"})
+	assert.Equal(1, sent)
+	assert.Len(fallback.events, 2)
+
+	// once the intake rejects a batch, all the events are reported one at a time
+	sendErr = errors.New("client error")
+	r.Report(&Event{AgentRuleID: "rule-3"})
+	r.Report(&Event{AgentRuleID: "rule-4"})
+	assert.Equal(2, sent)
+	r.Report(&Event{AgentRuleID: "rule-5"})
+	r.Flush()
+	assert.Equal(2, sent)
+
+	var ids []string
+	for _, e := range fallback.events {
+		ids = append(ids, e.AgentRuleID)
+	}
+	assert.Equal([]string{"rule-1", "rule-2", "rule-3", "rule-4", "rule-5"}, ids)
+}
+
+func TestBatchReporterFlushInterval(t *testing.T) {
+	batchChan := make(chan []byte, 10)
+	r := NewBatchReporter(sendBatchTo(batchChan), &testReporter{}, 100, 10*time.Millisecond)
+	r.Start()
+	defer r.Stop()
+
+	r.Report(&Event{AgentRuleID: "rule-1", Result: Error})
+	select {
+	case payload := <-batchChan:
+		batch, events := decodeBatch(t, payload)
+		assert.Equal(t, Manifest{}, batch.Manifest)
+		assert.Len(t, events, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the batch was not flushed")
+	}
+}
+
+func TestBatchReporterInvalidFlushInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		r := NewBatchReporter(sendBatchTo(make(chan []byte, 10)), &testReporter{}, 100, interval)
+		assert.Equal(t, DefaultBatchFlushInterval, r.flushInterval)
+		// the ticker of a reporter never panics on start
+		r.Start()
+		r.Stop()
+	}
+}
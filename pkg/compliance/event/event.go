@@ -24,6 +24,7 @@ type Event struct {
 	Result           string      `json:"result,omitempty"`
 	ResourceType     string      `json:"resource_type,omitempty"`
 	ResourceID       string      `json:"resource_id,omitempty"`
+	ResourceIdentity string      `json:"resource_identity,omitempty"`
 	Tags             []string    `json:"tags"`
 	Data             interface{} `json:"data,omitempty"`
 }
@@ -0,0 +1,152 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package compliance
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DriftRuleIDPrefix is the prefix of the IDs of the rules compiled from an expected state. The
+// failed events of these rules report a drift from the expected state.
+const DriftRuleIDPrefix = "drift:"
+
+// driftSection is the benchmark section of the rules compiled from an expected state
+const driftSection = "expected-state"
+
+var (
+	// packageNamePattern matches the names of the packages of dpkg and rpm, which are passed
+	// to a shell command
+	packageNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.+_-]*$`)
+	// sysctlNamePattern matches the names of kernel parameters
+	sysctlNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)*$`)
+	// sha256Pattern matches a hex-encoded SHA-256 hash
+	sha256Pattern = regexp.MustCompile(`^[a-fA-F0-9]{64}$`)
+)
+
+// ExpectedState declares the state a host is expected to be in: the content of files, the
+// versions of packages and the values of kernel parameters. It is compiled into rules so that
+// a suite can audit the state of a host without writing individual rules. The events of the
+// rules failing, with the actual state as evidence, report a drift from the expected state.
+type ExpectedState struct {
+	// Scope is the scope of the rules compiled from the expected state
+	Scope    RuleScopeList     `yaml:"scope"`
+	Files    []ExpectedFile    `yaml:"files,omitempty"`
+	Packages []ExpectedPackage `yaml:"packages,omitempty"`
+	Sysctls  []ExpectedSysctl  `yaml:"sysctls,omitempty"`
+}
+
+// ExpectedFile declares the expected content of a file by its hash
+type ExpectedFile struct {
+	Path   string `yaml:"path"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// ExpectedPackage declares the expected version of a dpkg or rpm package
+type ExpectedPackage struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+// ExpectedSysctl declares the expected value of a kernel parameter (e.g. net.ipv4.ip_forward).
+// The fields of parameters with several values are separated by whitespaces.
+type ExpectedSysctl struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// Rules returns the rules checking the expected state
+func (s *ExpectedState) Rules() []Rule {
+	var rules []Rule
+	for _, f := range s.Files {
+		rules = append(rules, s.rule("file:"+f.Path,
+			fmt.Sprintf("File %s has the SHA-256 hash %s", f.Path, strings.ToLower(f.SHA256)),
+			Resource{
+				File:      &File{Path: f.Path},
+				Condition: FileFuncSHA256 + "() == " + strconv.Quote(strings.ToLower(f.SHA256)),
+				Evidence:  &Evidence{Expression: FileFuncSHA256 + "()"},
+			}))
+	}
+	for _, p := range s.Packages {
+		run := fmt.Sprintf("dpkg-query -W -f='${Version}' %[1]s 2>/dev/null || rpm -q --qf '%%{VERSION}-%%{RELEASE}' %[1]s 2>/dev/null", p.Name)
+		rules = append(rules, s.rule("package:"+p.Name,
+			fmt.Sprintf("Package %s is installed at version %s", p.Name, p.Version),
+			Resource{
+				Command:   &Command{ShellCmd: &ShellCmd{Run: run}},
+				Condition: CommandFieldExitCode + " == 0 && " + CommandFieldStdout + " == " + strconv.Quote(p.Version),
+				Evidence:  &Evidence{Expression: CommandFieldStdout},
+			}))
+	}
+	for _, p := range s.Sysctls {
+		// the fields of the files of /proc/sys are separated by tabs
+		value := strings.Join(strings.Fields(p.Value), "\t")
+		rules = append(rules, s.rule("sysctl:"+p.Name,
+			fmt.Sprintf("Kernel parameter %s is set to %s", p.Name, p.Value),
+			Resource{
+				File:      &File{Path: sysctlPath(p.Name)},
+				Condition: FileFuncRegexp + `("^.*") == ` + strconv.Quote(value),
+				Evidence:  &Evidence{Expression: FileFuncRegexp + `("^.*")`},
+			}))
+	}
+	return rules
+}
+
+func (s *ExpectedState) rule(id, description string, resource Resource) Rule {
+	return Rule{
+		ID:          DriftRuleIDPrefix + id,
+		Description: description,
+		Scope:       s.Scope,
+		Resources:   []Resource{resource},
+		Section:     driftSection,
+	}
+}
+
+// sysctlPath returns the path of the file of /proc/sys holding a kernel parameter
+func sysctlPath(name string) string {
+	return filepath.Join("/proc/sys", strings.Replace(name, ".", "/", -1))
+}
+
+// validateExpectedState reports the entries of the expected state which can't be compiled
+// into rules
+func (v *suiteValidator) validateExpectedState(s *ExpectedState) {
+	if len(s.Scope) == 0 {
+		v.errorf(0, "expected state is missing a scope")
+	}
+	for _, scope := range s.Scope {
+		switch scope {
+		case DockerScope, KubernetesNodeScope, KubernetesClusterScope:
+		default:
+			v.errorf(0, "expected state has unknown scope %q", scope)
+		}
+	}
+	for i, f := range s.Files {
+		if !filepath.IsAbs(f.Path) {
+			v.errorf(0, "expected state: file %d must have an absolute path, got %q", i+1, f.Path)
+		}
+		if !sha256Pattern.MatchString(f.SHA256) {
+			v.errorf(0, "expected state: file %s must have a hex-encoded SHA-256 hash", f.Path)
+		}
+	}
+	for i, p := range s.Packages {
+		if !packageNamePattern.MatchString(p.Name) {
+			v.errorf(0, "expected state: package %d has invalid name %q", i+1, p.Name)
+		}
+		if p.Version == "" {
+			v.errorf(0, "expected state: package %s is missing a version", p.Name)
+		}
+	}
+	for i, p := range s.Sysctls {
+		if !sysctlNamePattern.MatchString(p.Name) {
+			v.errorf(0, "expected state: sysctl %d has invalid name %q", i+1, p.Name)
+		}
+		if strings.TrimSpace(p.Value) == "" {
+			v.errorf(0, "expected state: sysctl %s is missing a value", p.Name)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package compliance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpectedStateRules(t *testing.T) {
+	assert := assert.New(t)
+	s := &ExpectedState{
+		Scope: RuleScopeList{DockerScope},
+		Files: []ExpectedFile{
+			{Path: "/etc/docker/daemon.json", SHA256: "D08190AD49CAB6425453CEA9B142737A8E376D6C34D2086F120D398136940AE9"},
+		},
+		Packages: []ExpectedPackage{
+			{Name: "docker-ce", Version: "5:19.03.13~3-0~ubuntu-focal"},
+		},
+		Sysctls: []ExpectedSysctl{
+			{Name: "net.ipv4.tcp_rmem", Value: "4096 87380  6291456"},
+		},
+	}
+
+	rules := s.Rules()
+	assert.Len(rules, 3)
+	for _, rule := range rules {
+		assert.Equal(RuleScopeList{DockerScope}, rule.Scope)
+		assert.Equal("expected-state", rule.BenchmarkSection("golden-image"))
+		assert.Len(rule.Resources, 1)
+		assert.NoError(validateResourceFields(&rule.Resources[0]))
+	}
+
+	file := rules[0]
+	assert.Equal("drift:file:/etc/docker/daemon.json", file.ID)
+	assert.Equal("/etc/docker/daemon.json", file.Resources[0].File.Path)
+	assert.Equal(`file.sha256() == "d08190ad49cab6425453cea9b142737a8e376d6c34d2086f120d398136940ae9"`, file.Resources[0].Condition)
+	assert.Equal("file.sha256()", file.Resources[0].Evidence.Expression)
+
+	pkg := rules[1]
+	assert.Equal("drift:package:docker-ce", pkg.ID)
+	assert.Contains(pkg.Resources[0].Command.ShellCmd.Run, "dpkg-query -W -f='${Version}' docker-ce")
+	assert.Contains(pkg.Resources[0].Command.ShellCmd.Run, "rpm -q --qf '%{VERSION}-%{RELEASE}' docker-ce")
+	assert.Equal(`command.exitCode == 0 && command.stdout == "5:19.03.13~3-0~ubuntu-focal"`, pkg.Resources[0].Condition)
+
+	sysctl := rules[2]
+	assert.Equal("drift:sysctl:net.ipv4.tcp_rmem", sysctl.ID)
+	assert.Equal("/proc/sys/net/ipv4/tcp_rmem", sysctl.Resources[0].File.Path)
+	assert.Equal(`file.regexp("^.*") == "4096\t87380\t6291456"`, sysctl.Resources[0].Condition)
+}
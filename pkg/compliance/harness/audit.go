@@ -0,0 +1,45 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package harness
+
+import (
+	"fmt"
+
+	"github.com/elastic/go-libaudit/rule"
+	"github.com/elastic/go-libaudit/rule/flags"
+)
+
+// AuditClient is a fake env.AuditClient returning a fixed set of audit rules
+type AuditClient struct {
+	Rules []*rule.FileWatchRule
+}
+
+// NewAuditClient returns an AuditClient from audit rules in auditctl syntax, for instance
+// `-w /usr/bin/dockerd -p rwxa -k docker`. Rules other than file watches are ignored, like
+// the actual client does.
+func NewAuditClient(lines ...string) (*AuditClient, error) {
+	c := &AuditClient{}
+	for _, line := range lines {
+		r, err := flags.Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid audit rule %q: %w", line, err)
+		}
+		if r, ok := r.(*rule.FileWatchRule); ok {
+			c.Rules = append(c.Rules, r)
+		}
+	}
+	return c, nil
+}
+
+// GetFileWatchRules implements env.AuditClient
+func (c *AuditClient) GetFileWatchRules() ([]*rule.FileWatchRule, error) {
+	return c.Rules, nil
+}
+
+// Close implements env.AuditClient
+func (c *AuditClient) Close() error {
+	return nil
+}
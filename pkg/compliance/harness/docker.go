@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+	"github.com/docker/docker/api/types"
+)
+
+// DockerClient is a fake env.DockerClient serving fixed docker API responses. Only the
+// methods used by compliance checks are implemented, calling any other method panics.
+type DockerClient struct {
+	env.DockerClient
+
+	DaemonInfo    types.Info
+	DaemonVersion types.Version
+	Images        []types.ImageInspect
+	Containers    []types.ContainerJSON
+	Networks      []types.NetworkResource
+}
+
+// Info implements env.DockerClient
+func (c *DockerClient) Info(ctx context.Context) (types.Info, error) {
+	return c.DaemonInfo, nil
+}
+
+// ServerVersion implements env.DockerClient
+func (c *DockerClient) ServerVersion(ctx context.Context) (types.Version, error) {
+	return c.DaemonVersion, nil
+}
+
+// ImageList implements env.DockerClient
+func (c *DockerClient) ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error) {
+	images := make([]types.ImageSummary, 0, len(c.Images))
+	for _, image := range c.Images {
+		images = append(images, types.ImageSummary{
+			ID:          image.ID,
+			ParentID:    image.Parent,
+			RepoTags:    image.RepoTags,
+			RepoDigests: image.RepoDigests,
+			Size:        image.Size,
+			VirtualSize: image.VirtualSize,
+		})
+	}
+	return images, nil
+}
+
+// ImageInspectWithRaw implements env.DockerClient
+func (c *DockerClient) ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error) {
+	for _, image := range c.Images {
+		if image.ID == imageID {
+			return image, nil, nil
+		}
+	}
+	return types.ImageInspect{}, nil, fmt.Errorf("no such image: %s", imageID)
+}
+
+// ContainerList implements env.DockerClient
+func (c *DockerClient) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	containers := make([]types.Container, 0, len(c.Containers))
+	for _, container := range c.Containers {
+		if container.ContainerJSONBase == nil {
+			continue
+		}
+		summary := types.Container{
+			ID:      container.ID,
+			Names:   []string{container.Name},
+			ImageID: container.Image,
+		}
+		if container.Config != nil {
+			summary.Image = container.Config.Image
+			summary.Labels = container.Config.Labels
+		}
+		if container.State != nil {
+			summary.State = container.State.Status
+		}
+		containers = append(containers, summary)
+	}
+	return containers, nil
+}
+
+// ContainerInspect implements env.DockerClient
+func (c *DockerClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	for _, container := range c.Containers {
+		if container.ContainerJSONBase != nil && container.ID == containerID {
+			return container, nil
+		}
+	}
+	return types.ContainerJSON{}, fmt.Errorf("no such container: %s", containerID)
+}
+
+// NetworkList implements env.DockerClient
+func (c *DockerClient) NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error) {
+	return c.Networks, nil
+}
+
+// Close implements env.DockerClient
+func (c *DockerClient) Close() error {
+	return nil
+}
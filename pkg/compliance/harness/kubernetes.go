@@ -0,0 +1,23 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package harness
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+// NewKubeClient returns a fake env.KubeClient serving the given objects
+func NewKubeClient(objects ...*unstructured.Unstructured) env.KubeClient {
+	objs := make([]runtime.Object, 0, len(objects))
+	for _, o := range objects {
+		objs = append(objs, o)
+	}
+	return fake.NewSimpleDynamicClient(runtime.NewScheme(), objs...)
+}
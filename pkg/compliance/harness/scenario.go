@@ -0,0 +1,271 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// Package harness runs compliance rule suites against fake docker, Kubernetes and audit
+// backends, so that rules can be tested without access to the systems they check.
+package harness
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/compliance/checks"
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+	"github.com/docker/docker/api/types"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Skipped is the result of a rule which does not apply to the scenario
+const Skipped = "skipped"
+
+// DockerFixtures lists the files holding the responses of the fake docker daemon, as
+// returned by the docker API
+type DockerFixtures struct {
+	Info       string   `yaml:"info,omitempty"`
+	Version    string   `yaml:"version,omitempty"`
+	Images     []string `yaml:"images,omitempty"`
+	Containers []string `yaml:"containers,omitempty"`
+	Networks   string   `yaml:"networks,omitempty"`
+}
+
+// Scenario describes the environment a rule suite is run in and the expected result of its
+// rules. File paths are relative to the directory of the scenario file.
+type Scenario struct {
+	// Suite is the rule suite to run
+	Suite string `yaml:"suite"`
+	// Hostname is the hostname reported by checks
+	Hostname string `yaml:"hostname,omitempty"`
+	// HostRoot is the directory used as the root filesystem of the host
+	HostRoot string `yaml:"hostRoot,omitempty"`
+	// KubernetesNode runs node scoped rules as if the host was a Kubernetes node
+	KubernetesNode bool `yaml:"kubernetesNode,omitempty"`
+	// NodeLabels are the labels of the Kubernetes node
+	NodeLabels map[string]string `yaml:"nodeLabels,omitempty"`
+	// Parameters are the values of the rule parameters
+	Parameters map[string]interface{} `yaml:"parameters,omitempty"`
+	// Docker enables the fake docker daemon
+	Docker *DockerFixtures `yaml:"docker,omitempty"`
+	// Audit enables the fake audit client, with rules in auditctl syntax
+	Audit []string `yaml:"audit,omitempty"`
+	// Kubernetes enables the fake Kubernetes API server, serving the objects of the given
+	// JSON files
+	Kubernetes []string `yaml:"kubernetes,omitempty"`
+	// Expect maps rule IDs to their expected result: passed, failed, error or skipped
+	Expect map[string]string `yaml:"expect"`
+
+	dir string
+}
+
+// Results maps rule IDs to the last event reported for them
+type Results map[string]*event.Event
+
+// LoadScenario reads a scenario from a YAML file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scenario{}
+	if err := yaml.UnmarshalStrict(data, s); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	if s.Suite == "" {
+		return nil, fmt.Errorf("invalid scenario %s: no suite", path)
+	}
+	s.dir = filepath.Dir(path)
+	return s, nil
+}
+
+// Verify runs the scenario of the given file and checks the results match its expectations
+func Verify(path string) error {
+	s, err := LoadScenario(path)
+	if err != nil {
+		return err
+	}
+
+	results, err := s.Run()
+	if err != nil {
+		return err
+	}
+	return s.Check(results)
+}
+
+// Run runs the rules of the suite once and returns their results
+func (s *Scenario) Run() (Results, error) {
+	options, err := s.builderOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	reporter := &recorder{results: Results{}}
+	builder, err := checks.NewBuilder(reporter, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer builder.Close()
+
+	err = builder.ChecksFromFile(s.path(s.Suite), func(rule *compliance.Rule, check compliance.Check, err error) bool {
+		if err != nil {
+			reporter.Report(&event.Event{
+				AgentRuleID: rule.ID,
+				Result:      Skipped,
+				Data:        event.Data{"error": err.Error()},
+			})
+			return true
+		}
+		_ = check.Run()
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reporter.results, nil
+}
+
+// Check returns an error listing the rules whose result does not match the expectations
+func (s *Scenario) Check(results Results) error {
+	var mismatches []string
+	for ruleID, expected := range s.Expect {
+		result := "missing"
+		if e, ok := results[ruleID]; ok {
+			result = e.Result
+		}
+		if result != expected {
+			mismatches = append(mismatches, fmt.Sprintf("rule %s: expected %s, got %s", ruleID, expected, result))
+		}
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+	sort.Strings(mismatches)
+	return errors.New(strings.Join(mismatches, "\n"))
+}
+
+func (s *Scenario) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(s.dir, p)
+}
+
+func (s *Scenario) builderOptions() ([]checks.BuilderOption, error) {
+	options := []checks.BuilderOption{
+		checks.WithHostname(s.Hostname),
+		checks.WithNodeLabels(s.NodeLabels),
+		checks.WithParameterResolvers(checks.StaticParameters(s.Parameters)),
+	}
+
+	if s.HostRoot != "" {
+		options = append(options, checks.WithHostRootMount(s.path(s.HostRoot)))
+	}
+
+	if s.KubernetesNode {
+		options = append(options, checks.WithKubernetesNode())
+	}
+
+	if s.Docker != nil {
+		cli, err := s.dockerClient()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, checks.WithDockerClient(cli))
+	}
+
+	if s.Audit != nil {
+		cli, err := NewAuditClient(s.Audit...)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, checks.WithAuditClient(cli))
+	}
+
+	if s.Kubernetes != nil {
+		objects := make([]*unstructured.Unstructured, 0, len(s.Kubernetes))
+		for _, file := range s.Kubernetes {
+			data, err := ioutil.ReadFile(s.path(file))
+			if err != nil {
+				return nil, err
+			}
+			o := &unstructured.Unstructured{}
+			if err := o.UnmarshalJSON(data); err != nil {
+				return nil, fmt.Errorf("invalid Kubernetes object %s: %w", file, err)
+			}
+			objects = append(objects, o)
+		}
+		options = append(options, checks.WithKubernetesClient(NewKubeClient(objects...)))
+	}
+
+	return options, nil
+}
+
+func (s *Scenario) dockerClient() (*DockerClient, error) {
+	cli := &DockerClient{}
+	fixtures := s.Docker
+
+	if fixtures.Info != "" {
+		if err := s.loadJSON(fixtures.Info, &cli.DaemonInfo); err != nil {
+			return nil, err
+		}
+	}
+	if fixtures.Version != "" {
+		if err := s.loadJSON(fixtures.Version, &cli.DaemonVersion); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range fixtures.Images {
+		var image types.ImageInspect
+		if err := s.loadJSON(file, &image); err != nil {
+			return nil, err
+		}
+		cli.Images = append(cli.Images, image)
+	}
+	for _, file := range fixtures.Containers {
+		var container types.ContainerJSON
+		if err := s.loadJSON(file, &container); err != nil {
+			return nil, err
+		}
+		cli.Containers = append(cli.Containers, container)
+	}
+	if fixtures.Networks != "" {
+		if err := s.loadJSON(fixtures.Networks, &cli.Networks); err != nil {
+			return nil, err
+		}
+	}
+	return cli, nil
+}
+
+func (s *Scenario) loadJSON(file string, v interface{}) error {
+	data, err := ioutil.ReadFile(s.path(file))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid docker fixture %s: %w", file, err)
+	}
+	return nil
+}
+
+// recorder is an event.Reporter keeping the last event of each rule
+type recorder struct {
+	sync.Mutex
+	results Results
+}
+
+// Report implements event.Reporter
+func (r *recorder) Report(e *event.Event) {
+	r.Lock()
+	defer r.Unlock()
+	r.results[e.AgentRuleID] = e
+}
@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package harness
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	assert.NoError(t, Verify("./testdata/scenario.yaml"))
+}
+
+func TestScenarioRun(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := LoadScenario("./testdata/scenario.yaml")
+	assert.NoError(err)
+
+	results, err := s.Run()
+	assert.NoError(err)
+	assert.Len(results, len(s.Expect))
+
+	e := results["docker-image-healthcheck"]
+	assert.Equal(event.Failed, e.Result)
+	assert.Equal(event.Data{
+		"image.id":   "sha256:f9b9909726890b00d2098081642edf32e5211b7ab53563929a47f250bcdc1d7c",
+		"image.tags": []string{"redis:latest"},
+	}, e.Data)
+
+	s.Expect["docker-version"] = event.Failed
+	s.Expect["unknown-rule"] = event.Passed
+	assert.EqualError(s.Check(results), "rule docker-version: expected failed, got passed\nrule unknown-rule: expected passed, got missing")
+}
+
+func TestLoadScenarioErrors(t *testing.T) {
+	_, err := LoadScenario("./testdata/missing.yaml")
+	assert.Error(t, err)
+
+	_, err = LoadScenario("./testdata/suite.yaml")
+	assert.Error(t, err)
+}
+
+func TestNewAuditClient(t *testing.T) {
+	assert := assert.New(t)
+
+	cli, err := NewAuditClient("-w /etc/docker -p wa -k docker", "-a always,exit -F arch=b64 -S execve")
+	assert.NoError(err)
+
+	rules, err := cli.GetFileWatchRules()
+	assert.NoError(err)
+	assert.Len(rules, 1)
+	assert.Equal("/etc/docker", rules[0].Path)
+
+	_, err = NewAuditClient("-w")
+	assert.Error(err)
+}
@@ -40,6 +40,22 @@ func (_m *Builder) Close() error {
 	return r0
 }
 
+// GetBackendStatus provides a mock function with given fields:
+func (_m *Builder) GetBackendStatus() compliance.BackendStatusList {
+	ret := _m.Called()
+
+	var r0 compliance.BackendStatusList
+	if rf, ok := ret.Get(0).(func() compliance.BackendStatusList); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(compliance.BackendStatusList)
+		}
+	}
+
+	return r0
+}
+
 // GetCheckStatus provides a mock function with given fields:
 func (_m *Builder) GetCheckStatus() compliance.CheckStatusList {
 	ret := _m.Called()
@@ -55,3 +71,19 @@ func (_m *Builder) GetCheckStatus() compliance.CheckStatusList {
 
 	return r0
 }
+
+// GetRuleUsage provides a mock function with given fields:
+func (_m *Builder) GetRuleUsage() compliance.RuleUsageList {
+	ret := _m.Called()
+
+	var r0 compliance.RuleUsageList
+	if rf, ok := ret.Get(0).(func() compliance.RuleUsageList); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(compliance.RuleUsageList)
+		}
+	}
+
+	return r0
+}
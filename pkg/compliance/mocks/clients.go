@@ -59,3 +59,19 @@ func (_m *Clients) KubeClient() env.KubeClient {
 
 	return r0
 }
+
+// OsqueryClient provides a mock function with given fields:
+func (_m *Clients) OsqueryClient() env.OsqueryClient {
+	ret := _m.Called()
+
+	var r0 env.OsqueryClient
+	if rf, ok := ret.Get(0).(func() env.OsqueryClient); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(env.OsqueryClient)
+		}
+	}
+
+	return r0
+}
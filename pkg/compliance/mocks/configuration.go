@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	env "github.com/DataDog/datadog-agent/pkg/compliance/checks/env"
 	eval "github.com/DataDog/datadog-agent/pkg/compliance/eval"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -49,6 +50,22 @@ func (_m *Configuration) EvaluateFromCache(e eval.Evaluatable) (interface{}, err
 	return r0, r1
 }
 
+// HostNamespace provides a mock function with given fields:
+func (_m *Configuration) HostNamespace() env.Namespace {
+	ret := _m.Called()
+
+	var r0 env.Namespace
+	if rf, ok := ret.Get(0).(func() env.Namespace); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(env.Namespace)
+		}
+	}
+
+	return r0
+}
+
 // Hostname provides a mock function with given fields:
 func (_m *Configuration) Hostname() string {
 	ret := _m.Called()
@@ -104,3 +121,33 @@ func (_m *Configuration) RelativeToHostRoot(path string) string {
 
 	return r0
 }
+
+// ResolveParameter provides a mock function with given fields: name
+func (_m *Configuration) ResolveParameter(name string) (interface{}, bool, error) {
+	ret := _m.Called(name)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(string) interface{}); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string) error); ok {
+		r2 = rf(name)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
@@ -85,6 +85,22 @@ func (_m *Env) EvaluateFromCache(e eval.Evaluatable) (interface{}, error) {
 	return r0, r1
 }
 
+// HostNamespace provides a mock function with given fields:
+func (_m *Env) HostNamespace() env.Namespace {
+	ret := _m.Called()
+
+	var r0 env.Namespace
+	if rf, ok := ret.Get(0).(func() env.Namespace); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(env.Namespace)
+		}
+	}
+
+	return r0
+}
+
 // Hostname provides a mock function with given fields:
 func (_m *Env) Hostname() string {
 	ret := _m.Called()
@@ -143,6 +159,22 @@ func (_m *Env) NormalizeToHostRoot(path string) string {
 	return r0
 }
 
+// OsqueryClient provides a mock function with given fields:
+func (_m *Env) OsqueryClient() env.OsqueryClient {
+	ret := _m.Called()
+
+	var r0 env.OsqueryClient
+	if rf, ok := ret.Get(0).(func() env.OsqueryClient); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(env.OsqueryClient)
+		}
+	}
+
+	return r0
+}
+
 // RelativeToHostRoot provides a mock function with given fields: path
 func (_m *Env) RelativeToHostRoot(path string) string {
 	ret := _m.Called(path)
@@ -172,3 +204,33 @@ func (_m *Env) Reporter() event.Reporter {
 
 	return r0
 }
+
+// ResolveParameter provides a mock function with given fields: name
+func (_m *Env) ResolveParameter(name string) (interface{}, bool, error) {
+	ret := _m.Called(name)
+
+	var r0 interface{}
+	if rf, ok := ret.Get(0).(func(string) interface{}); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interface{})
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string) error); ok {
+		r2 = rf(name)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
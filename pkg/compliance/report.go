@@ -15,4 +15,6 @@ type Report struct {
 	Data event.Data
 	// Passed defines whether check was successful or not
 	Passed bool
+	// Identity is a stable key of the evaluated resource, which does not change with its attributes
+	Identity string
 }
@@ -32,6 +32,12 @@ const (
 	KindKubernetes = ResourceKind("kubernetes")
 	// KindCustom is used for a Custom check
 	KindCustom = ResourceKind("custom")
+	// KindOsquery is used for an Osquery resource
+	KindOsquery = ResourceKind("osquery")
+	// KindSudoers is used for a Sudoers resource
+	KindSudoers = ResourceKind("sudoers")
+	// KindPAM is used for a PAM resource
+	KindPAM = ResourceKind("pam")
 )
 
 // Resource describes supported resource types observed by a Rule
@@ -44,8 +50,12 @@ type Resource struct {
 	Docker        *DockerResource     `yaml:"docker,omitempty"`
 	KubeApiserver *KubernetesResource `yaml:"kubeApiserver,omitempty"`
 	Custom        *Custom             `yaml:"custom,omitempty"`
+	Osquery       *Osquery            `yaml:"osquery,omitempty"`
+	Sudoers       *Sudoers            `yaml:"sudoers,omitempty"`
+	PAM           *PAM                `yaml:"pam,omitempty"`
 	Condition     string              `yaml:"condition"`
 	Fallback      *Fallback           `yaml:"fallback,omitempty"`
+	Evidence      *Evidence           `yaml:"evidence,omitempty"`
 }
 
 // Kind returns ResourceKind of the resource
@@ -67,6 +77,12 @@ func (r *Resource) Kind() ResourceKind {
 		return KindKubernetes
 	case r.Custom != nil:
 		return KindCustom
+	case r.Osquery != nil:
+		return KindOsquery
+	case r.Sudoers != nil:
+		return KindSudoers
+	case r.PAM != nil:
+		return KindPAM
 	default:
 		return KindInvalid
 	}
@@ -78,16 +94,37 @@ type Fallback struct {
 	Resource  Resource `yaml:"resource"`
 }
 
+const (
+	// DefaultEvidenceSize is the maximum size of an evidence when not specified
+	DefaultEvidenceSize = 1024
+	// MaxEvidenceSize is the maximum size of an evidence
+	MaxEvidenceSize = 4096
+)
+
+// Evidence specifies an excerpt of the evaluated resource (e.g. matching lines of a file or
+// the output of a command) which is attached to reported events.
+type Evidence struct {
+	// Expression is evaluated against the reported instance to produce the evidence
+	Expression string `yaml:"expression"`
+	// Redact lists regular expressions matching sensitive data to remove from the evidence
+	Redact []string `yaml:"redact,omitempty"`
+	// MaxSize is the maximum size of the evidence in bytes, larger evidences get truncated
+	MaxSize int `yaml:"maxSize,omitempty"`
+}
+
 // Fields & functions available for File
 const (
 	FileFieldPath        = "file.path"
 	FileFieldPermissions = "file.permissions"
 	FileFieldUser        = "file.user"
 	FileFieldGroup       = "file.group"
+	FileFieldDevice      = "file.device"
 
 	FileFuncJQ     = "file.jq"
 	FileFuncYAML   = "file.yaml"
 	FileFuncRegexp = "file.regexp"
+	FileFuncLines  = "file.lines"
+	FileFuncSHA256 = "file.sha256"
 )
 
 // File describes a file resource
@@ -257,3 +294,96 @@ type Custom struct {
 	Name      string            `yaml:"name"`
 	Variables map[string]string `yaml:"variables,omitempty"`
 }
+
+// Fields available for Osquery
+const (
+	OsqueryFieldName = "osquery.name"
+
+	// OsqueryFieldPrefix is the prefix of the fields holding the columns of a row
+	OsqueryFieldPrefix = "osquery."
+)
+
+// Osquery describes the rows returned by an osquery SQL query. The columns of each row are
+// available as "osquery.<column>" fields, values looking like integers being converted.
+type Osquery struct {
+	// Name identifies the query in reports
+	Name string `yaml:"name"`
+	// Query is the SQL query run by osqueryd
+	Query string `yaml:"query"`
+	// Report lists the columns reported with the results
+	Report []string `yaml:"report,omitempty"`
+}
+
+// Validate validates osquery resource
+func (o *Osquery) Validate() error {
+	if len(o.Name) == 0 {
+		return errors.New("osquery resource is missing name")
+	}
+	if len(o.Query) == 0 {
+		return errors.New("osquery resource is missing query")
+	}
+	return nil
+}
+
+// Fields available for Sudoers
+const (
+	SudoersFieldFile     = "sudoers.file"
+	SudoersFieldType     = "sudoers.type"
+	SudoersFieldUsers    = "sudoers.users"
+	SudoersFieldHosts    = "sudoers.hosts"
+	SudoersFieldRunAs    = "sudoers.runAs"
+	SudoersFieldCommands = "sudoers.commands"
+	SudoersFieldTags     = "sudoers.tags"
+	SudoersFieldNoPasswd = "sudoers.noPasswd"
+	SudoersFieldOption   = "sudoers.option"
+	SudoersFieldValue    = "sudoers.value"
+)
+
+// Types of the sudoers entries
+const (
+	SudoersTypeDefaults = "defaults"
+	SudoersTypeUser     = "user"
+)
+
+// DefaultSudoersPath is the path of the main sudoers file
+const DefaultSudoersPath = "/etc/sudoers"
+
+// Sudoers describes the entries of the sudoers policy, including the files it includes.
+// Each "Defaults" option is an entry of type "defaults", with its option and value fields
+// set. Each user specification is an entry of type "user", with its users, hosts, runAs,
+// commands and tags fields set.
+type Sudoers struct {
+	// Path is the path of the main sudoers file, DefaultSudoersPath when not set
+	Path string `yaml:"path,omitempty"`
+}
+
+// Fields available for PAM
+const (
+	PAMFieldService   = "pam.service"
+	PAMFieldFile      = "pam.file"
+	PAMFieldType      = "pam.type"
+	PAMFieldControl   = "pam.control"
+	PAMFieldModule    = "pam.module"
+	PAMFieldArguments = "pam.arguments"
+)
+
+// DefaultPAMDir is the directory holding the PAM configuration of services
+const DefaultPAMDir = "/etc/pam.d"
+
+// PAM describes the rules of the PAM configuration of a service, including the rules of the
+// configurations it includes. Each rule is an entry with its type, control, module and
+// arguments fields set.
+type PAM struct {
+	// Service is the name of the service, as found in the PAM configuration directory
+	Service string `yaml:"service"`
+	// Dir is the PAM configuration directory, DefaultPAMDir when not set
+	Dir string `yaml:"dir,omitempty"`
+}
+
+// Validate validates PAM resource
+func (p *PAM) Validate() error {
+	if len(p.Service) == 0 {
+		return errors.New("pam resource is missing service")
+	}
+	return nil
+}
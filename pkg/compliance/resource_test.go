@@ -43,12 +43,44 @@ command:
 condition: command.exitCode == 0
 `
 
+const testResourceCommandWithEvidence = `
+command:
+  shell:
+    run: sshd -T
+condition: command.exitCode == 0
+evidence:
+  expression: command.stdout
+  redact:
+    - "(?m)^hostkey .*$"
+  maxSize: 512
+`
+
 const testResourceAudit = `
 audit:
   path: /usr/bin/dockerd
 condition: audit.enabled
 `
 
+const testResourceOsquery = `
+osquery:
+  name: sshd-root-login
+  query: SELECT key, value FROM ssh_configs WHERE key = 'permitrootlogin'
+  report:
+    - value
+condition: osquery.value == "no"
+`
+
+const testResourceSudoers = `
+sudoers: {}
+condition: none("NOPASSWD" in sudoers.tags)
+`
+
+const testResourcePAM = `
+pam:
+  service: common-password
+condition: count(pam.module == "pam_pwquality.so") > 0
+`
+
 const testResourceGroup = `
 group:
   name: docker
@@ -119,6 +151,23 @@ func TestResources(t *testing.T) {
 				Condition: `command.exitCode == 0`,
 			},
 		},
+		{
+			name:  "command with evidence",
+			input: testResourceCommandWithEvidence,
+			expected: Resource{
+				Command: &Command{
+					ShellCmd: &ShellCmd{
+						Run: `sshd -T`,
+					},
+				},
+				Condition: `command.exitCode == 0`,
+				Evidence: &Evidence{
+					Expression: `command.stdout`,
+					Redact:     []string{`(?m)^hostkey .*$`},
+					MaxSize:    512,
+				},
+			},
+		},
 		{
 			name:  "audit",
 			input: testResourceAudit,
@@ -129,6 +178,36 @@ func TestResources(t *testing.T) {
 				Condition: `audit.enabled`,
 			},
 		},
+		{
+			name:  "osquery",
+			input: testResourceOsquery,
+			expected: Resource{
+				Osquery: &Osquery{
+					Name:   "sshd-root-login",
+					Query:  "SELECT key, value FROM ssh_configs WHERE key = 'permitrootlogin'",
+					Report: []string{"value"},
+				},
+				Condition: `osquery.value == "no"`,
+			},
+		},
+		{
+			name:  "sudoers",
+			input: testResourceSudoers,
+			expected: Resource{
+				Sudoers:   &Sudoers{},
+				Condition: `none("NOPASSWD" in sudoers.tags)`,
+			},
+		},
+		{
+			name:  "pam",
+			input: testResourcePAM,
+			expected: Resource{
+				PAM: &PAM{
+					Service: "common-password",
+				},
+				Condition: `count(pam.module == "pam_pwquality.so") > 0`,
+			},
+		},
 		{
 			name:  "group",
 			input: testResourceGroup,
@@ -15,6 +15,30 @@ type Rule struct {
 	Scope        RuleScopeList `yaml:"scope,omitempty"`
 	HostSelector string        `yaml:"hostSelector,omitempty"`
 	Resources    []Resource    `yaml:"resources,omitempty"`
+	Parameters   []Parameter   `yaml:"parameters,omitempty"`
+	Sampling     *Sampling     `yaml:"sampling,omitempty"`
+	// Section is the ID of the benchmark section the rule checks, when it can't be derived
+	// from the rule ID
+	Section string `yaml:"section,omitempty"`
+}
+
+// Sampling configures a rule to only be evaluated on a fraction of the hosts at each check interval.
+// Hosts take turns deterministically, based on a hash of their hostname, so that the whole fleet is
+// covered every 1/Rate intervals while expensive checks don't run on every host at once.
+type Sampling struct {
+	Rate float64 `yaml:"rate"`
+}
+
+// ParameterVarPrefix is the prefix of the variables holding the values of rule parameters
+// in expressions (e.g. "param.approved_registries")
+const ParameterVarPrefix = "param."
+
+// Parameter declares a parameter used by the conditions of a rule. Parameter values are resolved
+// from the environment (local configuration or constants service) every time the rule is evaluated,
+// so that the same rule can be used across environments with different expectations.
+type Parameter struct {
+	Name    string      `yaml:"name"`
+	Default interface{} `yaml:"default,omitempty"`
 }
 
 // RuleScope defines scope for applicability of a rule
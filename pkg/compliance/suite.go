@@ -9,6 +9,7 @@ import (
 	"errors"
 	"io/ioutil"
 
+	"github.com/DataDog/datadog-agent/pkg/util/log"
 	"github.com/Masterminds/semver"
 	"gopkg.in/yaml.v2"
 )
@@ -37,9 +38,15 @@ type SuiteMeta struct {
 type Suite struct {
 	Meta  SuiteMeta `yaml:",inline"`
 	Rules []Rule    `yaml:"rules,omitempty"`
+	// Benchmark lists the sections of the benchmark the suite implements, used to report
+	// its coverage
+	Benchmark []BenchmarkSection `yaml:"benchmark,omitempty"`
+	// ExpectedState declares the state of the host, checked by rules compiled from it
+	ExpectedState *ExpectedState `yaml:"expectedState,omitempty"`
 }
 
-// ParseSuite loads a single compliance suite
+// ParseSuite loads a single compliance suite. Suites which do not follow the schema are
+// rejected with a ValidationError.
 func ParseSuite(config string) (*Suite, error) {
 	c, err := semver.NewConstraint(versionConstraint)
 	if err != nil {
@@ -64,5 +71,16 @@ func ParseSuite(config string) (*Suite, error) {
 	if !c.Check(v) {
 		return nil, ErrUnsupportedSchemaVersion
 	}
+
+	issues := ValidateSuite(f)
+	if HasErrors(issues) {
+		return nil, &ValidationError{Source: config, Issues: issues}
+	}
+	for _, issue := range issues {
+		log.Warnf("%s: %s", config, issue)
+	}
+	if s.ExpectedState != nil {
+		s.Rules = append(s.Rules, s.ExpectedState.Rules()...)
+	}
 	return s, nil
 }
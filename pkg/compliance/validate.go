@@ -0,0 +1,354 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package compliance
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ValidationIssue describes a problem found in a compliance suite. Errors prevent the suite
+// from being loaded, warnings are only logged.
+type ValidationIssue struct {
+	// Line is the line of the suite file where the issue was found, 0 if unknown
+	Line    int
+	Message string
+	Warning bool
+}
+
+func (i ValidationIssue) String() string {
+	severity := "error"
+	if i.Warning {
+		severity = "warning"
+	}
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", severity, i.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", i.Line, severity, i.Message)
+}
+
+// ValidationError is returned when loading a compliance suite which does not follow the schema
+type ValidationError struct {
+	Source string
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	issues := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		issues[i] = issue.String()
+	}
+	return fmt.Sprintf("invalid compliance suite %s:\n  %s", e.Source, strings.Join(issues, "\n  "))
+}
+
+// HasErrors returns true if the list includes at least one issue which is not a warning
+func HasErrors(issues []ValidationIssue) bool {
+	for _, issue := range issues {
+		if !issue.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSuiteFile validates a compliance suite file against the suite schema
+func ValidateSuiteFile(path string) ([]ValidationIssue, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateSuite(data), nil
+}
+
+var (
+	// yamlErrorLine matches the line prefix of the errors returned by the YAML decoder
+	yamlErrorLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+	// yamlUnknownField matches the errors returned by the YAML decoder for unknown keys
+	yamlUnknownField = regexp.MustCompile(`^field (\S+) not found in type compliance\.(\w+)$`)
+	// ruleIDLine matches the lines declaring a rule ID
+	ruleIDLine = regexp.MustCompile(`^\s*-?\s*id:\s*["']?([^"'#]*?)["']?\s*(#.*)?$`)
+)
+
+// schemaTypeNames are the names of the suite sections, as shown in validation messages
+var schemaTypeNames = map[string]string{
+	"Suite":                "suite",
+	"SuiteSchema":          "schema",
+	"Rule":                 "rule",
+	"Parameter":            "parameter",
+	"Sampling":             "sampling",
+	"BenchmarkSection":     "benchmark section",
+	"Resource":             "resource",
+	"Fallback":             "fallback",
+	"Evidence":             "evidence",
+	"File":                 "file resource",
+	"Process":              "process resource",
+	"Group":                "group resource",
+	"Command":              "command resource",
+	"BinaryCmd":            "binary command",
+	"ShellCmd":             "shell command",
+	"Audit":                "audit resource",
+	"DockerResource":       "docker resource",
+	"KubernetesResource":   "kubeApiserver resource",
+	"KubernetesAPIRequest": "apiRequest",
+	"Custom":               "custom resource",
+	"Osquery":              "osquery resource",
+	"Sudoers":              "sudoers resource",
+	"PAM":                  "pam resource",
+	"ExpectedState":        "expected state",
+	"ExpectedFile":         "expected file",
+	"ExpectedPackage":      "expected package",
+	"ExpectedSysctl":       "expected sysctl",
+}
+
+// ValidateSuite validates the content of a compliance suite file against the suite schema.
+// It reports unknown keys, values of the wrong type and missing fields, along with the line
+// they were found at when known.
+func ValidateSuite(data []byte) []ValidationIssue {
+	var issues []ValidationIssue
+
+	s := &Suite{}
+	if err := yaml.UnmarshalStrict(data, s); err != nil {
+		typeErr, ok := err.(*yaml.TypeError)
+		if !ok {
+			// syntax errors stop the decoding
+			return []ValidationIssue{yamlIssue(err.Error())}
+		}
+		for _, msg := range typeErr.Errors {
+			issues = append(issues, yamlIssue(msg))
+		}
+	}
+
+	v := &suiteValidator{
+		ruleLines: ruleLines(data, s.Rules),
+		issues:    issues,
+	}
+	v.validate(s)
+	return v.issues
+}
+
+// yamlIssue converts an error message of the YAML decoder to a validation issue
+func yamlIssue(msg string) ValidationIssue {
+	msg = strings.TrimPrefix(msg, "yaml: ")
+	issue := ValidationIssue{Message: msg}
+	if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+		issue.Line, _ = strconv.Atoi(m[1])
+		issue.Message = m[2]
+	}
+	if m := yamlUnknownField.FindStringSubmatch(issue.Message); m != nil {
+		name, ok := schemaTypeNames[m[2]]
+		if !ok {
+			name = m[2]
+		}
+		issue.Message = fmt.Sprintf("unknown key %q in %s", m[1], name)
+	}
+	return issue
+}
+
+// ruleLines returns the line of each rule, found from the line its ID is declared on
+func ruleLines(data []byte, rules []Rule) []int {
+	lines := make([]int, len(rules))
+	text := strings.Split(string(data), "\n")
+	next := 0
+	for i, rule := range rules {
+		if rule.ID == "" {
+			continue
+		}
+		for n := next; n < len(text); n++ {
+			if m := ruleIDLine.FindStringSubmatch(text[n]); m != nil && m[1] == rule.ID {
+				lines[i] = n + 1
+				next = n + 1
+				break
+			}
+		}
+	}
+	return lines
+}
+
+type suiteValidator struct {
+	ruleLines []int
+	issues    []ValidationIssue
+}
+
+func (v *suiteValidator) errorf(line int, format string, args ...interface{}) {
+	v.issues = append(v.issues, ValidationIssue{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *suiteValidator) warnf(line int, format string, args ...interface{}) {
+	v.issues = append(v.issues, ValidationIssue{Line: line, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+func (v *suiteValidator) validate(s *Suite) {
+	if s.Meta.Schema.Version == "" {
+		v.errorf(0, "missing schema version")
+	}
+	// name, framework and version are reported with the events of the suite
+	if s.Meta.Name == "" {
+		v.errorf(0, "missing suite name")
+	}
+	if s.Meta.Framework == "" {
+		v.errorf(0, "missing suite framework")
+	}
+	if s.Meta.Version == "" {
+		v.errorf(0, "missing suite version")
+	}
+
+	ids := make(map[string]int)
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		line := v.ruleLines[i]
+		if rule.ID == "" {
+			v.errorf(line, "rule %d is missing an id", i+1)
+			continue
+		}
+		if first, found := ids[rule.ID]; found {
+			v.errorf(line, "duplicate rule id %s, first declared on line %d", rule.ID, first)
+		} else {
+			ids[rule.ID] = line
+		}
+		v.validateRule(rule, line)
+	}
+	if s.ExpectedState != nil {
+		v.validateExpectedState(s.ExpectedState)
+	}
+
+	sections := make(map[string]bool)
+	for i, section := range s.Benchmark {
+		if section.ID == "" {
+			v.errorf(0, "benchmark section %d is missing an id", i+1)
+			continue
+		}
+		if sections[section.ID] {
+			v.errorf(0, "duplicate benchmark section %s", section.ID)
+		}
+		sections[section.ID] = true
+	}
+	if len(sections) == 0 {
+		return
+	}
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		if section := rule.BenchmarkSection(s.Meta.Framework); rule.ID != "" && !sections[section] {
+			v.warnf(v.ruleLines[i], "rule %s checks section %q which is not listed in the benchmark", rule.ID, section)
+		}
+	}
+}
+
+func (v *suiteValidator) validateRule(rule *Rule, line int) {
+	if rule.Description == "" {
+		v.warnf(line, "rule %s is missing a description", rule.ID)
+	}
+
+	if len(rule.Scope) == 0 {
+		v.errorf(line, "rule %s is missing a scope", rule.ID)
+	}
+	for _, scope := range rule.Scope {
+		switch scope {
+		case DockerScope, KubernetesNodeScope, KubernetesClusterScope:
+		default:
+			v.errorf(line, "rule %s has unknown scope %q", rule.ID, scope)
+		}
+	}
+
+	if len(rule.Resources) == 0 {
+		v.warnf(line, "rule %s has no resources and will be skipped", rule.ID)
+	}
+	for i := range rule.Resources {
+		v.validateResource(&rule.Resources[i], fmt.Sprintf("rule %s: resource %d", rule.ID, i+1), line)
+	}
+
+	for i, p := range rule.Parameters {
+		if p.Name == "" {
+			v.errorf(line, "rule %s: parameter %d is missing a name", rule.ID, i+1)
+		}
+	}
+
+	if s := rule.Sampling; s != nil && (s.Rate <= 0 || s.Rate > 1) {
+		v.errorf(line, "rule %s: sampling rate must be greater than 0 and at most 1, got %v", rule.ID, s.Rate)
+	}
+}
+
+func (v *suiteValidator) validateResource(r *Resource, where string, line int) {
+	kinds := 0
+	for _, set := range []bool{r.File != nil, r.Process != nil, r.Group != nil, r.Command != nil, r.Audit != nil, r.Docker != nil, r.KubeApiserver != nil, r.Custom != nil, r.Osquery != nil, r.Sudoers != nil, r.PAM != nil} {
+		if set {
+			kinds++
+		}
+	}
+	switch {
+	case kinds == 0:
+		v.errorf(line, "%s does not declare a resource kind", where)
+	case kinds > 1:
+		v.errorf(line, "%s declares %d resource kinds, only one is allowed", where, kinds)
+	}
+
+	if r.Condition == "" {
+		v.errorf(line, "%s is missing a condition", where)
+	}
+
+	if err := validateResourceFields(r); err != nil {
+		v.errorf(line, "%s: %v", where, err)
+	}
+
+	if r.Evidence != nil {
+		if r.Evidence.Expression == "" {
+			v.errorf(line, "%s: evidence is missing an expression", where)
+		}
+		if r.Evidence.MaxSize < 0 || r.Evidence.MaxSize > MaxEvidenceSize {
+			v.errorf(line, "%s: evidence maxSize must be between 0 and %d", where, MaxEvidenceSize)
+		}
+		for _, expr := range r.Evidence.Redact {
+			if _, err := regexp.Compile(expr); err != nil {
+				v.errorf(line, "%s: invalid evidence redact expression %q: %v", where, expr, err)
+			}
+		}
+	}
+
+	if r.Fallback != nil {
+		if r.Fallback.Condition == "" {
+			v.errorf(line, "%s: fallback is missing a condition", where)
+		}
+		v.validateResource(&r.Fallback.Resource, where+": fallback resource", line)
+	}
+}
+
+// validateResourceFields checks the fields required by the kind of a resource
+func validateResourceFields(r *Resource) error {
+	switch {
+	case r.File != nil && r.File.Path == "":
+		return errors.New("file resource is missing path")
+	case r.Process != nil && r.Process.Name == "":
+		return errors.New("process resource is missing name")
+	case r.Group != nil && r.Group.Name == "":
+		return errors.New("group resource is missing name")
+	case r.Command != nil && r.Command.BinaryCmd == nil && r.Command.ShellCmd == nil:
+		return errors.New("command resource is missing a binary or shell command")
+	case r.Command != nil && r.Command.BinaryCmd != nil && r.Command.BinaryCmd.Name == "":
+		return errors.New("binary command is missing name")
+	case r.Command != nil && r.Command.ShellCmd != nil && r.Command.ShellCmd.Run == "":
+		return errors.New("shell command is missing run")
+	case r.Audit != nil:
+		return r.Audit.Validate()
+	case r.Docker != nil && r.Docker.Kind == "":
+		return errors.New("docker resource is missing kind")
+	case r.KubeApiserver != nil && r.KubeApiserver.Kind == "":
+		return errors.New("kubeApiserver resource is missing kind")
+	case r.KubeApiserver != nil && r.KubeApiserver.APIRequest.Verb == "":
+		return errors.New("kubeApiserver resource is missing apiRequest verb")
+	case r.Custom != nil && r.Custom.Name == "":
+		return errors.New("custom resource is missing name")
+	case r.Osquery != nil:
+		return r.Osquery.Validate()
+	case r.PAM != nil:
+		return r.PAM.Validate()
+	}
+	return nil
+}
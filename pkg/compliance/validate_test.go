@@ -0,0 +1,251 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package compliance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSuite(t *testing.T) {
+	tests := []struct {
+		name         string
+		suite        string
+		expectIssues []ValidationIssue
+	}{
+		{
+			name: "valid suite",
+			suite: `
+schema:
+  version: 1.0
+name: CIS Docker Generic
+framework: cis-docker
+version: 1.2.0
+rules:
+- id: cis-docker-1
+  description: Ensure daemon.json permissions are set to 644
+  scope:
+    - docker
+  resources:
+    - file:
+        path: /etc/docker/daemon.json
+      condition: file.permissions == 0644
+`,
+		},
+		{
+			name: "missing suite fields",
+			suite: `
+schema:
+  version: 1.0
+name: CIS Docker Generic
+rules: []
+`,
+			expectIssues: []ValidationIssue{
+				{Message: "missing suite framework"},
+				{Message: "missing suite version"},
+			},
+		},
+		{
+			name: "fallback and command",
+			suite: `
+schema:
+  version: 1.0
+name: CIS Docker Generic
+framework: cis-docker
+version: 1.2.0
+rules:
+- id: "cis-docker-1"
+  description: Ensure the Docker daemon runs with live-restore
+  scope:
+    - kubernetesNode
+  resources:
+    - command:
+        shell:
+          run: ""
+      condition: command.exitCode == 0
+      fallback:
+        condition: "true"
+        resource:
+          file:
+            path: /etc/docker/daemon.json
+`,
+			expectIssues: []ValidationIssue{
+				{Line: 8, Message: "rule cis-docker-1: resource 1: shell command is missing run"},
+				{Line: 8, Message: "rule cis-docker-1: resource 1: fallback resource is missing a condition"},
+			},
+		},
+		{
+			name: "sudoers and pam",
+			suite: `
+schema:
+  version: 1.0
+name: CIS Ubuntu
+framework: cis-ubuntu
+version: 1.0.0
+rules:
+- id: cis-ubuntu-5.2.2
+  description: Ensure sudo commands use pty
+  scope:
+    - docker
+  resources:
+    - sudoers: {}
+      condition: count(sudoers.option == "use_pty") > 0
+    - pam:
+        dir: /etc/pam.d
+      condition: count(pam.module == "pam_pwquality.so") > 0
+`,
+			expectIssues: []ValidationIssue{
+				{Line: 8, Message: "rule cis-ubuntu-5.2.2: resource 2: pam resource is missing service"},
+			},
+		},
+		{
+			name: "sampling",
+			suite: `
+schema:
+  version: 1.0
+name: CIS Docker Generic
+framework: cis-docker
+version: 1.2.0
+rules:
+- id: cis-docker-1
+  description: Ensure images are scanned
+  scope:
+    - docker
+  sampling:
+    rate: 0.1
+  resources:
+    - file:
+        path: /etc/docker/daemon.json
+      condition: file.permissions == 0644
+- id: cis-docker-2
+  description: Ensure images are scanned
+  scope:
+    - docker
+  sampling:
+    rate: 10
+    hosts: 3
+  resources:
+    - file:
+        path: /etc/docker/daemon.json
+      condition: file.permissions == 0644
+`,
+			expectIssues: []ValidationIssue{
+				{Line: 24, Message: `unknown key "hosts" in sampling`},
+				{Line: 18, Message: "rule cis-docker-2: sampling rate must be greater than 0 and at most 1, got 10"},
+			},
+		},
+		{
+			name: "benchmark",
+			suite: `
+schema:
+  version: 1.0
+name: CIS Docker Generic
+framework: cis-docker
+version: 1.2.0
+benchmark:
+- id: "1.1"
+  title: Ensure the container host has been hardened
+- id: "1.1"
+- title: Ensure the version of Docker is up to date
+rules:
+- id: cis-docker-1.1
+  description: Ensure the container host has been hardened
+  scope:
+    - docker
+  resources:
+    - file:
+        path: /etc/docker/daemon.json
+      condition: file.permissions == 0644
+- id: cis-docker-3
+  description: Ensure daemon.json permissions are set to 644
+  scope:
+    - docker
+  resources:
+    - file:
+        path: /etc/docker/daemon.json
+      condition: file.permissions == 0644
+`,
+			expectIssues: []ValidationIssue{
+				{Message: "duplicate benchmark section 1.1"},
+				{Message: "benchmark section 3 is missing an id"},
+				{Line: 21, Message: `rule cis-docker-3 checks section "3" which is not listed in the benchmark`, Warning: true},
+			},
+		},
+		{
+			name: "expected state",
+			suite: `
+schema:
+  version: 1.0
+name: Golden Image
+framework: golden-image
+version: 1.0.0
+expectedState:
+  scope:
+    - docker
+  files:
+    - path: /etc/docker/daemon.json
+      sha256: d08190ad49cab6425453cea9b142737a8e376d6c34d2086f120d398136940ae9
+    - path: etc/hosts
+      sha256: abc
+  packages:
+    - name: docker-ce
+      version: 5:19.03.13~3-0~ubuntu-focal
+    - name: "docker; rm -rf /"
+      version: "1.0"
+  sysctls:
+    - name: net.ipv4.ip_forward
+      value: "1"
+    - name: net.ipv4.tcp_rmem
+`,
+			expectIssues: []ValidationIssue{
+				{Message: `expected state: file 2 must have an absolute path, got "etc/hosts"`},
+				{Message: "expected state: file etc/hosts must have a hex-encoded SHA-256 hash"},
+				{Message: `expected state: package 2 has invalid name "docker; rm -rf /"`},
+				{Message: "expected state: sysctl net.ipv4.tcp_rmem is missing a value"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expectIssues, ValidateSuite([]byte(test.suite)))
+		})
+	}
+
+	t.Run("syntax error", func(t *testing.T) {
+		issues := ValidateSuite([]byte("name: [CIS Docker Generic\n"))
+		assert.Len(t, issues, 1)
+		assert.True(t, HasErrors(issues))
+	})
+}
+
+func TestValidateSuiteFile(t *testing.T) {
+	assert := assert.New(t)
+
+	issues, err := ValidateSuiteFile("./testdata/cis-docker-invalid.yaml")
+	assert.NoError(err)
+	assert.Equal([]ValidationIssue{
+		{Line: 14, Message: `unknown key "owner" in file resource`},
+		{Line: 23, Message: "cannot unmarshal !!str `large` into int"},
+		{Line: 16, Message: "rule cis-docker-2 is missing a description", Warning: true},
+		{Line: 16, Message: "rule cis-docker-2: resource 1 is missing a condition"},
+		{Line: 16, Message: "rule cis-docker-2: resource 1: evidence is missing an expression"},
+		{Line: 24, Message: "duplicate rule id cis-docker-2, first declared on line 16"},
+		{Line: 24, Message: "rule cis-docker-2 has unknown scope \"kubernetes\""},
+	}, issues)
+	assert.True(HasErrors(issues))
+
+	_, err = ParseSuite("./testdata/cis-docker-invalid.yaml")
+	assert.IsType(&ValidationError{}, err)
+	assert.Contains(err.Error(), `line 14: error: unknown key "owner" in file resource`)
+
+	issues, err = ValidateSuiteFile("./testdata/cis-docker.yaml")
+	assert.NoError(err)
+	assert.Equal([]ValidationIssue{
+		{Line: 7, Message: "rule cis-docker-1 is missing a description", Warning: true},
+	}, issues)
+	assert.False(HasErrors(issues))
+}
@@ -20,11 +20,16 @@ func setupAPM(config Config) {
 	config.SetKnown("apm_config.obfuscation.http.remove_paths_with_digits")
 	config.SetKnown("apm_config.obfuscation.remove_stack_traces")
 	config.SetKnown("apm_config.obfuscation.redis.enabled")
+	config.SetKnown("apm_config.obfuscation.redis.commands")
 	config.SetKnown("apm_config.obfuscation.memcached.enabled")
+	config.SetKnown("apm_config.obfuscation.pii.kinds")
+	config.SetKnown("apm_config.obfuscation.pii.keep_tags")
+	config.SetKnown("apm_config.obfuscation.scrub_rules")
 	config.SetKnown("apm_config.extra_sample_rate")
 	config.SetKnown("apm_config.dd_agent_bin")
 	config.SetKnown("apm_config.trace_writer.connection_limit")
 	config.SetKnown("apm_config.trace_writer.queue_size")
+	config.SetKnown("apm_config.trace_writer.heartbeat_interval_seconds")
 	config.SetKnown("apm_config.service_writer.connection_limit")
 	config.SetKnown("apm_config.service_writer.queue_size")
 	config.SetKnown("apm_config.stats_writer.connection_limit")
@@ -45,27 +50,94 @@ func setupAPM(config Config) {
 	config.BindEnvAndSetDefault("apm_config.receiver_port", 8126, "DD_APM_RECEIVER_PORT", "DD_RECEIVER_PORT")
 	config.BindEnvAndSetDefault("apm_config.windows_pipe_buffer_size", 1_000_000, "DD_APM_WINDOWS_PIPE_BUFFER_SIZE")                          //nolint:errcheck
 	config.BindEnvAndSetDefault("apm_config.windows_pipe_security_descriptor", "D:AI(A;;GA;;;WD)", "DD_APM_WINDOWS_PIPE_SECURITY_DESCRIPTOR") //nolint:errcheck
+	config.BindEnvAndSetDefault("apm_config.windows_pipe_max_connections", 0, "DD_APM_WINDOWS_PIPE_MAX_CONNECTIONS")                          //nolint:errcheck
 
-	config.BindEnv("apm_config.receiver_timeout", "DD_APM_RECEIVER_TIMEOUT")                             //nolint:errcheck
-	config.BindEnv("apm_config.max_payload_size", "DD_APM_MAX_PAYLOAD_SIZE")                             //nolint:errcheck
-	config.BindEnv("apm_config.log_file", "DD_APM_LOG_FILE")                                             //nolint:errcheck
-	config.BindEnv("apm_config.max_events_per_second", "DD_APM_MAX_EPS", "DD_MAX_EPS")                   //nolint:errcheck
-	config.BindEnv("apm_config.max_traces_per_second", "DD_APM_MAX_TPS", "DD_MAX_TPS")                   //nolint:errcheck
-	config.BindEnv("apm_config.max_memory", "DD_APM_MAX_MEMORY")                                         //nolint:errcheck
-	config.BindEnv("apm_config.max_cpu_percent", "DD_APM_MAX_CPU_PERCENT")                               //nolint:errcheck
-	config.BindEnv("apm_config.env", "DD_APM_ENV")                                                       //nolint:errcheck
-	config.BindEnv("apm_config.apm_non_local_traffic", "DD_APM_NON_LOCAL_TRAFFIC")                       //nolint:errcheck
-	config.BindEnv("apm_config.apm_dd_url", "DD_APM_DD_URL")                                             //nolint:errcheck
-	config.BindEnv("apm_config.connection_limit", "DD_APM_CONNECTION_LIMIT", "DD_CONNECTION_LIMIT")      //nolint:errcheck
-	config.BindEnv("apm_config.connection_reset_interval", "DD_APM_CONNECTION_RESET_INTERVAL")           //nolint:errcheck
-	config.BindEnv("apm_config.profiling_dd_url", "DD_APM_PROFILING_DD_URL")                             //nolint:errcheck
-	config.BindEnv("apm_config.profiling_additional_endpoints", "DD_APM_PROFILING_ADDITIONAL_ENDPOINTS") //nolint:errcheck
-	config.BindEnv("apm_config.additional_endpoints", "DD_APM_ADDITIONAL_ENDPOINTS")                     //nolint:errcheck
-	config.BindEnv("apm_config.replace_tags", "DD_APM_REPLACE_TAGS")                                     //nolint:errcheck
-	config.BindEnv("apm_config.analyzed_spans", "DD_APM_ANALYZED_SPANS")                                 //nolint:errcheck
-	config.BindEnv("apm_config.ignore_resources", "DD_APM_IGNORE_RESOURCES", "DD_IGNORE_RESOURCE")       //nolint:errcheck
-	config.BindEnv("apm_config.receiver_socket", "DD_APM_RECEIVER_SOCKET")                               //nolint:errcheck
-	config.BindEnv("apm_config.windows_pipe_name", "DD_APM_WINDOWS_PIPE_NAME")                           //nolint:errcheck
+	config.BindEnv("apm_config.receiver_timeout", "DD_APM_RECEIVER_TIMEOUT")                                           //nolint:errcheck
+	config.BindEnv("apm_config.max_payload_size", "DD_APM_MAX_PAYLOAD_SIZE")                                           //nolint:errcheck
+	config.BindEnv("apm_config.log_file", "DD_APM_LOG_FILE")                                                           //nolint:errcheck
+	config.BindEnv("apm_config.max_events_per_second", "DD_APM_MAX_EPS", "DD_MAX_EPS")                                 //nolint:errcheck
+	config.BindEnv("apm_config.max_traces_per_second", "DD_APM_MAX_TPS", "DD_MAX_TPS")                                 //nolint:errcheck
+	config.BindEnv("apm_config.max_error_traces_per_second_per_service", "DD_APM_MAX_ERROR_TPS_PER_SERVICE")           //nolint:errcheck
+	config.BindEnv("apm_config.target_traces_per_second", "DD_APM_TARGET_TPS")                                         //nolint:errcheck
+	config.BindEnv("apm_config.priority_sampling_by_resource", "DD_APM_PRIORITY_SAMPLING_BY_RESOURCE")                 //nolint:errcheck
+	config.BindEnv("apm_config.max_memory", "DD_APM_MAX_MEMORY")                                                       //nolint:errcheck
+	config.BindEnv("apm_config.max_cpu_percent", "DD_APM_MAX_CPU_PERCENT")                                             //nolint:errcheck
+	config.BindEnv("apm_config.env", "DD_APM_ENV")                                                                     //nolint:errcheck
+	config.BindEnv("apm_config.apm_non_local_traffic", "DD_APM_NON_LOCAL_TRAFFIC")                                     //nolint:errcheck
+	config.BindEnv("apm_config.apm_dd_url", "DD_APM_DD_URL")                                                           //nolint:errcheck
+	config.BindEnv("apm_config.connection_limit", "DD_APM_CONNECTION_LIMIT", "DD_CONNECTION_LIMIT")                    //nolint:errcheck
+	config.BindEnv("apm_config.connection_reset_interval", "DD_APM_CONNECTION_RESET_INTERVAL")                         //nolint:errcheck
+	config.BindEnv("apm_config.otlp_pipelines.metrics", "DD_APM_OTLP_PIPELINES_METRICS")                               //nolint:errcheck
+	config.BindEnv("apm_config.otlp_pipelines.logs_port", "DD_APM_OTLP_PIPELINES_LOGS_PORT")                           //nolint:errcheck
+	config.BindEnv("apm_config.trace_reassembly.window_ms", "DD_APM_TRACE_REASSEMBLY_WINDOW_MS")                       //nolint:errcheck
+	config.BindEnv("apm_config.trace_reassembly.max_spans", "DD_APM_TRACE_REASSEMBLY_MAX_SPANS")                       //nolint:errcheck
+	config.BindEnv("apm_config.profiling_dd_url", "DD_APM_PROFILING_DD_URL")                                           //nolint:errcheck
+	config.BindEnv("apm_config.profiling_additional_endpoints", "DD_APM_PROFILING_ADDITIONAL_ENDPOINTS")               //nolint:errcheck
+	config.BindEnv("apm_config.profiling_proxy", "DD_APM_PROFILING_PROXY")                                             //nolint:errcheck
+	config.BindEnv("apm_config.debugger_dd_url", "DD_APM_DEBUGGER_DD_URL")                                             //nolint:errcheck
+	config.BindEnv("apm_config.debugger_api_key", "DD_APM_DEBUGGER_API_KEY")                                           //nolint:errcheck
+	config.BindEnv("apm_config.debugger_queue_size", "DD_APM_DEBUGGER_QUEUE_SIZE")                                     //nolint:errcheck
+	config.BindEnv("apm_config.debugger_max_payload_size", "DD_APM_DEBUGGER_MAX_PAYLOAD_SIZE")                         //nolint:errcheck
+	config.BindEnv("apm_config.additional_endpoints", "DD_APM_ADDITIONAL_ENDPOINTS")                                   //nolint:errcheck
+	config.BindEnv("apm_config.api_key_aliases", "DD_APM_API_KEY_ALIASES")                                             //nolint:errcheck
+	config.BindEnv("apm_config.accept_tracer_api_keys", "DD_APM_ACCEPT_TRACER_API_KEYS")                               //nolint:errcheck
+	config.BindEnv("apm_config.replace_tags", "DD_APM_REPLACE_TAGS")                                                   //nolint:errcheck
+	config.BindEnv("apm_config.sampling_rules", "DD_APM_SAMPLING_RULES")                                               //nolint:errcheck
+	config.BindEnv("apm_config.span_sampling_rules", "DD_APM_SPAN_SAMPLING_RULES")                                     //nolint:errcheck
+	config.BindEnv("apm_config.analyzed_spans", "DD_APM_ANALYZED_SPANS")                                               //nolint:errcheck
+	config.BindEnv("apm_config.ignore_resources", "DD_APM_IGNORE_RESOURCES", "DD_IGNORE_RESOURCE")                     //nolint:errcheck
+	config.BindEnv("apm_config.receiver_socket", "DD_APM_RECEIVER_SOCKET")                                             //nolint:errcheck
+	config.BindEnv("apm_config.windows_pipe_name", "DD_APM_WINDOWS_PIPE_NAME")                                         //nolint:errcheck
+	config.BindEnv("apm_config.receiver_auth_token", "DD_APM_RECEIVER_AUTH_TOKEN")                                     //nolint:errcheck
+	config.BindEnv("apm_config.address_family", "DD_APM_ADDRESS_FAMILY")                                               //nolint:errcheck
+	config.BindEnv("apm_config.receiver_reuse_port", "DD_APM_RECEIVER_REUSE_PORT")                                     //nolint:errcheck
+	config.BindEnv("apm_config.receiver_handoff", "DD_APM_RECEIVER_HANDOFF")                                           //nolint:errcheck
+	config.BindEnv("apm_config.receiver_hardened", "DD_APM_RECEIVER_HARDENED")                                         //nolint:errcheck
+	config.BindEnv("apm_config.receiver_max_header_bytes", "DD_APM_RECEIVER_MAX_HEADER_BYTES")                         //nolint:errcheck
+	config.BindEnv("apm_config.receiver_max_connections", "DD_APM_RECEIVER_MAX_CONNECTIONS")                           //nolint:errcheck
+	config.BindEnv("apm_config.receiver_read_timeout", "DD_APM_RECEIVER_READ_TIMEOUT")                                 //nolint:errcheck
+	config.BindEnv("apm_config.receiver_write_timeout", "DD_APM_RECEIVER_WRITE_TIMEOUT")                               //nolint:errcheck
+	config.BindEnv("apm_config.receiver_idle_timeout", "DD_APM_RECEIVER_IDLE_TIMEOUT")                                 //nolint:errcheck
+	config.BindEnv("apm_config.receiver_keep_alives", "DD_APM_RECEIVER_KEEP_ALIVES")                                   //nolint:errcheck
+	config.BindEnv("apm_config.receiver_tls.cert_file", "DD_APM_RECEIVER_TLS_CERT_FILE")                               //nolint:errcheck
+	config.BindEnv("apm_config.receiver_tls.key_file", "DD_APM_RECEIVER_TLS_KEY_FILE")                                 //nolint:errcheck
+	config.BindEnv("apm_config.receiver_tls.client_ca_file", "DD_APM_RECEIVER_TLS_CLIENT_CA_FILE")                     //nolint:errcheck
+	config.BindEnv("apm_config.access_log.path", "DD_APM_ACCESS_LOG_PATH")                                             //nolint:errcheck
+	config.BindEnv("apm_config.access_log.sample_rate", "DD_APM_ACCESS_LOG_SAMPLE_RATE")                               //nolint:errcheck
+	config.BindEnv("apm_config.access_log.max_size", "DD_APM_ACCESS_LOG_MAX_SIZE")                                     //nolint:errcheck
+	config.BindEnv("apm_config.inject_container_runtime_id", "DD_APM_INJECT_CONTAINER_RUNTIME_ID")                     //nolint:errcheck
+	config.BindEnv("apm_config.error_fingerprinting", "DD_APM_ERROR_FINGERPRINTING")                                   //nolint:errcheck
+	config.BindEnv("apm_config.client_computed_stats", "DD_APM_CLIENT_COMPUTED_STATS")                                 //nolint:errcheck
+	config.BindEnv("apm_config.language_quirks", "DD_APM_LANGUAGE_QUIRKS")                                             //nolint:errcheck
+	config.BindEnv("apm_config.tracer_config_file", "DD_APM_TRACER_CONFIG_FILE")                                       //nolint:errcheck
+	config.BindEnv("apm_config.xray_udp_port", "DD_APM_XRAY_UDP_PORT")                                                 //nolint:errcheck
+	config.BindEnv("apm_config.jaeger_udp_port", "DD_APM_JAEGER_UDP_PORT")                                             //nolint:errcheck
+	config.BindEnv("apm_config.receiver_grpc_port", "DD_APM_RECEIVER_GRPC_PORT")                                       //nolint:errcheck
+	config.BindEnv("apm_config.fine_stats.services", "DD_APM_FINE_STATS_SERVICES")                                     //nolint:errcheck
+	config.BindEnv("apm_config.fine_stats.bucket_size_ms", "DD_APM_FINE_STATS_BUCKET_SIZE_MS")                         //nolint:errcheck
+	config.BindEnv("apm_config.fine_stats.max_grains", "DD_APM_FINE_STATS_MAX_GRAINS")                                 //nolint:errcheck
+	config.BindEnv("apm_config.stats_by_version", "DD_APM_STATS_BY_VERSION")                                           //nolint:errcheck
+	config.BindEnv("apm_config.stats_max_versions_per_service", "DD_APM_STATS_MAX_VERSIONS_PER_SERVICE")               //nolint:errcheck
+	config.BindEnv("apm_config.sampling_mode", "DD_APM_SAMPLING_MODE")                                                 //nolint:errcheck
+	config.BindEnv("apm_config.trace_id_hash_sample_rate", "DD_APM_TRACE_ID_HASH_SAMPLE_RATE")                         //nolint:errcheck
+	config.BindEnv("apm_config.exception_sampler.enabled", "DD_APM_EXCEPTION_SAMPLER_ENABLED")                         //nolint:errcheck
+	config.BindEnv("apm_config.exception_sampler.ttl", "DD_APM_EXCEPTION_SAMPLER_TTL")                                 //nolint:errcheck
+	config.BindEnv("apm_config.exception_sampler.priority_ttl", "DD_APM_EXCEPTION_SAMPLER_PRIORITY_TTL")               //nolint:errcheck
+	config.BindEnv("apm_config.exception_sampler.cardinality_limit", "DD_APM_EXCEPTION_SAMPLER_CARDINALITY_LIMIT")     //nolint:errcheck
+	config.BindEnv("apm_config.exception_sampler.signature", "DD_APM_EXCEPTION_SAMPLER_SIGNATURE")                     //nolint:errcheck
+	config.BindEnv("apm_config.min_tracer_versions", "DD_APM_MIN_TRACER_VERSIONS")                                     //nolint:errcheck
+	config.BindEnv("apm_config.reject_outdated_tracers", "DD_APM_REJECT_OUTDATED_TRACERS")                             //nolint:errcheck
+	config.BindEnv("apm_config.evp_proxy_config.enabled", "DD_APM_EVP_PROXY_CONFIG_ENABLED")                           //nolint:errcheck
+	config.BindEnv("apm_config.evp_proxy_config.dd_url", "DD_APM_EVP_PROXY_CONFIG_DD_URL")                             //nolint:errcheck
+	config.BindEnv("apm_config.evp_proxy_config.additional_endpoints", "DD_APM_EVP_PROXY_CONFIG_ADDITIONAL_ENDPOINTS") //nolint:errcheck
+	config.BindEnv("apm_config.evp_proxy_config.max_payload_size", "DD_APM_EVP_PROXY_CONFIG_MAX_PAYLOAD_SIZE")         //nolint:errcheck
+	config.BindEnv("apm_config.obfuscation.elasticsearch.enabled", "DD_APM_OBFUSCATION_ELASTICSEARCH_ENABLED")         //nolint:errcheck
+	config.BindEnv("apm_config.obfuscation.elasticsearch.keep_values", "DD_APM_OBFUSCATION_ELASTICSEARCH_KEEP_VALUES") //nolint:errcheck
+	config.BindEnv("apm_config.obfuscation.mongodb.enabled", "DD_APM_OBFUSCATION_MONGODB_ENABLED")                     //nolint:errcheck
+	config.BindEnv("apm_config.obfuscation.mongodb.keep_values", "DD_APM_OBFUSCATION_MONGODB_KEEP_VALUES")             //nolint:errcheck
+	config.BindEnv("apm_config.obfuscation.sql.keep_comments", "DD_APM_OBFUSCATION_SQL_KEEP_COMMENTS")                 //nolint:errcheck
+	config.BindEnv("apm_config.obfuscation.sql.table_names", "DD_APM_OBFUSCATION_SQL_TABLE_NAMES")                     //nolint:errcheck
+	config.BindEnv("apm_config.obfuscation.pii.enabled", "DD_APM_OBFUSCATION_PII_ENABLED")                             //nolint:errcheck
 
 	config.SetEnvKeyTransformer("apm_config.ignore_resources", func(in string) interface{} {
 		r, err := splitCSVString(in, ',')
@@ -84,6 +156,30 @@ func setupAPM(config Config) {
 		return out
 	})
 
+	config.SetEnvKeyTransformer("apm_config.sampling_rules", func(in string) interface{} {
+		var out []map[string]interface{}
+		if err := json.Unmarshal([]byte(in), &out); err != nil {
+			log.Warnf(`"apm_config.sampling_rules" can not be parsed: %v`, err)
+		}
+		return out
+	})
+
+	config.SetEnvKeyTransformer("apm_config.span_sampling_rules", func(in string) interface{} {
+		var out []map[string]interface{}
+		if err := json.Unmarshal([]byte(in), &out); err != nil {
+			log.Warnf(`"apm_config.span_sampling_rules" can not be parsed: %v`, err)
+		}
+		return out
+	})
+
+	config.SetEnvKeyTransformer("apm_config.api_key_aliases", func(in string) interface{} {
+		var out map[string]map[string]string
+		if err := json.Unmarshal([]byte(in), &out); err != nil {
+			log.Warnf(`"apm_config.api_key_aliases" can not be parsed: %v`, err)
+		}
+		return out
+	})
+
 	config.SetEnvKeyTransformer("apm_config.analyzed_spans", func(in string) interface{} {
 		out, err := parseAnalyzedSpans(in)
 		if err != nil {
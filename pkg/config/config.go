@@ -53,6 +53,10 @@ const (
 
 	// DefaultRuntimePoliciesDir is the default policies directory used by the runtime security module
 	DefaultRuntimePoliciesDir = "/etc/datadog-agent/runtime-security.d"
+
+	// DefaultExecArgsRedactPattern is the default pattern of the secrets redacted from the arguments and environment
+	// variables captured by the runtime security module
+	DefaultExecArgsRedactPattern = `(?i)(?:pass(?:word|wd)?|secret|token|api[_-]?key)[=:\s]\s*(\S+)`
 )
 
 var overrideVars = make(map[string]interface{})
@@ -747,6 +751,15 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("compliance_config.check_interval", 20*time.Minute)
 	config.BindEnvAndSetDefault("compliance_config.dir", "/etc/datadog-agent/compliance.d")
 	config.BindEnvAndSetDefault("compliance_config.run_path", defaultRunPath)
+	config.BindEnvAndSetDefault("compliance_config.parameters_url", "")
+	config.BindEnvAndSetDefault("compliance_config.parameters_ttl", 10*time.Minute)
+	config.BindEnvAndSetDefault("compliance_config.host_namespace_pid", 0)
+	config.BindEnvAndSetDefault("compliance_config.osquery.socket", "")
+	config.BindEnvAndSetDefault("compliance_config.results_port", 0)
+	config.BindEnvAndSetDefault("compliance_config.batch_events.enabled", false)
+	config.BindEnvAndSetDefault("compliance_config.batch_events.max_events", 100)
+	config.BindEnvAndSetDefault("compliance_config.batch_events.flush_interval", 10*time.Second)
+	config.SetKnown("compliance_config.parameters")
 
 	// Datadog security agent (runtime)
 	config.BindEnvAndSetDefault("runtime_security_config.enabled", false)
@@ -755,13 +768,35 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("runtime_security_config.enable_kernel_filters", true)
 	config.BindEnvAndSetDefault("runtime_security_config.flush_discarder_window", 3)
 	config.BindEnvAndSetDefault("runtime_security_config.syscall_monitor.enabled", false)
+	config.BindEnvAndSetDefault("runtime_security_config.network_flows.enabled", false)
+	config.BindEnvAndSetDefault("runtime_security_config.exec_args.enabled", false)
+	config.BindEnvAndSetDefault("runtime_security_config.exec_args.env_keys", []string{})
+	config.BindEnvAndSetDefault("runtime_security_config.exec_args.redact_patterns", []string{DefaultExecArgsRedactPattern})
+	config.BindEnvAndSetDefault("runtime_security_config.redaction.allow_paths", []string{})
+	config.BindEnvAndSetDefault("runtime_security_config.redaction.deny_paths", []string{})
+	config.BindEnvAndSetDefault("runtime_security_config.redaction.args_patterns", []string{})
+	config.BindEnvAndSetDefault("runtime_security_config.redaction.hash_user_names", false)
+	config.BindEnvAndSetDefault("runtime_security_config.actions.kill.enabled", false)
+	config.BindEnvAndSetDefault("runtime_security_config.actions.activity_dump.output_dir", filepath.Join(defaultRunPath, "activity-dumps"))
+	config.BindEnvAndSetDefault("runtime_security_config.anomaly_detection.enabled", false)
+	config.BindEnvAndSetDefault("runtime_security_config.anomaly_detection.learning_period", 3600)
+	config.BindEnvAndSetDefault("runtime_security_config.anomaly_detection.threshold", 0.9)
+	config.SetKnown("runtime_security_config.anomaly_detection.workload_thresholds")
 	config.BindEnvAndSetDefault("runtime_security_config.run_path", defaultRunPath)
 	config.BindEnvAndSetDefault("runtime_security_config.event_server.burst", 40)
 	config.BindEnvAndSetDefault("runtime_security_config.event_server.rate", 10)
+	config.BindEnvAndSetDefault("runtime_security_config.event_queue.enabled", false)
+	config.BindEnvAndSetDefault("runtime_security_config.event_queue.dir", filepath.Join(defaultRunPath, "runtime-security-queue"))
+	config.BindEnvAndSetDefault("runtime_security_config.event_queue.max_size", 50*1024*1024)
 	config.BindEnvAndSetDefault("runtime_security_config.load_controller.events_count_threshold", 20000)
 	config.BindEnvAndSetDefault("runtime_security_config.load_controller.discarder_timeout", 10)
 	config.BindEnvAndSetDefault("runtime_security_config.load_controller.control_period", 2)
 	config.BindEnvAndSetDefault("runtime_security_config.pid_cache_size", 10000)
+	config.BindEnvAndSetDefault("runtime_security_config.process_cache.reconciliation_period", 300)
+	config.BindEnvAndSetDefault("runtime_security_config.dentry_cache.size", 1024)
+	config.BindEnvAndSetDefault("runtime_security_config.dentry_cache.ttl", 0)
+	config.BindEnvAndSetDefault("runtime_security_config.dentry_cache.negative_size", 1024)
+	config.BindEnvAndSetDefault("runtime_security_config.dentry_cache.negative_ttl", 2)
 
 	// command line options
 	config.SetKnown("cmd.check.fullsketches")
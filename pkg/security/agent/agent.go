@@ -26,7 +26,7 @@ import (
 // RuntimeSecurityAgent represents the main wrapper for the Runtime Security product
 type RuntimeSecurityAgent struct {
 	hostname      string
-	reporter      event.Reporter
+	sink          EventSink
 	conn          *grpc.ClientConn
 	running       atomic.Value
 	wg            sync.WaitGroup
@@ -34,8 +34,8 @@ type RuntimeSecurityAgent struct {
 	eventReceived uint64
 }
 
-// NewRuntimeSecurityAgent instantiates a new RuntimeSecurityAgent
-func NewRuntimeSecurityAgent(hostname string, reporter event.Reporter) (*RuntimeSecurityAgent, error) {
+// NewRuntimeSecurityAgent instantiates a new RuntimeSecurityAgent submitting events to the given sink
+func NewRuntimeSecurityAgent(hostname string, sink EventSink) (*RuntimeSecurityAgent, error) {
 	socketPath := coreconfig.Datadog.GetString("runtime_security_config.socket")
 	if socketPath == "" {
 		return nil, errors.New("runtime_security_config.socket must be set")
@@ -49,7 +49,7 @@ func NewRuntimeSecurityAgent(hostname string, reporter event.Reporter) (*Runtime
 
 	return &RuntimeSecurityAgent{
 		conn:     conn,
-		reporter: reporter,
+		sink:     sink,
 		hostname: hostname,
 	}, nil
 }
@@ -120,7 +120,9 @@ func (rsa *RuntimeSecurityAgent) SendSecurityEvent(evt *api.SecurityEventMessage
 		Data:         json.RawMessage(evt.GetData()),
 	}
 
-	rsa.reporter.Report(event)
+	if err := rsa.sink.Send(event); err != nil {
+		log.Errorf("Failed to send event for rule `%s`: %v", evt.RuleID, err)
+	}
 }
 
 // DispatchEvent dispatches a security event message to the subsytems of the runtime security agent
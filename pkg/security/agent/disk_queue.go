@@ -0,0 +1,378 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// queueSegmentSize is the size above which the queue starts writing to a new segment file
+	queueSegmentSize = 1024 * 1024
+	// queueSegmentExt is the extension of the segment files
+	queueSegmentExt = ".queue"
+	// queueAckFile is the name of the file holding the sequence number of the last acknowledged record
+	queueAckFile = "ack"
+)
+
+// queueRecord is a record of the disk queue
+type queueRecord struct {
+	seq  uint64
+	data []byte
+}
+
+// queueSegment is a file of the disk queue. Each line of the file is a record, made of its
+// sequence number followed by its data.
+type queueSegment struct {
+	// first is the sequence number of the first record of the segment
+	first uint64
+	path  string
+	size  int64
+}
+
+// queueCursor caches the position of the record following the last read one, so that
+// consecutive reads don't rescan the segment from its beginning
+type queueCursor struct {
+	path   string
+	seq    uint64
+	offset int64
+}
+
+// diskQueue is a queue of records persisted on disk, split in segment files. Segments are
+// removed once all their records are acknowledged or when the queue exceeds its maximum size,
+// oldest first.
+type diskQueue struct {
+	sync.Mutex
+	dir      string
+	maxSize  int64
+	segments []*queueSegment
+	size     int64
+	nextSeq  uint64
+	acked    uint64
+	file     *os.File
+	cursor   queueCursor
+	notifyCh chan struct{}
+}
+
+// newDiskQueue opens the disk queue stored in dir, creating it if needed. Records up to
+// sequence number acked are considered acknowledged, in addition to those acknowledged
+// before the queue was last closed.
+func newDiskQueue(dir string, maxSize int64, acked uint64) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	q := &diskQueue{
+		dir:      dir,
+		maxSize:  maxSize,
+		acked:    acked,
+		notifyCh: make(chan struct{}, 1),
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(dir, queueAckFile)); err == nil {
+		if seq, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); seq > q.acked {
+			q.acked = seq
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+queueSegmentExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		first, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), queueSegmentExt), 10, 64)
+		if err != nil {
+			log.Warnf("Ignoring unexpected file %s in event queue", path)
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		q.segments = append(q.segments, &queueSegment{first: first, path: path, size: fi.Size()})
+		q.size += fi.Size()
+	}
+	sort.Slice(q.segments, func(i, j int) bool {
+		return q.segments[i].first < q.segments[j].first
+	})
+
+	q.nextSeq = q.acked + 1
+	if len(q.segments) == 0 {
+		return q, q.newSegment()
+	}
+
+	last := q.segments[len(q.segments)-1]
+	if err := q.recoverSegment(last); err != nil {
+		return nil, err
+	}
+	if q.file, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// recoverSegment computes the next sequence number from the last segment and truncates
+// the record it may end with if it was partially written
+func (q *diskQueue) recoverSegment(s *queueSegment) error {
+	if s.first > q.nextSeq {
+		q.nextSeq = s.first
+	}
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var end int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		end += int64(len(line))
+		if rec, ok := parseQueueRecord(line); ok && rec.seq >= q.nextSeq {
+			q.nextSeq = rec.seq + 1
+		}
+	}
+	if end != s.size {
+		log.Warnf("Truncating partially written record at the end of %s", s.path)
+		if err := os.Truncate(s.path, end); err != nil {
+			return err
+		}
+		q.size -= s.size - end
+		s.size = end
+	}
+	return nil
+}
+
+func (q *diskQueue) newSegment() error {
+	if q.file != nil {
+		if err := q.file.Close(); err != nil {
+			return err
+		}
+		q.file = nil
+	}
+	s := &queueSegment{
+		first: q.nextSeq,
+		path:  filepath.Join(q.dir, fmt.Sprintf("%020d%s", q.nextSeq, queueSegmentExt)),
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	q.file = f
+	q.segments = append(q.segments, s)
+	return nil
+}
+
+// append persists a record and returns its sequence number
+func (q *diskQueue) append(data []byte) (uint64, error) {
+	if bytes.IndexByte(data, '\n') >= 0 {
+		return 0, fmt.Errorf("queue records can't contain new lines")
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	if q.file == nil {
+		return 0, fmt.Errorf("queue %s is closed", q.dir)
+	}
+
+	last := q.segments[len(q.segments)-1]
+	if last.size >= queueSegmentSize {
+		if err := q.newSegment(); err != nil {
+			return 0, err
+		}
+		last = q.segments[len(q.segments)-1]
+	}
+
+	seq := q.nextSeq
+	line := make([]byte, 0, len(data)+22)
+	line = strconv.AppendUint(line, seq, 10)
+	line = append(line, ' ')
+	line = append(line, data...)
+	line = append(line, '\n')
+	// a single write per record, so that readers never see partial records in the middle of a segment
+	n, err := q.file.Write(line)
+	last.size += int64(n)
+	q.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	q.nextSeq++
+
+	q.enforceMaxSize()
+
+	select {
+	case q.notifyCh <- struct{}{}:
+	default:
+	}
+	return seq, nil
+}
+
+// enforceMaxSize drops the oldest segments, acknowledged or not, while the queue is too large
+func (q *diskQueue) enforceMaxSize() {
+	for q.maxSize > 0 && q.size > q.maxSize && len(q.segments) > 1 {
+		s := q.segments[0]
+		count := q.segments[1].first - s.first
+		if s.first+count-1 > q.acked {
+			unacked := count
+			if q.acked >= s.first {
+				unacked = s.first + count - 1 - q.acked
+			}
+			log.Warnf("Event queue is full, dropping %d events", unacked)
+		}
+		q.removeFirstSegment()
+	}
+}
+
+func (q *diskQueue) removeFirstSegment() {
+	s := q.segments[0]
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		log.Errorf("Failed to remove event queue segment %s: %v", s.path, err)
+	}
+	q.size -= s.size
+	q.segments = q.segments[1:]
+}
+
+// notify returns a channel signaled when records are appended
+func (q *diskQueue) notify() <-chan struct{} {
+	return q.notifyCh
+}
+
+// read returns up to max records starting at sequence number from. Records which were dropped
+// are skipped.
+func (q *diskQueue) read(from uint64, max int) ([]queueRecord, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	if from >= q.nextSeq || len(q.segments) == 0 {
+		return nil, nil
+	}
+
+	// the segment holding the record is the last one starting before it
+	i := sort.Search(len(q.segments), func(i int) bool {
+		return q.segments[i].first > from
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	var records []queueRecord
+	for ; i < len(q.segments) && len(records) < max; i++ {
+		recs, err := q.readSegment(q.segments[i], from, max-len(records))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, recs...)
+	}
+	return records, nil
+}
+
+func (q *diskQueue) readSegment(s *queueSegment, from uint64, max int) ([]queueRecord, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var offset int64
+	if q.cursor.path == s.path && q.cursor.seq <= from {
+		offset = q.cursor.offset
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	var records []queueRecord
+	r := bufio.NewReader(f)
+	for offset < s.size && len(records) < max {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// partial record being written
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		offset += int64(len(line))
+		rec, ok := parseQueueRecord(line)
+		if !ok {
+			log.Warnf("Skipping corrupted record in %s", s.path)
+			continue
+		}
+		q.cursor = queueCursor{path: s.path, seq: rec.seq + 1, offset: offset}
+		if rec.seq >= from {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+func parseQueueRecord(line []byte) (queueRecord, bool) {
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	i := bytes.IndexByte(line, ' ')
+	if i < 0 {
+		return queueRecord{}, false
+	}
+	seq, err := strconv.ParseUint(string(line[:i]), 10, 64)
+	if err != nil {
+		return queueRecord{}, false
+	}
+	return queueRecord{seq: seq, data: line[i+1:]}, true
+}
+
+// ack acknowledges all the records up to sequence number seq, removing the segments
+// holding only acknowledged records
+func (q *diskQueue) ack(seq uint64) error {
+	q.Lock()
+	defer q.Unlock()
+
+	if seq <= q.acked {
+		return nil
+	}
+	q.acked = seq
+
+	for len(q.segments) > 1 && q.segments[1].first <= seq+1 {
+		q.removeFirstSegment()
+	}
+	return ioutil.WriteFile(filepath.Join(q.dir, queueAckFile), []byte(strconv.FormatUint(seq, 10)), 0600)
+}
+
+// lastAcked returns the sequence number of the last acknowledged record
+func (q *diskQueue) lastAcked() uint64 {
+	q.Lock()
+	defer q.Unlock()
+	return q.acked
+}
+
+// close closes the queue, records can't be appended anymore
+func (q *diskQueue) close() error {
+	q.Lock()
+	defer q.Unlock()
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	return err
+}
@@ -0,0 +1,147 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendRecords(t *testing.T, q *diskQueue, n int) {
+	for i := 0; i < n; i++ {
+		_, err := q.append([]byte(fmt.Sprintf(`{"n":%d}`, i)))
+		require.NoError(t, err)
+	}
+}
+
+func recordSeqs(records []queueRecord) []uint64 {
+	seqs := make([]uint64, len(records))
+	for i, rec := range records {
+		seqs[i] = rec.seq
+	}
+	return seqs
+}
+
+func TestDiskQueue(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "event-queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := newDiskQueue(dir, 0, 0)
+	require.NoError(t, err)
+
+	seq, err := q.append([]byte(`{"n":0}`))
+	assert.NoError(err)
+	assert.EqualValues(1, seq)
+	appendRecords(t, q, 4)
+
+	_, err = q.append([]byte("{\n}"))
+	assert.Error(err)
+
+	records, err := q.read(1, 2)
+	assert.NoError(err)
+	assert.Equal([]uint64{1, 2}, recordSeqs(records))
+	assert.Equal(`{"n":0}`, string(records[0].data))
+
+	records, err = q.read(3, 10)
+	assert.NoError(err)
+	assert.Equal([]uint64{3, 4, 5}, recordSeqs(records))
+
+	records, err = q.read(6, 10)
+	assert.NoError(err)
+	assert.Empty(records)
+
+	assert.NoError(q.ack(3))
+	assert.NoError(q.close())
+
+	// records appended after the last acknowledged one are read again after a restart, and
+	// partially written records are discarded
+	f, err := os.OpenFile(q.segments[0].path, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(`6 {"n":`))
+	require.NoError(t, err)
+	f.Close()
+
+	q, err = newDiskQueue(dir, 0, 0)
+	require.NoError(t, err)
+	defer q.close()
+	assert.EqualValues(3, q.lastAcked())
+
+	seq, err = q.append([]byte(`{"n":5}`))
+	assert.NoError(err)
+	assert.EqualValues(6, seq)
+
+	records, err = q.read(q.lastAcked()+1, 10)
+	assert.NoError(err)
+	assert.Equal([]uint64{4, 5, 6}, recordSeqs(records))
+	assert.Equal(`{"n":5}`, string(records[2].data))
+}
+
+func TestDiskQueueSegments(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "event-queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := newDiskQueue(dir, 4*queueSegmentSize, 0)
+	require.NoError(t, err)
+	defer q.close()
+
+	data := make([]byte, queueSegmentSize/4)
+	for i := range data {
+		data[i] = 'a'
+	}
+	for i := 0; i < 12; i++ {
+		_, err := q.append(data)
+		require.NoError(t, err)
+	}
+	assert.Len(q.segments, 3)
+
+	// acknowledged segments are removed, except the one being written
+	assert.NoError(q.ack(6))
+	assert.Len(q.segments, 2)
+	assert.EqualValues(5, q.segments[0].first)
+	paths, _ := filepath.Glob(filepath.Join(dir, "*"+queueSegmentExt))
+	assert.Len(paths, 2)
+
+	records, err := q.read(9, 100)
+	assert.NoError(err)
+	assert.Equal([]uint64{9, 10, 11, 12}, recordSeqs(records))
+
+	// the oldest segments are dropped when the queue is full
+	for i := 0; i < 12; i++ {
+		_, err := q.append(data)
+		require.NoError(t, err)
+	}
+	assert.True(q.size <= 4*queueSegmentSize)
+	records, err = q.read(9, 100)
+	assert.NoError(err)
+	assert.Equal(q.segments[0].first, records[0].seq)
+	assert.EqualValues(24, records[len(records)-1].seq)
+}
+
+func TestDiskQueueAckedFloor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "event-queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// sequence numbers keep increasing when the queue is recreated, so that new records are
+	// not considered as delivered
+	q, err := newDiskQueue(dir, 0, 42)
+	require.NoError(t, err)
+	defer q.close()
+
+	seq, err := q.append([]byte(`{}`))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 43, seq)
+}
@@ -0,0 +1,179 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance/event"
+	"github.com/DataDog/datadog-agent/pkg/logs/auditor"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// EventSink submits the security events matching the runtime security rules to the backend
+type EventSink interface {
+	// Send submits an event. It returns once the event is handed off, sinks with at-least-once
+	// delivery return once the event is persisted.
+	Send(evt *event.Event) error
+	Start()
+	Stop()
+}
+
+// reporterSink is an EventSink handing events off to a reporter, events are lost if they
+// can't be delivered before the agent stops
+type reporterSink struct {
+	reporter event.Reporter
+}
+
+// NewReporterSink returns an EventSink sending events through the given reporter
+func NewReporterSink(reporter event.Reporter) EventSink {
+	return &reporterSink{reporter: reporter}
+}
+
+func (s *reporterSink) Send(evt *event.Event) error {
+	s.reporter.Report(evt)
+	return nil
+}
+
+func (s *reporterSink) Start() {}
+
+func (s *reporterSink) Stop() {}
+
+const (
+	// queueSinkIdentifier identifies the messages sent by the queue sink in the auditor registry
+	queueSinkIdentifier = "runtime-security:event-queue"
+	// queueSinkBatchSize is the maximum number of events read from the queue at once
+	queueSinkBatchSize = 100
+	// queueSinkAckPeriod is the period at which delivered events are acknowledged
+	queueSinkAckPeriod = time.Second
+)
+
+// QueueSink is an EventSink providing at-least-once delivery: events are persisted in a
+// queue on disk before being handed off to the logs pipeline, and removed from the queue
+// once the auditor reports them as delivered. Events not delivered when the agent stops,
+// for instance during an intake outage, are sent again when it restarts.
+type QueueSink struct {
+	queue     *diskQueue
+	logSource *config.LogSource
+	logChan   chan *message.Message
+	registry  auditor.Registry
+
+	exit chan struct{}
+	done chan struct{}
+}
+
+// NewQueueSink returns a QueueSink storing its queue in dir, up to maxSize bytes. Events are
+// sent to logChan, and their delivery is tracked through the registry of the auditor of
+// the logs pipeline.
+func NewQueueSink(dir string, maxSize int64, logSource *config.LogSource, logChan chan *message.Message, registry auditor.Registry) (*QueueSink, error) {
+	s := &QueueSink{
+		logSource: logSource,
+		logChan:   logChan,
+		registry:  registry,
+		exit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	queue, err := newDiskQueue(dir, maxSize, s.delivered())
+	if err != nil {
+		return nil, err
+	}
+	s.queue = queue
+	return s, nil
+}
+
+// Send persists an event in the queue
+func (s *QueueSink) Send(evt *event.Event) error {
+	buf, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	_, err = s.queue.append(buf)
+	return err
+}
+
+// Start starts sending the queued events
+func (s *QueueSink) Start() {
+	go s.run()
+}
+
+// Stop stops sending the queued events. Events not delivered yet remain in the queue.
+func (s *QueueSink) Stop() {
+	close(s.exit)
+	<-s.done
+	s.ack()
+	if err := s.queue.close(); err != nil {
+		log.Errorf("Failed to close event queue: %v", err)
+	}
+}
+
+// delivered returns the sequence number of the last event reported as delivered by the auditor
+func (s *QueueSink) delivered() uint64 {
+	seq, _ := strconv.ParseUint(s.registry.GetOffset(queueSinkIdentifier), 10, 64)
+	return seq
+}
+
+func (s *QueueSink) ack() {
+	if err := s.queue.ack(s.delivered()); err != nil {
+		log.Errorf("Failed to acknowledge delivered events: %v", err)
+	}
+}
+
+func (s *QueueSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(queueSinkAckPeriod)
+	defer ticker.Stop()
+
+	// events sent before a restart and not reported as delivered are sent again
+	next := s.queue.lastAcked() + 1
+
+	for {
+		records, err := s.queue.read(next, queueSinkBatchSize)
+		if err != nil {
+			log.Errorf("Failed to read event queue: %v", err)
+		}
+
+		if len(records) == 0 {
+			select {
+			case <-s.queue.notify():
+			case <-ticker.C:
+				s.ack()
+			case <-s.exit:
+				return
+			}
+			continue
+		}
+
+		for _, rec := range records {
+			msg := message.NewMessageWithSource(rec.data, message.StatusInfo, s.logSource)
+			msg.Origin.Identifier = queueSinkIdentifier
+			msg.Origin.Offset = strconv.FormatUint(rec.seq, 10)
+			if !s.send(msg, ticker) {
+				return
+			}
+			next = rec.seq + 1
+		}
+	}
+}
+
+// send hands a message off to the logs pipeline, acknowledging delivered events while it
+// waits. It returns false if the sink was stopped before.
+func (s *QueueSink) send(msg *message.Message, ticker *time.Ticker) bool {
+	for {
+		select {
+		case s.logChan <- msg:
+			return true
+		case <-ticker.C:
+			s.ack()
+		case <-s.exit:
+			return false
+		}
+	}
+}
@@ -41,12 +41,48 @@ type Config struct {
 	SocketPath string
 	// SyscallMonitor defines if the syscall monitor should be activated or not
 	SyscallMonitor bool
+	// NetworkFlows defines if the network flows of processes should be attached to exec and exit events
+	NetworkFlows bool
+	// ExecArgs defines if the arguments of processes should be captured on exec
+	ExecArgs bool
+	// ExecEnvKeys defines the environment variables captured on exec, when ExecArgs is enabled
+	ExecEnvKeys []string
+	// ExecArgsRedactPatterns defines the regular expressions of the secrets redacted from the captured arguments
+	// and environment variables
+	ExecArgsRedactPatterns []string
+	// RedactionAllowPaths defines the glob patterns of the paths displayed in the events sent by the agent, the other
+	// paths being redacted. All the paths are displayed when empty
+	RedactionAllowPaths []string
+	// RedactionDenyPaths defines the glob patterns of the paths redacted from the events sent by the agent
+	RedactionDenyPaths []string
+	// RedactionArgsPatterns defines the regular expressions of the secrets redacted from the arguments and environment
+	// variables of the events sent by the agent
+	RedactionArgsPatterns []string
+	// RedactionHashUserNames defines if the user and group names of the events sent by the agent are replaced with
+	// their hash
+	RedactionHashUserNames bool
+	// KillAction defines if rules are allowed to kill the processes which triggered them
+	KillAction bool
+	// ActivityDumpOutputDir defines the directory in which the activity dumps triggered by rules are written
+	ActivityDumpOutputDir string
+	// AnomalyDetection defines if the events of the workloads should be scored against their learned activity profiles
+	AnomalyDetection bool
+	// AnomalyDetectionLearningPeriod defines how long the activity of a workload is learned before its events are scored
+	AnomalyDetectionLearningPeriod time.Duration
+	// AnomalyDetectionThreshold defines the score from which anomalies are reported
+	AnomalyDetectionThreshold float64
+	// AnomalyDetectionThresholds defines the thresholds of the workloads running the given images, overriding the
+	// default one
+	AnomalyDetectionThresholds map[string]float64
 	// EventServerBurst defines the maximum burst of events that can be sent over the grpc server
 	EventServerBurst int
 	// EventServerRate defines the grpc server rate at which events can be sent
 	EventServerRate int
 	// PIDCacheSize is the size of the user space PID caches
 	PIDCacheSize int
+	// ProcessCacheReconciliationPeriod defines the period at which the process cache is reconciled with /proc, 0
+	// meaning never
+	ProcessCacheReconciliationPeriod time.Duration
 	// LoadControllerEventsCountThreshold defines the amount of events past which we will trigger the in-kernel circuit breaker
 	LoadControllerEventsCountThreshold int64
 	// LoadControllerDiscarderTimeout defines the amount of time discarders set by the load controller should last
@@ -54,6 +90,15 @@ type Config struct {
 	// LoadControllerControlPeriod defines the period at which the load controller will empty the user space counter used
 	// to evaluate the amount of events brought back to user space
 	LoadControllerControlPeriod time.Duration
+	// DentryCacheSize defines the number of dentries kept in the user space dentry cache
+	DentryCacheSize int
+	// DentryCacheTTL defines how long a dentry is kept in the user space dentry cache, 0 meaning until it is evicted
+	DentryCacheTTL time.Duration
+	// DentryNegativeCacheSize defines the number of unresolvable dentries remembered to avoid looking them up again,
+	// 0 disables negative caching
+	DentryNegativeCacheSize int
+	// DentryNegativeCacheTTL defines how long an unresolvable dentry is remembered
+	DentryNegativeCacheTTL time.Duration
 	// StatsAddr defines the statsd address
 	StatsdAddr string
 }
@@ -68,13 +113,31 @@ func NewConfig(cfg *config.AgentConfig) (*Config, error) {
 		FlushDiscarderWindow:               aconfig.Datadog.GetInt("runtime_security_config.flush_discarder_window"),
 		SocketPath:                         aconfig.Datadog.GetString("runtime_security_config.socket"),
 		SyscallMonitor:                     aconfig.Datadog.GetBool("runtime_security_config.syscall_monitor.enabled"),
+		NetworkFlows:                       aconfig.Datadog.GetBool("runtime_security_config.network_flows.enabled"),
+		ExecArgs:                           aconfig.Datadog.GetBool("runtime_security_config.exec_args.enabled"),
+		ExecEnvKeys:                        aconfig.Datadog.GetStringSlice("runtime_security_config.exec_args.env_keys"),
+		ExecArgsRedactPatterns:             aconfig.Datadog.GetStringSlice("runtime_security_config.exec_args.redact_patterns"),
+		RedactionAllowPaths:                aconfig.Datadog.GetStringSlice("runtime_security_config.redaction.allow_paths"),
+		RedactionDenyPaths:                 aconfig.Datadog.GetStringSlice("runtime_security_config.redaction.deny_paths"),
+		RedactionArgsPatterns:              aconfig.Datadog.GetStringSlice("runtime_security_config.redaction.args_patterns"),
+		RedactionHashUserNames:             aconfig.Datadog.GetBool("runtime_security_config.redaction.hash_user_names"),
+		KillAction:                         aconfig.Datadog.GetBool("runtime_security_config.actions.kill.enabled"),
+		ActivityDumpOutputDir:              aconfig.Datadog.GetString("runtime_security_config.actions.activity_dump.output_dir"),
+		AnomalyDetection:                   aconfig.Datadog.GetBool("runtime_security_config.anomaly_detection.enabled"),
+		AnomalyDetectionLearningPeriod:     time.Duration(aconfig.Datadog.GetInt("runtime_security_config.anomaly_detection.learning_period")) * time.Second,
+		AnomalyDetectionThreshold:          aconfig.Datadog.GetFloat64("runtime_security_config.anomaly_detection.threshold"),
 		PoliciesDir:                        aconfig.Datadog.GetString("runtime_security_config.policies.dir"),
 		EventServerBurst:                   aconfig.Datadog.GetInt("runtime_security_config.event_server.burst"),
 		EventServerRate:                    aconfig.Datadog.GetInt("runtime_security_config.event_server.rate"),
 		PIDCacheSize:                       aconfig.Datadog.GetInt("runtime_security_config.pid_cache_size"),
+		ProcessCacheReconciliationPeriod:   time.Duration(aconfig.Datadog.GetInt("runtime_security_config.process_cache.reconciliation_period")) * time.Second,
 		LoadControllerEventsCountThreshold: int64(aconfig.Datadog.GetInt("runtime_security_config.load_controller.events_count_threshold")),
 		LoadControllerDiscarderTimeout:     time.Duration(aconfig.Datadog.GetInt("runtime_security_config.load_controller.discarder_timeout")) * time.Second,
 		LoadControllerControlPeriod:        time.Duration(aconfig.Datadog.GetInt("runtime_security_config.load_controller.control_period")) * time.Second,
+		DentryCacheSize:                    aconfig.Datadog.GetInt("runtime_security_config.dentry_cache.size"),
+		DentryCacheTTL:                     time.Duration(aconfig.Datadog.GetInt("runtime_security_config.dentry_cache.ttl")) * time.Second,
+		DentryNegativeCacheSize:            aconfig.Datadog.GetInt("runtime_security_config.dentry_cache.negative_size"),
+		DentryNegativeCacheTTL:             time.Duration(aconfig.Datadog.GetInt("runtime_security_config.dentry_cache.negative_ttl")) * time.Second,
 		StatsdAddr:                         fmt.Sprintf("%s:%d", cfg.StatsdHost, cfg.StatsdPort),
 	}
 
@@ -82,6 +145,10 @@ func NewConfig(cfg *config.AgentConfig) (*Config, error) {
 		c.BPFDir = cfg.SystemProbeBPFDir
 	}
 
+	if err := aconfig.Datadog.UnmarshalKey("runtime_security_config.anomaly_detection.workload_thresholds", &c.AnomalyDetectionThresholds); err != nil {
+		return nil, fmt.Errorf("invalid anomaly detection workload thresholds: %w", err)
+	}
+
 	if !c.Enabled {
 		return c, nil
 	}
@@ -0,0 +1,68 @@
+#ifndef _CGROUP_WRITE_H_
+#define _CGROUP_WRITE_H_
+
+#define CGROUP_WRITE_VALUE_LEN 256
+
+enum cgroup_file
+{
+    CGROUP_RELEASE_AGENT = 1,
+    CGROUP_NOTIFY_ON_RELEASE,
+};
+
+struct cgroup_write_event_t {
+    struct kevent_t event;
+    struct process_context_t process;
+    struct container_context_t container;
+    u32 file;
+    u32 padding;
+    char value[CGROUP_WRITE_VALUE_LEN];
+};
+
+// cgroup_release_agent_write is called on writes to the release_agent file of a cgroup v1 hierarchy,
+// the program it points to is executed in the root namespaces when a cgroup becomes empty.
+SEC("kprobe/cgroup_release_agent_write")
+int kprobe__cgroup_release_agent_write(struct pt_regs *ctx) {
+    if (!is_event_enabled(EVENT_CGROUP_WRITE))
+        return 0;
+
+    struct cgroup_write_event_t event = {
+        .event.type = EVENT_CGROUP_WRITE,
+        .event.timestamp = bpf_ktime_get_ns(),
+        .file = CGROUP_RELEASE_AGENT,
+    };
+
+    bpf_probe_read_str(&event.value, CGROUP_WRITE_VALUE_LEN, (void *)PT_REGS_PARM2(ctx));
+
+    struct proc_cache_t *entry = fill_process_context(&event.process);
+    fill_container_context(entry, &event.container);
+
+    send_event(ctx, event);
+
+    return 0;
+}
+
+// cgroup_write_notify_on_release is called on writes to the notify_on_release file of a cgroup, which
+// enables the execution of the release agent.
+SEC("kprobe/cgroup_write_notify_on_release")
+int kprobe__cgroup_write_notify_on_release(struct pt_regs *ctx) {
+    if (!is_event_enabled(EVENT_CGROUP_WRITE))
+        return 0;
+
+    struct cgroup_write_event_t event = {
+        .event.type = EVENT_CGROUP_WRITE,
+        .event.timestamp = bpf_ktime_get_ns(),
+        .file = CGROUP_NOTIFY_ON_RELEASE,
+    };
+
+    u64 val = (u64)PT_REGS_PARM3(ctx);
+    event.value[0] = val ? '1' : '0';
+
+    struct proc_cache_t *entry = fill_process_context(&event.process);
+    fill_container_context(entry, &event.container);
+
+    send_event(ctx, event);
+
+    return 0;
+}
+
+#endif
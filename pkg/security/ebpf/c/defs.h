@@ -198,6 +198,9 @@ enum event_type
     EVENT_EXEC,
     EVENT_EXIT,
     EVENT_INVALIDATE_DENTRY,
+    EVENT_EXEC_ARGS,
+    EVENT_NAMESPACE,
+    EVENT_CGROUP_WRITE,
     EVENT_MAX, // has to be the last one and a power of two
 };
 
@@ -220,6 +223,7 @@ enum syscall_type
     SYSCALL_SETXATTR    = 1 << EVENT_SETXATTR,
     SYSCALL_REMOVEXATTR = 1 << EVENT_REMOVEXATTR,
     SYSCALL_EXEC        = 1 << EVENT_EXEC,
+    SYSCALL_NAMESPACE   = 1 << EVENT_NAMESPACE,
 };
 
 struct kevent_t {
@@ -6,18 +6,22 @@
 #include "filters.h"
 #include "syscalls.h"
 #include "container.h"
+#include "flow.h"
+#include "exec_args.h"
 
 struct exec_event_t {
     struct kevent_t event;
     struct process_context_t process;
     struct proc_cache_t proc_entry;
     struct pid_cache_t pid_entry;
+    struct process_flows_t flows;
 };
 
 struct exit_event_t {
     struct kevent_t event;
     struct process_context_t process;
     struct container_context_t container;
+    struct process_flows_t flows;
 };
 
 struct _tracepoint_sched_process_fork
@@ -35,6 +39,8 @@ struct _tracepoint_sched_process_fork
 
 void __attribute__((always_inline)) copy_proc_cache(struct proc_cache_t *dst, struct proc_cache_t *src) {
     dst->executable = src->executable;
+    dst->loginuid = src->loginuid;
+    dst->sessionid = src->sessionid;
     fill_container_context(src, &dst->container);
     return;
 }
@@ -52,21 +58,22 @@ static __attribute__((always_inline)) u32 copy_tty_name(char dst[TTY_NAME_LEN],
     return TTY_NAME_LEN;
 }
 
-int __attribute__((always_inline)) trace__sys_execveat() {
+int __attribute__((always_inline)) trace__sys_execveat(const char **argv, const char **envp) {
     struct syscall_cache_t syscall = {
         .type = SYSCALL_EXEC,
     };
 
     cache_syscall(&syscall, EVENT_EXEC);
+    cache_exec_args(argv, envp);
     return 0;
 }
 
-SYSCALL_KPROBE0(execve) {
-    return trace__sys_execveat();
+SYSCALL_KPROBE3(execve, const char *, filename, const char **, argv, const char **, envp) {
+    return trace__sys_execveat(argv, envp);
 }
 
-SYSCALL_KPROBE0(execveat) {
-    return trace__sys_execveat();
+SYSCALL_KPROBE4(execveat, int, fd, const char *, filename, const char **, argv, const char **, envp) {
+    return trace__sys_execveat(argv, envp);
 }
 
 int __attribute__((always_inline)) handle_exec_event(struct pt_regs *ctx, struct syscall_cache_t *syscall) {
@@ -92,6 +99,7 @@ int __attribute__((always_inline)) handle_exec_event(struct pt_regs *ctx, struct
         .exec_timestamp = bpf_ktime_get_ns(),
     };
     bpf_get_current_comm(&entry.comm, sizeof(entry.comm));
+    fill_audit_ids(&entry);
 
     // select the previous cookie entry in cache of the current process
     // (this entry was created by the fork of the current process)
@@ -141,6 +149,8 @@ int sched_process_fork(struct _tracepoint_sched_process_fork *args) {
         .pid_entry.fork_timestamp = ts,
     };
     bpf_get_current_comm(&event.proc_entry.comm, sizeof(event.proc_entry.comm));
+    // the child inherits the audit IDs of the parent, which is the current task
+    fill_audit_ids(&event.proc_entry);
     fill_process_context(&event.process);
 
     // the `parent_pid` entry of `sched_process_fork` might point to the TID (and not PID) of the parent. Since we
@@ -207,6 +217,7 @@ int kprobe_do_exit(struct pt_regs *ctx) {
         };
         struct proc_cache_t *cache_entry = fill_process_context(&event.process);
         fill_container_context(cache_entry, &event.container);
+        pop_process_flows(tgid, &event.flows);
 
         send_process_events(ctx, event);
     }
@@ -251,6 +262,8 @@ int kprobe_security_bprm_committed_creds(struct pt_regs *ctx) {
                 },
                 .proc_entry.container = {},
                 .proc_entry.exec_timestamp = proc_entry->exec_timestamp,
+                .proc_entry.loginuid = proc_entry->loginuid,
+                .proc_entry.sessionid = proc_entry->sessionid,
                 .pid_entry.cookie = pid_entry->cookie,
                 .pid_entry.ppid = pid_entry->ppid,
                 .pid_entry.fork_timestamp = pid_entry->fork_timestamp,
@@ -261,6 +274,12 @@ int kprobe_security_bprm_committed_creds(struct pt_regs *ctx) {
             fill_process_context(&event.process);
             fill_container_context(proc_entry, &event.proc_entry.container);
 
+            // attach the flows of the previous image, the new one starts with an empty summary
+            pop_process_flows(tgid, &event.flows);
+
+            // the arguments have to be received before the exec event they belong to
+            send_exec_args(ctx, tgid);
+
             // send the entry to maintain userspace cache
             send_process_events(ctx, event);
         }
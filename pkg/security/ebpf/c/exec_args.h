@@ -0,0 +1,125 @@
+#ifndef _EXEC_ARGS_H_
+#define _EXEC_ARGS_H_
+
+#define EXEC_MAX_STRINGS 32
+#define EXEC_MAX_STRING_SIZE 128
+
+#define EXEC_ARGS_TRUNCATED (1 << 0)
+#define EXEC_ENVS_TRUNCATED (1 << 1)
+
+struct exec_args_t {
+    struct kevent_t event;
+    u32 pid;
+    u32 flags;
+    u32 args_count;
+    u32 envs_count;
+    char args[EXEC_MAX_STRINGS][EXEC_MAX_STRING_SIZE];
+    char envs[EXEC_MAX_STRINGS][EXEC_MAX_STRING_SIZE];
+};
+
+struct bpf_map_def SEC("maps/exec_args_enabled") exec_args_enabled = {
+    .type = BPF_MAP_TYPE_ARRAY,
+    .key_size = sizeof(u32),
+    .value_size = sizeof(u32),
+    .max_entries = 1,
+    .pinning = 0,
+    .namespace = "",
+};
+
+// exec_args_gen is used to build the exec_args_t entries, they are too large for the stack
+struct bpf_map_def SEC("maps/exec_args_gen") exec_args_gen = {
+    .type = BPF_MAP_TYPE_PERCPU_ARRAY,
+    .key_size = sizeof(u32),
+    .value_size = sizeof(struct exec_args_t),
+    .max_entries = 1,
+    .pinning = 0,
+    .namespace = "",
+};
+
+// exec_args holds the arguments of the pending execs until their exec event is sent
+struct bpf_map_def SEC("maps/exec_args") exec_args = {
+    .type = BPF_MAP_TYPE_LRU_HASH,
+    .key_size = sizeof(u32),
+    .value_size = sizeof(struct exec_args_t),
+    .max_entries = 512,
+    .pinning = 0,
+    .namespace = "",
+};
+
+static __attribute__((always_inline)) int is_exec_args_enabled(void) {
+    u32 key = 0;
+    u32 *enabled = bpf_map_lookup_elem(&exec_args_enabled, &key);
+    return enabled != NULL && *enabled;
+}
+
+// read_exec_strings copies the strings of a NULL terminated array, such as argv or envp, and returns how many were
+// copied. truncated is set when some strings didn't fit.
+static __attribute__((always_inline)) u32 read_exec_strings(const char **src, char (*dst)[EXEC_MAX_STRING_SIZE], u32 *truncated) {
+    const char *str = NULL;
+    u32 count = 0;
+
+#pragma unroll
+    for (int i = 0; i < EXEC_MAX_STRINGS; i++)
+    {
+        if (bpf_probe_read(&str, sizeof(str), (void *)&src[i]) < 0 || str == NULL) {
+            return count;
+        }
+        int len = bpf_probe_read_str(dst[i], EXEC_MAX_STRING_SIZE, (void *)str);
+        if (len < 0) {
+            dst[i][0] = 0;
+        } else if (len == EXEC_MAX_STRING_SIZE) {
+            // the copy always ends with a NULL byte, the string only didn't fit if the source doesn't end there
+            char last = 0;
+            if (bpf_probe_read(&last, sizeof(last), (void *)str + EXEC_MAX_STRING_SIZE - 1) == 0 && last != 0) {
+                *truncated = 1;
+            }
+        }
+        count++;
+    }
+
+    // more strings than available slots
+    if (bpf_probe_read(&str, sizeof(str), (void *)&src[EXEC_MAX_STRINGS]) == 0 && str != NULL) {
+        *truncated = 1;
+    }
+    return count;
+}
+
+// cache_exec_args captures the arguments and environment variables of an exec, they are read from the memory
+// of the calling process and have to be copied before the new image replaces it. Only 64 bits callers are supported.
+static __attribute__((always_inline)) void cache_exec_args(const char **argv, const char **envp) {
+    if (!is_exec_args_enabled()) {
+        return;
+    }
+
+    u32 key = 0;
+    struct exec_args_t *entry = bpf_map_lookup_elem(&exec_args_gen, &key);
+    if (!entry) {
+        return;
+    }
+
+    u32 args_truncated = 0, envs_truncated = 0;
+    entry->args_count = read_exec_strings(argv, entry->args, &args_truncated);
+    entry->envs_count = read_exec_strings(envp, entry->envs, &envs_truncated);
+    entry->flags = (args_truncated ? EXEC_ARGS_TRUNCATED : 0) | (envs_truncated ? EXEC_ENVS_TRUNCATED : 0);
+
+    u32 tgid = bpf_get_current_pid_tgid() >> 32;
+    entry->pid = tgid;
+    bpf_map_update_elem(&exec_args, &tgid, entry, BPF_ANY);
+}
+
+// send_exec_args sends the arguments of an exec before its exec event. Both events are sent from the same CPU, which
+// guarantees that user space receives them in order.
+static __attribute__((always_inline)) void send_exec_args(struct pt_regs *ctx, u32 tgid) {
+    struct exec_args_t *entry = bpf_map_lookup_elem(&exec_args, &tgid);
+    if (!entry) {
+        return;
+    }
+
+    entry->event.type = EVENT_EXEC_ARGS;
+    entry->event.timestamp = bpf_ktime_get_ns();
+    bpf_perf_event_output(ctx, &events, bpf_get_smp_processor_id(), entry, sizeof(struct exec_args_t));
+
+    bpf_map_delete_elem(&exec_args, &tgid);
+}
+
+#endif
@@ -0,0 +1,106 @@
+#ifndef _FLOW_H_
+#define _FLOW_H_
+
+#include <linux/socket.h>
+#include <linux/in.h>
+#include <linux/in6.h>
+
+#define MAX_PROCESS_FLOWS 4
+
+struct flow_t {
+    u64 daddr[2];
+    u16 family;
+    u16 dport;
+    u32 count;
+};
+
+struct process_flows_t {
+    struct flow_t flows[MAX_PROCESS_FLOWS];
+};
+
+struct bpf_map_def SEC("maps/process_flows") process_flows = {
+    .type = BPF_MAP_TYPE_LRU_HASH,
+    .key_size = sizeof(u32),
+    .value_size = sizeof(struct process_flows_t),
+    .max_entries = 4096,
+    .pinning = 0,
+    .namespace = "",
+};
+
+static __attribute__((always_inline)) int is_same_flow(struct flow_t *a, struct flow_t *b) {
+    return a->family == b->family && a->dport == b->dport && a->daddr[0] == b->daddr[0] && a->daddr[1] == b->daddr[1];
+}
+
+static __attribute__((always_inline)) void track_flow(u32 tgid, struct flow_t *flow) {
+    struct process_flows_t *entry = bpf_map_lookup_elem(&process_flows, &tgid);
+    if (!entry) {
+        struct process_flows_t new_entry = {};
+        new_entry.flows[0] = *flow;
+        new_entry.flows[0].count = 1;
+        bpf_map_update_elem(&process_flows, &tgid, &new_entry, BPF_ANY);
+        return;
+    }
+
+    // look for the destination, and keep track of the least used slot
+    u32 slot = 0;
+    u32 min_count = (u32)-1;
+
+#pragma unroll
+    for (int i = 0; i < MAX_PROCESS_FLOWS; i++)
+    {
+        if (entry->flows[i].count > 0 && is_same_flow(&entry->flows[i], flow)) {
+            __sync_fetch_and_add(&entry->flows[i].count, 1);
+            return;
+        }
+        if (entry->flows[i].count < min_count) {
+            min_count = entry->flows[i].count;
+            slot = i;
+        }
+    }
+
+    // new destination, replace the least used one so that the top destinations are kept
+#pragma unroll
+    for (int i = 0; i < MAX_PROCESS_FLOWS; i++)
+    {
+        if (i == slot) {
+            entry->flows[i] = *flow;
+            entry->flows[i].count = 1;
+        }
+    }
+}
+
+// pop_process_flows copies the flows of the given process to dst and resets them
+static __attribute__((always_inline)) void pop_process_flows(u32 tgid, struct process_flows_t *dst) {
+    struct process_flows_t *entry = bpf_map_lookup_elem(&process_flows, &tgid);
+    if (entry) {
+        *dst = *entry;
+        bpf_map_delete_elem(&process_flows, &tgid);
+    }
+}
+
+SEC("kprobe/security_socket_connect")
+int kprobe_security_socket_connect(struct pt_regs *ctx) {
+    struct sockaddr *address = (struct sockaddr *)PT_REGS_PARM2(ctx);
+
+    struct flow_t flow = {};
+    bpf_probe_read(&flow.family, sizeof(flow.family), &address->sa_family);
+
+    if (flow.family == AF_INET) {
+        struct sockaddr_in *addr_in = (struct sockaddr_in *)address;
+        bpf_probe_read(&flow.dport, sizeof(flow.dport), &addr_in->sin_port);
+        bpf_probe_read(&flow.daddr, sizeof(addr_in->sin_addr), &addr_in->sin_addr);
+    } else if (flow.family == AF_INET6) {
+        struct sockaddr_in6 *addr_in6 = (struct sockaddr_in6 *)address;
+        bpf_probe_read(&flow.dport, sizeof(flow.dport), &addr_in6->sin6_port);
+        bpf_probe_read(&flow.daddr, sizeof(addr_in6->sin6_addr), &addr_in6->sin6_addr);
+    } else {
+        return 0;
+    }
+
+    u32 tgid = bpf_get_current_pid_tgid() >> 32;
+    track_flow(tgid, &flow);
+
+    return 0;
+}
+
+#endif
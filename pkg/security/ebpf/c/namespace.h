@@ -0,0 +1,120 @@
+#ifndef _NAMESPACE_H_
+#define _NAMESPACE_H_
+
+#include "syscalls.h"
+
+#ifndef CLONE_NEWNS
+#define CLONE_NEWNS     0x00020000
+#endif
+#ifndef CLONE_NEWCGROUP
+#define CLONE_NEWCGROUP 0x02000000
+#endif
+#ifndef CLONE_NEWUTS
+#define CLONE_NEWUTS    0x04000000
+#endif
+#ifndef CLONE_NEWIPC
+#define CLONE_NEWIPC    0x08000000
+#endif
+#ifndef CLONE_NEWUSER
+#define CLONE_NEWUSER   0x10000000
+#endif
+#ifndef CLONE_NEWPID
+#define CLONE_NEWPID    0x20000000
+#endif
+#ifndef CLONE_NEWNET
+#define CLONE_NEWNET    0x40000000
+#endif
+
+#define NAMESPACE_FLAGS (CLONE_NEWNS | CLONE_NEWCGROUP | CLONE_NEWUTS | CLONE_NEWIPC | CLONE_NEWUSER | CLONE_NEWPID | CLONE_NEWNET)
+
+enum namespace_origin
+{
+    NAMESPACE_UNSHARE = 1,
+    NAMESPACE_SETNS,
+    NAMESPACE_CLONE,
+};
+
+struct namespace_event_t {
+    struct kevent_t event;
+    struct process_context_t process;
+    struct container_context_t container;
+    struct syscall_t syscall;
+    u64 flags;
+    u32 origin;
+    u32 padding;
+};
+
+int __attribute__((always_inline)) trace__sys_namespace(u64 flags, u32 origin) {
+    // only report the syscalls creating namespaces. setns is always reported as a namespace
+    // type of 0 allows to join a namespace of any type.
+    flags &= NAMESPACE_FLAGS;
+    if (!flags && origin != NAMESPACE_SETNS)
+        return 0;
+
+    struct syscall_cache_t syscall = {
+        .type = SYSCALL_NAMESPACE,
+        .namespace = {
+            .flags = flags,
+            .origin = origin,
+        }
+    };
+
+    cache_syscall(&syscall, EVENT_NAMESPACE);
+
+    if (discarded_by_process(syscall.policy.mode, EVENT_NAMESPACE)) {
+        pop_syscall(SYSCALL_NAMESPACE);
+    }
+
+    return 0;
+}
+
+SYSCALL_KPROBE1(unshare, unsigned long, flags) {
+    return trace__sys_namespace(flags, NAMESPACE_UNSHARE);
+}
+
+SYSCALL_KPROBE2(setns, int, fd, int, nstype) {
+    return trace__sys_namespace(nstype, NAMESPACE_SETNS);
+}
+
+SYSCALL_KPROBE1(clone, unsigned long, flags) {
+    return trace__sys_namespace(flags, NAMESPACE_CLONE);
+}
+
+int __attribute__((always_inline)) trace__sys_namespace_ret(struct pt_regs *ctx) {
+    struct syscall_cache_t *syscall = pop_syscall(SYSCALL_NAMESPACE);
+    if (!syscall)
+        return 0;
+
+    int retval = PT_REGS_RC(ctx);
+    if (IS_UNHANDLED_ERROR(retval))
+        return 0;
+
+    struct namespace_event_t event = {
+        .event.type = EVENT_NAMESPACE,
+        .event.timestamp = bpf_ktime_get_ns(),
+        .syscall.retval = retval,
+        .flags = syscall->namespace.flags,
+        .origin = syscall->namespace.origin,
+    };
+
+    struct proc_cache_t *entry = fill_process_context(&event.process);
+    fill_container_context(entry, &event.container);
+
+    send_event(ctx, event);
+
+    return 0;
+}
+
+SYSCALL_KRETPROBE(unshare) {
+    return trace__sys_namespace_ret(ctx);
+}
+
+SYSCALL_KRETPROBE(setns) {
+    return trace__sys_namespace_ret(ctx);
+}
+
+SYSCALL_KRETPROBE(clone) {
+    return trace__sys_namespace_ret(ctx);
+}
+
+#endif
@@ -29,6 +29,8 @@
 #include "raw_syscalls.h"
 #include "procfs.h"
 #include "setxattr.h"
+#include "namespace.h"
+#include "cgroup_write.h"
 
 struct invalidate_dentry_event_t {
     struct kevent_t event;
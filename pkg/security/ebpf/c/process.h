@@ -11,8 +11,27 @@ struct proc_cache_t {
     u64 exec_timestamp;
     char tty_name[TTY_NAME_LEN];
     char comm[TASK_COMM_LEN];
+    u32 loginuid;
+    u32 sessionid;
 };
 
+// AUDIT_ID_UNSET is the value of the audit login UID and session ID of the processes which are not part of a login
+// session, or when the kernel is built without audit support
+#define AUDIT_ID_UNSET ((u32)-1)
+
+// fill_audit_ids reads the audit login UID and session ID of the current task. Both are inherited across fork and
+// execve.
+static __attribute__((always_inline)) void fill_audit_ids(struct proc_cache_t *entry) {
+#ifdef CONFIG_AUDITSYSCALL
+    struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+    bpf_probe_read(&entry->loginuid, sizeof(entry->loginuid), &task->loginuid);
+    bpf_probe_read(&entry->sessionid, sizeof(entry->sessionid), &task->sessionid);
+#else
+    entry->loginuid = AUDIT_ID_UNSET;
+    entry->sessionid = AUDIT_ID_UNSET;
+#endif
+}
+
 struct bpf_map_def SEC("maps/proc_cache") proc_cache = {
     .type = BPF_MAP_TYPE_LRU_HASH,
     .key_size = sizeof(u32),
@@ -100,6 +100,11 @@ struct syscall_cache_t {
             const char *name;
             u64 real_inode;
         } setxattr;
+
+        struct {
+            u64 flags;
+            u32 origin;
+        } namespace;
     };
 };
 
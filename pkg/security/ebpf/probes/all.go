@@ -23,6 +23,7 @@ func AllProbes() []*manager.Probe {
 	allProbes = append(allProbes, getLinkProbe()...)
 	allProbes = append(allProbes, getMkdirProbes()...)
 	allProbes = append(allProbes, getMountProbes()...)
+	allProbes = append(allProbes, getNamespaceProbes()...)
 	allProbes = append(allProbes, getOpenProbes()...)
 	allProbes = append(allProbes, getRenameProbes()...)
 	allProbes = append(allProbes, getRmdirProbe()...)
@@ -45,6 +46,11 @@ func AllProbes() []*manager.Probe {
 			UID:     SecurityAgentUID,
 			Section: "kretprobe/get_task_exe_file",
 		},
+		// Network flows
+		&manager.Probe{
+			UID:     SecurityAgentUID,
+			Section: "kprobe/security_socket_connect",
+		},
 	)
 
 	return allProbes
@@ -67,6 +73,12 @@ func AllMaps() []*manager.Map {
 		// Exec tables
 		{Name: "proc_cache"},
 		{Name: "pid_cache"},
+		// Network flows table
+		{Name: "process_flows"},
+		// Exec arguments tables
+		{Name: "exec_args_enabled"},
+		{Name: "exec_args_gen"},
+		{Name: "exec_args"},
 		// Mount tables
 		{Name: "mount_id_offset"},
 		// Syscall monitor tables
@@ -19,6 +19,11 @@ var SyscallMonitorSelectors = []manager.ProbesSelector{
 	&manager.ProbeSelector{ProbeIdentificationPair: manager.ProbeIdentificationPair{UID: SecurityAgentUID, Section: "tracepoint/sched/sched_process_exec"}},
 }
 
+// NetworkFlowsSelectors is the list of probes that should be activated to attach network flows to process events
+var NetworkFlowsSelectors = []manager.ProbesSelector{
+	&manager.ProbeSelector{ProbeIdentificationPair: manager.ProbeIdentificationPair{UID: SecurityAgentUID, Section: "kprobe/security_socket_connect"}},
+}
+
 // SelectorsPerEventType is the list of probes that should be activated for each event
 var SelectorsPerEventType = map[eval.EventType][]manager.ProbesSelector{
 
@@ -110,6 +115,14 @@ var SelectorsPerEventType = map[eval.EventType][]manager.ProbesSelector{
 		}},
 	},
 
+	// List of probes to activate to capture writes to the cgroup release files, only available on cgroup v1
+	"cgroup_write": {
+		&manager.BestEffort{Selectors: []manager.ProbesSelector{
+			&manager.ProbeSelector{ProbeIdentificationPair: manager.ProbeIdentificationPair{UID: SecurityAgentUID, Section: "kprobe/cgroup_release_agent_write"}},
+			&manager.ProbeSelector{ProbeIdentificationPair: manager.ProbeIdentificationPair{UID: SecurityAgentUID, Section: "kprobe/cgroup_write_notify_on_release"}},
+		}},
+	},
+
 	// List of probes to activate to capture chmod events
 	"chmod": {
 		&manager.AllOf{Selectors: []manager.ProbesSelector{
@@ -188,6 +201,19 @@ var SelectorsPerEventType = map[eval.EventType][]manager.ProbesSelector{
 		},
 	},
 
+	// List of probes to activate to capture namespace events
+	"namespace": {
+		&manager.OneOf{Selectors: ExpandSyscallProbesSelector(
+			manager.ProbeIdentificationPair{UID: SecurityAgentUID, Section: "unshare"}, EntryAndExit),
+		},
+		&manager.OneOf{Selectors: ExpandSyscallProbesSelector(
+			manager.ProbeIdentificationPair{UID: SecurityAgentUID, Section: "setns"}, EntryAndExit),
+		},
+		&manager.OneOf{Selectors: ExpandSyscallProbesSelector(
+			manager.ProbeIdentificationPair{UID: SecurityAgentUID, Section: "clone"}, EntryAndExit),
+		},
+	},
+
 	// List of probes to activate to capture open events
 	"open": {
 		&manager.AllOf{Selectors: []manager.ProbesSelector{
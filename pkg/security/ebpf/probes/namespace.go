@@ -0,0 +1,38 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probes
+
+import "github.com/DataDog/ebpf/manager"
+
+// namespaceProbes holds the list of probes used to track namespace and cgroup release events
+var namespaceProbes = []*manager.Probe{
+	{
+		UID:     SecurityAgentUID,
+		Section: "kprobe/cgroup_release_agent_write",
+	},
+	{
+		UID:     SecurityAgentUID,
+		Section: "kprobe/cgroup_write_notify_on_release",
+	},
+}
+
+func getNamespaceProbes() []*manager.Probe {
+	namespaceProbes = append(namespaceProbes, ExpandSyscallProbes(&manager.Probe{
+		UID:             SecurityAgentUID,
+		SyscallFuncName: "unshare",
+	}, EntryAndExit)...)
+	namespaceProbes = append(namespaceProbes, ExpandSyscallProbes(&manager.Probe{
+		UID:             SecurityAgentUID,
+		SyscallFuncName: "setns",
+	}, EntryAndExit)...)
+	namespaceProbes = append(namespaceProbes, ExpandSyscallProbes(&manager.Probe{
+		UID:             SecurityAgentUID,
+		SyscallFuncName: "clone",
+	}, EntryAndExit)...)
+	return namespaceProbes
+}
@@ -0,0 +1,314 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/DataDog/datadog-agent/pkg/security/config"
+	sprobe "github.com/DataDog/datadog-agent/pkg/security/probe"
+	"github.com/DataDog/datadog-agent/pkg/security/rules"
+	"github.com/DataDog/datadog-agent/pkg/security/secl/eval"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// Maximum rate of processes killed by rules. A permissive rule with a kill action could otherwise
+	// take down a whole host.
+	defaultKillLimit = rate.Limit(1)
+	// Token bucket size of the kill rate limiter
+	defaultKillBurst int = 5
+	// Maximum number of processes with tags set by rules
+	maxTaggedProcesses = 4096
+	// Maximum number of activity dumps running at the same time
+	maxActivityDumps = 10
+)
+
+var killSignals = map[string]syscall.Signal{
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGSTOP": syscall.SIGSTOP,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+// activityDump records the events of a process to a file
+type activityDump struct {
+	file     *os.File
+	deadline time.Time
+}
+
+// ActionExecutor executes the actions of the rules that matched an event
+type ActionExecutor struct {
+	sync.Mutex
+	config      *config.Config
+	redactor    *sprobe.EventRedactor
+	killLimiter *rate.Limiter
+	processTags map[uint32][]string
+	dumps       map[uint32]*activityDump
+
+	killed      int64
+	killErrors  int64
+	killDropped int64
+	dumped      int64
+}
+
+// NewActionExecutor returns a new action executor, redacting the recorded events with redactor when it is set
+func NewActionExecutor(cfg *config.Config, redactor *sprobe.EventRedactor) *ActionExecutor {
+	return &ActionExecutor{
+		config:      cfg,
+		redactor:    redactor,
+		killLimiter: rate.NewLimiter(defaultKillLimit, defaultKillBurst),
+		processTags: make(map[uint32][]string),
+		dumps:       make(map[uint32]*activityDump),
+	}
+}
+
+// Execute runs the given actions for the event that matched rule
+func (ae *ActionExecutor) Execute(rule *eval.Rule, actions []rules.ActionDefinition, event *sprobe.Event) {
+	pid := event.Process.Pid
+
+	for _, action := range actions {
+		var err error
+		switch {
+		case action.Kill != nil:
+			err = ae.kill(pid, action.Kill.Signal)
+		case len(action.SetTags) != 0:
+			ae.setTags(pid, action.SetTags)
+		case action.ActivityDump != nil:
+			err = ae.startActivityDump(rule.ID, pid, action.ActivityDump.Timeout)
+		}
+
+		if err != nil {
+			log.Warnf("failed to execute action `%s` of rule `%s` on process %d: %s", action.Name(), rule.ID, pid, err)
+		}
+	}
+}
+
+// kill sends a signal to a process, unless a safety guard prevents it
+func (ae *ActionExecutor) kill(pid uint32, signal string) error {
+	if !ae.config.KillAction {
+		return errors.New("kill actions are disabled")
+	}
+
+	// never kill init or ourselves
+	if pid <= 1 || int(pid) == os.Getpid() {
+		return errors.New("refusing to kill protected process")
+	}
+
+	sig, ok := killSignals[signal]
+	if !ok {
+		return fmt.Errorf("unsupported signal `%s`", signal)
+	}
+
+	if !ae.killLimiter.Allow() {
+		atomic.AddInt64(&ae.killDropped, 1)
+		return errors.New("kill rate limit reached")
+	}
+
+	if err := syscall.Kill(int(pid), sig); err != nil {
+		atomic.AddInt64(&ae.killErrors, 1)
+		return err
+	}
+	atomic.AddInt64(&ae.killed, 1)
+
+	log.Infof("process %d killed with %s", pid, signal)
+	return nil
+}
+
+// setTags attaches tags to the events of a process
+func (ae *ActionExecutor) setTags(pid uint32, tags map[string]string) {
+	ae.Lock()
+	defer ae.Unlock()
+
+	current, exists := ae.processTags[pid]
+	if !exists && len(ae.processTags) >= maxTaggedProcesses {
+		log.Debugf("too many tagged processes, ignoring tags of process %d", pid)
+		return
+	}
+
+	// the previous slice may still be used by events being sent, build a new one
+	merged := make([]string, len(current), len(current)+len(tags))
+	copy(merged, current)
+LOOP:
+	for k, v := range tags {
+		tag := k + ":" + v
+		for _, t := range current {
+			if t == tag {
+				continue LOOP
+			}
+		}
+		merged = append(merged, tag)
+	}
+	sort.Strings(merged)
+	ae.processTags[pid] = merged
+}
+
+// GetProcessTags returns the tags attached to the events of a process
+func (ae *ActionExecutor) GetProcessTags(pid uint32) []string {
+	ae.Lock()
+	defer ae.Unlock()
+
+	return ae.processTags[pid]
+}
+
+// startActivityDump starts recording the events of a process
+func (ae *ActionExecutor) startActivityDump(ruleID string, pid uint32, timeout time.Duration) error {
+	ae.Lock()
+	defer ae.Unlock()
+
+	if dump, exists := ae.dumps[pid]; exists {
+		// extend the running dump
+		if deadline := time.Now().Add(timeout); deadline.After(dump.deadline) {
+			dump.deadline = deadline
+		}
+		return nil
+	}
+
+	if len(ae.dumps) >= maxActivityDumps {
+		return errors.New("too many activity dumps running")
+	}
+
+	if err := os.MkdirAll(ae.config.ActivityDumpOutputDir, 0700); err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("%s-%d-%d.json", ruleID, pid, time.Now().Unix())
+	file, err := os.OpenFile(filepath.Join(ae.config.ActivityDumpOutputDir, filename), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	ae.dumps[pid] = &activityDump{
+		file:     file,
+		deadline: time.Now().Add(timeout),
+	}
+	atomic.AddInt64(&ae.dumped, 1)
+
+	log.Infof("activity dump of process %d started in %s", pid, file.Name())
+	return nil
+}
+
+// HandleEvent records the event if its process is being dumped, and forgets about processes that exited
+func (ae *ActionExecutor) HandleEvent(event *sprobe.Event) {
+	pid := event.Process.Pid
+	exited := sprobe.EventType(event.Type) == sprobe.ExitEventType
+
+	ae.Lock()
+	defer ae.Unlock()
+
+	if dump, exists := ae.dumps[pid]; exists {
+		if time.Now().After(dump.deadline) {
+			ae.stopActivityDump(pid, dump)
+		} else {
+			if data, err := marshalEventRecord(event, ae.redactor); err != nil {
+				log.Warnf("failed to record event of process %d: %s", pid, err)
+			} else {
+				data = append(data, '\n')
+				if _, err := dump.file.Write(data); err != nil {
+					log.Warnf("failed to write activity dump of process %d: %s", pid, err)
+					exited = true
+				}
+			}
+			if exited {
+				ae.stopActivityDump(pid, dump)
+			}
+		}
+	}
+
+	if exited {
+		delete(ae.processTags, pid)
+	}
+}
+
+// marshalEventRecord returns the JSON encoding of the record of an event, which can be replayed
+// to simulate rulesets. The recorded event and field values are redacted like the events sent
+// by the event server.
+func marshalEventRecord(event *sprobe.Event, redactor *sprobe.EventRedactor) ([]byte, error) {
+	record, err := sprobe.NewEventRecord(event)
+	if err != nil {
+		return nil, err
+	}
+	if record.Event, err = redactor.Redact(record.Event); err != nil {
+		return nil, err
+	}
+	redactor.RedactFields(record.Fields)
+	return json.Marshal(record)
+}
+
+// stopActivityDump closes the dump of a process. The executor lock must be held.
+func (ae *ActionExecutor) stopActivityDump(pid uint32, dump *activityDump) {
+	if err := dump.file.Close(); err != nil {
+		log.Warnf("failed to close activity dump %s: %s", dump.file.Name(), err)
+	}
+	delete(ae.dumps, pid)
+
+	log.Infof("activity dump of process %d stopped", pid)
+}
+
+// ExpireActivityDumps stops the activity dumps which reached their deadline
+func (ae *ActionExecutor) ExpireActivityDumps(now time.Time) {
+	ae.Lock()
+	defer ae.Unlock()
+
+	for pid, dump := range ae.dumps {
+		if now.After(dump.deadline) {
+			ae.stopActivityDump(pid, dump)
+		}
+	}
+}
+
+// Close stops all the running activity dumps
+func (ae *ActionExecutor) Close() {
+	ae.Lock()
+	defer ae.Unlock()
+
+	for pid, dump := range ae.dumps {
+		ae.stopActivityDump(pid, dump)
+	}
+}
+
+// SendStats sends statistics about the executed actions
+func (ae *ActionExecutor) SendStats(client *statsd.Client) error {
+	if val := atomic.SwapInt64(&ae.killed, 0); val > 0 {
+		if err := client.Count(sprobe.MetricPrefix+".actions.kill", val, []string{"status:success"}, 1.0); err != nil {
+			return err
+		}
+	}
+	if val := atomic.SwapInt64(&ae.killErrors, 0); val > 0 {
+		if err := client.Count(sprobe.MetricPrefix+".actions.kill", val, []string{"status:error"}, 1.0); err != nil {
+			return err
+		}
+	}
+	if val := atomic.SwapInt64(&ae.killDropped, 0); val > 0 {
+		if err := client.Count(sprobe.MetricPrefix+".actions.kill", val, []string{"status:rate_limited"}, 1.0); err != nil {
+			return err
+		}
+	}
+	if val := atomic.SwapInt64(&ae.dumped, 0); val > 0 {
+		if err := client.Count(sprobe.MetricPrefix+".actions.activity_dump", val, nil, 1.0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package module
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-go/statsd"
+
+	"github.com/DataDog/datadog-agent/pkg/security/config"
+	sprobe "github.com/DataDog/datadog-agent/pkg/security/probe"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// AnomalyRuleID is the rule ID of the anomaly events
+	AnomalyRuleID = "anomaly_detection"
+	// Maximum number of workloads profiled at the same time
+	maxActivityProfiles = 1024
+	// Maximum number of distinct values learned per dimension of a profile. Dimensions with more
+	// values are too diverse to be scored.
+	maxProfileValues = 4096
+	// Profiles of the workloads which didn't generate any event for this long are forgotten
+	activityProfileTTL = 24 * time.Hour
+)
+
+// Dimensions of the activity profiles
+const (
+	AnomalyNewBinary   = "new_binary"
+	AnomalyNewPort     = "new_port"
+	AnomalyNewFilePath = "new_file_path"
+)
+
+// Weights of the dimensions in the anomaly score. Binaries of a workload rarely change, while the
+// files it opens are the most diverse.
+var anomalyWeights = map[string]float64{
+	AnomalyNewBinary:   1,
+	AnomalyNewPort:     0.9,
+	AnomalyNewFilePath: 0.7,
+}
+
+// Event fields of the values of the dimensions, according to which their values are redacted
+var anomalyFields = map[string]string{
+	AnomalyNewBinary:   "exec.filename",
+	AnomalyNewFilePath: "open.filename",
+}
+
+// Anomaly describes an event which deviates from the activity profile of its workload
+type Anomaly struct {
+	Workload  string  `json:"workload"`
+	Dimension string  `json:"dimension"`
+	Value     string  `json:"value"`
+	Score     float64 `json:"score"`
+}
+
+// profileDimension holds the values learned for a dimension of a profile
+type profileDimension struct {
+	values       map[string]struct{}
+	observations int64
+}
+
+// stability returns the proportion of the observations which didn't bring a new value. A
+// dimension without any observation is fully stable, the workload never did this kind of activity.
+func (d *profileDimension) stability() float64 {
+	if d.observations == 0 {
+		return 1
+	}
+	return 1 - float64(len(d.values))/float64(d.observations)
+}
+
+// anomalyScore returns the score of a new value of a dimension, between 0 and 1: the stability of
+// the dimension raised to the inverse of its weight. A fully stable dimension scores 1 whatever its
+// weight, while the same instability lowers the score of the dimensions with lower weights more.
+func anomalyScore(dimension string, stability float64) float64 {
+	weight, ok := anomalyWeights[dimension]
+	if !ok || weight <= 0 {
+		return 0
+	}
+	return math.Pow(stability, 1/weight)
+}
+
+// activityProfile holds the activity learned for a workload
+type activityProfile struct {
+	sync.Mutex
+	firstSeen  time.Time
+	lastSeen   time.Time
+	dimensions map[string]*profileDimension
+}
+
+// containerWorkload is the workload of a container, resolved from the tags of its image once
+type containerWorkload struct {
+	workload string
+	image    string
+	lastSeen int64 // unix time in nanoseconds, accessed atomically
+}
+
+// AnomalyDetector learns the activity profiles of the workloads during a learning period, then
+// scores their events against them. An event brings a new value to a dimension of the profile
+// of its workload, such as a binary never executed before, see anomalyScore for its score. An
+// anomaly is reported when the score reaches the threshold of the workload, after which the value
+// is part of the profile. The profiles are locked separately so that the events of different
+// workloads are scored concurrently.
+type AnomalyDetector struct {
+	sync.RWMutex // protects profiles and containers, not their content
+	config       *config.Config
+	profiles     map[string]*activityProfile
+	containers   map[string]*containerWorkload
+
+	anomalies int64
+}
+
+// NewAnomalyDetector returns a new anomaly detector
+func NewAnomalyDetector(cfg *config.Config) *AnomalyDetector {
+	return &AnomalyDetector{
+		config:     cfg,
+		profiles:   make(map[string]*activityProfile),
+		containers: make(map[string]*containerWorkload),
+	}
+}
+
+// eventWorkload returns the workload of an event, made of the name and tag of the image of its
+// container. Events which don't come from a container have no workload. The workloads are cached
+// per container once the image of the container is resolved, so that the tags of the containers
+// are not resolved for each event.
+func (ad *AnomalyDetector) eventWorkload(event *sprobe.Event, now time.Time) (workload string, image string) {
+	containerID := event.Container.ResolveContainerID(event)
+	if len(containerID) == 0 {
+		return "", ""
+	}
+
+	ad.RLock()
+	cw, exists := ad.containers[containerID]
+	ad.RUnlock()
+	if exists {
+		atomic.StoreInt64(&cw.lastSeen, now.UnixNano())
+		return cw.workload, cw.image
+	}
+
+	image = event.Container.ResolveImageName(event)
+	if len(image) == 0 {
+		// the tags of the container may not be known yet
+		return containerID, ""
+	}
+	workload = image
+	if tag := event.Container.ResolveImageTag(event); len(tag) > 0 {
+		workload = image + ":" + tag
+	}
+
+	ad.Lock()
+	ad.containers[containerID] = &containerWorkload{workload: workload, image: image, lastSeen: now.UnixNano()}
+	ad.Unlock()
+
+	return workload, image
+}
+
+// HandleEvent learns or scores the event against the profile of its workload and returns the
+// anomalies found
+func (ad *AnomalyDetector) HandleEvent(event *sprobe.Event) []*Anomaly {
+	eventType := sprobe.EventType(event.Type)
+	if eventType != sprobe.ExecEventType && eventType != sprobe.FileOpenEventType && len(event.Flows.Flows) == 0 {
+		return nil
+	}
+
+	now := event.ResolveEventTimestamp()
+	workload, image := ad.eventWorkload(event, now)
+	if len(workload) == 0 {
+		return nil
+	}
+
+	// the values are resolved only for the events of the workloads
+	var observations [][2]string
+	switch eventType {
+	case sprobe.ExecEventType:
+		observations = append(observations, [2]string{AnomalyNewBinary, event.Exec.ResolveInode(event)})
+	case sprobe.FileOpenEventType:
+		observations = append(observations, [2]string{AnomalyNewFilePath, event.Open.ResolveInode(event)})
+	}
+	for _, flow := range event.Flows.Flows {
+		observations = append(observations, [2]string{AnomalyNewPort, strconv.Itoa(int(flow.Port))})
+	}
+
+	var anomalies []*Anomaly
+	for _, o := range observations {
+		if a := ad.observe(workload, image, o[0], o[1], now); a != nil {
+			anomalies = append(anomalies, a)
+		}
+	}
+	return anomalies
+}
+
+// threshold returns the score from which the anomalies of the workload running the given image
+// are reported
+func (ad *AnomalyDetector) threshold(image string) float64 {
+	if threshold, ok := ad.config.AnomalyDetectionThresholds[image]; ok {
+		return threshold
+	}
+	return ad.config.AnomalyDetectionThreshold
+}
+
+// profile returns the profile of a workload, creating it if needed, or nil if there are too many
+// profiles
+func (ad *AnomalyDetector) profile(workload string, now time.Time) *activityProfile {
+	ad.RLock()
+	profile, exists := ad.profiles[workload]
+	ad.RUnlock()
+	if exists {
+		return profile
+	}
+
+	ad.Lock()
+	defer ad.Unlock()
+
+	if profile, exists = ad.profiles[workload]; exists {
+		return profile
+	}
+	if len(ad.profiles) >= maxActivityProfiles {
+		log.Debugf("too many activity profiles, ignoring workload %s", workload)
+		return nil
+	}
+	profile = &activityProfile{
+		firstSeen:  now,
+		dimensions: make(map[string]*profileDimension),
+	}
+	ad.profiles[workload] = profile
+	return profile
+}
+
+// observe adds a value of a dimension to the profile of a workload, returning an anomaly if the
+// value is new once the profile is learned and its score reaches the threshold of the workload
+func (ad *AnomalyDetector) observe(workload, image, dimension, value string, now time.Time) *Anomaly {
+	if len(value) == 0 {
+		return nil
+	}
+
+	profile := ad.profile(workload, now)
+	if profile == nil {
+		return nil
+	}
+
+	profile.Lock()
+	defer profile.Unlock()
+
+	if now.After(profile.lastSeen) {
+		profile.lastSeen = now
+	}
+
+	dim, exists := profile.dimensions[dimension]
+	if !exists {
+		dim = &profileDimension{values: make(map[string]struct{})}
+		profile.dimensions[dimension] = dim
+	}
+	_, known := dim.values[value]
+	learning := now.Sub(profile.firstSeen) < ad.config.AnomalyDetectionLearningPeriod
+
+	var anomaly *Anomaly
+	if !known && len(dim.values) < maxProfileValues {
+		if !learning {
+			score := anomalyScore(dimension, dim.stability())
+			if score >= ad.threshold(image) {
+				anomaly = &Anomaly{
+					Workload:  workload,
+					Dimension: dimension,
+					Value:     value,
+					Score:     score,
+				}
+				atomic.AddInt64(&ad.anomalies, 1)
+			}
+		}
+		dim.values[value] = struct{}{}
+	}
+	dim.observations++
+
+	return anomaly
+}
+
+// ExpireProfiles forgets the profiles of the workloads, and the workloads of the containers, which
+// stopped generating events
+func (ad *AnomalyDetector) ExpireProfiles(now time.Time) {
+	ad.Lock()
+	defer ad.Unlock()
+
+	for workload, profile := range ad.profiles {
+		profile.Lock()
+		expired := now.Sub(profile.lastSeen) > activityProfileTTL
+		profile.Unlock()
+		if expired {
+			delete(ad.profiles, workload)
+		}
+	}
+
+	for containerID, cw := range ad.containers {
+		if now.Sub(time.Unix(0, atomic.LoadInt64(&cw.lastSeen))) > activityProfileTTL {
+			delete(ad.containers, containerID)
+		}
+	}
+}
+
+// SendStats sends statistics about the anomalies and the profiles
+func (ad *AnomalyDetector) SendStats(client *statsd.Client) error {
+	if val := atomic.SwapInt64(&ad.anomalies, 0); val > 0 {
+		if err := client.Count(sprobe.MetricPrefix+".anomaly_detection.anomalies", val, nil, 1.0); err != nil {
+			return err
+		}
+	}
+
+	ad.RLock()
+	profiles := len(ad.profiles)
+	ad.RUnlock()
+
+	return client.Gauge(sprobe.MetricPrefix+".anomaly_detection.profiles", float64(profiles), nil, 1.0)
+}
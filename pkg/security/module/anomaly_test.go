@@ -0,0 +1,154 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package module
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/security/config"
+)
+
+func newTestAnomalyDetector() *AnomalyDetector {
+	return NewAnomalyDetector(&config.Config{
+		AnomalyDetectionLearningPeriod: time.Hour,
+		AnomalyDetectionThreshold:      0.9,
+		AnomalyDetectionThresholds:     map[string]float64{"nginx": 1.1},
+	})
+}
+
+func TestAnomalyScore(t *testing.T) {
+	for _, dimension := range []string{AnomalyNewBinary, AnomalyNewPort, AnomalyNewFilePath} {
+		if score := anomalyScore(dimension, 1); score != 1 {
+			t.Errorf("expected a stable %s dimension to score 1, got %f", dimension, score)
+		}
+		if score := anomalyScore(dimension, 0); score != 0 {
+			t.Errorf("expected an unstable %s dimension to score 0, got %f", dimension, score)
+		}
+	}
+
+	binary := anomalyScore(AnomalyNewBinary, 0.95)
+	port := anomalyScore(AnomalyNewPort, 0.95)
+	filePath := anomalyScore(AnomalyNewFilePath, 0.95)
+	if !(binary > port && port > filePath) {
+		t.Errorf("expected binaries to score higher than ports, and ports than file paths: %f, %f, %f", binary, port, filePath)
+	}
+
+	if score := anomalyScore("unknown", 1); score != 0 {
+		t.Errorf("expected an unknown dimension to score 0, got %f", score)
+	}
+}
+
+func TestAnomalyDetectorDimensions(t *testing.T) {
+	for _, dimension := range []string{AnomalyNewBinary, AnomalyNewPort, AnomalyNewFilePath} {
+		t.Run(dimension, func(t *testing.T) {
+			ad := newTestAnomalyDetector()
+			start := time.Now()
+
+			// the activity of the workload is learned
+			for i := 0; i < 100; i++ {
+				if anomaly := ad.observe("redis:6", "redis", dimension, "known", start.Add(time.Duration(i)*time.Second)); anomaly != nil {
+					t.Fatalf("unexpected anomaly while learning: %+v", anomaly)
+				}
+			}
+			if anomaly := ad.observe("redis:6", "redis", dimension, "learned", start.Add(time.Minute)); anomaly != nil {
+				t.Fatalf("unexpected anomaly while learning: %+v", anomaly)
+			}
+
+			// a new value of a stable dimension is reported once the profile is learned
+			now := start.Add(2 * time.Hour)
+			if anomaly := ad.observe("redis:6", "redis", dimension, "known", now); anomaly != nil {
+				t.Errorf("unexpected anomaly for a known value: %+v", anomaly)
+			}
+			if anomaly := ad.observe("redis:6", "redis", dimension, "learned", now); anomaly != nil {
+				t.Errorf("unexpected anomaly for a value learned: %+v", anomaly)
+			}
+
+			anomaly := ad.observe("redis:6", "redis", dimension, "new", now)
+			if anomaly == nil {
+				t.Fatal("expected an anomaly for a new value")
+			}
+			expected := math.Pow(1-2.0/103, 1/anomalyWeights[dimension])
+			if anomaly.Workload != "redis:6" || anomaly.Dimension != dimension || anomaly.Value != "new" || math.Abs(anomaly.Score-expected) > 1e-9 {
+				t.Errorf("unexpected anomaly %+v, expected a score of %f", anomaly, expected)
+			}
+
+			// the value is part of the profile once reported
+			if anomaly := ad.observe("redis:6", "redis", dimension, "new", now); anomaly != nil {
+				t.Errorf("unexpected anomaly for a value reported: %+v", anomaly)
+			}
+
+			// the threshold of the image applies
+			ad.observe("nginx:1", "nginx", dimension, "known", start)
+			if anomaly := ad.observe("nginx:1", "nginx", dimension, "new", now); anomaly != nil {
+				t.Errorf("unexpected anomaly under the threshold of the image: %+v", anomaly)
+			}
+		})
+	}
+}
+
+func TestAnomalyDetectorUnstableDimension(t *testing.T) {
+	ad := newTestAnomalyDetector()
+	start := time.Now()
+
+	for i := 0; i < 50; i++ {
+		ad.observe("app", "", AnomalyNewFilePath, fmt.Sprintf("/tmp/file-%d", i), start)
+	}
+	if anomaly := ad.observe("app", "", AnomalyNewFilePath, "/tmp/new", start.Add(2*time.Hour)); anomaly != nil {
+		t.Errorf("unexpected anomaly for an unstable dimension: %+v", anomaly)
+	}
+}
+
+func TestAnomalyDetectorConcurrency(t *testing.T) {
+	ad := newTestAnomalyDetector()
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				ad.observe(fmt.Sprintf("workload-%d", j%4), "", AnomalyNewBinary, fmt.Sprintf("/bin/%d", i), start)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(ad.profiles) != 4 {
+		t.Fatalf("expected 4 profiles, got %d", len(ad.profiles))
+	}
+	for workload, profile := range ad.profiles {
+		if dim := profile.dimensions[AnomalyNewBinary]; dim.observations != 2000 || len(dim.values) != 8 {
+			t.Errorf("unexpected dimension of %s: %d observations, %d values", workload, dim.observations, len(dim.values))
+		}
+	}
+}
+
+func TestAnomalyDetectorExpireProfiles(t *testing.T) {
+	ad := newTestAnomalyDetector()
+	start := time.Now()
+
+	ad.observe("old", "", AnomalyNewBinary, "/bin/sh", start)
+	ad.observe("recent", "", AnomalyNewBinary, "/bin/sh", start.Add(activityProfileTTL))
+	ad.containers["abc"] = &containerWorkload{workload: "old", lastSeen: start.UnixNano()}
+
+	ad.ExpireProfiles(start.Add(activityProfileTTL + time.Minute))
+	if _, exists := ad.profiles["old"]; exists {
+		t.Error("expected the profile of the old workload to be expired")
+	}
+	if _, exists := ad.profiles["recent"]; !exists {
+		t.Error("expected the profile of the recent workload to be kept")
+	}
+	if _, exists := ad.containers["abc"]; exists {
+		t.Error("expected the workload of the container to be expired")
+	}
+}
@@ -46,6 +46,8 @@ type Module struct {
 	listener       net.Listener
 	statsdClient   *statsd.Client
 	rateLimiter    *RateLimiter
+	actions        *ActionExecutor
+	anomalies      *AnomalyDetector // nil if anomaly detection is disabled
 	sigupChan      chan os.Signal
 }
 
@@ -135,6 +137,9 @@ func (m *Module) Reload() error {
 
 	ruleSet.AddListener(m)
 	ruleIDs := ruleSet.ListRuleIDs()
+	if m.anomalies != nil {
+		ruleIDs = append(ruleIDs, AnomalyRuleID)
+	}
 
 	m.eventServer.Apply(ruleIDs)
 	m.rateLimiter.Apply(ruleIDs)
@@ -161,12 +166,19 @@ func (m *Module) Close() {
 	}
 
 	m.probe.Close()
+	m.actions.Close()
 }
 
 // RuleMatch is called by the ruleset when a rule matches
 func (m *Module) RuleMatch(rule *eval.Rule, event eval.Event) {
+	if ruleSet := m.GetRuleSet(); ruleSet != nil {
+		if actions := ruleSet.GetRuleActions(rule.ID); len(actions) != 0 {
+			m.actions.Execute(rule, actions, event.(*sprobe.Event))
+		}
+	}
+
 	if m.rateLimiter.Allow(rule.ID) {
-		m.eventServer.SendEvent(rule, event)
+		m.eventServer.SendEvent(rule, event, m.actions.GetProcessTags(event.(*sprobe.Event).Process.Pid)...)
 	} else {
 		log.Tracef("Event on rule %s was dropped due to rate limiting", rule.ID)
 	}
@@ -184,6 +196,18 @@ func (m *Module) HandleEvent(event *sprobe.Event) {
 	if ruleSet := m.ruleSets[atomic.LoadUint64(&m.currentRuleSet)]; ruleSet != nil {
 		ruleSet.Evaluate(event)
 	}
+
+	m.actions.HandleEvent(event)
+
+	if m.anomalies != nil {
+		for _, anomaly := range m.anomalies.HandleEvent(event) {
+			if m.rateLimiter.Allow(AnomalyRuleID) {
+				m.eventServer.SendAnomaly(anomaly, event, m.actions.GetProcessTags(event.Process.Pid)...)
+			} else {
+				log.Tracef("Anomaly of workload %s was dropped due to rate limiting", anomaly.Workload)
+			}
+		}
+	}
 }
 
 func (m *Module) statsMonitor(ctx context.Context) {
@@ -205,6 +229,16 @@ func (m *Module) statsMonitor(ctx context.Context) {
 			if err := m.eventServer.SendStats(m.statsdClient); err != nil {
 				log.Debug(err)
 			}
+			if err := m.actions.SendStats(m.statsdClient); err != nil {
+				log.Debug(err)
+			}
+			m.actions.ExpireActivityDumps(time.Now())
+			if m.anomalies != nil {
+				if err := m.anomalies.SendStats(m.statsdClient); err != nil {
+					log.Debug(err)
+				}
+				m.anomalies.ExpireProfiles(time.Now())
+			}
 		case <-ctx.Done():
 			return
 		}
@@ -255,17 +289,27 @@ func NewModule(cfg *config.Config) (api.Module, error) {
 		return nil, err
 	}
 
+	redactor, err := sprobe.NewEventRedactor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	m := &Module{
 		config:         cfg,
 		probe:          probe,
-		eventServer:    NewEventServer(cfg),
+		eventServer:    NewEventServer(cfg, redactor),
 		grpcServer:     grpc.NewServer(),
 		statsdClient:   statsdClient,
 		rateLimiter:    NewRateLimiter(),
+		actions:        NewActionExecutor(cfg, redactor),
 		sigupChan:      make(chan os.Signal, 1),
 		currentRuleSet: 1,
 	}
 
+	if cfg != nil && cfg.AnomalyDetection {
+		m.anomalies = NewAnomalyDetector(cfg)
+	}
+
 	sapi.RegisterSecurityModuleServer(m.grpcServer, m.eventServer)
 
 	return m, nil
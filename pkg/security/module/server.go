@@ -32,6 +32,7 @@ type EventServer struct {
 	msgs          chan *api.SecurityEventMessage
 	expiredEvents map[rules.RuleID]*int64
 	rate          *Limiter
+	redactor      *sprobe.EventRedactor
 }
 
 // GetEvents waits for security events
@@ -65,23 +66,67 @@ LOOP:
 	return nil
 }
 
-// SendEvent forwards events sent by the runtime security module to Datadog
-func (e *EventServer) SendEvent(rule *eval.Rule, event eval.Event) {
+// SendEvent forwards events sent by the runtime security module to Datadog, with the given extra tags
+func (e *EventServer) SendEvent(rule *eval.Rule, event eval.Event, extraTags ...string) {
 	data, err := json.Marshal(rules.RuleEvent{Event: event, RuleID: rule.ID})
 	if err != nil {
 		return
 	}
+	if data, err = e.redactor.Redact(data); err != nil {
+		log.Errorf("Failed to redact event for rule `%s`: %v", rule.ID, err)
+		return
+	}
 	tags := append(rule.Tags, "rule_id:"+rule.ID)
 	tags = append(tags, event.(*sprobe.Event).GetTags()...)
+	tags = append(tags, extraTags...)
 	log.Tracef("Sending event message for rule `%s` to security-agent `%s` with tags %v", rule.ID, string(data), tags)
 
-	msg := &api.SecurityEventMessage{
+	e.sendMessage(&api.SecurityEventMessage{
 		RuleID: rule.ID,
 		Type:   event.GetType(),
 		Tags:   tags,
 		Data:   data,
+	})
+}
+
+// anomalyEvent is the payload of the events reporting anomalies
+type anomalyEvent struct {
+	RuleID  string        `json:"rule_id"`
+	Event   *sprobe.Event `json:"event"`
+	Anomaly *Anomaly      `json:"anomaly"`
+}
+
+// SendAnomaly forwards an event which deviates from the activity profile of its workload to Datadog
+func (e *EventServer) SendAnomaly(anomaly *Anomaly, event *sprobe.Event, extraTags ...string) {
+	// the value of the anomaly is redacted like the event field it comes from
+	redacted := *anomaly
+	if field, ok := anomalyFields[anomaly.Dimension]; ok {
+		redacted.Value = e.redactor.RedactField(field, anomaly.Value)
 	}
 
+	data, err := json.Marshal(anomalyEvent{RuleID: AnomalyRuleID, Event: event, Anomaly: &redacted})
+	if err != nil {
+		return
+	}
+	if data, err = e.redactor.Redact(data); err != nil {
+		log.Errorf("Failed to redact anomaly event: %v", err)
+		return
+	}
+	tags := []string{"rule_id:" + AnomalyRuleID, "anomaly_dimension:" + anomaly.Dimension}
+	tags = append(tags, event.GetTags()...)
+	tags = append(tags, extraTags...)
+	log.Tracef("Sending anomaly event to security-agent `%s` with tags %v", string(data), tags)
+
+	e.sendMessage(&api.SecurityEventMessage{
+		RuleID: AnomalyRuleID,
+		Type:   event.GetType(),
+		Tags:   tags,
+		Data:   data,
+	})
+}
+
+// sendMessage queues a message, dropping the oldest one if the queue is full
+func (e *EventServer) sendMessage(msg *api.SecurityEventMessage) {
 	select {
 	case e.msgs <- msg:
 		break
@@ -152,12 +197,13 @@ func (e *EventServer) Apply(ruleIDs []rules.RuleID) {
 	}
 }
 
-// NewEventServer returns a new gRPC event server
-func NewEventServer(cfg *config.Config) *EventServer {
+// NewEventServer returns a new gRPC event server, redacting the events with redactor when it is set
+func NewEventServer(cfg *config.Config, redactor *sprobe.EventRedactor) *EventServer {
 	es := &EventServer{
 		msgs:          make(chan *api.SecurityEventMessage, cfg.EventServerBurst*3),
 		expiredEvents: make(map[rules.RuleID]*int64),
 		rate:          NewLimiter(rate.Limit(cfg.EventServerRate), cfg.EventServerBurst),
+		redactor:      redactor,
 	}
 	return es
 }
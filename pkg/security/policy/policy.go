@@ -68,6 +68,12 @@ func LoadPolicy(r io.Reader) (*Policy, error) {
 		if ruleDef.Expression == "" {
 			return nil, errors.New("rule has no expression")
 		}
+
+		for i := range ruleDef.Actions {
+			if err := ruleDef.Actions[i].Check(); err != nil {
+				return nil, errors.Wrapf(err, "invalid action for rule `%s`", ruleDef.ID)
+			}
+		}
 	}
 
 	return policy, nil
@@ -23,6 +23,10 @@ var (
 	kernel4_13 = kernel.VersionCode(4, 13, 0) //nolint:deadcode,unused
 )
 
+// auditIDUnset is the value of the audit login UID and session ID of the processes which are not
+// part of a login session (AUDIT_UID_UNSET)
+const auditIDUnset = ^uint32(0)
+
 // EventType describes the type of an event sent from the kernel
 type EventType uint64
 
@@ -63,6 +67,12 @@ const (
 	ExitEventType
 	// InvalidateDentryEventType - Dentry invalidated event
 	InvalidateDentryEventType
+	// ExecArgsEventType - Exec arguments event
+	ExecArgsEventType
+	// NamespaceEventType - Namespace creation event (unshare, setns, clone)
+	NamespaceEventType
+	// CgroupWriteEventType - Cgroup release_agent or notify_on_release write event
+	CgroupWriteEventType
 	// internalEventType - used internally to get the maximum number of event. Has to be the last one
 	maxEventType //nolint:deadcode,unused
 )
@@ -106,6 +116,12 @@ func (t EventType) String() string {
 		return "exit"
 	case InvalidateDentryEventType:
 		return "invalidate_dentry"
+	case ExecArgsEventType:
+		return "exec_args"
+	case NamespaceEventType:
+		return "namespace"
+	case CgroupWriteEventType:
+		return "cgroup_write"
 	}
 	return "unknown"
 }
@@ -318,6 +334,16 @@ var (
 		"AT_REMOVEDIR": unix.AT_REMOVEDIR,
 	}
 
+	namespaceTypesConstants = map[string]int{
+		"CLONE_NEWNS":     unix.CLONE_NEWNS,
+		"CLONE_NEWCGROUP": unix.CLONE_NEWCGROUP,
+		"CLONE_NEWUTS":    unix.CLONE_NEWUTS,
+		"CLONE_NEWIPC":    unix.CLONE_NEWIPC,
+		"CLONE_NEWUSER":   unix.CLONE_NEWUSER,
+		"CLONE_NEWPID":    unix.CLONE_NEWPID,
+		"CLONE_NEWNET":    unix.CLONE_NEWNET,
+	}
+
 	// SECLConstants are constants available in runtime security agent rules
 	SECLConstants = map[string]interface{}{
 		// boolean
@@ -327,9 +353,10 @@ var (
 )
 
 var (
-	openFlagsStrings   = map[int]string{}
-	chmodModeStrings   = map[int]string{}
-	unlinkFlagsStrings = map[int]string{}
+	openFlagsStrings      = map[int]string{}
+	chmodModeStrings      = map[int]string{}
+	unlinkFlagsStrings    = map[int]string{}
+	namespaceTypesStrings = map[int]string{}
 )
 
 func initOpenConstants() {
@@ -362,6 +389,16 @@ func initUnlinkConstanst() {
 	}
 }
 
+func initNamespaceConstants() {
+	for k, v := range namespaceTypesConstants {
+		SECLConstants[k] = &eval.IntEvaluator{Value: v}
+	}
+
+	for k, v := range namespaceTypesConstants {
+		namespaceTypesStrings[v] = k
+	}
+}
+
 func initErrorConstants() {
 	for k, v := range errorConstants {
 		SECLConstants[k] = &eval.IntEvaluator{Value: v}
@@ -373,6 +410,7 @@ func initConstants() {
 	initOpenConstants()
 	initChmodConstants()
 	initUnlinkConstanst()
+	initNamespaceConstants()
 }
 
 func bitmaskToString(bitmask int, intToStrMap map[int]string) string {
@@ -423,6 +461,13 @@ func (f UnlinkFlags) String() string {
 	return bitmaskToString(int(f), unlinkFlagsStrings)
 }
 
+// NamespaceTypes represents a bitmask of namespace types
+type NamespaceTypes int
+
+func (t NamespaceTypes) String() string {
+	return bitmaskToString(int(t), namespaceTypesStrings)
+}
+
 // RetValError represents a syscall return error value
 type RetValError int
 
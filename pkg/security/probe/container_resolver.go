@@ -8,11 +8,78 @@
 package probe
 
 import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/DataDog/datadog-agent/pkg/security/utils"
+	"github.com/DataDog/datadog-agent/pkg/tagger"
+	"github.com/DataDog/datadog-agent/pkg/tagger/collectors"
+	"github.com/DataDog/datadog-agent/pkg/util/containers"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// Maximum number of containers waiting for their tags to be resolved
+	containerTagsQueueSize = 256
+	// Age after which the tags of a container are resolved again. The tags of the containers that
+	// weren't refreshed for twice this period are forgotten.
+	containerTagsTTL = 5 * time.Minute
 )
 
+// containerTagger returns the tags of an entity
+type containerTagger interface {
+	Tag(entity string, cardinality collectors.TagCardinality) ([]string, error)
+}
+
+type containerTags struct {
+	tags       []string
+	resolvedAt time.Time
+}
+
 // ContainerResolver is used to resolve the container context of the events
-type ContainerResolver struct{}
+type ContainerResolver struct {
+	sync.RWMutex
+	tagger     containerTagger
+	initTagger func()
+	initOnce   sync.Once
+	tags       map[string]*containerTags
+	pending    map[string]bool
+	queue      chan string
+}
+
+// NewContainerResolver returns a new container resolver using the default tagger
+func NewContainerResolver() *ContainerResolver {
+	return newContainerResolver(tagger.GetDefaultTagger(), tagger.Init)
+}
+
+func newContainerResolver(tagger containerTagger, initTagger func()) *ContainerResolver {
+	return &ContainerResolver{
+		tagger:     tagger,
+		initTagger: initTagger,
+		tags:       make(map[string]*containerTags),
+		pending:    make(map[string]bool),
+		queue:      make(chan string, containerTagsQueueSize),
+	}
+}
+
+// Start resolves the tags of the containers queued by ResolveTags, until ctx is done
+func (cr *ContainerResolver) Start(ctx context.Context) {
+	ticker := time.NewTicker(containerTagsTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case containerID := <-cr.queue:
+			cr.resolveTags(containerID, time.Now())
+		case now := <-ticker.C:
+			cr.expireTags(now)
+		}
+	}
+}
 
 // GetContainerID returns the container id of the given pid
 func (cr *ContainerResolver) GetContainerID(pid uint32) (utils.ContainerID, error) {
@@ -20,8 +87,88 @@ func (cr *ContainerResolver) GetContainerID(pid uint32) (utils.ContainerID, erro
 	return utils.GetProcContainerID(pid, pid)
 }
 
-// ResolveLabels resolves the label of a container from its container ID
-func (cr *ContainerResolver) ResolveLabels(containerID string) ([]string, error) {
-	// Do not use the tagger for now
-	return []string{}, nil
+// ResolveTags returns the tags of a container from its container ID. The tags include the image
+// of the container and the Kubernetes workload it belongs to. They are resolved in the background
+// so that the tagger doesn't slow down the handling of the events, which means that the first
+// events of a container have no tags.
+func (cr *ContainerResolver) ResolveTags(containerID string) []string {
+	if len(containerID) == 0 || cr.tagger == nil {
+		return nil
+	}
+
+	cr.RLock()
+	entry, exists := cr.tags[containerID]
+	cr.RUnlock()
+
+	if exists && time.Since(entry.resolvedAt) < containerTagsTTL {
+		return entry.tags
+	}
+
+	cr.queueContainer(containerID)
+
+	if exists {
+		return entry.tags
+	}
+	return nil
+}
+
+// queueContainer queues a container so that its tags are resolved in the background
+func (cr *ContainerResolver) queueContainer(containerID string) {
+	cr.Lock()
+	defer cr.Unlock()
+
+	if cr.pending[containerID] {
+		return
+	}
+
+	select {
+	case cr.queue <- containerID:
+		cr.pending[containerID] = true
+	default:
+		// the queue is full, the container will be queued again by one of its next events
+	}
+}
+
+// resolveTags resolves the tags of a container. The tagger, which collects the tags from the container
+// runtime and the orchestrator, is only initialized once the tags of a container are needed.
+func (cr *ContainerResolver) resolveTags(containerID string, now time.Time) {
+	if cr.initTagger != nil {
+		cr.initOnce.Do(cr.initTagger)
+	}
+
+	tags, err := cr.tagger.Tag(containers.BuildTaggerEntityName(containerID), collectors.OrchestratorCardinality)
+	if err != nil {
+		log.Debugf("failed to resolve the tags of container %s: %v", containerID, err)
+	}
+
+	cr.Lock()
+	defer cr.Unlock()
+
+	delete(cr.pending, containerID)
+	if err == nil {
+		cr.tags[containerID] = &containerTags{tags: tags, resolvedAt: now}
+	}
+}
+
+// expireTags forgets the tags of the containers that weren't refreshed recently
+func (cr *ContainerResolver) expireTags(now time.Time) {
+	cr.Lock()
+	defer cr.Unlock()
+
+	for containerID, entry := range cr.tags {
+		if now.Sub(entry.resolvedAt) > 2*containerTagsTTL {
+			delete(cr.tags, containerID)
+		}
+	}
+}
+
+// getTagValue returns the value of the first tag with the given name
+func getTagValue(tags []string, name string) string {
+	prefix := name + ":"
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, prefix) {
+			return tag[len(prefix):]
+		}
+	}
+	return ""
 }
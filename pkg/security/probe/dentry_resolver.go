@@ -10,8 +10,11 @@ package probe
 import (
 	"C"
 	"fmt"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
+	"github.com/DataDog/datadog-go/statsd"
 	lib "github.com/DataDog/ebpf"
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/pkg/errors"
@@ -26,9 +29,27 @@ const (
 
 // DentryResolver resolves inode/mountID to full paths
 type DentryResolver struct {
+	// counters are kept first for the 64 bit alignment required by atomic operations
+	hits             int64
+	misses           int64
+	negativeHits     int64
+	notFoundErrors   int64
+	invalidKeyErrors int64
+
 	probe     *Probe
 	pathnames *lib.Map
 	cache     *lru.Cache
+	cacheTTL  time.Duration
+
+	// negativeCache holds the expiration time of the path keys which could not be resolved
+	negativeCache *lru.Cache
+	negativeTTL   time.Duration
+}
+
+// dentryCacheEntry is an entry of the user space dentry cache
+type dentryCacheEntry struct {
+	path       PathValue
+	insertedAt time.Time
 }
 
 // ErrInvalidKeyPath is returned when inode or mountid are not valid
@@ -87,14 +108,50 @@ func (dr *DentryResolver) DelCacheEntry(mountID uint32, inode uint64) {
 	dr.cache.Remove(key)
 }
 
+// lookupCache returns the cached entry of a path key, ignoring the entries older than the cache TTL
+func (dr *DentryResolver) lookupCache(key PathKey, now time.Time) (PathValue, bool) {
+	entry, exists := dr.cache.Get(key)
+	if !exists {
+		return PathValue{}, false
+	}
+	cached := entry.(dentryCacheEntry)
+	if dr.cacheTTL > 0 && now.Sub(cached.insertedAt) > dr.cacheTTL {
+		dr.cache.Remove(key)
+		return PathValue{}, false
+	}
+	return cached.path, true
+}
+
+// isUnresolvable returns whether a path key was recently found not to be resolvable
+func (dr *DentryResolver) isUnresolvable(key PathKey, now time.Time) bool {
+	if dr.negativeCache == nil {
+		return false
+	}
+	entry, exists := dr.negativeCache.Get(key)
+	if !exists {
+		return false
+	}
+	if now.After(entry.(time.Time)) {
+		dr.negativeCache.Remove(key)
+		return false
+	}
+	return true
+}
+
+// markUnresolvable remembers that a path key could not be resolved
+func (dr *DentryResolver) markUnresolvable(key PathKey, now time.Time) {
+	if dr.negativeCache != nil {
+		dr.negativeCache.Add(key, now.Add(dr.negativeTTL))
+	}
+}
+
 func (dr *DentryResolver) getNameFromCache(mountID uint32, inode uint64) (name string, err error) {
 	key := PathKey{MountID: mountID, Inode: inode}
 
-	entry, exists := dr.cache.Get(key)
+	path, exists := dr.lookupCache(key, time.Now())
 	if !exists {
 		return "", ErrEntryNotFound
 	}
-	path := entry.(PathValue)
 
 	return C.GoString((*C.char)(unsafe.Pointer(&path.Name))), nil
 }
@@ -104,6 +161,7 @@ func (dr *DentryResolver) getNameFromMap(mountID uint32, inode uint64, pathID ui
 	var path PathValue
 
 	if err := dr.pathnames.Lookup(key, &path); err != nil {
+		atomic.AddInt64(&dr.notFoundErrors, 1)
 		return "", fmt.Errorf("unable to get filename for mountID `%d` and inode `%d`", mountID, inode)
 	}
 
@@ -122,17 +180,16 @@ func (dr *DentryResolver) GetName(mountID uint32, inode uint64, pathID uint32) s
 // ResolveFromCache resolve from the cache
 func (dr *DentryResolver) ResolveFromCache(mountID uint32, inode uint64) (filename string, err error) {
 	key := PathKey{MountID: mountID, Inode: inode}
-	var path PathValue
+	now := time.Now()
 
 	// Fetch path recursively
 	for {
 		cacheKey := PathKey{MountID: key.MountID, Inode: key.Inode}
 
-		entry, exists := dr.cache.Get(cacheKey)
+		path, exists := dr.lookupCache(cacheKey, now)
 		if !exists {
 			return "", ErrEntryNotFound
 		}
-		path = entry.(PathValue)
 
 		// Don't append dentry name if this is the root dentry (i.d. name == '/')
 		if path.Name[0] != '\x00' && path.Name[0] != '/' {
@@ -161,9 +218,16 @@ func (dr *DentryResolver) ResolveFromMap(mountID uint32, inode uint64, pathID ui
 
 	keyBuffer, err := key.MarshalBinary()
 	if err != nil {
+		atomic.AddInt64(&dr.invalidKeyErrors, 1)
 		return "", err
 	}
 
+	now := time.Now()
+	if dr.isUnresolvable(key, now) {
+		atomic.AddInt64(&dr.negativeHits, 1)
+		return dentryPathKeyNotFound, ErrEntryNotFound
+	}
+
 	toAdd := make(map[PathKey]PathValue)
 
 	// Fetch path recursively
@@ -171,6 +235,8 @@ func (dr *DentryResolver) ResolveFromMap(mountID uint32, inode uint64, pathID ui
 		key.Write(keyBuffer)
 		if err = dr.pathnames.Lookup(keyBuffer, &path); err != nil {
 			filename = dentryPathKeyNotFound
+			atomic.AddInt64(&dr.notFoundErrors, 1)
+			dr.markUnresolvable(PathKey{MountID: mountID, Inode: inode, PathID: pathID}, now)
 			break
 		}
 
@@ -198,7 +264,7 @@ func (dr *DentryResolver) ResolveFromMap(mountID uint32, inode uint64, pathID ui
 		for k, v := range toAdd {
 			// do not cache fake path keys in the case of rename events
 			if k.Inode>>32 != fakeInodeMSW {
-				dr.cache.Add(k, v)
+				dr.cache.Add(k, dentryCacheEntry{path: v, insertedAt: now})
 			}
 		}
 	}
@@ -210,7 +276,10 @@ func (dr *DentryResolver) ResolveFromMap(mountID uint32, inode uint64, pathID ui
 func (dr *DentryResolver) Resolve(mountID uint32, inode uint64, pathID uint32) string {
 	path, err := dr.ResolveFromCache(mountID, inode)
 	if err != nil {
+		atomic.AddInt64(&dr.misses, 1)
 		path, _ = dr.ResolveFromMap(mountID, inode, pathID)
+	} else {
+		atomic.AddInt64(&dr.hits, 1)
 	}
 	return path
 }
@@ -218,11 +287,10 @@ func (dr *DentryResolver) Resolve(mountID uint32, inode uint64, pathID uint32) s
 func (dr *DentryResolver) getParentFromCache(mountID uint32, inode uint64) (uint32, uint64, error) {
 	key := PathKey{MountID: mountID, Inode: inode}
 
-	entry, exists := dr.cache.Get(key)
+	path, exists := dr.lookupCache(key, time.Now())
 	if !exists {
 		return 0, 0, ErrEntryNotFound
 	}
-	path := entry.(PathValue)
 
 	return path.Parent.MountID, path.Parent.Inode, nil
 }
@@ -258,18 +326,50 @@ func (dr *DentryResolver) Start() error {
 	}
 	dr.pathnames = pathnames
 
-	cache, err := lru.New(128)
-	if err != nil {
-		return err
-	}
-	dr.cache = cache
+	return nil
+}
 
+// SendStats sends the dentry resolver metrics
+func (dr *DentryResolver) SendStats(statsdClient *statsd.Client) error {
+	for _, counter := range []struct {
+		metric string
+		value  *int64
+		tags   []string
+	}{
+		{metric: ".dentry_resolver.hits", value: &dr.hits, tags: []string{"cache:dentry"}},
+		{metric: ".dentry_resolver.hits", value: &dr.negativeHits, tags: []string{"cache:negative"}},
+		{metric: ".dentry_resolver.misses", value: &dr.misses},
+		{metric: ".dentry_resolver.failures", value: &dr.notFoundErrors, tags: []string{"reason:not_found"}},
+		{metric: ".dentry_resolver.failures", value: &dr.invalidKeyErrors, tags: []string{"reason:invalid_key"}},
+	} {
+		if value := atomic.SwapInt64(counter.value, 0); value > 0 {
+			if err := statsdClient.Count(MetricPrefix+counter.metric, value, counter.tags, 1.0); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
 // NewDentryResolver returns a new dentry resolver
 func NewDentryResolver(probe *Probe) (*DentryResolver, error) {
+	cache, err := lru.New(probe.config.DentryCacheSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid dentry cache size")
+	}
+
+	var negativeCache *lru.Cache
+	if probe.config.DentryNegativeCacheSize > 0 {
+		if negativeCache, err = lru.New(probe.config.DentryNegativeCacheSize); err != nil {
+			return nil, err
+		}
+	}
+
 	return &DentryResolver{
-		probe: probe,
+		probe:         probe,
+		cache:         cache,
+		cacheTTL:      probe.config.DentryCacheTTL,
+		negativeCache: negativeCache,
+		negativeTTL:   probe.config.DentryNegativeCacheTTL,
 	}, nil
 }
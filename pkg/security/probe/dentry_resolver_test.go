@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/security/config"
+)
+
+func newTestDentryResolver(t *testing.T, cfg *config.Config) *DentryResolver {
+	dr, err := NewDentryResolver(&Probe{config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dr
+}
+
+func pathValue(name string, parent PathKey) PathValue {
+	value := PathValue{Parent: parent}
+	copy(value.Name[:], name)
+	return value
+}
+
+func TestDentryResolverCache(t *testing.T) {
+	dr := newTestDentryResolver(t, &config.Config{
+		DentryCacheSize: 16,
+		DentryCacheTTL:  time.Minute,
+	})
+
+	now := time.Now()
+	root := PathKey{MountID: 1, Inode: 2}
+	etc := PathKey{MountID: 1, Inode: 10}
+	passwd := PathKey{MountID: 1, Inode: 11}
+	dr.cache.Add(root, dentryCacheEntry{path: pathValue("/", PathKey{}), insertedAt: now})
+	dr.cache.Add(etc, dentryCacheEntry{path: pathValue("etc", root), insertedAt: now})
+	dr.cache.Add(passwd, dentryCacheEntry{path: pathValue("passwd", etc), insertedAt: now.Add(-2 * time.Minute)})
+
+	path, err := dr.ResolveFromCache(etc.MountID, etc.Inode)
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc", path)
+
+	name, err := dr.getNameFromCache(etc.MountID, etc.Inode)
+	assert.NoError(t, err)
+	assert.Equal(t, "etc", name)
+
+	// expired entries are evicted
+	_, err = dr.ResolveFromCache(passwd.MountID, passwd.Inode)
+	assert.Equal(t, ErrEntryNotFound, err)
+	assert.False(t, dr.cache.Contains(passwd))
+
+	dr.DelCacheEntry(etc.MountID, etc.Inode)
+	_, _, err = dr.getParentFromCache(etc.MountID, etc.Inode)
+	assert.Equal(t, ErrEntryNotFound, err)
+}
+
+func TestDentryResolverNegativeCache(t *testing.T) {
+	key := PathKey{MountID: 1, Inode: 42, PathID: 3}
+	now := time.Now()
+
+	t.Run("enabled", func(t *testing.T) {
+		dr := newTestDentryResolver(t, &config.Config{
+			DentryCacheSize:         16,
+			DentryNegativeCacheSize: 16,
+			DentryNegativeCacheTTL:  2 * time.Second,
+		})
+
+		assert.False(t, dr.isUnresolvable(key, now))
+		dr.markUnresolvable(key, now)
+		assert.True(t, dr.isUnresolvable(key, now.Add(time.Second)))
+		assert.False(t, dr.isUnresolvable(PathKey{MountID: 1, Inode: 42, PathID: 4}, now))
+
+		// unresolvable keys are looked up again once expired
+		assert.False(t, dr.isUnresolvable(key, now.Add(3*time.Second)))
+		assert.False(t, dr.negativeCache.Contains(key))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		dr := newTestDentryResolver(t, &config.Config{DentryCacheSize: 16})
+		dr.markUnresolvable(key, now)
+		assert.False(t, dr.isUnresolvable(key, now))
+	})
+
+	t.Run("invalid-size", func(t *testing.T) {
+		_, err := NewDentryResolver(&Probe{config: &config.Config{}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,109 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/DataDog/datadog-agent/pkg/security/secl/eval"
+)
+
+// EventRecord is the record of an event written in the activity dumps. Along with the JSON
+// serialization of the event, it holds the values of all the fields of the event, resolved when
+// it was recorded, so that the event can be replayed offline through a ruleset.
+type EventRecord struct {
+	Type      string                 `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	Fields    map[string]interface{} `json:"fields"`
+	Event     json.RawMessage        `json:"event,omitempty"`
+}
+
+// NewEventRecord returns the record of an event, resolving the values of its fields
+func NewEventRecord(e *Event) (*EventRecord, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	eventType := e.GetType()
+	record := &EventRecord{
+		Type:      eventType,
+		Timestamp: e.ResolveEventTimestamp(),
+		Fields:    make(map[string]interface{}),
+		Event:     data,
+	}
+
+	for _, field := range e.GetFields() {
+		if fieldEventType, err := e.GetFieldEventType(field); err != nil || (fieldEventType != "*" && fieldEventType != eventType) {
+			continue
+		}
+		value, err := e.GetFieldValue(field)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve field `%s`", field)
+		}
+		record.Fields[field] = value
+	}
+
+	return record, nil
+}
+
+// IsRecorded returns whether the value of a field was recorded
+func (r *EventRecord) IsRecorded(field eval.Field) bool {
+	_, exists := r.Fields[field]
+	return exists
+}
+
+// ReplayEvent returns an event holding the values of the fields of the record. The event is not
+// bound to the resolvers of a probe: its fields which weren't recorded can't be evaluated.
+func (r *EventRecord) ReplayEvent() (*Event, error) {
+	eventType := parseEvalEventType(r.Type)
+	if eventType == UnknownEventType {
+		return nil, fmt.Errorf("unknown event type `%s`", r.Type)
+	}
+
+	e := &Event{
+		Type:      uint64(eventType),
+		Timestamp: r.Timestamp,
+	}
+
+	for field, value := range r.Fields {
+		kind, err := e.GetFieldType(field)
+		if err != nil {
+			// the field was removed from the model since the event was recorded
+			if _, ok := err.(*eval.ErrFieldNotFound); ok {
+				continue
+			}
+			return nil, err
+		}
+
+		// JSON numbers are decoded as float64, or json.Number when the decoder uses numbers
+		if kind == reflect.Int {
+			switch number := value.(type) {
+			case float64:
+				value = int(number)
+			case json.Number:
+				i, err := number.Int64()
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid value for field `%s`", field)
+				}
+				value = int(i)
+			}
+		}
+
+		if err := e.SetFieldValue(field, value); err != nil {
+			return nil, errors.Wrapf(err, "invalid value for field `%s`", field)
+		}
+	}
+
+	return e, nil
+}
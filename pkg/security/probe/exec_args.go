@@ -0,0 +1,191 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probe
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/DataDog/datadog-agent/pkg/security/config"
+	"github.com/DataDog/datadog-agent/pkg/security/ebpf"
+)
+
+const (
+	// execMaxStrings is the maximum number of arguments, and of environment variables, captured by the kernel
+	execMaxStrings = 32
+	// execMaxStringSize is the size of the buffers holding each argument and environment variable
+	execMaxStringSize = 128
+
+	execArgsTruncated = 1 << 0
+	execEnvsTruncated = 1 << 1
+
+	redactedSecret = "********"
+)
+
+// ExecArgs holds the arguments and environment variables of an exec, as captured by the kernel
+type ExecArgs struct {
+	Pid           uint32
+	Args          []string
+	Envs          []string
+	ArgsTruncated bool
+	EnvsTruncated bool
+}
+
+// UnmarshalBinary unmarshals a binary representation of itself
+func (e *ExecArgs) UnmarshalBinary(data []byte) (int, error) {
+	if len(data) < 16+2*execMaxStrings*execMaxStringSize {
+		return 0, ErrNotEnoughData
+	}
+
+	e.Pid = ebpf.ByteOrder.Uint32(data[0:4])
+	flags := ebpf.ByteOrder.Uint32(data[4:8])
+	e.ArgsTruncated = flags&execArgsTruncated != 0
+	e.EnvsTruncated = flags&execEnvsTruncated != 0
+	argsCount := ebpf.ByteOrder.Uint32(data[8:12])
+	envsCount := ebpf.ByteOrder.Uint32(data[12:16])
+	read := 16
+
+	e.Args, read = unmarshalExecStrings(data, read, argsCount)
+	e.Envs, read = unmarshalExecStrings(data, read, envsCount)
+
+	return read, nil
+}
+
+func unmarshalExecStrings(data []byte, offset int, count uint32) ([]string, int) {
+	if count > execMaxStrings {
+		count = execMaxStrings
+	}
+
+	strs := make([]string, 0, count)
+	for i := 0; i < int(count); i++ {
+		buf := data[offset+i*execMaxStringSize : offset+(i+1)*execMaxStringSize]
+		if n := bytes.IndexByte(buf, 0); n >= 0 {
+			buf = buf[:n]
+		}
+		strs = append(strs, string(buf))
+	}
+	return strs, offset + execMaxStrings*execMaxStringSize
+}
+
+// ExecArgsResolver keeps the arguments received from the kernel until the exec event they belong to is
+// received, and redacts the secrets they contain
+type ExecArgsResolver struct {
+	sync.Mutex
+	envKeys   map[string]bool
+	redactors []*regexp.Regexp
+	pending   map[uint32]*ExecArgs
+}
+
+// NewExecArgsResolver returns a new exec arguments resolver
+func NewExecArgsResolver(cfg *config.Config) (*ExecArgsResolver, error) {
+	r := &ExecArgsResolver{
+		envKeys: make(map[string]bool),
+		pending: make(map[uint32]*ExecArgs),
+	}
+
+	for _, key := range cfg.ExecEnvKeys {
+		r.envKeys[key] = true
+	}
+
+	for _, pattern := range cfg.ExecArgsRedactPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid exec arguments redact pattern `%s`", pattern)
+		}
+		r.redactors = append(r.redactors, re)
+	}
+
+	return r, nil
+}
+
+// Add stores the arguments of an exec until its exec event is received
+func (r *ExecArgsResolver) Add(args *ExecArgs) {
+	r.Lock()
+	r.pending[args.Pid] = args
+	r.Unlock()
+}
+
+// Delete removes the pending arguments of a process
+func (r *ExecArgsResolver) Delete(pid uint32) {
+	r.Lock()
+	delete(r.pending, pid)
+	r.Unlock()
+}
+
+// Resolve sets the pending arguments of the process of an exec event to its process cache entry
+func (r *ExecArgsResolver) Resolve(entry *ProcessCacheEntry) {
+	r.Lock()
+	args, ok := r.pending[entry.Pid]
+	delete(r.pending, entry.Pid)
+	r.Unlock()
+
+	if !ok {
+		return
+	}
+
+	// the first argument is the name of the program, which is already reported
+	if len(args.Args) > 0 {
+		entry.Args = r.redact(strings.Join(args.Args[1:], " "))
+	}
+	entry.ArgsTruncated = args.ArgsTruncated
+
+	var envs []string
+	for _, env := range args.Envs {
+		if r.envKeys[strings.SplitN(env, "=", 2)[0]] {
+			envs = append(envs, r.redact(env))
+		}
+	}
+	entry.Envs = strings.Join(envs, " ")
+}
+
+// ResolveFromCmdline sets the arguments of a process cache entry from its command line, read from /proc
+func (r *ExecArgsResolver) ResolveFromCmdline(entry *ProcessCacheEntry, cmdline []string) {
+	if len(cmdline) > 0 {
+		entry.Args = r.redact(strings.Join(cmdline[1:], " "))
+	}
+}
+
+// redact replaces the secrets matched by the redact patterns
+func (r *ExecArgsResolver) redact(s string) string {
+	return redactSecrets(r.redactors, s)
+}
+
+// redactSecrets replaces the secrets matched by the given patterns. When a pattern has a group, only the text
+// matched by its first group is replaced.
+func redactSecrets(redactors []*regexp.Regexp, s string) string {
+	for _, re := range redactors {
+		if re.NumSubexp() == 0 {
+			s = re.ReplaceAllLiteralString(s, redactedSecret)
+			continue
+		}
+
+		matches := re.FindAllStringSubmatchIndex(s, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var buf strings.Builder
+		last := 0
+		for _, match := range matches {
+			start, end := match[2], match[3]
+			if start < 0 {
+				continue
+			}
+			buf.WriteString(s[last:start])
+			buf.WriteString(redactedSecret)
+			last = end
+		}
+		buf.WriteString(s[last:])
+		s = buf.String()
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probe
+
+import (
+	"testing"
+
+	aconfig "github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/security/config"
+	"github.com/DataDog/datadog-agent/pkg/security/ebpf"
+)
+
+func TestExecArgs(t *testing.T) {
+	data := make([]byte, 16+2*execMaxStrings*execMaxStringSize)
+
+	putStrings := func(offset int, strs ...string) {
+		for i, s := range strs {
+			copy(data[offset+i*execMaxStringSize:], s)
+		}
+	}
+	ebpf.ByteOrder.PutUint32(data[0:4], 42)
+	ebpf.ByteOrder.PutUint32(data[4:8], execArgsTruncated)
+	ebpf.ByteOrder.PutUint32(data[8:12], 4)
+	ebpf.ByteOrder.PutUint32(data[12:16], 3)
+	putStrings(16, "curl", "-H", "Authorization: token abc123", "http://example.com/install.sh")
+	putStrings(16+execMaxStrings*execMaxStringSize, "PATH=/usr/bin", "LD_PRELOAD=/tmp/hook.so", "API_KEY=xyz")
+
+	var args ExecArgs
+	read, err := args.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != len(data) {
+		t.Fatalf("expected %d bytes to be read, got %d", len(data), read)
+	}
+	if args.Pid != 42 || !args.ArgsTruncated || args.EnvsTruncated || len(args.Args) != 4 || len(args.Envs) != 3 {
+		t.Fatalf("unexpected exec arguments: %+v", args)
+	}
+
+	resolver, err := NewExecArgsResolver(&config.Config{
+		ExecEnvKeys:            []string{"LD_PRELOAD", "API_KEY"},
+		ExecArgsRedactPatterns: []string{aconfig.DefaultExecArgsRedactPattern},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver.Add(&args)
+	entry := NewProcessCacheEntry()
+	entry.Pid = 42
+	resolver.Resolve(entry)
+
+	if expected := "-H Authorization: token ******** http://example.com/install.sh"; entry.Args != expected {
+		t.Fatalf("expected args `%s`, got `%s`", expected, entry.Args)
+	}
+	if !entry.ArgsTruncated {
+		t.Fatal("expected args to be truncated")
+	}
+	if expected := "LD_PRELOAD=/tmp/hook.so API_KEY=********"; entry.Envs != expected {
+		t.Fatalf("expected envs `%s`, got `%s`", expected, entry.Envs)
+	}
+
+	// pending arguments are only used once
+	entry = NewProcessCacheEntry()
+	entry.Pid = 42
+	resolver.Resolve(entry)
+	if entry.Args != "" {
+		t.Fatalf("expected no args, got `%s`", entry.Args)
+	}
+
+	if _, err := args.UnmarshalBinary(data[:16]); err != ErrNotEnoughData {
+		t.Fatalf("expected ErrNotEnoughData, got %v", err)
+	}
+}
+
+func TestExecArgsRedact(t *testing.T) {
+	resolver, err := NewExecArgsResolver(&config.Config{
+		ExecArgsRedactPatterns: []string{aconfig.DefaultExecArgsRedactPattern, `--key-[a-z]+`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for in, out := range map[string]string{
+		"--password=hunter2 --user root": "--password=******** --user root",
+		"-u root --passwd hunter2":       "-u root --passwd ********",
+		"--secret: a --token=b":          "--secret: ******** --token=********",
+		"--tokenizer=fast":               "--tokenizer=fast",
+		"--key-abc value":                "******** value",
+		"ls -l /tmp":                     "ls -l /tmp",
+	} {
+		if redacted := resolver.redact(in); redacted != out {
+			t.Errorf("expected `%s` to be redacted to `%s`, got `%s`", in, out, redacted)
+		}
+	}
+
+	if _, err := NewExecArgsResolver(&config.Config{ExecArgsRedactPatterns: []string{"("}}); err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+}
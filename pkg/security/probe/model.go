@@ -11,11 +11,14 @@ package probe
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"net"
 	"os/user"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -142,7 +145,7 @@ func (e *FileEvent) ResolveInode(event *Event) string {
 
 // ResolveInodeWithResolvers resolves the inode to a full path
 func (e *FileEvent) ResolveInodeWithResolvers(resolvers *Resolvers) string {
-	if len(e.PathnameStr) == 0 {
+	if len(e.PathnameStr) == 0 && resolvers != nil {
 		e.PathnameStr = resolvers.DentryResolver.Resolve(e.MountID, e.Inode, e.PathID)
 		if e.PathnameStr == dentryPathKeyNotFound {
 			return e.PathnameStr
@@ -167,7 +170,7 @@ func (e *FileEvent) ResolveContainerPath(event *Event) string {
 
 // ResolveContainerPathWithResolvers resolves the inode to a path relative to the container
 func (e *FileEvent) ResolveContainerPathWithResolvers(resolvers *Resolvers) string {
-	if len(e.ContainerPath) == 0 {
+	if len(e.ContainerPath) == 0 && resolvers != nil {
 		containerPath, _, _, err := resolvers.MountResolver.GetMountPath(e.MountID)
 		if err == nil {
 			e.ContainerPath = containerPath
@@ -185,7 +188,7 @@ func (e *FileEvent) ResolveBasename(event *Event) string {
 	if len(e.BasenameStr) == 0 {
 		if e.PathnameStr != "" {
 			e.BasenameStr = path.Base(e.PathnameStr)
-		} else {
+		} else if event.resolvers != nil {
 			e.BasenameStr = event.resolvers.DentryResolver.GetName(e.MountID, e.Inode, e.PathID)
 		}
 	}
@@ -686,7 +689,7 @@ func (e *MountEvent) UnmarshalBinary(data []byte) (int, error) {
 
 // ResolveMountPoint resolves the mountpoint to a full path
 func (e *MountEvent) ResolveMountPoint(event *Event) string {
-	if len(e.MountPointStr) == 0 {
+	if len(e.MountPointStr) == 0 && event.resolvers != nil {
 		e.MountPointStr = event.resolvers.DentryResolver.Resolve(e.ParentMountID, e.ParentInode, 0)
 	}
 	return e.MountPointStr
@@ -694,7 +697,7 @@ func (e *MountEvent) ResolveMountPoint(event *Event) string {
 
 // ResolveRoot resolves the mountpoint to a full path
 func (e *MountEvent) ResolveRoot(event *Event) string {
-	if len(e.RootStr) == 0 {
+	if len(e.RootStr) == 0 && event.resolvers != nil {
 		e.RootStr = event.resolvers.DentryResolver.Resolve(e.RootMountID, e.RootInode, 0)
 	}
 	return e.RootStr
@@ -739,9 +742,101 @@ func (e *UmountEvent) UnmarshalBinary(data []byte) (int, error) {
 	return 4, nil
 }
 
+// NamespaceEvent represents a syscall creating or joining namespaces
+type NamespaceEvent struct {
+	SyscallEvent
+	Syscall string `field:"syscall"`
+	Types   uint64 `field:"types"`
+}
+
+func (e *NamespaceEvent) marshalJSON(event *Event) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteRune('{')
+	fmt.Fprintf(&buf, `"syscall":"%s",`, e.Syscall)
+	fmt.Fprintf(&buf, `"types":"%s"`, NamespaceTypes(e.Types))
+	buf.WriteRune('}')
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary unmarshals a binary representation of itself
+func (e *NamespaceEvent) UnmarshalBinary(data []byte) (int, error) {
+	n, err := unmarshalBinary(data, &e.SyscallEvent)
+	if err != nil {
+		return n, err
+	}
+
+	data = data[n:]
+	if len(data) < 16 {
+		return n, ErrNotEnoughData
+	}
+
+	e.Types = ebpf.ByteOrder.Uint64(data[0:8])
+	switch ebpf.ByteOrder.Uint32(data[8:12]) {
+	case 1:
+		e.Syscall = "unshare"
+	case 2:
+		e.Syscall = "setns"
+	case 3:
+		e.Syscall = "clone"
+	}
+
+	// Notes: bytes 12 to 16 are used to pad the structure
+
+	return n + 16, nil
+}
+
+// CgroupWriteEvent represents a write to the release_agent or notify_on_release file of a cgroup,
+// which can be used to execute a program outside of a container
+type CgroupWriteEvent struct {
+	File  string `field:"file"`
+	Value string `field:"value"`
+}
+
+func (e *CgroupWriteEvent) marshalJSON(event *Event) ([]byte, error) {
+	value, err := json.Marshal(e.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteRune('{')
+	fmt.Fprintf(&buf, `"file":"%s",`, e.File)
+	fmt.Fprintf(&buf, `"value":%s`, value)
+	buf.WriteRune('}')
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary unmarshals a binary representation of itself
+func (e *CgroupWriteEvent) UnmarshalBinary(data []byte) (int, error) {
+	if len(data) < 264 {
+		return 0, ErrNotEnoughData
+	}
+
+	switch ebpf.ByteOrder.Uint32(data[0:4]) {
+	case 1:
+		e.File = "release_agent"
+	case 2:
+		e.File = "notify_on_release"
+	}
+
+	// Notes: bytes 4 to 8 are used to pad the structure
+
+	value := data[8:264]
+	if i := bytes.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+	e.Value = string(value)
+
+	return 264, nil
+}
+
 // ContainerContext holds the container context of an event
 type ContainerContext struct {
-	ID string `field:"id" handler:"ResolveContainerID,string"`
+	ID        string `field:"id" handler:"ResolveContainerID,string"`
+	ImageName string `field:"image.name" handler:"ResolveImageName,string"`
+	ImageTag  string `field:"image.tag" handler:"ResolveImageTag,string"`
 }
 
 func (e *ContainerContext) marshalJSON(event *Event) ([]byte, error) {
@@ -752,6 +847,12 @@ func (e *ContainerContext) marshalJSON(event *Event) ([]byte, error) {
 	var buf bytes.Buffer
 	buf.WriteRune('{')
 	fmt.Fprintf(&buf, `"container_id":"%s"`, e.ResolveContainerID(event))
+	if imageName := e.ResolveImageName(event); len(imageName) > 0 {
+		fmt.Fprintf(&buf, `,"image_name":"%s"`, imageName)
+	}
+	if imageTag := e.ResolveImageTag(event); len(imageTag) > 0 {
+		fmt.Fprintf(&buf, `,"image_tag":"%s"`, imageTag)
+	}
 	buf.WriteRune('}')
 
 	return buf.Bytes(), nil
@@ -788,6 +889,58 @@ func (e *ContainerContext) ResolveContainerID(event *Event) string {
 	return e.ID
 }
 
+// ResolveImageName resolves the name of the image of the container of the event
+func (e *ContainerContext) ResolveImageName(event *Event) string {
+	if len(e.ImageName) == 0 && event != nil {
+		e.ImageName = getTagValue(event.ResolveContainerTags(), "image_name")
+	}
+	return e.ImageName
+}
+
+// ResolveImageTag resolves the tag of the image of the container of the event
+func (e *ContainerContext) ResolveImageTag(event *Event) string {
+	if len(e.ImageTag) == 0 && event != nil {
+		e.ImageTag = getTagValue(event.ResolveContainerTags(), "image_tag")
+	}
+	return e.ImageTag
+}
+
+// KubernetesContext holds the Kubernetes context of an event, resolved from the tags of its container
+type KubernetesContext struct {
+	PodName   string `field:"pod.name" handler:"ResolvePodName,string"`
+	Namespace string `field:"namespace" handler:"ResolveNamespace,string"`
+}
+
+func (e *KubernetesContext) marshalJSON(event *Event) ([]byte, error) {
+	if len(e.ResolvePodName(event)) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteRune('{')
+	fmt.Fprintf(&buf, `"pod_name":"%s",`, e.ResolvePodName(event))
+	fmt.Fprintf(&buf, `"namespace":"%s"`, e.ResolveNamespace(event))
+	buf.WriteRune('}')
+
+	return buf.Bytes(), nil
+}
+
+// ResolvePodName resolves the name of the Kubernetes pod of the event
+func (e *KubernetesContext) ResolvePodName(event *Event) string {
+	if len(e.PodName) == 0 && event != nil {
+		e.PodName = getTagValue(event.ResolveContainerTags(), "pod_name")
+	}
+	return e.PodName
+}
+
+// ResolveNamespace resolves the Kubernetes namespace of the event
+func (e *KubernetesContext) ResolveNamespace(event *Event) string {
+	if len(e.Namespace) == 0 && event != nil {
+		e.Namespace = getTagValue(event.ResolveContainerTags(), "kube_namespace")
+	}
+	return e.Namespace
+}
+
 // ExecEvent represents a exec event
 type ExecEvent struct {
 	// proc_cache_t
@@ -808,11 +961,23 @@ type ExecEvent struct {
 	GID   uint32 `field:"uid" handler:"ResolveGID,int"`
 	User  string `field:"user" handler:"ResolveUser,string"`
 	Group string `field:"group" handler:"ResolveGroup,string"`
+
+	// Audit login UID and session ID, set when a user logs in and inherited by all the processes of
+	// the session. They are -1 for the processes which are not part of a login session, like services.
+	AUID            uint32 `field:"auid" handler:"ResolveAUID,int"`
+	SessionID       uint32 `field:"session_id" handler:"ResolveSessionID,int"`
+	LoginUser       string `field:"login_user" handler:"ResolveLoginUser,string"`
+	SessionResolved bool   `field:"-"`
+
+	// Arguments and selected environment variables, sent separately by the kernel and redacted
+	Args          string `field:"args" handler:"ResolveArgs,string"`
+	ArgsTruncated bool   `field:"-"`
+	Envs          string `field:"envs" handler:"ResolveEnvs,string"`
 }
 
 // UnmarshalBinary unmarshals a binary representation of itself
 func (e *ExecEvent) UnmarshalBinary(data []byte, resolvers *Resolvers) (int, error) {
-	if len(data) < 136 {
+	if len(data) < 144 {
 		return 0, ErrNotEnoughData
 	}
 
@@ -835,6 +1000,11 @@ func (e *ExecEvent) UnmarshalBinary(data []byte, resolvers *Resolvers) (int, err
 	e.Comm = string(bytes.Trim(commRaw[:], "\x00"))
 	read += 16
 
+	e.AUID = ebpf.ByteOrder.Uint32(data[read : read+4])
+	e.SessionID = ebpf.ByteOrder.Uint32(data[read+4 : read+8])
+	e.SessionResolved = true
+	read += 8
+
 	// Unmarshal pid_cache_t
 	e.Cookie = ebpf.ByteOrder.Uint32(data[read : read+4])
 	e.PPid = ebpf.ByteOrder.Uint32(data[read+4 : read+8])
@@ -855,7 +1025,7 @@ func (e *ExecEvent) UnmarshalBinary(data []byte, resolvers *Resolvers) (int, err
 
 // UnmarshalEvent unmarshal an ExecEvent
 func (e *ExecEvent) UnmarshalEvent(data []byte, event *Event) (int, error) {
-	if len(data) < 136 {
+	if len(data) < 144 {
 		return 0, ErrNotEnoughData
 	}
 
@@ -935,6 +1105,27 @@ func (e *ExecEvent) ResolveTTY(event *Event) string {
 	return e.TTYName
 }
 
+// ResolveArgs resolves the arguments of the process
+func (e *ExecEvent) ResolveArgs(event *Event) string {
+	if len(e.Args) == 0 {
+		if entry := event.ResolveProcessCacheEntry(); entry != nil {
+			e.Args = entry.Args
+			e.ArgsTruncated = entry.ArgsTruncated
+		}
+	}
+	return e.Args
+}
+
+// ResolveEnvs resolves the captured environment variables of the process
+func (e *ExecEvent) ResolveEnvs(event *Event) string {
+	if len(e.Envs) == 0 {
+		if entry := event.ResolveProcessCacheEntry(); entry != nil {
+			e.Envs = entry.Envs
+		}
+	}
+	return e.Envs
+}
+
 // ResolveComm resolves the comm of the process
 func (e *ExecEvent) ResolveComm(event *Event) string {
 	if len(e.Comm) == 0 {
@@ -987,6 +1178,56 @@ func (e *ExecEvent) ResolveGroup(event *Event) string {
 	return e.Group
 }
 
+// auditID returns the value of an audit login UID or session ID, -1 when it is unset
+func auditID(id uint32) int {
+	if id == auditIDUnset {
+		return -1
+	}
+	return int(id)
+}
+
+// resolveSession resolves the audit login UID and session ID of the process
+func (e *ExecEvent) resolveSession(event *Event) {
+	if !e.SessionResolved {
+		if entry := event.ResolveProcessCacheEntry(); entry != nil && entry.SessionResolved {
+			e.AUID = entry.AUID
+			e.SessionID = entry.SessionID
+			e.SessionResolved = true
+		}
+	}
+}
+
+// ResolveAUID resolves the audit login UID of the process
+func (e *ExecEvent) ResolveAUID(event *Event) int {
+	e.resolveSession(event)
+	if !e.SessionResolved {
+		return -1
+	}
+	return auditID(e.AUID)
+}
+
+// ResolveSessionID resolves the audit session ID of the process
+func (e *ExecEvent) ResolveSessionID(event *Event) int {
+	e.resolveSession(event)
+	if !e.SessionResolved {
+		return -1
+	}
+	return auditID(e.SessionID)
+}
+
+// ResolveLoginUser resolves the audit login UID of the process to a username
+func (e *ExecEvent) ResolveLoginUser(event *Event) string {
+	if len(e.LoginUser) == 0 {
+		if auid := e.ResolveAUID(event); auid >= 0 {
+			u, err := user.LookupId(strconv.Itoa(auid))
+			if err == nil {
+				e.LoginUser = u.Username
+			}
+		}
+	}
+	return e.LoginUser
+}
+
 // ResolveForkTimestamp returns the fork timestamp of the process
 func (e *ExecEvent) ResolveForkTimestamp(event *Event) time.Time {
 	if e.ForkTimestamp.IsZero() && event != nil {
@@ -1037,6 +1278,90 @@ func (e *InvalidateDentryEvent) UnmarshalBinary(data []byte) (int, error) {
 	return 16, nil
 }
 
+// maxProcessFlows is the number of destinations tracked in kernel per process, see MAX_PROCESS_FLOWS
+const maxProcessFlows = 4
+
+// Flow describes a network destination a process connected to
+type Flow struct {
+	Family uint16
+	IP     net.IP
+	Port   uint16
+	Count  uint32
+}
+
+// UnmarshalBinary unmarshals a binary representation of itself
+func (f *Flow) UnmarshalBinary(data []byte) (int, error) {
+	if len(data) < 24 {
+		return 0, ErrNotEnoughData
+	}
+
+	f.Family = ebpf.ByteOrder.Uint16(data[16:18])
+	switch f.Family {
+	case syscall.AF_INET:
+		f.IP = net.IP(append([]byte(nil), data[0:4]...))
+	case syscall.AF_INET6:
+		f.IP = net.IP(append([]byte(nil), data[0:16]...))
+	}
+	// the port is stored in network byte order
+	f.Port = binary.BigEndian.Uint16(data[18:20])
+	f.Count = ebpf.ByteOrder.Uint32(data[20:24])
+
+	return 24, nil
+}
+
+// ProcessFlows holds a summary of the top destinations a process connected to
+type ProcessFlows struct {
+	Flows []Flow
+}
+
+// UnmarshalBinary unmarshals a binary representation of itself
+func (p *ProcessFlows) UnmarshalBinary(data []byte) (int, error) {
+	if len(data) < maxProcessFlows*24 {
+		return 0, ErrNotEnoughData
+	}
+
+	p.Flows = nil
+	for i := 0; i < maxProcessFlows; i++ {
+		var flow Flow
+		if _, err := flow.UnmarshalBinary(data[i*24:]); err != nil {
+			return 0, err
+		}
+		if flow.Count == 0 || flow.IP == nil {
+			continue
+		}
+		p.Flows = append(p.Flows, flow)
+	}
+
+	// most used destinations first
+	sort.SliceStable(p.Flows, func(i, j int) bool {
+		return p.Flows[i].Count > p.Flows[j].Count
+	})
+
+	return maxProcessFlows * 24, nil
+}
+
+func (p *ProcessFlows) marshalJSON(event *Event) ([]byte, error) {
+	if len(p.Flows) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteRune('[')
+	for i, flow := range p.Flows {
+		if i > 0 {
+			buf.WriteRune(',')
+		}
+		family := "ipv4"
+		if flow.Family == syscall.AF_INET6 {
+			family = "ipv6"
+		}
+		fmt.Fprintf(&buf, `{"family":"%s","ip":"%s","port":%d,"count":%d}`, family, flow.IP, flow.Port, flow.Count)
+	}
+	buf.WriteRune(']')
+
+	return buf.Bytes(), nil
+}
+
 // ProcessContext holds the process context of an event
 type ProcessContext struct {
 	ExecEvent
@@ -1135,8 +1460,9 @@ type Event struct {
 	TimestampRaw uint64    `field:"-"`
 	Timestamp    time.Time `field:"timestamp"`
 
-	Process   ProcessContext   `field:"process" event:"*"`
-	Container ContainerContext `field:"container"`
+	Process    ProcessContext    `field:"process" event:"*"`
+	Container  ContainerContext  `field:"container"`
+	Kubernetes KubernetesContext `field:"kubernetes"`
 
 	Chmod       ChmodEvent    `field:"chmod" event:"chmod"`
 	Chown       ChownEvent    `field:"chown" event:"chown"`
@@ -1151,12 +1477,18 @@ type Event struct {
 	RemoveXAttr SetXAttrEvent `field:"removexattr" event:"removexattr"`
 	Exec        ExecEvent     `field:"exec" event:"exec"`
 
+	Namespace   NamespaceEvent   `field:"namespace" event:"namespace"`
+	CgroupWrite CgroupWriteEvent `field:"cgroup_write" event:"cgroup_write"`
+
+	Flows ProcessFlows `field:"-"`
+
 	Mount            MountEvent            `field:"-"`
 	Umount           UmountEvent           `field:"-"`
 	InvalidateDentry InvalidateDentryEvent `field:"-"`
 
 	resolvers         *Resolvers         `field:"-"`
 	processCacheEntry *ProcessCacheEntry `field:"-"`
+	containerTags     []string           `field:"-"`
 }
 
 func (e *Event) String() string {
@@ -1206,6 +1538,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "file",
 				marshalFnc: e.Chmod.marshalJSON,
@@ -1224,6 +1560,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "file",
 				marshalFnc: e.Chown.marshalJSON,
@@ -1242,6 +1582,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "file",
 				marshalFnc: e.Open.marshalJSON,
@@ -1260,6 +1604,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "file",
 				marshalFnc: e.Mkdir.marshalJSON,
@@ -1278,6 +1626,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "file",
 				marshalFnc: e.Rmdir.marshalJSON,
@@ -1296,6 +1648,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "file",
 				marshalFnc: e.Unlink.marshalJSON,
@@ -1314,6 +1670,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				marshalFnc: e.Rename.marshalJSON,
 			})
@@ -1331,6 +1691,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "file",
 				marshalFnc: e.Utimes.marshalJSON,
@@ -1349,6 +1713,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				marshalFnc: e.Link.marshalJSON,
 			})
@@ -1366,6 +1734,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "mount",
 				marshalFnc: e.Mount.marshalJSON,
@@ -1384,6 +1756,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "umount",
 				marshalFnc: e.Umount.marshalJSON,
@@ -1402,6 +1778,10 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "file",
 				marshalFnc: e.SetXAttr.marshalJSON,
@@ -1420,10 +1800,54 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
 			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
 			eventMarshaler{
 				field:      "file",
 				marshalFnc: e.RemoveXAttr.marshalJSON,
 			})
+	case NamespaceEventType:
+		entries = append(entries,
+			eventMarshaler{
+				field:      "syscall",
+				marshalFnc: eventMarshalJSON(&e.Namespace.SyscallEvent),
+			},
+			eventMarshaler{
+				field:      "process",
+				marshalFnc: e.Process.marshalJSON,
+			},
+			eventMarshaler{
+				field:      "container",
+				marshalFnc: e.Container.marshalJSON,
+			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
+			eventMarshaler{
+				field:      "namespace",
+				marshalFnc: e.Namespace.marshalJSON,
+			})
+	case CgroupWriteEventType:
+		entries = append(entries,
+			eventMarshaler{
+				field:      "process",
+				marshalFnc: e.Process.marshalJSON,
+			},
+			eventMarshaler{
+				field:      "container",
+				marshalFnc: e.Container.marshalJSON,
+			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
+			eventMarshaler{
+				field:      "cgroup",
+				marshalFnc: e.CgroupWrite.marshalJSON,
+			})
 	case ExecEventType, ForkEventType, ExitEventType:
 		entries = append(entries,
 			eventMarshaler{
@@ -1433,6 +1857,14 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 			eventMarshaler{
 				field:      "container",
 				marshalFnc: e.Container.marshalJSON,
+			},
+			eventMarshaler{
+				field:      "kubernetes",
+				marshalFnc: e.Kubernetes.marshalJSON,
+			},
+			eventMarshaler{
+				field:      "network_flows",
+				marshalFnc: e.Flows.marshalJSON,
 			})
 	}
 
@@ -1461,8 +1893,20 @@ func (e *Event) GetType() string {
 
 // GetTags returns the list of tags specific to this event
 func (e *Event) GetTags() []string {
-	// TODO: add container tags once we collect them
-	return []string{"type:" + e.GetType()}
+	return append([]string{"type:" + e.GetType()}, e.ResolveContainerTags()...)
+}
+
+// ResolveContainerTags resolves the tags of the container of the event
+func (e *Event) ResolveContainerTags() []string {
+	if e.containerTags == nil {
+		if id := e.Container.ResolveContainerID(e); len(id) > 0 && e.resolvers != nil {
+			e.containerTags = e.resolvers.ContainerResolver.ResolveTags(id)
+		}
+		if e.containerTags == nil {
+			e.containerTags = []string{}
+		}
+	}
+	return e.containerTags
 }
 
 // GetPointer return an unsafe.Pointer of the Event
@@ -1484,7 +1928,9 @@ func (e *Event) UnmarshalBinary(data []byte) (int, error) {
 // ResolveEventTimestamp resolves the monolitic kernel event timestamp to an absolute time
 func (e *Event) ResolveEventTimestamp() time.Time {
 	if e.Timestamp.IsZero() {
-		e.Timestamp = e.resolvers.TimeResolver.ResolveMonotonicTimestamp(e.TimestampRaw)
+		if e.resolvers != nil {
+			e.Timestamp = e.resolvers.TimeResolver.ResolveMonotonicTimestamp(e.TimestampRaw)
+		}
 		if e.Timestamp.IsZero() {
 			e.Timestamp = time.Now()
 		}
@@ -1495,7 +1941,9 @@ func (e *Event) ResolveEventTimestamp() time.Time {
 // ResolveProcessCacheEntry queries the ProcessResolver to retrieve the ProcessCacheEntry of the event
 func (e *Event) ResolveProcessCacheEntry() *ProcessCacheEntry {
 	if e.processCacheEntry == nil {
-		e.processCacheEntry = e.resolvers.ProcessResolver.Resolve(e.Process.Pid)
+		if e.resolvers != nil {
+			e.processCacheEntry = e.resolvers.ProcessResolver.Resolve(e.Process.Pid)
+		}
 		if e.processCacheEntry == nil {
 			e.processCacheEntry = &ProcessCacheEntry{}
 		}
@@ -13,6 +13,22 @@ import (
 func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 	switch field {
 
+	case "cgroup_write.file":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string { return (*Event)(ctx.Object).CgroupWrite.File },
+
+			Field: field,
+		}, nil
+
+	case "cgroup_write.value":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string { return (*Event)(ctx.Object).CgroupWrite.Value },
+
+			Field: field,
+		}, nil
+
 	case "chmod.basename":
 
 		return &eval.StringEvaluator{
@@ -151,6 +167,42 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 			Field: field,
 		}, nil
 
+	case "container.image.name":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string {
+				return (*Event)(ctx.Object).Container.ResolveImageName((*Event)(ctx.Object))
+			},
+
+			Field: field,
+		}, nil
+
+	case "container.image.tag":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string {
+				return (*Event)(ctx.Object).Container.ResolveImageTag((*Event)(ctx.Object))
+			},
+
+			Field: field,
+		}, nil
+
+	case "exec.args":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string { return (*Event)(ctx.Object).Exec.ResolveArgs((*Event)(ctx.Object)) },
+
+			Field: field,
+		}, nil
+
+	case "exec.auid":
+
+		return &eval.IntEvaluator{
+			EvalFnc: func(ctx *eval.Context) int { return int((*Event)(ctx.Object).Exec.ResolveAUID((*Event)(ctx.Object))) },
+
+			Field: field,
+		}, nil
+
 	case "exec.basename":
 
 		return &eval.StringEvaluator{
@@ -177,6 +229,14 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 			Field: field,
 		}, nil
 
+	case "exec.envs":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string { return (*Event)(ctx.Object).Exec.ResolveEnvs((*Event)(ctx.Object)) },
+
+			Field: field,
+		}, nil
+
 	case "exec.filename":
 
 		return &eval.StringEvaluator{
@@ -201,6 +261,16 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 			Field: field,
 		}, nil
 
+	case "exec.login_user":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string {
+				return (*Event)(ctx.Object).Exec.ResolveLoginUser((*Event)(ctx.Object))
+			},
+
+			Field: field,
+		}, nil
+
 	case "exec.name":
 
 		return &eval.StringEvaluator{
@@ -225,6 +295,16 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 			Field: field,
 		}, nil
 
+	case "exec.session_id":
+
+		return &eval.IntEvaluator{
+			EvalFnc: func(ctx *eval.Context) int {
+				return int((*Event)(ctx.Object).Exec.ResolveSessionID((*Event)(ctx.Object)))
+			},
+
+			Field: field,
+		}, nil
+
 	case "exec.tty_name":
 
 		return &eval.StringEvaluator{
@@ -241,6 +321,26 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 			Field: field,
 		}, nil
 
+	case "kubernetes.namespace":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string {
+				return (*Event)(ctx.Object).Kubernetes.ResolveNamespace((*Event)(ctx.Object))
+			},
+
+			Field: field,
+		}, nil
+
+	case "kubernetes.pod.name":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string {
+				return (*Event)(ctx.Object).Kubernetes.ResolvePodName((*Event)(ctx.Object))
+			},
+
+			Field: field,
+		}, nil
+
 	case "link.retval":
 
 		return &eval.IntEvaluator{
@@ -401,6 +501,30 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 			Field: field,
 		}, nil
 
+	case "namespace.retval":
+
+		return &eval.IntEvaluator{
+			EvalFnc: func(ctx *eval.Context) int { return int((*Event)(ctx.Object).Namespace.Retval) },
+
+			Field: field,
+		}, nil
+
+	case "namespace.syscall":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string { return (*Event)(ctx.Object).Namespace.Syscall },
+
+			Field: field,
+		}, nil
+
+	case "namespace.types":
+
+		return &eval.IntEvaluator{
+			EvalFnc: func(ctx *eval.Context) int { return int((*Event)(ctx.Object).Namespace.Types) },
+
+			Field: field,
+		}, nil
+
 	case "open.basename":
 
 		return &eval.StringEvaluator{
@@ -467,6 +591,24 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 			Field: field,
 		}, nil
 
+	case "process.args":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string { return (*Event)(ctx.Object).Process.ResolveArgs((*Event)(ctx.Object)) },
+
+			Field: field,
+		}, nil
+
+	case "process.auid":
+
+		return &eval.IntEvaluator{
+			EvalFnc: func(ctx *eval.Context) int {
+				return int((*Event)(ctx.Object).Process.ResolveAUID((*Event)(ctx.Object)))
+			},
+
+			Field: field,
+		}, nil
+
 	case "process.basename":
 
 		return &eval.StringEvaluator{
@@ -497,6 +639,14 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 			Field: field,
 		}, nil
 
+	case "process.envs":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string { return (*Event)(ctx.Object).Process.ResolveEnvs((*Event)(ctx.Object)) },
+
+			Field: field,
+		}, nil
+
 	case "process.filename":
 
 		return &eval.StringEvaluator{
@@ -529,6 +679,16 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 			Field: field,
 		}, nil
 
+	case "process.login_user":
+
+		return &eval.StringEvaluator{
+			EvalFnc: func(ctx *eval.Context) string {
+				return (*Event)(ctx.Object).Process.ResolveLoginUser((*Event)(ctx.Object))
+			},
+
+			Field: field,
+		}, nil
+
 	case "process.name":
 
 		return &eval.StringEvaluator{
@@ -563,6 +723,16 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 			Field: field,
 		}, nil
 
+	case "process.session_id":
+
+		return &eval.IntEvaluator{
+			EvalFnc: func(ctx *eval.Context) int {
+				return int((*Event)(ctx.Object).Process.ResolveSessionID((*Event)(ctx.Object)))
+			},
+
+			Field: field,
+		}, nil
+
 	case "process.tid":
 
 		return &eval.IntEvaluator{
@@ -1011,6 +1181,14 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 	switch field {
 
+	case "cgroup_write.file":
+
+		return e.CgroupWrite.File, nil
+
+	case "cgroup_write.value":
+
+		return e.CgroupWrite.Value, nil
+
 	case "chmod.basename":
 
 		return e.Chmod.ResolveBasename(e), nil
@@ -1075,6 +1253,22 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 
 		return e.Container.ResolveContainerID(e), nil
 
+	case "container.image.name":
+
+		return e.Container.ResolveImageName(e), nil
+
+	case "container.image.tag":
+
+		return e.Container.ResolveImageTag(e), nil
+
+	case "exec.args":
+
+		return e.Exec.ResolveArgs(e), nil
+
+	case "exec.auid":
+
+		return int(e.Exec.ResolveAUID(e)), nil
+
 	case "exec.basename":
 
 		return e.Exec.ResolveBasename(e), nil
@@ -1087,6 +1281,10 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 
 		return int(e.Exec.ResolveCookie(e)), nil
 
+	case "exec.envs":
+
+		return e.Exec.ResolveEnvs(e), nil
+
 	case "exec.filename":
 
 		return e.Exec.ResolveInode(e), nil
@@ -1099,6 +1297,10 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 
 		return int(e.Exec.Inode), nil
 
+	case "exec.login_user":
+
+		return e.Exec.ResolveLoginUser(e), nil
+
 	case "exec.name":
 
 		return e.Exec.ResolveComm(e), nil
@@ -1111,6 +1313,10 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 
 		return int(e.Exec.ResolvePPID(e)), nil
 
+	case "exec.session_id":
+
+		return int(e.Exec.ResolveSessionID(e)), nil
+
 	case "exec.tty_name":
 
 		return e.Exec.ResolveTTY(e), nil
@@ -1119,6 +1325,14 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 
 		return e.Exec.ResolveUser(e), nil
 
+	case "kubernetes.namespace":
+
+		return e.Kubernetes.ResolveNamespace(e), nil
+
+	case "kubernetes.pod.name":
+
+		return e.Kubernetes.ResolvePodName(e), nil
+
 	case "link.retval":
 
 		return int(e.Link.Retval), nil
@@ -1191,6 +1405,18 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 
 		return int(e.Mkdir.Retval), nil
 
+	case "namespace.retval":
+
+		return int(e.Namespace.Retval), nil
+
+	case "namespace.syscall":
+
+		return e.Namespace.Syscall, nil
+
+	case "namespace.types":
+
+		return int(e.Namespace.Types), nil
+
 	case "open.basename":
 
 		return e.Open.ResolveBasename(e), nil
@@ -1223,6 +1449,14 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 
 		return int(e.Open.Retval), nil
 
+	case "process.args":
+
+		return e.Process.ResolveArgs(e), nil
+
+	case "process.auid":
+
+		return int(e.Process.ResolveAUID(e)), nil
+
 	case "process.basename":
 
 		return e.Process.ResolveBasename(e), nil
@@ -1235,6 +1469,10 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 
 		return int(e.Process.ResolveCookie(e)), nil
 
+	case "process.envs":
+
+		return e.Process.ResolveEnvs(e), nil
+
 	case "process.filename":
 
 		return e.Process.ResolveInode(e), nil
@@ -1251,6 +1489,10 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 
 		return int(e.Process.Inode), nil
 
+	case "process.login_user":
+
+		return e.Process.ResolveLoginUser(e), nil
+
 	case "process.name":
 
 		return e.Process.ResolveComm(e), nil
@@ -1267,6 +1509,10 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 
 		return int(e.Process.ResolvePPID(e)), nil
 
+	case "process.session_id":
+
+		return int(e.Process.ResolveSessionID(e)), nil
+
 	case "process.tid":
 
 		return int(e.Process.Tid), nil
@@ -1475,6 +1721,12 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	switch field {
 
+	case "cgroup_write.file":
+		return "cgroup_write", nil
+
+	case "cgroup_write.value":
+		return "cgroup_write", nil
+
 	case "chmod.basename":
 		return "chmod", nil
 
@@ -1523,6 +1775,18 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	case "container.id":
 		return "*", nil
 
+	case "container.image.name":
+		return "*", nil
+
+	case "container.image.tag":
+		return "*", nil
+
+	case "exec.args":
+		return "exec", nil
+
+	case "exec.auid":
+		return "exec", nil
+
 	case "exec.basename":
 		return "exec", nil
 
@@ -1532,6 +1796,9 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	case "exec.cookie":
 		return "exec", nil
 
+	case "exec.envs":
+		return "exec", nil
+
 	case "exec.filename":
 		return "exec", nil
 
@@ -1541,6 +1808,9 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	case "exec.inode":
 		return "exec", nil
 
+	case "exec.login_user":
+		return "exec", nil
+
 	case "exec.name":
 		return "exec", nil
 
@@ -1550,12 +1820,21 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	case "exec.ppid":
 		return "exec", nil
 
+	case "exec.session_id":
+		return "exec", nil
+
 	case "exec.tty_name":
 		return "exec", nil
 
 	case "exec.user":
 		return "exec", nil
 
+	case "kubernetes.namespace":
+		return "*", nil
+
+	case "kubernetes.pod.name":
+		return "*", nil
+
 	case "link.retval":
 		return "link", nil
 
@@ -1610,6 +1889,15 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	case "mkdir.retval":
 		return "mkdir", nil
 
+	case "namespace.retval":
+		return "namespace", nil
+
+	case "namespace.syscall":
+		return "namespace", nil
+
+	case "namespace.types":
+		return "namespace", nil
+
 	case "open.basename":
 		return "open", nil
 
@@ -1634,6 +1922,12 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	case "open.retval":
 		return "open", nil
 
+	case "process.args":
+		return "*", nil
+
+	case "process.auid":
+		return "*", nil
+
 	case "process.basename":
 		return "*", nil
 
@@ -1643,6 +1937,9 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	case "process.cookie":
 		return "*", nil
 
+	case "process.envs":
+		return "*", nil
+
 	case "process.filename":
 		return "*", nil
 
@@ -1655,6 +1952,9 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	case "process.inode":
 		return "*", nil
 
+	case "process.login_user":
+		return "*", nil
+
 	case "process.name":
 		return "*", nil
 
@@ -1667,6 +1967,9 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	case "process.ppid":
 		return "*", nil
 
+	case "process.session_id":
+		return "*", nil
+
 	case "process.tid":
 		return "*", nil
 
@@ -1822,9 +2125,155 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	return "", &eval.ErrFieldNotFound{Field: field}
 }
 
+func (e *Event) GetFields() []eval.Field {
+	return []eval.Field{
+		"cgroup_write.file",
+		"cgroup_write.value",
+		"chmod.basename",
+		"chmod.container_path",
+		"chmod.filename",
+		"chmod.inode",
+		"chmod.mode",
+		"chmod.overlay_numlower",
+		"chmod.retval",
+		"chown.basename",
+		"chown.container_path",
+		"chown.filename",
+		"chown.gid",
+		"chown.inode",
+		"chown.overlay_numlower",
+		"chown.retval",
+		"chown.uid",
+		"container.id",
+		"container.image.name",
+		"container.image.tag",
+		"exec.args",
+		"exec.auid",
+		"exec.basename",
+		"exec.container_path",
+		"exec.cookie",
+		"exec.envs",
+		"exec.filename",
+		"exec.group",
+		"exec.inode",
+		"exec.login_user",
+		"exec.name",
+		"exec.overlay_numlower",
+		"exec.ppid",
+		"exec.session_id",
+		"exec.tty_name",
+		"exec.user",
+		"kubernetes.namespace",
+		"kubernetes.pod.name",
+		"link.retval",
+		"link.source.basename",
+		"link.source.container_path",
+		"link.source.filename",
+		"link.source.inode",
+		"link.source.overlay_numlower",
+		"link.target.basename",
+		"link.target.container_path",
+		"link.target.filename",
+		"link.target.inode",
+		"link.target.overlay_numlower",
+		"mkdir.basename",
+		"mkdir.container_path",
+		"mkdir.filename",
+		"mkdir.inode",
+		"mkdir.mode",
+		"mkdir.overlay_numlower",
+		"mkdir.retval",
+		"namespace.retval",
+		"namespace.syscall",
+		"namespace.types",
+		"open.basename",
+		"open.container_path",
+		"open.filename",
+		"open.flags",
+		"open.inode",
+		"open.mode",
+		"open.overlay_numlower",
+		"open.retval",
+		"process.args",
+		"process.auid",
+		"process.basename",
+		"process.container_path",
+		"process.cookie",
+		"process.envs",
+		"process.filename",
+		"process.gid",
+		"process.group",
+		"process.inode",
+		"process.login_user",
+		"process.name",
+		"process.overlay_numlower",
+		"process.pid",
+		"process.ppid",
+		"process.session_id",
+		"process.tid",
+		"process.tty_name",
+		"process.uid",
+		"process.user",
+		"removexattr.basename",
+		"removexattr.container_path",
+		"removexattr.filename",
+		"removexattr.inode",
+		"removexattr.name",
+		"removexattr.namespace",
+		"removexattr.overlay_numlower",
+		"removexattr.retval",
+		"rename.new.basename",
+		"rename.new.container_path",
+		"rename.new.filename",
+		"rename.new.inode",
+		"rename.new.overlay_numlower",
+		"rename.old.basename",
+		"rename.old.container_path",
+		"rename.old.filename",
+		"rename.old.inode",
+		"rename.old.overlay_numlower",
+		"rename.retval",
+		"rmdir.basename",
+		"rmdir.container_path",
+		"rmdir.filename",
+		"rmdir.inode",
+		"rmdir.overlay_numlower",
+		"rmdir.retval",
+		"setxattr.basename",
+		"setxattr.container_path",
+		"setxattr.filename",
+		"setxattr.inode",
+		"setxattr.name",
+		"setxattr.namespace",
+		"setxattr.overlay_numlower",
+		"setxattr.retval",
+		"unlink.basename",
+		"unlink.container_path",
+		"unlink.filename",
+		"unlink.flags",
+		"unlink.inode",
+		"unlink.overlay_numlower",
+		"unlink.retval",
+		"utimes.basename",
+		"utimes.container_path",
+		"utimes.filename",
+		"utimes.inode",
+		"utimes.overlay_numlower",
+		"utimes.retval",
+	}
+}
+
 func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 	switch field {
 
+	case "cgroup_write.file":
+
+		return reflect.String, nil
+
+	case "cgroup_write.value":
+
+		return reflect.String, nil
+
 	case "chmod.basename":
 
 		return reflect.String, nil
@@ -1889,6 +2338,22 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 
 		return reflect.String, nil
 
+	case "container.image.name":
+
+		return reflect.String, nil
+
+	case "container.image.tag":
+
+		return reflect.String, nil
+
+	case "exec.args":
+
+		return reflect.String, nil
+
+	case "exec.auid":
+
+		return reflect.Int, nil
+
 	case "exec.basename":
 
 		return reflect.String, nil
@@ -1901,6 +2366,10 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 
 		return reflect.Int, nil
 
+	case "exec.envs":
+
+		return reflect.String, nil
+
 	case "exec.filename":
 
 		return reflect.String, nil
@@ -1913,6 +2382,10 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 
 		return reflect.Int, nil
 
+	case "exec.login_user":
+
+		return reflect.String, nil
+
 	case "exec.name":
 
 		return reflect.String, nil
@@ -1925,6 +2398,10 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 
 		return reflect.Int, nil
 
+	case "exec.session_id":
+
+		return reflect.Int, nil
+
 	case "exec.tty_name":
 
 		return reflect.String, nil
@@ -1933,6 +2410,14 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 
 		return reflect.String, nil
 
+	case "kubernetes.namespace":
+
+		return reflect.String, nil
+
+	case "kubernetes.pod.name":
+
+		return reflect.String, nil
+
 	case "link.retval":
 
 		return reflect.Int, nil
@@ -2005,6 +2490,18 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 
 		return reflect.Int, nil
 
+	case "namespace.retval":
+
+		return reflect.Int, nil
+
+	case "namespace.syscall":
+
+		return reflect.String, nil
+
+	case "namespace.types":
+
+		return reflect.Int, nil
+
 	case "open.basename":
 
 		return reflect.String, nil
@@ -2037,6 +2534,14 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 
 		return reflect.Int, nil
 
+	case "process.args":
+
+		return reflect.String, nil
+
+	case "process.auid":
+
+		return reflect.Int, nil
+
 	case "process.basename":
 
 		return reflect.String, nil
@@ -2049,6 +2554,10 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 
 		return reflect.Int, nil
 
+	case "process.envs":
+
+		return reflect.String, nil
+
 	case "process.filename":
 
 		return reflect.String, nil
@@ -2065,6 +2574,10 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 
 		return reflect.Int, nil
 
+	case "process.login_user":
+
+		return reflect.String, nil
+
 	case "process.name":
 
 		return reflect.String, nil
@@ -2081,6 +2594,10 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 
 		return reflect.Int, nil
 
+	case "process.session_id":
+
+		return reflect.Int, nil
+
 	case "process.tid":
 
 		return reflect.Int, nil
@@ -2290,6 +2807,20 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 	var ok bool
 	switch field {
 
+	case "cgroup_write.file":
+
+		if e.CgroupWrite.File, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "CgroupWrite.File"}
+		}
+		return nil
+
+	case "cgroup_write.value":
+
+		if e.CgroupWrite.Value, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "CgroupWrite.Value"}
+		}
+		return nil
+
 	case "chmod.basename":
 
 		if e.Chmod.BasenameStr, ok = value.(string); !ok {
@@ -2420,6 +2951,36 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		}
 		return nil
 
+	case "container.image.name":
+
+		if e.Container.ImageName, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Container.ImageName"}
+		}
+		return nil
+
+	case "container.image.tag":
+
+		if e.Container.ImageTag, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Container.ImageTag"}
+		}
+		return nil
+
+	case "exec.args":
+
+		if e.Exec.Args, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Exec.Args"}
+		}
+		return nil
+
+	case "exec.auid":
+
+		v, ok := value.(int)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Exec.AUID"}
+		}
+		e.Exec.AUID = uint32(v)
+		return nil
+
 	case "exec.basename":
 
 		if e.Exec.BasenameStr, ok = value.(string); !ok {
@@ -2443,6 +3004,13 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		e.Exec.Cookie = uint32(v)
 		return nil
 
+	case "exec.envs":
+
+		if e.Exec.Envs, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Exec.Envs"}
+		}
+		return nil
+
 	case "exec.filename":
 
 		if e.Exec.PathnameStr, ok = value.(string); !ok {
@@ -2466,6 +3034,13 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		e.Exec.Inode = uint64(v)
 		return nil
 
+	case "exec.login_user":
+
+		if e.Exec.LoginUser, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Exec.LoginUser"}
+		}
+		return nil
+
 	case "exec.name":
 
 		if e.Exec.Comm, ok = value.(string); !ok {
@@ -2491,6 +3066,15 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		e.Exec.PPid = uint32(v)
 		return nil
 
+	case "exec.session_id":
+
+		v, ok := value.(int)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Exec.SessionID"}
+		}
+		e.Exec.SessionID = uint32(v)
+		return nil
+
 	case "exec.tty_name":
 
 		if e.Exec.TTYName, ok = value.(string); !ok {
@@ -2505,6 +3089,20 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		}
 		return nil
 
+	case "kubernetes.namespace":
+
+		if e.Kubernetes.Namespace, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Kubernetes.Namespace"}
+		}
+		return nil
+
+	case "kubernetes.pod.name":
+
+		if e.Kubernetes.PodName, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Kubernetes.PodName"}
+		}
+		return nil
+
 	case "link.retval":
 
 		v, ok := value.(int)
@@ -2649,6 +3247,31 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		e.Mkdir.Retval = int64(v)
 		return nil
 
+	case "namespace.retval":
+
+		v, ok := value.(int)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Namespace.Retval"}
+		}
+		e.Namespace.Retval = int64(v)
+		return nil
+
+	case "namespace.syscall":
+
+		if e.Namespace.Syscall, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Namespace.Syscall"}
+		}
+		return nil
+
+	case "namespace.types":
+
+		v, ok := value.(int)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Namespace.Types"}
+		}
+		e.Namespace.Types = uint64(v)
+		return nil
+
 	case "open.basename":
 
 		if e.Open.BasenameStr, ok = value.(string); !ok {
@@ -2715,6 +3338,22 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		e.Open.Retval = int64(v)
 		return nil
 
+	case "process.args":
+
+		if e.Process.Args, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Process.Args"}
+		}
+		return nil
+
+	case "process.auid":
+
+		v, ok := value.(int)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Process.AUID"}
+		}
+		e.Process.AUID = uint32(v)
+		return nil
+
 	case "process.basename":
 
 		if e.Process.BasenameStr, ok = value.(string); !ok {
@@ -2738,6 +3377,13 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		e.Process.Cookie = uint32(v)
 		return nil
 
+	case "process.envs":
+
+		if e.Process.Envs, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Process.Envs"}
+		}
+		return nil
+
 	case "process.filename":
 
 		if e.Process.PathnameStr, ok = value.(string); !ok {
@@ -2770,6 +3416,13 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		e.Process.Inode = uint64(v)
 		return nil
 
+	case "process.login_user":
+
+		if e.Process.LoginUser, ok = value.(string); !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Process.LoginUser"}
+		}
+		return nil
+
 	case "process.name":
 
 		if e.Process.Comm, ok = value.(string); !ok {
@@ -2804,6 +3457,15 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		e.Process.PPid = uint32(v)
 		return nil
 
+	case "process.session_id":
+
+		v, ok := value.(int)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "Process.SessionID"}
+		}
+		e.Process.SessionID = uint32(v)
+		return nil
+
 	case "process.tid":
 
 		v, ok := value.(int)
@@ -9,10 +9,15 @@ package probe
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/DataDog/datadog-agent/pkg/security/ebpf"
 	"github.com/DataDog/datadog-agent/pkg/security/secl/eval"
+	"github.com/DataDog/datadog-agent/pkg/tagger/collectors"
 )
 
 func TestMkdirJSON(t *testing.T) {
@@ -68,3 +73,155 @@ func TestAbsolutePath(t *testing.T) {
 		t.Fatal("should return an error")
 	}
 }
+
+func TestProcessFlows(t *testing.T) {
+	data := make([]byte, maxProcessFlows*24)
+
+	putFlow := func(i int, family uint16, ip []byte, port uint16, count uint32) {
+		b := data[i*24:]
+		copy(b[0:16], ip)
+		ebpf.ByteOrder.PutUint16(b[16:18], family)
+		binary.BigEndian.PutUint16(b[18:20], port)
+		ebpf.ByteOrder.PutUint32(b[20:24], count)
+	}
+	putFlow(0, syscall.AF_INET, []byte{10, 0, 0, 1}, 443, 2)
+	putFlow(2, syscall.AF_INET6, []byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}, 53, 5)
+
+	var flows ProcessFlows
+	read, err := flows.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != len(data) {
+		t.Fatalf("expected %d bytes to be read, got %d", len(data), read)
+	}
+
+	d, err := flows.marshalJSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"family":"ipv6","ip":"2001:db8::1","port":53,"count":5},{"family":"ipv4","ip":"10.0.0.1","port":443,"count":2}]`
+	if string(d) != expected {
+		t.Fatalf("expected %s, got %s", expected, d)
+	}
+
+	if _, err := flows.UnmarshalBinary(data[:24]); err != ErrNotEnoughData {
+		t.Fatalf("expected ErrNotEnoughData, got %v", err)
+	}
+}
+
+func TestNamespaceEvent(t *testing.T) {
+	data := make([]byte, 24)
+	ebpf.ByteOrder.PutUint64(data[0:8], 0)
+	ebpf.ByteOrder.PutUint64(data[8:16], syscall.CLONE_NEWUSER|syscall.CLONE_NEWNS)
+	ebpf.ByteOrder.PutUint32(data[16:20], 1)
+
+	var e NamespaceEvent
+	read, err := e.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != len(data) {
+		t.Fatalf("expected %d bytes to be read, got %d", len(data), read)
+	}
+
+	d, err := e.marshalJSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"syscall":"unshare","types":"CLONE_NEWNS | CLONE_NEWUSER"}`
+	if string(d) != expected {
+		t.Fatalf("expected %s, got %s", expected, d)
+	}
+
+	if _, err := e.UnmarshalBinary(data[:16]); err != ErrNotEnoughData {
+		t.Fatalf("expected ErrNotEnoughData, got %v", err)
+	}
+}
+
+func TestCgroupWriteEvent(t *testing.T) {
+	data := make([]byte, 264)
+	ebpf.ByteOrder.PutUint32(data[0:4], 1)
+	copy(data[8:], `/tmp/"escape".sh`)
+
+	var e CgroupWriteEvent
+	read, err := e.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != len(data) {
+		t.Fatalf("expected %d bytes to be read, got %d", len(data), read)
+	}
+
+	d, err := e.marshalJSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"file":"release_agent","value":"/tmp/\"escape\".sh"}`
+	if string(d) != expected {
+		t.Fatalf("expected %s, got %s", expected, d)
+	}
+
+	if _, err := e.UnmarshalBinary(data[:8]); err != ErrNotEnoughData {
+		t.Fatalf("expected ErrNotEnoughData, got %v", err)
+	}
+}
+
+type fakeContainerTagger map[string][]string
+
+func (t fakeContainerTagger) Tag(entity string, cardinality collectors.TagCardinality) ([]string, error) {
+	return t[entity], nil
+}
+
+func TestContainerTags(t *testing.T) {
+	cr := newContainerResolver(fakeContainerTagger{
+		"container_id://abc": {"image_name:nginx", "image_tag:1.19", "pod_name:web-1", "kube_namespace:prod"},
+	}, nil)
+	resolvers := &Resolvers{
+		ContainerResolver: cr,
+	}
+
+	// the tags are resolved in the background
+	e := NewEvent(resolvers)
+	e.Container.ID = "abc"
+	if value, _ := e.GetFieldValue("container.image.name"); value != "" {
+		t.Errorf("expected no image name before the tags are resolved, got %v", value)
+	}
+
+	if len(cr.queue) != 1 {
+		t.Fatalf("expected the container to be queued once, got %d", len(cr.queue))
+	}
+	cr.resolveTags(<-cr.queue, time.Now())
+
+	e = NewEvent(resolvers)
+	e.Container.ID = "abc"
+
+	for field, expected := range map[string]string{
+		"container.id":         "abc",
+		"container.image.name": "nginx",
+		"container.image.tag":  "1.19",
+		"kubernetes.pod.name":  "web-1",
+		"kubernetes.namespace": "prod",
+	} {
+		value, err := e.GetFieldValue(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected {
+			t.Errorf("expected %s for %s, got %v", expected, field, value)
+		}
+	}
+
+	e = NewEvent(resolvers)
+	e.Container.ID = "def"
+	e.GetFieldValue("container.image.name")
+	cr.resolveTags(<-cr.queue, time.Now())
+	if value, _ := e.GetFieldValue("container.image.name"); value != "" {
+		t.Errorf("expected no image name, got %v", value)
+	}
+
+	cr.expireTags(time.Now().Add(3 * containerTagsTTL))
+	if len(cr.tags) != 0 {
+		t.Errorf("expected the tags of the containers to be expired, got %d", len(cr.tags))
+	}
+}
@@ -76,6 +76,7 @@ type Probe struct {
 	startTime          time.Time
 	event              *Event
 	mountEvent         *Event
+	cancel             context.CancelFunc
 }
 
 // GetResolvers returns the resolvers of Probe
@@ -158,6 +159,17 @@ func (p *Probe) Init() error {
 		return err
 	}
 
+	if p.config.ExecArgs {
+		execArgsEnabledMap, err := p.Map("exec_args_enabled")
+		if err != nil {
+			return err
+		}
+
+		if err := execArgsEnabledMap.Put(ebpf.ZeroUint32MapItem, uint32(1)); err != nil {
+			return errors.Wrap(err, "failed to enable exec arguments capture")
+		}
+	}
+
 	if err := p.resolvers.Start(); err != nil {
 		return err
 	}
@@ -177,7 +189,16 @@ func (p *Probe) Start() error {
 	if err := p.manager.Start(); err != nil {
 		return err
 	}
-	go p.loadController.Start(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	go p.loadController.Start(ctx)
+	go p.resolvers.TimeResolver.Start(ctx)
+	go p.resolvers.ContainerResolver.Start(ctx)
+	if period := p.config.ProcessCacheReconciliationPeriod; period > 0 {
+		go p.resolvers.StartReconciliation(ctx, period)
+	}
 	return nil
 }
 
@@ -201,6 +222,18 @@ func (p *Probe) SendStats(statsdClient *statsd.Client) error {
 		}
 	}
 
+	if err := p.resolvers.TimeResolver.SendStats(statsdClient); err != nil {
+		return errors.Wrap(err, "failed to send time resolver stats")
+	}
+
+	if err := p.resolvers.DentryResolver.SendStats(statsdClient); err != nil {
+		return errors.Wrap(err, "failed to send dentry resolver stats")
+	}
+
+	if err := p.resolvers.ProcessResolver.SendStats(statsdClient); err != nil {
+		return errors.Wrap(err, "failed to send process resolver stats")
+	}
+
 	if err := statsdClient.Count(MetricPrefix+".events.lost", p.eventsStats.GetAndResetLost(), nil, 1.0); err != nil {
 		return errors.Wrap(err, "failed to send events.lost metric")
 	}
@@ -250,6 +283,11 @@ func (p *Probe) GetStats() (map[string]interface{}, error) {
 		perEventType[eventType.String()] = p.eventsStats.GetEventCount(eventType)
 	}
 
+	stats["time_resolver"] = map[string]interface{}{
+		"error_bound": p.resolvers.TimeResolver.GetErrorBound().String(),
+		"drift":       p.resolvers.TimeResolver.GetLastDrift().String(),
+	}
+
 	return stats, err
 }
 
@@ -295,6 +333,8 @@ func (p *Probe) handleMountEvent(CPU int, data []byte, perfMap *manager.PerfMap,
 	}
 	offset += read
 
+	event.TimestampRaw = p.resolvers.TimeResolver.CorrectCPUTimestamp(CPU, event.TimestampRaw)
+
 	eventType := EventType(event.Type)
 
 	log.Tracef("Decoding mount event %s(%d)", eventType, event.Type)
@@ -353,6 +393,20 @@ func (p *Probe) handleEvent(CPU int, data []byte, perfMap *manager.PerfMap, mana
 
 	log.Tracef("Decoding event %s(%d)", eventType, event.Type)
 
+	if eventType == ExecArgsEventType {
+		var args ExecArgs
+		if _, err := args.UnmarshalBinary(data[offset:]); err != nil {
+			log.Errorf("failed to decode exec arguments: %s (offset %d, len %d)", err, offset, len(data))
+			return
+		}
+
+		// the arguments are attached to the process cache entry on the exec event that follows
+		p.resolvers.ExecArgsResolver.Add(&args)
+
+		// no need to dispatch
+		return
+	}
+
 	if eventType == InvalidateDentryEventType {
 		if _, err := event.InvalidateDentry.UnmarshalBinary(data[offset:]); err != nil {
 			log.Errorf("failed to decode invalidate dentry event: %s (offset %d, len %d)", err, offset, len(data))
@@ -452,15 +506,42 @@ func (p *Probe) handleEvent(CPU int, data []byte, perfMap *manager.PerfMap, mana
 			log.Errorf("failed to decode removexattr event: %s (offset %d, len %d)", err, offset, len(data))
 			return
 		}
+	case NamespaceEventType:
+		if _, err := event.Namespace.UnmarshalBinary(data[offset:]); err != nil {
+			log.Errorf("failed to decode namespace event: %s (offset %d, len %d)", err, offset, len(data))
+			return
+		}
+	case CgroupWriteEventType:
+		if _, err := event.CgroupWrite.UnmarshalBinary(data[offset:]); err != nil {
+			log.Errorf("failed to decode cgroup write event: %s (offset %d, len %d)", err, offset, len(data))
+			return
+		}
 	case ExecEventType, ForkEventType:
-		if _, err := event.Exec.UnmarshalEvent(data[offset:], event); err != nil {
+		read, err = event.Exec.UnmarshalEvent(data[offset:], event)
+		if err != nil {
 			log.Errorf("failed to decode exec event: %s (offset %d, len %d)", err, offset, len(data))
 			return
 		}
+		offset += read
+
+		if _, err := event.Flows.UnmarshalBinary(data[offset:]); err != nil {
+			log.Errorf("failed to decode network flows: %s (offset %d, len %d)", err, offset, len(data))
+			return
+		}
+
+		if eventType == ExecEventType {
+			p.resolvers.ExecArgsResolver.Resolve(event.processCacheEntry)
+		}
 
 		// update the process resolver cache
 		event.updateProcessCachePointer(p.resolvers.ProcessResolver.AddEntry(event.Process.Pid, event.processCacheEntry))
 	case ExitEventType:
+		if _, err := event.Flows.UnmarshalBinary(data[offset:]); err != nil {
+			log.Errorf("failed to decode network flows: %s (offset %d, len %d)", err, offset, len(data))
+			return
+		}
+
+		p.resolvers.ExecArgsResolver.Delete(event.Process.Pid)
 		defer p.resolvers.ProcessResolver.DeleteEntry(event.Process.Pid, event.ResolveEventTimestamp())
 	default:
 		log.Errorf("unsupported event type %d on perf map %s", eventType, perfMap.Name)
@@ -585,6 +666,10 @@ func (p *Probe) SelectProbes(rs *rules.RuleSet) error {
 		log.Tracef("probe %s selected", id)
 	}
 
+	if p.config.NetworkFlows {
+		activatedProbes = append(activatedProbes, probes.NetworkFlowsSelectors...)
+	}
+
 	enabledEventsMap, err := p.Map("enabled_events")
 	if err != nil {
 		return err
@@ -703,6 +788,11 @@ func (p *Probe) Snapshot() error {
 
 // Close the probe
 func (p *Probe) Close() error {
+	// stop the background routines of the probe and of its resolvers
+	if p.cancel != nil {
+		p.cancel()
+	}
+
 	return p.manager.Stop(manager.CleanAll)
 }
 
@@ -760,6 +850,11 @@ func NewProbe(config *config.Config, client *statsd.Client) (*Probe, error) {
 		p.managerOptions.ActivatedProbes = append(p.managerOptions.ActivatedProbes, probes.SyscallMonitorSelectors...)
 	}
 
+	if p.config.NetworkFlows {
+		// Add network flows probes
+		p.managerOptions.ActivatedProbes = append(p.managerOptions.ActivatedProbes, probes.NetworkFlowsSelectors...)
+	}
+
 	resolvers, err := NewResolvers(p)
 	if err != nil {
 		return nil, err
@@ -921,4 +1016,6 @@ func init() {
 				return "removexattr.filename", event.RemoveXAttr.MountID, event.RemoveXAttr.Inode, event.RemoveXAttr.PathID, false
 			}))
 	SupportedDiscarders["removexattr.filename"] = true
+
+	allDiscarderHandlers["namespace"] = processDiscarderWrapper(NamespaceEventType, nil)
 }
@@ -9,6 +9,7 @@ package probe
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 )
 
@@ -57,7 +58,7 @@ func (pc *ProcessCacheEntry) UnmarshalBinary(data []byte, resolvers *Resolvers,
 	var read int
 
 	if unmarshalContext {
-		if len(data) < 200 {
+		if len(data) < 208 {
 			return 0, ErrNotEnoughData
 		}
 
@@ -67,7 +68,7 @@ func (pc *ProcessCacheEntry) UnmarshalBinary(data []byte, resolvers *Resolvers,
 		}
 		read += offset
 	} else {
-		if len(data) < 136 {
+		if len(data) < 144 {
 			return 0, ErrNotEnoughData
 		}
 	}
@@ -107,6 +108,19 @@ func (pc *ProcessCacheEntry) marshalJSON(resolvers *Resolvers, topLevelProcess b
 	fmt.Fprintf(&buf, `"ppid":%d,`, pc.PPid)
 	fmt.Fprintf(&buf, `"cookie":%d,`, pc.Cookie)
 	fmt.Fprintf(&buf, `"tty":"%s",`, pc.TTYName)
+	if pc.SessionResolved {
+		fmt.Fprintf(&buf, `"auid":%d,`, auditID(pc.AUID))
+		fmt.Fprintf(&buf, `"session_id":%d,`, auditID(pc.SessionID))
+	}
+	if len(pc.Args) > 0 {
+		args, _ := json.Marshal(pc.Args)
+		fmt.Fprintf(&buf, `"args":%s,`, args)
+		fmt.Fprintf(&buf, `"args_truncated":%t,`, pc.ArgsTruncated)
+	}
+	if len(pc.Envs) > 0 {
+		envs, _ := json.Marshal(pc.Envs)
+		fmt.Fprintf(&buf, `"envs":%s,`, envs)
+	}
 	fmt.Fprintf(&buf, `"inode":%d,`, pc.Inode)
 	fmt.Fprintf(&buf, `"mount_id":%d,`, pc.MountID)
 	fmt.Fprintf(&buf, `"overlay_numlower":%d,`, pc.OverlayNumLower)
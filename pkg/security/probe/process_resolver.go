@@ -11,9 +11,11 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/DataDog/datadog-go/statsd"
 	lib "github.com/DataDog/ebpf"
 	"github.com/DataDog/ebpf/manager"
 	"github.com/pkg/errors"
@@ -59,6 +61,10 @@ type ProcessResolver struct {
 	pidCookieMap   *lib.Map
 
 	entryCache map[uint32]*ProcessCacheEntry
+
+	// divergences between the cache and /proc found by the reconciliations, since the last stats were sent
+	missingEntries int64
+	staleEntries   int64
 }
 
 // GetProbes returns the probes required by the snapshot
@@ -137,6 +143,12 @@ func (p *ProcessResolver) enrichEventFromProc(entry *ProcessCacheEntry, proc *pr
 	entry.Comm = proc.Name
 	entry.PPid = uint32(proc.Ppid)
 	entry.TTYName = utils.PidTTY(pid)
+	// the audit login UID and session ID are sent by the kernel for the processes started after the probe, only
+	// the processes of the snapshot read them from /proc
+	p.resolveSessionFromProc(entry, pid)
+	if p.probe.config.ExecArgs {
+		p.resolvers.ExecArgsResolver.ResolveFromCmdline(entry, proc.Cmdline)
+	}
 	entry.ProcessContext.Pid = pid
 	entry.ProcessContext.Tid = pid
 	if len(proc.Uids) > 0 {
@@ -148,6 +160,21 @@ func (p *ProcessResolver) enrichEventFromProc(entry *ProcessCacheEntry, proc *pr
 	return nil
 }
 
+// resolveSessionFromProc resolves the audit login UID and session ID of an entry from /proc
+func (p *ProcessResolver) resolveSessionFromProc(entry *ProcessCacheEntry, pid uint32) {
+	auid, err := utils.PidLoginUID(pid)
+	if err == nil {
+		entry.AUID = auid
+		entry.SessionID, err = utils.PidSessionID(pid)
+	}
+	if err != nil {
+		// the kernel may not be built with audit support
+		entry.AUID = auditIDUnset
+		entry.SessionID = auditIDUnset
+	}
+	entry.SessionResolved = true
+}
+
 // retrieveInodeInfo fetches inode metadata from kernel space
 func (p *ProcessResolver) retrieveInodeInfo(inode uint64) (*InodeInfo, error) {
 	inodeb := make([]byte, 8)
@@ -188,7 +215,17 @@ func (p *ProcessResolver) insertEntry(pid uint32, entry *ProcessCacheEntry) *Pro
 			newEntry.Group = entry.Group
 			newEntry.ForkTimestamp = entry.ForkTimestamp
 			newEntry.PPid = entry.PPid
+			if entry.SessionResolved {
+				newEntry.AUID = entry.AUID
+				newEntry.SessionID = entry.SessionID
+				newEntry.SessionResolved = true
+			}
 			entry = newEntry
+		} else if entry.Cookie != 0 && entry.Cookie == parent.Cookie && len(entry.Args) == 0 {
+			// a forked process runs the same image as its parent until it executes a new one
+			entry.Args = parent.Args
+			entry.ArgsTruncated = parent.ArgsTruncated
+			entry.Envs = parent.Envs
 		}
 
 		// inherit the container ID from the parent if necessary. If a container is already running when system-probe
@@ -300,7 +337,7 @@ func (p *ProcessResolver) resolveWithKernelMaps(pid uint32) *ProcessCacheEntry {
 
 	entry := NewProcessCacheEntry()
 	data := append(entryb, cookieb...)
-	if len(data) < 208 {
+	if len(data) < 216 {
 		// not enough data
 		return nil
 	}
@@ -404,7 +441,13 @@ func (p *ProcessResolver) syncCache(proc *process.FilledProcess) (*ProcessCacheE
 
 	log.Tracef("New process cache entry added: %s %s %d/%d", proc.Name, entry.PathnameStr, pid, entry.Inode)
 
-	// loop through the children of the newly inserted process to propagate the container ID if necessary
+	propagateContainerID(entry)
+
+	return entry, true
+}
+
+// propagateContainerID sets the container ID of the children of an entry if necessary
+func propagateContainerID(entry *ProcessCacheEntry) {
 	if len(entry.ID) > 0 {
 		for _, child := range entry.Children {
 			if len(child.ID) == 0 {
@@ -412,8 +455,153 @@ func (p *ProcessResolver) syncCache(proc *process.FilledProcess) (*ProcessCacheE
 			}
 		}
 	}
+}
 
-	return entry, true
+// isSynced returns whether the cache holds a complete entry for the given pid
+func (p *ProcessResolver) isSynced(pid uint32) bool {
+	p.RLock()
+	defer p.RUnlock()
+	entry, exists := p.entryCache[pid]
+	return exists && !entry.ExecTimestamp.IsZero()
+}
+
+// Reconcile repairs the cache from a snapshot of /proc: the processes whose fork or exec events were missed, such as
+// when events are lost under load, are added to the cache, and the entries of the processes whose exit events were
+// missed are deleted. The entries are collected from /proc without holding the lock of the cache, so that the events
+// aren't blocked, and are then swapped in under the lock.
+func (p *ProcessResolver) Reconcile(processes map[int32]*process.FilledProcess) {
+	var synced []*ProcessCacheEntry
+	for _, proc := range processes {
+		// If Exe is not set, the process is a short lived process and its /proc entry has already expired.
+		if len(proc.Exe) == 0 {
+			continue
+		}
+		if entry := p.newEntryFromProc(proc); entry != nil {
+			synced = append(synced, entry)
+		}
+	}
+
+	// the entries of the processes which aren't part of the snapshot are candidates for deletion
+	exited := make(map[uint32]*ProcessCacheEntry)
+	p.RLock()
+	for pid, entry := range p.entryCache {
+		if !entry.ExitTimestamp.IsZero() {
+			continue
+		}
+		if _, alive := processes[int32(pid)]; !alive {
+			exited[pid] = entry
+		}
+	}
+	p.RUnlock()
+
+	for pid := range exited {
+		// the process may have started after the snapshot
+		if _, err := os.Stat(utils.ProcPidPath(pid)); !os.IsNotExist(err) {
+			delete(exited, pid)
+		}
+	}
+
+	var missing, stale int64
+	now := time.Now()
+
+	p.Lock()
+	for _, entry := range synced {
+		if p.swapEntry(entry) {
+			missing++
+		}
+	}
+	for pid, entry := range exited {
+		// the entry may have been replaced or deleted by an event in the meantime
+		if current, exists := p.entryCache[pid]; !exists || current != entry || !entry.ExitTimestamp.IsZero() {
+			continue
+		}
+		entry.ExitTimestamp = now
+		p.recursiveDelete(entry)
+		stale++
+	}
+	p.Unlock()
+
+	if missing > 0 || stale > 0 {
+		log.Debugf("process cache reconciled: %d missing entries added, %d stale entries deleted", missing, stale)
+	}
+	atomic.AddInt64(&p.missingEntries, missing)
+	atomic.AddInt64(&p.staleEntries, stale)
+}
+
+// newEntryFromProc returns an entry filled from /proc for a process which isn't synced in the cache, or nil
+func (p *ProcessResolver) newEntryFromProc(proc *process.FilledProcess) *ProcessCacheEntry {
+	pid := uint32(proc.Pid)
+	if pid == 0 {
+		return nil
+	}
+
+	p.RLock()
+	cached, inCache := p.entryCache[pid]
+	if inCache && !cached.ExecTimestamp.IsZero() {
+		p.RUnlock()
+		return nil
+	}
+	entry := NewProcessCacheEntry()
+	if inCache {
+		// keep what was learned from the fork event of the process
+		entry = cached.Copy()
+	}
+	p.RUnlock()
+
+	if err := p.enrichEventFromProc(entry, proc); err != nil {
+		return nil
+	}
+	return entry
+}
+
+// swapEntry inserts an entry filled from /proc in the cache, unless an event synced the process in the meantime. It
+// returns whether the entry was inserted. The lock of the cache must be held.
+func (p *ProcessResolver) swapEntry(entry *ProcessCacheEntry) bool {
+	pid := entry.Pid
+	if cached, inCache := p.entryCache[pid]; inCache {
+		if !cached.ExecTimestamp.IsZero() {
+			return false
+		}
+
+		// the cached entry may have been created for the parent of other processes
+		for childPid, child := range cached.Children {
+			entry.Children[childPid] = child
+			child.Parent = entry
+		}
+	}
+
+	entry = p.insertEntry(pid, entry)
+
+	log.Tracef("New process cache entry added: %s %d/%d", entry.PathnameStr, pid, entry.Inode)
+
+	propagateContainerID(entry)
+
+	return true
+}
+
+// SendStats sends the process resolver metrics
+func (p *ProcessResolver) SendStats(statsdClient *statsd.Client) error {
+	p.RLock()
+	size := len(p.entryCache)
+	p.RUnlock()
+	if err := statsdClient.Gauge(MetricPrefix+".process_resolver.cache_size", float64(size), nil, 1.0); err != nil {
+		return err
+	}
+
+	for _, counter := range []struct {
+		value *int64
+		tags  []string
+	}{
+		{value: &p.missingEntries, tags: []string{"divergence:missing"}},
+		{value: &p.staleEntries, tags: []string{"divergence:stale"}},
+	} {
+		if value := atomic.SwapInt64(counter.value, 0); value > 0 {
+			if err := statsdClient.Count(MetricPrefix+".process_resolver.divergences", value, counter.tags, 1.0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // NewProcessResolver returns a new process resolver
@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probe
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/security/config"
+)
+
+func TestProcessResolverReconcile(t *testing.T) {
+	resolver, err := NewProcessResolver(&Probe{config: &config.Config{}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newEntry := func(pid, ppid uint32) *ProcessCacheEntry {
+		entry := NewProcessCacheEntry()
+		entry.Pid = pid
+		entry.Tid = pid
+		entry.PPid = ppid
+		entry.ExecTimestamp = time.Now()
+		return entry
+	}
+
+	// a running process, not part of the snapshot because it started after it
+	self := uint32(os.Getpid())
+	resolver.AddEntry(self, newEntry(self, 1))
+	// a process whose exit was missed, along with its parent
+	resolver.AddEntry(4194300, newEntry(4194300, 4194299))
+
+	resolver.Reconcile(nil)
+
+	assert.NotNil(t, resolver.Get(self))
+	assert.NotNil(t, resolver.Get(1))
+	assert.Nil(t, resolver.Get(4194300))
+	assert.Nil(t, resolver.Get(4194299))
+	assert.EqualValues(t, 2, resolver.staleEntries)
+	assert.EqualValues(t, 0, resolver.missingEntries)
+}
@@ -0,0 +1,246 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probe
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/DataDog/datadog-agent/pkg/security/config"
+)
+
+// redactionKind is the kind of value held by a field, which defines how it is redacted
+type redactionKind int
+
+const (
+	redactNone redactionKind = iota
+	// redactPath is the kind of the paths, replaced when they are not displayed
+	redactPath
+	// redactArgs is the kind of the process arguments and environment variables, of which the secrets are removed
+	redactArgs
+	// redactName is the kind of the user and group names, which can be hashed
+	redactName
+)
+
+var (
+	// redactedKeys are the kinds of the values, by key
+	redactedKeys = map[string]redactionKind{
+		"filename":       redactPath,
+		"container_path": redactPath,
+		"mount_point":    redactPath,
+		"root":           redactPath,
+		"args":           redactArgs,
+		"envs":           redactArgs,
+		"user":           redactName,
+		"login_user":     redactName,
+		"group":          redactName,
+	}
+	// redactedFields are the kinds of the values of which the key is too generic to be redacted on its own, by
+	// field name
+	redactedFields = map[string]redactionKind{
+		"cgroup_write.file": redactPath,
+	}
+)
+
+// fieldRedactionKind returns the kind of the value of key, held by the object of key parent
+func fieldRedactionKind(parent, key string) redactionKind {
+	if kind, ok := redactedFields[parent+"."+key]; ok {
+		return kind
+	}
+	return redactedKeys[key]
+}
+
+// EventRedactor redacts the events before they leave the host, whatever their sink. The values are redacted
+// according to the kind of their field: paths which are not displayed are replaced, secrets are removed from
+// process arguments, and user and group names can be hashed.
+type EventRedactor struct {
+	allowPaths    []string
+	denyPaths     []string
+	argsRedactors []*regexp.Regexp
+	hashUserNames bool
+}
+
+// NewEventRedactor returns the event redactor configured in cfg, or nil if events are not redacted
+func NewEventRedactor(cfg *config.Config) (*EventRedactor, error) {
+	if len(cfg.RedactionAllowPaths) == 0 && len(cfg.RedactionDenyPaths) == 0 &&
+		len(cfg.RedactionArgsPatterns) == 0 && !cfg.RedactionHashUserNames {
+		return nil, nil
+	}
+
+	r := &EventRedactor{
+		allowPaths:    cfg.RedactionAllowPaths,
+		denyPaths:     cfg.RedactionDenyPaths,
+		hashUserNames: cfg.RedactionHashUserNames,
+	}
+
+	for _, patterns := range [][]string{r.allowPaths, r.denyPaths} {
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, errors.Wrapf(err, "invalid redaction path pattern `%s`", pattern)
+			}
+		}
+	}
+
+	for _, pattern := range cfg.RedactionArgsPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid redaction arguments pattern `%s`", pattern)
+		}
+		r.argsRedactors = append(r.argsRedactors, re)
+	}
+
+	return r, nil
+}
+
+// Redact returns the JSON encoding of an event with its values redacted. A nil redactor returns data as is.
+func (r *EventRedactor) Redact(data []byte) ([]byte, error) {
+	if r == nil {
+		return data, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.Wrap(err, "failed to decode event")
+	}
+	return json.Marshal(r.redactValue("", "", value))
+}
+
+func (r *EventRedactor) redactValue(parent, key string, value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			value[k] = r.redactValue(key, k, v)
+		}
+	case []interface{}:
+		// the elements of an array are redacted according to the key of the array
+		for i, v := range value {
+			value[i] = r.redactValue(parent, key, v)
+		}
+	case string:
+		return r.redactString(fieldRedactionKind(parent, key), value)
+	}
+	return value
+}
+
+// RedactField returns the value of an event field, such as "open.filename", redacted the same way as the
+// values of serialized events. A nil redactor returns value as is.
+func (r *EventRedactor) RedactField(field string, value string) string {
+	if r == nil {
+		return value
+	}
+	return r.redactString(r.fieldKind(field), value)
+}
+
+// fieldKind returns the kind of the value of an event field
+func (r *EventRedactor) fieldKind(field string) redactionKind {
+	if kind, ok := redactedFields[field]; ok {
+		return kind
+	}
+	return redactedKeys[field[strings.LastIndexByte(field, '.')+1:]]
+}
+
+// RedactFields redacts the values of event fields, keyed by field name such as "open.filename", the same way
+// as the values of serialized events
+func (r *EventRedactor) RedactFields(fields map[string]interface{}) {
+	if r == nil {
+		return
+	}
+
+	// the base name of a file is redacted along with its path, which has to be checked before being redacted
+	var basenames []string
+	for field := range fields {
+		if prefix := strings.TrimSuffix(field, "basename"); prefix != field && (prefix == "" || strings.HasSuffix(prefix, ".")) {
+			if filename, ok := fields[prefix+"filename"].(string); ok && !r.pathDisplayed(filename) {
+				basenames = append(basenames, field)
+			}
+		}
+	}
+
+	for field, value := range fields {
+		kind := r.fieldKind(field)
+		switch value := value.(type) {
+		case string:
+			fields[field] = r.redactString(kind, value)
+		case []string:
+			redacted := make([]string, len(value))
+			for i, v := range value {
+				redacted[i] = r.redactString(kind, v)
+			}
+			fields[field] = redacted
+		}
+	}
+
+	for _, field := range basenames {
+		fields[field] = redactedSecret
+	}
+}
+
+func (r *EventRedactor) redactString(kind redactionKind, s string) string {
+	if s == "" {
+		return s
+	}
+
+	switch kind {
+	case redactPath:
+		if !r.pathDisplayed(s) {
+			return redactedSecret
+		}
+	case redactArgs:
+		return redactSecrets(r.argsRedactors, s)
+	case redactName:
+		if r.hashUserNames {
+			return hashUserName(s)
+		}
+	}
+	return s
+}
+
+// pathDisplayed returns whether a path is displayed: it must not match the denied paths and, when there are
+// allowed paths, it must match one of them
+func (r *EventRedactor) pathDisplayed(path string) bool {
+	if path == "" {
+		return true
+	}
+	if pathMatches(r.denyPaths, path) {
+		return false
+	}
+	return len(r.allowPaths) == 0 || pathMatches(r.allowPaths, path)
+}
+
+// pathMatches returns whether one of the glob patterns matches a path or one of its parent directories
+func pathMatches(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		for p := path; ; {
+			if matched, _ := filepath.Match(pattern, p); matched {
+				return true
+			}
+			parent := filepath.Dir(p)
+			if parent == p {
+				break
+			}
+			p = parent
+		}
+	}
+	return false
+}
+
+// hashUserName returns the first 16 hexadecimal characters of the SHA-256 hash of a user or group name, so
+// that the events of a user can still be correlated
+func hashUserName(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:8])
+}
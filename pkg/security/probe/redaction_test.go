@@ -0,0 +1,245 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probe
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/security/config"
+)
+
+func newTestEventRedactor(t *testing.T) *EventRedactor {
+	redactor, err := NewEventRedactor(&config.Config{
+		RedactionAllowPaths:    []string{"/etc", "/usr/bin/*"},
+		RedactionDenyPaths:     []string{"/etc/shadow", "/etc/ssl/private"},
+		RedactionArgsPatterns:  []string{`--password=(\S+)`},
+		RedactionHashUserNames: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redactor
+}
+
+func TestEventRedactorDisabled(t *testing.T) {
+	redactor, err := NewEventRedactor(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redactor != nil {
+		t.Fatal("expected no redactor when redaction is not configured")
+	}
+
+	data := []byte(`{"file":{"filename":"/home/user/.ssh/id_rsa"}}`)
+	redacted, err := redactor.Redact(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(redacted) != string(data) {
+		t.Errorf("expected event to be left as is, got `%s`", redacted)
+	}
+}
+
+func TestEventRedactorInvalidPatterns(t *testing.T) {
+	for _, cfg := range []*config.Config{
+		{RedactionAllowPaths: []string{"/etc/["}},
+		{RedactionDenyPaths: []string{"/etc/["}},
+		{RedactionArgsPatterns: []string{"("}},
+	} {
+		if _, err := NewEventRedactor(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestEventRedactorRedact(t *testing.T) {
+	redactor := newTestEventRedactor(t)
+
+	data := []byte(`{
+		"id": "1",
+		"file": {"filename": "/etc/passwd", "container_path": "/home/user/notes", "inode": 42},
+		"process": {
+			"user": "root",
+			"filename": "/usr/bin/mysql",
+			"args": "-u admin --password=secret",
+			"ancestors": [
+				{"user": "", "filename": "/etc/ssl/private/key.pem"},
+				{"filename": "/bin/bash", "envs": ["TOKEN=abc", "PASS --password=secret"]}
+			]
+		}
+	}`)
+	redacted, err := redactor.Redact(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var event, expected interface{}
+	if err := json.Unmarshal(redacted, &event); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{
+		"id": "1",
+		"file": {"filename": "/etc/passwd", "container_path": "********", "inode": 42},
+		"process": {
+			"user": "4813494d137e1631",
+			"filename": "/usr/bin/mysql",
+			"args": "-u admin --password=********",
+			"ancestors": [
+				{"user": "", "filename": "********"},
+				{"filename": "********", "envs": ["TOKEN=abc", "PASS --password=********"]}
+			]
+		}
+	}`), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, event) {
+		t.Errorf("expected event to be redacted to `%v`, got `%v`", expected, event)
+	}
+
+	if _, err := redactor.Redact([]byte(`{`)); err == nil {
+		t.Error("expected an error for an invalid event")
+	}
+}
+
+func TestEventRedactorRedactFields(t *testing.T) {
+	redactor := newTestEventRedactor(t)
+
+	fields := map[string]interface{}{
+		"open.filename":     "/etc/shadow",
+		"open.basename":     "shadow",
+		"process.filename":  "/usr/bin/mysql",
+		"process.basename":  "mysql",
+		"process.args":      "--password=secret",
+		"process.user":      "root",
+		"process.pid":       1,
+		"exec.envs":         []string{"--password=secret"},
+		"mkdir.basename":    "dir",
+		"container.id":      "abc",
+		"process.tty_name":  "pts0",
+		"process.login_uid": 0,
+		"process.group":     "wheel",
+		"cgroup_write.file": "/sys/fs/cgroup/rdma/release_agent",
+	}
+	redactor.RedactFields(fields)
+
+	expected := map[string]interface{}{
+		"open.filename":     "********",
+		"open.basename":     "********",
+		"process.filename":  "/usr/bin/mysql",
+		"process.basename":  "mysql",
+		"process.args":      "--password=********",
+		"process.user":      "4813494d137e1631",
+		"process.pid":       1,
+		"exec.envs":         []string{"--password=********"},
+		"mkdir.basename":    "dir",
+		"container.id":      "abc",
+		"process.tty_name":  "pts0",
+		"process.login_uid": 0,
+		"process.group":     "ba59926159d2aa25",
+		"cgroup_write.file": "********",
+	}
+	if !reflect.DeepEqual(expected, fields) {
+		t.Errorf("expected fields to be redacted to `%v`, got `%v`", expected, fields)
+	}
+}
+
+func TestEventRedactorEventTypes(t *testing.T) {
+	redactor := newTestEventRedactor(t)
+
+	for _, test := range []struct {
+		name     string
+		event    string
+		expected string
+	}{
+		{
+			name:     "open",
+			event:    `{"file": {"filename": "/home/user/.ssh/id_rsa", "container_path": "/etc/hosts", "flags": "O_RDONLY"}}`,
+			expected: `{"file": {"filename": "********", "container_path": "/etc/hosts", "flags": "O_RDONLY"}}`,
+		},
+		{
+			name:     "rename",
+			event:    `{"file": {"filename": "/tmp/a"}, "destination": {"filename": "/etc/cron.d/job"}}`,
+			expected: `{"file": {"filename": "********"}, "destination": {"filename": "/etc/cron.d/job"}}`,
+		},
+		{
+			name:     "exec",
+			event:    `{"process": {"user": "root", "group": "wheel", "filename": "/usr/bin/curl", "args": "--password=secret", "envs": ["--password=secret"], "ancestors": [{"group": "admin", "filename": "/tmp/sh"}]}}`,
+			expected: `{"process": {"user": "4813494d137e1631", "group": "ba59926159d2aa25", "filename": "/usr/bin/curl", "args": "--password=********", "envs": ["--password=********"], "ancestors": [{"group": "8c6976e5b5410415", "filename": "********"}]}}`,
+		},
+		{
+			name:     "mount",
+			event:    `{"mount": {"mount_point": "/home/user", "root": "/etc/ssl/private", "fstype": "ext4"}}`,
+			expected: `{"mount": {"mount_point": "********", "root": "********", "fstype": "ext4"}}`,
+		},
+		{
+			name:     "cgroup_write",
+			event:    `{"cgroup_write": {"file": "/sys/fs/cgroup/rdma/release_agent", "value": "/cmd"}, "file": {"filename": "/etc/passwd"}}`,
+			expected: `{"cgroup_write": {"file": "********", "value": "/cmd"}, "file": {"filename": "/etc/passwd"}}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			redacted, err := redactor.Redact([]byte(test.event))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var event, expected interface{}
+			if err := json.Unmarshal(redacted, &event); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.expected), &expected); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(expected, event) {
+				t.Errorf("expected event to be redacted to `%v`, got `%v`", expected, event)
+			}
+		})
+	}
+
+	t.Run("anomaly", func(t *testing.T) {
+		if value := redactor.RedactField("exec.filename", "/tmp/miner"); value != redactedSecret {
+			t.Errorf("expected the anomaly value to be redacted, got `%s`", value)
+		}
+		if value := redactor.RedactField("open.filename", "/etc/passwd"); value != "/etc/passwd" {
+			t.Errorf("expected the anomaly value to be displayed, got `%s`", value)
+		}
+		if value := redactor.RedactField("", "8080"); value != "8080" {
+			t.Errorf("expected the anomaly value to be left as is, got `%s`", value)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var redactor *EventRedactor
+		if value := redactor.RedactField("exec.filename", "/tmp/miner"); value != "/tmp/miner" {
+			t.Errorf("expected the value to be left as is, got `%s`", value)
+		}
+	})
+}
+
+func TestPathMatches(t *testing.T) {
+	for _, test := range []struct {
+		patterns []string
+		path     string
+		matches  bool
+	}{
+		{[]string{"/etc"}, "/etc", true},
+		{[]string{"/etc"}, "/etc/ssh/sshd_config", true},
+		{[]string{"/etc"}, "/etcd/data", false},
+		{[]string{"/usr/bin/*"}, "/usr/bin/ls", true},
+		{[]string{"/usr/bin/*"}, "/usr/lib/libc.so", false},
+		{[]string{"/home/*/.ssh"}, "/home/user/.ssh/id_rsa", true},
+		{[]string{"/tmp", "/var/log"}, "/var/log/syslog", true},
+		{nil, "/etc", false},
+	} {
+		if matches := pathMatches(test.patterns, test.path); matches != test.matches {
+			t.Errorf("expected %v to match `%s`: %v, got %v", test.patterns, test.path, test.matches, matches)
+		}
+	}
+}
@@ -8,7 +8,10 @@
 package probe
 
 import (
+	"context"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/DataDog/gopsutil/process"
 	"github.com/avast/retry-go"
@@ -25,6 +28,9 @@ type Resolvers struct {
 	ContainerResolver *ContainerResolver
 	TimeResolver      *TimeResolver
 	ProcessResolver   *ProcessResolver
+	ExecArgsResolver  *ExecArgsResolver
+
+	snapshotLock sync.Mutex // serializes the snapshots, which start and stop the snapshot probes
 }
 
 // NewResolvers creates a new instance of Resolvers
@@ -39,12 +45,18 @@ func NewResolvers(probe *Probe) (*Resolvers, error) {
 		return nil, err
 	}
 
+	execArgsResolver, err := NewExecArgsResolver(probe.config)
+	if err != nil {
+		return nil, err
+	}
+
 	resolvers := &Resolvers{
 		probe:             probe,
 		DentryResolver:    dentryResolver,
 		MountResolver:     NewMountResolver(probe),
 		TimeResolver:      timeResolver,
-		ContainerResolver: &ContainerResolver{},
+		ContainerResolver: NewContainerResolver(),
+		ExecArgsResolver:  execArgsResolver,
 	}
 
 	processResolver, err := NewProcessResolver(probe, resolvers)
@@ -72,6 +84,9 @@ func (r *Resolvers) Start() error {
 
 // Snapshot collects data on the current state of the system to populate user space and kernel space caches.
 func (r *Resolvers) Snapshot() error {
+	r.snapshotLock.Lock()
+	defer r.snapshotLock.Unlock()
+
 	// start the snapshot probes
 	err := r.startSnapshotProbes()
 	if err != nil {
@@ -156,3 +171,51 @@ func (r *Resolvers) snapshot() error {
 
 	return nil
 }
+
+// Reconcile snapshots /proc to repair the process cache, which misses processes when events are lost
+func (r *Resolvers) Reconcile() error {
+	r.snapshotLock.Lock()
+	defer r.snapshotLock.Unlock()
+
+	if err := r.startSnapshotProbes(); err != nil {
+		return err
+	}
+	defer r.stopSnapshotProbes()
+
+	processes, err := process.AllProcesses()
+	if err != nil {
+		return err
+	}
+
+	// the process resolver might need the mount points of the missing processes to resolve their paths
+	for _, proc := range processes {
+		if len(proc.Exe) == 0 || r.ProcessResolver.isSynced(uint32(proc.Pid)) {
+			continue
+		}
+		if err := r.MountResolver.SyncCache(uint32(proc.Pid)); err != nil {
+			if !os.IsNotExist(err) {
+				log.Debug(errors.Wrapf(err, "reconciliation failed for %d: couldn't sync mount points", proc.Pid))
+			}
+		}
+	}
+
+	r.ProcessResolver.Reconcile(processes)
+	return nil
+}
+
+// StartReconciliation reconciles the process cache with /proc at the given period until ctx is done
+func (r *Resolvers) StartReconciliation(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reconcile(); err != nil {
+				log.Warnf("failed to reconcile the process cache: %s", err)
+			}
+		}
+	}
+}
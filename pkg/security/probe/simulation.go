@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probe
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/DataDog/datadog-agent/pkg/security/rules"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// maxSimulationSamples is the maximum number of matching events reported per rule by a simulation
+const maxSimulationSamples = 5
+
+// RuleSimulation reports how a rule evaluated the events replayed by a simulation
+type RuleSimulation struct {
+	ID         rules.RuleID   `json:"id"`
+	Matches    int            `json:"matches"`
+	Failures   int            `json:"failures,omitempty"`
+	FirstMatch *time.Time     `json:"first_match,omitempty"`
+	LastMatch  *time.Time     `json:"last_match,omitempty"`
+	Samples    []*EventRecord `json:"samples,omitempty"`
+}
+
+// SimulationReport reports which rules of a ruleset would have matched the events of an archive
+type SimulationReport struct {
+	Events        int               `json:"events"`
+	InvalidEvents int               `json:"invalid_events"`
+	Rules         []*RuleSimulation `json:"rules"`
+}
+
+// SimulateRuleSet replays the event records of an archive, such as an activity dump, through a
+// ruleset and reports the rules which would have matched them. The rule actions aren't executed.
+func SimulateRuleSet(rs *rules.RuleSet, archive io.Reader) (*SimulationReport, error) {
+	report := &SimulationReport{}
+	results := make(map[rules.RuleID]*RuleSimulation)
+	for _, id := range rs.ListRuleIDs() {
+		result := &RuleSimulation{ID: id}
+		results[id] = result
+		report.Rules = append(report.Rules, result)
+	}
+	sort.Slice(report.Rules, func(i, j int) bool { return report.Rules[i].ID < report.Rules[j].ID })
+
+	decoder := json.NewDecoder(archive)
+	decoder.UseNumber()
+	for {
+		var record EventRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "invalid archive after %d events", report.Events)
+		}
+		report.Events++
+
+		event, err := record.ReplayEvent()
+		if err != nil {
+			log.Debugf("failed to replay event %d: %s", report.Events, err)
+			report.InvalidEvents++
+			continue
+		}
+
+		matched, failed := rs.Match(event, record.IsRecorded)
+		for _, id := range failed {
+			results[id].Failures++
+		}
+		for _, id := range matched {
+			result := results[id]
+			result.Matches++
+			timestamp := record.Timestamp
+			if result.FirstMatch == nil {
+				result.FirstMatch = &timestamp
+			}
+			result.LastMatch = &timestamp
+			if len(result.Samples) < maxSimulationSamples {
+				sample := record
+				result.Samples = append(result.Samples, &sample)
+			}
+		}
+	}
+
+	return report, nil
+}
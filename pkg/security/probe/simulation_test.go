@@ -0,0 +1,104 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probe
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/security/rules"
+	"github.com/DataDog/datadog-agent/pkg/security/secl/eval"
+)
+
+func TestEventRecordReplay(t *testing.T) {
+	record := &EventRecord{
+		Type:      "open",
+		Timestamp: time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC),
+		Fields: map[string]interface{}{
+			"open.filename": "/etc/shadow",
+			"open.flags":    float64(syscall.O_RDWR),
+			"process.name":  "cat",
+			"removed.field": "value",
+		},
+	}
+
+	event, err := record.ReplayEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "open", event.GetType())
+
+	value, err := event.GetFieldValue("open.filename")
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/shadow", value)
+
+	value, err = event.GetFieldValue("open.flags")
+	assert.NoError(t, err)
+	assert.Equal(t, syscall.O_RDWR, value)
+
+	value, err = event.GetFieldValue("process.name")
+	assert.NoError(t, err)
+	assert.Equal(t, "cat", value)
+
+	_, err = (&EventRecord{Type: "unknown"}).ReplayEvent()
+	assert.Error(t, err)
+}
+
+func TestSimulateRuleSet(t *testing.T) {
+	rs := rules.NewRuleSet(&Model{}, func() eval.Event { return NewEvent(nil) }, rules.NewOptsWithParams(SECLConstants, SupportedDiscarders))
+	ruleDefs := []*rules.RuleDefinition{
+		{ID: "shadow", Expression: `open.filename == "/etc/shadow"`},
+		{ID: "cat", Expression: `open.filename =~ "/etc/*" && process.name == "cat"`},
+		{ID: "exec", Expression: `exec.filename == "/usr/bin/nc"`},
+		{ID: "container", Expression: `open.filename == "/etc/shadow" && container.image.name == "nginx"`},
+	}
+	if err := rs.AddRules(ruleDefs); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := strings.Join([]string{
+		`{"type":"open","timestamp":"2020-11-01T00:00:00Z","fields":{"open.filename":"/etc/shadow","process.name":"cat"}}`,
+		`{"type":"open","timestamp":"2020-11-01T00:00:01Z","fields":{"open.filename":"/etc/passwd","process.name":"cat"}}`,
+		`{"type":"open","timestamp":"2020-11-01T00:00:02Z","fields":{"open.filename":"/etc/shadow","process.name":"vim"}}`,
+		`{"evt":{"name":"open"},"file":{"path":"/etc/shadow"}}`,
+	}, "\n")
+
+	report, err := SimulateRuleSet(rs, strings.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 4, report.Events)
+	assert.Equal(t, 1, report.InvalidEvents)
+	if !assert.Len(t, report.Rules, 4) {
+		return
+	}
+
+	cat, container, exec, shadow := report.Rules[0], report.Rules[1], report.Rules[2], report.Rules[3]
+	assert.Equal(t, rules.RuleID("cat"), cat.ID)
+	assert.Equal(t, 2, cat.Matches)
+	assert.Equal(t, 0, cat.Failures)
+	// the image of the container isn't recorded
+	assert.Equal(t, 0, container.Matches)
+	assert.Equal(t, 3, container.Failures)
+	assert.Equal(t, 0, exec.Matches)
+	assert.Nil(t, exec.FirstMatch)
+	assert.Equal(t, 2, shadow.Matches)
+	assert.Equal(t, time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC), shadow.FirstMatch.UTC())
+	assert.Equal(t, time.Date(2020, 11, 1, 0, 0, 2, 0, time.UTC), shadow.LastMatch.UTC())
+	if assert.Len(t, shadow.Samples, 2) {
+		assert.Equal(t, "vim", shadow.Samples[1].Fields["process.name"])
+	}
+
+	_, err = SimulateRuleSet(rs, strings.NewReader(`{"type":`))
+	assert.Error(t, err)
+}
@@ -8,31 +8,157 @@
 package probe
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/DataDog/gopsutil/host"
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/pkg/errors"
+
+	"github.com/DataDog/datadog-agent/pkg/ebpf"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// Period between two re-syncs of the monotonic to wall clock mapping
+	defaultTimeSyncPeriod = time.Minute
+	// Number of clock readings done on each sync, the tightest one is kept
+	timeSyncSamples = 5
+	// Maximum number of CPUs tracked for drift correction
+	maxTrackedCPUs = 1024
 )
 
-// TimeResolver converts kernel monotonic timestamps to absolute times
+// TimeResolver converts kernel monotonic timestamps to absolute times. The monotonic clock stops
+// while the host is suspended and is not stepped by wall clock adjustments, so the mapping between
+// both clocks is periodically re-synced. The timestamps of the CPUs whose clock is ahead of the
+// clock read from user space are also corrected.
 type TimeResolver struct {
-	bootTime time.Time
+	sync.RWMutex
+	bootTime   time.Time
+	errorBound time.Duration
+	lastDrift  time.Duration
+
+	// per-CPU skews, in nanoseconds, accessed atomically
+	cpuSkews []int64
+
+	monotonicNow func() (int64, error)
+	wallNow      func() time.Time
 }
 
 // NewTimeResolver returns a new time resolver
 func NewTimeResolver() (*TimeResolver, error) {
-	bt, err := host.BootTime()
-	if err != nil {
+	tr := &TimeResolver{
+		cpuSkews:     make([]int64, maxTrackedCPUs),
+		monotonicNow: ebpf.NowNanoseconds,
+		wallNow:      time.Now,
+	}
+	if err := tr.Sync(); err != nil {
 		return nil, err
 	}
-	tr := TimeResolver{
-		bootTime: time.Unix(int64(bt), 0),
+	return tr, nil
+}
+
+// sample reads the monotonic clock between two readings of the wall clock. It returns the boot
+// time estimated from the readings and the uncertainty of this estimation. The monotonic readings
+// of the wall clock are stripped, otherwise the boot times would be compared with them and the
+// time spent while the host was suspended would be ignored.
+func (tr *TimeResolver) sample() (time.Time, time.Duration, error) {
+	before := tr.wallNow().Round(0)
+	monotonic, err := tr.monotonicNow()
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	after := tr.wallNow().Round(0)
+
+	window := after.Sub(before)
+	bootTime := before.Add(window / 2).Add(-time.Duration(monotonic))
+	return bootTime, window / 2, nil
+}
+
+// Sync re-computes the mapping between the monotonic and the wall clocks
+func (tr *TimeResolver) Sync() error {
+	var (
+		bootTime   time.Time
+		errorBound time.Duration = -1
+	)
+	for i := 0; i < timeSyncSamples; i++ {
+		bt, eb, err := tr.sample()
+		if err != nil {
+			return errors.Wrap(err, "failed to read monotonic clock")
+		}
+		if errorBound < 0 || eb < errorBound {
+			bootTime, errorBound = bt, eb
+		}
+	}
+
+	tr.Lock()
+	if !tr.bootTime.IsZero() {
+		tr.lastDrift = bootTime.Sub(tr.bootTime)
+	}
+	tr.bootTime = bootTime
+	tr.errorBound = errorBound
+	tr.Unlock()
+
+	// skews are measured against the previous mapping, halve them so that transient
+	// measurement errors fade away
+	for i := range tr.cpuSkews {
+		if skew := atomic.LoadInt64(&tr.cpuSkews[i]); skew != 0 {
+			atomic.StoreInt64(&tr.cpuSkews[i], skew/2)
+		}
 	}
-	return &tr, nil
+
+	return nil
+}
+
+// Start periodically re-syncs the mapping between the monotonic and the wall clocks, until ctx is done
+func (tr *TimeResolver) Start(ctx context.Context) {
+	ticker := time.NewTicker(defaultTimeSyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := tr.Sync(); err != nil {
+				log.Warnf("failed to sync time resolver: %s", err)
+				continue
+			}
+			if drift := tr.GetLastDrift(); drift > time.Millisecond || drift < -time.Millisecond {
+				log.Debugf("monotonic clock drifted by %s since last sync", drift)
+			}
+		}
+	}
+}
+
+// CorrectCPUTimestamp corrects the drift of the clock of a CPU. An event can not have been
+// generated after it was received, so a CPU whose timestamps are ahead of the monotonic clock
+// read from user space has its subsequent timestamps shifted back.
+func (tr *TimeResolver) CorrectCPUTimestamp(cpu int, timestamp uint64) uint64 {
+	if timestamp == 0 || cpu < 0 || cpu >= len(tr.cpuSkews) {
+		return timestamp
+	}
+
+	if now, err := tr.monotonicNow(); err == nil && int64(timestamp) > now {
+		skew := int64(timestamp) - now
+		if skew > atomic.LoadInt64(&tr.cpuSkews[cpu]) {
+			atomic.StoreInt64(&tr.cpuSkews[cpu], skew)
+		}
+	}
+
+	skew := uint64(atomic.LoadInt64(&tr.cpuSkews[cpu]))
+	if skew >= timestamp {
+		return timestamp
+	}
+	return timestamp - skew
 }
 
 // ResolveMonotonicTimestamp converts a kernel monotonic timestamp to an absolute time
 func (tr *TimeResolver) ResolveMonotonicTimestamp(timestamp uint64) time.Time {
 	if timestamp > 0 {
+		tr.RLock()
+		defer tr.RUnlock()
 		return tr.bootTime.Add(time.Duration(timestamp) * time.Nanosecond)
 	}
 	return time.Time{}
@@ -41,7 +167,39 @@ func (tr *TimeResolver) ResolveMonotonicTimestamp(timestamp uint64) time.Time {
 // ComputeMonotonicTimestamp converts an absolute time to a kernel monotonic timestamp
 func (tr *TimeResolver) ComputeMonotonicTimestamp(timestamp time.Time) int64 {
 	if !timestamp.IsZero() {
+		tr.RLock()
+		defer tr.RUnlock()
 		return timestamp.Sub(tr.bootTime).Nanoseconds()
 	}
 	return 0
 }
+
+// GetErrorBound returns the estimated maximum error of the resolved times, which accounts for
+// the uncertainty of the last sync and for the largest CPU skew
+func (tr *TimeResolver) GetErrorBound() time.Duration {
+	var maxSkew int64
+	for i := range tr.cpuSkews {
+		if skew := atomic.LoadInt64(&tr.cpuSkews[i]); skew > maxSkew {
+			maxSkew = skew
+		}
+	}
+
+	tr.RLock()
+	defer tr.RUnlock()
+	return tr.errorBound + time.Duration(maxSkew)
+}
+
+// GetLastDrift returns how much the boot time moved during the last sync
+func (tr *TimeResolver) GetLastDrift() time.Duration {
+	tr.RLock()
+	defer tr.RUnlock()
+	return tr.lastDrift
+}
+
+// SendStats sends the time resolver statistics to statsd
+func (tr *TimeResolver) SendStats(statsdClient *statsd.Client) error {
+	if err := statsdClient.Gauge(MetricPrefix+".time_resolver.error_bound", float64(tr.GetErrorBound().Nanoseconds()), nil, 1.0); err != nil {
+		return err
+	}
+	return statsdClient.Gauge(MetricPrefix+".time_resolver.drift", float64(tr.GetLastDrift().Nanoseconds()), nil, 1.0)
+}
@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package probe
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClocks returns a monotonic and a wall clock advancing by step on each reading
+func fakeClocks(bootTime time.Time, step time.Duration) (func() (int64, error), func() time.Time) {
+	var elapsed time.Duration
+	monotonic := func() (int64, error) {
+		elapsed += step
+		return int64(elapsed), nil
+	}
+	wall := func() time.Time {
+		elapsed += step
+		return bootTime.Add(elapsed)
+	}
+	return monotonic, wall
+}
+
+func newTestTimeResolver(t *testing.T, bootTime time.Time) *TimeResolver {
+	monotonic, wall := fakeClocks(bootTime, time.Microsecond)
+	tr := &TimeResolver{
+		cpuSkews:     make([]int64, 4),
+		monotonicNow: monotonic,
+		wallNow:      wall,
+	}
+	if err := tr.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestTimeResolverSync(t *testing.T) {
+	bootTime := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	tr := newTestTimeResolver(t, bootTime)
+
+	if resolved := tr.ResolveMonotonicTimestamp(uint64(time.Hour)); !resolved.Equal(bootTime.Add(time.Hour)) {
+		t.Errorf("expected %s, got %s", bootTime.Add(time.Hour), resolved)
+	}
+	if ts := tr.ComputeMonotonicTimestamp(bootTime.Add(time.Hour)); ts != int64(time.Hour) {
+		t.Errorf("expected %d, got %d", int64(time.Hour), ts)
+	}
+	if bound := tr.GetErrorBound(); bound != time.Microsecond {
+		t.Errorf("expected an error bound of 1µs, got %s", bound)
+	}
+
+	// the host was suspended for a minute, the monotonic clock did not count it
+	tr.monotonicNow, tr.wallNow = fakeClocks(bootTime.Add(time.Minute), time.Microsecond)
+	if err := tr.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if drift := tr.GetLastDrift(); drift != time.Minute {
+		t.Errorf("expected a drift of 1m, got %s", drift)
+	}
+	if resolved := tr.ResolveMonotonicTimestamp(uint64(time.Hour)); !resolved.Equal(bootTime.Add(time.Hour + time.Minute)) {
+		t.Errorf("expected %s, got %s", bootTime.Add(time.Hour+time.Minute), resolved)
+	}
+}
+
+func TestTimeResolverWallClock(t *testing.T) {
+	tr := newTestTimeResolver(t, time.Now())
+	tr.wallNow = time.Now
+	if err := tr.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the boot time must be compared with the wall clock only
+	if bootTime := tr.ResolveMonotonicTimestamp(1); strings.Contains(bootTime.String(), "m=") {
+		t.Errorf("expected the boot time to have no monotonic clock reading, got %s", bootTime)
+	}
+}
+
+func TestTimeResolverCPUDrift(t *testing.T) {
+	tr := newTestTimeResolver(t, time.Now())
+	tr.monotonicNow = func() (int64, error) {
+		return int64(time.Second), nil
+	}
+
+	// CPU 1 is 10ms ahead
+	ahead := uint64(time.Second + 10*time.Millisecond)
+	if ts := tr.CorrectCPUTimestamp(1, ahead); ts != uint64(time.Second) {
+		t.Errorf("expected timestamp to be corrected to %d, got %d", uint64(time.Second), ts)
+	}
+	if ts := tr.CorrectCPUTimestamp(1, uint64(500*time.Millisecond)); ts != uint64(490*time.Millisecond) {
+		t.Errorf("expected skew to be applied to subsequent timestamps, got %d", ts)
+	}
+	if ts := tr.CorrectCPUTimestamp(0, uint64(500*time.Millisecond)); ts != uint64(500*time.Millisecond) {
+		t.Errorf("expected timestamps of other CPUs to be left untouched, got %d", ts)
+	}
+	if ts := tr.CorrectCPUTimestamp(len(tr.cpuSkews), ahead); ts != ahead {
+		t.Errorf("expected timestamps of untracked CPUs to be left untouched, got %d", ts)
+	}
+	if bound := tr.GetErrorBound(); bound < 10*time.Millisecond {
+		t.Errorf("expected the error bound to include the CPU skew, got %s", bound)
+	}
+}
@@ -0,0 +1,112 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Action names
+const (
+	KillAction         = "kill"
+	SetTagsAction      = "set_tags"
+	ActivityDumpAction = "activity_dump"
+)
+
+// KillSignals holds the signals that can be sent by a kill action
+var KillSignals = map[string]bool{
+	"SIGKILL": true,
+	"SIGTERM": true,
+	"SIGINT":  true,
+	"SIGQUIT": true,
+	"SIGSTOP": true,
+	"SIGHUP":  true,
+	"SIGUSR1": true,
+	"SIGUSR2": true,
+}
+
+const (
+	// DefaultActivityDumpTimeout is the duration of an activity dump when not specified
+	DefaultActivityDumpTimeout = time.Minute
+	// MaxActivityDumpTimeout is the maximum duration of an activity dump
+	MaxActivityDumpTimeout = 10 * time.Minute
+)
+
+// KillDefinition describes a kill action, sending a signal to the process which triggered the rule
+type KillDefinition struct {
+	Signal string `yaml:"signal"`
+}
+
+// ActivityDumpDefinition describes an activity dump action, recording the subsequent events of the
+// process which triggered the rule
+type ActivityDumpDefinition struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// ActionDefinition describes an action executed when a rule matches. Exactly one kind of action
+// should be set.
+type ActionDefinition struct {
+	Kill         *KillDefinition         `yaml:"kill"`
+	SetTags      map[string]string       `yaml:"set_tags"`
+	ActivityDump *ActivityDumpDefinition `yaml:"activity_dump"`
+}
+
+// Name returns the name of the action
+func (a *ActionDefinition) Name() string {
+	switch {
+	case a.Kill != nil:
+		return KillAction
+	case len(a.SetTags) != 0:
+		return SetTagsAction
+	case a.ActivityDump != nil:
+		return ActivityDumpAction
+	default:
+		return ""
+	}
+}
+
+// Check validates the action, filling default values
+func (a *ActionDefinition) Check() error {
+	var count int
+	if a.Kill != nil {
+		count++
+	}
+	if len(a.SetTags) != 0 {
+		count++
+	}
+	if a.ActivityDump != nil {
+		count++
+	}
+	switch count {
+	case 0:
+		return errors.New("action has no kind")
+	case 1:
+	default:
+		return errors.New("action has more than one kind")
+	}
+
+	if a.Kill != nil {
+		if a.Kill.Signal == "" {
+			a.Kill.Signal = "SIGKILL"
+		}
+		if !KillSignals[a.Kill.Signal] {
+			return fmt.Errorf("unsupported signal `%s`", a.Kill.Signal)
+		}
+	}
+
+	if a.ActivityDump != nil {
+		if a.ActivityDump.Timeout == 0 {
+			a.ActivityDump.Timeout = DefaultActivityDumpTimeout
+		}
+		if a.ActivityDump.Timeout < 0 || a.ActivityDump.Timeout > MaxActivityDumpTimeout {
+			return fmt.Errorf("activity dump timeout should be positive and at most %s", MaxActivityDumpTimeout)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package rules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActionCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		action ActionDefinition
+		valid  bool
+	}{
+		{name: "empty", action: ActionDefinition{}},
+		{name: "kill", action: ActionDefinition{Kill: &KillDefinition{Signal: "SIGTERM"}}, valid: true},
+		{name: "kill default signal", action: ActionDefinition{Kill: &KillDefinition{}}, valid: true},
+		{name: "kill unknown signal", action: ActionDefinition{Kill: &KillDefinition{Signal: "SIGSEGV"}}},
+		{name: "set tags", action: ActionDefinition{SetTags: map[string]string{"suspicious": "true"}}, valid: true},
+		{name: "activity dump", action: ActionDefinition{ActivityDump: &ActivityDumpDefinition{Timeout: time.Minute}}, valid: true},
+		{name: "activity dump default timeout", action: ActionDefinition{ActivityDump: &ActivityDumpDefinition{}}, valid: true},
+		{name: "activity dump timeout too long", action: ActionDefinition{ActivityDump: &ActivityDumpDefinition{Timeout: time.Hour}}},
+		{
+			name: "multiple kinds",
+			action: ActionDefinition{
+				Kill:    &KillDefinition{},
+				SetTags: map[string]string{"suspicious": "true"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.action.Check()
+			if test.valid && err != nil {
+				t.Fatalf("expected a valid action, got: %s", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("expected an invalid action")
+			}
+		})
+	}
+
+	action := ActionDefinition{Kill: &KillDefinition{}}
+	if err := action.Check(); err != nil || action.Kill.Signal != "SIGKILL" {
+		t.Errorf("expected SIGKILL to be the default signal, got `%s`", action.Kill.Signal)
+	}
+
+	action = ActionDefinition{ActivityDump: &ActivityDumpDefinition{}}
+	if err := action.Check(); err != nil || action.ActivityDump.Timeout != DefaultActivityDumpTimeout {
+		t.Errorf("expected %s to be the default timeout, got %s", DefaultActivityDumpTimeout, action.ActivityDump.Timeout)
+	}
+}
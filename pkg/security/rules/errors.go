@@ -62,3 +62,13 @@ type ErrNoEventTypeBucket struct {
 func (e ErrNoEventTypeBucket) Error() string {
 	return fmt.Sprintf("no bucket for event type `%s`", e.EventType)
 }
+
+// ErrFieldNotResolved is returned when a rule uses a field whose value can't be resolved for an event
+type ErrFieldNotResolved struct {
+	RuleID RuleID
+	Field  string
+}
+
+func (e ErrFieldNotResolved) Error() string {
+	return fmt.Sprintf("rule `%s` uses field `%s` which can't be resolved for the event", e.RuleID, e.Field)
+}
@@ -6,6 +6,7 @@
 package rules
 
 import (
+	"net"
 	"reflect"
 	"syscall"
 	"unsafe"
@@ -19,6 +20,7 @@ type testProcess struct {
 	uid    int
 	gid    int
 	isRoot bool
+	ip     net.IP
 }
 
 type testOpen struct {
@@ -106,6 +108,13 @@ func (m *testModel) GetEvaluator(key string) (eval.Evaluator, error) {
 			Field:   key,
 		}, nil
 
+	case "process.ip":
+
+		return &eval.IPEvaluator{
+			EvalFnc: func(ctx *eval.Context) net.IP { return (*testEvent)(ctx.Object).process.ip },
+			Field:   key,
+		}, nil
+
 	case "open.filename":
 
 		return &eval.StringEvaluator{
@@ -165,6 +174,10 @@ func (e *testEvent) GetFieldValue(key string) (interface{}, error) {
 
 		return e.process.isRoot, nil
 
+	case "process.ip":
+
+		return e.process.ip, nil
+
 	case "open.filename":
 
 		return e.open.filename, nil
@@ -209,6 +222,10 @@ func (e *testEvent) GetFieldEventType(key string) (string, error) {
 
 		return "*", nil
 
+	case "process.ip":
+
+		return "*", nil
+
 	case "open.filename":
 
 		return "open", nil
@@ -257,6 +274,11 @@ func (e *testEvent) SetFieldValue(key string, value interface{}) error {
 		e.process.isRoot = value.(bool)
 		return nil
 
+	case "process.ip":
+
+		e.process.ip = value.(net.IP)
+		return nil
+
 	case "open.filename":
 
 		e.open.filename = value.(string)
@@ -306,6 +328,10 @@ func (e *testEvent) GetFieldType(key string) (reflect.Kind, error) {
 
 		return reflect.Bool, nil
 
+	case "process.ip":
+
+		return reflect.Slice, nil
+
 	case "open.filename":
 
 		return reflect.String, nil
@@ -29,9 +29,10 @@ type RuleID = string
 
 // RuleDefinition holds the definition of a rule
 type RuleDefinition struct {
-	ID         RuleID            `yaml:"id"`
-	Expression string            `yaml:"expression"`
-	Tags       map[string]string `yaml:"tags"`
+	ID         RuleID             `yaml:"id"`
+	Expression string             `yaml:"expression"`
+	Tags       map[string]string  `yaml:"tags"`
+	Actions    []ActionDefinition `yaml:"actions"`
 }
 
 // GetTags returns the tags associated to a rule
@@ -75,6 +76,7 @@ type RuleSet struct {
 	opts             *Opts
 	eventRuleBuckets map[eval.EventType]*RuleBucket
 	rules            map[eval.RuleID]*eval.Rule
+	actions          map[eval.RuleID][]ActionDefinition
 	model            eval.Model
 	eventCtor        func() eval.Event
 	listeners        []RuleSetListener
@@ -91,6 +93,11 @@ func (rs *RuleSet) ListRuleIDs() []RuleID {
 	return ids
 }
 
+// GetRuleActions returns the actions to execute when the given rule matches
+func (rs *RuleSet) GetRuleActions(id RuleID) []ActionDefinition {
+	return rs.actions[id]
+}
+
 // AddMacros parses the macros AST and adds them to the list of macros of the ruleset
 func (rs *RuleSet) AddMacros(macros []*MacroDefinition) error {
 	var result *multierror.Error
@@ -198,6 +205,9 @@ func (rs *RuleSet) AddRule(ruleDef *RuleDefinition) (*eval.Rule, error) {
 	rs.AddFields(rule.GetEvaluator().GetFields())
 
 	rs.rules[ruleDef.ID] = rule
+	if len(ruleDef.Actions) != 0 {
+		rs.actions[ruleDef.ID] = ruleDef.Actions
+	}
 
 	return rule, nil
 }
@@ -336,6 +346,71 @@ func (rs *RuleSet) Evaluate(event eval.Event) bool {
 	return result
 }
 
+// Explain evaluates the rule with the given ID against an event, and returns the result of each of
+// the predicates of its expression, so that one can understand why the rule matched or not. The
+// isResolved function reports which fields can be resolved for the event, nil meaning all of them.
+func (rs *RuleSet) Explain(id RuleID, event eval.Event, isResolved func(field eval.Field) bool) (*eval.Explanation, error) {
+	rule, exists := rs.rules[id]
+	if !exists {
+		return nil, fmt.Errorf("rule `%s` not found", id)
+	}
+
+	if err := checkRuleFields(rule, isResolved); err != nil {
+		return nil, err
+	}
+
+	ctx := &eval.Context{}
+	ctx.SetObject(event.GetPointer())
+
+	return rule.Explain(ctx)
+}
+
+// Match returns the IDs of the rules matching an event, without notifying the listeners of the
+// ruleset. The isResolved function reports which fields can be resolved for the event, nil meaning
+// all of them. The rules using fields which can't be resolved, for instance because they require a
+// running probe, aren't evaluated and are returned apart.
+func (rs *RuleSet) Match(event eval.Event, isResolved func(field eval.Field) bool) (matched []RuleID, failed []RuleID) {
+	bucket, exists := rs.eventRuleBuckets[event.GetType()]
+	if !exists {
+		return nil, nil
+	}
+
+	ctx := &eval.Context{}
+	ctx.SetObject(event.GetPointer())
+
+	for _, rule := range bucket.rules {
+		if err := checkRuleFields(rule, isResolved); err != nil {
+			log.Debugf("failed to evaluate rule: %s", err)
+			failed = append(failed, rule.ID)
+			continue
+		}
+
+		if rule.GetEvaluator().Eval(ctx) {
+			matched = append(matched, rule.ID)
+		}
+	}
+	return matched, failed
+}
+
+// checkRuleFields returns an error if a rule uses a field which can't be resolved
+func checkRuleFields(rule *eval.Rule, isResolved func(field eval.Field) bool) error {
+	if isResolved == nil {
+		return nil
+	}
+
+	for _, field := range rule.GetFields() {
+		if !isResolved(field) {
+			return &ErrFieldNotResolved{RuleID: rule.ID, Field: field}
+		}
+	}
+	return nil
+}
+
+// NewEvent returns a new event instance of the model of the ruleset
+func (rs *RuleSet) NewEvent() eval.Event {
+	return rs.eventCtor()
+}
+
 // GetEventTypes returns all the event types handled by the ruleset
 func (rs *RuleSet) GetEventTypes() []eval.EventType {
 	eventTypes := make([]string, 0, len(rs.eventRuleBuckets))
@@ -381,5 +456,6 @@ func NewRuleSet(model eval.Model, eventCtor func() eval.Event, opts *Opts) *Rule
 		opts:             opts,
 		eventRuleBuckets: make(map[eval.EventType]*RuleBucket),
 		rules:            make(map[eval.RuleID]*eval.Rule),
+		actions:          make(map[eval.RuleID][]ActionDefinition),
 	}
 }
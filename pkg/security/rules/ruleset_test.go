@@ -7,7 +7,9 @@ package rules
 
 import (
 	"fmt"
+	"net"
 	"reflect"
+	"sort"
 	"syscall"
 	"testing"
 
@@ -352,6 +354,96 @@ func TestRuleSetFilters5(t *testing.T) {
 	}
 }
 
+func TestRuleSetFiltersCIDR(t *testing.T) {
+	rs := NewRuleSet(&testModel{}, func() eval.Event { return &testEvent{} }, NewOptsWithParams(testConstants, testSupportedDiscarders))
+
+	addRuleExpr(t, rs, `open.filename == "/etc/passwd" && process.ip in ["10.0.0.0/8", "192.168.1.0/24"]`)
+
+	caps := FieldCapabilities{
+		{
+			Field: "process.ip",
+			Types: eval.ScalarValueType | eval.CIDRValueType,
+		},
+	}
+
+	if _, err := rs.GetApprovers("open", caps); err == nil {
+		t.Fatal("shouldn't get any approver")
+	}
+
+	caps = FieldCapabilities{
+		{
+			Field: "open.filename",
+			Types: eval.ScalarValueType,
+		},
+		{
+			Field: "process.ip",
+			Types: eval.ScalarValueType | eval.CIDRValueType,
+		},
+	}
+
+	approvers, err := rs.GetApprovers("open", caps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values, exists := approvers["open.filename"]; !exists || len(values) != 1 {
+		t.Fatalf("expected approver not found: %v", values)
+	}
+
+	if _, exists := approvers["process.ip"]; exists {
+		t.Fatal("a CIDR range shouldn't be an approver")
+	}
+
+	event := &testEvent{
+		kind: "open",
+		process: testProcess{
+			ip: net.ParseIP("172.16.0.1"),
+		},
+		open: testOpen{
+			filename: "/etc/passwd",
+		},
+	}
+
+	// process.ip isn't bound to the open event type, evaluate the rule partially instead of using IsDiscarder
+	rule := rs.eventRuleBuckets["open"].rules[0]
+	ctx := &eval.Context{}
+	ctx.SetObject(event.GetPointer())
+
+	if isTrue, err := rule.PartialEval(ctx, "process.ip"); err != nil || isTrue {
+		t.Fatalf("expected the address to be a discarder: %v", err)
+	}
+
+	if isTrue, err := rule.PartialEval(ctx, "open.filename"); err != nil || !isTrue {
+		t.Fatalf("didn't expect the filename to be a discarder: %v", err)
+	}
+
+	event.process.ip = net.ParseIP("192.168.1.12")
+	if isTrue, err := rule.PartialEval(ctx, "process.ip"); err != nil || !isTrue {
+		t.Fatalf("didn't expect the address to be a discarder: %v", err)
+	}
+}
+
+func TestRuleSetFiltersCIDROr(t *testing.T) {
+	rs := NewRuleSet(&testModel{}, func() eval.Event { return &testEvent{} }, NewOptsWithParams(testConstants, testSupportedDiscarders))
+
+	addRuleExpr(t, rs, `open.filename == "/etc/passwd" || process.ip in ["10.0.0.0/8"]`)
+
+	caps := FieldCapabilities{
+		{
+			Field: "open.filename",
+			Types: eval.ScalarValueType,
+		},
+		{
+			Field: "process.ip",
+			Types: eval.ScalarValueType | eval.CIDRValueType,
+		},
+	}
+
+	if _, err := rs.GetApprovers("open", caps); err == nil {
+		t.Fatal("shouldn't get any approver")
+	}
+}
+
 // TODO: re-add this test once approver on multiple event type rules will be fixed
 func TestRuleSetFilters6(t *testing.T) {
 	t.Skip()
@@ -371,3 +463,88 @@ func TestRuleSetFilters6(t *testing.T) {
 		t.Fatal("shouldn't get any approver")
 	}
 }
+
+func TestRuleSetExplain(t *testing.T) {
+	rs := NewRuleSet(&testModel{}, func() eval.Event { return &testEvent{} }, NewOptsWithParams(testConstants, testSupportedDiscarders))
+	addRuleExpr(t, rs, `open.filename == "/etc/shadow" && open.flags & O_CREAT > 0`)
+
+	event := rs.NewEvent().(*testEvent)
+	event.kind = "open"
+	event.open = testOpen{
+		filename: "/etc/shadow",
+		flags:    syscall.O_RDONLY,
+	}
+
+	explanation, err := rs.Explain("ID0", event, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if explanation.Result || explanation.Operator != "&&" || len(explanation.Children) != 2 {
+		t.Fatalf("unexpected explanation: %+v", explanation)
+	}
+
+	if child := explanation.Children[0]; !child.Result || child.Values["open.filename"] != "/etc/shadow" {
+		t.Errorf("unexpected explanation of `%s`: %+v", child.Expression, child)
+	}
+
+	if child := explanation.Children[1]; child.Result || child.Expression != "open.flags & O_CREAT > 0" || child.Values["open.flags"] != syscall.O_RDONLY {
+		t.Errorf("unexpected explanation of `%s`: %+v", child.Expression, child)
+	}
+
+	if _, err := rs.Explain("ID1", event, nil); err == nil {
+		t.Error("expected an error for an unknown rule")
+	}
+
+	isResolved := func(field eval.Field) bool { return field == "open.filename" }
+	if _, err := rs.Explain("ID0", event, isResolved); err == nil {
+		t.Error("expected an error for a rule using a field which can't be resolved")
+	}
+}
+
+type matchListener struct {
+	matches int
+}
+
+func (l *matchListener) RuleMatch(rule *eval.Rule, event eval.Event) {
+	l.matches++
+}
+
+func (l *matchListener) EventDiscarderFound(rs *RuleSet, event eval.Event, field eval.Field, eventType eval.EventType) {
+}
+
+func TestRuleSetMatch(t *testing.T) {
+	rs := NewRuleSet(&testModel{}, func() eval.Event { return &testEvent{} }, NewOptsWithParams(testConstants, testSupportedDiscarders))
+	addRuleExpr(t, rs, `open.filename == "/etc/shadow"`, `open.filename =~ "/etc/*"`, `open.filename == "/tmp/test"`, `open.filename == "/etc/shadow" && process.name == "cat"`)
+
+	listener := &matchListener{}
+	rs.AddListener(listener)
+
+	event := rs.NewEvent().(*testEvent)
+	event.kind = "open"
+	event.open = testOpen{
+		filename: "/etc/shadow",
+	}
+
+	matched, failed := rs.Match(event, nil)
+	sort.Strings(matched)
+	if !reflect.DeepEqual(matched, []RuleID{"ID0", "ID1"}) || len(failed) != 0 {
+		t.Errorf("unexpected matching rules: %v (failed: %v)", matched, failed)
+	}
+	if listener.matches != 0 {
+		t.Error("the listeners shouldn't be notified")
+	}
+
+	// the rules using fields which can't be resolved aren't evaluated
+	isResolved := func(field eval.Field) bool { return field == "open.filename" }
+	matched, failed = rs.Match(event, isResolved)
+	sort.Strings(matched)
+	if !reflect.DeepEqual(matched, []RuleID{"ID0", "ID1"}) || !reflect.DeepEqual(failed, []RuleID{"ID3"}) {
+		t.Errorf("unexpected matching rules: %v (failed: %v)", matched, failed)
+	}
+
+	event.kind = "mkdir"
+	if matched, _ := rs.Match(event, nil); len(matched) != 0 {
+		t.Errorf("no rule should match: %v", matched)
+	}
+}
@@ -6,6 +6,7 @@
 package rules
 
 import (
+	"net"
 	"reflect"
 
 	"github.com/DataDog/datadog-agent/pkg/security/secl/eval"
@@ -29,11 +30,11 @@ func (tt *truthTable) getApprovers(fields ...string) map[eval.Field]FilterValues
 		}
 
 		// a field value can't be an approver if we can find a entry that is true
-		// when all the fields are set to false.
+		// when all the fields are set to false. Ignored values can't be approvers either.
 		allFalse := true
 		for _, field := range fields {
 			for _, value := range entry.Values {
-				if value.Field == field && !value.Not {
+				if value.Field == field && !value.Not && !value.ignore {
 					allFalse = false
 					break
 				}
@@ -142,6 +143,28 @@ func genFilterValues(rule *eval.Rule, event eval.Event) ([]FilterValues, error)
 				})
 			case eval.BitmaskValueType:
 				bitmasks = append(bitmasks, fValue.Value.(int))
+			case eval.CIDRValueType:
+				// a CIDR range can't be used as an approver, only add an address within and
+				// outside of the range so that the other fields of the rule can still be approvers
+				cidr, ok := fValue.Value.(*net.IPNet)
+				if !ok {
+					return nil, &ErrValueTypeUnknown{Field: field}
+				}
+
+				values = append(values, FilterValue{
+					Field:  field,
+					Value:  cidr.IP,
+					Type:   fValue.Type,
+					ignore: true,
+				}, FilterValue{
+					Field:  field,
+					Value:  net.IP(nil),
+					Type:   fValue.Type,
+					Not:    true,
+					ignore: true,
+				})
+			default:
+				return nil, &ErrValueTypeUnknown{Field: field}
 			}
 		}
 
@@ -22,6 +22,15 @@ func (e ErrInvalidPattern) Error() string {
 	return fmt.Sprintf("invalid pattern `%s`", e.Pattern)
 }
 
+// ErrInvalidCIDR is returned for an invalid IP address or CIDR range
+type ErrInvalidCIDR struct {
+	Value string
+}
+
+func (e ErrInvalidCIDR) Error() string {
+	return fmt.Sprintf("invalid IP address or CIDR range `%s`", e.Value)
+}
+
 // ErrAstToEval describes an error that occurred during the conversion from the AST to an evaluator
 type ErrAstToEval struct {
 	Pos  lexer.Position
@@ -9,6 +9,7 @@ package eval
 
 import (
 	"fmt"
+	"net"
 	"reflect"
 	"regexp"
 	"sort"
@@ -34,6 +35,7 @@ const (
 	ScalarValueType  FieldValueType = 1
 	PatternValueType FieldValueType = 2
 	BitmaskValueType FieldValueType = 4
+	CIDRValueType    FieldValueType = 8
 )
 
 // FieldValue describes a field value with its type
@@ -120,6 +122,21 @@ func (s *StringEvaluator) Eval(ctx *Context) interface{} {
 	return s.EvalFnc(ctx)
 }
 
+// IPEvaluator returns an IP address as result of the evaluation. The accessors of the net.IP fields of a
+// model return such evaluators. CIDR ranges are matched by the rules but are never used as approvers.
+type IPEvaluator struct {
+	EvalFnc func(ctx *Context) net.IP
+	Field   Field
+	Value   net.IP
+
+	isPartial bool
+}
+
+// Eval returns the result of the evaluation
+func (i *IPEvaluator) Eval(ctx *Context) interface{} {
+	return i.EvalFnc(ctx)
+}
+
 // StringArray represents an array of string values
 type StringArray struct {
 	Values []string
@@ -254,6 +271,17 @@ func nodeToEvaluator(obj interface{}, opts *Opts, state *state) (interface{}, in
 					return nil, nil, pos, err
 				}
 				return intEvaluator, nil, obj.Pos, nil
+			case *IPEvaluator:
+				nextStringArray, ok := next.(*StringArray)
+				if !ok {
+					return nil, nil, pos, NewTypeError(pos, reflect.Array)
+				}
+
+				boolEvaluator, err := IPArrayContains(unary, nextStringArray, *obj.ArrayComparison.Op == "notin", opts, state)
+				if err != nil {
+					return nil, nil, pos, NewOpError(obj.Pos, *obj.ArrayComparison.Op, err)
+				}
+				return boolEvaluator, nil, obj.Pos, nil
 			default:
 				return nil, nil, pos, NewTypeError(pos, reflect.Array)
 			}
@@ -357,6 +385,21 @@ func nodeToEvaluator(obj interface{}, opts *Opts, state *state) (interface{}, in
 					return boolEvaluator, nil, obj.Pos, nil
 				}
 				return nil, nil, pos, NewOpUnknownError(obj.Pos, *obj.ScalarComparison.Op)
+			case *IPEvaluator:
+				nextString, ok := next.(*StringEvaluator)
+				if !ok {
+					return nil, nil, pos, NewTypeError(pos, reflect.String)
+				}
+
+				switch *obj.ScalarComparison.Op {
+				case "==", "!=":
+					boolEvaluator, err := IPEquals(unary, nextString, *obj.ScalarComparison.Op == "!=", opts, state)
+					if err != nil {
+						return nil, nil, pos, NewOpError(obj.Pos, *obj.ScalarComparison.Op, err)
+					}
+					return boolEvaluator, nil, obj.Pos, nil
+				}
+				return nil, nil, pos, NewOpUnknownError(obj.Pos, *obj.ScalarComparison.Op)
 			}
 		} else {
 			return unary, nil, pos, nil
@@ -7,6 +7,7 @@ package eval
 
 import (
 	"fmt"
+	"net"
 	"strings"
 	"syscall"
 	"testing"
@@ -434,6 +435,54 @@ func TestPartial(t *testing.T) {
 	}
 }
 
+func TestIPCIDR(t *testing.T) {
+	event := &testEvent{
+		connect: testConnect{
+			addr: net.ParseIP("10.1.2.3"),
+		},
+	}
+
+	tests := []struct {
+		Expr     string
+		Expected bool
+	}{
+		{Expr: `connect.addr in ["10.0.0.0/8", "192.168.0.0/16"]`, Expected: true},
+		{Expr: `connect.addr not in ["10.0.0.0/8", "192.168.0.0/16"]`, Expected: false},
+		{Expr: `connect.addr in ["172.16.0.0/12", "fd00::/8"]`, Expected: false},
+		{Expr: `connect.addr in ["10.1.2.3"]`, Expected: true},
+		{Expr: `connect.addr == "10.1.2.3"`, Expected: true},
+		{Expr: `connect.addr == "10.1.2.0/24"`, Expected: true},
+		{Expr: `connect.addr != "10.1.2.0/24"`, Expected: false},
+	}
+
+	for _, test := range tests {
+		result, _, err := eval(t, event, test.Expr)
+		if err != nil {
+			t.Fatalf("error while evaluating `%s`: %s", test.Expr, err)
+		}
+
+		if result != test.Expected {
+			t.Errorf("expected result `%t` not found, got `%t`\n%s", test.Expected, result, test.Expr)
+		}
+	}
+
+	event.connect.addr = net.ParseIP("2001:db8::1")
+	result, _, err := eval(t, event, `connect.addr in ["2001:db8::/32"]`)
+	if err != nil || !result {
+		t.Errorf("should match an IPv6 range: %v", err)
+	}
+
+	for _, expr := range []string{
+		`connect.addr in ["10.0.0.0/33"]`,
+		`connect.addr == "localhost"`,
+		`connect.addr == 3`,
+	} {
+		if _, _, err := eval(t, event, expr); err == nil {
+			t.Errorf("should report an error for `%s`", expr)
+		}
+	}
+}
+
 func TestMacroList(t *testing.T) {
 	macro := &Macro{
 		ID:         "list",
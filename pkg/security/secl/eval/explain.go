@@ -0,0 +1,302 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package eval
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/DataDog/datadog-agent/pkg/security/secl/ast"
+)
+
+// Explanation is the breakdown of the evaluation of a rule against an event. Each node
+// corresponds to a predicate of the rule expression: leaves are comparisons or boolean
+// fields and macros, inner nodes combine their children with a logical operator.
+type Explanation struct {
+	Expression string                `json:"expression"`
+	Result     bool                  `json:"result"`
+	Operator   string                `json:"operator,omitempty"`
+	Values     map[Field]interface{} `json:"values,omitempty"`
+	Children   []*Explanation        `json:"children,omitempty"`
+}
+
+// Explain evaluates the rule against the event set in the given context, and returns the
+// result of each of its predicates along with the values of the event fields they use
+func (r *Rule) Explain(ctx *Context) (*Explanation, error) {
+	if r.ast == nil || r.evaluator == nil {
+		return nil, errors.Errorf("rule %s is not compiled", r.ID)
+	}
+
+	macros := make(map[MacroID]*MacroEvaluator)
+	for id, macro := range r.Opts.Macros {
+		macros[id] = macro.evaluator
+	}
+
+	e := &explainer{rule: r, ctx: ctx, macros: macros}
+	return e.explainExpression(r.ast.BooleanExpression.Expression)
+}
+
+type explainer struct {
+	rule   *Rule
+	ctx    *Context
+	macros map[MacroID]*MacroEvaluator
+}
+
+// evaluate compiles and evaluates a boolean node of the rule AST on its own
+func (e *explainer) evaluate(node interface{}) (bool, error) {
+	state := newState(e.rule.Model, "", e.macros)
+	evaluator, _, pos, err := nodeToEvaluator(node, e.rule.Opts, state)
+	if err != nil {
+		return false, err
+	}
+
+	evalBool, ok := evaluator.(*BoolEvaluator)
+	if !ok {
+		return false, NewTypeError(pos, reflect.Bool)
+	}
+
+	if evalBool.EvalFnc == nil {
+		return evalBool.Value, nil
+	}
+	return evalBool.EvalFnc(e.ctx), nil
+}
+
+func (e *explainer) explainExpression(expr *ast.Expression) (*Explanation, error) {
+	if expr.Op == nil {
+		return e.explainComparison(expr.Comparison)
+	}
+
+	// chains of the same operator, such as "a && b && c", are grouped in a single node
+	explanation := &Explanation{Expression: exprString(expr), Operator: *expr.Op}
+	for cur := expr; ; {
+		child, err := e.explainComparison(cur.Comparison)
+		if err != nil {
+			return nil, err
+		}
+		explanation.Children = append(explanation.Children, child)
+
+		next := cur.Next.Expression
+		if next.Op == nil || *next.Op != explanation.Operator {
+			child, err := e.explainExpression(next)
+			if err != nil {
+				return nil, err
+			}
+			explanation.Children = append(explanation.Children, child)
+			break
+		}
+		cur = next
+	}
+
+	result, err := e.evaluate(expr)
+	if err != nil {
+		return nil, err
+	}
+	explanation.Result = result
+
+	return explanation, nil
+}
+
+func (e *explainer) explainComparison(cmp *ast.Comparison) (*Explanation, error) {
+	if cmp.ScalarComparison == nil && cmp.ArrayComparison == nil && cmp.BitOperation.Op == nil {
+		return e.explainUnary(cmp.BitOperation.Unary)
+	}
+	return e.explainLeaf(cmp, comparisonString(cmp))
+}
+
+func (e *explainer) explainUnary(unary *ast.Unary) (*Explanation, error) {
+	switch {
+	case unary.Op != nil && *unary.Op == "!":
+		child, err := e.explainUnary(unary.Unary)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := e.evaluate(unary)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Explanation{
+			Expression: unaryString(unary),
+			Result:     result,
+			Operator:   "!",
+			Children:   []*Explanation{child},
+		}, nil
+	case unary.Op == nil && unary.Primary.SubExpression != nil:
+		return e.explainExpression(unary.Primary.SubExpression)
+	default:
+		return e.explainLeaf(unary, unaryString(unary))
+	}
+}
+
+func (e *explainer) explainLeaf(node interface{}, expression string) (*Explanation, error) {
+	result, err := e.evaluate(node)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &Explanation{Expression: expression, Result: result}
+	for _, field := range e.fields(node) {
+		evaluator, err := e.rule.Model.GetEvaluator(field)
+		if err != nil {
+			return nil, err
+		}
+
+		if explanation.Values == nil {
+			explanation.Values = make(map[Field]interface{})
+		}
+		explanation.Values[field] = evaluatorValue(evaluator, e.ctx)
+	}
+
+	return explanation, nil
+}
+
+// fields returns the event fields used by a node of the rule AST, excluding constants and macros
+func (e *explainer) fields(node interface{}) []Field {
+	var fields []Field
+
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch node := node.(type) {
+		case *ast.Expression:
+			walk(node.Comparison)
+			if node.Next != nil {
+				walk(node.Next.Expression)
+			}
+		case *ast.Comparison:
+			walk(node.BitOperation)
+			if node.ScalarComparison != nil {
+				walk(node.ScalarComparison.Next)
+			}
+		case *ast.BitOperation:
+			walk(node.Unary)
+			if node.Next != nil {
+				walk(node.Next)
+			}
+		case *ast.Unary:
+			if node.Unary != nil {
+				walk(node.Unary)
+			} else {
+				walk(node.Primary)
+			}
+		case *ast.Primary:
+			switch {
+			case node.SubExpression != nil:
+				walk(node.SubExpression)
+			case node.Ident != nil:
+				if _, isConstant := e.rule.Opts.Constants[*node.Ident]; isConstant {
+					return
+				}
+				if _, isMacro := e.macros[*node.Ident]; isMacro {
+					return
+				}
+				for _, field := range fields {
+					if field == *node.Ident {
+						return
+					}
+				}
+				fields = append(fields, *node.Ident)
+			}
+		}
+	}
+	walk(node)
+
+	return fields
+}
+
+// evaluatorValue returns the value of a field evaluator for the event set in the given context
+func evaluatorValue(evaluator Evaluator, ctx *Context) interface{} {
+	switch evaluator := evaluator.(type) {
+	case *BoolEvaluator:
+		if evaluator.EvalFnc == nil {
+			return evaluator.Value
+		}
+		return evaluator.EvalFnc(ctx)
+	case *IntEvaluator:
+		if evaluator.EvalFnc == nil {
+			return evaluator.Value
+		}
+		return evaluator.EvalFnc(ctx)
+	case *StringEvaluator:
+		if evaluator.EvalFnc == nil {
+			return evaluator.Value
+		}
+		return evaluator.EvalFnc(ctx)
+	default:
+		return evaluator.Eval(ctx)
+	}
+}
+
+// exprString and the following functions format the nodes of the rule AST back to SECL
+func exprString(expr *ast.Expression) string {
+	s := comparisonString(expr.Comparison)
+	if expr.Op != nil {
+		s += " " + *expr.Op + " " + exprString(expr.Next.Expression)
+	}
+	return s
+}
+
+func comparisonString(cmp *ast.Comparison) string {
+	s := bitOperationString(cmp.BitOperation)
+	switch {
+	case cmp.ScalarComparison != nil:
+		s += " " + *cmp.ScalarComparison.Op + " " + comparisonString(cmp.ScalarComparison.Next)
+	case cmp.ArrayComparison != nil:
+		op := *cmp.ArrayComparison.Op
+		if op == "notin" {
+			op = "not in"
+		}
+		s += " " + op + " " + arrayString(cmp.ArrayComparison.Array)
+	}
+	return s
+}
+
+func bitOperationString(op *ast.BitOperation) string {
+	s := unaryString(op.Unary)
+	if op.Op != nil {
+		s += " " + *op.Op + " " + bitOperationString(op.Next)
+	}
+	return s
+}
+
+func unaryString(unary *ast.Unary) string {
+	if unary.Op != nil {
+		return *unary.Op + unaryString(unary.Unary)
+	}
+
+	primary := unary.Primary
+	switch {
+	case primary.Ident != nil:
+		return *primary.Ident
+	case primary.Number != nil:
+		return strconv.Itoa(*primary.Number)
+	case primary.String != nil:
+		return strconv.Quote(*primary.String)
+	case primary.SubExpression != nil:
+		return "(" + exprString(primary.SubExpression) + ")"
+	}
+	return ""
+}
+
+func arrayString(array *ast.Array) string {
+	var values []string
+	switch {
+	case len(array.Strings) != 0:
+		for _, s := range array.Strings {
+			values = append(values, strconv.Quote(s))
+		}
+	case len(array.Numbers) != 0:
+		for _, n := range array.Numbers {
+			values = append(values, strconv.Itoa(n))
+		}
+	case array.Ident != nil:
+		return *array.Ident
+	}
+	return "[ " + strings.Join(values, ", ") + " ]"
+}
@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package eval
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestExplain(t *testing.T) {
+	event := &testEvent{
+		process: testProcess{
+			name: "/usr/bin/cat",
+			uid:  1,
+		},
+		open: testOpen{
+			filename: "/etc/shadow",
+		},
+	}
+
+	ctx := &Context{}
+	ctx.SetObject(unsafe.Pointer(event))
+
+	expr := `process.name == "/usr/bin/cat" && process.uid != 0 && !(open.filename in [ "/etc/shadow", "/etc/passwd" ] || process.is_root)`
+
+	rule, err := parseRule(expr, &testModel{}, NewOptsWithParams(testConstants))
+	if err != nil {
+		t.Fatalf("error while evaluating `%s`: %s", expr, err)
+	}
+
+	explanation, err := rule.Explain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Explanation{
+		Expression: `process.name == "/usr/bin/cat" && process.uid != 0 && !(open.filename in [ "/etc/passwd", "/etc/shadow" ] || process.is_root)`,
+		Result:     false,
+		Operator:   "&&",
+		Children: []*Explanation{
+			{
+				Expression: `process.name == "/usr/bin/cat"`,
+				Result:     true,
+				Values:     map[Field]interface{}{"process.name": "/usr/bin/cat"},
+			},
+			{
+				Expression: `process.uid != 0`,
+				Result:     true,
+				Values:     map[Field]interface{}{"process.uid": 1},
+			},
+			{
+				Expression: `!(open.filename in [ "/etc/passwd", "/etc/shadow" ] || process.is_root)`,
+				Result:     false,
+				Operator:   "!",
+				Children: []*Explanation{
+					{
+						Expression: `open.filename in [ "/etc/passwd", "/etc/shadow" ] || process.is_root`,
+						Result:     true,
+						Operator:   "||",
+						Children: []*Explanation{
+							{
+								Expression: `open.filename in [ "/etc/passwd", "/etc/shadow" ]`,
+								Result:     true,
+								Values:     map[Field]interface{}{"open.filename": "/etc/shadow"},
+							},
+							{
+								Expression: `process.is_root`,
+								Result:     false,
+								Values:     map[Field]interface{}{"process.is_root": false},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(expected, explanation) {
+		got, _ := json.MarshalIndent(explanation, "", "  ")
+		t.Errorf("unexpected explanation:\n%s", got)
+	}
+
+	if explanation.Result != rule.Eval(ctx) {
+		t.Error("the explanation result should match the rule evaluation")
+	}
+}
+
+func TestExplainMacro(t *testing.T) {
+	macro := &Macro{
+		ID:         "is_passwd",
+		Expression: `open.filename in [ "/etc/shadow", "/etc/passwd" ]`,
+	}
+
+	if err := macro.Parse(); err != nil {
+		t.Fatalf("%s\n%s", err, macro.Expression)
+	}
+
+	model := &testModel{}
+
+	if err := macro.GenEvaluator(model, &Opts{}); err != nil {
+		t.Fatalf("%s\n%s", err, macro.Expression)
+	}
+
+	opts := NewOptsWithParams(make(map[string]interface{}))
+	opts.Macros = map[string]*Macro{
+		"is_passwd": macro,
+	}
+
+	expr := `is_passwd && process.name == "/usr/bin/vipw"`
+
+	rule, err := parseRule(expr, model, opts)
+	if err != nil {
+		t.Fatalf("error while evaluating `%s`: %s", expr, err)
+	}
+
+	ctx := &Context{}
+	ctx.SetObject(unsafe.Pointer(&testEvent{
+		process: testProcess{name: "/usr/bin/cat"},
+		open:    testOpen{filename: "/etc/passwd"},
+	}))
+
+	explanation, err := rule.Explain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if explanation.Result || len(explanation.Children) != 2 {
+		t.Fatalf("unexpected explanation: %+v", explanation)
+	}
+
+	if child := explanation.Children[0]; child.Expression != "is_passwd" || !child.Result || child.Values != nil {
+		t.Errorf("unexpected macro explanation: %+v", child)
+	}
+
+	if child := explanation.Children[1]; child.Result || child.Values["process.name"] != "/usr/bin/cat" {
+		t.Errorf("unexpected comparison explanation: %+v", child)
+	}
+}
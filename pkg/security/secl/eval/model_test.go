@@ -6,6 +6,7 @@
 package eval
 
 import (
+	"net"
 	"reflect"
 	"syscall"
 	"unsafe"
@@ -31,6 +32,10 @@ type testMkdir struct {
 	mode     int
 }
 
+type testConnect struct {
+	addr net.IP
+}
+
 type testEvent struct {
 	id   string
 	kind string
@@ -38,6 +43,7 @@ type testEvent struct {
 	process testProcess
 	open    testOpen
 	mkdir   testMkdir
+	connect testConnect
 }
 
 type testModel struct {
@@ -126,6 +132,13 @@ func (m *testModel) GetEvaluator(key string) (Evaluator, error) {
 			Field:   key,
 		}, nil
 
+	case "connect.addr":
+
+		return &IPEvaluator{
+			EvalFnc: func(ctx *Context) net.IP { return (*testEvent)(ctx.Object).connect.addr },
+			Field:   key,
+		}, nil
+
 	case "mkdir.filename":
 
 		return &StringEvaluator{
@@ -164,6 +177,10 @@ func (e *testEvent) GetFieldValue(key string) (interface{}, error) {
 
 		return e.process.isRoot, nil
 
+	case "connect.addr":
+
+		return e.connect.addr, nil
+
 	case "open.filename":
 
 		return e.open.filename, nil
@@ -208,6 +225,10 @@ func (e *testEvent) GetFieldEventType(key string) (string, error) {
 
 		return "*", nil
 
+	case "connect.addr":
+
+		return "connect", nil
+
 	case "open.filename":
 
 		return "open", nil
@@ -256,6 +277,11 @@ func (e *testEvent) SetFieldValue(key string, value interface{}) error {
 		e.process.isRoot = value.(bool)
 		return nil
 
+	case "connect.addr":
+
+		e.connect.addr = value.(net.IP)
+		return nil
+
 	case "open.filename":
 
 		e.open.filename = value.(string)
@@ -305,6 +331,10 @@ func (e *testEvent) GetFieldType(key string) (reflect.Kind, error) {
 
 		return reflect.Bool, nil
 
+	case "connect.addr":
+
+		return reflect.Slice, nil
+
 	case "open.filename":
 
 		return reflect.String, nil
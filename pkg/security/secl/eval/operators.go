@@ -6,6 +6,7 @@
 package eval
 
 import (
+	"net"
 	"regexp"
 	"sort"
 
@@ -272,3 +273,97 @@ func IntArrayContains(a *IntEvaluator, b *IntArray, not bool, opts *Opts, state
 		isPartial: isPartialLeaf,
 	}, nil
 }
+
+// parseCIDR parses an IP address or a CIDR range. An address is parsed as the range holding
+// only this address.
+func parseCIDR(value string) (*net.IPNet, error) {
+	if ip := net.ParseIP(value); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		bits := 8 * len(ip)
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipnet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, &ErrInvalidCIDR{Value: value}
+	}
+	return ipnet, nil
+}
+
+// cidrsContain returns whether one of the ranges contains ip
+func cidrsContain(cidrs []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPArrayContains - 10.0.0.1 in ["10.0.0.0/8", "192.168.0.0/16"] operator
+func IPArrayContains(a *IPEvaluator, b *StringArray, not bool, opts *Opts, state *state) (*BoolEvaluator, error) {
+	cidrs := make([]*net.IPNet, 0, len(b.Values))
+	for _, value := range b.Values {
+		cidr, err := parseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+
+	isPartialLeaf := a.isPartial
+	if a.Field != "" && state.field != "" && a.Field != state.field {
+		isPartialLeaf = true
+	}
+
+	if a.Field != "" {
+		for _, cidr := range cidrs {
+			if err := state.UpdateFieldValues(a.Field, FieldValue{Value: cidr, Type: CIDRValueType}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if a.EvalFnc != nil {
+		ea := a.EvalFnc
+
+		evalFnc := func(ctx *Context) bool {
+			result := cidrsContain(cidrs, ea(ctx))
+			if not {
+				result = !result
+			}
+			return result
+		}
+
+		return &BoolEvaluator{
+			EvalFnc:   evalFnc,
+			isPartial: isPartialLeaf,
+		}, nil
+	}
+
+	ea := true
+	if !isPartialLeaf {
+		ea = cidrsContain(cidrs, a.Value)
+		if not {
+			ea = !ea
+		}
+	}
+
+	return &BoolEvaluator{
+		Value:     ea,
+		isPartial: isPartialLeaf,
+	}, nil
+}
+
+// IPEquals - 10.0.0.1 == "10.0.0.0/8" operator
+func IPEquals(a *IPEvaluator, b *StringEvaluator, not bool, opts *Opts, state *state) (*BoolEvaluator, error) {
+	if b.EvalFnc != nil {
+		return nil, errors.New("IP address or CIDR range has to be a scalar string")
+	}
+
+	return IPArrayContains(a, &StringArray{Values: []string{b.Value}}, not, opts, state)
+}
@@ -46,6 +46,7 @@ type Module struct {
 	Name      string
 	PkgPrefix string
 	BuildTags []string
+	Imports   []string
 	Fields    map[string]*structField
 }
 
@@ -78,12 +79,24 @@ func origTypeToBasicType(kind string) string {
 	return kind
 }
 
+func addImport(pkg string) {
+	for _, imp := range module.Imports {
+		if imp == pkg {
+			return
+		}
+	}
+	module.Imports = append(module.Imports, pkg)
+}
+
 func handleBasic(name, alias, kind, event string) {
 	fmt.Printf("handleBasic %s %s\n", name, kind)
 
 	switch kind {
 	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
 		module.Fields[alias] = &structField{Name: name, ReturnType: "int", Public: true, Event: event, OrigType: kind, BasicType: origTypeToBasicType(kind)}
+	case "net.IP":
+		addImport("net")
+		module.Fields[alias] = &structField{Name: name, ReturnType: kind, Public: true, Event: event, OrigType: kind, BasicType: kind}
 	default:
 		public := false
 		firstChar := strings.TrimPrefix(kind, "[]")
@@ -206,6 +219,17 @@ func handleSpec(astFile *ast.File, spec interface{}, prefix, aliasPrefix, event
 							}
 							continue
 						}
+					} else if fieldType, ok := field.Type.(*ast.SelectorExpr); ok {
+						// IP addresses are the only types of another package that can be used as fields
+						if pkgIdent, ok := fieldType.X.(*ast.Ident); ok && pkgIdent.Name == "net" && fieldType.Sel.Name == "IP" {
+							name, alias := fieldName, fieldAlias
+							if prefix != "" {
+								name = prefix + "." + name
+								alias = aliasPrefix + "." + alias
+							}
+							handleBasic(name, alias, "net.IP", event)
+							continue
+						}
 					}
 
 					if strict {
@@ -317,7 +341,8 @@ func main() {
 package {{.Name}}
 
 import (
-	"reflect"
+	"reflect"{{range .Imports}}
+	"{{.}}"{{end}}
 
 	"github.com/DataDog/datadog-agent/pkg/security/secl/eval"
 )
@@ -340,6 +365,9 @@ func (m *Model) GetEvaluator(field eval.Field) (eval.Evaluator, error) {
 	{{else if eq $Field.ReturnType "bool"}}
 		return &eval.BoolEvaluator{
 			EvalFnc: func(ctx *eval.Context) bool { return {{$Return}} },
+	{{else if eq $Field.ReturnType "net.IP"}}
+		return &eval.IPEvaluator{
+			EvalFnc: func(ctx *eval.Context) net.IP { return {{$Return}} },
 	{{end}}
 			Field: field,
 		}, nil
@@ -364,6 +392,8 @@ func (e *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 			return int({{$Return}}), nil
 		{{else if eq $Field.ReturnType "bool"}}
 			return {{$Return}}, nil
+		{{else if eq $Field.ReturnType "net.IP"}}
+			return {{$Return}}, nil
 		{{end}}
 		{{end}}
 		}
@@ -382,6 +412,12 @@ func (e *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 	return "", &eval.ErrFieldNotFound{Field: field}
 }
 
+func (e *Event) GetFields() []eval.Field {
+	return []eval.Field{ {{range $Name, $Field := .Fields}}
+		"{{$Name}}",{{end}}
+	}
+}
+
 func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 	switch field {
 		{{range $Name, $Field := .Fields}}
@@ -393,6 +429,8 @@ func (e *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 			return reflect.Int, nil
 		{{else if eq $Field.ReturnType "bool"}}
 			return reflect.Bool, nil
+		{{else if eq $Field.ReturnType "net.IP"}}
+			return reflect.Slice, nil
 		{{end}}
 		{{end}}
 		}
@@ -411,6 +449,11 @@ func (e *Event) SetFieldValue(field eval.Field, value interface{}) error {
 				return &eval.ErrValueTypeMismatch{Field: "{{$Field.Name}}"}
 			}
 			return nil
+		{{else if eq $Field.OrigType "net.IP"}}
+			if {{$FieldName}}, ok = value.(net.IP); !ok {
+				return &eval.ErrValueTypeMismatch{Field: "{{$Field.Name}}"}
+			}
+			return nil
 		{{else if eq $Field.BasicType "int"}}
 			v, ok := value.(int)
 			if !ok {
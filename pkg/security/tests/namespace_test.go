@@ -0,0 +1,109 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build functionaltests
+
+package tests
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/security/rules"
+)
+
+func TestNamespace(t *testing.T) {
+	executable, err := exec.LookPath("unshare")
+	if err != nil {
+		t.Skip("unshare not found")
+	}
+
+	rule := &rules.RuleDefinition{
+		ID:         "test_rule",
+		Expression: `namespace.syscall == "unshare" && namespace.types & CLONE_NEWUTS > 0 && process.filename == "` + executable + `"`,
+	}
+
+	test, err := newTestModule(nil, []*rules.RuleDefinition{rule}, testOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer test.Close()
+
+	if out, err := exec.Command(executable, "--uts", "true").CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", err, out)
+	}
+
+	event, _, err := test.GetEvent()
+	if err != nil {
+		t.Error(err)
+	} else {
+		if event.GetType() != "namespace" {
+			t.Errorf("expected namespace event, got %s", event.GetType())
+		}
+
+		if event.Namespace.Types&syscall.CLONE_NEWUTS == 0 {
+			t.Errorf("expected CLONE_NEWUTS in namespace types, got %d", event.Namespace.Types)
+		}
+
+		if event.Namespace.Retval != 0 {
+			t.Errorf("expected a successful unshare, got %d", event.Namespace.Retval)
+		}
+	}
+}
+
+func TestCgroupWrite(t *testing.T) {
+	// notify_on_release is only available on cgroup v1 hierarchies
+	files, err := filepath.Glob("/sys/fs/cgroup/*/notify_on_release")
+	if err != nil || len(files) == 0 {
+		t.Skip("no cgroup v1 hierarchy found")
+	}
+	file := files[0]
+
+	original, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := "1"
+	if strings.TrimSpace(string(original)) == "1" {
+		value = "0"
+	}
+
+	rule := &rules.RuleDefinition{
+		ID:         "test_rule",
+		Expression: `cgroup_write.file == "notify_on_release" && cgroup_write.value == "` + value + `"`,
+	}
+
+	test, err := newTestModule(nil, []*rules.RuleDefinition{rule}, testOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer test.Close()
+
+	if err := ioutil.WriteFile(file, []byte(value), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer ioutil.WriteFile(file, original, 0644)
+
+	event, _, err := test.GetEvent()
+	if err != nil {
+		t.Error(err)
+	} else {
+		if event.GetType() != "cgroup_write" {
+			t.Errorf("expected cgroup_write event, got %s", event.GetType())
+		}
+
+		if event.CgroupWrite.File != "notify_on_release" {
+			t.Errorf("expected notify_on_release file, got %s", event.CgroupWrite.File)
+		}
+
+		if event.CgroupWrite.Value != value {
+			t.Errorf("expected value %s, got %s", value, event.CgroupWrite.Value)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"os/exec"
 	"os/user"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,6 +21,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/security/probe"
 	"github.com/DataDog/datadog-agent/pkg/security/rules"
+	"github.com/DataDog/datadog-agent/pkg/security/utils"
 )
 
 func TestProcess(t *testing.T) {
@@ -125,6 +127,34 @@ func TestProcessContext(t *testing.T) {
 		}
 	})
 
+	t.Run("session", func(t *testing.T) {
+		expectedAUID, expectedSessionID := -1, -1
+		if auid, err := utils.PidLoginUID(uint32(os.Getpid())); err == nil && auid != ^uint32(0) {
+			expectedAUID = int(auid)
+		}
+		if sessionID, err := utils.PidSessionID(uint32(os.Getpid())); err == nil && sessionID != ^uint32(0) {
+			expectedSessionID = int(sessionID)
+		}
+
+		f, err := os.Open(testFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		event, _, err := test.GetEvent()
+		if err != nil {
+			t.Error(err)
+		} else {
+			if auid, _ := event.GetFieldValue("process.auid"); auid.(int) != expectedAUID {
+				t.Errorf("expected process auid %d, got %v", expectedAUID, auid)
+			}
+			if sessionID, _ := event.GetFieldValue("process.session_id"); sessionID.(int) != expectedSessionID {
+				t.Errorf("expected process session ID %d, got %v", expectedSessionID, sessionID)
+			}
+		}
+	})
+
 	t.Run("tty", func(t *testing.T) {
 		// not working on centos8
 		t.Skip()
@@ -202,6 +232,97 @@ func TestProcessExec(t *testing.T) {
 	}
 }
 
+func TestProcessExecArgs(t *testing.T) {
+	executable := "/usr/bin/touch"
+	if resolved, err := os.Readlink(executable); err == nil {
+		executable = resolved
+	} else {
+		if os.IsNotExist(err) {
+			executable = "/bin/touch"
+		}
+	}
+
+	ruleDef := &rules.RuleDefinition{
+		ID:         "test_rule",
+		Expression: fmt.Sprintf(`exec.filename == "%s"`, executable),
+	}
+
+	test, err := newTestModule(nil, []*rules.RuleDefinition{ruleDef}, testOpts{enableExecArgs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer test.Close()
+
+	cmd := exec.Command(executable, "-c", "/dev/null")
+	if _, err := cmd.CombinedOutput(); err != nil {
+		t.Error(err)
+	}
+
+	event, _, err := test.GetEvent()
+	if err != nil {
+		t.Error(err)
+	} else {
+		if args, _ := event.GetFieldValue("exec.args"); args.(string) != "-c /dev/null" {
+			t.Errorf("expected exec args `-c /dev/null`, got `%v`", args)
+		}
+
+		if args, _ := event.GetFieldValue("process.args"); args.(string) != "-c /dev/null" {
+			t.Errorf("expected process args `-c /dev/null`, got `%v`", args)
+		}
+	}
+}
+
+func TestProcessExecArgsTruncated(t *testing.T) {
+	executable := "/usr/bin/touch"
+	if resolved, err := os.Readlink(executable); err == nil {
+		executable = resolved
+	} else {
+		if os.IsNotExist(err) {
+			executable = "/bin/touch"
+		}
+	}
+
+	ruleDef := &rules.RuleDefinition{
+		ID:         "test_rule",
+		Expression: fmt.Sprintf(`exec.filename == "%s"`, executable),
+	}
+
+	test, err := newTestModule(nil, []*rules.RuleDefinition{ruleDef}, testOpts{enableExecArgs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer test.Close()
+
+	// the arguments are copied to buffers of 128 bytes, including the terminating NULL byte
+	for _, tt := range []struct {
+		arg       string
+		args      string
+		truncated bool
+	}{
+		{arg: strings.Repeat("a", 127), args: "-c " + strings.Repeat("a", 127), truncated: false},
+		{arg: strings.Repeat("b", 128), args: "-c " + strings.Repeat("b", 127), truncated: true},
+	} {
+		cmd := exec.Command(executable, "-c", tt.arg)
+		if _, err := cmd.CombinedOutput(); err != nil {
+			t.Error(err)
+		}
+
+		event, _, err := test.GetEvent()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if args, _ := event.GetFieldValue("exec.args"); args.(string) != tt.args {
+			t.Errorf("expected exec args `%s`, got `%v`", tt.args, args)
+		}
+
+		if event.Exec.ArgsTruncated != tt.truncated {
+			t.Errorf("expected args truncated to be %t for an argument of %d bytes", tt.truncated, len(tt.arg))
+		}
+	}
+}
+
 func TestProcessLineage(t *testing.T) {
 	executable := "/usr/bin/touch"
 	if resolved, err := os.Readlink(executable); err == nil {
@@ -61,6 +61,10 @@ runtime_security_config:
 {{if .DisableDiscarders}}
   enable_discarders: false
 {{end}}
+{{if .EnableExecArgs}}
+  exec_args:
+    enabled: true
+{{end}}
 
   policies:
     dir: {{.TestPoliciesDir}}
@@ -101,6 +105,7 @@ type testOpts struct {
 	disableApprovers  bool
 	disableDiscarders bool
 	wantProbeEvents   bool
+	enableExecArgs    bool
 }
 
 type testModule struct {
@@ -170,6 +175,7 @@ func setTestConfig(dir string, opts testOpts) error {
 	buffer := new(bytes.Buffer)
 	if err := tmpl.Execute(buffer, map[string]interface{}{
 		"TestPoliciesDir": dir,
+		"EnableExecArgs":  opts.enableExecArgs,
 	}); err != nil {
 		return err
 	}
@@ -252,7 +258,8 @@ func newTestModule(macros []*rules.MacroDefinition, rules []*rules.RuleDefinitio
 	if useReload && testMod != nil {
 		if opts.disableApprovers == testMod.opts.disableApprovers &&
 			opts.disableDiscarders == testMod.opts.disableDiscarders &&
-			opts.disableFilters == testMod.opts.disableFilters {
+			opts.disableFilters == testMod.opts.disableFilters &&
+			opts.enableExecArgs == testMod.opts.enableExecArgs {
 			testMod.reset()
 			testMod.st = st
 			return testMod, testMod.reloadConfiguration()
@@ -9,9 +9,11 @@ package utils
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/DataDog/gopsutil/process"
@@ -35,6 +37,11 @@ func CgroupTaskPath(tgid, pid uint32) string {
 	return filepath.Join(util.HostProc(), fmt.Sprintf("%d/task/%d/cgroup", tgid, pid))
 }
 
+// ProcPidPath returns the path to the directory of a pid in /proc
+func ProcPidPath(pid uint32) string {
+	return filepath.Join(util.HostProc(), fmt.Sprintf("%d", pid))
+}
+
 // ProcExePath returns the path to the exe file of a pid in /proc
 func ProcExePath(pid uint32) string {
 	return filepath.Join(util.HostProc(), fmt.Sprintf("%d/exe", pid))
@@ -64,6 +71,28 @@ func PidTTY(pid uint32) string {
 	return ""
 }
 
+// PidLoginUID returns the audit login UID of the given pid
+func PidLoginUID(pid uint32) (uint32, error) {
+	return readProcUint32(pid, "loginuid")
+}
+
+// PidSessionID returns the audit session ID of the given pid
+func PidSessionID(pid uint32) (uint32, error) {
+	return readProcUint32(pid, "sessionid")
+}
+
+func readProcUint32(pid uint32, name string) (uint32, error) {
+	data, err := ioutil.ReadFile(filepath.Join(util.HostProc(), fmt.Sprintf("%d/%s", pid, name)))
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(v), nil
+}
+
 // ParseMountInfoFile collects the mounts for a specific process ID.
 func ParseMountInfoFile(pid uint32) ([]*mountinfo.Info, error) {
 	f, err := os.Open(MountInfoPidPath(pid))
@@ -111,12 +111,14 @@ func FormatSecurityAgentStatus(data []byte) (string, error) {
 	json.Unmarshal(data, &stats) //nolint:errcheck
 	runnerStats := stats["runnerStats"]
 	complianceChecks := stats["complianceChecks"]
+	complianceBackends := stats["complianceBackends"]
+	complianceRuleUsage := stats["complianceRuleUsage"]
 	title := fmt.Sprintf("Datadog Security Agent (v%s)", stats["version"])
 	stats["title"] = title
 	renderStatusTemplate(b, "/header.tmpl", stats)
 
 	renderRuntimeSecurityStats(b, stats["runtimeSecurityStatus"])
-	renderComplianceChecksStats(b, runnerStats, complianceChecks)
+	renderComplianceChecksStats(b, runnerStats, complianceChecks, complianceBackends, complianceRuleUsage)
 
 	return b.String(), nil
 }
@@ -162,10 +164,12 @@ func renderCheckStats(data []byte, checkName string) (string, error) {
 	return b.String(), nil
 }
 
-func renderComplianceChecksStats(w io.Writer, runnerStats interface{}, complianceChecks interface{}) {
+func renderComplianceChecksStats(w io.Writer, runnerStats interface{}, complianceChecks interface{}, complianceBackends interface{}, complianceRuleUsage interface{}) {
 	checkStats := make(map[string]interface{})
 	checkStats["RunnerStats"] = runnerStats
 	checkStats["ComplianceChecks"] = complianceChecks
+	checkStats["ComplianceBackends"] = complianceBackends
+	checkStats["ComplianceRuleUsage"] = complianceRuleUsage
 	renderStatusTemplate(w, "/compliance.tmpl", checkStats)
 }
 
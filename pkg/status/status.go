@@ -404,8 +404,12 @@ func expvarStats(stats map[string]interface{}) (map[string]interface{}, error) {
 		complianceStatus := make(map[string]interface{})
 		json.Unmarshal(complianceStatusJSON, &complianceStatus) //nolint:errcheck
 		stats["complianceChecks"] = complianceStatus["Checks"]
+		stats["complianceBackends"] = complianceStatus["Backends"]
+		stats["complianceRuleUsage"] = complianceStatus["RuleUsage"]
 	} else {
 		stats["complianceChecks"] = map[string]interface{}{}
+		stats["complianceBackends"] = []interface{}{}
+		stats["complianceRuleUsage"] = []interface{}{}
 	}
 
 	return stats, err
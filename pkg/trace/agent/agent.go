@@ -8,6 +8,7 @@ package agent
 import (
 	"context"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/trace/metrics/timing"
 	"github.com/DataDog/datadog-agent/pkg/trace/obfuscate"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb/columnar"
 	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
 	"github.com/DataDog/datadog-agent/pkg/trace/stats"
 	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
@@ -30,6 +32,13 @@ const (
 	// tagContainersTags specifies the name of the tag which holds key/value
 	// pairs representing information about the container (Docker, EC2, etc).
 	tagContainersTags = "_dd.tags.container"
+
+	// tagOrigin specifies the name of the tag which holds the origin of a trace
+	// (e.g. "synthetics").
+	tagOrigin = "_dd.origin"
+
+	// tagVersion specifies the name of the tag which holds the version of the application.
+	tagVersion = "version"
 )
 
 // Agent struct holds all the sub-routines structs and make the data flow between them
@@ -40,19 +49,45 @@ type Agent struct {
 	Replacer           *filters.Replacer
 	ScoreSampler       *Sampler
 	ErrorsScoreSampler *Sampler
-	ExceptionSampler   *sampler.ExceptionSampler
+	ExceptionSampler   *sampler.ExceptionSampler // nil if the exception sampler is disabled
 	PrioritySampler    *Sampler
+	HashSampler        *Sampler // nil unless the trace ID hash sampling mode is enabled
+	SpanSampler        *sampler.SpanSampler
 	EventProcessor     *event.Processor
 	TraceWriter        *writer.TraceWriter
+	ArchiveWriter      *writer.ArchiveWriter // nil if the archive is disabled
 	StatsWriter        *writer.StatsWriter
+	Flusher            *writer.Flusher
 
 	// obfuscator is used to obfuscate sensitive data from various span
 	// tags based on their type.
 	obfuscator *obfuscate.Obfuscator
 
+	// obfuscationBypass decides which spans should not be obfuscated based
+	// on their service.
+	obfuscationBypass *obfuscationBypass
+
+	// metaLimiter limits the number of meta entries of spans.
+	metaLimiter *metaLimiter
+
+	// reassembler merges the chunks of the traces received in several payloads. It is nil if
+	// the reassembly is disabled.
+	reassembler *reassembler
+
+	// columnar reports whether the sampled spans are passed to the writers in columnar form.
+	columnar bool
+
 	// In takes incoming payloads to be processed by the agent.
 	In chan *api.Payload
 
+	// workers waits for the workers to exit, and workersIn for the ones still receiving
+	// from In.
+	workers   sync.WaitGroup
+	workersIn sync.WaitGroup
+
+	// samplersDump caches the result of dumpSamplers.
+	samplersDump samplersDumpCache
+
 	// config
 	conf *config.AgentConfig
 
@@ -66,28 +101,73 @@ func NewAgent(ctx context.Context, conf *config.AgentConfig) *Agent {
 	dynConf := sampler.NewDynamicConfig(conf.DefaultEnv)
 	in := make(chan *api.Payload, 1000)
 	statsChan := make(chan []stats.Bucket)
+	flusher := writer.NewFlusher(conf)
 
-	return &Agent{
+	a := &Agent{
 		Receiver:           api.NewHTTPReceiver(conf, dynConf, in),
-		Concentrator:       stats.NewConcentrator(conf.ExtraAggregators, conf.BucketInterval.Nanoseconds(), statsChan),
+		Concentrator:       newConcentrator(conf, statsChan),
 		Blacklister:        filters.NewBlacklister(conf.Ignore["resource"]),
 		Replacer:           filters.NewReplacer(conf.ReplaceTags),
 		ScoreSampler:       NewScoreSampler(conf),
-		ExceptionSampler:   sampler.NewExceptionSampler(),
+		ExceptionSampler:   newExceptionSampler(conf),
 		ErrorsScoreSampler: NewErrorsSampler(conf),
 		PrioritySampler:    NewPrioritySampler(conf, dynConf),
+		SpanSampler:        newSpanSampler(conf),
 		EventProcessor:     newEventProcessor(conf),
-		TraceWriter:        writer.NewTraceWriter(conf),
-		StatsWriter:        writer.NewStatsWriter(conf, statsChan),
-		obfuscator:         obfuscate.NewObfuscator(conf.Obfuscation),
+		TraceWriter:        writer.NewTraceWriter(conf, flusher),
+		StatsWriter:        writer.NewStatsWriter(conf, statsChan, flusher),
+		Flusher:            flusher,
+		obfuscator:         newObfuscator(conf.Obfuscation),
+		obfuscationBypass:  newObfuscationBypass(conf.Obfuscation),
+		metaLimiter:        newMetaLimiter(conf.MetaLimit),
+		reassembler:        newReassembler(conf),
+		columnar:           config.HasFeature("columnar_spans"),
 		In:                 in,
 		conf:               conf,
 		ctx:                ctx,
 	}
+	enableLanguageQuirks(conf.LanguageQuirks)
+	if conf.TracerConfigFile != "" {
+		a.Receiver.SetRemoteConfigClient(api.NewFileRemoteConfigClient(conf.TracerConfigFile))
+	}
+	if conf.TargetTPS > 0 {
+		// the rates sent to the tracers are lowered with the rate limiter of the receiver
+		// when the agent uses too much CPU or memory
+		a.PrioritySampler.setTargetTPS(conf.TargetTPS, a.Receiver.RateLimiter.TargetRate)
+	}
+	if conf.SamplingMode == config.SamplingModeTraceIDHash {
+		a.HashSampler = NewHashSampler(conf)
+	}
+	if conf.Archive != nil {
+		w, err := writer.NewArchiveWriter(conf, flusher)
+		if err != nil {
+			log.Errorf("Failed to create the archive writer, sampled traces are not archived: %v", err)
+		} else {
+			a.ArchiveWriter = w
+		}
+	}
+	return a
+}
+
+// newConcentrator returns the concentrator computing the stats of the agent, aggregating the
+// services configured for it at a finer resolution.
+func newConcentrator(conf *config.AgentConfig, out chan []stats.Bucket) *stats.Concentrator {
+	c := stats.NewConcentrator(conf.ExtraAggregators, conf.BucketInterval.Nanoseconds(), out)
+	err := c.SetFineResolution(stats.FineResolution{
+		Services:   conf.FineStatsServices,
+		BucketSize: conf.FineStatsBucketInterval.Nanoseconds(),
+		MaxGrains:  int64(conf.FineStatsMaxGrains),
+	})
+	if err != nil {
+		log.Errorf("Stats of services %v are aggregated at the default resolution: %v", conf.FineStatsServices, err)
+	}
+	c.SetMaxVersions(conf.StatsMaxVersionsPerService)
+	return c
 }
 
 // Run starts routers routines and individual pieces then stop them when the exit order is received
 func (a *Agent) Run() {
+	info.SetSamplersDump(a.dumpSamplers)
 	for _, starter := range []interface{ Start() }{
 		a.Receiver,
 		a.Concentrator,
@@ -95,32 +175,67 @@ func (a *Agent) Run() {
 		a.ErrorsScoreSampler,
 		a.PrioritySampler,
 		a.EventProcessor,
+		a.obfuscationBypass,
+		a.metaLimiter,
+		a.Flusher,
 	} {
 		starter.Start()
 	}
+	if a.HashSampler != nil {
+		a.HashSampler.Start()
+	}
+	if a.reassembler != nil {
+		a.reassembler.Start()
+	}
 
 	go a.TraceWriter.Run()
+	if a.ArchiveWriter != nil {
+		go a.ArchiveWriter.Run()
+	}
 	go a.StatsWriter.Run()
 
-	for i := 0; i < runtime.NumCPU(); i++ {
+	n := runtime.NumCPU()
+	a.workers.Add(n)
+	a.workersIn.Add(n)
+	for i := 0; i < n; i++ {
 		go a.work()
 	}
 
 	a.loop()
 }
 
+// work processes the payloads received. It returns once In is closed and, when the reassembly
+// is enabled, once the reassembler flushed the traces it held and closed its output.
 func (a *Agent) work() {
+	defer a.workers.Done()
 	sublayerCalculator := stats.NewSublayerCalculator()
-	for {
+	in := a.In
+	var reassembled chan *api.Payload // nil, never ready, if the reassembly is disabled
+	if a.reassembler != nil {
+		reassembled = a.reassembler.Out
+	}
+	for in != nil || reassembled != nil {
 		select {
-		case p, ok := <-a.In:
+		case p, ok := <-in:
+			if !ok {
+				in = nil
+				a.workersIn.Done()
+				continue
+			}
+			if a.reassembler != nil {
+				if p = a.reassembler.Add(p); p == nil {
+					continue
+				}
+			}
+			a.Process(p, sublayerCalculator)
+		case p, ok := <-reassembled:
 			if !ok {
-				return
+				reassembled = nil
+				continue
 			}
 			a.Process(p, sublayerCalculator)
 		}
 	}
-
 }
 
 func (a *Agent) loop() {
@@ -129,34 +244,64 @@ func (a *Agent) loop() {
 		case <-a.ctx.Done():
 			log.Info("Exiting...")
 			if err := a.Receiver.Stop(); err != nil {
+				// In is not closed, the workers are left running
 				log.Error(err)
+			} else {
+				a.stopWorkers()
 			}
 			a.Concentrator.Stop()
+			a.Flusher.Stop()
 			a.TraceWriter.Stop()
+			if a.ArchiveWriter != nil {
+				a.ArchiveWriter.Stop()
+			}
 			a.StatsWriter.Stop()
 			a.ScoreSampler.Stop()
-			a.ExceptionSampler.Stop()
+			if a.ExceptionSampler != nil {
+				a.ExceptionSampler.Stop()
+			}
 			a.ErrorsScoreSampler.Stop()
 			a.PrioritySampler.Stop()
+			if a.HashSampler != nil {
+				a.HashSampler.Stop()
+			}
 			a.EventProcessor.Stop()
 			a.obfuscator.Stop()
+			a.obfuscationBypass.Stop()
+			a.metaLimiter.Stop()
 			return
 		}
 	}
 }
 
+// stopWorkers waits for the workers to process the payloads left once In is closed. Once no
+// worker receives from In anymore, no more traces are held for reassembly: the ones held are
+// flushed to the workers before they exit.
+func (a *Agent) stopWorkers() {
+	a.workersIn.Wait()
+	if a.reassembler != nil {
+		a.reassembler.Stop()
+	}
+	a.workers.Wait()
+}
+
 // Process is the default work unit that receives a trace, transforms it and
 // passes it downstream.
 func (a *Agent) Process(p *api.Payload, sublayerCalculator *stats.SublayerCalculator) {
-	if len(p.Traces) == 0 {
+	if p.TracerPayload == nil || len(p.TracerPayload.Chunks) == 0 {
 		log.Debugf("Skipping received empty payload")
 		return
 	}
 	defer timing.Since("datadog.trace_agent.internal.process_payload_ms", time.Now())
 	ts := p.Source
-	ss := new(writer.SampledSpans)
-	sinputs := make([]stats.Input, 0, len(p.Traces))
-	for _, t := range p.Traces {
+	p.TracerPayload.RuntimeID = payloadRuntimeID(p.TracerPayload.RuntimeID, p.TracerPayload.ContainerID, a.conf.InjectContainerRuntimeID)
+	ss := a.newSampledSpans(p)
+	sinputs := make([]stats.Input, 0, len(p.TracerPayload.Chunks))
+	// the stats of the payloads computed by the tracers, which include the traces they dropped,
+	// would be counted twice by the concentrator
+	clientComputedStats := p.ClientComputedStats && a.conf.ClientComputedStats
+	for _, chunk := range p.TracerPayload.Chunks {
+		t := pb.Trace(chunk.Spans)
 		if len(t) == 0 {
 			log.Debugf("Skipping received empty trace")
 			continue
@@ -183,8 +328,17 @@ func (a *Agent) Process(p *api.Payload, sublayerCalculator *stats.SublayerCalcul
 
 		// Extra sanitization steps of the trace.
 		for _, span := range t {
-			a.obfuscator.Obfuscate(span)
+			if a.obfuscationBypass.Bypass(span) {
+				// only the obfuscator of the span type is bypassed
+				a.obfuscator.Scrub(span)
+			} else {
+				a.obfuscator.Obfuscate(span)
+			}
 			Truncate(span)
+			a.metaLimiter.Limit(span)
+			if a.conf.ErrorFingerprinting {
+				fingerprintError(span)
+			}
 		}
 		a.Replacer.Replace(t)
 
@@ -202,13 +356,36 @@ func (a *Agent) Process(p *api.Payload, sublayerCalculator *stats.SublayerCalcul
 				traceutil.SetMeta(root, tagContainersTags, p.ContainerTags)
 			}
 		}
+		// the runtime ID set by the tracer on the trace takes precedence over the one of the payload
+		runtimeID := normalizeRuntimeID(root.Meta[tagRuntimeID])
+		if runtimeID == "" {
+			runtimeID = p.TracerPayload.RuntimeID
+		}
+		if runtimeID != "" {
+			traceutil.SetMeta(root, tagRuntimeID, runtimeID)
+			if chunk.Tags == nil {
+				chunk.Tags = make(map[string]string, 1)
+			}
+			chunk.Tags[tagRuntimeID] = runtimeID
+		}
 		if !p.ClientComputedTopLevel {
 			// Figure out the top-level spans and sublayers now as it involves modifying the Metrics map
 			// which is not thread-safe while samplers and Concentrator might modify it too.
 			traceutil.ComputeTopLevel(t)
 		}
+		if priority, ok := manualSamplingPriority(t); ok {
+			// the manual tags set on any span take precedence over the priority set by the tracer
+			if current, hasPriority := sampler.GetSamplingPriority(root); !hasPriority || current != priority {
+				sampler.SetSamplingPriority(root, priority)
+				if priority == sampler.PriorityUserKeep {
+					atomic.AddInt64(&ts.TracesManualKeep, 1)
+				} else {
+					atomic.AddInt64(&ts.TracesManualDrop, 1)
+				}
+			}
+		}
 
-		env := a.conf.DefaultEnv
+		env := a.payloadEnv(p)
 		if v := traceutil.GetEnv(t); v != "" {
 			// this trace has a user defined env.
 			env = v
@@ -222,43 +399,130 @@ func (a *Agent) Process(p *api.Payload, sublayerCalculator *stats.SublayerCalcul
 		}
 
 		events, keep := a.sample(ts, pt)
+		var singleSpans []*pb.Span
+		if !keep {
+			// the spans kept by single-span sampling are sent without the rest of their trace
+			singleSpans = a.SpanSampler.Sample(t)
+			atomic.AddInt64(&ts.SpansSingleSampled, int64(len(singleSpans)))
+		}
 
-		subtraces := stats.ExtractSubtraces(t, root)
-		for _, subtrace := range subtraces {
-			subtraceSublayers := sublayerCalculator.ComputeSublayers(subtrace.Trace)
-			pt.Sublayers[subtrace.Root] = subtraceSublayers
-			if keep {
-				stats.SetSublayersOnSpan(subtrace.Root, subtraceSublayers)
+		if keep || !clientComputedStats {
+			subtraces := stats.ExtractSubtraces(t, root)
+			for _, subtrace := range subtraces {
+				subtraceSublayers := sublayerCalculator.ComputeSublayers(subtrace.Trace)
+				pt.Sublayers[subtrace.Root] = subtraceSublayers
+				if keep {
+					stats.SetSublayersOnSpan(subtrace.Root, subtraceSublayers)
+				}
 			}
 		}
-		sinputs = append(sinputs, stats.Input{
-			Trace:     pt.WeightedTrace,
-			Sublayers: pt.Sublayers,
-			Env:       pt.Env,
-		})
-
-		if keep {
-			ss.Traces = append(ss.Traces, traceutil.APITrace(t))
-			ss.Size += t.Msgsize()
-			ss.SpanCount += int64(len(t))
+		if clientComputedStats {
+			atomic.AddInt64(&ts.TracesClientComputedStats, 1)
+		} else {
+			sinputs = append(sinputs, stats.Input{
+				Trace:     pt.WeightedTrace,
+				Sublayers: pt.Sublayers,
+				Env:       pt.Env,
+				RuntimeID: runtimeID,
+				Version:   a.statsVersion(root),
+				Endpoint:  p.Endpoint,
+			})
 		}
-		if len(events) > 0 {
-			ss.Events = append(ss.Events, events...)
-			ss.Size += pb.Trace(events).Msgsize()
+
+		if keep || len(events) > 0 || len(singleSpans) > 0 {
+			priority, ok := sampler.GetSamplingPriority(root)
+			if !ok {
+				priority = sampler.PriorityNone
+			}
+			chunk.Priority = int32(priority)
+			chunk.Origin = root.Meta[tagOrigin]
+			chunk.DroppedTrace = !keep
+			switch {
+			case keep:
+				chunk.Spans = t
+				ss.Size += t.Msgsize()
+				ss.SpanCount += int64(len(t))
+			case len(singleSpans) > 0:
+				chunk.Spans = singleSpans
+				ss.Size += pb.Trace(singleSpans).Msgsize()
+				ss.SpanCount += int64(len(singleSpans))
+			default:
+				chunk.Spans = nil
+			}
+			if ss.Columnar != nil {
+				ss.Columnar.Append(chunk.Spans)
+				chunk.Spans = nil
+			}
+			if len(events) > 0 {
+				ss.Events = append(ss.Events, events...)
+				ss.Size += pb.Trace(events).Msgsize()
+			}
+			ss.TracerPayload.Chunks = append(ss.TracerPayload.Chunks, chunk)
 		}
 		if ss.Size > writer.MaxPayloadSize {
-			a.TraceWriter.In <- ss
-			ss = new(writer.SampledSpans)
+			a.writeSampledSpans(ss)
+			ss = a.newSampledSpans(p)
 		}
 	}
 	if ss.Size > 0 {
-		a.TraceWriter.In <- ss
+		a.writeSampledSpans(ss)
 	}
 	if len(sinputs) > 0 {
 		a.Concentrator.In <- sinputs
 	}
 }
 
+// writeSampledSpans sends the sampled spans to the trace writer, and to the archive writer
+// when the archive is enabled.
+func (a *Agent) writeSampledSpans(ss *writer.SampledSpans) {
+	a.TraceWriter.In <- ss
+	if a.ArchiveWriter != nil {
+		a.ArchiveWriter.In <- ss
+	}
+}
+
+// statsVersion returns the version of the application the trace of root comes from, which the
+// stats of its spans are aggregated on, or an empty string if stats by version are disabled.
+func (a *Agent) statsVersion(root *pb.Span) string {
+	if !a.conf.StatsByVersion {
+		return ""
+	}
+	return root.Meta[tagVersion]
+}
+
+// payloadEnv returns the env set by the tracer on the payload p, or the default env of the
+// agent if it is not set.
+func (a *Agent) payloadEnv(p *api.Payload) string {
+	if env := p.TracerPayload.Env; env != "" {
+		return normalizeTag(env)
+	}
+	return a.conf.DefaultEnv
+}
+
+// newSampledSpans returns an empty set of sampled spans, carrying the metadata of the
+// tracer payload of p and written to its endpoint.
+func (a *Agent) newSampledSpans(p *api.Payload) *writer.SampledSpans {
+	tp := p.TracerPayload
+	ss := &writer.SampledSpans{
+		TracerPayload: &pb.TracerPayload{
+			ContainerID:     tp.ContainerID,
+			LanguageName:    tp.LanguageName,
+			LanguageVersion: tp.LanguageVersion,
+			TracerVersion:   tp.TracerVersion,
+			RuntimeID:       tp.RuntimeID,
+			Tags:            tp.Tags,
+			Env:             a.payloadEnv(p),
+			Hostname:        a.conf.Hostname,
+			AppVersion:      tp.AppVersion,
+		},
+		Endpoint: p.Endpoint,
+	}
+	if a.columnar {
+		ss.Columnar = columnar.NewBatch()
+	}
+	return ss
+}
+
 // sample decides whether the trace will be kept and extracts any APM events
 // from it.
 func (a *Agent) sample(ts *info.TagStats, pt ProcessedTrace) (events []*pb.Span, keep bool) {
@@ -299,6 +563,10 @@ func (a *Agent) sample(ts *info.TagStats, pt ProcessedTrace) (events []*pb.Span,
 // runSamplers runs all the agent's samplers on pt and returns the sampling decision
 // along with the sampling rate.
 func (a *Agent) runSamplers(pt ProcessedTrace, hasPriority bool) (bool, float64) {
+	if a.HashSampler != nil {
+		// the other samplers would make decisions specific to this agent
+		return a.HashSampler.Add(pt)
+	}
 	if hasPriority {
 		return a.samplePriorityTrace(pt)
 	}
@@ -314,6 +582,9 @@ func (a *Agent) samplePriorityTrace(pt ProcessedTrace) (sampled bool, rate float
 		sampledError, rateError := a.ErrorsScoreSampler.Add(pt)
 		return sampledError || sampledPriority, sampler.CombineRates(ratePriority, rateError)
 	}
+	if a.ExceptionSampler == nil {
+		return sampledPriority, ratePriority
+	}
 	if sampled := a.ExceptionSampler.Add(pt.Env, pt.Root, pt.Trace); sampled {
 		return sampled, 1
 	}
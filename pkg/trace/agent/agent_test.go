@@ -94,8 +94,8 @@ func TestProcess(t *testing.T) {
 			Duration: (500 * time.Millisecond).Nanoseconds(),
 		}
 		agnt.Process(&api.Payload{
-			Traces: pb.Traces{{span}},
-			Source: info.NewReceiverStats().GetTagStats(info.Tags{}),
+			TracerPayload: testutil.TracerPayload(pb.Traces{{span}}),
+			Source:        info.NewReceiverStats().GetTagStats(info.Tags{}),
 		}, stats.NewSublayerCalculator())
 
 		assert := assert.New(t)
@@ -103,6 +103,108 @@ func TestProcess(t *testing.T) {
 		assert.Equal("SELECT name FROM people WHERE age = ? AND extra = ?", span.Meta["sql.query"])
 	})
 
+	t.Run("ObfuscationBypass", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		cfg.Obfuscation = &config.ObfuscationConfig{
+			Bypass: []config.ObfuscationBypass{{Service: "Billing-DB", Obfuscators: []string{"sql"}}},
+			PII:    config.PIIObfuscationConfig{Enabled: true},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		agnt := NewAgent(ctx, cfg)
+		defer cancel()
+
+		now := time.Now()
+		newSpan := func(service string) *pb.Span {
+			return &pb.Span{
+				TraceID:  1,
+				SpanID:   1,
+				Service:  service,
+				Resource: "SELECT name FROM people WHERE age = 42",
+				Type:     "sql",
+				Start:    now.Add(-time.Second).UnixNano(),
+				Duration: (500 * time.Millisecond).Nanoseconds(),
+				Meta:     map[string]string{"usr.email": "jane.doe@example.com"},
+			}
+		}
+		bypassed, obfuscated := newSpan("billing-db"), newSpan("web-store")
+		agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayload(pb.Traces{{bypassed}, {obfuscated}}),
+			Source:        info.NewReceiverStats().GetTagStats(info.Tags{}),
+		}, stats.NewSublayerCalculator())
+
+		assert := assert.New(t)
+		assert.Equal("SELECT name FROM people WHERE age = 42", bypassed.Resource)
+		assert.Equal("SELECT name FROM people WHERE age = ?", obfuscated.Resource)
+		// personal data is masked whatever the obfuscators bypassed
+		assert.Equal("?", bypassed.Meta["usr.email"])
+		assert.Equal("?", obfuscated.Meta["usr.email"])
+	})
+
+	t.Run("ObfuscationBypassScrub", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		cfg.Obfuscation = &config.ObfuscationConfig{
+			Bypass:     []config.ObfuscationBypass{{Service: "billing-api", Obfuscators: []string{"*"}}},
+			ScrubRules: []*config.ScrubRule{{Name: "token", Pattern: `token=\w+`, Repl: "token=?"}},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		agnt := NewAgent(ctx, cfg)
+		defer cancel()
+
+		span := &pb.Span{
+			TraceID:  1,
+			SpanID:   1,
+			Service:  "billing-api",
+			Resource: "GET /invoices",
+			Type:     "http",
+			Start:    time.Now().Add(-time.Second).UnixNano(),
+			Duration: (500 * time.Millisecond).Nanoseconds(),
+			Meta:     map[string]string{"http.url": "http://billing/invoices?id=42&token=s3cr3t"},
+		}
+		agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayload(pb.Traces{{span}}),
+			Source:        info.NewReceiverStats().GetTagStats(info.Tags{}),
+		}, stats.NewSublayerCalculator())
+
+		// the HTTP obfuscator is bypassed, the scrub rules are still applied
+		assert.Equal(t, "http://billing/invoices?id=42&token=?", span.Meta["http.url"])
+	})
+
+	t.Run("ObfuscationOverride", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		keep := false
+		cfg.Obfuscation = &config.ObfuscationConfig{
+			ServiceOverrides: []config.ObfuscationOverride{{Service: "Reporting-DB", SQLQuantizeLiterals: &keep}},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		agnt := NewAgent(ctx, cfg)
+		defer cancel()
+
+		now := time.Now()
+		newSpan := func(service string) *pb.Span {
+			return &pb.Span{
+				TraceID:  1,
+				SpanID:   1,
+				Service:  service,
+				Resource: "SELECT name FROM people WHERE city = 'Paris' AND age = 42",
+				Type:     "sql",
+				Start:    now.Add(-time.Second).UnixNano(),
+				Duration: (500 * time.Millisecond).Nanoseconds(),
+			}
+		}
+		overridden, obfuscated := newSpan("reporting-db"), newSpan("web-store")
+		agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayload(pb.Traces{{overridden}, {obfuscated}}),
+			Source:        info.NewReceiverStats().GetTagStats(info.Tags{}),
+		}, stats.NewSublayerCalculator())
+
+		assert := assert.New(t)
+		assert.Equal("SELECT name FROM people WHERE city = 'Paris' AND age = 42", overridden.Resource)
+		assert.Equal("SELECT name FROM people WHERE city = ? AND age = ?", obfuscated.Resource)
+	})
+
 	t.Run("Blacklister", func(t *testing.T) {
 		cfg := config.New()
 		cfg.Endpoints[0].APIKey = "test"
@@ -133,15 +235,15 @@ func TestProcess(t *testing.T) {
 		assert := assert.New(t)
 
 		agnt.Process(&api.Payload{
-			Traces: pb.Traces{{spanValid}},
-			Source: want,
+			TracerPayload: testutil.TracerPayload(pb.Traces{{spanValid}}),
+			Source:        want,
 		}, stats.NewSublayerCalculator())
 		assert.EqualValues(0, want.TracesFiltered)
 		assert.EqualValues(0, want.SpansFiltered)
 
 		agnt.Process(&api.Payload{
-			Traces: pb.Traces{{spanInvalid, spanInvalid}},
-			Source: want,
+			TracerPayload: testutil.TracerPayload(pb.Traces{{spanInvalid, spanInvalid}}),
+			Source:        want,
 		}, stats.NewSublayerCalculator())
 		assert.EqualValues(1, want.TracesFiltered)
 		assert.EqualValues(2, want.SpansFiltered)
@@ -178,15 +280,15 @@ func TestProcess(t *testing.T) {
 		assert := assert.New(t)
 
 		agnt.Process(&api.Payload{
-			Traces: pb.Traces{{spanInvalid, spanInvalid}, {spanValid}},
-			Source: want,
+			TracerPayload: testutil.TracerPayload(pb.Traces{{spanInvalid, spanInvalid}, {spanValid}}),
+			Source:        want,
 		}, stats.NewSublayerCalculator())
 		assert.EqualValues(1, want.TracesFiltered)
 		assert.EqualValues(2, want.SpansFiltered)
 		var span *pb.Span
 		select {
 		case ss := <-agnt.TraceWriter.In:
-			span = ss.Traces[0].Spans[0]
+			span = ss.TracerPayload.Chunks[0].Spans[0]
 		case <-time.After(2 * time.Second):
 			t.Fatal("timeout: Expected one valid trace, but none were received.")
 		}
@@ -210,7 +312,7 @@ func TestProcess(t *testing.T) {
 		}
 
 		agnt.Process(&api.Payload{
-			Traces:        pb.Traces{{span}},
+			TracerPayload: testutil.TracerPayload(pb.Traces{{span}}),
 			Source:        info.NewReceiverStats().GetTagStats(info.Tags{}),
 			ContainerTags: "A:B,C",
 		}, stats.NewSublayerCalculator())
@@ -218,6 +320,97 @@ func TestProcess(t *testing.T) {
 		assert.Equal(t, "A:B,C", span.Meta[tagContainersTags])
 	})
 
+	t.Run("RuntimeID", func(t *testing.T) {
+		for _, tt := range []struct {
+			name        string
+			inject      bool
+			header      string
+			meta        string
+			containerID string
+			payload     string
+			trace       string
+		}{
+			{
+				name:    "header",
+				header:  "9E1B6B4E-3A8C-4F21-B0E4-8F0C7D7E1A52",
+				payload: "9e1b6b4e-3a8c-4f21-b0e4-8f0c7d7e1a52",
+				trace:   "9e1b6b4e-3a8c-4f21-b0e4-8f0c7d7e1a52",
+			},
+			{
+				name:    "meta",
+				header:  "9e1b6b4e-3a8c-4f21-b0e4-8f0c7d7e1a52",
+				meta:    "{1C5A3F0E-2D7B-4B8E-9F6A-0D3C2B1E4F5A}",
+				payload: "9e1b6b4e-3a8c-4f21-b0e4-8f0c7d7e1a52",
+				trace:   "1c5a3f0e-2d7b-4b8e-9f6a-0d3c2b1e4f5a",
+			},
+			{
+				name:        "no-injection",
+				containerID: "abcdef",
+			},
+			{
+				name:        "injection",
+				inject:      true,
+				containerID: "abcdef",
+				payload:     containerRuntimeID("abcdef"),
+				trace:       containerRuntimeID("abcdef"),
+			},
+			{
+				name:        "invalid",
+				inject:      true,
+				header:      "not-a-uuid",
+				containerID: "abcdef",
+				payload:     containerRuntimeID("abcdef"),
+				trace:       containerRuntimeID("abcdef"),
+			},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				cfg := config.New()
+				cfg.Endpoints[0].APIKey = "test"
+				cfg.InjectContainerRuntimeID = tt.inject
+				ctx, cancel := context.WithCancel(context.Background())
+				agnt := NewAgent(ctx, cfg)
+				defer cancel()
+
+				span := &pb.Span{
+					TraceID:  1,
+					SpanID:   1,
+					Resource: "SELECT 1",
+					Type:     "sql",
+					Start:    time.Now().Add(-time.Second).UnixNano(),
+					Duration: (500 * time.Millisecond).Nanoseconds(),
+					Metrics:  map[string]float64{sampler.KeySamplingPriority: 2},
+				}
+				if tt.meta != "" {
+					span.Meta = map[string]string{tagRuntimeID: tt.meta}
+				}
+				tp := testutil.TracerPayload(pb.Traces{{span}})
+				tp.RuntimeID = tt.header
+				tp.ContainerID = tt.containerID
+				go agnt.Process(&api.Payload{
+					TracerPayload: tp,
+					Source:        agnt.Receiver.Stats.GetTagStats(info.Tags{}),
+				}, stats.NewSublayerCalculator())
+
+				select {
+				case ss := <-agnt.TraceWriter.In:
+					assert.Equal(t, tt.payload, ss.TracerPayload.RuntimeID)
+					chunk := ss.TracerPayload.Chunks[0]
+					if tt.trace == "" {
+						assert.NotContains(t, span.Meta, tagRuntimeID)
+						assert.NotContains(t, chunk.Tags, tagRuntimeID)
+					} else {
+						assert.Equal(t, tt.trace, span.Meta[tagRuntimeID])
+						assert.Equal(t, tt.trace, chunk.Tags[tagRuntimeID])
+					}
+				case <-time.After(time.Second):
+					t.Fatal("no sampled spans")
+				}
+				inputs := <-agnt.Concentrator.In
+				assert.Equal(t, tt.trace, inputs[0].RuntimeID)
+			})
+		}
+	})
+
 	t.Run("Stats/Priority", func(t *testing.T) {
 		cfg := config.New()
 		cfg.Endpoints[0].APIKey = "test"
@@ -257,8 +450,8 @@ func TestProcess(t *testing.T) {
 				sampler.SetSamplingPriority(span, key)
 			}
 			agnt.Process(&api.Payload{
-				Traces: pb.Traces{{span}},
-				Source: want,
+				TracerPayload: testutil.TracerPayload(pb.Traces{{span}}),
+				Source:        want,
 			}, stats.NewSublayerCalculator())
 		}
 
@@ -287,14 +480,14 @@ func TestProcess(t *testing.T) {
 			Metrics:  map[string]float64{sampler.KeySamplingPriority: 2},
 		}}}
 		go agnt.Process(&api.Payload{
-			Traces: traces,
-			Source: agnt.Receiver.Stats.GetTagStats(info.Tags{}),
+			TracerPayload: testutil.TracerPayload(traces),
+			Source:        agnt.Receiver.Stats.GetTagStats(info.Tags{}),
 		}, stats.NewSublayerCalculator())
 		timeout := time.After(2 * time.Second)
 		var span *pb.Span
 		select {
 		case ss := <-agnt.TraceWriter.In:
-			span = ss.Traces[0].Spans[0]
+			span = ss.TracerPayload.Chunks[0].Spans[0]
 		case <-timeout:
 			t.Fatal("timed out")
 		}
@@ -302,6 +495,129 @@ func TestProcess(t *testing.T) {
 		assert.Equal(t, "something_that_should_be_a_metric", span.Service)
 	})
 
+	t.Run("TraceChunk", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		ctx, cancel := context.WithCancel(context.Background())
+		agnt := NewAgent(ctx, cfg)
+		defer cancel()
+
+		traces := pb.Traces{{{
+			Service:  "web",
+			Name:     "http.request",
+			TraceID:  1,
+			SpanID:   1,
+			Resource: "GET /",
+			Start:    time.Now().Add(-time.Second).UnixNano(),
+			Duration: (500 * time.Millisecond).Nanoseconds(),
+			Meta:     map[string]string{tagOrigin: "synthetics"},
+			Metrics:  map[string]float64{sampler.KeySamplingPriority: 2},
+		}}}
+		go agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayload(traces),
+			Source:        agnt.Receiver.Stats.GetTagStats(info.Tags{}),
+		}, stats.NewSublayerCalculator())
+		select {
+		case ss := <-agnt.TraceWriter.In:
+			assert.Len(t, ss.TracerPayload.Chunks, 1)
+			chunk := ss.TracerPayload.Chunks[0]
+			assert.EqualValues(t, sampler.PriorityUserKeep, chunk.Priority)
+			assert.Equal(t, "synthetics", chunk.Origin)
+			assert.False(t, chunk.DroppedTrace)
+			assert.Len(t, chunk.Spans, 1)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out")
+		}
+	})
+
+	t.Run("TracerPayloadEnv", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		cfg.DefaultEnv = "default"
+		ctx, cancel := context.WithCancel(context.Background())
+		agnt := NewAgent(ctx, cfg)
+		defer cancel()
+
+		for _, tt := range []struct {
+			payloadEnv, spanEnv, want string
+		}{
+			{"", "", "default"},
+			{"Staging", "", "staging"},
+			{"staging", "prod", "staging"},
+		} {
+			span := testutil.RandomSpan()
+			span.ParentID = 0
+			span.Metrics = map[string]float64{sampler.KeySamplingPriority: 2}
+			span.Meta = map[string]string{}
+			if tt.spanEnv != "" {
+				span.Meta["env"] = tt.spanEnv
+			}
+			tp := testutil.TracerPayload(pb.Traces{{span}})
+			tp.Env = tt.payloadEnv
+			go agnt.Process(&api.Payload{
+				TracerPayload: tp,
+				Source:        agnt.Receiver.Stats.GetTagStats(info.Tags{}),
+			}, stats.NewSublayerCalculator())
+			select {
+			case ss := <-agnt.TraceWriter.In:
+				// the payload env is the one of the tracer, not the one of its traces
+				assert.Equal(t, tt.want, ss.TracerPayload.Env)
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out")
+			}
+		}
+	})
+
+	t.Run("SingleSpanSampling", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		cfg.SpanSamplingRules = []*config.SpanSamplingRule{{Service: "web", Name: "db.query", SampleRate: 1}}
+		ctx, cancel := context.WithCancel(context.Background())
+		agnt := NewAgent(ctx, cfg)
+		defer cancel()
+
+		now := time.Now()
+		traces := pb.Traces{{{
+			Service:  "web",
+			Name:     "http.request",
+			TraceID:  1,
+			SpanID:   1,
+			Resource: "GET /",
+			Start:    now.Add(-time.Second).UnixNano(),
+			Duration: (500 * time.Millisecond).Nanoseconds(),
+			Metrics:  map[string]float64{sampler.KeySamplingPriority: -1},
+		}, {
+			Service:  "web",
+			Name:     "db.query",
+			TraceID:  1,
+			SpanID:   2,
+			ParentID: 1,
+			Resource: "SELECT 1",
+			Start:    now.Add(-time.Second).UnixNano(),
+			Duration: (100 * time.Millisecond).Nanoseconds(),
+		}}}
+		go agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayload(traces),
+			Source:        agnt.Receiver.Stats.GetTagStats(info.Tags{}),
+		}, stats.NewSublayerCalculator())
+		select {
+		case ss := <-agnt.TraceWriter.In:
+			assert.Len(t, ss.TracerPayload.Chunks, 1)
+			chunk := ss.TracerPayload.Chunks[0]
+			assert.True(t, chunk.DroppedTrace)
+			assert.EqualValues(t, sampler.PriorityUserDrop, chunk.Priority)
+			if assert.Len(t, chunk.Spans, 1) {
+				span := chunk.Spans[0]
+				assert.Equal(t, "db.query", span.Name)
+				assert.EqualValues(t, 8, span.Metrics[sampler.KeySpanSamplingMechanism])
+				assert.EqualValues(t, 1, span.Metrics[sampler.KeySpanSamplingRuleRate])
+			}
+			assert.EqualValues(t, 1, ss.SpanCount)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out")
+		}
+	})
+
 	t.Run("chunking", func(t *testing.T) {
 		cfg := config.New()
 		cfg.Endpoints[0].APIKey = "test"
@@ -326,8 +642,8 @@ func TestProcess(t *testing.T) {
 		// and expecting it to result in 3 payloads
 		expectedPayloads := 3
 		go agnt.Process(&api.Payload{
-			Traces: traces,
-			Source: agnt.Receiver.Stats.GetTagStats(info.Tags{}),
+			TracerPayload: testutil.TracerPayload(traces),
+			Source:        agnt.Receiver.Stats.GetTagStats(info.Tags{}),
 		}, stats.NewSublayerCalculator())
 
 		var gotCount int
@@ -397,8 +713,8 @@ func TestProcess(t *testing.T) {
 				traces := pb.Traces{tt.trace}
 				traceutil.SetTopLevel(tt.trace[0], true)
 				agnt.Process(&api.Payload{
-					Traces: traces,
-					Source: agnt.Receiver.Stats.GetTagStats(info.Tags{}),
+					TracerPayload: testutil.TracerPayload(traces),
+					Source:        agnt.Receiver.Stats.GetTagStats(info.Tags{}),
 				}, stats.NewSublayerCalculator())
 				tt.f(t, tt.trace)
 			})
@@ -425,7 +741,7 @@ func TestClientComputedTopLevel(t *testing.T) {
 
 	t.Run("on", func(t *testing.T) {
 		go agnt.Process(&api.Payload{
-			Traces:                 traces,
+			TracerPayload:          testutil.TracerPayload(traces),
 			Source:                 agnt.Receiver.Stats.GetTagStats(info.Tags{}),
 			ClientComputedTopLevel: true,
 		}, stats.NewSublayerCalculator())
@@ -433,7 +749,7 @@ func TestClientComputedTopLevel(t *testing.T) {
 		for {
 			select {
 			case ss := <-agnt.TraceWriter.In:
-				_, ok := ss.Traces[0].Spans[0].Metrics["_top_level"]
+				_, ok := ss.TracerPayload.Chunks[0].Spans[0].Metrics["_top_level"]
 				assert.False(t, ok)
 				return
 			case <-timeout:
@@ -444,7 +760,7 @@ func TestClientComputedTopLevel(t *testing.T) {
 
 	t.Run("off", func(t *testing.T) {
 		go agnt.Process(&api.Payload{
-			Traces:                 traces,
+			TracerPayload:          testutil.TracerPayload(traces),
 			Source:                 agnt.Receiver.Stats.GetTagStats(info.Tags{}),
 			ClientComputedTopLevel: false,
 		}, stats.NewSublayerCalculator())
@@ -452,7 +768,7 @@ func TestClientComputedTopLevel(t *testing.T) {
 		for {
 			select {
 			case ss := <-agnt.TraceWriter.In:
-				_, ok := ss.Traces[0].Spans[0].Metrics["_top_level"]
+				_, ok := ss.TracerPayload.Chunks[0].Spans[0].Metrics["_top_level"]
 				assert.True(t, ok)
 				return
 			case <-timeout:
@@ -462,6 +778,84 @@ func TestClientComputedTopLevel(t *testing.T) {
 	})
 }
 
+func TestStopWorkersReassembly(t *testing.T) {
+	cfg := config.New()
+	cfg.Endpoints[0].APIKey = "test"
+	cfg.ReassemblyWindow = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	agnt := NewAgent(ctx, cfg)
+	defer cancel()
+
+	agnt.reassembler.Start()
+	agnt.workers.Add(1)
+	agnt.workersIn.Add(1)
+	go agnt.work()
+
+	agnt.In <- &api.Payload{
+		TracerPayload: testutil.TracerPayload(pb.Traces{{{
+			Service:  "db",
+			TraceID:  1,
+			SpanID:   1,
+			Start:    time.Now().Add(-time.Second).UnixNano(),
+			Duration: (500 * time.Millisecond).Nanoseconds(),
+			Metrics:  map[string]float64{sampler.KeySamplingPriority: 2},
+		}}}),
+		Source: agnt.Receiver.Stats.GetTagStats(info.Tags{}),
+	}
+	close(agnt.In)
+	// the trace held for reassembly is processed before the workers exit
+	agnt.stopWorkers()
+	if assert.Len(t, agnt.TraceWriter.In, 1) {
+		ss := <-agnt.TraceWriter.In
+		assert.EqualValues(t, 1, ss.TracerPayload.Chunks[0].Spans[0].TraceID)
+	}
+}
+
+func TestClientComputedStats(t *testing.T) {
+	traces := pb.Traces{{{
+		Service:  "db",
+		TraceID:  1,
+		SpanID:   1,
+		Resource: "SELECT name FROM people WHERE age = 42 AND extra = 55",
+		Type:     "sql",
+		Start:    time.Now().Add(-time.Second).UnixNano(),
+		Duration: (500 * time.Millisecond).Nanoseconds(),
+		Metrics:  map[string]float64{sampler.KeySamplingPriority: 2},
+	}}}
+	for name, tt := range map[string]struct {
+		enabled, flagged, computed bool
+	}{
+		"enabled/flagged":     {enabled: true, flagged: true, computed: false},
+		"enabled/not-flagged": {enabled: true, flagged: false, computed: true},
+		"disabled/flagged":    {enabled: false, flagged: true, computed: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.Endpoints[0].APIKey = "test"
+			cfg.ClientComputedStats = tt.enabled
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			agnt := NewAgent(ctx, cfg)
+			ts := agnt.Receiver.Stats.GetTagStats(info.Tags{})
+			agnt.Process(&api.Payload{
+				TracerPayload:       testutil.TracerPayload(traces),
+				Source:              ts,
+				ClientComputedStats: tt.flagged,
+			}, stats.NewSublayerCalculator())
+
+			// the trace is sent either way
+			assert.Len(t, agnt.TraceWriter.In, 1)
+			if tt.computed {
+				assert.Len(t, agnt.Concentrator.In, 1)
+				assert.EqualValues(t, 0, ts.TracesClientComputedStats)
+			} else {
+				assert.Len(t, agnt.Concentrator.In, 0)
+				assert.EqualValues(t, 1, ts.TracesClientComputedStats)
+			}
+		})
+	}
+}
+
 func TestSampling(t *testing.T) {
 	for name, tt := range map[string]struct {
 		// hasErrors will be true if the input trace should have errors
@@ -587,6 +981,32 @@ func TestSampling(t *testing.T) {
 	}
 }
 
+func TestSamplingTraceIDHash(t *testing.T) {
+	a := &Agent{
+		ScoreSampler:       newMockSampler(true, 1),
+		ErrorsScoreSampler: newMockSampler(true, 1),
+		PrioritySampler:    newMockSampler(true, 1),
+		HashSampler:        NewHashSampler(&config.AgentConfig{TraceIDHashSampleRate: 0.5}),
+	}
+	for _, hasPriority := range []bool{false, true} {
+		var kept int
+		for i := uint64(1); i <= 1000; i++ {
+			root := &pb.Span{TraceID: i, Service: "serv1", Error: 1, Metrics: map[string]float64{}}
+			if hasPriority {
+				sampler.SetSamplingPriority(root, sampler.PriorityAutoKeep)
+			}
+			pt := ProcessedTrace{Trace: pb.Trace{root}, Root: root}
+			sampled, rate := a.runSamplers(pt, hasPriority)
+			assert.Equal(t, 0.5, rate)
+			assert.Equal(t, sampler.SampleByRate(i, 0.5), sampled)
+			if sampled {
+				kept++
+			}
+		}
+		assert.InDelta(t, 500, kept, 100)
+	}
+}
+
 func TestEventProcessorFromConf(t *testing.T) {
 	if _, ok := os.LookupEnv("INTEGRATION"); !ok {
 		t.Skip("set INTEGRATION environment variable to run")
@@ -775,8 +1195,8 @@ func runTraceProcessingBenchmark(b *testing.B, c *config.AgentConfig) {
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		ta.Process(&api.Payload{
-			Traces: pb.Traces{testutil.RandomTrace(10, 8)},
-			Source: info.NewReceiverStats().GetTagStats(info.Tags{}),
+			TracerPayload: testutil.TracerPayload(pb.Traces{testutil.RandomTrace(10, 8)}),
+			Source:        info.NewReceiverStats().GetTagStats(info.Tags{}),
 		}, stats.NewSublayerCalculator())
 	}
 }
@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+)
+
+const (
+	tagErrorStack = "error.stack"
+
+	// maxFingerprintFrames is the number of stack trace lines used to compute error fingerprints.
+	maxFingerprintFrames = 20
+)
+
+// variableFrameParts matches what changes in stack traces between occurrences of the same error:
+// line numbers, memory addresses, goroutine IDs...
+var variableFrameParts = regexp.MustCompile(`0x[0-9a-fA-F]+|[0-9]+`)
+
+// fingerprintError classifies the error of a span in error and sets its fingerprint, a hash of
+// its error type and stack trace which is the same for all the occurrences of the same error.
+func fingerprintError(s *pb.Span) {
+	if s.Error == 0 {
+		return
+	}
+	stack := s.Meta[tagErrorStack]
+	typ := s.Meta[sampler.KeyErrorType]
+	if typ == "" {
+		typ = stackErrorType(stack)
+		if typ == "" && stack == "" {
+			// nothing stable to fingerprint the error with
+			return
+		}
+		if typ != "" {
+			traceutil.SetMeta(s, sampler.KeyErrorType, typ)
+		}
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(typ))
+	for _, frame := range stackFrames(stack, typ) {
+		h.Write([]byte{'\n'})
+		h.Write([]byte(frame))
+	}
+	traceutil.SetMeta(s, sampler.KeyErrorFingerprint, fmt.Sprintf("%016x", h.Sum64()))
+}
+
+// stackErrorType extracts the type of an error from its stack trace. Depending on the language,
+// the type is on the first line (e.g. "java.lang.IllegalStateException: message") or on the
+// last one (e.g. "ValueError: message"). It returns an empty string if no type is found.
+func stackErrorType(stack string) string {
+	stack = strings.TrimSpace(stack)
+	if stack == "" {
+		return ""
+	}
+	first, last := stack, stack
+	if i := strings.IndexByte(stack, '\n'); i >= 0 {
+		first = stack[:i]
+		last = stack[strings.LastIndexByte(stack, '\n')+1:]
+	}
+	for _, line := range []string{first, last} {
+		if typ := lineErrorType(strings.TrimSpace(line)); typ != "" {
+			return typ
+		}
+	}
+	return ""
+}
+
+// lineErrorType returns the error type of a "<type>: <message>" line of a stack trace, if its
+// type looks like an exception class name.
+func lineErrorType(line string) string {
+	if i := strings.Index(line, ": "); i >= 0 {
+		line = line[:i]
+	}
+	if !strings.HasSuffix(line, "Error") && !strings.HasSuffix(line, "Exception") {
+		return ""
+	}
+	for _, r := range line {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == '$', r == ':':
+		default:
+			return ""
+		}
+	}
+	return line
+}
+
+// stackFrames returns the normalized lines of a stack trace, without the line holding the
+// error message, which usually contains values specific to each occurrence.
+func stackFrames(stack, typ string) []string {
+	var frames []string
+	for _, line := range strings.Split(stack, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || (typ != "" && strings.HasPrefix(line, typ)) {
+			continue
+		}
+		frames = append(frames, variableFrameParts.ReplaceAllString(line, ""))
+		if len(frames) == maxFingerprintFrames {
+			break
+		}
+	}
+	return frames
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	javaStack = `java.lang.IllegalStateException: order 1234 not found
+	at com.example.OrderService.get(OrderService.java:42)
+	at com.example.OrderController.show(OrderController.java:17)`
+
+	pythonStack = `Traceback (most recent call last):
+  File "/app/views.py", line 12, in show
+    order = get_order(order_id)
+  File "/app/orders.py", line 30, in get_order
+    raise ValueError("invalid order id %s" % order_id)
+ValueError: invalid order id 1234`
+
+	goStack = `goroutine 42 [running]:
+main.handler(0xc000123456)
+	/app/main.go:12 +0x1d`
+)
+
+func TestFingerprintError(t *testing.T) {
+	fingerprint := func(meta map[string]string) *pb.Span {
+		s := &pb.Span{Error: 1, Meta: meta}
+		fingerprintError(s)
+		return s
+	}
+
+	t.Run("no-error", func(t *testing.T) {
+		s := &pb.Span{Meta: map[string]string{"error.stack": javaStack}}
+		fingerprintError(s)
+		assert.NotContains(t, s.Meta, "error.fingerprint")
+	})
+
+	t.Run("no-details", func(t *testing.T) {
+		s := fingerprint(map[string]string{"error.msg": "failed"})
+		assert.NotContains(t, s.Meta, "error.fingerprint")
+		assert.NotContains(t, s.Meta, "error.type")
+	})
+
+	t.Run("classification", func(t *testing.T) {
+		for stack, typ := range map[string]string{
+			javaStack:   "java.lang.IllegalStateException",
+			pythonStack: "ValueError",
+			goStack:     "",
+		} {
+			s := fingerprint(map[string]string{"error.stack": stack})
+			assert.Equal(t, typ, s.Meta["error.type"])
+			assert.Len(t, s.Meta["error.fingerprint"], 16)
+		}
+
+		// the type set by the tracer is kept
+		s := fingerprint(map[string]string{"error.stack": javaStack, "error.type": "OrderNotFound"})
+		assert.Equal(t, "OrderNotFound", s.Meta["error.type"])
+	})
+
+	t.Run("stable", func(t *testing.T) {
+		s1 := fingerprint(map[string]string{"error.stack": javaStack})
+		s2 := fingerprint(map[string]string{
+			"error.stack": `java.lang.IllegalStateException: order 5678 not found
+	at com.example.OrderService.get(OrderService.java:45)
+	at com.example.OrderController.show(OrderController.java:17)`,
+		})
+		assert.Equal(t, s1.Meta["error.fingerprint"], s2.Meta["error.fingerprint"])
+
+		s3 := fingerprint(map[string]string{"error.stack": goStack})
+		s4 := fingerprint(map[string]string{"error.stack": `goroutine 7 [running]:
+main.handler(0xc0000abcde)
+	/app/main.go:12 +0x1d`})
+		assert.Equal(t, s3.Meta["error.fingerprint"], s4.Meta["error.fingerprint"])
+	})
+
+	t.Run("distinct", func(t *testing.T) {
+		s1 := fingerprint(map[string]string{"error.stack": javaStack})
+		s2 := fingerprint(map[string]string{
+			"error.stack": `java.lang.IllegalStateException: order 1234 not found
+	at com.example.OrderService.list(OrderService.java:42)
+	at com.example.OrderController.index(OrderController.java:17)`,
+		})
+		assert.NotEqual(t, s1.Meta["error.fingerprint"], s2.Meta["error.fingerprint"])
+
+		s3 := fingerprint(map[string]string{"error.stack": javaStack, "error.type": "OrderNotFound"})
+		assert.NotEqual(t, s1.Meta["error.fingerprint"], s3.Meta["error.fingerprint"])
+	})
+}
@@ -0,0 +1,50 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+)
+
+const (
+	// tagManualKeep is set by users on any span of a trace to force the trace to be kept.
+	tagManualKeep = "manual.keep"
+	// tagManualDrop is set by users on any span of a trace to force the trace to be dropped.
+	tagManualDrop = "manual.drop"
+)
+
+// manualSamplingPriority returns the sampling priority forced by the manual.keep or manual.drop
+// tags set on any span of t, and whether one of them is set. When both are set, the trace is
+// kept, as a trace which is dropped can not be recovered.
+func manualSamplingPriority(t pb.Trace) (sampler.SamplingPriority, bool) {
+	var drop bool
+	for _, span := range t {
+		if hasManualTag(span, tagManualKeep) {
+			return sampler.PriorityUserKeep, true
+		}
+		if hasManualTag(span, tagManualDrop) {
+			drop = true
+		}
+	}
+	if drop {
+		return sampler.PriorityUserDrop, true
+	}
+	return 0, false
+}
+
+// hasManualTag reports whether the given manual sampling tag is set on s. Depending on the
+// tracers, it is set either in the meta or in the metrics of the span, with any value which
+// is not false.
+func hasManualTag(s *pb.Span, tag string) bool {
+	if v, ok := s.Meta[tag]; ok {
+		return v != "false" && v != "0"
+	}
+	if v, ok := s.Metrics[tag]; ok {
+		return v != 0
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/api"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/DataDog/datadog-agent/pkg/trace/stats"
+	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManualSamplingPriority(t *testing.T) {
+	for name, tt := range map[string]struct {
+		trace    pb.Trace
+		priority sampler.SamplingPriority
+		ok       bool
+	}{
+		"none": {
+			trace: pb.Trace{{Meta: map[string]string{"env": "prod"}}, {}},
+		},
+		"keep-child": {
+			trace:    pb.Trace{{}, {Meta: map[string]string{tagManualKeep: ""}}},
+			priority: sampler.PriorityUserKeep,
+			ok:       true,
+		},
+		"keep-metric": {
+			trace:    pb.Trace{{}, {Metrics: map[string]float64{tagManualKeep: 1}}},
+			priority: sampler.PriorityUserKeep,
+			ok:       true,
+		},
+		"keep-false": {
+			trace: pb.Trace{{}, {Meta: map[string]string{tagManualKeep: "false"}}},
+		},
+		"drop-child": {
+			trace:    pb.Trace{{}, {Meta: map[string]string{tagManualDrop: "true"}}},
+			priority: sampler.PriorityUserDrop,
+			ok:       true,
+		},
+		"keep-and-drop": {
+			trace:    pb.Trace{{Meta: map[string]string{tagManualDrop: "true"}}, {Meta: map[string]string{tagManualKeep: "true"}}},
+			priority: sampler.PriorityUserKeep,
+			ok:       true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			priority, ok := manualSamplingPriority(tt.trace)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.priority, priority)
+		})
+	}
+}
+
+func TestProcessManualSampling(t *testing.T) {
+	newTrace := func(priority float64, childTag string) pb.Traces {
+		now := time.Now()
+		return pb.Traces{{
+			{
+				Service:  "web",
+				Name:     "http.request",
+				TraceID:  1,
+				SpanID:   1,
+				Start:    now.Add(-time.Second).UnixNano(),
+				Duration: (500 * time.Millisecond).Nanoseconds(),
+				Metrics:  map[string]float64{sampler.KeySamplingPriority: priority},
+			},
+			{
+				Service:  "web",
+				Name:     "db.query",
+				TraceID:  1,
+				SpanID:   2,
+				ParentID: 1,
+				Start:    now.Add(-time.Second).UnixNano(),
+				Duration: (100 * time.Millisecond).Nanoseconds(),
+				Meta:     map[string]string{childTag: "true"},
+			},
+		}}
+	}
+
+	t.Run("keep", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		agnt := NewAgent(ctx, cfg)
+		ts := agnt.Receiver.Stats.GetTagStats(info.Tags{})
+		agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayload(newTrace(float64(sampler.PriorityAutoDrop), tagManualKeep)),
+			Source:        ts,
+		}, stats.NewSublayerCalculator())
+
+		if !assert.Len(t, agnt.TraceWriter.In, 1) {
+			return
+		}
+		ss := <-agnt.TraceWriter.In
+		assert.Len(t, ss.TracerPayload.Chunks, 1)
+		assert.False(t, ss.TracerPayload.Chunks[0].DroppedTrace)
+		assert.EqualValues(t, sampler.PriorityUserKeep, ss.TracerPayload.Chunks[0].Priority)
+		assert.EqualValues(t, 1, ts.TracesManualKeep)
+		assert.EqualValues(t, 1, ts.TracesPriority2)
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		agnt := NewAgent(ctx, cfg)
+		ts := agnt.Receiver.Stats.GetTagStats(info.Tags{})
+		agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayload(newTrace(float64(sampler.PriorityAutoKeep), tagManualDrop)),
+			Source:        ts,
+		}, stats.NewSublayerCalculator())
+
+		assert.Len(t, agnt.TraceWriter.In, 0)
+		assert.EqualValues(t, 1, ts.TracesManualDrop)
+		assert.EqualValues(t, 1, ts.TracesPriorityNeg)
+	})
+}
@@ -0,0 +1,229 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// tagMetaOverflow specifies the name of the tag which holds the meta entries spilled
+	// from a span over the limit, as base64 encoded gzipped JSON.
+	tagMetaOverflow = "_dd.meta.overflow"
+
+	// metaPolicySpill moves the entries over the limit to tagMetaOverflow.
+	metaPolicySpill = "spill"
+	// metaPolicyDrop removes the entries over the limit.
+	metaPolicyDrop = "drop"
+
+	// maxClippedKeys is the maximum number of distinct keys reported by the clipped
+	// keys metric, to bound its cardinality. Other keys are reported as clippedKeyOther.
+	maxClippedKeys  = 100
+	clippedKeyOther = "_other"
+)
+
+// clipKey identifies the meta entries with the given key clipped using a given policy.
+type clipKey struct {
+	key    string
+	policy string
+}
+
+// metaLimiter limits the number of meta entries of spans, keeping the entries with the highest
+// priority, and reports which keys are clipped.
+type metaLimiter struct {
+	max      int
+	policy   string
+	priority []string
+
+	mu      sync.Mutex
+	counts  map[clipKey]int64
+	spans   map[string]int64  // clipped spans by policy
+	tracked map[string]string // keys clipped, mapped to their copy kept in counts
+
+	exit chan struct{}
+	done chan struct{}
+}
+
+// newMetaLimiter returns a new metaLimiter for the given configuration.
+func newMetaLimiter(conf *config.MetaLimitConfig) *metaLimiter {
+	l := &metaLimiter{
+		policy:  metaPolicySpill,
+		counts:  make(map[clipKey]int64),
+		spans:   make(map[string]int64),
+		tracked: make(map[string]string),
+		exit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if conf == nil || conf.MaxEntries <= 0 {
+		return l
+	}
+	l.max = conf.MaxEntries
+	l.priority = conf.KeyPriority
+	switch conf.Policy {
+	case "", metaPolicySpill:
+	case metaPolicyDrop:
+		l.policy = metaPolicyDrop
+	default:
+		log.Warnf("Unknown meta limit policy %q, using %q", conf.Policy, metaPolicySpill)
+	}
+	log.Infof("Meta limit enabled: spans are limited to %d meta entries (policy: %s)", l.max, l.policy)
+	return l
+}
+
+// rank returns the rank of a meta key, the entries with the lowest ranks being kept first.
+func (l *metaLimiter) rank(key string) int {
+	if strings.HasPrefix(key, "_dd.") {
+		// internal tags are used by the agent and the backend and are never clipped
+		return -1
+	}
+	for i, p := range l.priority {
+		if key == p || (strings.HasSuffix(p, "*") && strings.HasPrefix(key, p[:len(p)-1])) {
+			return i
+		}
+	}
+	return len(l.priority)
+}
+
+// Limit applies the limit to the meta entries of s.
+func (l *metaLimiter) Limit(s *pb.Span) {
+	if l.max <= 0 || len(s.Meta) <= l.max {
+		return
+	}
+	keep := l.max
+	if l.policy == metaPolicySpill {
+		// the overflow tag takes one of the entries of the span
+		keep--
+	}
+	keys := make([]string, 0, len(s.Meta))
+	for k := range s.Meta {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, rj := l.rank(keys[i]), l.rank(keys[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+
+	overflow := make(map[string]string, len(keys)-keep)
+	for _, k := range keys[keep:] {
+		if l.rank(k) < 0 {
+			continue
+		}
+		overflow[k] = s.Meta[k]
+		delete(s.Meta, k)
+	}
+	if len(overflow) == 0 {
+		return
+	}
+
+	policy := l.policy
+	if policy == metaPolicySpill {
+		blob, err := encodeMetaOverflow(overflow)
+		switch {
+		case err != nil:
+			log.Debugf("Failed to encode meta overflow, dropping %d entries: %v", len(overflow), err)
+			policy = metaPolicyDrop
+		case len(blob) > MaxMetaValLen:
+			log.Debugf("Meta overflow too large (%d bytes), dropping %d entries", len(blob), len(overflow))
+			policy = metaPolicyDrop
+		default:
+			s.Meta[tagMetaOverflow] = blob
+		}
+	}
+	l.count(overflow, policy)
+}
+
+// count records the keys clipped from a span.
+func (l *metaLimiter) count(overflow map[string]string, policy string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.spans[policy]++
+	for k := range overflow {
+		if tracked, ok := l.tracked[k]; ok {
+			k = tracked
+		} else if len(l.tracked) >= maxClippedKeys {
+			k = clippedKeyOther
+		} else {
+			// the key outlives the payload the span comes from (see pb.CopyString)
+			k = pb.CopyString(k)
+			l.tracked[k] = k
+			log.Debugf("Meta key %q clipped for the first time (policy: %s)", k, policy)
+		}
+		l.counts[clipKey{key: k, policy: policy}]++
+	}
+}
+
+// encodeMetaOverflow returns the base64 encoded gzipped JSON representation of meta.
+func encodeMetaOverflow(meta map[string]string) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(meta); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// Start starts reporting the clipped keys.
+func (l *metaLimiter) Start() {
+	if l.max <= 0 {
+		close(l.done)
+		return
+	}
+	go func() {
+		defer close(l.done)
+		tick := time.NewTicker(10 * time.Second)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				l.flush()
+			case <-l.exit:
+				l.flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops reporting the clipped keys, flushing the remaining counts.
+func (l *metaLimiter) Stop() {
+	close(l.exit)
+	<-l.done
+}
+
+// flush reports the number of clipped spans and entries since the last flush.
+func (l *metaLimiter) flush() {
+	l.mu.Lock()
+	counts, spans := l.counts, l.spans
+	l.counts = make(map[clipKey]int64, len(counts))
+	l.spans = make(map[string]int64, len(spans))
+	l.mu.Unlock()
+
+	for policy, n := range spans {
+		metrics.Count("datadog.trace_agent.meta.clipped_spans", n, []string{"policy:" + policy}, 1)
+	}
+	for key, n := range counts {
+		tags := []string{"key:" + key.key, "policy:" + key.policy}
+		metrics.Count("datadog.trace_agent.meta.clipped_keys", n, tags, 1)
+	}
+}
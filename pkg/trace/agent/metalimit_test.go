@@ -0,0 +1,177 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+	"unsafe"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeMetaOverflow(t *testing.T, blob string) map[string]string {
+	data, err := base64.StdEncoding.DecodeString(blob)
+	assert.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	assert.NoError(t, err)
+	var meta map[string]string
+	assert.NoError(t, json.NewDecoder(gz).Decode(&meta))
+	return meta
+}
+
+func metaLimitSpan() *pb.Span {
+	return &pb.Span{
+		Meta: map[string]string{
+			"_dd.origin":       "synthetics",
+			"http.method":      "GET",
+			"http.url":         "/raclette",
+			"db.statement":     "SELECT 1",
+			"custom.a":         "a",
+			"custom.b":         "b",
+			"user.preferences": "fondue",
+		},
+	}
+}
+
+func TestMetaLimiter(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		for _, conf := range []*config.MetaLimitConfig{nil, {MaxEntries: 0}} {
+			l := newMetaLimiter(conf)
+			span := metaLimitSpan()
+			l.Limit(span)
+			assert.Equal(t, metaLimitSpan(), span)
+			l.Start()
+			l.Stop()
+		}
+	})
+
+	t.Run("under-limit", func(t *testing.T) {
+		l := newMetaLimiter(&config.MetaLimitConfig{MaxEntries: 7})
+		span := metaLimitSpan()
+		l.Limit(span)
+		assert.Equal(t, metaLimitSpan(), span)
+		assert.Empty(t, l.counts)
+	})
+
+	t.Run("spill", func(t *testing.T) {
+		l := newMetaLimiter(&config.MetaLimitConfig{
+			MaxEntries:  4,
+			KeyPriority: []string{"db.statement", "http.*"},
+		})
+		span := metaLimitSpan()
+		l.Limit(span)
+
+		// the overflow tag counts in the limit
+		assert.Len(t, span.Meta, 4)
+		blob := span.Meta[tagMetaOverflow]
+		delete(span.Meta, tagMetaOverflow)
+		assert.Equal(t, map[string]string{
+			"_dd.origin":   "synthetics",
+			"db.statement": "SELECT 1",
+			"http.method":  "GET",
+		}, span.Meta)
+		assert.Equal(t, map[string]string{
+			"http.url":         "/raclette",
+			"custom.a":         "a",
+			"custom.b":         "b",
+			"user.preferences": "fondue",
+		}, decodeMetaOverflow(t, blob))
+
+		assert.Equal(t, map[string]int64{metaPolicySpill: 1}, l.spans)
+		assert.Equal(t, map[clipKey]int64{
+			{key: "http.url", policy: metaPolicySpill}:         1,
+			{key: "custom.a", policy: metaPolicySpill}:         1,
+			{key: "custom.b", policy: metaPolicySpill}:         1,
+			{key: "user.preferences", policy: metaPolicySpill}: 1,
+		}, l.counts)
+
+		l.flush()
+		assert.Empty(t, l.counts)
+		assert.Empty(t, l.spans)
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		l := newMetaLimiter(&config.MetaLimitConfig{
+			MaxEntries:  3,
+			Policy:      metaPolicyDrop,
+			KeyPriority: []string{"user.preferences"},
+		})
+		span := metaLimitSpan()
+		l.Limit(span)
+		assert.Equal(t, map[string]string{
+			"_dd.origin":       "synthetics",
+			"user.preferences": "fondue",
+			"custom.a":         "a",
+		}, span.Meta)
+		assert.Equal(t, map[string]int64{metaPolicyDrop: 1}, l.spans)
+		assert.Len(t, l.counts, 4)
+	})
+
+	t.Run("internal-keys", func(t *testing.T) {
+		l := newMetaLimiter(&config.MetaLimitConfig{MaxEntries: 1, Policy: metaPolicyDrop})
+		span := &pb.Span{Meta: map[string]string{
+			"_dd.origin":   "synthetics",
+			"_dd.hostname": "host",
+			"env":          "prod",
+		}}
+		l.Limit(span)
+		assert.Equal(t, map[string]string{
+			"_dd.origin":   "synthetics",
+			"_dd.hostname": "host",
+		}, span.Meta)
+	})
+
+	t.Run("overflow-too-large", func(t *testing.T) {
+		l := newMetaLimiter(&config.MetaLimitConfig{MaxEntries: 1})
+		span := &pb.Span{Meta: make(map[string]string)}
+		r := rand.New(rand.NewSource(42))
+		for i := 0; i < 100; i++ {
+			// random values do not compress well
+			value := make([]byte, 100)
+			r.Read(value)
+			span.Meta[fmt.Sprintf("key.%d", i)] = hex.EncodeToString(value)
+		}
+		l.Limit(span)
+		// all the entries are dropped as the slot kept for the overflow tag is left empty
+		assert.Empty(t, span.Meta)
+		assert.Equal(t, map[string]int64{metaPolicyDrop: 1}, l.spans)
+	})
+
+	t.Run("cardinality", func(t *testing.T) {
+		l := newMetaLimiter(&config.MetaLimitConfig{MaxEntries: 1, Policy: metaPolicyDrop})
+		for i := 0; i < maxClippedKeys+10; i++ {
+			l.Limit(&pb.Span{Meta: map[string]string{
+				"a":                    "kept",
+				fmt.Sprintf("b.%d", i): "clipped",
+			}})
+		}
+		assert.Len(t, l.tracked, maxClippedKeys)
+		assert.Len(t, l.counts, maxClippedKeys+1)
+		assert.Equal(t, int64(10), l.counts[clipKey{key: clippedKeyOther, policy: metaPolicyDrop}])
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		l := newMetaLimiter(&config.MetaLimitConfig{MaxEntries: 1, Policy: metaPolicyDrop})
+		// the strings of decoded payloads may share their memory, which is reused
+		buf := []byte("b.1")
+		key := *(*string)(unsafe.Pointer(&buf))
+		l.Limit(&pb.Span{Meta: map[string]string{"a": "kept", key: "clipped"}})
+		copy(buf, "b.2")
+
+		assert.Equal(t, map[string]string{"b.1": "b.1"}, l.tracked)
+		assert.Equal(t, map[clipKey]int64{{key: "b.1", policy: metaPolicyDrop}: 1}, l.counts)
+	})
+}
@@ -8,7 +8,6 @@ package agent
 import (
 	"errors"
 	"fmt"
-	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,6 +18,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/trace/info"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb/validate"
 	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
@@ -37,8 +37,10 @@ const (
 )
 
 var (
-	// Year2000NanosecTS is an arbitrary cutoff to spot weird-looking values
-	Year2000NanosecTS = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+	// Year2000NanosecTS is an arbitrary cutoff to spot weird-looking values.
+	//
+	// Deprecated: use validate.Year2000NanosecTS.
+	Year2000NanosecTS = validate.Year2000NanosecTS
 )
 
 // fallbackServiceNames is a cache of default service names to use
@@ -65,11 +67,11 @@ func fallbackService(lang string) string {
 // normalize makes sure a Span is properly initialized and encloses the minimum required info, returning error if it
 // is invalid beyond repair
 func normalize(ts *info.TagStats, s *pb.Span) error {
-	if s.TraceID == 0 {
+	if validate.TraceID(s.TraceID) != nil {
 		atomic.AddInt64(&ts.TracesDropped.TraceIDZero, 1)
 		return fmt.Errorf("TraceID is zero (reason:trace_id_zero): %s", s)
 	}
-	if s.SpanID == 0 {
+	if validate.SpanID(s.SpanID) != nil {
 		atomic.AddInt64(&ts.TracesDropped.SpanIDZero, 1)
 		return fmt.Errorf("SpanID is zero (reason:span_id_zero): %s", s)
 	}
@@ -130,17 +132,17 @@ func normalize(ts *info.TagStats, s *pb.Span) error {
 	// Start & Duration as nanoseconds timestamps
 	// if s.Start is very little, less than year 2000 probably a unit issue so discard
 	// (or it is "le bug de l'an 2000")
-	if s.Duration < 0 {
+	switch validate.Duration(s.Start, s.Duration) {
+	case validate.ErrDurationNegative:
 		atomic.AddInt64(&ts.SpansMalformed.InvalidDuration, 1)
 		log.Debugf("Fixing malformed trace. Duration is invalid (reason:invalid_duration), setting span.duration=0: %s", s)
 		s.Duration = 0
-	}
-	if s.Duration > math.MaxInt64-s.Start {
+	case validate.ErrDurationOverflow:
 		atomic.AddInt64(&ts.SpansMalformed.InvalidDuration, 1)
 		log.Debugf("Fixing malformed trace. Duration is too large and causes overflow (reason:invalid_duration), setting span.duration=0: %s", s)
 		s.Duration = 0
 	}
-	if s.Start < Year2000NanosecTS {
+	if validate.Start(s.Start) != nil {
 		atomic.AddInt64(&ts.SpansMalformed.InvalidStartDate, 1)
 		log.Debugf("Fixing malformed trace. Start date is invalid (reason:invalid_start_date), setting span.start=time.now(): %s", s)
 		now := time.Now().UnixNano()
@@ -169,6 +171,7 @@ func normalize(ts *info.TagStats, s *pb.Span) error {
 }
 
 // normalizeTrace takes a trace and
+// * applies the quirks registered for the language of the tracer to its spans
 // * rejects the trace if there is a trace ID discrepancy between 2 spans
 // * rejects the trace if two spans have the same span_id
 // * rejects empty traces
@@ -184,12 +187,16 @@ func normalizeTrace(ts *info.TagStats, t pb.Trace) error {
 
 	spanIDs := make(map[uint64]struct{})
 	firstSpan := t[0]
+	quirks := quirksFor(ts.Lang)
 
 	for _, span := range t {
 		if span.TraceID != firstSpan.TraceID {
 			atomic.AddInt64(&ts.TracesDropped.ForeignSpan, 1)
 			return fmt.Errorf("trace has foreign span (reason:foreign_span): %s", span)
 		}
+		for _, q := range quirks {
+			q.Apply(span)
+		}
 		if err := normalize(ts, span); err != nil {
 			return err
 		}
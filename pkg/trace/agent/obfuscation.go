@@ -0,0 +1,168 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/obfuscate"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// allObfuscators is the value disabling all obfuscators for a service.
+const allObfuscators = "*"
+
+// bypassKey identifies the spans of a service which bypassed a given obfuscator.
+type bypassKey struct {
+	service    string
+	obfuscator string
+}
+
+// obfuscationBypass decides which spans should not be obfuscated, based on their
+// service, and reports how many spans bypassed obfuscation.
+type obfuscationBypass struct {
+	// services maps normalized service names to the set of obfuscators disabled for them.
+	services map[string]map[string]struct{}
+
+	mu     sync.Mutex
+	counts map[bypassKey]int64
+	seen   map[bypassKey]bypassKey // keys seen, mapped to their copy kept in counts
+
+	exit chan struct{}
+	done chan struct{}
+}
+
+// newObfuscator returns a new obfuscator for the given configuration. The services of the
+// overrides are normalized, as the ones of the spans they apply to are.
+func newObfuscator(conf *config.ObfuscationConfig) *obfuscate.Obfuscator {
+	if conf == nil || len(conf.ServiceOverrides) == 0 {
+		return obfuscate.NewObfuscator(conf)
+	}
+	c := *conf
+	c.ServiceOverrides = make([]config.ObfuscationOverride, 0, len(conf.ServiceOverrides))
+	for _, override := range conf.ServiceOverrides {
+		service := normalizeTag(override.Service)
+		if service == "" {
+			log.Warnf("Ignoring obfuscation override with invalid service %q", override.Service)
+			continue
+		}
+		override.Service = service
+		c.ServiceOverrides = append(c.ServiceOverrides, override)
+		log.Infof("Obfuscation settings overridden for spans of service %q", service)
+	}
+	return obfuscate.NewObfuscator(&c)
+}
+
+// newObfuscationBypass returns a new obfuscationBypass for the given configuration.
+func newObfuscationBypass(conf *config.ObfuscationConfig) *obfuscationBypass {
+	b := &obfuscationBypass{
+		services: make(map[string]map[string]struct{}),
+		counts:   make(map[bypassKey]int64),
+		seen:     make(map[bypassKey]bypassKey),
+		exit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if conf == nil {
+		return b
+	}
+	for _, rule := range conf.Bypass {
+		// spans are normalized before being obfuscated, so services have to be too
+		service := normalizeTag(rule.Service)
+		if service == "" {
+			log.Warnf("Ignoring obfuscation bypass with invalid service %q", rule.Service)
+			continue
+		}
+		for _, name := range rule.Obfuscators {
+			if name != allObfuscators && obfuscate.SpanObfuscator(name) != name {
+				log.Warnf("Ignoring unknown obfuscator %q in obfuscation bypass for service %q", name, service)
+				continue
+			}
+			if _, ok := b.services[service]; !ok {
+				b.services[service] = make(map[string]struct{})
+			}
+			b.services[service][name] = struct{}{}
+			log.Infof("Obfuscation bypass enabled: obfuscator %q will not be applied to spans of service %q", name, service)
+		}
+	}
+	return b
+}
+
+// Bypass reports whether span should not be obfuscated.
+func (b *obfuscationBypass) Bypass(span *pb.Span) bool {
+	if len(b.services) == 0 {
+		return false
+	}
+	disabled, ok := b.services[span.Service]
+	if !ok {
+		return false
+	}
+	name := obfuscate.SpanObfuscator(span.Type)
+	if name == "" {
+		// nothing to obfuscate anyway
+		return false
+	}
+	_, all := disabled[allObfuscators]
+	if _, ok := disabled[name]; !ok && !all {
+		return false
+	}
+
+	b.mu.Lock()
+	key, ok := b.seen[bypassKey{service: span.Service, obfuscator: name}]
+	if !ok {
+		// the key outlives the payload the span comes from (see pb.CopyString)
+		key = bypassKey{service: pb.CopyString(span.Service), obfuscator: name}
+		b.seen[key] = key
+		log.Infof("Obfuscation bypassed for the first time: obfuscator %q was not applied to a span of service %q", name, span.Service)
+	}
+	b.counts[key]++
+	b.mu.Unlock()
+	return true
+}
+
+// Start starts reporting bypass telemetry.
+func (b *obfuscationBypass) Start() {
+	if len(b.services) == 0 {
+		close(b.done)
+		return
+	}
+	go func() {
+		defer close(b.done)
+		tick := time.NewTicker(10 * time.Second)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				b.flush()
+			case <-b.exit:
+				b.flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops reporting bypass telemetry, flushing the remaining counts.
+func (b *obfuscationBypass) Stop() {
+	close(b.exit)
+	<-b.done
+}
+
+// flush reports the number of spans which bypassed obfuscation since the last flush.
+func (b *obfuscationBypass) flush() {
+	b.mu.Lock()
+	counts := b.counts
+	b.counts = make(map[bypassKey]int64, len(counts))
+	b.mu.Unlock()
+
+	for key, n := range counts {
+		tags := []string{"service:" + key.service, "obfuscator:" + key.obfuscator}
+		metrics.Count("datadog.trace_agent.obfuscation.bypassed", n, tags, 1)
+	}
+}
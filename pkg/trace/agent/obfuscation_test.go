@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObfuscationBypass(t *testing.T) {
+	b := newObfuscationBypass(&config.ObfuscationConfig{
+		Bypass: []config.ObfuscationBypass{
+			{Service: "billing-db", Obfuscators: []string{"sql", "unknown"}},
+			{Service: "Internal Cache", Obfuscators: []string{"*"}},
+			{Service: "", Obfuscators: []string{"*"}},
+		},
+	})
+
+	for _, tt := range []struct {
+		service, typ string
+		bypass       bool
+	}{
+		{"billing-db", "sql", true},
+		{"billing-db", "cassandra", true},
+		{"billing-db", "redis", false},
+		{"billing-db", "custom", false},
+		{"internal_cache", "redis", true},
+		{"internal_cache", "memcached", true},
+		{"internal_cache", "custom", false},
+		{"web-store", "sql", false},
+	} {
+		span := &pb.Span{Service: tt.service, Type: tt.typ}
+		assert.Equal(t, tt.bypass, b.Bypass(span), "%s/%s", tt.service, tt.typ)
+	}
+
+	assert.Equal(t, map[bypassKey]int64{
+		{service: "billing-db", obfuscator: "sql"}:           2,
+		{service: "internal_cache", obfuscator: "redis"}:     1,
+		{service: "internal_cache", obfuscator: "memcached"}: 1,
+	}, b.counts)
+
+	b.flush()
+	assert.Empty(t, b.counts)
+
+	t.Run("disabled", func(t *testing.T) {
+		b := newObfuscationBypass(nil)
+		assert.False(t, b.Bypass(&pb.Span{Service: "billing-db", Type: "sql"}))
+		b.Start()
+		b.Stop()
+	})
+}
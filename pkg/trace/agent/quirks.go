@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// LanguageQuirk is a fixup applied to the spans sent by the tracers of a language before
+// they are normalized, working around what those tracers do differently from others.
+type LanguageQuirk struct {
+	// Name identifies the quirk in logs and tests.
+	Name string
+	// Apply fixes up a span. It is called for every span of the language, and must return
+	// quickly when the span is not affected.
+	Apply func(s *pb.Span)
+	// OptIn reports whether the quirk changes the spans of existing setups, for example by
+	// renaming their operations, in which case it only applies once enabled by name with
+	// apm_config.language_quirks.
+	OptIn bool
+}
+
+var (
+	// languageQuirks holds the registered quirks, keyed by lower-cased language as reported
+	// by tracers in the Datadog-Meta-Lang header.
+	languageQuirks = make(map[string][]LanguageQuirk)
+	// enabledQuirks holds the names of the opt-in quirks which are enabled.
+	enabledQuirks = make(map[string]bool)
+	// activeQuirks holds the map[string][]LanguageQuirk of the quirks applied, keyed like
+	// languageQuirks: the ones which are not opt-in, and the enabled ones.
+	activeQuirks atomic.Value
+)
+
+// RegisterLanguageQuirk registers a quirk applied to the spans of the given language. It is
+// not safe for concurrent use and must be called before the agent starts processing traces,
+// typically from an init function.
+func RegisterLanguageQuirk(lang string, q LanguageQuirk) {
+	lang = strings.ToLower(lang)
+	languageQuirks[lang] = append(languageQuirks[lang], q)
+	updateActiveQuirks()
+}
+
+// enableLanguageQuirks enables the opt-in quirks with the given names, disabling the others.
+// Like RegisterLanguageQuirk, it must be called before the agent starts processing traces.
+func enableLanguageQuirks(names []string) {
+	optIn := make(map[string]bool)
+	for _, qs := range languageQuirks {
+		for _, q := range qs {
+			optIn[q.Name] = q.OptIn
+		}
+	}
+	enabledQuirks = make(map[string]bool, len(names))
+	for _, name := range names {
+		if !optIn[name] {
+			log.Warnf("Unknown opt-in language quirk %q in apm_config.language_quirks, ignoring it", name)
+			continue
+		}
+		enabledQuirks[name] = true
+	}
+	updateActiveQuirks()
+}
+
+// updateActiveQuirks computes the quirks applied from the registered ones.
+func updateActiveQuirks() {
+	active := make(map[string][]LanguageQuirk, len(languageQuirks))
+	for lang, qs := range languageQuirks {
+		for _, q := range qs {
+			if !q.OptIn || enabledQuirks[q.Name] {
+				active[lang] = append(active[lang], q)
+			}
+		}
+	}
+	activeQuirks.Store(active)
+}
+
+// quirksFor returns the quirks applied to the spans of lang.
+func quirksFor(lang string) []LanguageQuirk {
+	active, _ := activeQuirks.Load().(map[string][]LanguageQuirk)
+	if lang == "" || len(active) == 0 {
+		return nil
+	}
+	if qs, ok := active[lang]; ok {
+		return qs
+	}
+	return active[strings.ToLower(lang)]
+}
+
+func init() {
+	RegisterLanguageQuirk(".NET", LanguageQuirk{Name: "http_method_case", Apply: upperCaseHTTPMethod})
+	// span names would change for users of the PHP tracer, and with them the monitors
+	// and dashboards based on the metrics of these spans
+	RegisterLanguageQuirk("php", LanguageQuirk{Name: "namespace_separator", Apply: phpNamespaceSeparator, OptIn: true})
+}
+
+// httpMethods are the HTTP methods looked for at the beginning of resources.
+var httpMethods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS", "CONNECT", "TRACE"}
+
+// upperCaseHTTPMethod upper-cases the HTTP method starting the resource of web spans. Some
+// versions of the .NET tracer report it as it was received, so that "get /home" and
+// "GET /home" end up as distinct resources.
+func upperCaseHTTPMethod(s *pb.Span) {
+	if s.Type != "web" && s.Type != "http" {
+		return
+	}
+	i := strings.IndexByte(s.Resource, ' ')
+	if i <= 0 {
+		return
+	}
+	method := s.Resource[:i]
+	for _, m := range httpMethods {
+		if method != m && strings.EqualFold(method, m) {
+			s.Resource = m + s.Resource[i:]
+			return
+		}
+	}
+}
+
+// phpNamespaceSeparator maps the namespace separators of the PHP class names found in the
+// span names of the PHP tracer (e.g. "Predis\Client.executeCommand") to dots, instead of
+// letting them be replaced by underscores like other invalid characters.
+func phpNamespaceSeparator(s *pb.Span) {
+	if strings.IndexByte(s.Name, '\\') < 0 {
+		return
+	}
+	s.Name = strings.Trim(strings.Replace(s.Name, "\\", ".", -1), ".")
+}
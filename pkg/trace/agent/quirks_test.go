@@ -0,0 +1,99 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpperCaseHTTPMethod(t *testing.T) {
+	for _, tt := range []struct {
+		typ, in, out string
+	}{
+		{"web", "get /home/index", "GET /home/index"},
+		{"http", "Post /api", "POST /api"},
+		{"web", "GET /home/index", "GET /home/index"},
+		{"web", "get", "get"},
+		{"web", "fetch /home", "fetch /home"},
+		{"sql", "delete from users", "delete from users"},
+	} {
+		s := &pb.Span{Type: tt.typ, Resource: tt.in}
+		upperCaseHTTPMethod(s)
+		assert.Equal(t, tt.out, s.Resource)
+	}
+}
+
+func TestPHPNamespaceSeparator(t *testing.T) {
+	for in, out := range map[string]string{
+		`Predis\Client.executeCommand`: "Predis.Client.executeCommand",
+		`\PDO.query`:                   "PDO.query",
+		"laravel.request":              "laravel.request",
+	} {
+		s := &pb.Span{Name: in}
+		phpNamespaceSeparator(s)
+		assert.Equal(t, out, s.Name)
+	}
+}
+
+func TestNormalizeTraceLanguageQuirks(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newTagStats()
+	ts.Lang = ".NET"
+	span := newTestSpan()
+	span.Resource = "get /some/raclette"
+	assert.NoError(normalizeTrace(ts, pb.Trace{span}))
+	assert.Equal("GET /some/raclette", span.Resource)
+
+	// the PHP quirk is opt-in
+	ts = newTagStats()
+	ts.Lang = "PHP"
+	span = newTestSpan()
+	span.Name = `Predis\Client.executeCommand`
+	assert.NoError(normalizeTrace(ts, pb.Trace{span}))
+	assert.Equal("Predis_Client.executeCommand", span.Name)
+
+	enableLanguageQuirks([]string{"namespace_separator", "unknown"})
+	defer enableLanguageQuirks(nil)
+	span = newTestSpan()
+	span.Name = `Predis\Client.executeCommand`
+	assert.NoError(normalizeTrace(ts, pb.Trace{span}))
+	assert.Equal("Predis.Client.executeCommand", span.Name)
+
+	// quirks only apply to their language
+	ts = newTagStats()
+	ts.Lang = "python"
+	span = newTestSpan()
+	span.Resource = "get /some/raclette"
+	assert.NoError(normalizeTrace(ts, pb.Trace{span}))
+	assert.Equal("get /some/raclette", span.Resource)
+}
+
+func TestRegisterLanguageQuirk(t *testing.T) {
+	defer func() {
+		delete(languageQuirks, "cpp")
+		updateActiveQuirks()
+	}()
+
+	RegisterLanguageQuirk("CPP", LanguageQuirk{
+		Name: "test",
+		Apply: func(s *pb.Span) {
+			s.Service = "fixed"
+		},
+	})
+	qs := quirksFor("cpp")
+	assert.Len(t, qs, 1)
+	assert.Equal(t, "test", qs[0].Name)
+
+	span := newTestSpan()
+	for _, q := range quirksFor("Cpp") {
+		q.Apply(span)
+	}
+	assert.Equal(t, "fixed", span.Service)
+}
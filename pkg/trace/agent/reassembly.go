@@ -0,0 +1,227 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/api"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// reassemblyKey identifies the chunks of a trace sent by the same tracer.
+type reassemblyKey struct {
+	traceID     uint64
+	runtimeID   string
+	containerID string
+	endpoint    *config.Endpoint
+}
+
+// pendingTrace is a trace held by the reassembler.
+type pendingTrace struct {
+	key reassemblyKey
+	// payload is the payload of the first chunk of the trace, whose metadata the trace is
+	// processed with.
+	payload *api.Payload
+	chunk   *pb.TraceChunk
+	chunks  int // number of chunks merged into chunk
+	expire  time.Time
+}
+
+// reassembler holds the chunks of the traces received for a short window, merging the chunks of
+// the same trace sent by a tracer in several payloads, as the tracers flushing partial traces do,
+// so that the trace is sampled and its stats computed as a whole.
+type reassembler struct {
+	window   time.Duration
+	maxSpans int
+
+	// Out receives the payloads holding the traces whose window expired. It is closed once
+	// the reassembler is stopped and all the traces held are flushed.
+	Out chan *api.Payload
+
+	mu      sync.Mutex
+	pending map[reassemblyKey]*pendingTrace
+	queue   []*pendingTrace // pending traces by expiration
+	spans   int             // number of spans held
+
+	// counters reported as metrics
+	traces      int64 // traces flushed
+	reassembled int64 // traces flushed made of several chunks
+	overflow    int64 // chunks processed right away because of maxSpans
+
+	exit chan struct{}
+	done chan struct{}
+}
+
+// newReassembler returns a reassembler for the given configuration, or nil if the reassembly
+// is disabled.
+func newReassembler(conf *config.AgentConfig) *reassembler {
+	if conf.ReassemblyWindow <= 0 {
+		return nil
+	}
+	log.Infof("Trace reassembly enabled: chunks are held for %s (max spans: %d)", conf.ReassemblyWindow, conf.ReassemblyMaxSpans)
+	return &reassembler{
+		window:   conf.ReassemblyWindow,
+		maxSpans: conf.ReassemblyMaxSpans,
+		Out:      make(chan *api.Payload, 100),
+		pending:  make(map[reassemblyKey]*pendingTrace),
+		exit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Add holds the chunks of p for reassembly. It returns a payload holding the chunks which
+// can't be held without going over the maximum number of spans, to be processed right away,
+// or nil if all of them are held.
+func (r *reassembler) Add(p *api.Payload) *api.Payload {
+	var rest []*pb.TraceChunk
+	expire := time.Now().Add(r.window)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, chunk := range p.TracerPayload.Chunks {
+		if len(chunk.Spans) == 0 {
+			continue
+		}
+		if r.maxSpans > 0 && r.spans+len(chunk.Spans) > r.maxSpans {
+			rest = append(rest, chunk)
+			r.overflow++
+			continue
+		}
+		r.spans += len(chunk.Spans)
+		key := reassemblyKey{
+			traceID:     chunk.Spans[0].TraceID,
+			runtimeID:   p.TracerPayload.RuntimeID,
+			containerID: p.TracerPayload.ContainerID,
+			endpoint:    p.Endpoint,
+		}
+		if t, ok := r.pending[key]; ok {
+			mergeChunk(t.chunk, chunk)
+			t.chunks++
+			continue
+		}
+		t := &pendingTrace{key: key, payload: p, chunk: chunk, chunks: 1, expire: expire}
+		r.pending[key] = t
+		r.queue = append(r.queue, t)
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+	return payloadWithChunks(p, rest)
+}
+
+// mergeChunk adds the spans and the tags of src to dst. The tags of dst take precedence.
+func mergeChunk(dst, src *pb.TraceChunk) {
+	dst.Spans = append(dst.Spans, src.Spans...)
+	for k, v := range src.Tags {
+		if dst.Tags == nil {
+			dst.Tags = make(map[string]string, len(src.Tags))
+		}
+		if _, ok := dst.Tags[k]; !ok {
+			dst.Tags[k] = v
+		}
+	}
+	if src.Priority > dst.Priority {
+		dst.Priority = src.Priority
+	}
+	if dst.Origin == "" {
+		dst.Origin = src.Origin
+	}
+}
+
+// payloadWithChunks returns a copy of p holding the given chunks.
+func payloadWithChunks(p *api.Payload, chunks []*pb.TraceChunk) *api.Payload {
+	tp := *p.TracerPayload
+	tp.Chunks = chunks
+	out := *p
+	out.TracerPayload = &tp
+	return &out
+}
+
+// Start starts flushing the traces whose window expired.
+func (r *reassembler) Start() {
+	go func() {
+		defer close(r.done)
+		interval := r.window / 4
+		if interval < 10*time.Millisecond {
+			interval = 10 * time.Millisecond
+		}
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		report := time.NewTicker(10 * time.Second)
+		defer report.Stop()
+		for {
+			select {
+			case now := <-tick.C:
+				r.flush(now, false)
+			case <-report.C:
+				r.report()
+			case <-r.exit:
+				r.flush(time.Now(), true)
+				r.report()
+				close(r.Out)
+				return
+			}
+		}
+	}()
+}
+
+// Stop flushes all the traces held and stops the reassembler, closing Out. The traces held are
+// sent to Out, which must be received from until it is closed. Add must not be called anymore.
+func (r *reassembler) Stop() {
+	close(r.exit)
+	<-r.done
+}
+
+// flush sends the traces whose window expired at now to Out, or all the traces if all is set.
+// The traces are grouped by the payload of their first chunk.
+func (r *reassembler) flush(now time.Time, all bool) {
+	r.mu.Lock()
+	n := 0
+	for n < len(r.queue) && (all || !now.Before(r.queue[n].expire)) {
+		n++
+	}
+	expired := r.queue[:n]
+	r.queue = r.queue[n:]
+	for _, t := range expired {
+		delete(r.pending, t.key)
+		r.spans -= len(t.chunk.Spans)
+		r.traces++
+		if t.chunks > 1 {
+			r.reassembled++
+		}
+	}
+	r.mu.Unlock()
+
+	var payloads []*api.Payload
+	chunks := make(map[*api.Payload][]*pb.TraceChunk)
+	for _, t := range expired {
+		if _, ok := chunks[t.payload]; !ok {
+			payloads = append(payloads, t.payload)
+		}
+		chunks[t.payload] = append(chunks[t.payload], t.chunk)
+	}
+	for _, p := range payloads {
+		r.Out <- payloadWithChunks(p, chunks[p])
+	}
+}
+
+// report reports the metrics of the reassembly since the last report.
+func (r *reassembler) report() {
+	r.mu.Lock()
+	traces, reassembled, overflow, spans := r.traces, r.reassembled, r.overflow, r.spans
+	r.traces, r.reassembled, r.overflow = 0, 0, 0
+	r.mu.Unlock()
+
+	metrics.Count("datadog.trace_agent.reassembly.traces", traces, nil, 1)
+	metrics.Count("datadog.trace_agent.reassembly.reassembled", reassembled, nil, 1)
+	metrics.Count("datadog.trace_agent.reassembly.overflow", overflow, nil, 1)
+	metrics.Gauge("datadog.trace_agent.reassembly.pending_spans", float64(spans), nil, 1)
+}
@@ -0,0 +1,113 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/api"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func reassemblyPayload(runtimeID string, traces pb.Traces) *api.Payload {
+	tp := testutil.TracerPayload(traces)
+	tp.RuntimeID = runtimeID
+	return &api.Payload{TracerPayload: tp}
+}
+
+func TestReassembler(t *testing.T) {
+	newTestReassembler := func(maxSpans int) *reassembler {
+		cfg := config.New()
+		cfg.ReassemblyWindow = time.Second
+		cfg.ReassemblyMaxSpans = maxSpans
+		return newReassembler(cfg)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Nil(t, newReassembler(config.New()))
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		assert := assert.New(t)
+		r := newTestReassembler(100)
+
+		p1 := reassemblyPayload("rid", pb.Traces{
+			{{TraceID: 1, SpanID: 1}},
+			{{TraceID: 2, SpanID: 3}},
+		})
+		p1.TracerPayload.Chunks[0].Tags = map[string]string{"a": "1"}
+		p2 := reassemblyPayload("rid", pb.Traces{
+			{{TraceID: 1, SpanID: 2, ParentID: 1}},
+		})
+		p2.TracerPayload.Chunks[0].Tags = map[string]string{"a": "2", "b": "2"}
+		p2.TracerPayload.Chunks[0].Priority = 2
+		// same trace ID, other tracer
+		p3 := reassemblyPayload("other", pb.Traces{
+			{{TraceID: 1, SpanID: 4}},
+		})
+		for _, p := range []*api.Payload{p1, p2, p3} {
+			assert.Nil(r.Add(p))
+		}
+		assert.Equal(4, r.spans)
+
+		// nothing expired yet
+		r.flush(time.Now(), false)
+		assert.Len(r.Out, 0)
+
+		r.flush(time.Now().Add(time.Second), false)
+		assert.Len(r.Out, 2)
+		out := <-r.Out
+		assert.Equal("rid", out.TracerPayload.RuntimeID)
+		assert.Len(out.TracerPayload.Chunks, 2)
+		merged := out.TracerPayload.Chunks[0]
+		assert.Len(merged.Spans, 2)
+		assert.Equal(map[string]string{"a": "1", "b": "2"}, merged.Tags)
+		assert.EqualValues(2, merged.Priority)
+		assert.Len(out.TracerPayload.Chunks[1].Spans, 1)
+		out = <-r.Out
+		assert.Equal("other", out.TracerPayload.RuntimeID)
+		assert.Len(out.TracerPayload.Chunks, 1)
+
+		assert.Empty(r.pending)
+		assert.Empty(r.queue)
+		assert.Equal(0, r.spans)
+		assert.EqualValues(3, r.traces)
+		assert.EqualValues(1, r.reassembled)
+	})
+
+	t.Run("max-spans", func(t *testing.T) {
+		assert := assert.New(t)
+		r := newTestReassembler(2)
+
+		assert.Nil(r.Add(reassemblyPayload("rid", pb.Traces{
+			{{TraceID: 1, SpanID: 1}, {TraceID: 1, SpanID: 2}},
+		})))
+		rest := r.Add(reassemblyPayload("rid", pb.Traces{
+			{{TraceID: 2, SpanID: 3}},
+		}))
+		if assert.NotNil(rest) {
+			assert.Len(rest.TracerPayload.Chunks, 1)
+			assert.EqualValues(2, rest.TracerPayload.Chunks[0].Spans[0].TraceID)
+		}
+		assert.EqualValues(1, r.overflow)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		r := newTestReassembler(100)
+		r.Start()
+		assert.Nil(t, r.Add(reassemblyPayload("rid", pb.Traces{{{TraceID: 1, SpanID: 1}}})))
+		r.Stop()
+		assert.Len(t, r.Out, 1)
+		<-r.Out
+		_, ok := <-r.Out
+		assert.False(t, ok, "Out should be closed once stopped")
+	})
+}
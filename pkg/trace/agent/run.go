@@ -17,9 +17,11 @@ import (
 	coreconfig "github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/pidfile"
 	"github.com/DataDog/datadog-agent/pkg/tagger"
+	"github.com/DataDog/datadog-agent/pkg/trace/api"
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/flags"
 	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/loadgen"
 	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
 	"github.com/DataDog/datadog-agent/pkg/trace/metrics/timing"
 	"github.com/DataDog/datadog-agent/pkg/trace/osutil"
@@ -38,6 +40,11 @@ func Run(ctx context.Context) {
 		return
 	}
 
+	if flags.LoadGen.URL != "" {
+		runLoadGen(ctx)
+		return
+	}
+
 	cfg, err := config.Load(flags.ConfigPath)
 	if err != nil {
 		if err == config.ErrMissingAPIKey {
@@ -103,6 +110,10 @@ func Run(ctx context.Context) {
 	}
 
 	if flags.PIDFilePath != "" {
+		if api.ReceivingHandoff() {
+			// the PID file belongs to the process handing off its listeners to this one
+			os.Remove(flags.PIDFilePath)
+		}
 		err := pidfile.WritePID(flags.PIDFilePath)
 		if err != nil {
 			log.Criticalf("Error writing PID file, exiting: %v", err)
@@ -110,7 +121,12 @@ func Run(ctx context.Context) {
 		}
 
 		log.Infof("PID '%d' written to PID file '%s'", os.Getpid(), flags.PIDFilePath)
-		defer os.Remove(flags.PIDFilePath)
+		defer func() {
+			if !api.HandedOff() {
+				// otherwise, the PID file now belongs to the new process
+				os.Remove(flags.PIDFilePath)
+			}
+		}()
 	}
 
 	err = metrics.Configure(cfg, []string{"version:" + info.Version})
@@ -148,3 +164,25 @@ func Run(ctx context.Context) {
 		f.Close()
 	}
 }
+
+// runLoadGen runs the synthetic load generator configured by the command line flags.
+func runLoadGen(ctx context.Context) {
+	cfg := &loadgen.Config{
+		URL:              flags.LoadGen.URL,
+		Rate:             flags.LoadGen.Rate,
+		Duration:         flags.LoadGen.Duration,
+		Concurrency:      flags.LoadGen.Concurrency,
+		TracesPerPayload: flags.LoadGen.TracesPerPayload,
+		Services:         flags.LoadGen.Services,
+		MaxDepth:         flags.LoadGen.MaxDepth,
+		ErrorRate:        flags.LoadGen.ErrorRate,
+		MetaSize:         flags.LoadGen.MetaSize,
+		PID:              int32(flags.LoadGen.PID),
+	}
+	fmt.Printf("Sending %.1f traces/s to %s for %s...\n", cfg.Rate, cfg.URL, cfg.Duration)
+	report, err := loadgen.Run(ctx, cfg)
+	if err != nil {
+		osutil.Exitf("Load generation failed: %v", err)
+	}
+	report.WriteTo(os.Stdout) //nolint:errcheck
+}
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package agent
+
+import (
+	"github.com/google/uuid"
+)
+
+// tagRuntimeID specifies the name of the tag which holds the ID of the tracer session a
+// trace comes from. It is used to correlate traces with runtime metrics.
+const tagRuntimeID = "runtime-id"
+
+// containerRuntimeIDSpace is the namespace of the runtime IDs derived from container IDs.
+var containerRuntimeIDSpace = uuid.MustParse("2b7e1a38-5c4f-4d3e-9a57-0c6a3b1f8e21")
+
+// normalizeRuntimeID returns the canonical form of a runtime ID (lowercase UUID with dashes),
+// or an empty string if it is not a valid UUID.
+func normalizeRuntimeID(id string) string {
+	if id == "" {
+		return ""
+	}
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+// containerRuntimeID returns a runtime ID derived from a container ID, which is the same for
+// all the payloads of the container, including across agent restarts.
+func containerRuntimeID(containerID string) string {
+	return uuid.NewSHA1(containerRuntimeIDSpace, []byte(containerID)).String()
+}
+
+// payloadRuntimeID returns the normalized runtime ID of a payload. When it is missing, a runtime
+// ID derived from the container ID is returned if inject is set.
+func payloadRuntimeID(runtimeID, containerID string, inject bool) string {
+	if id := normalizeRuntimeID(runtimeID); id != "" {
+		return id
+	}
+	if inject && containerID != "" {
+		return containerRuntimeID(containerID)
+	}
+	return ""
+}
@@ -8,6 +8,7 @@ package agent
 import (
 	"fmt"
 	"reflect"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -35,29 +36,95 @@ type Sampler struct {
 // NewScoreSampler creates a new empty sampler ready to be started
 func NewScoreSampler(conf *config.AgentConfig) *Sampler {
 	return &Sampler{
-		engine: sampler.NewScoreEngine(conf.ExtraSampleRate, conf.MaxTPS),
+		engine: sampler.NewScoreEngine(conf.ExtraSampleRate, conf.MaxTPS, samplingRules(conf)),
 		exit:   make(chan struct{}),
 	}
 }
 
 // NewErrorsSampler creates a new sampler dedicated to traces containing errors
 // to isolate them from the global max tps. It behaves exactly like the normal
-// ScoreSampler except that its statistics are reported under a different name.
+// ScoreSampler except that its statistics are reported under a different name,
+// and that it can limit the traces sampled for each service.
 func NewErrorsSampler(conf *config.AgentConfig) *Sampler {
 	return &Sampler{
-		engine: sampler.NewErrorsEngine(conf.ExtraSampleRate, conf.MaxTPS),
+		engine: sampler.NewErrorsEngine(conf.ExtraSampleRate, conf.MaxTPS, conf.MaxErrorTPSPerService),
 		exit:   make(chan struct{}),
 	}
 }
 
 // NewPrioritySampler creates a new empty distributed sampler ready to be started
 func NewPrioritySampler(conf *config.AgentConfig, dynConf *sampler.DynamicConfig) *Sampler {
+	e := sampler.NewPriorityEngine(conf.ExtraSampleRate, conf.MaxTPS, &dynConf.RateByService, samplingRules(conf))
+	if conf.PrioritySamplingByResource {
+		e.SetRatesByResource()
+	}
+	return &Sampler{
+		engine: e,
+		exit:   make(chan struct{}),
+	}
+}
+
+// setTargetTPS makes the priority sampler adjust the rates sent back to the tracers to keep
+// targetTPS traces per second, lowered under pressure by the rate returned by pressure.
+func (s *Sampler) setTargetTPS(targetTPS float64, pressure func() float64) {
+	if e, ok := s.engine.(*sampler.PriorityEngine); ok {
+		e.SetTargetTPS(targetTPS, pressure)
+	}
+}
+
+// NewHashSampler creates a sampler keeping the traces from a hash of their trace ID, at the
+// fixed rate of the configuration.
+func NewHashSampler(conf *config.AgentConfig) *Sampler {
 	return &Sampler{
-		engine: sampler.NewPriorityEngine(conf.ExtraSampleRate, conf.MaxTPS, &dynConf.RateByService),
+		engine: sampler.NewHashEngine(conf.TraceIDHashSampleRate),
 		exit:   make(chan struct{}),
 	}
 }
 
+// samplingRules returns the sampling rules of the configuration, in the form of the engines.
+func samplingRules(conf *config.AgentConfig) []sampler.Rule {
+	if len(conf.SamplingRules) == 0 {
+		return nil
+	}
+	rules := make([]sampler.Rule, 0, len(conf.SamplingRules))
+	for _, r := range conf.SamplingRules {
+		rules = append(rules, sampler.Rule{
+			Service:  r.Service,
+			Name:     r.Name,
+			Resource: r.ResourceRe,
+			Rate:     r.SampleRate,
+		})
+	}
+	return rules
+}
+
+// newExceptionSampler returns the exception sampler of the configuration, or nil if it is disabled.
+func newExceptionSampler(conf *config.AgentConfig) *sampler.ExceptionSampler {
+	if !conf.ExceptionSamplerEnabled {
+		return nil
+	}
+	return sampler.NewExceptionSampler(sampler.ExceptionSamplerConfig{
+		TTL:              conf.ExceptionSamplerTTL,
+		PriorityTTL:      conf.ExceptionSamplerPriorityTTL,
+		CardinalityLimit: conf.ExceptionSamplerCardinalityLimit,
+		Signature:        conf.ExceptionSamplerSignature,
+	})
+}
+
+// newSpanSampler returns the single-span sampler applying the span sampling rules of the configuration.
+func newSpanSampler(conf *config.AgentConfig) *sampler.SpanSampler {
+	rules := make([]sampler.SpanRule, 0, len(conf.SpanSamplingRules))
+	for _, r := range conf.SpanSamplingRules {
+		rules = append(rules, sampler.SpanRule{
+			Service:      r.Service,
+			Name:         r.Name,
+			Rate:         r.SampleRate,
+			MaxPerSecond: r.MaxPerSecond,
+		})
+	}
+	return sampler.NewSpanSampler(rules)
+}
+
 // Start starts sampling traces
 func (s *Sampler) Start() {
 	go func() {
@@ -81,6 +148,48 @@ func (s *Sampler) Add(t ProcessedTrace) (sampled bool, rate float64) {
 	return sampled, rate
 }
 
+// Dump returns the detailed state of the engine of the sampler.
+func (s *Sampler) Dump() sampler.EngineDump {
+	return sampler.DumpEngine(s.engine)
+}
+
+// samplersDumpTTL is the duration a dump of the samplers is served before being recomputed,
+// so that polling the samplers expvar does not copy and sort all their signatures each time.
+const samplersDumpTTL = 10 * time.Second
+
+// samplersDumpCache holds the last dump of the samplers of the agent.
+type samplersDumpCache struct {
+	mu    sync.Mutex
+	time  time.Time
+	dumps map[string]sampler.EngineDump
+}
+
+// dumpSamplers returns the detailed state of the samplers of the agent by engine type. It is
+// computed at most once every samplersDumpTTL.
+func (a *Agent) dumpSamplers() interface{} {
+	c := &a.samplersDump
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); c.dumps == nil || now.Sub(c.time) >= samplersDumpTTL {
+		c.dumps = a.computeSamplersDump()
+		c.time = now
+	}
+	return c.dumps
+}
+
+// computeSamplersDump returns the detailed state of the samplers of the agent by engine type.
+func (a *Agent) computeSamplersDump() map[string]sampler.EngineDump {
+	dumps := make(map[string]sampler.EngineDump)
+	for _, s := range []*Sampler{a.ScoreSampler, a.ErrorsScoreSampler, a.PrioritySampler, a.HashSampler} {
+		if s == nil {
+			continue
+		}
+		d := s.Dump()
+		dumps[d.Type] = d
+	}
+	return dumps
+}
+
 // Stop stops the sampler
 func (s *Sampler) Stop() {
 	s.exit <- struct{}{}
@@ -132,6 +241,8 @@ func (s *Sampler) logStats() {
 				case sampler.PriorityEngineType:
 					info.UpdatePrioritySamplerInfo(info.SamplerInfo{Stats: stats, State: state})
 				}
+			case sampler.HashState:
+				log.Tracef("%s: rate: %f", engineType, state.Rate)
 			default:
 				log.Debugf("unhandled sampler engine, can't log state")
 			}
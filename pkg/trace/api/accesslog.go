@@ -0,0 +1,231 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// accessLogEntry is a line of the access log.
+type accessLogEntry struct {
+	Time          time.Time `json:"time"`
+	Endpoint      string    `json:"endpoint"`
+	Lang          string    `json:"lang,omitempty"`
+	LangVersion   string    `json:"lang_version,omitempty"`
+	TracerVersion string    `json:"tracer_version,omitempty"`
+	Bytes         int64     `json:"bytes"`
+	Status        int       `json:"status"`
+	DurationMs    float64   `json:"duration_ms"`
+}
+
+// accessLogger writes a sample of the requests received to an io.Writer, as JSON lines.
+type accessLogger struct {
+	rate float64
+
+	mu  sync.Mutex // guards out
+	out io.Writer
+}
+
+// newAccessLogger returns an accessLogger writing a proportion rate of the requests to out.
+func newAccessLogger(out io.Writer, rate float64) *accessLogger {
+	return &accessLogger{out: out, rate: rate}
+}
+
+// openAccessLog returns an accessLogger writing to the file at path, which is created if needed
+// and rotated once larger than maxSize bytes.
+func openAccessLog(path string, maxSize int64, rate float64) (*accessLogger, error) {
+	f, err := openRotatingFile(path, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	return newAccessLogger(f, rate), nil
+}
+
+// rotatingFile is an io.WriteCloser appending to a file which is renamed with the ".1" suffix,
+// replacing the previous one, before it grows larger than maxSize bytes. It is not safe for
+// concurrent use.
+type rotatingFile struct {
+	path    string
+	maxSize int64
+
+	f    *os.File
+	size int64
+}
+
+// openRotatingFile opens the file at path for appending, creating it if needed.
+func openRotatingFile(path string, maxSize int64) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+// Write implements io.Writer.
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	if r.f == nil {
+		// the file could not be reopened after the last rotation
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.size > 0 && r.size+int64(len(b)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate replaces the previous file with the current one, and starts a new one.
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// Close implements io.Closer.
+func (r *rotatingFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	return r.f.Close()
+}
+
+// sampled reports whether the next request should be logged.
+func (l *accessLogger) sampled() bool {
+	return l.rate >= 1 || (l.rate > 0 && rand.Float64() < l.rate)
+}
+
+// log writes e to the access log.
+func (l *accessLogger) log(e *accessLogEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.out.Write(b); err != nil {
+		log.Debugf("Error writing to the access log: %v", err)
+	}
+}
+
+// Close closes the underlying writer, if it can be closed.
+func (l *accessLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.out.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// countingReader counts the bytes read from an io.ReadCloser.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read implements io.Reader.
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// statusRecorder records the status code written to an http.ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, for the handlers streaming their responses.
+func (w *statusRecorder) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// accessLogHandler wraps h, logging a sample of the requests it serves to l. If l is nil,
+// h is returned unchanged.
+func accessLogHandler(l *accessLogger, h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !l.sampled() {
+			h.ServeHTTP(w, req)
+			return
+		}
+		start := time.Now()
+		body := &countingReader{ReadCloser: req.Body}
+		req.Body = body
+		rec := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(rec, req)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		l.log(&accessLogEntry{
+			Time:          start,
+			Endpoint:      req.URL.Path,
+			Lang:          req.Header.Get(headerLang),
+			LangVersion:   req.Header.Get(headerLangVersion),
+			TracerVersion: req.Header.Get(headerTracerVersion),
+			Bytes:         body.n,
+			Status:        rec.status,
+			DurationMs:    float64(time.Since(start)) / float64(time.Millisecond),
+		})
+	})
+}
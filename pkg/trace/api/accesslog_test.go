@@ -0,0 +1,124 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLogHandler(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ioutil.ReadAll(req.Body)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("logged", func(t *testing.T) {
+		var buf bytes.Buffer
+		req := httptest.NewRequest("POST", "/v0.4/traces", strings.NewReader("0123456789"))
+		req.Header.Set(headerLang, "go")
+		req.Header.Set(headerLangVersion, "1.15")
+		req.Header.Set(headerTracerVersion, "1.27.0")
+		accessLogHandler(newAccessLogger(&buf, 1), h).ServeHTTP(httptest.NewRecorder(), req)
+
+		var entry accessLogEntry
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, "/v0.4/traces", entry.Endpoint)
+		assert.Equal(t, "go", entry.Lang)
+		assert.Equal(t, "1.15", entry.LangVersion)
+		assert.Equal(t, "1.27.0", entry.TracerVersion)
+		assert.EqualValues(t, 10, entry.Bytes)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, entry.Status)
+		assert.True(t, entry.DurationMs >= 0)
+		assert.True(t, strings.HasSuffix(buf.String(), "}\n"))
+	})
+
+	t.Run("implicit-status", func(t *testing.T) {
+		var buf bytes.Buffer
+		req := httptest.NewRequest("GET", "/info", nil)
+		ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte("{}"))
+		})
+		accessLogHandler(newAccessLogger(&buf, 1), ok).ServeHTTP(httptest.NewRecorder(), req)
+
+		var entry accessLogEntry
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, http.StatusOK, entry.Status)
+	})
+
+	t.Run("flusher", func(t *testing.T) {
+		var buf bytes.Buffer
+		req := httptest.NewRequest("GET", "/debug/stream", nil)
+		streaming := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			f, ok := w.(http.Flusher)
+			assert.True(t, ok)
+			w.Write([]byte("{}"))
+			f.Flush()
+		})
+		rec := httptest.NewRecorder()
+		accessLogHandler(newAccessLogger(&buf, 1), streaming).ServeHTTP(rec, req)
+		assert.True(t, rec.Flushed)
+
+		var entry accessLogEntry
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, http.StatusOK, entry.Status)
+	})
+
+	t.Run("sampled-out", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := newAccessLogger(&buf, 0)
+		for i := 0; i < 100; i++ {
+			req := httptest.NewRequest("POST", "/v0.4/traces", strings.NewReader("{}"))
+			accessLogHandler(l, h).ServeHTTP(httptest.NewRecorder(), req)
+		}
+		assert.Zero(t, buf.Len())
+	})
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+
+	f, err := openRotatingFile(path, 10)
+	assert.NoError(t, err)
+	for _, line := range []string{"abcd\n", "efgh\n", "ijkl\n", "mnop\n"} {
+		_, err := f.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, f.Close())
+
+	current, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "ijkl\nmnop\n", string(current))
+	previous, err := ioutil.ReadFile(path + ".1")
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd\nefgh\n", string(previous))
+
+	// the size of an existing file is accounted for
+	f, err = openRotatingFile(path, 10)
+	assert.NoError(t, err)
+	_, err = f.Write([]byte("qrst\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	current, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "qrst\n", string(current))
+	previous, err = ioutil.ReadFile(path + ".1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ijkl\nmnop\n", string(previous))
+}
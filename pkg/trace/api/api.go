@@ -8,6 +8,7 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"expvar"
 	"fmt"
@@ -28,6 +29,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/tinylib/msgp/msgp"
+	"google.golang.org/grpc"
+
 	mainconfig "github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/tagger"
 	"github.com/DataDog/datadog-agent/pkg/tagger/collectors"
@@ -42,20 +46,24 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
-var bufferPool = sync.Pool{
+// tracesPool holds the containers into which the payloads are decoded. Only the containers
+// are reused: the traces they held are retained by the payloads sent to the agent.
+var tracesPool = sync.Pool{
 	New: func() interface{} {
-		return new(bytes.Buffer)
+		return new(pb.Traces)
 	},
 }
 
-func getBuffer() *bytes.Buffer {
-	buffer := bufferPool.Get().(*bytes.Buffer)
-	buffer.Reset()
-	return buffer
+func getTraces() *pb.Traces {
+	return tracesPool.Get().(*pb.Traces)
 }
 
-func putBuffer(buffer *bytes.Buffer) {
-	bufferPool.Put(buffer)
+func putTraces(traces *pb.Traces) {
+	for i := range *traces {
+		(*traces)[i] = nil
+	}
+	*traces = (*traces)[:0]
+	tracesPool.Put(traces)
 }
 
 // HTTPReceiver is a collector that uses HTTP protocol and just holds
@@ -64,13 +72,33 @@ type HTTPReceiver struct {
 	Stats       *info.ReceiverStats
 	RateLimiter *rateLimiter
 
+	accStats      *info.ReceiverStats // stats accumulated since the last log, served on /debug/receiver
+	accStatsSince int64               // unix time in ns at which accStats was last reset, atomically accessed
+
 	out     chan *Payload
 	conf    *config.AgentConfig
 	dynConf *sampler.DynamicConfig
 	server  *http.Server
 
 	debug               bool
-	rateLimiterResponse int // HTTP status code when refusing
+	rateLimiterResponse int           // HTTP status code when refusing
+	accessLog           *accessLogger // nil if disabled
+	tracerVersionLogger *logutil.ThrottledLogger
+	tlsConfig           *tls.Config  // nil if TLS is disabled
+	connLimiter         *connLimiter // sheds the connections past apm_config.receiver_max_connections
+	addressFamily       string       // IP address family of the TCP listener: "ipv4", "ipv6" or "dual"
+	transformers        []PayloadTransformer
+	remoteConfig        *remoteConfigServer     // nil if remote configuration is disabled
+	debugger            *debuggerForwarder      // nil if the debugger intake URL is invalid
+	otlpPipelines       map[string]otlpPipeline // pipelines of the OTLP signals other than traces, by signal
+
+	listenersMu sync.Mutex
+	listeners   []net.Listener // listeners handed off to a replacement process on SIGUSR2
+	handoffExit chan struct{}
+
+	xrayConn   net.PacketConn // nil if the X-Ray UDP listener is disabled
+	jaegerConn net.PacketConn // nil if the Jaeger UDP listener is disabled
+	grpcServer *grpc.Server   // nil if the gRPC intake is disabled
 
 	wg   sync.WaitGroup // waits for all requests to be processed
 	exit chan struct{}
@@ -87,13 +115,20 @@ func NewHTTPReceiver(conf *config.AgentConfig, dynConf *sampler.DynamicConfig, o
 		RateLimiter: newRateLimiter(),
 		out:         out,
 
+		accStats:      info.NewReceiverStats(),
+		accStatsSince: time.Now().UnixNano(),
+
 		conf:    conf,
 		dynConf: dynConf,
 
 		debug:               strings.ToLower(conf.LogLevel) == "debug",
 		rateLimiterResponse: rateLimiterResponse,
+		tracerVersionLogger: logutil.NewThrottled(5, 10*time.Second), // limit to 5 messages every 10 seconds
+		connLimiter:         newConnLimiter(conf.ReceiverMaxConnections),
+		otlpPipelines:       otlpPipelinesFromConfig(conf),
 
-		exit: make(chan struct{}),
+		handoffExit: make(chan struct{}),
+		exit:        make(chan struct{}),
 	}
 }
 
@@ -114,31 +149,85 @@ func (r *HTTPReceiver) Start() {
 	mux.HandleFunc("/v0.4/traces", r.handleWithVersion(v04, r.handleTraces))
 	mux.HandleFunc("/v0.4/services", r.handleWithVersion(v04, r.handleServices))
 	mux.HandleFunc("/v0.5/traces", r.handleWithVersion(v05, r.handleTraces))
+	mux.HandleFunc("/v0.7/traces", r.handleWithVersion(v07, r.handleTraces))
+	mux.Handle("/v0.7/config", r.remoteConfigHandler())
 	mux.Handle("/profiling/v1/input", r.profileProxyHandler())
+	mux.Handle(evpProxyPath+"/", r.evpProxyHandler())
+	mux.Handle(debuggerPath, r.debuggerHandler())
+	mux.HandleFunc("/xray/v1/segments", r.handleXRaySegments)
+	mux.HandleFunc("/v1/traces", r.handleOTLPTraces)
+	mux.HandleFunc(otlpLogs.path, r.handleOTLPSignal(otlpLogs))
+	mux.HandleFunc(otlpMetrics.path, r.handleOTLPSignal(otlpMetrics))
+	mux.HandleFunc("/zipkin/api/v2/spans", r.handleZipkinSpans)
+	mux.HandleFunc("/api/traces", r.handleJaegerTraces)
 
 	timeout := 5 * time.Second
 	if r.conf.ReceiverTimeout > 0 {
 		timeout = time.Duration(r.conf.ReceiverTimeout) * time.Second
 	}
+	readTimeout, writeTimeout := timeout, timeout
+	if r.conf.ReceiverReadTimeout > 0 {
+		readTimeout = r.conf.ReceiverReadTimeout
+	}
+	if r.conf.ReceiverWriteTimeout > 0 {
+		writeTimeout = r.conf.ReceiverWriteTimeout
+	}
+	if path := r.conf.AccessLogPath; path != "" {
+		l, err := openAccessLog(path, r.conf.AccessLogMaxSize, r.conf.AccessLogSampleRate)
+		if err != nil {
+			log.Errorf("Error opening access log %q, access log disabled: %v", path, err)
+		} else {
+			r.accessLog = l
+			log.Infof("Writing access log to %s (sample rate: %.2f)", path, r.conf.AccessLogSampleRate)
+		}
+	}
 	httpLogger := logutil.NewThrottled(5, 10*time.Second) // limit to 5 messages every 10 seconds
 	r.server = &http.Server{
-		ReadTimeout:  timeout,
-		WriteTimeout: timeout,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  r.conf.ReceiverIdleTimeout,
 		ErrorLog:     stdlog.New(httpLogger, "http.Server: ", 0),
-		Handler:      mux,
+		Handler:      accessLogHandler(r.accessLog, r.connLimiter.handler(hardenedHandler(authHandler(r.conf.ReceiverAuthToken, mux)))),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return r.connLimiter.connContext(connContext(ctx, c), c)
+		},
+		ConnState: r.connLimiter.connState,
 	}
+	if r.conf.ReceiverHardened {
+		r.server.MaxHeaderBytes = r.conf.ReceiverMaxHeaderBytes
+	}
+	if r.conf.ReceiverDisableKeepAlives {
+		r.server.SetKeepAlivesEnabled(false)
+	}
+
+	tlsConfig, err := newTLSConfig(r.conf)
+	if err != nil {
+		killProcess("Error setting up TLS: %v", err)
+	}
+	r.tlsConfig = tlsConfig
 
-	addr := fmt.Sprintf("%s:%d", r.conf.ReceiverHost, r.conf.ReceiverPort)
+	addr := net.JoinHostPort(r.conf.ReceiverHost, strconv.Itoa(r.conf.ReceiverPort))
 	ln, err := r.listenTCP(addr)
 	if err != nil {
 		killProcess("Error creating tcp listener: %v", err)
 	}
+	r.addressFamily = addressFamily(ln.Addr(), r.conf.AddressFamily)
+	scheme := "http"
+	if r.tlsConfig != nil {
+		// the hardened listener, if any, has to see the decrypted requests
+		ln = tls.NewListener(ln, r.tlsConfig)
+		scheme = "https"
+	}
+	if r.conf.ReceiverHardened {
+		ln = newHardenedListener(ln, r.conf.ReceiverMaxHeaderBytes)
+		log.Infof("Hardened mode enabled for %s://%s", scheme, addr)
+	}
 	go func() {
 		defer watchdog.LogOnPanic()
 		r.server.Serve(ln)
 		ln.Close()
 	}()
-	log.Infof("Listening for traces at http://%s", addr)
+	log.Infof("Listening for traces at %s://%s (%s)", scheme, addr, r.addressFamily)
 
 	if path := r.conf.ReceiverSocket; path != "" {
 		ln, err := r.listenUnix(path)
@@ -157,10 +246,12 @@ func (r *HTTPReceiver) Start() {
 		pipepath := `\\.\pipe\` + path
 		bufferSize := mainconfig.Datadog.GetInt("apm_config.windows_pipe_buffer_size")
 		secdec := mainconfig.Datadog.GetString("apm_config.windows_pipe_security_descriptor")
-		ln, err := listenPipe(pipepath, secdec, bufferSize)
+		maxConn := mainconfig.Datadog.GetInt("apm_config.windows_pipe_max_connections")
+		pln, err := listenPipe(pipepath, secdec, bufferSize)
 		if err != nil {
 			killProcess("Error creating %q named pipe: %v", pipepath, err)
 		}
+		ln := newMeasuredListener(pln, path, maxConn)
 		go func() {
 			defer watchdog.LogOnPanic()
 			r.server.Serve(ln)
@@ -169,6 +260,47 @@ func (r *HTTPReceiver) Start() {
 		log.Infof("Listening for traces on Windowes pipe %q. Security descriptor is %q", pipepath, secdec)
 	}
 
+	if port := r.conf.XRayUDPPort; port > 0 {
+		host, restricted := udpListenHost(r.conf.ReceiverHost, r.conf.ReceiverAuthToken)
+		if restricted {
+			log.Warnf("X-Ray segments received over UDP can't be authenticated with apm_config.receiver_auth_token, only listening for them on %s", host)
+		}
+		addr := net.JoinHostPort(host, strconv.Itoa(port))
+		if err := r.listenXRayUDP(addr); err != nil {
+			killProcess("Error creating X-Ray UDP listener: %v", err)
+		}
+		log.Infof("Listening for X-Ray segments at udp://%s", addr)
+	}
+
+	if port := r.conf.JaegerUDPPort; port > 0 {
+		host, restricted := udpListenHost(r.conf.ReceiverHost, r.conf.ReceiverAuthToken)
+		if restricted {
+			log.Warnf("Jaeger batches received over UDP can't be authenticated with apm_config.receiver_auth_token, only listening for them on %s", host)
+		}
+		addr := net.JoinHostPort(host, strconv.Itoa(port))
+		if err := r.listenJaegerUDP(addr); err != nil {
+			killProcess("Error creating Jaeger UDP listener: %v", err)
+		}
+		log.Infof("Listening for Jaeger batches at udp://%s", addr)
+	}
+
+	if port := r.conf.ReceiverGRPCPort; port > 0 {
+		addr := net.JoinHostPort(r.conf.ReceiverHost, strconv.Itoa(port))
+		if err := r.listenGRPC(addr); err != nil {
+			killProcess("Error creating gRPC listener: %v", err)
+		}
+		log.Infof("Listening for traces over gRPC at %s", addr)
+	}
+
+	// all listeners are set up, a process which handed off its listeners to this one can exit
+	notifyHandoffReady()
+	if r.conf.ReceiverHandoff {
+		go func() {
+			defer watchdog.LogOnPanic()
+			r.handoffOnSignal()
+		}()
+	}
+
 	go r.RateLimiter.Run()
 
 	go func() {
@@ -207,6 +339,10 @@ func (r *HTTPReceiver) attachDebugHandlers(mux *http.ServeMux) {
 		runtime.SetBlockProfileRate(0)
 	})
 
+	// these endpoints expose the names of the services and resources of the tracers
+	mux.Handle("/debug/receiver", debugAuthHandler(r.conf.ReceiverAuthToken, http.HandlerFunc(r.handleReceiverStats)))
+	mux.Handle("/debug/samplers", debugAuthHandler(r.conf.ReceiverAuthToken, http.HandlerFunc(r.handleSamplers)))
+
 	mux.Handle("/debug/vars", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		// allow the GUI to call this endpoint so that the status can be reported
 		w.Header().Set("Access-Control-Allow-Origin", "http://127.0.0.1:"+mainconfig.Datadog.GetString("GUI_port"))
@@ -214,8 +350,77 @@ func (r *HTTPReceiver) attachDebugHandlers(mux *http.ServeMux) {
 	}))
 }
 
+// receiverStatsResponse is the response of the /debug/receiver endpoint.
+type receiverStatsResponse struct {
+	// Since is the time from which the stats are accumulated.
+	Since time.Time `json:"since"`
+	// Stats holds the stats of each set of tracer tags, ordered by decreasing amount of
+	// bytes received.
+	Stats []info.TagStatsSummary `json:"stats"`
+}
+
+// handleReceiverStats serves as JSON the stats of the payloads received by language, tracer
+// and endpoint since the last time they were logged, including the current flush period. This
+// helps figuring out which tracers are flooding the agent. The "lang" and "endpoint" query
+// parameters optionally filter the stats by language and endpoint version (e.g. "v0.4").
+func (r *HTTPReceiver) handleReceiverStats(w http.ResponseWriter, req *http.Request) {
+	lang := req.URL.Query().Get("lang")
+	endpoint := req.URL.Query().Get("endpoint")
+
+	// r.Stats is merged into a copy of r.accStats so that the stats received since the last
+	// flush are included without altering any of them.
+	stats := info.NewReceiverStats()
+	stats.Acc(r.accStats)
+	stats.Acc(r.Stats)
+
+	resp := receiverStatsResponse{
+		Since: time.Unix(0, atomic.LoadInt64(&r.accStatsSince)).UTC(),
+		Stats: []info.TagStatsSummary{},
+	}
+	for _, ts := range stats.Summary() {
+		if lang != "" && ts.Lang != lang {
+			continue
+		}
+		if endpoint != "" && ts.EndpointVersion != endpoint {
+			continue
+		}
+		resp.Stats = append(resp.Stats, ts)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorf("Error encoding the receiver stats: %v", err)
+	}
+}
+
+// handleSamplers serves as JSON the detailed state of the samplers of the agent: their
+// counters, the recent throughput and rate of each signature and the rates by service sent
+// back to the tracers. This helps figuring out why a given trace was kept or not.
+func (r *HTTPReceiver) handleSamplers(w http.ResponseWriter, req *http.Request) {
+	dump := info.SamplersDump()
+	if dump == nil {
+		http.Error(w, "samplers are not running", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(dump); err != nil {
+		log.Errorf("Error encoding the samplers state: %v", err)
+	}
+}
+
+// addListener records a listener to be handed off to a replacement process.
+func (r *HTTPReceiver) addListener(ln net.Listener) {
+	r.listenersMu.Lock()
+	r.listeners = append(r.listeners, ln)
+	r.listenersMu.Unlock()
+}
+
 // listenUnix returns a net.Listener listening on the given "unix" socket path.
 func (r *HTTPReceiver) listenUnix(path string) (net.Listener, error) {
+	if ln := inheritedListener("unix", path); ln != nil {
+		log.Infof("Using inherited listener for unix://%s", path)
+		r.addListener(ln)
+		return ln, nil
+	}
 	fi, err := os.Stat(path)
 	if err == nil {
 		// already exists
@@ -233,15 +438,27 @@ func (r *HTTPReceiver) listenUnix(path string) (net.Listener, error) {
 	if err := os.Chmod(path, 0722); err != nil {
 		return nil, fmt.Errorf("error setting socket permissions: %v", err)
 	}
+	r.addListener(ln)
 	return ln, err
 }
 
-// listenTCP creates a new net.Listener on the provided TCP address.
+// listenTCP creates a new net.Listener on the provided TCP address, or uses the
+// inherited one if any.
 func (r *HTTPReceiver) listenTCP(addr string) (net.Listener, error) {
-	tcpln, err := net.Listen("tcp", addr)
-	if err != nil {
-		return nil, err
+	tcpln := inheritedListener("tcp", addr)
+	if tcpln != nil {
+		log.Infof("Using inherited listener for http://%s", addr)
+	} else {
+		var lc net.ListenConfig
+		if r.conf.ReceiverReusePort {
+			lc.Control = reusePortControl
+		}
+		var err error
+		if tcpln, err = lc.Listen(context.Background(), r.conf.Network("tcp"), addr); err != nil {
+			return nil, err
+		}
 	}
+	r.addListener(tcpln)
 	if climit := r.conf.ConnectionLimit; climit > 0 {
 		ln, err := newRateLimitedListener(tcpln, climit)
 		go func() {
@@ -250,23 +467,72 @@ func (r *HTTPReceiver) listenTCP(addr string) (net.Listener, error) {
 		}()
 		return ln, err
 	}
-	return tcpln, err
+	return tcpln, nil
+}
+
+// addressFamily returns the IP address family a listener bound to addr accepts connections
+// from: "ipv4", "ipv6", or "dual" for the unspecified address when the configured family
+// doesn't restrict it. It returns an empty string if addr is not an IP address.
+func addressFamily(addr net.Addr, family string) string {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		return ""
+	}
+	switch {
+	case family != "":
+		return family
+	case ip.IsUnspecified():
+		return "dual"
+	case ip.To4() != nil:
+		return "ipv4"
+	default:
+		return "ipv6"
+	}
 }
 
 // Stop stops the receiver and shuts down the HTTP server.
 func (r *HTTPReceiver) Stop() error {
+	close(r.handoffExit)
 	r.exit <- struct{}{}
 	<-r.exit
 
 	r.RateLimiter.Stop()
 
+	if r.xrayConn != nil {
+		r.xrayConn.Close()
+	}
+	if r.jaegerConn != nil {
+		r.jaegerConn.Close()
+	}
+	if r.grpcServer != nil {
+		// the gRPC streams are persistent, they are closed so that tracers reconnect to
+		// the next listener
+		r.grpcServer.Stop()
+	}
+
 	expiry := time.Now().Add(5 * time.Second) // give it 5 seconds
 	ctx, cancel := context.WithDeadline(context.Background(), expiry)
 	defer cancel()
+	// Shutdown closes the idle keep-alive connections and replies to in-flight requests
+	// with "Connection: close", so that tracers reconnect to the next listener.
 	if err := r.server.Shutdown(ctx); err != nil {
 		return err
 	}
+	if r.accessLog != nil {
+		r.accessLog.Close()
+	}
 	r.wg.Wait()
+	if r.debugger != nil {
+		r.debugger.stop()
+	}
+	if p, ok := r.otlpPipelines[otlpLogs.name].(*otlpLogsPipeline); ok {
+		p.Close()
+	}
 	close(r.out)
 	return nil
 }
@@ -310,6 +576,10 @@ const (
 	// container where the request originated.
 	headerContainerID = "Datadog-Container-ID"
 
+	// headerRuntimeID specifies the name of the header which contains the runtime ID
+	// (tracer session) of the tracer which sent the payload.
+	headerRuntimeID = "Datadog-Runtime-ID"
+
 	// headerLang specifies the name of the header which contains the language from
 	// which the traces originate.
 	headerLang = "Datadog-Meta-Lang"
@@ -333,6 +603,22 @@ const (
 	// headerComputedTopLevel specifies that the client has marked top-level spans, when set.
 	// Any non-empty value will mean 'yes'.
 	headerComputedTopLevel = "Datadog-Client-Computed-Top-Level"
+
+	// headerComputedStats specifies that the client has computed the stats of its traces,
+	// including the ones it dropped, when set. Any non-empty value will mean 'yes'.
+	headerComputedStats = "Datadog-Client-Computed-Stats"
+
+	// headerRateByResource specifies that the tracer supports the sampling rates keyed by
+	// resource, which are then sent in its responses. Any non-empty value will mean 'yes'.
+	headerRateByResource = "Datadog-Rate-By-Resource"
+
+	// headerAPIKeyAlias specifies the alias of the API key, as configured in
+	// 'apm_config.api_key_aliases', which the traces of the payload are written with.
+	headerAPIKeyAlias = "Datadog-API-Key-Alias"
+
+	// headerAPIKey specifies the API key which the traces of the payload are written with,
+	// when the agent accepts the API keys of the tracers.
+	headerAPIKey = "DD-API-KEY"
 )
 
 func (r *HTTPReceiver) tagStats(v Version, req *http.Request) *info.TagStats {
@@ -343,43 +629,71 @@ func (r *HTTPReceiver) tagStats(v Version, req *http.Request) *info.TagStats {
 		LangVendor:      req.Header.Get(headerLangInterpreterVendor),
 		TracerVersion:   req.Header.Get(headerTracerVersion),
 		EndpointVersion: string(v),
+		Transport:       requestTransport(req),
 	})
 }
 
-func decodeTraces(v Version, req *http.Request) (pb.Traces, error) {
+// decodeTraces decodes the traces of req into a pooled container, which should be released
+// using putTraces once the traces are sent to the agent.
+func decodeTraces(v Version, req *http.Request) (*pb.Traces, error) {
+	traces := getTraces()
+	var err error
 	switch v {
 	case v01:
 		var spans []pb.Span
-		if err := json.NewDecoder(req.Body).Decode(&spans); err != nil {
-			return nil, err
+		if err = json.NewDecoder(req.Body).Decode(&spans); err == nil {
+			*traces = tracesFromSpans(spans)
 		}
-		return tracesFromSpans(spans), nil
 	case v05:
-		var traces pb.Traces
-		rd := pb.NewMsgpReader(req.Body)
-		err := traces.DecodeMsgDictionary(rd)
-		pb.FreeMsgpReader(rd)
-		return traces, err
-	default:
-		var traces pb.Traces
-		if err := decodeRequest(req, &traces); err != nil {
-			return nil, err
+		// The payload buffer is purposely not pooled: the decoded spans reference
+		// its memory directly and it is released once they are no longer in use.
+		buf := bytes.NewBuffer(make([]byte, 0, arenaSize(req)))
+		if _, err = io.Copy(buf, req.Body); err == nil {
+			err = traces.UnmarshalMsgDictionary(buf.Bytes())
 		}
-		return traces, nil
+	case v07:
+		reader := pb.NewMsgpReader(req.Body)
+		defer pb.FreeMsgpReader(reader)
+		err = traces.DecodeMsgStringTable(reader)
+	default:
+		err = decodeRequest(req, traces)
+	}
+	if err != nil {
+		putTraces(traces)
+		return nil, err
 	}
+	return traces, nil
 }
 
-func (r *HTTPReceiver) replyOK(v Version, w http.ResponseWriter) {
+// maxArenaPrealloc specifies the maximum number of bytes preallocated for a payload
+// arena based on the request's Content-Length.
+const maxArenaPrealloc = 10 * 1024 * 1024
+
+// arenaSize returns the initial size of the buffer used to read the body of req.
+func arenaSize(req *http.Request) int64 {
+	if req.ContentLength <= 0 {
+		return bytes.MinRead
+	}
+	if req.ContentLength > maxArenaPrealloc {
+		return maxArenaPrealloc
+	}
+	return req.ContentLength
+}
+
+// replyOK replies to a request with the given headers, sending the sampling rates to the
+// tracers using an API version which supports them.
+func (r *HTTPReceiver) replyOK(v Version, w http.ResponseWriter, header http.Header) {
 	switch v {
 	case v01, v02, v03:
 		httpOK(w)
 	default:
-		httpRateByService(w, r.dynConf)
+		httpRateByService(w, r.dynConf, header.Get(headerRateByResource) != "")
 	}
 }
 
-// rateLimited reports whether n number of traces should be rejected by the API.
-func (r *HTTPReceiver) rateLimited(n int64) bool {
+// rateLimited reports whether n number of traces from the given origin should be rejected
+// by the API. The origin is given by payloadOrigin.
+func (r *HTTPReceiver) rateLimited(origin string, n int64) bool {
 	if n == 0 {
 		return false
 	}
@@ -387,21 +701,64 @@ func (r *HTTPReceiver) rateLimited(n int64) bool {
 		// rate limiting is off
 		return false
 	}
-	return !r.RateLimiter.Permits(n)
+	return !r.RateLimiter.Permits(origin, n)
+}
+
+// tracerOrigin returns the origin of the payload of req for the rate limiter. The tracers of
+// distinct languages sending from the same container or host, such as a PHP application calling
+// a Go service, are limited separately, so that the payloads of one do not use up the rate of the
+// other.
+func tracerOrigin(req *http.Request) string {
+	peer, _ := req.Context().Value(connOriginKey{}).(string)
+	origin := payloadOrigin(peer, req.RemoteAddr)
+	if lang := req.Header.Get(headerLang); lang != "" {
+		return origin + "|" + lang
+	}
+	return origin
 }
 
 // handleTraces knows how to handle a bunch of traces
 func (r *HTTPReceiver) handleTraces(v Version, w http.ResponseWriter, req *http.Request) {
 	ts := r.tagStats(v, req)
-	tracen, err := traceCount(req)
-	if err == nil && r.rateLimited(tracen) {
-		// this payload can not be accepted
+	origin := tracerOrigin(req)
+	tracen, countErr := traceCount(req)
+	if countErr == nil && r.rateLimited(origin, tracen) {
+		// this payload can not be accepted, it is refused without being decoded
 		io.Copy(ioutil.Discard, req.Body)
 		w.WriteHeader(r.rateLimiterResponse)
-		r.replyOK(v, w)
+		r.replyOK(v, w, req.Header)
 		atomic.AddInt64(&ts.PayloadRefused, 1)
+		atomic.AddInt64(&ts.TracesDropped.PreSampled, tracen)
+		return
+	}
+	if err := r.checkTracerVersion(req.Header.Get(headerLang), req.Header.Get(headerTracerVersion)); err != nil {
+		// this tracer is too old to be accepted
+		io.Copy(ioutil.Discard, req.Body)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		atomic.AddInt64(&ts.TracesDropped.OutdatedTracer, tracen)
+		return
+	}
+	endpoint, err := r.payloadEndpoint(req.Header)
+	if err != nil {
+		io.Copy(ioutil.Discard, req.Body)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		metrics.Count(receiverErrorKey, 1, []string{"handler:traces", "error:unknown-api-key-alias"}, 1)
+		return
+	}
+	wire := req.Body.(*LimitedReader)
+	if err := decompressBody(req, r.conf.MaxRequestBytes); err != nil {
+		io.Copy(ioutil.Discard, req.Body)
+		status := http.StatusBadRequest
+		if _, ok := err.(errUnsupportedEncoding); ok {
+			status = http.StatusUnsupportedMediaType
+		}
+		http.Error(w, err.Error(), status)
+		metrics.Count(receiverErrorKey, 1, []string{"handler:traces", "error:content-encoding"}, 1)
+		atomic.AddInt64(&ts.TracesDropped.DecodingError, tracen)
+		log.Errorf("Cannot decompress %s traces payload: %v", v, err)
 		return
 	}
+	defer req.Body.Close()
 
 	traces, err := decodeTraces(v, req)
 	if err != nil {
@@ -421,17 +778,79 @@ func (r *HTTPReceiver) handleTraces(v Version, w http.ResponseWriter, req *http.
 		log.Errorf("Cannot decode %s traces payload: %v", v, err)
 		return
 	}
-	r.replyOK(v, w)
+	defer putTraces(traces)
 
-	atomic.AddInt64(&ts.TracesReceived, int64(len(traces)))
-	atomic.AddInt64(&ts.TracesBytes, req.Body.(*LimitedReader).Count)
+	if n := int64(len(*traces)); countErr != nil && r.rateLimited(origin, n) {
+		// the payloads without a trace count can only be limited once decoded
+		w.WriteHeader(r.rateLimiterResponse)
+		r.replyOK(v, w, req.Header)
+		atomic.AddInt64(&ts.PayloadRefused, 1)
+		atomic.AddInt64(&ts.TracesDropped.PreSampled, n)
+		return
+	}
+	r.replyOK(v, w, req.Header)
+
+	atomic.AddInt64(&ts.TracesReceived, int64(len(*traces)))
+	atomic.AddInt64(&ts.TracesBytes, wire.Count)
 	atomic.AddInt64(&ts.PayloadAccepted, 1)
 
+	containerID := req.Header.Get(headerContainerID)
 	payload := &Payload{
-		Source:                 ts,
-		Traces:                 traces,
-		ContainerTags:          getContainerTags(req.Header.Get(headerContainerID)),
+		Source: ts,
+		TracerPayload: &pb.TracerPayload{
+			ContainerID:     containerID,
+			LanguageName:    req.Header.Get(headerLang),
+			LanguageVersion: req.Header.Get(headerLangVersion),
+			TracerVersion:   req.Header.Get(headerTracerVersion),
+			RuntimeID:       req.Header.Get(headerRuntimeID),
+			Chunks:          traceChunksFromTraces(*traces),
+		},
+		ContainerTags:          getContainerTags(containerID),
 		ClientComputedTopLevel: req.Header.Get(headerComputedTopLevel) != "",
+		ClientComputedStats:    req.Header.Get(headerComputedStats) != "",
+		Endpoint:               endpoint,
+	}
+	r.sendPayload(payload, req.Header)
+}
+
+// payloadEndpoint returns the endpoint the traces of a payload received with the given headers
+// are written to, as chosen by the tracer, or nil for the default endpoints.
+func (r *HTTPReceiver) payloadEndpoint(header http.Header) (*config.Endpoint, error) {
+	if alias := header.Get(headerAPIKeyAlias); alias != "" {
+		e, ok := r.conf.APIKeyAliases[alias]
+		if !ok {
+			return nil, fmt.Errorf("unknown API key alias %q", alias)
+		}
+		return e, nil
+	}
+	if key := header.Get(headerAPIKey); key != "" && r.conf.AcceptTracerAPIKeys {
+		return &config.Endpoint{
+			Host:    r.conf.Endpoints[0].Host,
+			APIKey:  key,
+			NoProxy: r.conf.Endpoints[0].NoProxy,
+		}, nil
+	}
+	return nil, nil
+}
+
+// PayloadTransformer transforms a payload received by the receiver once it is decoded, before it
+// is processed by the agent, such as to rewrite service names or to add tags based on custom
+// headers. header holds the headers of the HTTP request, or the metadata of the gRPC call, the
+// payload was received in. It is empty for the payloads received over UDP.
+type PayloadTransformer func(p *Payload, header http.Header)
+
+// AddPayloadTransformer installs t, allowing programs embedding the receiver to customize
+// the payloads received on any of its endpoints. The transformers are called in the order they
+// are added, from the goroutine handling the request. It must be called before Start.
+func (r *HTTPReceiver) AddPayloadTransformer(t PayloadTransformer) {
+	r.transformers = append(r.transformers, t)
+}
+
+// sendPayload sends a payload received with the given headers to the agent, once transformed,
+// without ever dropping it.
+func (r *HTTPReceiver) sendPayload(payload *Payload, header http.Header) {
+	for _, transform := range r.transformers {
+		transform(payload, header)
 	}
 	select {
 	case r.out <- payload:
@@ -460,12 +879,31 @@ type Payload struct {
 	// trace (e.g. K8S pod, Docker image, ECS, etc). They are of the type "k1:v1,k2:v2".
 	ContainerTags string
 
-	// Traces contains all the traces received in the payload
-	Traces pb.Traces
+	// TracerPayload holds the traces received in the payload, each in its own chunk, along
+	// with information about the tracer which sent them.
+	TracerPayload *pb.TracerPayload
 
 	// ClientComputedTopLevel specifies that the client has already marked top-level
 	// spans.
 	ClientComputedTopLevel bool
+
+	// ClientComputedStats specifies that the client has already computed the stats of
+	// the traces of the payload.
+	ClientComputedStats bool
+
+	// Endpoint specifies the endpoint the traces of the payload are written to, as chosen by
+	// the tracer using an API key alias or its own API key. When nil, the traces are written
+	// to the endpoints of the agent configuration.
+	Endpoint *config.Endpoint
+}
+
+// traceChunksFromTraces returns a chunk for each of the given traces.
+func traceChunksFromTraces(traces pb.Traces) []*pb.TraceChunk {
+	chunks := make([]*pb.TraceChunk, 0, len(traces))
+	for _, trace := range traces {
+		chunks = append(chunks, &pb.TraceChunk{Spans: trace})
+	}
+	return chunks
 }
 
 // handleServices handle a request with a list of several services
@@ -481,7 +919,7 @@ func (r *HTTPReceiver) loop() {
 	defer close(r.exit)
 
 	var lastLog time.Time
-	accStats := info.NewReceiverStats()
+	accStats := r.accStats
 
 	t := time.NewTicker(10 * time.Second)
 	defer t.Stop()
@@ -497,6 +935,16 @@ func (r *HTTPReceiver) loop() {
 		case now := <-t.C:
 			metrics.Gauge("datadog.trace_agent.heartbeat", 1, nil, 1)
 			metrics.Gauge("datadog.trace_agent.receiver.out_chan_fill", float64(len(r.out))/float64(cap(r.out)), nil, 1)
+			r.connLimiter.report()
+			if r.remoteConfig != nil {
+				r.remoteConfig.report()
+			}
+			if r.debugger != nil {
+				r.debugger.report()
+			}
+			if r.addressFamily != "" {
+				metrics.Gauge("datadog.trace_agent.receiver.address_family", 1, []string{"family:" + r.addressFamily}, 1)
+			}
 
 			// We update accStats with the new stats we collected
 			accStats.Acc(r.Stats)
@@ -515,6 +963,7 @@ func (r *HTTPReceiver) loop() {
 
 				// We reset the stats accumulated during the last minute
 				accStats.Reset()
+				atomic.StoreInt64(&r.accStatsSince, now.UnixNano())
 				lastLog = now
 
 				// Also publish rates by service (they are updated by receiver)
@@ -591,17 +1040,14 @@ func (r *HTTPReceiver) Languages() string {
 	return strings.Join(str, "|")
 }
 
+// decodeRequest decodes the traces of req into dest. Msgpack payloads are decoded while they
+// are read, without buffering the whole request body.
 func decodeRequest(req *http.Request, dest *pb.Traces) error {
 	switch mediaType := getMediaType(req); mediaType {
 	case "application/msgpack":
-		buf := getBuffer()
-		defer putBuffer(buf)
-		_, err := io.Copy(buf, req.Body)
-		if err != nil {
-			return err
-		}
-		_, err = dest.UnmarshalMsg(buf.Bytes())
-		return err
+		reader := pb.NewMsgpReader(req.Body)
+		defer pb.FreeMsgpReader(reader)
+		return readError(dest.DecodeMsg(reader))
 	case "application/json":
 		fallthrough
 	case "text/json":
@@ -611,14 +1057,9 @@ func decodeRequest(req *http.Request, dest *pb.Traces) error {
 	default:
 		// do our best
 		if err1 := json.NewDecoder(req.Body).Decode(dest); err1 != nil {
-			buf := getBuffer()
-			defer putBuffer(buf)
-			_, err2 := io.Copy(buf, req.Body)
-			if err2 != nil {
-				return fmt.Errorf("could not decode JSON (%q), nor Msgpack (%q)", err1, err2)
-			}
-			_, err2 = dest.UnmarshalMsg(buf.Bytes())
-			if err2 != nil {
+			reader := pb.NewMsgpReader(req.Body)
+			defer pb.FreeMsgpReader(reader)
+			if err2 := dest.DecodeMsg(reader); err2 != nil {
 				return fmt.Errorf("could not decode JSON (%q), nor Msgpack (%q)", err1, err2)
 			}
 		}
@@ -626,6 +1067,16 @@ func decodeRequest(req *http.Request, dest *pb.Traces) error {
 	}
 }
 
+// readError returns the error which occurred while reading the body of a request if it caused
+// err, so that it is reported as such (e.g. payload too large) instead of a decoding error.
+func readError(err error) error {
+	cause := msgp.Cause(err)
+	if _, ok := cause.(msgp.Error); ok || cause == nil {
+		return err
+	}
+	return cause
+}
+
 func tracesFromSpans(spans []pb.Span) pb.Traces {
 	traces := pb.Traces{}
 	byID := make(map[uint64][]*pb.Span)
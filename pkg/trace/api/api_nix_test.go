@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
 )
@@ -66,5 +67,12 @@ func TestUDS(t *testing.T) {
 		if resp.StatusCode != 200 {
 			t.Fatalf("expected http.StatusOK, got response: %#v", resp)
 		}
+
+		r.Stats.RLock()
+		_, ok := r.Stats.Stats[info.Tags{EndpointVersion: "v0.4", Transport: "unix"}]
+		r.Stats.RUnlock()
+		if !ok {
+			t.Fatalf("expected stats for the unix transport, got: %v", r.Stats.Stats)
+		}
 	})
 }
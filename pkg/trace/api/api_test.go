@@ -11,10 +11,12 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -28,6 +30,7 @@ import (
 	"github.com/cihub/seelog"
 	"github.com/stretchr/testify/assert"
 	vmsgp "github.com/vmihailenco/msgpack/v4"
+	"google.golang.org/grpc/metadata"
 )
 
 // Traces shouldn't come from more than 5 different sources
@@ -148,8 +151,8 @@ func TestLegacyReceiver(t *testing.T) {
 			// now we should be able to read the trace data
 			select {
 			case p := <-tc.r.out:
-				assert.Len(p.Traces, 1)
-				rt := p.Traces[0]
+				assert.Len(p.TracerPayload.Chunks, 1)
+				rt := p.TracerPayload.Chunks[0].Spans
 				assert.Len(rt, 1)
 				span := rt[0]
 				assert.Equal(uint64(42), span.TraceID)
@@ -213,7 +216,7 @@ func TestReceiverJSONDecoder(t *testing.T) {
 			// now we should be able to read the trace data
 			select {
 			case p := <-tc.r.out:
-				rt := p.Traces[0]
+				rt := p.TracerPayload.Chunks[0].Spans
 				assert.Len(rt, 1)
 				span := rt[0]
 				assert.Equal(uint64(42), span.TraceID)
@@ -280,7 +283,7 @@ func TestReceiverMsgpackDecoder(t *testing.T) {
 				// now we should be able to read the trace data
 				select {
 				case p := <-tc.r.out:
-					rt := p.Traces[0]
+					rt := p.TracerPayload.Chunks[0].Spans
 					assert.Len(rt, 1)
 					span := rt[0]
 					assert.Equal(uint64(42), span.TraceID)
@@ -303,7 +306,7 @@ func TestReceiverMsgpackDecoder(t *testing.T) {
 				// now we should be able to read the trace data
 				select {
 				case p := <-tc.r.out:
-					rt := p.Traces[0]
+					rt := p.TracerPayload.Chunks[0].Spans
 					assert.Len(rt, 1)
 					span := rt[0]
 					assert.Equal(uint64(42), span.TraceID)
@@ -393,6 +396,45 @@ func TestReceiverUnexpectedEOF(t *testing.T) {
 	assert.EqualValues(traceCount, r.Stats.GetTagStats(info.Tags{EndpointVersion: "v0.5"}).TracesDropped.EOF)
 }
 
+func TestReceiverTracerPayload(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestReceiverFromConfig(newTestReceiverConfig())
+	server := httptest.NewServer(http.HandlerFunc(r.handleWithVersion(v04, r.handleTraces)))
+	defer server.Close()
+
+	traces := pb.Traces{testutil.RandomTrace(3, 1), testutil.RandomTrace(2, 1)}
+	req, err := http.NewRequest("POST", server.URL, bytes.NewReader(msgpTraces(t, traces)))
+	assert.NoError(err)
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set(headerTraceCount, "2")
+	req.Header.Set(headerLang, "python")
+	req.Header.Set(headerLangVersion, "3.8.1")
+	req.Header.Set(headerTracerVersion, "0.44.0")
+	req.Header.Set(headerContainerID, "abcdef")
+	req.Header.Set(headerRuntimeID, "9E1B6B4E-3A8C-4F21-B0E4-8F0C7D7E1A52")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(200, resp.StatusCode)
+
+	select {
+	case p := <-r.out:
+		tp := p.TracerPayload
+		assert.Equal("abcdef", tp.ContainerID)
+		assert.Equal("python", tp.LanguageName)
+		assert.Equal("3.8.1", tp.LanguageVersion)
+		assert.Equal("0.44.0", tp.TracerVersion)
+		assert.Equal("9E1B6B4E-3A8C-4F21-B0E4-8F0C7D7E1A52", tp.RuntimeID)
+		assert.Len(tp.Chunks, 2)
+		for i, chunk := range tp.Chunks {
+			assert.Len(chunk.Spans, len(traces[i]))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no payload received")
+	}
+}
+
 func TestTraceCount(t *testing.T) {
 	req, err := http.NewRequest("GET", "/", nil)
 	assert.NoError(t, err)
@@ -457,7 +499,7 @@ func TestDecodeV05(t *testing.T) {
 	assert.NoError(err)
 	traces, err := decodeTraces(v05, req)
 	assert.NoError(err)
-	assert.EqualValues(traces, pb.Traces{
+	assert.EqualValues(*traces, pb.Traces{
 		{
 			{
 				Service:  "Service",
@@ -505,6 +547,21 @@ func TestDecodeV05(t *testing.T) {
 	})
 }
 
+func TestDecodeV07(t *testing.T) {
+	assert := assert.New(t)
+	want := pb.Traces{
+		{
+			{Service: "Service", Name: "Name", Resource: "Resource", TraceID: 1, SpanID: 2, Meta: map[string]string{"A": "B"}, Type: "sql"},
+			{Service: "Service", Name: "Name", Resource: "Resource2", TraceID: 1, SpanID: 3, ParentID: 2, Metrics: map[string]float64{"X": 1.2}},
+		},
+	}
+	req, err := http.NewRequest("POST", "/v0.7/traces", bytes.NewReader(want.MarshalMsgStringTable(nil)))
+	assert.NoError(err)
+	traces, err := decodeTraces(v07, req)
+	assert.NoError(err)
+	assert.EqualValues(want, *traces)
+}
+
 func TestHandleTraces(t *testing.T) {
 	assert := assert.New(t)
 
@@ -551,6 +608,120 @@ func TestHandleTraces(t *testing.T) {
 	assert.Equal("C#|go|java|python|ruby", receiver.Languages())
 }
 
+func TestPayloadTransformer(t *testing.T) {
+	assert := assert.New(t)
+	bts, err := testutil.GetTestTraces(2, 2, true).MarshalMsg(nil)
+	assert.NoError(err)
+	receiver := newTestReceiverFromConfig(newTestReceiverConfig())
+	var order []string
+	receiver.AddPayloadTransformer(func(p *Payload, header http.Header) {
+		order = append(order, "first")
+		for _, chunk := range p.TracerPayload.Chunks {
+			for _, span := range chunk.Spans {
+				span.Service = header.Get("X-Service")
+			}
+		}
+	})
+	receiver.AddPayloadTransformer(func(p *Payload, header http.Header) {
+		order = append(order, "second")
+		p.TracerPayload.Tags = map[string]string{"team": header.Get("X-Team")}
+	})
+
+	req, _ := http.NewRequest("POST", "/v0.4/traces", bytes.NewReader(bts))
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("X-Service", "rewritten")
+	req.Header.Set("X-Team", "apm")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(receiver.handleWithVersion(v04, receiver.handleTraces)).ServeHTTP(rr, req)
+	assert.Equal(http.StatusOK, rr.Code)
+
+	select {
+	case p := <-receiver.out:
+		assert.Equal([]string{"first", "second"}, order)
+		assert.Len(p.TracerPayload.Chunks, 2)
+		for _, chunk := range p.TracerPayload.Chunks {
+			for _, span := range chunk.Spans {
+				assert.Equal("rewritten", span.Service)
+			}
+		}
+		assert.Equal(map[string]string{"team": "apm"}, p.TracerPayload.Tags)
+	case <-time.After(time.Second):
+		t.Fatal("no payload received")
+	}
+
+	t.Run("zipkin", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/zipkin/api/v2/spans", strings.NewReader(testZipkinSpans))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Team", "tracing")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(receiver.handleZipkinSpans).ServeHTTP(rr, req)
+		assert.Equal(http.StatusAccepted, rr.Code)
+
+		p := <-receiver.out
+		assert.Equal(map[string]string{"team": "tracing"}, p.TracerPayload.Tags)
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		md := metadata.Pairs("x-team", "grpc")
+		assert.Equal("grpc", metadataHeader(md).Get("X-Team"))
+	})
+}
+
+func TestPayloadEndpoint(t *testing.T) {
+	bts, err := testutil.GetTestTraces(2, 2, true).MarshalMsg(nil)
+	assert.NoError(t, err)
+	teamA := &config.Endpoint{APIKey: "key-a", Host: "https://trace.agent.datadoghq.eu"}
+	post := func(conf *config.AgentConfig, header map[string]string) (int, *Payload) {
+		receiver := newTestReceiverFromConfig(conf)
+		req, _ := http.NewRequest("POST", "/v0.4/traces", bytes.NewReader(bts))
+		req.Header.Set("Content-Type", "application/msgpack")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(receiver.handleWithVersion(v04, receiver.handleTraces)).ServeHTTP(rr, req)
+		select {
+		case p := <-receiver.out:
+			return rr.Code, p
+		default:
+			return rr.Code, nil
+		}
+	}
+
+	conf := newTestReceiverConfig()
+	conf.APIKeyAliases = map[string]*config.Endpoint{"team-a": teamA}
+
+	t.Run("default", func(t *testing.T) {
+		code, p := post(conf, nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Nil(t, p.Endpoint)
+	})
+
+	t.Run("alias", func(t *testing.T) {
+		code, p := post(conf, map[string]string{"Datadog-API-Key-Alias": "team-a"})
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, teamA, p.Endpoint)
+	})
+
+	t.Run("unknown-alias", func(t *testing.T) {
+		code, p := post(conf, map[string]string{"Datadog-API-Key-Alias": "team-b"})
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Nil(t, p)
+	})
+
+	t.Run("api-key", func(t *testing.T) {
+		code, p := post(conf, map[string]string{"DD-API-KEY": "key-b"})
+		assert.Equal(t, http.StatusOK, code)
+		assert.Nil(t, p.Endpoint)
+
+		conf := newTestReceiverConfig()
+		conf.AcceptTracerAPIKeys = true
+		code, p = post(conf, map[string]string{"DD-API-KEY": "key-b"})
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, &config.Endpoint{APIKey: "key-b", Host: conf.Endpoints[0].Host}, p.Endpoint)
+	})
+}
+
 // chunkedReader is a reader which forces partial reads, this is required
 // to trigger some network related bugs, such as body not being read fully by server.
 // Without this, all the data could be read/written at once, not triggering the issue.
@@ -658,6 +829,58 @@ func TestReceiverRateLimiterCancel(t *testing.T) {
 	wg.Wait()
 }
 
+func TestReceiverPreSampled(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestReceiverFromConfig(newTestReceiverConfig())
+	r.RateLimiter.SetTargetRate(0)
+	server := httptest.NewServer(http.HandlerFunc(r.handleWithVersion(v04, r.handleTraces)))
+	defer server.Close()
+
+	post := func(count string) {
+		traces := pb.Traces{testutil.RandomTrace(3, 1), testutil.RandomTrace(3, 1)}
+		req, err := http.NewRequest("POST", server.URL, bytes.NewReader(msgpTraces(t, traces)))
+		assert.NoError(err)
+		req.Header.Set("Content-Type", "application/msgpack")
+		req.Header.Set(headerLang, "python")
+		if count != "" {
+			req.Header.Set(headerTraceCount, count)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(err)
+		resp.Body.Close()
+		assert.Equal(http.StatusOK, resp.StatusCode)
+	}
+	ts := r.Stats.GetTagStats(info.Tags{Lang: "python", EndpointVersion: "v0.4"})
+
+	// the first payload of an origin is accepted until its rate is known
+	post("2")
+	assert.EqualValues(1, ts.PayloadAccepted)
+	post("2")
+	assert.EqualValues(1, ts.PayloadRefused)
+	assert.EqualValues(2, ts.TracesDropped.PreSampled)
+
+	// the payloads without a trace count are limited once decoded, their traces counted
+	post("")
+	assert.EqualValues(2, ts.PayloadRefused)
+	assert.EqualValues(4, ts.TracesDropped.PreSampled)
+	assert.Len(r.out, 1)
+
+	// the tracers of other languages are limited separately
+	post2 := func(lang string) {
+		req, err := http.NewRequest("POST", server.URL, bytes.NewReader(msgpTraces(t, pb.Traces{testutil.RandomTrace(3, 1)})))
+		assert.NoError(err)
+		req.Header.Set("Content-Type", "application/msgpack")
+		req.Header.Set(headerLang, lang)
+		req.Header.Set(headerTraceCount, "1")
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(err)
+		resp.Body.Close()
+	}
+	post2("go")
+	assert.EqualValues(1, r.Stats.GetTagStats(info.Tags{Lang: "go", EndpointVersion: "v0.4"}).PayloadAccepted)
+	assert.Len(r.out, 2)
+}
+
 func BenchmarkHandleTracesFromOneApp(b *testing.B) {
 	assert := assert.New(b)
 	// prepare the payload
@@ -768,22 +991,16 @@ func BenchmarkDecoderMsgpack(b *testing.B) {
 	// msgpack payload
 	bts, err := testutil.GetTestTraces(150, 66, true).MarshalMsg(nil)
 	assert.Nil(err)
-	bufferPool := &sync.Pool{
-		New: func() interface{} {
-			return new(bytes.Buffer)
-		},
-	}
 
 	// benchmark
 	b.ResetTimer()
 	b.ReportAllocs()
 	for n := 0; n < b.N; n++ {
-		var traces pb.Traces
-		buffer := bufferPool.Get().(*bytes.Buffer)
-		buffer.Reset()
-		_, _ = io.Copy(buffer, bytes.NewReader(bts))
-		_, _ = traces.UnmarshalMsg(buffer.Bytes())
-		bufferPool.Put(buffer)
+		traces := getTraces()
+		reader := pb.NewMsgpReader(bytes.NewReader(bts))
+		_ = traces.DecodeMsg(reader)
+		pb.FreeMsgpReader(reader)
+		putTraces(traces)
 	}
 }
 
@@ -818,6 +1035,40 @@ func TestReplyOKV5(t *testing.T) {
 	assert.Contains(t, string(slurp), `"rate_by_service"`)
 }
 
+func TestReplyRateByResource(t *testing.T) {
+	r := newTestReceiverFromConfig(config.New())
+	r.dynConf.RateByService.SetAll(map[sampler.ServiceSignature]float64{
+		{Name: "web", Env: "prod"}:                    0.5,
+		{Name: "web", Env: "prod", Resource: "GET /"}: 0.1,
+	})
+
+	for name, tt := range map[string]struct {
+		header    string
+		resources map[string]float64
+	}{
+		"unsupported": {},
+		"supported": {
+			header:    "true",
+			resources: map[string]float64{"service:web,env:prod,resource:GET /": 0.1},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set(headerRateByResource, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			r.replyOK(v04, rec, header)
+
+			var tr traceResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tr))
+			// the rates by resource are only sent to the tracers supporting them
+			assert.Equal(t, map[string]float64{"service:web,env:prod": 0.5}, tr.Rates)
+			assert.Equal(t, tt.resources, tr.ResourceRates)
+		})
+	}
+}
+
 func TestExpvar(t *testing.T) {
 	if testing.Short() {
 		return
@@ -839,6 +1090,121 @@ func TestExpvar(t *testing.T) {
 	}
 }
 
+func TestHandleSamplers(t *testing.T) {
+	r := newTestReceiverFromConfig(config.New())
+	defer info.SetSamplersDump(nil)
+
+	rec := httptest.NewRecorder()
+	r.handleSamplers(rec, httptest.NewRequest("GET", "/debug/samplers", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	info.SetSamplersDump(func() interface{} {
+		return map[string]sampler.EngineDump{"hash": {Type: "hash", State: sampler.HashState{Rate: 0.5}}}
+	})
+	rec = httptest.NewRecorder()
+	r.handleSamplers(rec, httptest.NewRequest("GET", "/debug/samplers", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"hash":{"type":"hash","state":{"Rate":0.5}}}`, rec.Body.String())
+}
+
+func TestHandleReceiverStats(t *testing.T) {
+	r := newTestReceiverFromConfig(config.New())
+	r.accStats.GetTagStats(info.Tags{Lang: "python", TracerVersion: "0.50.0", EndpointVersion: "v0.4"}).TracesBytes = 10
+	r.Stats.GetTagStats(info.Tags{Lang: "python", TracerVersion: "0.50.0", EndpointVersion: "v0.4"}).TracesBytes = 5
+	ts := r.Stats.GetTagStats(info.Tags{Lang: "go", TracerVersion: "1.30.0", EndpointVersion: "v0.5"})
+	ts.TracesBytes = 100
+	ts.TracesReceived = 2
+	ts.TracesDropped.DecodingError = 1
+
+	get := func(t *testing.T, query string) receiverStatsResponse {
+		rec := httptest.NewRecorder()
+		r.handleReceiverStats(rec, httptest.NewRequest("GET", "/debug/receiver"+query, nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		var resp receiverStatsResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp
+	}
+
+	t.Run("all", func(t *testing.T) {
+		resp := get(t, "")
+		assert.Equal(t, []info.TagStatsSummary{
+			{
+				Lang:            "go",
+				TracerVersion:   "1.30.0",
+				EndpointVersion: "v0.5",
+				TracesReceived:  2,
+				TracesBytes:     100,
+				TracesDropped:   map[string]int64{"decoding_error": 1},
+				SpansMalformed:  map[string]int64{},
+			},
+			{
+				Lang:            "python",
+				TracerVersion:   "0.50.0",
+				EndpointVersion: "v0.4",
+				TracesBytes:     15,
+				TracesDropped:   map[string]int64{},
+				SpansMalformed:  map[string]int64{},
+			},
+		}, resp.Stats)
+		assert.False(t, resp.Since.IsZero())
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		resp := get(t, "?lang=python")
+		if assert.Len(t, resp.Stats, 1) {
+			assert.Equal(t, "python", resp.Stats[0].Lang)
+		}
+		resp = get(t, "?endpoint=v0.5")
+		if assert.Len(t, resp.Stats, 1) {
+			assert.Equal(t, "go", resp.Stats[0].Lang)
+		}
+		assert.Empty(t, get(t, "?lang=java").Stats)
+	})
+}
+
+func TestAddressFamily(t *testing.T) {
+	for _, tt := range []struct {
+		addr   net.Addr
+		family string
+		want   string
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1")}, "", "ipv4"},
+		{&net.TCPAddr{IP: net.ParseIP("::1")}, "", "ipv6"},
+		{&net.TCPAddr{IP: net.ParseIP("::")}, "", "dual"},
+		{&net.TCPAddr{IP: net.ParseIP("::")}, "ipv6", "ipv6"},
+		{&net.UDPAddr{IP: net.ParseIP("0.0.0.0")}, "ipv4", "ipv4"},
+		{&net.UnixAddr{Name: "/tmp/apm.sock"}, "", ""},
+	} {
+		assert.Equal(t, tt.want, addressFamily(tt.addr, tt.family), tt.addr.String())
+	}
+}
+
+func TestReceiverIPv6(t *testing.T) {
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 is not supported: %v", err)
+	}
+	ln.Close()
+
+	conf := config.New()
+	conf.Endpoints[0].APIKey = "test"
+	conf.ReceiverHost = "::1"
+	conf.ReceiverPort = ln.Addr().(*net.TCPAddr).Port
+	conf.AddressFamily = "ipv6"
+	r := newTestReceiverFromConfig(conf)
+	r.Start()
+	defer r.Stop()
+
+	assert.Equal(t, "ipv6", r.addressFamily)
+	resp, err := http.Post(fmt.Sprintf("http://[::1]:%d/v0.4/traces", conf.ReceiverPort), "application/msgpack", bytes.NewReader(msgpTraces(t, pb.Traces{})))
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
 func TestWatchdog(t *testing.T) {
 	t.Run("rate-limit", func(t *testing.T) {
 		if testing.Short() {
@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/logutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+)
+
+// connNetworkKey is the context key holding the network ("tcp", "unix", "pipe", etc.)
+// of the listener which accepted the connection a request was received on.
+type connNetworkKey struct{}
+
+// connOriginKey is the context key holding the origin of the connection a request was
+// received on, as given by connOrigin.
+type connOriginKey struct{}
+
+// connContext tags the context of every connection with the network of the listener
+// that accepted it, with its origin when known, and with the connection itself when it
+// is hardened. It is meant to be used as an http.Server's ConnContext.
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	if hc, ok := c.(*hardenedConn); ok {
+		ctx = context.WithValue(ctx, hardenedConnKey{}, hc)
+	}
+	if origin := connOrigin(c); origin != "" {
+		ctx = context.WithValue(ctx, connOriginKey{}, origin)
+	}
+	return context.WithValue(ctx, connNetworkKey{}, c.LocalAddr().Network())
+}
+
+// transport returns the transport reported in the stats of the payloads received on the
+// given network, merging the TCP ones ("tcp4", "tcp6", etc.).
+func transport(network string) string {
+	if strings.HasPrefix(network, "tcp") {
+		return "tcp"
+	}
+	return network
+}
+
+// requestTransport returns the transport of the connection req was received on, or an
+// empty string when it is unknown.
+func requestTransport(req *http.Request) string {
+	network, _ := req.Context().Value(connNetworkKey{}).(string)
+	return transport(network)
+}
+
+// isLocalRequest reports whether req originates from the same host, meaning that it was
+// received either via a non-TCP transport (such as UDS or Windows pipes) or from a
+// loopback address.
+func isLocalRequest(req *http.Request) bool {
+	if network, ok := req.Context().Value(connNetworkKey{}).(string); ok && !strings.HasPrefix(network, "tcp") {
+		return true
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// bearerToken returns the token in the "Authorization: Bearer <token>" header of req.
+func bearerToken(req *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	auth := req.Header.Get("Authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
+// udpListenHost returns the host the UDP listeners of the receiver bind on, given the host
+// of the receiver. The datagrams they receive can't carry the shared token, so when it is
+// set they only listen on the loopback interface, and restricted is true if host is not
+// already a loopback one.
+func udpListenHost(host, token string) (listenHost string, restricted bool) {
+	if token == "" || host == "localhost" {
+		return host, false
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return host, false
+	}
+	return "localhost", true
+}
+
+// authHandler wraps h, requiring requests which are not local to be authenticated using
+// the given shared bearer token. If token is empty, h is returned unchanged.
+func authHandler(token string, h http.Handler) http.Handler {
+	if token == "" {
+		return h
+	}
+	logger := logutil.NewThrottled(5, 10*time.Second) // limit to 5 messages every 10 seconds
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if isLocalRequest(req) {
+			h.ServeHTTP(w, req)
+			return
+		}
+		got, ok := bearerToken(req)
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			logger.Warn("Rejecting unauthenticated request from %s to %s", req.RemoteAddr, req.URL.Path)
+			metrics.Count(receiverErrorKey, 1, []string{"error:unauthorized"}, 1)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="datadog-trace-agent"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// debugAuthHandler wraps h, which serves details about the tracers and their services. The
+// requests must be authenticated using the given shared bearer token, even when they are local.
+// If token is empty, only the local requests are accepted.
+func debugAuthHandler(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if token == "" {
+			if !isLocalRequest(req) {
+				metrics.Count(receiverErrorKey, 1, []string{"error:forbidden"}, 1)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, req)
+			return
+		}
+		got, ok := bearerToken(req)
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			metrics.Count(receiverErrorKey, 1, []string{"error:unauthorized"}, 1)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="datadog-trace-agent"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,110 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for name, tt := range map[string]struct {
+		token   string
+		remote  string
+		network string
+		header  string
+		status  int
+	}{
+		"disabled":        {token: "", remote: "10.0.0.1:1234", status: http.StatusOK},
+		"missing":         {token: "abc", remote: "10.0.0.1:1234", status: http.StatusUnauthorized},
+		"wrong":           {token: "abc", remote: "10.0.0.1:1234", header: "Bearer abd", status: http.StatusUnauthorized},
+		"wrong-scheme":    {token: "abc", remote: "10.0.0.1:1234", header: "Basic abc", status: http.StatusUnauthorized},
+		"valid":           {token: "abc", remote: "10.0.0.1:1234", header: "Bearer abc", status: http.StatusOK},
+		"valid-case":      {token: "abc", remote: "10.0.0.1:1234", header: "bearer abc", status: http.StatusOK},
+		"localhost-ipv4":  {token: "abc", remote: "127.0.0.1:1234", status: http.StatusOK},
+		"localhost-ipv6":  {token: "abc", remote: "[::1]:1234", status: http.StatusOK},
+		"unix":            {token: "abc", remote: "@", network: "unix", status: http.StatusOK},
+		"pipe":            {token: "abc", remote: "", network: "pipe", status: http.StatusOK},
+		"tcp-not-trusted": {token: "abc", remote: "10.0.0.1:1234", network: "tcp", status: http.StatusUnauthorized},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v0.4/traces", nil)
+			req.RemoteAddr = tt.remote
+			if tt.network != "" {
+				req = req.WithContext(context.WithValue(req.Context(), connNetworkKey{}, tt.network))
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			authHandler(tt.token, ok).ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
+
+func TestDebugAuthHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for name, tt := range map[string]struct {
+		token   string
+		remote  string
+		network string
+		header  string
+		status  int
+	}{
+		"no-token-remote":    {token: "", remote: "10.0.0.1:1234", status: http.StatusForbidden},
+		"no-token-localhost": {token: "", remote: "127.0.0.1:1234", status: http.StatusOK},
+		"no-token-unix":      {token: "", remote: "@", network: "unix", status: http.StatusOK},
+		"missing":            {token: "abc", remote: "10.0.0.1:1234", status: http.StatusUnauthorized},
+		"missing-localhost":  {token: "abc", remote: "127.0.0.1:1234", status: http.StatusUnauthorized},
+		"wrong":              {token: "abc", remote: "127.0.0.1:1234", header: "Bearer abd", status: http.StatusUnauthorized},
+		"valid":              {token: "abc", remote: "10.0.0.1:1234", header: "Bearer abc", status: http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/debug/receiver", nil)
+			req.RemoteAddr = tt.remote
+			if tt.network != "" {
+				req = req.WithContext(context.WithValue(req.Context(), connNetworkKey{}, tt.network))
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			debugAuthHandler(tt.token, ok).ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
+
+func TestUDPListenHost(t *testing.T) {
+	for _, tt := range []struct {
+		host, token, listenHost string
+		restricted              bool
+	}{
+		{host: "0.0.0.0", token: "", listenHost: "0.0.0.0"},
+		{host: "localhost", token: "abc", listenHost: "localhost"},
+		{host: "127.0.0.1", token: "abc", listenHost: "127.0.0.1"},
+		{host: "::1", token: "abc", listenHost: "::1"},
+		{host: "0.0.0.0", token: "abc", listenHost: "localhost", restricted: true},
+		{host: "::", token: "abc", listenHost: "localhost", restricted: true},
+		{host: "10.0.0.1", token: "abc", listenHost: "localhost", restricted: true},
+	} {
+		listenHost, restricted := udpListenHost(tt.host, tt.token)
+		assert.Equal(t, tt.listenHost, listenHost, tt.host)
+		assert.Equal(t, tt.restricted, restricted, tt.host)
+	}
+}
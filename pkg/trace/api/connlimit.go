@@ -0,0 +1,70 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+)
+
+// shedConnKey is the context key set on the connections open past the limit of a connLimiter.
+type shedConnKey struct{}
+
+// connLimiter counts the connections open on an http.Server and sheds the connections past
+// a limit: their requests are refused with http.StatusTooManyRequests and they are closed.
+// Unlike a listener, it works the same whether the connections use TLS or not, and whatever
+// the listener they were accepted on.
+type connLimiter struct {
+	max int64 // maximum number of open connections, unlimited when 0
+
+	active int64 // open connections
+	shed   int64 // requests refused since the last report
+}
+
+func newConnLimiter(max int) *connLimiter {
+	return &connLimiter{max: int64(max)}
+}
+
+// connContext counts the new connection c, marking its context if it is past the limit. It
+// must be called from the ConnContext function of the server.
+func (l *connLimiter) connContext(ctx context.Context, c net.Conn) context.Context {
+	if n := atomic.AddInt64(&l.active, 1); l.max > 0 && n > l.max {
+		return context.WithValue(ctx, shedConnKey{}, true)
+	}
+	return ctx
+}
+
+// connState releases the closed connections. It must be set as the ConnState hook of the server.
+func (l *connLimiter) connState(c net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		atomic.AddInt64(&l.active, -1)
+	}
+}
+
+// handler wraps h, refusing the requests received on the connections past the limit.
+func (l *connLimiter) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if shed, _ := req.Context().Value(shedConnKey{}).(bool); shed {
+			atomic.AddInt64(&l.shed, 1)
+			// closes the connection once the response is written
+			w.Header().Set("Connection", "close")
+			http.Error(w, "too many connections", http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// report submits the metrics about the connections.
+func (l *connLimiter) report() {
+	metrics.Gauge("datadog.trace_agent.receiver.active_connections", float64(atomic.LoadInt64(&l.active)), nil, 1)
+	metrics.Count("datadog.trace_agent.receiver.connections_shed", atomic.SwapInt64(&l.shed, 0), nil, 1)
+}
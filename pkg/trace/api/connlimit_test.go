@@ -0,0 +1,64 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnLimiter(t *testing.T) {
+	assert := assert.New(t)
+	l := newConnLimiter(1)
+	srv := httptest.NewUnstartedServer(l.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	srv.Config.ConnContext = l.connContext
+	srv.Config.ConnState = l.connState
+	srv.Start()
+	defer srv.Close()
+
+	get := func(conn net.Conn) *http.Response {
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		assert.NoError(req.Write(conn))
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if !assert.NoError(err) {
+			t.FailNow()
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// the first connection is kept open
+	conn1, err := net.Dial("tcp", srv.Listener.Addr().String())
+	assert.NoError(err)
+	defer conn1.Close()
+	assert.Equal(http.StatusOK, get(conn1).StatusCode)
+
+	// the second one is past the limit
+	conn2, err := net.Dial("tcp", srv.Listener.Addr().String())
+	assert.NoError(err)
+	defer conn2.Close()
+	resp := get(conn2)
+	assert.Equal(http.StatusTooManyRequests, resp.StatusCode)
+	assert.True(resp.Close)
+	assert.EqualValues(1, atomic.LoadInt64(&l.shed))
+
+	// the connections are released once closed
+	conn1.Close()
+	assert.Eventually(func() bool { return atomic.LoadInt64(&l.active) == 0 }, time.Second, 10*time.Millisecond)
+	conn3, err := net.Dial("tcp", srv.Listener.Addr().String())
+	assert.NoError(err)
+	defer conn3.Close()
+	assert.Equal(http.StatusOK, get(conn3).StatusCode)
+}
@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errUnsupportedEncoding is returned when a request body is compressed with an encoding
+// which is not supported.
+type errUnsupportedEncoding string
+
+func (e errUnsupportedEncoding) Error() string {
+	return fmt.Sprintf("unsupported content encoding: %q", string(e))
+}
+
+// decompressBody replaces the body of req by a reader decompressing it on the fly according
+// to its Content-Encoding header, reading no more than limit decompressed bytes. The body is
+// left unchanged when it is not compressed. It returns an errUnsupportedEncoding error when
+// the encoding is not supported, or any error occurring while reading the compression header.
+func decompressBody(req *http.Request, limit int64) error {
+	var (
+		rc  io.ReadCloser
+		err error
+	)
+	switch encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		var gzipr *gzip.Reader
+		if gzipr, err = gzip.NewReader(req.Body); err == nil {
+			rc = &decompressingReader{ReadCloser: gzipr, body: req.Body}
+		}
+	case "zstd":
+		if rc, err = newZstdReader(req.Body); err == nil {
+			rc = &decompressingReader{ReadCloser: rc, body: req.Body}
+		}
+	default:
+		return errUnsupportedEncoding(encoding)
+	}
+	if err != nil {
+		return err
+	}
+	req.Body = NewLimitedReader(rc, limit)
+	return nil
+}
+
+// decompressingReader reads a decompressed request body, closing both the decompressor and
+// the body itself when closed.
+type decompressingReader struct {
+	io.ReadCloser           // decompressor
+	body          io.Closer // compressed body
+}
+
+// Close implements io.Closer.
+func (r *decompressingReader) Close() error {
+	err := r.ReadCloser.Close()
+	if err2 := r.body.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	gzipw := gzip.NewWriter(&buf)
+	_, err := gzipw.Write(b)
+	assert.NoError(t, err)
+	assert.NoError(t, gzipw.Close())
+	return buf.Bytes()
+}
+
+func TestDecompressBody(t *testing.T) {
+	newRequest := func(encoding string, body []byte) *http.Request {
+		req, _ := http.NewRequest("POST", "/v0.4/traces", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", encoding)
+		return req
+	}
+
+	t.Run("identity", func(t *testing.T) {
+		req := newRequest("", []byte("body"))
+		body := req.Body
+		assert.NoError(t, decompressBody(req, 100))
+		assert.Equal(t, body, req.Body)
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		req := newRequest("gzip", gzipBytes(t, []byte("body")))
+		assert.NoError(t, decompressBody(req, 100))
+		b, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "body", string(b))
+		assert.NoError(t, req.Body.Close())
+	})
+
+	t.Run("zstd", func(t *testing.T) {
+		enc, err := zstd.NewWriter(nil)
+		assert.NoError(t, err)
+		req := newRequest("zstd", enc.EncodeAll([]byte("body"), nil))
+		assert.NoError(t, decompressBody(req, 100))
+		b, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "body", string(b))
+		assert.NoError(t, req.Body.Close())
+	})
+
+	t.Run("gzip-invalid", func(t *testing.T) {
+		assert.Error(t, decompressBody(newRequest("gzip", []byte("body")), 100))
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		req := newRequest("gzip", gzipBytes(t, bytes.Repeat([]byte("a"), 1000)))
+		assert.NoError(t, decompressBody(req, 100))
+		_, err := ioutil.ReadAll(req.Body)
+		assert.Equal(t, ErrLimitedReaderLimitReached, err)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		err := decompressBody(newRequest("br", []byte("body")), 100)
+		assert.Equal(t, errUnsupportedEncoding("br"), err)
+	})
+}
+
+func TestReceiverCompressedTraces(t *testing.T) {
+	assert := assert.New(t)
+	traces := testutil.GetTestTraces(3, 2, true)
+	bts, err := traces.MarshalMsg(nil)
+	assert.NoError(err)
+	receiver := newTestReceiverFromConfig(newTestReceiverConfig())
+	post := func(encoding string, body []byte) int {
+		req, _ := http.NewRequest("POST", "/v0.4/traces", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/msgpack")
+		req.Header.Set("Content-Encoding", encoding)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(receiver.handleWithVersion(v04, receiver.handleTraces)).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(http.StatusOK, post("gzip", gzipBytes(t, bts)))
+	select {
+	case p := <-receiver.out:
+		assert.Len(p.TracerPayload.Chunks, 3)
+		assert.EqualValues(len(gzipBytes(t, bts)), p.Source.TracesBytes)
+	case <-time.After(time.Second):
+		t.Fatal("no payload received")
+	}
+	assert.Equal(http.StatusUnsupportedMediaType, post("br", bts))
+}
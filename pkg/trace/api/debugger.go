@@ -0,0 +1,277 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/logutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// debuggerPath is the path of the endpoint receiving the snapshots of the live debugger.
+	debuggerPath = "/debugger/v1/input"
+	// debuggerURLTemplate specifies the template for obtaining the debugger intake URL along with the site.
+	debuggerURLTemplate = "https://http-intake.logs.%s/api/v2/logs"
+	// debuggerURLDefault specifies the default debugger intake URL.
+	debuggerURLDefault = "https://http-intake.logs.datadoghq.com/api/v2/logs"
+	// debuggerQueueSizeDefault is the default number of payloads queued for forwarding.
+	debuggerQueueSizeDefault = 100
+	// debuggerMaxPayloadSizeDefault is the default maximum size of the payloads.
+	debuggerMaxPayloadSizeDefault = 1024 * 1024
+	// debuggerWorkers is the number of payloads forwarded concurrently.
+	debuggerWorkers = 4
+	// debuggerForwardTimeout is the timeout of the forwarding of a payload.
+	debuggerForwardTimeout = 10 * time.Second
+	// debuggerStopTimeout is the maximum time spent forwarding the payloads left in the queue
+	// when stopping, after which they are dropped.
+	debuggerStopTimeout = 5 * time.Second
+)
+
+// debuggerHandler returns the handler of the debugger endpoint, forwarding the snapshots to the
+// debugger intake. If the intake URL is invalid, the returned handler always responds with
+// http.StatusInternalServerError.
+func (r *HTTPReceiver) debuggerHandler() http.Handler {
+	target := debuggerURLDefault
+	if v := config.Datadog.GetString("apm_config.debugger_dd_url"); v != "" {
+		target = v
+	} else if site := config.Datadog.GetString("site"); site != "" {
+		target = fmt.Sprintf(debuggerURLTemplate, site)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		err = fmt.Errorf("error parsing debugger intake URL %s: %v", target, err)
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, fmt.Sprintf("Debugger forwarder is OFF: %v", err), http.StatusInternalServerError)
+		})
+	}
+	apiKey := r.conf.APIKey()
+	if v := config.Datadog.GetString("apm_config.debugger_api_key"); v != "" {
+		apiKey = config.SanitizeAPIKey(v)
+	}
+	queueSize := debuggerQueueSizeDefault
+	if k := "apm_config.debugger_queue_size"; config.Datadog.IsSet(k) {
+		queueSize = config.Datadog.GetInt(k)
+	}
+	maxSize := int64(debuggerMaxPayloadSizeDefault)
+	if k := "apm_config.debugger_max_payload_size"; config.Datadog.IsSet(k) {
+		maxSize = config.Datadog.GetInt64(k)
+	}
+	client := &http.Client{
+		Timeout:   debuggerForwardTimeout,
+		Transport: r.conf.NewHTTPTransport(),
+	}
+	tags := fmt.Sprintf("host:%s,default_env:%s", r.conf.Hostname, r.conf.DefaultEnv)
+	r.debugger = newDebuggerForwarder(client, u, apiKey, tags, queueSize, maxSize)
+	r.debugger.start(debuggerWorkers)
+	return r.debugger
+}
+
+// debuggerPayload is a payload of snapshots awaiting to be forwarded.
+type debuggerPayload struct {
+	body          []byte
+	contentType   string
+	containerTags string
+}
+
+// debuggerForwarder forwards the snapshots of the live debugger to their intake. The snapshots
+// are queued and forwarded by dedicated workers and HTTP client, so that they are neither delayed
+// by the traces, nor the tracers by the intake: the requests are answered as soon as their
+// payload is queued, and refused with http.StatusTooManyRequests when the queue is full.
+type debuggerForwarder struct {
+	client  *http.Client
+	target  *url.URL
+	apiKey  string
+	tags    string
+	maxSize int64 // maximum payload size, unlimited when 0
+	queue   chan *debuggerPayload
+	wg      sync.WaitGroup // waits for the workers
+	errlog  *logutil.ThrottledLogger
+
+	// ctx is the context of the requests to the intake, cancelled by stop once the payloads
+	// left in the queue could not be forwarded in time.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// telemetry, reset when reported
+	received int64
+	dropped  int64
+	rejected int64
+	sent     int64
+	errors   int64
+	bytes    int64
+}
+
+func newDebuggerForwarder(client *http.Client, target *url.URL, apiKey, tags string, queueSize int, maxSize int64) *debuggerForwarder {
+	if queueSize <= 0 {
+		queueSize = debuggerQueueSizeDefault
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &debuggerForwarder{
+		client:  client,
+		target:  target,
+		apiKey:  apiKey,
+		tags:    tags,
+		maxSize: maxSize,
+		queue:   make(chan *debuggerPayload, queueSize),
+		errlog:  logutil.NewThrottled(5, 10*time.Second), // limit to 5 messages every 10 seconds
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// start starts the given number of workers forwarding the queued payloads.
+func (f *debuggerForwarder) start(workers int) {
+	for i := 0; i < workers; i++ {
+		f.wg.Add(1)
+		go func() {
+			defer watchdog.LogOnPanic()
+			defer f.wg.Done()
+			for p := range f.queue {
+				if f.ctx.Err() != nil {
+					// stopping took too long
+					atomic.AddInt64(&f.dropped, 1)
+					continue
+				}
+				f.forward(p)
+			}
+		}()
+	}
+}
+
+// stop forwards the payloads left in the queue and stops the workers. It must be called once
+// no more requests are served. The payloads which are not forwarded within debuggerStopTimeout
+// are dropped.
+func (f *debuggerForwarder) stop() {
+	f.stopWithin(debuggerStopTimeout)
+}
+
+// stopWithin is stop, dropping the payloads not forwarded within the given timeout.
+func (f *debuggerForwarder) stopWithin(timeout time.Duration) {
+	close(f.queue)
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		log.Warnf("Timed out forwarding the debugger snapshots left, dropping %d payloads", len(f.queue))
+		f.cancel()
+		<-done
+	}
+	f.cancel()
+}
+
+// ServeHTTP implements http.Handler.
+func (f *debuggerForwarder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	atomic.AddInt64(&f.received, 1)
+	if f.maxSize > 0 && req.ContentLength > f.maxSize {
+		atomic.AddInt64(&f.rejected, 1)
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var body io.Reader = req.Body
+	if f.maxSize > 0 {
+		body = NewLimitedReader(req.Body, f.maxSize)
+	}
+	slurp, err := ioutil.ReadAll(body)
+	if err != nil {
+		atomic.AddInt64(&f.rejected, 1)
+		if err == ErrLimitedReaderLimitReached {
+			http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := &debuggerPayload{
+		body:          slurp,
+		contentType:   req.Header.Get("Content-Type"),
+		containerTags: getContainerTags(req.Header.Get(headerContainerID)),
+	}
+	select {
+	case f.queue <- p:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		atomic.AddInt64(&f.dropped, 1)
+		http.Error(w, "debugger queue full", http.StatusTooManyRequests)
+	}
+}
+
+// forward sends the payload p to the intake.
+func (f *debuggerForwarder) forward(p *debuggerPayload) {
+	u := *f.target
+	q := u.Query()
+	q.Set("ddtags", f.tags)
+	u.RawQuery = q.Encode()
+	ctx, cancel := context.WithTimeout(f.ctx, debuggerForwardTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(p.body))
+	if err != nil {
+		atomic.AddInt64(&f.errors, 1)
+		f.errlog.Error("Failed to create the debugger intake request: %v", err)
+		return
+	}
+	req.Header.Set("DD-API-KEY", f.apiKey)
+	req.Header.Set("Via", fmt.Sprintf("trace-agent %s", info.Version))
+	// explicitly disable User-Agent so it's not set to the default value
+	req.Header.Set("User-Agent", "")
+	if p.contentType != "" {
+		req.Header.Set("Content-Type", p.contentType)
+	}
+	if p.containerTags != "" {
+		req.Header.Set("X-Datadog-Container-Tags", p.containerTags)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		atomic.AddInt64(&f.errors, 1)
+		f.errlog.Error("Failed to forward the debugger snapshots: %v", err)
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body) //nolint:errcheck
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		atomic.AddInt64(&f.errors, 1)
+		f.errlog.Error("Failed to forward the debugger snapshots: the intake responded %s", resp.Status)
+		return
+	}
+	atomic.AddInt64(&f.sent, 1)
+	atomic.AddInt64(&f.bytes, int64(len(p.body)))
+	log.Tracef("Forwarded %d bytes of debugger snapshots", len(p.body))
+}
+
+// report submits the metrics about the forwarded payloads.
+func (f *debuggerForwarder) report() {
+	metrics.Count("datadog.trace_agent.debugger.received", atomic.SwapInt64(&f.received, 0), nil, 1)
+	metrics.Count("datadog.trace_agent.debugger.rejected", atomic.SwapInt64(&f.rejected, 0), nil, 1)
+	metrics.Count("datadog.trace_agent.debugger.dropped", atomic.SwapInt64(&f.dropped, 0), nil, 1)
+	metrics.Count("datadog.trace_agent.debugger.sent", atomic.SwapInt64(&f.sent, 0), nil, 1)
+	metrics.Count("datadog.trace_agent.debugger.errors", atomic.SwapInt64(&f.errors, 0), nil, 1)
+	metrics.Count("datadog.trace_agent.debugger.bytes", atomic.SwapInt64(&f.bytes, 0), nil, 1)
+	metrics.Gauge("datadog.trace_agent.debugger.queue_fill", float64(len(f.queue))/float64(cap(f.queue)), nil, 1)
+}
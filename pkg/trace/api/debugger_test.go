@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDebuggerForwarder(t *testing.T, rt http.RoundTripper, queueSize int, maxSize int64) *debuggerForwarder {
+	u, err := url.Parse(debuggerURLDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newDebuggerForwarder(&http.Client{Transport: rt}, u, "123", "host:myhost,default_env:prod", queueSize, maxSize)
+}
+
+func newDebuggerRequest(t *testing.T, body string) *http.Request {
+	req, err := http.NewRequest("POST", debuggerPath, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// blockingTransport is an http.RoundTripper blocking until the requests are cancelled.
+type blockingTransport struct{}
+
+func (blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestDebuggerForwarder(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		assert := assert.New(t)
+		rt := &recordingTransport{}
+		f := newTestDebuggerForwarder(t, rt, 10, 1024)
+		f.start(2)
+		rec := httptest.NewRecorder()
+		f.ServeHTTP(rec, newDebuggerRequest(t, `[{"message":"snapshot"}]`))
+		f.stop()
+
+		assert.Equal(http.StatusAccepted, rec.Code)
+		if !assert.Len(rt.reqs, 1) {
+			return
+		}
+		req := rt.reqs[0]
+		assert.Equal("https://http-intake.logs.datadoghq.com/api/v2/logs?ddtags=host%3Amyhost%2Cdefault_env%3Aprod", req.URL.String())
+		assert.Equal("123", req.Header.Get("DD-API-KEY"))
+		assert.Equal("application/json", req.Header.Get("Content-Type"))
+		assert.Equal(`[{"message":"snapshot"}]`, rt.bodies[0])
+		assert.EqualValues(1, f.sent)
+		assert.EqualValues(len(rt.bodies[0]), f.bytes)
+	})
+
+	t.Run("queue-full", func(t *testing.T) {
+		assert := assert.New(t)
+		rt := &recordingTransport{}
+		f := newTestDebuggerForwarder(t, rt, 1, 1024)
+		// the payloads are only queued until the workers are started
+		rec := httptest.NewRecorder()
+		f.ServeHTTP(rec, newDebuggerRequest(t, "first"))
+		assert.Equal(http.StatusAccepted, rec.Code)
+		rec = httptest.NewRecorder()
+		f.ServeHTTP(rec, newDebuggerRequest(t, "second"))
+		assert.Equal(http.StatusTooManyRequests, rec.Code)
+		assert.EqualValues(2, f.received)
+		assert.EqualValues(1, f.dropped)
+
+		f.start(1)
+		f.stop()
+		assert.Equal([]string{"first"}, rt.bodies)
+	})
+
+	t.Run("stop-timeout", func(t *testing.T) {
+		assert := assert.New(t)
+		f := newTestDebuggerForwarder(t, blockingTransport{}, 10, 1024)
+		for i := 0; i < 3; i++ {
+			f.ServeHTTP(httptest.NewRecorder(), newDebuggerRequest(t, "snapshot"))
+		}
+		f.start(1)
+		start := time.Now()
+		f.stopWithin(50 * time.Millisecond)
+		assert.True(time.Since(start) < debuggerForwardTimeout)
+		// the payload being forwarded fails, the other ones are dropped
+		assert.EqualValues(1, f.errors)
+		assert.EqualValues(2, f.dropped)
+	})
+
+	t.Run("too-large", func(t *testing.T) {
+		assert := assert.New(t)
+		rt := &recordingTransport{}
+		f := newTestDebuggerForwarder(t, rt, 10, 4)
+		f.start(1)
+		rec := httptest.NewRecorder()
+		f.ServeHTTP(rec, newDebuggerRequest(t, "too large"))
+		req := newDebuggerRequest(t, "too large")
+		req.ContentLength = -1 // the limit is also enforced on chunked requests
+		rec2 := httptest.NewRecorder()
+		f.ServeHTTP(rec2, req)
+		f.stop()
+
+		assert.Equal(http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(http.StatusRequestEntityTooLarge, rec2.Code)
+		assert.EqualValues(2, f.rejected)
+		assert.Len(rt.reqs, 0)
+	})
+
+	t.Run("method", func(t *testing.T) {
+		f := newTestDebuggerForwarder(t, &recordingTransport{}, 10, 1024)
+		rec := httptest.NewRecorder()
+		f.ServeHTTP(rec, httptest.NewRequest("GET", debuggerPath, nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
@@ -0,0 +1,201 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	stdlog "log"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/logutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// evpProxyPath is the path prefix of the requests forwarded to the event platform. The
+	// rest of the path is the path of the intake.
+	evpProxyPath = "/evp_proxy/v1"
+	// headerEVPSubdomain specifies the name of the header which contains the subdomain of the
+	// intake the request is forwarded to (e.g. "citestcycle-intake").
+	headerEVPSubdomain = "X-Datadog-EVP-Subdomain"
+	// evpProxySiteDefault specifies the default site of the event platform intakes.
+	evpProxySiteDefault = "datadoghq.com"
+	// evpProxyMaxPayloadSize specifies the default maximum size of the forwarded payloads.
+	evpProxyMaxPayloadSize = 5 * 1024 * 1024
+)
+
+var (
+	// evpSubdomainRegexp matches the valid intake subdomains
+	evpSubdomainRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	// evpPathRegexp matches the valid intake paths
+	evpPathRegexp = regexp.MustCompile(`^[a-zA-Z0-9_/.-]*$`)
+	// evpQueryRegexp matches the valid intake query strings
+	evpQueryRegexp = regexp.MustCompile(`^[a-zA-Z0-9_/.=&,:%-]*$`)
+)
+
+// evpProxyEndpoints returns the sites of the event platform intakes and their corresponding
+// api keys based on agent configuration. The main site is always returned as the first
+// element in the slice.
+func evpProxyEndpoints(apiKey string) (sites []string, apiKeys []string) {
+	main := evpProxySiteDefault
+	if v := config.Datadog.GetString("apm_config.evp_proxy_config.dd_url"); v != "" {
+		main = v
+	} else if site := config.Datadog.GetString("site"); site != "" {
+		main = site
+	}
+	sites = append(sites, main)
+	apiKeys = append(apiKeys, apiKey)
+
+	if opt := "apm_config.evp_proxy_config.additional_endpoints"; config.Datadog.IsSet(opt) {
+		extra := config.Datadog.GetStringMapStringSlice(opt)
+		for site, keys := range extra {
+			for _, key := range keys {
+				sites = append(sites, site)
+				apiKeys = append(apiKeys, config.SanitizeAPIKey(key))
+			}
+		}
+	}
+	return sites, apiKeys
+}
+
+// evpProxyHandler returns a new HTTP handler which will proxy requests to the event platform
+// intakes, or an handler always responding with http.StatusMethodNotAllowed if the proxy is
+// disabled.
+func (r *HTTPReceiver) evpProxyHandler() http.Handler {
+	if config.Datadog.IsSet("apm_config.evp_proxy_config.enabled") && !config.Datadog.GetBool("apm_config.evp_proxy_config.enabled") {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, "EVP proxy is disabled", http.StatusMethodNotAllowed)
+		})
+	}
+	sites, keys := evpProxyEndpoints(r.conf.APIKey())
+	maxSize := int64(evpProxyMaxPayloadSize)
+	if k := "apm_config.evp_proxy_config.max_payload_size"; config.Datadog.IsSet(k) {
+		maxSize = config.Datadog.GetInt64(k)
+	}
+	return newEVPProxy(r.conf.NewHTTPTransport(), sites, keys, r.conf.Hostname, r.conf.DefaultEnv, maxSize)
+}
+
+// newEVPProxy creates an http.Handler forwarding requests to the event platform intakes
+// of one or more sites, along with the given API keys. The subdomain of the intake is given
+// by the client in the X-Datadog-EVP-Subdomain header and its path follows the /evp_proxy/v1
+// prefix, so that tracer libraries can send payloads to new intakes without changes to the
+// agent.
+//
+// Each site must have a corresponding API key in the same position in the keys slice.
+// Requests are tagged with the hostname and the default env of the agent, and the tags of
+// the container they come from.
+func newEVPProxy(transport http.RoundTripper, sites []string, keys []string, hostname, env string, maxSize int64) http.Handler {
+	director := func(req *http.Request) {
+		req.Header.Set("Via", fmt.Sprintf("trace-agent %s", info.Version))
+		if _, ok := req.Header["User-Agent"]; !ok {
+			// explicitly disable User-Agent so it's not set to the default value
+			// that net/http gives it: Go-http-client/1.1
+			// See https://codereview.appspot.com/7532043
+			req.Header.Set("User-Agent", "")
+		}
+		containerID := req.Header.Get(headerContainerID)
+		if ctags := getContainerTags(containerID); ctags != "" {
+			req.Header.Set("X-Datadog-Container-Tags", ctags)
+		}
+		req.Header.Set("X-Datadog-Hostname", hostname)
+		req.Header.Set("X-Datadog-AgentDefaultEnv", env)
+		req.URL.Scheme = "https"
+		req.URL.Path = strings.TrimPrefix(req.URL.Path, evpProxyPath)
+		// host and key are set in the transport for each outbound request
+	}
+	logger := logutil.NewThrottled(5, 10*time.Second) // limit to 5 messages every 10 seconds
+	proxy := &httputil.ReverseProxy{
+		Director:  director,
+		ErrorLog:  stdlog.New(logger, "evp_proxy.Proxy: ", 0),
+		Transport: &evpProxyTransport{transport, sites, keys},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		subdomain := req.Header.Get(headerEVPSubdomain)
+		tags := []string{"subdomain:" + subdomain}
+		if !evpSubdomainRegexp.MatchString(subdomain) {
+			metrics.Count("datadog.trace_agent.evp_proxy.rejected", 1, tags, 1)
+			http.Error(w, fmt.Sprintf("invalid %s header: %q", headerEVPSubdomain, subdomain), http.StatusBadRequest)
+			return
+		}
+		path := strings.TrimPrefix(req.URL.Path, evpProxyPath)
+		if !evpPathRegexp.MatchString(path) || strings.Contains(path, "..") || !evpQueryRegexp.MatchString(req.URL.RawQuery) {
+			metrics.Count("datadog.trace_agent.evp_proxy.rejected", 1, tags, 1)
+			http.Error(w, fmt.Sprintf("invalid intake path: %q", req.URL.RequestURI()), http.StatusBadRequest)
+			return
+		}
+		if maxSize > 0 {
+			if req.ContentLength > maxSize {
+				metrics.Count("datadog.trace_agent.evp_proxy.rejected", 1, tags, 1)
+				http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			req.Body = NewLimitedReader(req.Body, maxSize)
+		}
+		metrics.Count("datadog.trace_agent.evp_proxy.request", 1, tags, 1)
+		proxy.ServeHTTP(w, req)
+	})
+}
+
+// evpProxyTransport sends HTTP requests to the event platform intake of multiple sites
+// using an underlying http.RoundTripper. API keys are set separately for each site.
+// The response from the main site is proxied back to the client, while the responses
+// of the additional sites are discarded.
+type evpProxyTransport struct {
+	rt    http.RoundTripper
+	sites []string
+	keys  []string
+}
+
+func (t *evpProxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	subdomain := req.Header.Get(headerEVPSubdomain)
+	setTarget := func(r *http.Request, site, apiKey string) {
+		host := subdomain + "." + site
+		r.Host = host
+		r.URL.Host = host
+		r.Header.Set("DD-API-KEY", apiKey)
+	}
+	if len(t.sites) == 1 {
+		setTarget(req, t.sites[0], t.keys[0])
+		return t.rt.RoundTrip(req)
+	}
+	slurp, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		rresp *http.Response
+		rerr  error
+	)
+	for i, site := range t.sites {
+		newreq := req.Clone(req.Context())
+		newreq.Body = ioutil.NopCloser(bytes.NewReader(slurp))
+		setTarget(newreq, site, t.keys[i])
+		if i == 0 {
+			// the main site is the first one, we return its response and error
+			rresp, rerr = t.rt.RoundTrip(newreq)
+			continue
+		}
+
+		if resp, err := t.rt.RoundTrip(newreq); err == nil {
+			// we discard responses for all subsequent requests
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		} else {
+			log.Error(err)
+		}
+	}
+	return rresp, rerr
+}
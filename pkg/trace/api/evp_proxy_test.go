@@ -0,0 +1,154 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingTransport is an http.RoundTripper recording the requests it receives and
+// responding with 200 OK
+type recordingTransport struct {
+	mu     sync.Mutex
+	reqs   []*http.Request
+	bodies []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.reqs = append(t.reqs, req)
+	t.bodies = append(t.bodies, string(body))
+	t.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte("OK"))),
+		Header:     http.Header{},
+		Request:    req,
+	}, nil
+}
+
+func newEVPRequest(t *testing.T, path, subdomain, body string) *http.Request {
+	req, err := http.NewRequest("POST", path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subdomain != "" {
+		req.Header.Set(headerEVPSubdomain, subdomain)
+	}
+	return req
+}
+
+func TestEVPProxy(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		assert := assert.New(t)
+		rt := &recordingTransport{}
+		proxy := newEVPProxy(rt, []string{"datadoghq.eu"}, []string{"123"}, "myhost", "prod", 1024)
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, newEVPRequest(t, "/evp_proxy/v1/api/v2/citestcycle?a=b", "citestcycle-intake", "body"))
+
+		assert.Equal(http.StatusOK, rec.Code)
+		if !assert.Len(rt.reqs, 1) {
+			return
+		}
+		req := rt.reqs[0]
+		assert.Equal("https://citestcycle-intake.datadoghq.eu/api/v2/citestcycle?a=b", req.URL.String())
+		assert.Equal("citestcycle-intake.datadoghq.eu", req.Host)
+		assert.Equal("123", req.Header.Get("DD-API-KEY"))
+		assert.Equal("myhost", req.Header.Get("X-Datadog-Hostname"))
+		assert.Equal("prod", req.Header.Get("X-Datadog-AgentDefaultEnv"))
+		assert.Equal("body", rt.bodies[0])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, req := range map[string]*http.Request{
+			"no-subdomain":      newEVPRequest(t, "/evp_proxy/v1/api/v2/citestcycle", "", "body"),
+			"invalid-subdomain": newEVPRequest(t, "/evp_proxy/v1/api/v2/citestcycle", "evil.com/", "body"),
+			"invalid-path":      newEVPRequest(t, "/evp_proxy/v1/api/v2/../../citestcycle", "citestcycle-intake", "body"),
+			"invalid-query":     newEVPRequest(t, "/evp_proxy/v1/api/v2/citestcycle?a=<b>", "citestcycle-intake", "body"),
+		} {
+			t.Run(name, func(t *testing.T) {
+				rt := &recordingTransport{}
+				rec := httptest.NewRecorder()
+				newEVPProxy(rt, []string{"datadoghq.com"}, []string{"123"}, "myhost", "", 1024).ServeHTTP(rec, req)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Empty(t, rt.reqs)
+			})
+		}
+	})
+
+	t.Run("too-large", func(t *testing.T) {
+		rt := &recordingTransport{}
+		rec := httptest.NewRecorder()
+		newEVPProxy(rt, []string{"datadoghq.com"}, []string{"123"}, "myhost", "", 3).ServeHTTP(rec, newEVPRequest(t, "/evp_proxy/v1/api/v2/citestcycle", "citestcycle-intake", "body"))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Empty(t, rt.reqs)
+	})
+
+	t.Run("multiple-sites", func(t *testing.T) {
+		assert := assert.New(t)
+		rt := &recordingTransport{}
+		proxy := newEVPProxy(rt, []string{"datadoghq.com", "datadoghq.eu", "datadoghq.eu"}, []string{"123", "456", "789"}, "myhost", "", 1024)
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, newEVPRequest(t, "/evp_proxy/v1/api/v2/citestcycle", "citestcycle-intake", "body"))
+
+		assert.Equal(http.StatusOK, rec.Code)
+		called := make(map[string]string)
+		for i, req := range rt.reqs {
+			called[req.Host+"|"+req.Header.Get("DD-API-KEY")] = rt.bodies[i]
+		}
+		assert.Equal(map[string]string{
+			"citestcycle-intake.datadoghq.com|123": "body",
+			"citestcycle-intake.datadoghq.eu|456":  "body",
+			"citestcycle-intake.datadoghq.eu|789":  "body",
+		}, called)
+	})
+}
+
+func TestEVPProxyEndpoints(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		defer mockConfig("site", "")()
+		sites, keys := evpProxyEndpoints("test_api_key")
+		assert.Equal(t, []string{"datadoghq.com"}, sites)
+		assert.Equal(t, []string{"test_api_key"}, keys)
+	})
+
+	t.Run("site", func(t *testing.T) {
+		defer mockConfig("site", "datadoghq.eu")()
+		sites, _ := evpProxyEndpoints("test_api_key")
+		assert.Equal(t, []string{"datadoghq.eu"}, sites)
+	})
+
+	t.Run("dd_url", func(t *testing.T) {
+		defer mockConfigMap(map[string]interface{}{
+			"site":                               "datadoghq.eu",
+			"apm_config.evp_proxy_config.dd_url": "us3.datadoghq.com",
+		})()
+		sites, _ := evpProxyEndpoints("test_api_key")
+		assert.Equal(t, []string{"us3.datadoghq.com"}, sites)
+	})
+
+	t.Run("additional_endpoints", func(t *testing.T) {
+		defer mockConfigMap(map[string]interface{}{
+			"apm_config.evp_proxy_config.additional_endpoints": map[string][]string{
+				"datadoghq.eu": {"key1", "key2"},
+			},
+		})()
+		sites, keys := evpProxyEndpoints("test_api_key")
+		assert.Equal(t, []string{"datadoghq.com", "datadoghq.eu", "datadoghq.eu"}, sites)
+		assert.Equal(t, []string{"test_api_key", "key1", "key2"}, keys)
+	})
+}
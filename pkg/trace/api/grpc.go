@@ -0,0 +1,199 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/logutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// grpcEndpointVersion is the endpoint version under which the stats of the traces received
+// on the gRPC intake are reported.
+const grpcEndpointVersion = "grpc"
+
+// grpcIntake implements the TraceIntake gRPC service, through which tracers stream their
+// payloads over a persistent connection instead of posting them to the HTTP endpoints.
+type grpcIntake struct {
+	r      *HTTPReceiver
+	logger *logutil.ThrottledLogger
+}
+
+// SendTraces implements pb.TraceIntakeServer. Each payload received on the stream is
+// answered with the recommended sampling rates of the services.
+func (g *grpcIntake) SendTraces(stream pb.TraceIntake_SendTracesServer) error {
+	r := g.r
+	r.wg.Add(1)
+	defer r.wg.Done()
+
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if !grpcAuthorized(stream.Context(), md, r.conf.ReceiverAuthToken) {
+		g.logger.Warn("Rejecting unauthenticated gRPC stream from %s", grpcPeerAddr(stream.Context()))
+		metrics.Count(receiverErrorKey, 1, []string{"error:unauthorized"}, 1)
+		return status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+	}
+	// the metadata of the stream holds the same headers as the HTTP requests
+	interpreter := metadataGet(md, headerLangInterpreter)
+	vendor := metadataGet(md, headerLangInterpreterVendor)
+	clientComputedTopLevel := metadataGet(md, headerComputedTopLevel) != ""
+	clientComputedStats := metadataGet(md, headerComputedStats) != ""
+	transport := grpcTransport(stream.Context())
+	header := metadataHeader(md)
+
+	for {
+		tp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if status.Code(err) != codes.Canceled {
+				log.Debugf("Stopped receiving traces from the gRPC stream of %s: %v", grpcPeerAddr(stream.Context()), err)
+			}
+			return err
+		}
+
+		ts := r.Stats.GetTagStats(info.Tags{
+			Lang:            tp.LanguageName,
+			LangVersion:     tp.LanguageVersion,
+			Interpreter:     interpreter,
+			LangVendor:      vendor,
+			TracerVersion:   tp.TracerVersion,
+			EndpointVersion: grpcEndpointVersion,
+			Transport:       transport,
+		})
+		if err := r.checkTracerVersion(tp.LanguageName, tp.TracerVersion); err != nil {
+			atomic.AddInt64(&ts.TracesDropped.OutdatedTracer, int64(len(tp.Chunks)))
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		resp := &pb.IntakeResponse{RateByService: r.dynConf.RateByService.GetAll()}
+		if r.rateLimited(payloadOrigin("", grpcPeerAddr(stream.Context())), int64(len(tp.Chunks))) {
+			atomic.AddInt64(&ts.PayloadRefused, 1)
+			resp.Refused = true
+		} else {
+			atomic.AddInt64(&ts.TracesReceived, int64(len(tp.Chunks)))
+			atomic.AddInt64(&ts.TracesBytes, int64(tp.Size()))
+			atomic.AddInt64(&ts.PayloadAccepted, 1)
+			r.sendPayload(&Payload{
+				Source:                 ts,
+				TracerPayload:          tp,
+				ContainerTags:          getContainerTags(tp.ContainerID),
+				ClientComputedTopLevel: clientComputedTopLevel,
+				ClientComputedStats:    clientComputedStats,
+			}, header)
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// metadataHeader returns the metadata md as HTTP headers, for the payload transformers.
+func metadataHeader(md metadata.MD) http.Header {
+	header := make(http.Header, len(md))
+	for k, v := range md {
+		header[http.CanonicalHeaderKey(k)] = v
+	}
+	return header
+}
+
+// metadataGet returns the first value of the given key in md.
+func metadataGet(md metadata.MD, key string) string {
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// grpcPeerAddr returns the address of the client of the stream with the given context.
+func grpcPeerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return "unknown"
+}
+
+// grpcTransport returns the transport of the stream with the given context, or an empty
+// string when it is unknown.
+func grpcTransport(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return transport(p.Addr.Network())
+	}
+	return ""
+}
+
+// grpcAuthorized reports whether the stream with the given context and metadata is allowed,
+// applying the same rules as authHandler: streams which are not local need to hold the shared
+// token in their "authorization: Bearer <token>" metadata.
+func grpcAuthorized(ctx context.Context, md metadata.MD, token string) bool {
+	if token == "" {
+		return true
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if !strings.HasPrefix(p.Addr.Network(), "tcp") {
+			return true
+		}
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+				return true
+			}
+		}
+	}
+	const prefix = "Bearer "
+	auth := metadataGet(md, "authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return false
+	}
+	got := strings.TrimSpace(auth[len(prefix):])
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// listenGRPC starts serving the gRPC intake on the given TCP address, along with the OTLP
+// trace service and the OTLP logs and metrics services, which reject or forward their requests.
+func (r *HTTPReceiver) listenGRPC(addr string) error {
+	ln, err := r.listenTCP(addr)
+	if err != nil {
+		return err
+	}
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(int(r.conf.MaxRequestBytes))}
+	if r.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(r.tlsConfig)))
+	}
+	r.grpcServer = grpc.NewServer(opts...)
+	pb.RegisterTraceIntakeServer(r.grpcServer, &grpcIntake{
+		r:      r,
+		logger: logutil.NewThrottled(5, 10*time.Second), // limit to 5 messages every 10 seconds
+	})
+	r.grpcServer.RegisterService(&otlpServiceDesc, &otlpIntake{r: r})
+	for _, s := range []*otlpSignal{otlpLogs, otlpMetrics} {
+		r.grpcServer.RegisterService(otlpSignalServiceDesc(s), &otlpIntake{r: r})
+	}
+	go func() {
+		defer watchdog.LogOnPanic()
+		if err := r.grpcServer.Serve(ln); err != nil {
+			log.Errorf("Stopped serving the gRPC intake: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
+)
+
+func TestGRPCIntake(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestReceiverFromConfig(newTestReceiverConfig())
+	r.dynConf.RateByService.SetAll(map[sampler.ServiceSignature]float64{{Name: "web", Env: "prod"}: 0.5})
+	assert.NoError(r.listenGRPC("127.0.0.1:0"))
+	defer r.grpcServer.Stop()
+
+	conn, err := grpc.Dial(r.listeners[0].Addr().String(), grpc.WithInsecure())
+	assert.NoError(err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, headerLangInterpreter, "CPython")
+	stream, err := pb.NewTraceIntakeClient(conn).SendTraces(ctx)
+	assert.NoError(err)
+
+	for i := 0; i < 2; i++ {
+		err := stream.Send(&pb.TracerPayload{
+			ContainerID:     "abcdef",
+			LanguageName:    "python",
+			LanguageVersion: "3.8.1",
+			TracerVersion:   "0.44.0",
+			Chunks: []*pb.TraceChunk{
+				{Spans: testutil.RandomTrace(3, 1)},
+				{Spans: testutil.RandomTrace(2, 1)},
+			},
+		})
+		assert.NoError(err)
+
+		resp, err := stream.Recv()
+		assert.NoError(err)
+		assert.False(resp.Refused)
+		assert.Equal(map[string]float64{"service:web,env:prod": 0.5}, resp.RateByService)
+
+		select {
+		case p := <-r.out:
+			assert.Equal("abcdef", p.TracerPayload.ContainerID)
+			assert.Equal("python", p.TracerPayload.LanguageName)
+			assert.Len(p.TracerPayload.Chunks, 2)
+			assert.Len(p.TracerPayload.Chunks[0].Spans, 3)
+		case <-time.After(time.Second):
+			t.Fatal("no payload received")
+		}
+	}
+	assert.NoError(stream.CloseSend())
+
+	ts := r.Stats.GetTagStats(info.Tags{
+		Lang:            "python",
+		LangVersion:     "3.8.1",
+		Interpreter:     "CPython",
+		TracerVersion:   "0.44.0",
+		EndpointVersion: grpcEndpointVersion,
+		Transport:       "tcp",
+	})
+	assert.EqualValues(4, ts.TracesReceived)
+	assert.EqualValues(2, ts.PayloadAccepted)
+}
+
+func TestGRPCAuthorized(t *testing.T) {
+	assert := assert.New(t)
+	remote := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4242}})
+	local := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4242}})
+	withToken := metadata.Pairs("authorization", "Bearer secret")
+
+	assert.True(grpcAuthorized(remote, nil, ""))
+	assert.True(grpcAuthorized(local, nil, "secret"))
+	assert.True(grpcAuthorized(remote, withToken, "secret"))
+	assert.False(grpcAuthorized(remote, nil, "secret"))
+	assert.False(grpcAuthorized(remote, metadata.Pairs("authorization", "Bearer other"), "secret"))
+}
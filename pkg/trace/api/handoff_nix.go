@@ -0,0 +1,273 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build !windows
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// listenFDsStart is the first file descriptor passed to a process using the systemd
+	// socket activation protocol, see sd_listen_fds(3).
+	listenFDsStart = 3
+
+	// handoffFDEnv specifies the environment variable holding the file descriptor a process
+	// started by a handoff writes to once it is ready to serve.
+	handoffFDEnv = "DD_APM_RECEIVER_HANDOFF_FD"
+
+	// handoffTimeout is how long to wait for a replacement process to be ready.
+	handoffTimeout = 30 * time.Second
+)
+
+var (
+	inheritedOnce sync.Once
+	inherited     []net.Listener
+
+	// handedOff is non-zero once the listeners have been handed off to another process.
+	handedOff uint32
+)
+
+// inheritedListeners returns the listeners passed by systemd socket activation or by
+// the process which handed off its listeners to this one.
+func inheritedListeners() []net.Listener {
+	inheritedOnce.Do(func() {
+		defer os.Unsetenv("LISTEN_PID")
+		defer os.Unsetenv("LISTEN_FDS")
+		defer os.Unsetenv("LISTEN_FDNAMES")
+
+		if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+			// meant for another process
+			return
+		}
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || n <= 0 {
+			return
+		}
+		for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+			syscall.CloseOnExec(fd)
+			f := os.NewFile(uintptr(fd), "listener-"+strconv.Itoa(fd))
+			ln, err := net.FileListener(f)
+			f.Close()
+			if err != nil {
+				log.Warnf("Ignoring inherited file descriptor %d: %v", fd, err)
+				continue
+			}
+			log.Debugf("Inherited listener on %s://%s", ln.Addr().Network(), ln.Addr())
+			inherited = append(inherited, ln)
+		}
+	})
+	return inherited
+}
+
+// inheritedListener returns the inherited listener for the given network and address, or nil.
+func inheritedListener(network, addr string) net.Listener {
+	for _, ln := range inheritedListeners() {
+		if ln.Addr().Network() != network {
+			continue
+		}
+		switch network {
+		case "unix":
+			if ln.Addr().String() == addr {
+				return ln
+			}
+		case "tcp":
+			want, err := net.ResolveTCPAddr(network, addr)
+			if err != nil {
+				return nil
+			}
+			got := ln.Addr().(*net.TCPAddr)
+			if got.Port == want.Port && (got.IP.Equal(want.IP) || (want.IP == nil && got.IP.IsUnspecified())) {
+				return ln
+			}
+		}
+	}
+	return nil
+}
+
+// reusePortControl sets SO_REUSEPORT on the socket of a listener, allowing another process to
+// listen on the same port, such as the agent replacing this one during an upgrade.
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	var opErr error
+	err := c.Control(func(fd uintptr) {
+		opErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
+
+// notifyHandoffReady tells the process which handed off its listeners to this one that
+// this process is now serving, so that it can drain its connections and exit.
+func notifyHandoffReady() {
+	v := os.Getenv(handoffFDEnv)
+	if v == "" {
+		return
+	}
+	os.Unsetenv(handoffFDEnv)
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		log.Warnf("Invalid %s: %q", handoffFDEnv, v)
+		return
+	}
+	f := os.NewFile(uintptr(fd), "handoff")
+	defer f.Close()
+	if _, err := f.Write([]byte{1}); err != nil {
+		log.Warnf("Failed to notify the previous process of the handoff: %v", err)
+	}
+}
+
+// ReceivingHandoff reports whether this process was started by another one handing off its
+// listeners, and which exits once this one is ready.
+func ReceivingHandoff() bool {
+	return os.Getenv(handoffFDEnv) != ""
+}
+
+// HandedOff reports whether the receiver listeners were handed off to another process.
+func HandedOff() bool {
+	return atomic.LoadUint32(&handedOff) != 0
+}
+
+// handoffOnSignal hands off the listeners to a new agent process when SIGUSR2 is received,
+// then stops this process with SIGTERM so that it drains its connections.
+func (r *HTTPReceiver) handoffOnSignal() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGUSR2)
+	defer signal.Stop(sigChan)
+	for {
+		select {
+		case <-sigChan:
+			log.Info("Received SIGUSR2, handing off listeners to a new process")
+			if err := r.handoff(); err != nil {
+				log.Errorf("Listener handoff failed, keeping on serving: %v", err)
+				continue
+			}
+			log.Info("Listeners handed off, draining connections and exiting")
+			syscall.Kill(os.Getpid(), syscall.SIGTERM) //nolint:errcheck
+			return
+		case <-r.handoffExit:
+			return
+		}
+	}
+}
+
+// handoff starts a new agent process, using the same executable and arguments, which serves
+// on the listeners of this one. It returns once the new process is ready to serve.
+func (r *HTTPReceiver) handoff() error {
+	r.listenersMu.Lock()
+	defer r.listenersMu.Unlock()
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, ln := range r.listeners {
+		fl, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("can not hand off listener on %s", ln.Addr())
+		}
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return errors.New("no listeners")
+	}
+
+	ready, notify, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		notify.Close()
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, notify)
+	cmd.Env = append(handoffEnviron(),
+		"LISTEN_FDS="+strconv.Itoa(len(files)),
+		handoffFDEnv+"="+strconv.Itoa(listenFDsStart+len(files)),
+	)
+	err = cmd.Start()
+	notify.Close()
+	if err != nil {
+		return err
+	}
+
+	readyCh := make(chan error, 1)
+	go func() {
+		defer watchdog.LogOnPanic()
+		b := make([]byte, 1)
+		_, err := ready.Read(b)
+		readyCh <- err
+	}()
+	select {
+	case err := <-readyCh:
+		if err != nil {
+			// the pipe was closed without notification: the process exited
+			cmd.Process.Kill() //nolint:errcheck
+			cmd.Wait()         //nolint:errcheck
+			return fmt.Errorf("new process did not start: %v", err)
+		}
+	case <-time.After(handoffTimeout):
+		cmd.Process.Kill() //nolint:errcheck
+		cmd.Wait()         //nolint:errcheck
+		return fmt.Errorf("new process not ready after %s", handoffTimeout)
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release() //nolint:errcheck
+
+	for _, ln := range r.listeners {
+		if ul, ok := ln.(*net.UnixListener); ok {
+			// the socket file is now used by the new process
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	atomic.StoreUint32(&handedOff, 1)
+	log.Infof("Listeners handed off to process %d", pid)
+	return nil
+}
+
+// handoffEnviron returns the environment of the current process, without the variables
+// used to pass listeners.
+func handoffEnviron() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		switch strings.SplitN(kv, "=", 2)[0] {
+		case "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", handoffFDEnv:
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build !windows
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReusePort(t *testing.T) {
+	lc := net.ListenConfig{Control: reusePortControl}
+	ln1, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ln1.Close()
+
+	// a second listener can use the same port
+	ln2, err := lc.Listen(context.Background(), "tcp", ln1.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	ln2.Close()
+
+	// but not without SO_REUSEPORT
+	_, err = net.Listen("tcp", ln1.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestInheritedListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "handoff")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	tcpln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer tcpln.Close()
+	sock := filepath.Join(dir, "apm.socket")
+	unixln, err := net.Listen("unix", sock)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer unixln.Close()
+
+	inheritedOnce.Do(func() {})
+	defer func(old []net.Listener) { inherited = old }(inherited)
+	inherited = []net.Listener{tcpln, unixln}
+
+	port := tcpln.Addr().(*net.TCPAddr).Port
+	assert.Equal(t, tcpln, inheritedListener("tcp", fmt.Sprintf("127.0.0.1:%d", port)))
+	assert.Nil(t, inheritedListener("tcp", fmt.Sprintf("127.0.0.1:%d", port+1)))
+	assert.Nil(t, inheritedListener("tcp", fmt.Sprintf("127.0.0.2:%d", port)))
+	assert.Equal(t, unixln, inheritedListener("unix", sock))
+	assert.Nil(t, inheritedListener("unix", filepath.Join(dir, "other.socket")))
+
+	t.Run("receiver", func(t *testing.T) {
+		conf := newTestReceiverConfig()
+		conf.ReceiverPort = port
+		conf.ReceiverSocket = sock
+		r := newTestReceiverFromConfig(conf)
+
+		ln, err := r.listenTCP(fmt.Sprintf("127.0.0.1:%d", port))
+		assert.NoError(t, err)
+		assert.Equal(t, tcpln, ln)
+		ln, err = r.listenUnix(sock)
+		assert.NoError(t, err)
+		assert.Equal(t, unixln, ln)
+		assert.Equal(t, []net.Listener{tcpln, unixln}, r.listeners)
+	})
+}
+
+func TestHandoffEnviron(t *testing.T) {
+	for k, v := range map[string]string{
+		"LISTEN_FDS": "2",
+		handoffFDEnv: "5",
+		"DD_API_KEY": "test",
+		"LISTEN_PID": "1",
+	} {
+		old, ok := os.LookupEnv(k)
+		defer func(k string) {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		}(k)
+		os.Setenv(k, v)
+	}
+
+	env := handoffEnviron()
+	assert.Contains(t, env, "DD_API_KEY=test")
+	for _, kv := range env {
+		assert.NotRegexp(t, "^(LISTEN_FDS|LISTEN_PID|"+handoffFDEnv+")=", kv)
+	}
+}
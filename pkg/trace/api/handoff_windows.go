@@ -0,0 +1,29 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"net"
+	"syscall"
+)
+
+// Listener handoff is not supported on Windows.
+
+func inheritedListener(_, _ string) net.Listener { return nil }
+
+func reusePortControl(_, _ string, _ syscall.RawConn) error { return nil }
+
+func notifyHandoffReady() {}
+
+// ReceivingHandoff reports whether this process was started by another one handing off its
+// listeners. It is always false on Windows.
+func ReceivingHandoff() bool { return false }
+
+// HandedOff reports whether the receiver listeners were handed off to another process. It is
+// always false on Windows.
+func HandedOff() bool { return false }
+
+func (r *HTTPReceiver) handoffOnSignal() {}
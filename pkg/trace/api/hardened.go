@@ -0,0 +1,271 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/logutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+)
+
+// Reasons for rejecting requests in hardened mode, reported in the "reason" tag of the
+// rejected requests count.
+const (
+	reasonInvalidRequestLine = "invalid_request_line"
+	reasonBareLF             = "bare_lf"
+	reasonLineFolding        = "line_folding"
+	reasonInvalidHeader      = "invalid_header"
+	reasonMultipleHost       = "multiple_host"
+	reasonMultipleLength     = "multiple_content_length"
+	reasonInvalidLength      = "invalid_content_length"
+	reasonInvalidEncoding    = "invalid_transfer_encoding"
+	reasonLengthAndEncoding  = "content_length_and_transfer_encoding"
+	reasonHeaderTooLarge     = "header_too_large"
+	reasonUnverifiedRequest  = "unverified"
+)
+
+// rejectedRequestsKey is the metric counting the requests rejected in hardened mode.
+const rejectedRequestsKey = "datadog.trace_agent.receiver.rejected_requests"
+
+// hardenedConnKey is the context key holding the *hardenedConn a request was received on.
+type hardenedConnKey struct{}
+
+// hardenedListener wraps a net.Listener, validating the raw headers of the requests received
+// on the connections it accepts. Validation has to happen before net/http parses them, because
+// it silently normalizes some of the ambiguities, such as removing the Content-Length header of
+// chunked requests.
+type hardenedListener struct {
+	net.Listener
+	maxHeaderBytes int
+}
+
+// newHardenedListener returns a listener validating the requests received on ln, rejecting
+// those having headers larger than maxHeaderBytes.
+func newHardenedListener(ln net.Listener, maxHeaderBytes int) net.Listener {
+	return &hardenedListener{Listener: ln, maxHeaderBytes: maxHeaderBytes}
+}
+
+// Accept implements net.Listener.
+func (ln *hardenedListener) Accept() (net.Conn, error) {
+	conn, err := ln.Listener.Accept()
+	if err != nil {
+		return conn, err
+	}
+	return &hardenedConn{Conn: conn, maxHeaderBytes: ln.maxHeaderBytes}, nil
+}
+
+// hardenedConn follows the framing of the requests read from a connection and validates their
+// headers. The result of the validation of each request is queued until it is served by
+// hardenedHandler, in order, as net/http serves the requests of a connection sequentially.
+type hardenedConn struct {
+	net.Conn
+	maxHeaderBytes int
+
+	mu       sync.Mutex
+	header   []byte   // header of the request being read
+	line     int      // offset of the line being read in header
+	skip     int64    // bytes left in the body of the request being read
+	blind    bool     // true once the framing of the following requests can't be followed
+	verdicts []string // rejection reasons of the requests not served yet, empty when valid
+}
+
+// Read implements net.Conn.
+func (c *hardenedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	c.inspect(p[:n])
+	c.mu.Unlock()
+	return n, err
+}
+
+// inspect consumes data read from the connection.
+func (c *hardenedConn) inspect(data []byte) {
+	for len(data) > 0 && !c.blind {
+		if c.skip > 0 {
+			n := c.skip
+			if n > int64(len(data)) {
+				n = int64(len(data))
+			}
+			c.skip -= n
+			data = data[n:]
+			continue
+		}
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i+1]
+		}
+		data = data[len(line):]
+		c.header = append(c.header, line...)
+		if len(c.header) > c.maxHeaderBytes {
+			c.reject(reasonHeaderTooLarge)
+			return
+		}
+		if c.header[len(c.header)-1] != '\n' {
+			// incomplete line, wait for more data
+			continue
+		}
+		if len(bytes.TrimRight(c.header[c.line:], "\r\n")) > 0 {
+			c.line = len(c.header)
+			continue
+		}
+		if c.line == 0 {
+			// empty lines preceding the request line are ignored
+			c.header = c.header[:0]
+			continue
+		}
+		reason, length, chunked := validateRequestHeader(c.header)
+		c.header, c.line = c.header[:0], 0
+		if reason != "" {
+			c.reject(reason)
+			return
+		}
+		c.verdicts = append(c.verdicts, "")
+		if chunked {
+			// the connection is closed after serving a chunked request, see hardenedHandler
+			c.blind = true
+			return
+		}
+		c.skip = length
+	}
+}
+
+// reject queues the rejection of the request being read. The connection is closed after the
+// rejected request is served, the following requests don't need to be followed.
+func (c *hardenedConn) reject(reason string) {
+	metrics.Count(rejectedRequestsKey, 1, []string{"reason:" + reason}, 1)
+	c.verdicts = append(c.verdicts, reason)
+	c.header = nil
+	c.blind = true
+}
+
+// nextVerdict returns the rejection reason of the next request to serve, which is empty if it
+// is valid. ok is false if the request couldn't be validated.
+func (c *hardenedConn) nextVerdict() (reason string, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.verdicts) == 0 {
+		return "", false
+	}
+	reason = c.verdicts[0]
+	c.verdicts = c.verdicts[1:]
+	return reason, true
+}
+
+// validateRequestHeader validates the request line and header fields of a request, which are
+// expected to be terminated by an empty line. It returns the reason for rejecting them, if any,
+// as well as the length of the request body, or whether it is chunked.
+func validateRequestHeader(header []byte) (reason string, length int64, chunked bool) {
+	var (
+		http10             bool
+		lengths, encodings []string
+		hosts              int
+		requestLine        = true
+		lines              = bytes.SplitAfter(header, []byte("\n"))
+	)
+	for _, line := range lines[:len(lines)-1] {
+		if !bytes.HasSuffix(line, []byte("\r\n")) {
+			return reasonBareLF, 0, false
+		}
+		line = line[:len(line)-2]
+		if requestLine {
+			requestLine = false
+			parts := strings.Split(string(line), " ")
+			if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+				return reasonInvalidRequestLine, 0, false
+			}
+			switch parts[2] {
+			case "HTTP/1.1":
+			case "HTTP/1.0":
+				http10 = true
+			default:
+				return reasonInvalidRequestLine, 0, false
+			}
+			continue
+		}
+		if len(line) == 0 {
+			break
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return reasonLineFolding, 0, false
+		}
+		i := bytes.IndexByte(line, ':')
+		if i <= 0 || bytes.ContainsAny(line[:i], " \t") {
+			return reasonInvalidHeader, 0, false
+		}
+		value := strings.TrimSpace(string(line[i+1:]))
+		switch http.CanonicalHeaderKey(string(line[:i])) {
+		case "Content-Length":
+			lengths = append(lengths, value)
+		case "Transfer-Encoding":
+			encodings = append(encodings, value)
+		case "Host":
+			hosts++
+		}
+	}
+	switch {
+	case hosts > 1:
+		return reasonMultipleHost, 0, false
+	case len(lengths) > 0 && len(encodings) > 0:
+		return reasonLengthAndEncoding, 0, false
+	case len(lengths) > 1:
+		return reasonMultipleLength, 0, false
+	case len(encodings) > 1, len(encodings) == 1 && (http10 || !strings.EqualFold(encodings[0], "chunked")):
+		return reasonInvalidEncoding, 0, false
+	case len(encodings) == 1:
+		return "", 0, true
+	case len(lengths) == 1:
+		if strings.Trim(lengths[0], "0123456789") != "" {
+			return reasonInvalidLength, 0, false
+		}
+		n, err := strconv.ParseInt(lengths[0], 10, 64)
+		if err != nil {
+			return reasonInvalidLength, 0, false
+		}
+		return "", n, false
+	}
+	return "", 0, false
+}
+
+// hardenedHandler wraps h, rejecting the requests which failed validation when they were received
+// on a hardenedConn. Requests received on other connections are passed to h unchanged.
+func hardenedHandler(h http.Handler) http.Handler {
+	logger := logutil.NewThrottled(5, 10*time.Second) // limit to 5 messages every 10 seconds
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c, ok := req.Context().Value(hardenedConnKey{}).(*hardenedConn)
+		if !ok {
+			h.ServeHTTP(w, req)
+			return
+		}
+		reason, ok := c.nextVerdict()
+		if !ok {
+			reason = reasonUnverifiedRequest
+			metrics.Count(rejectedRequestsKey, 1, []string{"reason:" + reason}, 1)
+		}
+		if reason != "" {
+			logger.Warn("Rejecting ambiguous request from %s to %s: %s", req.RemoteAddr, req.URL.Path, reason)
+			status := http.StatusBadRequest
+			if reason == reasonHeaderTooLarge {
+				status = http.StatusRequestHeaderFieldsTooLarge
+			}
+			w.Header().Set("Connection", "close")
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		if len(req.TransferEncoding) > 0 {
+			// the connection doesn't follow chunked bodies, so the requests following
+			// this one couldn't be validated
+			w.Header().Set("Connection", "close")
+		}
+		h.ServeHTTP(w, req)
+	})
+}
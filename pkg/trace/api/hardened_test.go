@@ -0,0 +1,168 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRequestHeader(t *testing.T) {
+	for name, tt := range map[string]struct {
+		header  string
+		reason  string
+		length  int64
+		chunked bool
+	}{
+		"no-body":            {header: "GET /info HTTP/1.1\r\nHost: a\r\n\r\n"},
+		"content-length":     {header: "POST /v0.4/traces HTTP/1.1\r\nHost: a\r\nContent-Length: 12\r\n\r\n", length: 12},
+		"chunked":            {header: "POST /v0.4/traces HTTP/1.1\r\nTransfer-Encoding: Chunked\r\n\r\n", chunked: true},
+		"http10":             {header: "POST /v0.4/traces HTTP/1.0\r\nContent-Length: 3\r\n\r\n", length: 3},
+		"bare-lf":            {header: "GET /info HTTP/1.1\nHost: a\n\n", reason: reasonBareLF},
+		"bare-lf-header":     {header: "GET /info HTTP/1.1\r\nHost: a\n\r\n", reason: reasonBareLF},
+		"request-line":       {header: "GET  /info HTTP/1.1\r\n\r\n", reason: reasonInvalidRequestLine},
+		"version":            {header: "GET /info HTTP/1.2\r\n\r\n", reason: reasonInvalidRequestLine},
+		"folding":            {header: "GET /info HTTP/1.1\r\nHost: a\r\n b\r\n\r\n", reason: reasonLineFolding},
+		"space-before-colon": {header: "POST / HTTP/1.1\r\nContent-Length : 3\r\n\r\n", reason: reasonInvalidHeader},
+		"no-colon":           {header: "GET /info HTTP/1.1\r\nHost\r\n\r\n", reason: reasonInvalidHeader},
+		"multiple-host":      {header: "GET /info HTTP/1.1\r\nHost: a\r\nhost: b\r\n\r\n", reason: reasonMultipleHost},
+		"multiple-length":    {header: "POST / HTTP/1.1\r\nContent-Length: 3\r\nContent-Length: 3\r\n\r\n", reason: reasonMultipleLength},
+		"invalid-length":     {header: "POST / HTTP/1.1\r\nContent-Length: +3\r\n\r\n", reason: reasonInvalidLength},
+		"list-length":        {header: "POST / HTTP/1.1\r\nContent-Length: 3, 3\r\n\r\n", reason: reasonInvalidLength},
+		"length-overflow":    {header: "POST / HTTP/1.1\r\nContent-Length: 99999999999999999999\r\n\r\n", reason: reasonInvalidLength},
+		"length-and-chunked": {header: "POST / HTTP/1.1\r\nContent-Length: 3\r\nTransfer-Encoding: chunked\r\n\r\n", reason: reasonLengthAndEncoding},
+		"gzip-encoding":      {header: "POST / HTTP/1.1\r\nTransfer-Encoding: gzip, chunked\r\n\r\n", reason: reasonInvalidEncoding},
+		"multiple-encoding":  {header: "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n\r\n", reason: reasonInvalidEncoding},
+		"http10-chunked":     {header: "POST / HTTP/1.0\r\nTransfer-Encoding: chunked\r\n\r\n", reason: reasonInvalidEncoding},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reason, length, chunked := validateRequestHeader([]byte(tt.header))
+			assert.Equal(t, tt.reason, reason)
+			assert.Equal(t, tt.length, length)
+			assert.Equal(t, tt.chunked, chunked)
+		})
+	}
+}
+
+func TestHardenedConn(t *testing.T) {
+	c := &hardenedConn{maxHeaderBytes: 64}
+	// requests are split across reads arbitrarily
+	for _, data := range []string{
+		"\r\nPOST / HTTP/1.1\r\nContent-Len",
+		"gth: 5\r\n\r\nhello",
+		"GET / HTTP/1.1\r\n\r\nPOST / HTTP/1.1\r\nContent-Length: 2\r\n",
+		"\r\n\r\n",
+		"GET / HTTP/1.1\r\nContent-Length: 1\r\nContent-Length: 1\r\n\r\nx",
+		"GET / HTTP/1.1\r\n\r\n",
+	} {
+		c.inspect([]byte(data))
+	}
+	assert.Equal(t, []string{"", "", "", reasonMultipleLength}, c.verdicts)
+	assert.True(t, c.blind)
+
+	c = &hardenedConn{maxHeaderBytes: 64}
+	c.inspect([]byte("GET / HTTP/1.1\r\nX-Padding: " + strings.Repeat("a", 64) + "\r\n\r\n"))
+	assert.Equal(t, []string{reasonHeaderTooLarge}, c.verdicts)
+}
+
+func TestHardenedListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu     sync.Mutex
+		served []string
+	)
+	srv := &http.Server{
+		Handler: hardenedHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			mu.Lock()
+			served = append(served, string(body))
+			mu.Unlock()
+		})),
+		ConnContext: connContext,
+	}
+	go srv.Serve(newHardenedListener(ln, 1024))
+	defer srv.Close()
+
+	reset := func() {
+		mu.Lock()
+		served = nil
+		mu.Unlock()
+	}
+	bodies := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return served
+	}
+
+	// send writes raw requests on a new connection and returns the status codes of the responses
+	send := func(raw string) []int {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte(raw)); err != nil {
+			t.Fatal(err)
+		}
+		var codes []int
+		rd := bufio.NewReader(conn)
+		for {
+			resp, err := http.ReadResponse(rd, nil)
+			if err != nil {
+				return codes
+			}
+			resp.Body.Close()
+			codes = append(codes, resp.StatusCode)
+			if resp.Close {
+				return codes
+			}
+		}
+	}
+
+	t.Run("pipelined", func(t *testing.T) {
+		reset()
+		codes := send("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 3\r\n\r\nabc" +
+			"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 2\r\nConnection: close\r\n\r\nde")
+		assert.Equal(t, []int{http.StatusOK, http.StatusOK}, codes)
+		assert.Equal(t, []string{"abc", "de"}, bodies())
+	})
+
+	t.Run("smuggling", func(t *testing.T) {
+		// net/http alone would accept the first request, ignoring its Content-Length, and
+		// serve the second one
+		reset()
+		codes := send("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n" +
+			"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 2\r\n\r\nde")
+		assert.Equal(t, []int{http.StatusBadRequest}, codes)
+		assert.Empty(t, bodies())
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		reset()
+		codes := send("POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n" +
+			"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 2\r\n\r\nde")
+		// the connection is closed after a chunked request
+		assert.Equal(t, []int{http.StatusOK}, codes)
+		assert.Equal(t, []string{"abc"}, bodies())
+	})
+
+	t.Run("header-too-large", func(t *testing.T) {
+		reset()
+		codes := send("GET / HTTP/1.1\r\nHost: a\r\nX-Padding: " + strings.Repeat("a", 1024) + "\r\n\r\n")
+		assert.Equal(t, []int{http.StatusRequestHeaderFieldsTooLarge}, codes)
+		assert.Empty(t, bodies())
+	})
+}
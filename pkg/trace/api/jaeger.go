@@ -0,0 +1,698 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// jaegerEndpointVersion is the endpoint version under which the stats of the traces received
+// as Jaeger batches are reported.
+const jaegerEndpointVersion = "jaeger"
+
+// maxJaegerPacketSize is the maximum size of the UDP packets sent by the Jaeger clients.
+const maxJaegerPacketSize = 65000
+
+// The Jaeger batches are decoded by hand from the Thrift binary and compact protocols, see:
+// https://github.com/apache/thrift/blob/master/doc/specs/thrift-binary-protocol.md
+// https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md
+// The types below are those of the binary protocol, to which the compact types are mapped.
+const (
+	thriftStop   = 0
+	thriftBool   = 2
+	thriftByte   = 3
+	thriftDouble = 4
+	thriftI16    = 6
+	thriftI32    = 8
+	thriftI64    = 10
+	thriftString = 11
+	thriftStruct = 12
+	thriftMap    = 13
+	thriftSet    = 14
+	thriftList   = 15
+)
+
+// thriftCompactTypes maps the types of the compact protocol to those of the binary protocol.
+// Booleans are handled apart, as their value is part of the type of struct fields.
+var thriftCompactTypes = [...]byte{
+	3:  thriftByte,
+	4:  thriftI16,
+	5:  thriftI32,
+	6:  thriftI64,
+	7:  thriftDouble,
+	8:  thriftString,
+	9:  thriftList,
+	10: thriftSet,
+	11: thriftMap,
+	12: thriftStruct,
+}
+
+// maxThriftDepth is the maximum nesting of the decoded Thrift structs and collections.
+const maxThriftDepth = 16
+
+// errThriftMalformed is returned when a Thrift message can not be decoded.
+var errThriftMalformed = errors.New("malformed thrift message")
+
+// thriftFields holds the fields of a decoded Thrift struct by ID. Their values are int64 for
+// integers, float64, bool, []byte for strings, []interface{} for lists and sets, and
+// thriftFields for structs. Maps are skipped, as Jaeger batches have none.
+type thriftFields map[int16]interface{}
+
+func (f thriftFields) getInt(id int16) int64     { v, _ := f[id].(int64); return v }
+func (f thriftFields) getFloat(id int16) float64 { v, _ := f[id].(float64); return v }
+func (f thriftFields) getBool(id int16) bool     { v, _ := f[id].(bool); return v }
+func (f thriftFields) getBytes(id int16) []byte  { v, _ := f[id].([]byte); return v }
+func (f thriftFields) getString(id int16) string { return string(f.getBytes(id)) }
+func (f thriftFields) getStruct(id int16) thriftFields {
+	v, _ := f[id].(thriftFields)
+	return v
+}
+
+// getStructs returns the structs of the list field with the given ID.
+func (f thriftFields) getStructs(id int16) []thriftFields {
+	list, _ := f[id].([]interface{})
+	structs := make([]thriftFields, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(thriftFields); ok {
+			structs = append(structs, s)
+		}
+	}
+	return structs
+}
+
+// thriftReader decodes Thrift values encoded with the binary protocol or, when compact is
+// set, with the compact protocol.
+type thriftReader struct {
+	data    []byte
+	compact bool
+	depth   int
+}
+
+func (t *thriftReader) readN(n int) ([]byte, error) {
+	if n < 0 || n > len(t.data) {
+		return nil, errThriftMalformed
+	}
+	b := t.data[:n]
+	t.data = t.data[n:]
+	return b, nil
+}
+
+func (t *thriftReader) readByte() (byte, error) {
+	b, err := t.readN(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (t *thriftReader) readUvarint() (uint64, error) {
+	v, n := binary.Uvarint(t.data)
+	if n <= 0 {
+		return 0, errThriftMalformed
+	}
+	t.data = t.data[n:]
+	return v, nil
+}
+
+// readInt reads an integer of the given size, in bytes, which is zigzag encoded in a varint
+// by the compact protocol.
+func (t *thriftReader) readInt(size int) (int64, error) {
+	if t.compact {
+		v, err := t.readUvarint()
+		return int64(v>>1) ^ -int64(v&1), err
+	}
+	b, err := t.readN(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 2:
+		return int64(int16(binary.BigEndian.Uint16(b))), nil
+	case 4:
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	default:
+		return int64(binary.BigEndian.Uint64(b)), nil
+	}
+}
+
+// readSize reads the size of a string or a collection, which can not exceed the remaining
+// bytes, as each element takes at least one.
+func (t *thriftReader) readSize() (int, error) {
+	var size int64
+	if t.compact {
+		v, err := t.readUvarint()
+		if err != nil {
+			return 0, err
+		}
+		size = int64(v)
+	} else {
+		v, err := t.readInt(4)
+		if err != nil {
+			return 0, err
+		}
+		size = v
+	}
+	if size < 0 || size > int64(len(t.data)) {
+		return 0, errThriftMalformed
+	}
+	return int(size), nil
+}
+
+// readValue reads a value of the given type.
+func (t *thriftReader) readValue(typ byte) (interface{}, error) {
+	switch typ {
+	case thriftBool:
+		b, err := t.readByte()
+		if t.compact {
+			// booleans which are not struct fields are encoded as their compact type
+			return b == 1, err
+		}
+		return b != 0, err
+	case thriftByte:
+		b, err := t.readByte()
+		return int64(int8(b)), err
+	case thriftI16:
+		return t.readInt(2)
+	case thriftI32:
+		return t.readInt(4)
+	case thriftI64:
+		return t.readInt(8)
+	case thriftDouble:
+		b, err := t.readN(8)
+		if err != nil {
+			return nil, err
+		}
+		if t.compact {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case thriftString:
+		n, err := t.readSize()
+		if err != nil {
+			return nil, err
+		}
+		return t.readN(n)
+	case thriftStruct:
+		return t.readStruct()
+	case thriftList, thriftSet:
+		return t.readList()
+	case thriftMap:
+		return nil, t.skipMap()
+	default:
+		return nil, fmt.Errorf("unsupported thrift type %d", typ)
+	}
+}
+
+// readType returns the binary protocol type of the elements of a collection.
+func (t *thriftReader) readType(b byte) (byte, error) {
+	if !t.compact {
+		return b, nil
+	}
+	switch b {
+	case 1, 2:
+		return thriftBool, nil
+	}
+	if int(b) >= len(thriftCompactTypes) || thriftCompactTypes[b] == 0 {
+		return 0, fmt.Errorf("unsupported thrift compact type %d", b)
+	}
+	return thriftCompactTypes[b], nil
+}
+
+func (t *thriftReader) readList() ([]interface{}, error) {
+	if t.depth++; t.depth > maxThriftDepth {
+		return nil, errThriftMalformed
+	}
+	defer func() { t.depth-- }()
+	b, err := t.readByte()
+	if err != nil {
+		return nil, err
+	}
+	var size int
+	if t.compact && b>>4 != 15 {
+		size = int(b >> 4)
+	} else if size, err = t.readSize(); err != nil {
+		return nil, err
+	}
+	elem := b
+	if t.compact {
+		elem = b & 0x0f
+	}
+	typ, err := t.readType(elem)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]interface{}, 0, size)
+	for i := 0; i < size; i++ {
+		v, err := t.readValue(typ)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+func (t *thriftReader) skipMap() error {
+	if t.depth++; t.depth > maxThriftDepth {
+		return errThriftMalformed
+	}
+	defer func() { t.depth-- }()
+	var (
+		size int
+		kv   [2]byte
+		err  error
+	)
+	if t.compact {
+		// the size comes first, followed by the key and value types of non-empty maps
+		if size, err = t.readSize(); err != nil || size == 0 {
+			return err
+		}
+		b, err := t.readByte()
+		if err != nil {
+			return err
+		}
+		kv = [2]byte{b >> 4, b & 0x0f}
+	} else {
+		b, err := t.readN(2)
+		if err != nil {
+			return err
+		}
+		kv = [2]byte{b[0], b[1]}
+		if size, err = t.readSize(); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < 2*size; i++ {
+		typ, err := t.readType(kv[i%2])
+		if err != nil {
+			return err
+		}
+		if _, err := t.readValue(typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *thriftReader) readStruct() (thriftFields, error) {
+	if t.depth++; t.depth > maxThriftDepth {
+		return nil, errThriftMalformed
+	}
+	defer func() { t.depth-- }()
+	fields := make(thriftFields)
+	var id int16
+	for {
+		b, err := t.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == thriftStop {
+			return fields, nil
+		}
+		typ := b
+		if t.compact {
+			if delta := b >> 4; delta != 0 {
+				id += int16(delta)
+			} else {
+				v, err := t.readInt(2)
+				if err != nil {
+					return nil, err
+				}
+				id = int16(v)
+			}
+			if typ = b & 0x0f; typ == 1 || typ == 2 {
+				// the value of boolean fields is their type
+				fields[id] = typ == 1
+				continue
+			}
+			if typ, err = t.readType(typ); err != nil {
+				return nil, err
+			}
+		} else {
+			v, err := t.readN(2)
+			if err != nil {
+				return nil, err
+			}
+			id = int16(binary.BigEndian.Uint16(v))
+		}
+		if fields[id], err = t.readValue(typ); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readCompactMessage reads the header of a message encoded with the compact protocol and
+// returns its method name, the arguments struct following it.
+func (t *thriftReader) readCompactMessage() (string, error) {
+	id, err := t.readByte()
+	if err != nil {
+		return "", err
+	}
+	vt, err := t.readByte()
+	if err != nil {
+		return "", err
+	}
+	if id != 0x82 || vt&0x1f != 1 {
+		return "", errors.New("unsupported thrift message protocol")
+	}
+	if _, err := t.readUvarint(); err != nil { // sequence ID
+		return "", err
+	}
+	name, err := t.readValue(thriftString)
+	if err != nil {
+		return "", err
+	}
+	return string(name.([]byte)), nil
+}
+
+// Field IDs and constants of the structs defined in:
+// https://github.com/jaegertracing/jaeger-idl/blob/master/thrift/jaeger.thrift
+const (
+	jaegerBatchProcess = 1
+	jaegerBatchSpans   = 2
+
+	jaegerProcessServiceName = 1
+	jaegerProcessTags        = 2
+
+	jaegerSpanTraceIDLow    = 1
+	jaegerSpanSpanID        = 3
+	jaegerSpanParentSpanID  = 4
+	jaegerSpanOperationName = 5
+	jaegerSpanReferences    = 6
+	jaegerSpanStartTime     = 8
+	jaegerSpanDuration      = 9
+	jaegerSpanTags          = 10
+	jaegerSpanLogs          = 11
+
+	jaegerSpanRefType       = 1
+	jaegerSpanRefTraceIDLow = 2
+	jaegerSpanRefSpanID     = 4
+	jaegerSpanRefChildOf    = 0
+
+	jaegerLogFields = 2
+
+	jaegerTagKey     = 1
+	jaegerTagType    = 2
+	jaegerTagString  = 3
+	jaegerTagDouble  = 4
+	jaegerTagBool    = 5
+	jaegerTagLong    = 6
+	jaegerTagBinary  = 7
+	jaegerTypeString = 0
+	jaegerTypeDouble = 1
+	jaegerTypeBool   = 2
+	jaegerTypeLong   = 3
+	jaegerTypeBinary = 4
+)
+
+// jaegerTag returns the value of the string tag with the given key.
+func jaegerTag(tags []thriftFields, key string) string {
+	for _, tag := range tags {
+		if tag.getString(jaegerTagKey) == key && tag.getInt(jaegerTagType) == jaegerTypeString {
+			return tag.getString(jaegerTagString)
+		}
+	}
+	return ""
+}
+
+// setJaegerTags sets the string, boolean and binary tags as tags of span, and the numeric ones
+// as its metrics.
+func setJaegerTags(span *pb.Span, tags []thriftFields) {
+	for _, tag := range tags {
+		key := tag.getString(jaegerTagKey)
+		switch tag.getInt(jaegerTagType) {
+		case jaegerTypeString:
+			span.Meta[key] = tag.getString(jaegerTagString)
+		case jaegerTypeBool:
+			span.Meta[key] = strconv.FormatBool(tag.getBool(jaegerTagBool))
+		case jaegerTypeBinary:
+			span.Meta[key] = hex.EncodeToString(tag.getBytes(jaegerTagBinary))
+		case jaegerTypeDouble:
+			span.Metrics[key] = tag.getFloat(jaegerTagDouble)
+		case jaegerTypeLong:
+			span.Metrics[key] = float64(tag.getInt(jaegerTagLong))
+		}
+	}
+}
+
+// jaegerVersion returns the language and the version of the Jaeger client from its
+// "jaeger.version" process tag, such as "Go-2.25.0".
+func jaegerVersion(process thriftFields) (lang, version string) {
+	v := jaegerTag(process.getStructs(jaegerProcessTags), "jaeger.version")
+	if i := strings.IndexByte(v, '-'); i > 0 {
+		return strings.ToLower(v[:i]), v[i+1:]
+	}
+	return "", v
+}
+
+// convertJaegerSpan converts a Jaeger span of the given process to a Datadog span. Jaeger
+// timestamps and durations are in microseconds.
+func convertJaegerSpan(process, s thriftFields) *pb.Span {
+	span := &pb.Span{
+		Service:  process.getString(jaegerProcessServiceName),
+		Resource: s.getString(jaegerSpanOperationName),
+		TraceID:  uint64(s.getInt(jaegerSpanTraceIDLow)),
+		SpanID:   uint64(s.getInt(jaegerSpanSpanID)),
+		ParentID: uint64(s.getInt(jaegerSpanParentSpanID)),
+		Start:    s.getInt(jaegerSpanStartTime) * 1e3,
+		Duration: s.getInt(jaegerSpanDuration) * 1e3,
+		Meta:     make(map[string]string),
+		Metrics:  make(map[string]float64),
+	}
+	if span.Service == "" {
+		span.Service = "unknown_service"
+	}
+	if span.ParentID == 0 {
+		// the parent can also be given as a reference, as done by the OpenTracing clients
+		for _, ref := range s.getStructs(jaegerSpanReferences) {
+			if ref.getInt(jaegerSpanRefType) == jaegerSpanRefChildOf && uint64(ref.getInt(jaegerSpanRefTraceIDLow)) == span.TraceID {
+				span.ParentID = uint64(ref.getInt(jaegerSpanRefSpanID))
+				break
+			}
+		}
+	}
+
+	setJaegerTags(span, process.getStructs(jaegerProcessTags))
+	setJaegerTags(span, s.getStructs(jaegerSpanTags))
+
+	// the operation name is made of the span kind, such as "jaeger.server", while the Jaeger
+	// operation name is the resource
+	kind := span.Meta["span.kind"]
+	if kind == "" {
+		kind = "internal"
+		span.Meta["span.kind"] = kind
+	}
+	span.Name = "jaeger." + kind
+
+	switch {
+	case span.Meta["db.type"] != "" || span.Meta["db.system"] != "":
+		span.Type = "db"
+	case kind == "server":
+		span.Type = "web"
+	case kind == "client" && span.Meta["http.method"] != "":
+		span.Type = "http"
+	default:
+		span.Type = "custom"
+	}
+
+	if span.Meta["error"] == "true" {
+		span.Error = 1
+	}
+	delete(span.Meta, "error")
+	for _, l := range s.getStructs(jaegerSpanLogs) {
+		fields := l.getStructs(jaegerLogFields)
+		if jaegerTag(fields, "event") != "error" {
+			continue
+		}
+		for key, tag := range map[string]string{
+			"error.kind": "error.type",
+			"message":    "error.msg",
+			"stack":      "error.stack",
+		} {
+			if v := jaegerTag(fields, key); v != "" {
+				span.Meta[tag] = v
+			}
+		}
+	}
+	return span
+}
+
+// convertJaegerBatch converts the spans of a Jaeger batch to a payload.
+func convertJaegerBatch(batch thriftFields) (*pb.TracerPayload, error) {
+	process := batch.getStruct(jaegerBatchProcess)
+	if process == nil {
+		return nil, errors.New("jaeger batch without process")
+	}
+	var traces pb.Traces
+	byID := make(map[uint64]int)
+	for _, s := range batch.getStructs(jaegerBatchSpans) {
+		span := convertJaegerSpan(process, s)
+		i, ok := byID[span.TraceID]
+		if !ok {
+			i = len(traces)
+			byID[span.TraceID] = i
+			traces = append(traces, nil)
+		}
+		traces[i] = append(traces[i], span)
+	}
+	lang, version := jaegerVersion(process)
+	tags := process.getStructs(jaegerProcessTags)
+	return &pb.TracerPayload{
+		LanguageName:  lang,
+		TracerVersion: version,
+		Hostname:      jaegerTag(tags, "hostname"),
+		Chunks:        traceChunksFromTraces(traces),
+	}, nil
+}
+
+// decodeJaegerBatch decodes a Jaeger batch encoded with the Thrift binary protocol, as
+// posted to the HTTP endpoint of the Jaeger collectors.
+func decodeJaegerBatch(data []byte) (thriftFields, error) {
+	t := thriftReader{data: data}
+	return t.readStruct()
+}
+
+// decodeJaegerAgentPacket decodes the Jaeger batch of an emitBatch message encoded with the
+// Thrift compact protocol, as sent over UDP to the Jaeger agents.
+func decodeJaegerAgentPacket(data []byte) (thriftFields, error) {
+	t := thriftReader{data: data, compact: true}
+	name, err := t.readCompactMessage()
+	if err != nil {
+		return nil, err
+	}
+	if name != "emitBatch" {
+		return nil, fmt.Errorf("unsupported jaeger agent method %q", name)
+	}
+	args, err := t.readStruct()
+	if err != nil {
+		return nil, err
+	}
+	batch := args.getStruct(1)
+	if batch == nil {
+		return nil, errors.New("emitBatch message without batch")
+	}
+	return batch, nil
+}
+
+// processJaegerBatch converts the spans of a Jaeger batch sent from the given address with
+// the given headers and sends them to the agent.
+func (r *HTTPReceiver) processJaegerBatch(batch thriftFields, size int64, transport, addr string, header http.Header) error {
+	tp, err := convertJaegerBatch(batch)
+	if err != nil {
+		atomic.AddInt64(&r.jaegerTagStats("", "", transport).TracesDropped.DecodingError, 1)
+		return err
+	}
+	ts := r.jaegerTagStats(tp.LanguageName, tp.TracerVersion, transport)
+	if len(tp.Chunks) == 0 {
+		return nil
+	}
+	if r.rateLimited(payloadOrigin("", addr), int64(len(tp.Chunks))) {
+		atomic.AddInt64(&ts.PayloadRefused, 1)
+		return nil
+	}
+	atomic.AddInt64(&ts.TracesReceived, int64(len(tp.Chunks)))
+	atomic.AddInt64(&ts.TracesBytes, size)
+	atomic.AddInt64(&ts.PayloadAccepted, 1)
+	r.sendPayload(&Payload{
+		Source:        ts,
+		TracerPayload: tp,
+	}, header)
+	return nil
+}
+
+// jaegerTagStats returns the stats of the traces received as Jaeger batches from the given
+// client on the given transport.
+func (r *HTTPReceiver) jaegerTagStats(lang, version, transport string) *info.TagStats {
+	return r.Stats.GetTagStats(info.Tags{
+		Lang:            lang,
+		TracerVersion:   version,
+		EndpointVersion: jaegerEndpointVersion,
+		Transport:       transport,
+	})
+}
+
+// handleJaegerTraces handles the Jaeger batches encoded with the Thrift binary protocol, as
+// posted by the Jaeger clients to the collectors.
+func (r *HTTPReceiver) handleJaegerTraces(w http.ResponseWriter, req *http.Request) {
+	switch mediaType := getMediaType(req); mediaType {
+	case "application/x-thrift", "application/vnd.apache.thrift.binary":
+	default:
+		httpFormatError(w, jaegerEndpointVersion, fmt.Errorf("unsupported media type: %q", mediaType))
+		return
+	}
+	transport := requestTransport(req)
+	data, err := ioutil.ReadAll(NewLimitedReader(req.Body, r.conf.MaxRequestBytes))
+	if err == nil {
+		var batch thriftFields
+		if batch, err = decodeJaegerBatch(data); err != nil {
+			atomic.AddInt64(&r.jaegerTagStats("", "", transport).TracesDropped.DecodingError, 1)
+		} else {
+			err = r.processJaegerBatch(batch, int64(len(data)), transport, req.RemoteAddr, req.Header)
+		}
+	}
+	if err != nil {
+		httpDecodingError(err, []string{"handler:jaeger"}, w)
+		log.Errorf("Cannot decode Jaeger batch payload: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// listenJaegerUDP starts receiving Jaeger batches sent to the given UDP address using the
+// compact Thrift protocol, as Jaeger agents do.
+func (r *HTTPReceiver) listenJaegerUDP(addr string) error {
+	conn, err := net.ListenPacket(r.conf.Network("udp"), addr)
+	if err != nil {
+		return err
+	}
+	r.jaegerConn = conn
+	go func() {
+		defer watchdog.LogOnPanic()
+		r.serveJaegerUDP(conn)
+	}()
+	return nil
+}
+
+func (r *HTTPReceiver) serveJaegerUDP(conn net.PacketConn) {
+	tr := transport(conn.LocalAddr().Network())
+	buf := make([]byte, maxJaegerPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			if !strings.Contains(err.Error(), "use of closed network connection") {
+				log.Errorf("Stopped receiving Jaeger batches: %v", err)
+			}
+			return
+		}
+		batch, err := decodeJaegerAgentPacket(buf[:n])
+		if err == nil {
+			err = r.processJaegerBatch(batch, int64(n), tr, addr.String(), nil)
+		} else {
+			atomic.AddInt64(&r.jaegerTagStats("", "", tr).TracesDropped.DecodingError, 1)
+		}
+		if err != nil {
+			log.Debugf("Cannot decode Jaeger batch packet: %v", err)
+		}
+	}
+}
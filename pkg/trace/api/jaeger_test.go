@@ -0,0 +1,342 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// thriftField is a field of a Thrift struct encoded by thriftEncoder. Its value is an int64,
+// a float64, a bool, a string, a []thriftField for structs or a thriftListValue.
+type thriftField struct {
+	id  int16
+	typ byte
+	v   interface{}
+}
+
+type thriftListValue struct {
+	typ   byte
+	items []interface{}
+}
+
+// thriftEncoder encodes Thrift structs with the binary protocol or, when compact is set, with
+// the compact protocol.
+type thriftEncoder struct {
+	bytes.Buffer
+	compact bool
+}
+
+var thriftToCompactTypes = map[byte]byte{
+	thriftByte:   3,
+	thriftI16:    4,
+	thriftI32:    5,
+	thriftI64:    6,
+	thriftDouble: 7,
+	thriftString: 8,
+	thriftList:   9,
+	thriftSet:    10,
+	thriftMap:    11,
+	thriftStruct: 12,
+}
+
+func (e *thriftEncoder) writeUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (e *thriftEncoder) writeInt(v int64, size int) {
+	if e.compact {
+		e.writeUvarint(uint64(v<<1) ^ uint64(v>>63))
+		return
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(v))
+	e.Write(buf[8-size:])
+}
+
+func (e *thriftEncoder) writeSize(n int) {
+	if e.compact {
+		e.writeUvarint(uint64(n))
+	} else {
+		e.writeInt(int64(n), 4)
+	}
+}
+
+func (e *thriftEncoder) writeValue(typ byte, v interface{}) {
+	switch typ {
+	case thriftBool:
+		switch {
+		case e.compact && v.(bool):
+			e.WriteByte(1)
+		case e.compact:
+			e.WriteByte(2)
+		case v.(bool):
+			e.WriteByte(1)
+		default:
+			e.WriteByte(0)
+		}
+	case thriftI16:
+		e.writeInt(v.(int64), 2)
+	case thriftI32:
+		e.writeInt(v.(int64), 4)
+	case thriftI64:
+		e.writeInt(v.(int64), 8)
+	case thriftDouble:
+		var buf [8]byte
+		if e.compact {
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v.(float64)))
+		} else {
+			binary.BigEndian.PutUint64(buf[:], math.Float64bits(v.(float64)))
+		}
+		e.Write(buf[:])
+	case thriftString:
+		e.writeSize(len(v.(string)))
+		e.WriteString(v.(string))
+	case thriftStruct:
+		e.writeStruct(v.([]thriftField))
+	case thriftList:
+		l := v.(thriftListValue)
+		switch {
+		case e.compact && len(l.items) < 15:
+			e.WriteByte(byte(len(l.items))<<4 | thriftToCompactTypes[l.typ])
+		case e.compact:
+			e.WriteByte(0xf0 | thriftToCompactTypes[l.typ])
+			e.writeSize(len(l.items))
+		default:
+			e.WriteByte(l.typ)
+			e.writeSize(len(l.items))
+		}
+		for _, item := range l.items {
+			e.writeValue(l.typ, item)
+		}
+	case thriftMap:
+		// only maps of strings are encoded
+		m := v.(map[string]string)
+		if e.compact {
+			e.writeSize(len(m))
+			if len(m) > 0 {
+				e.WriteByte(8<<4 | 8)
+			}
+		} else {
+			e.WriteByte(thriftString)
+			e.WriteByte(thriftString)
+			e.writeSize(len(m))
+		}
+		for k, v := range m {
+			e.writeValue(thriftString, k)
+			e.writeValue(thriftString, v)
+		}
+	}
+}
+
+func (e *thriftEncoder) writeStruct(fields []thriftField) {
+	var last int16
+	for _, f := range fields {
+		if !e.compact {
+			e.WriteByte(f.typ)
+			e.writeInt(int64(f.id), 2)
+			e.writeValue(f.typ, f.v)
+			continue
+		}
+		typ := thriftToCompactTypes[f.typ]
+		if f.typ == thriftBool {
+			typ = 2
+			if f.v.(bool) {
+				typ = 1
+			}
+		}
+		if delta := f.id - last; delta > 0 && delta <= 15 {
+			e.WriteByte(byte(delta)<<4 | typ)
+		} else {
+			e.WriteByte(typ)
+			e.writeInt(int64(f.id), 2)
+		}
+		last = f.id
+		if f.typ != thriftBool {
+			e.writeValue(f.typ, f.v)
+		}
+	}
+	e.WriteByte(thriftStop)
+}
+
+func jaegerStringTag(key, value string) []thriftField {
+	return []thriftField{{1, thriftString, key}, {2, thriftI32, int64(jaegerTypeString)}, {3, thriftString, value}}
+}
+
+// testJaegerBatch is a Jaeger batch holding a server span and its client child span, which
+// failed.
+var testJaegerBatch = []thriftField{
+	{1, thriftStruct, []thriftField{
+		{1, thriftString, "backend"},
+		{2, thriftList, thriftListValue{thriftStruct, []interface{}{
+			jaegerStringTag("jaeger.version", "Go-2.25.0"),
+			jaegerStringTag("hostname", "host-a"),
+		}}},
+	}},
+	{2, thriftList, thriftListValue{thriftStruct, []interface{}{
+		[]thriftField{
+			{1, thriftI64, int64(0x463acb4ea3f4b9c2)},
+			{2, thriftI64, int64(0x5af7183fb1d4cf5f)},
+			{3, thriftI64, int64(0x352bff9a74ca9ad2)},
+			{4, thriftI64, int64(0)},
+			{5, thriftString, "GET /api"},
+			{7, thriftI32, int64(1)},
+			{8, thriftI64, int64(1556604172355737)},
+			{9, thriftI64, int64(1431)},
+			{10, thriftList, thriftListValue{thriftStruct, []interface{}{
+				jaegerStringTag("span.kind", "server"),
+				jaegerStringTag("http.method", "GET"),
+				[]thriftField{{1, thriftString, "http.status_code"}, {2, thriftI32, int64(jaegerTypeLong)}, {6, thriftI64, int64(200)}},
+			}}},
+			// unknown fields are skipped
+			{42, thriftMap, map[string]string{"a": "b"}},
+		},
+		[]thriftField{
+			{1, thriftI64, int64(0x463acb4ea3f4b9c2)},
+			{2, thriftI64, int64(0x5af7183fb1d4cf5f)},
+			{3, thriftI64, int64(0x6b221d5bc9e6496c)},
+			{4, thriftI64, int64(0)},
+			{5, thriftString, "select"},
+			{6, thriftList, thriftListValue{thriftStruct, []interface{}{
+				[]thriftField{
+					{1, thriftI32, int64(jaegerSpanRefChildOf)},
+					{2, thriftI64, int64(0x463acb4ea3f4b9c2)},
+					{3, thriftI64, int64(0x5af7183fb1d4cf5f)},
+					{4, thriftI64, int64(0x352bff9a74ca9ad2)},
+				},
+			}}},
+			{7, thriftI32, int64(1)},
+			{8, thriftI64, int64(1556604172355900)},
+			{9, thriftI64, int64(800)},
+			{10, thriftList, thriftListValue{thriftStruct, []interface{}{
+				jaegerStringTag("span.kind", "client"),
+				jaegerStringTag("db.type", "sql"),
+				[]thriftField{{1, thriftString, "error"}, {2, thriftI32, int64(jaegerTypeBool)}, {5, thriftBool, true}},
+				[]thriftField{{1, thriftString, "sampled"}, {2, thriftI32, int64(jaegerTypeDouble)}, {4, thriftDouble, 0.5}},
+			}}},
+			{11, thriftList, thriftListValue{thriftStruct, []interface{}{
+				[]thriftField{
+					{1, thriftI64, int64(1556604172356000)},
+					{2, thriftList, thriftListValue{thriftStruct, []interface{}{
+						jaegerStringTag("event", "error"),
+						jaegerStringTag("message", "connection reset"),
+					}}},
+				},
+			}}},
+		},
+	}}},
+}
+
+func encodeJaegerBatch(compact bool) []byte {
+	e := thriftEncoder{compact: compact}
+	e.writeStruct(testJaegerBatch)
+	return e.Bytes()
+}
+
+// encodeJaegerAgentPacket encodes the test batch in an emitBatch message, as sent to the
+// Jaeger agents.
+func encodeJaegerAgentPacket() []byte {
+	e := thriftEncoder{compact: true}
+	e.WriteByte(0x82)
+	e.WriteByte(4<<5 | 1) // oneway message
+	e.writeUvarint(1)
+	e.writeValue(thriftString, "emitBatch")
+	e.writeStruct([]thriftField{{1, thriftStruct, testJaegerBatch}})
+	return e.Bytes()
+}
+
+func TestDecodeJaegerBatch(t *testing.T) {
+	binaryBatch, err := decodeJaegerBatch(encodeJaegerBatch(false))
+	assert.NoError(t, err)
+	compactBatch, err := decodeJaegerAgentPacket(encodeJaegerAgentPacket())
+	assert.NoError(t, err)
+	assert.Equal(t, binaryBatch, compactBatch)
+
+	for name, batch := range map[string]thriftFields{"binary": binaryBatch, "compact": compactBatch} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			tp, err := convertJaegerBatch(batch)
+			assert.NoError(err)
+			assert.Equal("go", tp.LanguageName)
+			assert.Equal("2.25.0", tp.TracerVersion)
+			assert.Equal("host-a", tp.Hostname)
+			assert.Len(tp.Chunks, 1)
+			assert.Len(tp.Chunks[0].Spans, 2)
+
+			server := tp.Chunks[0].Spans[0]
+			assert.Equal("backend", server.Service)
+			assert.Equal("jaeger.server", server.Name)
+			assert.Equal("GET /api", server.Resource)
+			assert.Equal("web", server.Type)
+			assert.EqualValues(0x463acb4ea3f4b9c2, server.TraceID)
+			assert.EqualValues(0x352bff9a74ca9ad2, server.SpanID)
+			assert.EqualValues(0, server.ParentID)
+			assert.EqualValues(1556604172355737000, server.Start)
+			assert.EqualValues(1431000, server.Duration)
+			assert.EqualValues(0, server.Error)
+			assert.Equal("GET", server.Meta["http.method"])
+			assert.Equal(200., server.Metrics["http.status_code"])
+			// process tags are set on every span
+			assert.Equal("host-a", server.Meta["hostname"])
+
+			client := tp.Chunks[0].Spans[1]
+			assert.Equal("jaeger.client", client.Name)
+			assert.Equal("db", client.Type)
+			assert.EqualValues(server.SpanID, client.ParentID)
+			assert.EqualValues(1, client.Error)
+			assert.Equal("connection reset", client.Meta["error.msg"])
+			assert.Equal(0.5, client.Metrics["sampled"])
+			assert.NotContains(client.Meta, "error")
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		data := encodeJaegerBatch(false)
+		_, err := decodeJaegerBatch(data[:len(data)-10])
+		assert.Error(t, err)
+
+		_, err = decodeJaegerAgentPacket(encodeJaegerBatch(true))
+		assert.Error(t, err)
+
+		_, err = convertJaegerBatch(thriftFields{})
+		assert.Error(t, err)
+	})
+}
+
+func TestHandleJaegerTraces(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestReceiverFromConfig(newTestReceiverConfig())
+	server := httptest.NewServer(http.HandlerFunc(r.handleJaegerTraces))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/x-thrift", bytes.NewReader(encodeJaegerBatch(false)))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusAccepted, resp.StatusCode)
+
+	p := <-r.out
+	assert.Len(p.TracerPayload.Chunks, 1)
+	assert.Len(p.TracerPayload.Chunks[0].Spans, 2)
+	assert.EqualValues(1, r.jaegerTagStats("go", "2.25.0", "").TracesReceived)
+
+	resp, err = http.Post(server.URL, "application/x-thrift", bytes.NewReader([]byte{thriftStruct, 0, 1}))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	assert.EqualValues(1, r.jaegerTagStats("", "", "").TracesDropped.DecodingError)
+
+	resp, err = http.Post(server.URL, "application/json", bytes.NewReader(encodeJaegerBatch(false)))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusUnsupportedMediaType, resp.StatusCode)
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
@@ -133,3 +134,111 @@ func (sl *rateLimitedListener) Close() error {
 	<-sl.exit
 	return sl.TCPListener.Close()
 }
+
+// measuredListener wraps a listener, such as a Windows named pipe, limiting the number of
+// connections open at once and reporting telemetry about its connections.
+type measuredListener struct {
+	net.Listener
+
+	name    string        // name reported in the telemetry
+	maxConn int32         // maximum number of open connections, unlimited when 0
+	exit    chan struct{} // exit notification channel
+	closed  uint32        // closed will be non-zero if the listener was closed
+
+	// stats
+	active   int32
+	accepted uint32
+	rejected uint32
+	errored  uint32
+}
+
+// newMeasuredListener returns a new listener wrapping ln, reporting telemetry tagged with the
+// given name and closing the connections exceeding maxConn. It reports its telemetry until
+// it is closed.
+func newMeasuredListener(ln net.Listener, name string, maxConn int) *measuredListener {
+	ml := &measuredListener{
+		Listener: ln,
+		name:     name,
+		maxConn:  int32(maxConn),
+		exit:     make(chan struct{}),
+	}
+	go func() {
+		defer watchdog.LogOnPanic()
+		ml.run()
+	}()
+	return ml
+}
+
+// run reports the telemetry of the listener every 10 seconds, until the listener is closed.
+func (ml *measuredListener) run() {
+	tick := time.NewTicker(10 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ml.exit:
+			ml.flushStats()
+			return
+		case <-tick.C:
+			ml.flushStats()
+		}
+	}
+}
+
+func (ml *measuredListener) flushStats() {
+	for tag, stat := range map[string]*uint32{
+		"status:accepted": &ml.accepted,
+		"status:rejected": &ml.rejected,
+		"status:errored":  &ml.errored,
+	} {
+		v := int64(atomic.SwapUint32(stat, 0))
+		metrics.Count("datadog.trace_agent.receiver.pipe_connections", v, []string{"pipe:" + ml.name, tag}, 1)
+	}
+	active := float64(atomic.LoadInt32(&ml.active))
+	metrics.Gauge("datadog.trace_agent.receiver.pipe_active_connections", active, []string{"pipe:" + ml.name}, 1)
+}
+
+// Accept implements net.Listener, closing right away the connections above the limit.
+func (ml *measuredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ml.Listener.Accept()
+		if err != nil {
+			if atomic.LoadUint32(&ml.closed) == 0 {
+				atomic.AddUint32(&ml.errored, 1)
+			}
+			return conn, err
+		}
+		if n := atomic.AddInt32(&ml.active, 1); ml.maxConn > 0 && n > ml.maxConn {
+			atomic.AddInt32(&ml.active, -1)
+			atomic.AddUint32(&ml.rejected, 1)
+			conn.Close()
+			continue
+		}
+		atomic.AddUint32(&ml.accepted, 1)
+		return &measuredConn{Conn: conn, active: &ml.active}, nil
+	}
+}
+
+// Close implements net.Listener.
+func (ml *measuredListener) Close() error {
+	if !atomic.CompareAndSwapUint32(&ml.closed, 0, 1) {
+		return nil
+	}
+	close(ml.exit)
+	return ml.Listener.Close()
+}
+
+// measuredConn is a connection accepted by a measuredListener, released from the count of
+// active connections when closed.
+type measuredConn struct {
+	net.Conn
+	active *int32
+	closed uint32
+}
+
+// Close implements net.Conn.
+func (c *measuredConn) Close() error {
+	if atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		atomic.AddInt32(c.active, -1)
+	}
+	return c.Conn.Close()
+}
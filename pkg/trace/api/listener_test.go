@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasuredListener(t *testing.T) {
+	assert := assert.New(t)
+	tcpln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(err)
+	ln := newMeasuredListener(tcpln, "test", 1)
+
+	conns := make(chan net.Conn)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				close(conns)
+				return
+			}
+			conns <- conn
+		}
+	}()
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", tcpln.Addr().String())
+		assert.NoError(err)
+		return conn
+	}
+
+	c1 := dial()
+	defer c1.Close()
+	first := <-conns
+	assert.EqualValues(1, atomic.LoadInt32(&ln.active))
+
+	// the limit is reached: the second connection is closed by the listener
+	c2 := dial()
+	defer c2.Close()
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = c2.Read(make([]byte, 1))
+	assert.Error(err)
+	assert.EqualValues(1, atomic.LoadUint32(&ln.rejected))
+
+	// closing a connection makes room for a new one
+	assert.NoError(first.Close())
+	first.Close() // closing twice does not release another connection
+	assert.EqualValues(0, atomic.LoadInt32(&ln.active))
+	c3 := dial()
+	defer c3.Close()
+	third := <-conns
+	defer third.Close()
+	assert.EqualValues(2, atomic.LoadUint32(&ln.accepted))
+	assert.EqualValues(1, atomic.LoadInt32(&ln.active))
+
+	assert.NoError(ln.Close())
+	assert.NoError(ln.Close())
+	_, ok := <-conns
+	assert.False(ok)
+	assert.EqualValues(0, atomic.LoadUint32(&ln.errored))
+}
@@ -0,0 +1,636 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// otlpEndpointVersion is the endpoint version under which the stats of the traces received
+// using the OpenTelemetry protocol (OTLP) are reported.
+const otlpEndpointVersion = "otlp"
+
+// otlpServiceName is the name of the OTLP trace service, see:
+// https://github.com/open-telemetry/opentelemetry-proto/blob/master/opentelemetry/proto/collector/trace/v1/trace_service.proto
+const otlpServiceName = "opentelemetry.proto.collector.trace.v1.TraceService"
+
+// The OTLP messages are decoded by hand from the protobuf wire format, as only a few of their
+// fields are needed. The field numbers below are those of the messages defined in:
+// https://github.com/open-telemetry/opentelemetry-proto/tree/master/opentelemetry/proto
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoKey returns the key of the protobuf field with the given number and wire type.
+func protoKey(num, wire uint64) uint64 { return num<<3 | wire }
+
+// errProtoMalformed is returned when a protobuf message can not be decoded.
+var errProtoMalformed = errors.New("malformed protobuf message")
+
+// walkProto calls fn with the key of each field of the protobuf message in data, along with its
+// value for varint and fixed size fields, or its bytes for length-delimited fields.
+func walkProto(data []byte, fn func(key, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtoMalformed
+		}
+		data = data[n:]
+		var (
+			v uint64
+			b []byte
+		)
+		switch key & 7 {
+		case wireVarint:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return errProtoMalformed
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errProtoMalformed
+			}
+			v, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireBytes:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || l > uint64(len(data)-n) {
+				return errProtoMalformed
+			}
+			b, data = data[n:n+int(l)], data[n+int(l):]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errProtoMalformed
+			}
+			v, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", key&7)
+		}
+		if err := fn(key, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OTLP span kinds.
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpSpanKindClient   = 3
+	otlpSpanKindProducer = 4
+	otlpSpanKindConsumer = 5
+)
+
+// otlpStatusCodeError is the code of the status of the spans which ended with an error.
+const otlpStatusCodeError = 2
+
+// otlpKeyValue is an OTLP attribute. Its value is a string, bool, int64 or float64, or nil when
+// it has another type.
+type otlpKeyValue struct {
+	key   string
+	value interface{}
+}
+
+type otlpEvent struct {
+	name       string
+	attributes []otlpKeyValue
+}
+
+type otlpSpan struct {
+	traceID, spanID, parentID []byte
+	name                      string
+	kind                      uint64
+	start, end                uint64
+	attributes                []otlpKeyValue
+	events                    []otlpEvent
+	statusCode                uint64
+	deprecatedStatusCode      uint64
+	statusMessage             string
+}
+
+// otlpLibrarySpans holds the spans created by an instrumentation library.
+type otlpLibrarySpans struct {
+	name, version string
+	spans         []*otlpSpan
+}
+
+// otlpResourceSpans holds the spans of a resource, such as a service instance.
+type otlpResourceSpans struct {
+	attributes []otlpKeyValue
+	libraries  []*otlpLibrarySpans
+}
+
+// decodeOTLPRequest decodes an ExportTraceServiceRequest message.
+func decodeOTLPRequest(data []byte) ([]*otlpResourceSpans, error) {
+	var rss []*otlpResourceSpans
+	err := walkProto(data, func(key, _ uint64, b []byte) error {
+		if key != protoKey(1, wireBytes) { // resource_spans
+			return nil
+		}
+		rs, err := decodeOTLPResourceSpans(b)
+		rss = append(rss, rs)
+		return err
+	})
+	return rss, err
+}
+
+func decodeOTLPResourceSpans(data []byte) (*otlpResourceSpans, error) {
+	rs := &otlpResourceSpans{}
+	return rs, walkProto(data, func(key, _ uint64, b []byte) error {
+		switch key {
+		case protoKey(1, wireBytes): // resource
+			var err error
+			rs.attributes, err = decodeOTLPResource(b)
+			return err
+		case protoKey(2, wireBytes): // instrumentation_library_spans
+			lib, err := decodeOTLPLibrarySpans(b)
+			rs.libraries = append(rs.libraries, lib)
+			return err
+		}
+		return nil
+	})
+}
+
+// decodeOTLPResource decodes the attributes of a Resource message.
+func decodeOTLPResource(data []byte) ([]otlpKeyValue, error) {
+	var attributes []otlpKeyValue
+	err := walkProto(data, func(key, _ uint64, b []byte) error {
+		if key != protoKey(1, wireBytes) { // attributes
+			return nil
+		}
+		kv, err := decodeOTLPKeyValue(b)
+		attributes = append(attributes, kv)
+		return err
+	})
+	return attributes, err
+}
+
+func decodeOTLPLibrarySpans(data []byte) (*otlpLibrarySpans, error) {
+	lib := &otlpLibrarySpans{}
+	return lib, walkProto(data, func(key, _ uint64, b []byte) error {
+		switch key {
+		case protoKey(1, wireBytes): // instrumentation_library
+			return walkProto(b, func(key, _ uint64, b []byte) error {
+				switch key {
+				case protoKey(1, wireBytes):
+					lib.name = string(b)
+				case protoKey(2, wireBytes):
+					lib.version = string(b)
+				}
+				return nil
+			})
+		case protoKey(2, wireBytes): // spans
+			span, err := decodeOTLPSpan(b)
+			lib.spans = append(lib.spans, span)
+			return err
+		}
+		return nil
+	})
+}
+
+func decodeOTLPSpan(data []byte) (*otlpSpan, error) {
+	span := &otlpSpan{}
+	return span, walkProto(data, func(key, v uint64, b []byte) error {
+		switch key {
+		case protoKey(1, wireBytes):
+			span.traceID = b
+		case protoKey(2, wireBytes):
+			span.spanID = b
+		case protoKey(4, wireBytes):
+			span.parentID = b
+		case protoKey(5, wireBytes):
+			span.name = string(b)
+		case protoKey(6, wireVarint):
+			span.kind = v
+		case protoKey(7, wireFixed64):
+			span.start = v
+		case protoKey(8, wireFixed64):
+			span.end = v
+		case protoKey(9, wireBytes):
+			kv, err := decodeOTLPKeyValue(b)
+			span.attributes = append(span.attributes, kv)
+			return err
+		case protoKey(11, wireBytes):
+			event, err := decodeOTLPEvent(b)
+			span.events = append(span.events, event)
+			return err
+		case protoKey(15, wireBytes): // status
+			return walkProto(b, func(key, v uint64, b []byte) error {
+				switch key {
+				case protoKey(1, wireVarint):
+					span.deprecatedStatusCode = v
+				case protoKey(2, wireBytes):
+					span.statusMessage = string(b)
+				case protoKey(3, wireVarint):
+					span.statusCode = v
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+func decodeOTLPEvent(data []byte) (otlpEvent, error) {
+	var event otlpEvent
+	err := walkProto(data, func(key, _ uint64, b []byte) error {
+		switch key {
+		case protoKey(2, wireBytes):
+			event.name = string(b)
+		case protoKey(3, wireBytes):
+			kv, err := decodeOTLPKeyValue(b)
+			event.attributes = append(event.attributes, kv)
+			return err
+		}
+		return nil
+	})
+	return event, err
+}
+
+func decodeOTLPKeyValue(data []byte) (otlpKeyValue, error) {
+	var kv otlpKeyValue
+	err := walkProto(data, func(key, _ uint64, b []byte) error {
+		switch key {
+		case protoKey(1, wireBytes):
+			kv.key = string(b)
+		case protoKey(2, wireBytes):
+			var err error
+			kv.value, err = decodeOTLPAnyValue(b)
+			return err
+		}
+		return nil
+	})
+	return kv, err
+}
+
+// decodeOTLPAnyValue decodes an AnyValue message holding a string, bool, int64 or float64. It
+// returns nil for the values of other types.
+func decodeOTLPAnyValue(data []byte) (interface{}, error) {
+	var value interface{}
+	err := walkProto(data, func(key, v uint64, b []byte) error {
+		switch key {
+		case protoKey(1, wireBytes):
+			value = string(b)
+		case protoKey(2, wireVarint):
+			value = v != 0
+		case protoKey(3, wireVarint):
+			value = int64(v)
+		case protoKey(4, wireFixed64):
+			value = math.Float64frombits(v)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// otlpAttribute returns the value of the attribute with the given key as a string.
+func otlpAttribute(attributes []otlpKeyValue, key string) string {
+	for _, kv := range attributes {
+		if kv.key == key {
+			s, _ := kv.value.(string)
+			return s
+		}
+	}
+	return ""
+}
+
+// otlpID returns the Datadog ID of an OTLP trace or span ID, which is made of its lower 64 bits.
+func otlpID(id []byte) (uint64, error) {
+	switch len(id) {
+	case 0:
+		return 0, nil
+	case 8, 16:
+		return binary.BigEndian.Uint64(id[len(id)-8:]), nil
+	default:
+		return 0, fmt.Errorf("invalid trace or span ID length %d", len(id))
+	}
+}
+
+// otlpKindName returns the name of an OTLP span kind.
+func otlpKindName(kind uint64) string {
+	switch kind {
+	case otlpSpanKindInternal:
+		return "internal"
+	case otlpSpanKindServer:
+		return "server"
+	case otlpSpanKindClient:
+		return "client"
+	case otlpSpanKindProducer:
+		return "producer"
+	case otlpSpanKindConsumer:
+		return "consumer"
+	default:
+		return "unspecified"
+	}
+}
+
+// setOTLPAttributes sets the string and boolean attributes as tags of span, and the
+// numeric ones as its metrics.
+func setOTLPAttributes(span *pb.Span, attributes []otlpKeyValue) {
+	for _, kv := range attributes {
+		switch v := kv.value.(type) {
+		case string:
+			span.Meta[kv.key] = v
+		case bool:
+			span.Meta[kv.key] = strconv.FormatBool(v)
+		case int64:
+			span.Metrics[kv.key] = float64(v)
+		case float64:
+			span.Metrics[kv.key] = v
+		}
+	}
+}
+
+// convertOTLPSpan converts an OTLP span of the given resource and instrumentation library
+// to a Datadog span.
+func convertOTLPSpan(rs *otlpResourceSpans, lib *otlpLibrarySpans, s *otlpSpan) (*pb.Span, error) {
+	span := &pb.Span{
+		Service:  otlpAttribute(rs.attributes, "service.name"),
+		Resource: s.name,
+		Start:    int64(s.start),
+		Meta:     make(map[string]string, len(rs.attributes)+len(s.attributes)+3),
+		Metrics:  make(map[string]float64),
+	}
+	var err error
+	if span.TraceID, err = otlpID(s.traceID); err != nil {
+		return nil, err
+	}
+	if span.SpanID, err = otlpID(s.spanID); err != nil {
+		return nil, err
+	}
+	if span.ParentID, err = otlpID(s.parentID); err != nil {
+		return nil, err
+	}
+	if s.end > s.start {
+		span.Duration = int64(s.end - s.start)
+	}
+	if span.Service == "" {
+		span.Service = "unknown_service"
+	}
+
+	// the operation name is made of the instrumentation library and the span kind, such
+	// as "net/http.server", while the OTLP span name is the resource
+	library := lib.name
+	if library == "" {
+		library = "opentelemetry"
+	}
+	kind := otlpKindName(s.kind)
+	span.Name = library + "." + kind
+
+	setOTLPAttributes(span, rs.attributes)
+	setOTLPAttributes(span, s.attributes)
+	span.Meta["span.kind"] = kind
+	if lib.name != "" {
+		span.Meta["otel.library.name"] = lib.name
+	}
+	if lib.version != "" {
+		span.Meta["otel.library.version"] = lib.version
+	}
+	if env := otlpAttribute(rs.attributes, "deployment.environment"); env != "" {
+		span.Meta["env"] = env
+	}
+	if version := otlpAttribute(rs.attributes, "service.version"); version != "" {
+		span.Meta["version"] = version
+	}
+
+	switch {
+	case span.Meta["db.system"] != "":
+		span.Type = "db"
+	case s.kind == otlpSpanKindServer:
+		span.Type = "web"
+	case s.kind == otlpSpanKindClient && span.Meta["http.method"] != "":
+		span.Type = "http"
+	default:
+		span.Type = "custom"
+	}
+
+	// the status code replaced the deprecated one, which only tells an error when the
+	// status code is unset, see:
+	// https://github.com/open-telemetry/opentelemetry-proto/blob/master/opentelemetry/proto/trace/v1/trace.proto
+	if s.statusCode == otlpStatusCodeError || (s.statusCode == 0 && s.deprecatedStatusCode != 0) {
+		span.Error = 1
+		if s.statusMessage != "" {
+			span.Meta["error.msg"] = s.statusMessage
+		}
+	}
+	for _, event := range s.events {
+		if event.name != "exception" {
+			continue
+		}
+		for key, tag := range map[string]string{
+			"exception.type":       "error.type",
+			"exception.message":    "error.msg",
+			"exception.stacktrace": "error.stack",
+		} {
+			if v := otlpAttribute(event.attributes, key); v != "" {
+				span.Meta[tag] = v
+			}
+		}
+	}
+	return span, nil
+}
+
+// convertOTLPResourceSpans converts the spans of an OTLP resource to a tracer payload,
+// grouping them by trace.
+func convertOTLPResourceSpans(rs *otlpResourceSpans) (*pb.TracerPayload, error) {
+	var traces pb.Traces
+	byID := make(map[uint64]int)
+	for _, lib := range rs.libraries {
+		for _, s := range lib.spans {
+			span, err := convertOTLPSpan(rs, lib, s)
+			if err != nil {
+				return nil, err
+			}
+			i, ok := byID[span.TraceID]
+			if !ok {
+				i = len(traces)
+				byID[span.TraceID] = i
+				traces = append(traces, nil)
+			}
+			traces[i] = append(traces[i], span)
+		}
+	}
+	return &pb.TracerPayload{
+		ContainerID:   otlpAttribute(rs.attributes, "container.id"),
+		LanguageName:  otlpAttribute(rs.attributes, "telemetry.sdk.language"),
+		TracerVersion: otlpAttribute(rs.attributes, "telemetry.sdk.version"),
+		Env:           otlpAttribute(rs.attributes, "deployment.environment"),
+		Hostname:      otlpAttribute(rs.attributes, "host.name"),
+		AppVersion:    otlpAttribute(rs.attributes, "service.version"),
+		Chunks:        traceChunksFromTraces(traces),
+	}, nil
+}
+
+// processOTLP converts the spans of an OTLP export request sent from the given address with
+// the given headers and sends them to the agent, in a payload for each resource. The size of
+// the request is accounted to its first payload.
+func (r *HTTPReceiver) processOTLP(data []byte, transport, addr string, header http.Header) error {
+	rss, err := decodeOTLPRequest(data)
+	if err != nil {
+		atomic.AddInt64(&r.Stats.GetTagStats(info.Tags{EndpointVersion: otlpEndpointVersion, Transport: transport}).TracesDropped.DecodingError, 1)
+		return err
+	}
+	// all the resources are converted before any is sent, so that a request failing on one
+	// of them is not partially accepted, which would duplicate spans when it is retried
+	tps := make([]*pb.TracerPayload, len(rss))
+	tss := make([]*info.TagStats, len(rss))
+	for i, rs := range rss {
+		tss[i] = r.Stats.GetTagStats(info.Tags{
+			Lang:            otlpAttribute(rs.attributes, "telemetry.sdk.language"),
+			TracerVersion:   otlpAttribute(rs.attributes, "telemetry.sdk.version"),
+			EndpointVersion: otlpEndpointVersion,
+			Transport:       transport,
+		})
+		if tps[i], err = convertOTLPResourceSpans(rs); err != nil {
+			atomic.AddInt64(&tss[i].TracesDropped.DecodingError, 1)
+			return err
+		}
+	}
+	size := int64(len(data))
+	for i, tp := range tps {
+		ts := tss[i]
+		if len(tp.Chunks) == 0 {
+			continue
+		}
+		if r.rateLimited(payloadOrigin("", addr), int64(len(tp.Chunks))) {
+			atomic.AddInt64(&ts.PayloadRefused, 1)
+			continue
+		}
+		atomic.AddInt64(&ts.TracesReceived, int64(len(tp.Chunks)))
+		atomic.AddInt64(&ts.TracesBytes, size)
+		atomic.AddInt64(&ts.PayloadAccepted, 1)
+		size = 0
+		r.sendPayload(&Payload{
+			Source:        ts,
+			TracerPayload: tp,
+			ContainerTags: getContainerTags(tp.ContainerID),
+		}, header)
+	}
+	return nil
+}
+
+// handleOTLPTraces handles the OTLP/HTTP export requests, which hold spans encoded in
+// protobuf.
+func (r *HTTPReceiver) handleOTLPTraces(w http.ResponseWriter, req *http.Request) {
+	if mediaType := getMediaType(req); mediaType != "application/x-protobuf" {
+		httpFormatError(w, otlpEndpointVersion, fmt.Errorf("unsupported media type: %q", mediaType))
+		return
+	}
+	data, err := ioutil.ReadAll(NewLimitedReader(req.Body, r.conf.MaxRequestBytes))
+	if err == nil {
+		err = r.processOTLP(data, requestTransport(req), req.RemoteAddr, req.Header)
+	}
+	if err != nil {
+		httpDecodingError(err, []string{"handler:otlp"}, w)
+		log.Errorf("Cannot decode OTLP traces payload: %v", err)
+		return
+	}
+	// the response is an empty ExportTraceServiceResponse
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+// otlpExportRequest is an OTLP ExportTraceServiceRequest received over gRPC, of which the
+// encoded message is kept to be decoded by processOTLP.
+type otlpExportRequest struct {
+	data []byte
+}
+
+func (m *otlpExportRequest) Reset()         { *m = otlpExportRequest{} }
+func (m *otlpExportRequest) String() string { return "ExportTraceServiceRequest" }
+func (*otlpExportRequest) ProtoMessage()    {}
+
+// Unmarshal implements proto.Unmarshaler.
+func (m *otlpExportRequest) Unmarshal(data []byte) error {
+	m.data = append(m.data[:0], data...)
+	return nil
+}
+
+// otlpExportResponse is an OTLP ExportTraceServiceResponse, which has no fields.
+type otlpExportResponse struct{}
+
+func (m *otlpExportResponse) Reset()         {}
+func (m *otlpExportResponse) String() string { return "ExportTraceServiceResponse" }
+func (*otlpExportResponse) ProtoMessage()    {}
+
+// Marshal implements proto.Marshaler.
+func (m *otlpExportResponse) Marshal() ([]byte, error) { return nil, nil }
+
+// otlpTraceServer is the server API of the OTLP trace service.
+type otlpTraceServer interface {
+	Export(context.Context, *otlpExportRequest) (*otlpExportResponse, error)
+}
+
+// otlpIntake implements the OTLP trace service.
+type otlpIntake struct {
+	r *HTTPReceiver
+}
+
+// Export implements otlpTraceServer.
+func (o *otlpIntake) Export(ctx context.Context, req *otlpExportRequest) (*otlpExportResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if !grpcAuthorized(ctx, md, o.r.conf.ReceiverAuthToken) {
+		metrics.Count(receiverErrorKey, 1, []string{"error:unauthorized"}, 1)
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+	}
+	if err := o.r.processOTLP(req.data, grpcTransport(ctx), grpcPeerAddr(ctx), metadataHeader(md)); err != nil {
+		metrics.Count(receiverErrorKey, 1, []string{"handler:otlp", "error:decoding-error"}, 1)
+		return nil, status.Errorf(codes.InvalidArgument, "cannot decode traces: %v", err)
+	}
+	return &otlpExportResponse{}, nil
+}
+
+func otlpExportHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(otlpExportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(otlpTraceServer).Export(ctx, in)
+	}
+	serverInfo := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + otlpServiceName + "/Export",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(otlpTraceServer).Export(ctx, req.(*otlpExportRequest))
+	}
+	return interceptor(ctx, in, serverInfo, handler)
+}
+
+var otlpServiceDesc = grpc.ServiceDesc{
+	ServiceName: otlpServiceName,
+	HandlerType: (*otlpTraceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Export",
+			Handler:    otlpExportHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "opentelemetry/proto/collector/trace/v1/trace_service.proto",
+}
@@ -0,0 +1,230 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// otlpLogsTimeout is the timeout of the connection to the logs pipeline, and of each write to it.
+const otlpLogsTimeout = 5 * time.Second
+
+// otlpLogRecord is an OTLP log record.
+type otlpLogRecord struct {
+	time           uint64
+	severityNumber uint64
+	severityText   string
+	body           interface{}
+	attributes     []otlpKeyValue
+	traceID        []byte
+	spanID         []byte
+}
+
+// otlpResourceLogs holds the log records of a resource, such as a service instance.
+type otlpResourceLogs struct {
+	attributes []otlpKeyValue
+	records    []*otlpLogRecord
+}
+
+// decodeOTLPLogsRequest decodes an ExportLogsServiceRequest message.
+func decodeOTLPLogsRequest(data []byte) ([]*otlpResourceLogs, error) {
+	var rls []*otlpResourceLogs
+	err := walkProto(data, func(key, _ uint64, b []byte) error {
+		if key != protoKey(1, wireBytes) { // resource_logs
+			return nil
+		}
+		rl := &otlpResourceLogs{}
+		rls = append(rls, rl)
+		return walkProto(b, func(key, _ uint64, b []byte) error {
+			switch key {
+			case protoKey(1, wireBytes): // resource
+				var err error
+				rl.attributes, err = decodeOTLPResource(b)
+				return err
+			case protoKey(2, wireBytes): // instrumentation_library_logs
+				return walkProto(b, func(key, _ uint64, b []byte) error {
+					if key != protoKey(2, wireBytes) { // log_records
+						return nil
+					}
+					record, err := decodeOTLPLogRecord(b)
+					rl.records = append(rl.records, record)
+					return err
+				})
+			}
+			return nil
+		})
+	})
+	return rls, err
+}
+
+func decodeOTLPLogRecord(data []byte) (*otlpLogRecord, error) {
+	record := &otlpLogRecord{}
+	return record, walkProto(data, func(key, v uint64, b []byte) error {
+		switch key {
+		case protoKey(1, wireFixed64):
+			record.time = v
+		case protoKey(2, wireVarint):
+			record.severityNumber = v
+		case protoKey(3, wireBytes):
+			record.severityText = string(b)
+		case protoKey(5, wireBytes):
+			var err error
+			record.body, err = decodeOTLPAnyValue(b)
+			return err
+		case protoKey(6, wireBytes):
+			kv, err := decodeOTLPKeyValue(b)
+			record.attributes = append(record.attributes, kv)
+			return err
+		case protoKey(9, wireBytes):
+			record.traceID = b
+		case protoKey(10, wireBytes):
+			record.spanID = b
+		}
+		return nil
+	})
+}
+
+// otlpLogStatus returns the status of a log record, given by its severity.
+func otlpLogStatus(record *otlpLogRecord) string {
+	if record.severityText != "" {
+		return strings.ToLower(record.severityText)
+	}
+	switch n := record.severityNumber; {
+	case n == 0:
+		return ""
+	case n <= 4:
+		return "trace"
+	case n <= 8:
+		return "debug"
+	case n <= 12:
+		return "info"
+	case n <= 16:
+		return "warn"
+	case n <= 20:
+		return "error"
+	default:
+		return "fatal"
+	}
+}
+
+// convertOTLPLogRecord returns the JSON log of a record of the resource rl. Its attributes are
+// attributes of the log, and the attributes of the resource are its tags.
+func convertOTLPLogRecord(rl *otlpResourceLogs, record *otlpLogRecord) ([]byte, error) {
+	entry := make(map[string]interface{}, len(record.attributes)+7)
+	for _, kv := range record.attributes {
+		if kv.value != nil {
+			entry[kv.key] = kv.value
+		}
+	}
+	if record.body != nil {
+		entry["message"] = record.body
+	}
+	if status := otlpLogStatus(record); status != "" {
+		entry["status"] = status
+	}
+	if record.time != 0 {
+		entry["timestamp"] = record.time / uint64(time.Millisecond)
+	}
+	if service := otlpAttribute(rl.attributes, "service.name"); service != "" {
+		entry["service"] = service
+	}
+	if tags := otlpTags(rl.attributes); len(tags) > 0 {
+		entry["ddtags"] = strings.Join(tags, ",")
+	}
+	traceID, err := otlpID(record.traceID)
+	if err != nil {
+		return nil, err
+	}
+	spanID, err := otlpID(record.spanID)
+	if err != nil {
+		return nil, err
+	}
+	if traceID != 0 {
+		entry["dd.trace_id"] = strconv.FormatUint(traceID, 10)
+	}
+	if spanID != 0 {
+		entry["dd.span_id"] = strconv.FormatUint(spanID, 10)
+	}
+	return json.Marshal(entry)
+}
+
+// otlpLogsPipeline sends the OTLP log records, as JSON lines, to a TCP source of the logs
+// pipeline of the Agent.
+type otlpLogsPipeline struct {
+	addr string
+
+	mu   sync.Mutex // guards conn
+	conn net.Conn   // nil until connected
+}
+
+func newOTLPLogsPipeline(addr string) *otlpLogsPipeline {
+	return &otlpLogsPipeline{addr: addr}
+}
+
+// consume implements otlpPipeline.
+func (p *otlpLogsPipeline) consume(data []byte) error {
+	rls, err := decodeOTLPLogsRequest(data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, rl := range rls {
+		for _, record := range rl.records {
+			line, err := convertOTLPLogRecord(rl, record)
+			if err != nil {
+				return err
+			}
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	if err := p.write(buf.Bytes()); err != nil {
+		return &otlpPipelineError{err: err}
+	}
+	return nil
+}
+
+// write writes the lines to the logs pipeline, connecting to it if needed.
+func (p *otlpLogsPipeline) write(lines []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		conn, err := net.DialTimeout("tcp", p.addr, otlpLogsTimeout)
+		if err != nil {
+			return err
+		}
+		p.conn = conn
+	}
+	p.conn.SetWriteDeadline(time.Now().Add(otlpLogsTimeout)) //nolint:errcheck
+	if _, err := p.conn.Write(lines); err != nil {
+		// reconnect on the next request, which the client retries
+		p.conn.Close()
+		p.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection to the logs pipeline.
+func (p *otlpLogsPipeline) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
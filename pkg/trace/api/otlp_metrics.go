@@ -0,0 +1,263 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// otlpMaxCumulativeSeries caps the number of series of cumulative metrics of which the last value
+// is kept to compute their deltas.
+const otlpMaxCumulativeSeries = 10000
+
+// OTLP metric types, given by the field of the Metric message holding the data.
+const (
+	otlpMetricGauge     = 5
+	otlpMetricSum       = 7
+	otlpMetricHistogram = 9
+	otlpMetricSummary   = 11
+)
+
+// otlpTemporalityCumulative is the aggregation temporality of the metrics reporting the total
+// since their start time, rather than the change since their last point.
+const otlpTemporalityCumulative = 2
+
+// otlpDataPoint is a data point of an OTLP metric.
+type otlpDataPoint struct {
+	attributes []otlpKeyValue
+	start      uint64  // start time of the cumulative series the point belongs to
+	value      float64 // value of a gauge or sum
+	count      uint64  // count of a histogram or summary
+	sum        float64 // sum of a histogram or summary
+}
+
+// otlpMetric is an OTLP metric.
+type otlpMetric struct {
+	name       string
+	typ        uint64
+	cumulative bool
+	monotonic  bool
+	points     []otlpDataPoint
+}
+
+// otlpResourceMetrics holds the metrics of a resource, such as a service instance.
+type otlpResourceMetrics struct {
+	attributes []otlpKeyValue
+	metrics    []*otlpMetric
+}
+
+// decodeOTLPMetricsRequest decodes an ExportMetricsServiceRequest message.
+func decodeOTLPMetricsRequest(data []byte) ([]*otlpResourceMetrics, error) {
+	var rms []*otlpResourceMetrics
+	err := walkProto(data, func(key, _ uint64, b []byte) error {
+		if key != protoKey(1, wireBytes) { // resource_metrics
+			return nil
+		}
+		rm := &otlpResourceMetrics{}
+		rms = append(rms, rm)
+		return walkProto(b, func(key, _ uint64, b []byte) error {
+			switch key {
+			case protoKey(1, wireBytes): // resource
+				var err error
+				rm.attributes, err = decodeOTLPResource(b)
+				return err
+			case protoKey(2, wireBytes): // instrumentation_library_metrics
+				return walkProto(b, func(key, _ uint64, b []byte) error {
+					if key != protoKey(2, wireBytes) { // metrics
+						return nil
+					}
+					m, err := decodeOTLPMetric(b)
+					rm.metrics = append(rm.metrics, m)
+					return err
+				})
+			}
+			return nil
+		})
+	})
+	return rms, err
+}
+
+func decodeOTLPMetric(data []byte) (*otlpMetric, error) {
+	m := &otlpMetric{}
+	return m, walkProto(data, func(key, _ uint64, b []byte) error {
+		switch key {
+		case protoKey(1, wireBytes):
+			m.name = string(b)
+		case protoKey(otlpMetricGauge, wireBytes), protoKey(otlpMetricSum, wireBytes), protoKey(otlpMetricHistogram, wireBytes), protoKey(otlpMetricSummary, wireBytes):
+			m.typ = key >> 3
+			// summaries are always cumulative
+			m.cumulative = m.typ == otlpMetricSummary
+			return walkProto(b, func(key, v uint64, b []byte) error {
+				switch key {
+				case protoKey(1, wireBytes): // data_points
+					p, err := decodeOTLPDataPoint(m.typ, b)
+					m.points = append(m.points, p)
+					return err
+				case protoKey(2, wireVarint): // aggregation_temporality
+					m.cumulative = v == otlpTemporalityCumulative
+				case protoKey(3, wireVarint): // is_monotonic
+					m.monotonic = v != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+// decodeOTLPDataPoint decodes a NumberDataPoint, HistogramDataPoint or SummaryDataPoint
+// message, according to the type of the metric.
+func decodeOTLPDataPoint(typ uint64, data []byte) (otlpDataPoint, error) {
+	var p otlpDataPoint
+	attributes := protoKey(7, wireBytes)
+	if typ == otlpMetricHistogram {
+		attributes = protoKey(9, wireBytes)
+	}
+	err := walkProto(data, func(key, v uint64, b []byte) error {
+		switch key {
+		case attributes:
+			kv, err := decodeOTLPKeyValue(b)
+			p.attributes = append(p.attributes, kv)
+			return err
+		case protoKey(2, wireFixed64):
+			p.start = v
+		}
+		if typ == otlpMetricGauge || typ == otlpMetricSum {
+			switch key {
+			case protoKey(4, wireFixed64): // as_double
+				p.value = math.Float64frombits(v)
+			case protoKey(6, wireFixed64): // as_int
+				p.value = float64(int64(v))
+			}
+			return nil
+		}
+		switch key {
+		case protoKey(4, wireFixed64):
+			p.count = v
+		case protoKey(5, wireFixed64):
+			p.sum = math.Float64frombits(v)
+		}
+		return nil
+	})
+	return p, err
+}
+
+// otlpTags returns the attributes as tags.
+func otlpTags(attributes ...[]otlpKeyValue) []string {
+	var tags []string
+	for _, attrs := range attributes {
+		for _, kv := range attrs {
+			if kv.value != nil {
+				tags = append(tags, fmt.Sprintf("%s:%v", kv.key, kv.value))
+			}
+		}
+	}
+	return tags
+}
+
+// otlpCumulativePoint is the last point of a series of a cumulative metric.
+type otlpCumulativePoint struct {
+	start uint64
+	value float64
+}
+
+// otlpMetricsPipeline sends the OTLP metrics to DogStatsD, so that they go through the metrics
+// pipeline of the Agent. Gauges and non-monotonic cumulative sums are sent as gauges, the other
+// sums as counts, and histograms and summaries as the counts of their count and sum. Counts are
+// rounded to integers, as DogStatsD counts are.
+type otlpMetricsPipeline struct {
+	client metrics.StatsClient
+
+	mu         sync.Mutex
+	cumulative map[string]otlpCumulativePoint // by series
+}
+
+func newOTLPMetricsPipeline(client metrics.StatsClient) *otlpMetricsPipeline {
+	return &otlpMetricsPipeline{
+		client:     client,
+		cumulative: make(map[string]otlpCumulativePoint),
+	}
+}
+
+// consume implements otlpPipeline.
+func (p *otlpMetricsPipeline) consume(data []byte) error {
+	rms, err := decodeOTLPMetricsRequest(data)
+	if err != nil {
+		return err
+	}
+	for _, rm := range rms {
+		for _, m := range rm.metrics {
+			for _, point := range m.points {
+				p.send(m, point, otlpTags(rm.attributes, point.attributes))
+			}
+		}
+	}
+	return nil
+}
+
+// send sends a data point of the metric m.
+func (p *otlpMetricsPipeline) send(m *otlpMetric, point otlpDataPoint, tags []string) {
+	switch m.typ {
+	case otlpMetricGauge:
+		p.client.Gauge(m.name, point.value, tags, 1) //nolint:errcheck
+	case otlpMetricSum:
+		switch {
+		case !m.cumulative:
+			p.client.Count(m.name, int64(math.Round(point.value)), tags, 1) //nolint:errcheck
+		case m.monotonic:
+			p.sendCumulative(m.name, point.start, point.value, tags)
+		default:
+			p.client.Gauge(m.name, point.value, tags, 1) //nolint:errcheck
+		}
+	case otlpMetricHistogram, otlpMetricSummary:
+		if m.cumulative {
+			p.sendCumulative(m.name+".count", point.start, float64(point.count), tags)
+			p.sendCumulative(m.name+".sum", point.start, point.sum, tags)
+			return
+		}
+		p.client.Count(m.name+".count", int64(point.count), tags, 1)         //nolint:errcheck
+		p.client.Count(m.name+".sum", int64(math.Round(point.sum)), tags, 1) //nolint:errcheck
+	}
+}
+
+// sendCumulative sends the change of a cumulative series since its last point as a count. Nothing
+// is sent for the first point of a series, of which the change is unknown.
+func (p *otlpMetricsPipeline) sendCumulative(name string, start uint64, value float64, tags []string) {
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	key := name + "|" + strings.Join(sorted, ",")
+
+	p.mu.Lock()
+	last, ok := p.cumulative[key]
+	if !ok && len(p.cumulative) >= otlpMaxCumulativeSeries {
+		log.Debugf("Too many series of cumulative OTLP metrics (%d), restarting the computation of their deltas", len(p.cumulative))
+		p.cumulative = make(map[string]otlpCumulativePoint)
+	}
+	p.cumulative[key] = otlpCumulativePoint{start: start, value: value}
+	p.mu.Unlock()
+
+	var delta float64
+	switch {
+	case !ok:
+		return
+	case start != last.start:
+		// the series restarted from zero
+		delta = value
+	case value < last.value:
+		// the series was reset without changing its start time
+		return
+	default:
+		delta = value - last.value
+	}
+	p.client.Count(name, int64(math.Round(delta)), tags, 1) //nolint:errcheck
+}
@@ -0,0 +1,232 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// otlpSignal is an OTLP signal other than traces, which the receiver sends to a pipeline of the
+// Agent when one is configured for it, or rejects with guidance.
+type otlpSignal struct {
+	name     string // name of the signal
+	setting  string // setting enabling the pipeline of the signal
+	path     string // path of the OTLP/HTTP endpoint
+	service  string // name of the gRPC service
+	metadata string // proto file of the gRPC service
+}
+
+var (
+	otlpLogs = &otlpSignal{
+		name:     "logs",
+		setting:  "apm_config.otlp_pipelines.logs_port",
+		path:     "/v1/logs",
+		service:  "opentelemetry.proto.collector.logs.v1.LogsService",
+		metadata: "opentelemetry/proto/collector/logs/v1/logs_service.proto",
+	}
+	otlpMetrics = &otlpSignal{
+		name:     "metrics",
+		setting:  "apm_config.otlp_pipelines.metrics",
+		path:     "/v1/metrics",
+		service:  "opentelemetry.proto.collector.metrics.v1.MetricsService",
+		metadata: "opentelemetry/proto/collector/metrics/v1/metrics_service.proto",
+	}
+)
+
+// otlpPipeline is a pipeline of the Agent consuming the export requests of an OTLP signal.
+type otlpPipeline interface {
+	// consume decodes the export request data and sends its content to the pipeline.
+	consume(data []byte) error
+}
+
+// otlpPipelineError is returned by an otlpPipeline which can't reach the pipeline of the Agent.
+// The request is valid and should be retried.
+type otlpPipelineError struct {
+	err error
+}
+
+func (e *otlpPipelineError) Error() string { return e.err.Error() }
+
+func (e *otlpPipelineError) Unwrap() error { return e.err }
+
+// otlpPipelinesFromConfig returns the pipelines of the OTLP signals enabled in conf, by signal name.
+func otlpPipelinesFromConfig(conf *config.AgentConfig) map[string]otlpPipeline {
+	pipelines := make(map[string]otlpPipeline)
+	if conf.OTLPMetricsPipeline {
+		// the metrics have their own client, which doesn't add the tags of the trace agent
+		client, err := statsd.New(net.JoinHostPort(conf.StatsdHost, strconv.Itoa(conf.StatsdPort)))
+		if err != nil {
+			log.Errorf("Error creating the DogStatsD client of the OTLP metrics, OTLP metrics are rejected: %v", err)
+		} else {
+			pipelines[otlpMetrics.name] = newOTLPMetricsPipeline(client)
+		}
+	}
+	if conf.OTLPLogsPipelinePort > 0 {
+		pipelines[otlpLogs.name] = newOTLPLogsPipeline(net.JoinHostPort("localhost", strconv.Itoa(conf.OTLPLogsPipelinePort)))
+	}
+	return pipelines
+}
+
+// otlpGuidance returns the message explaining why the signal s is rejected and where it should
+// be sent instead.
+func (r *HTTPReceiver) otlpGuidance(s *otlpSignal) string {
+	msg := fmt.Sprintf("the Datadog trace agent only accepts OTLP traces (on %s and the %s gRPC service), not OTLP %s. ", "/v1/traces", otlpServiceName, s.name) +
+		fmt.Sprintf("Set %s to have the trace agent send OTLP %s to the Agent, or send them to an OpenTelemetry Collector with the Datadog exporter", s.setting, s.name)
+	if s == otlpMetrics {
+		msg += fmt.Sprintf(". Metrics can also be sent to DogStatsD on %s:%d", r.conf.StatsdHost, r.conf.StatsdPort)
+	}
+	return msg
+}
+
+// handleOTLPSignal returns the handler of the OTLP/HTTP endpoint of the signal s, of which the
+// requests hold protobuf messages.
+func (r *HTTPReceiver) handleOTLPSignal(s *otlpSignal) http.HandlerFunc {
+	tags := []string{"handler:otlp_" + s.name}
+	return func(w http.ResponseWriter, req *http.Request) {
+		pipeline, ok := r.otlpPipelines[s.name]
+		if !ok {
+			metrics.Count(receiverErrorKey, 1, append(tags, "error:unsupported-signal"), 1)
+			writeOTLPStatus(w, req, http.StatusNotImplemented, codes.Unimplemented, r.otlpGuidance(s))
+			return
+		}
+		if mediaType := getMediaType(req); mediaType != "application/x-protobuf" {
+			metrics.Count(receiverErrorKey, 1, append(tags, "error:format-error"), 1)
+			writeOTLPStatus(w, req, http.StatusUnsupportedMediaType, codes.InvalidArgument, fmt.Sprintf("unsupported media type: %q", mediaType))
+			return
+		}
+		data, err := ioutil.ReadAll(NewLimitedReader(req.Body, r.conf.MaxRequestBytes))
+		if err != nil {
+			httpDecodingError(err, tags, w)
+			return
+		}
+		if err := pipeline.consume(data); err != nil {
+			var perr *otlpPipelineError
+			if errors.As(err, &perr) {
+				metrics.Count(receiverErrorKey, 1, append(tags, "error:pipeline-error"), 1)
+				log.Errorf("Error sending OTLP %s to the Agent: %v", s.name, err)
+				writeOTLPStatus(w, req, http.StatusServiceUnavailable, codes.Unavailable, fmt.Sprintf("error sending OTLP %s to the Agent: %v", s.name, err))
+				return
+			}
+			metrics.Count(receiverErrorKey, 1, append(tags, "error:decoding-error"), 1)
+			log.Errorf("Cannot decode OTLP %s payload: %v", s.name, err)
+			writeOTLPStatus(w, req, http.StatusBadRequest, codes.InvalidArgument, fmt.Sprintf("cannot decode OTLP %s: %v", s.name, err))
+			return
+		}
+		// the response is an empty export response
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// writeOTLPStatus responds to an OTLP/HTTP request with the given status, along with a
+// google.rpc.Status message holding code and msg, encoded like the request.
+func writeOTLPStatus(w http.ResponseWriter, req *http.Request, httpStatus int, code codes.Code, msg string) {
+	if getMediaType(req) == "application/json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(httpStatus)
+		json.NewEncoder(w).Encode(struct { //nolint:errcheck
+			Code    codes.Code `json:"code"`
+			Message string     `json:"message"`
+		}{code, msg})
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(httpStatus)
+	w.Write(encodeRPCStatus(code, msg)) //nolint:errcheck
+}
+
+// encodeRPCStatus encodes a google.rpc.Status message holding code and msg.
+func encodeRPCStatus(code codes.Code, msg string) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	b := make([]byte, 0, len(msg)+2*binary.MaxVarintLen64+2)
+	b = append(b, buf[:binary.PutUvarint(buf[:], protoKey(1, wireVarint))]...)
+	b = append(b, buf[:binary.PutUvarint(buf[:], uint64(code))]...)
+	b = append(b, buf[:binary.PutUvarint(buf[:], protoKey(2, wireBytes))]...)
+	b = append(b, buf[:binary.PutUvarint(buf[:], uint64(len(msg)))]...)
+	return append(b, msg...)
+}
+
+// otlpSignalServer is the server API of the OTLP services of the signals other than traces.
+type otlpSignalServer interface {
+	ExportSignal(context.Context, *otlpSignal, *otlpExportRequest) (*otlpExportResponse, error)
+}
+
+// ExportSignal implements otlpSignalServer. The export requests hold the same protobuf messages
+// as the OTLP/HTTP requests.
+func (o *otlpIntake) ExportSignal(ctx context.Context, s *otlpSignal, req *otlpExportRequest) (*otlpExportResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if !grpcAuthorized(ctx, md, o.r.conf.ReceiverAuthToken) {
+		metrics.Count(receiverErrorKey, 1, []string{"error:unauthorized"}, 1)
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+	}
+	tags := []string{"handler:otlp_" + s.name}
+	pipeline, ok := o.r.otlpPipelines[s.name]
+	if !ok {
+		metrics.Count(receiverErrorKey, 1, append(tags, "error:unsupported-signal"), 1)
+		return nil, status.Error(codes.Unimplemented, o.r.otlpGuidance(s))
+	}
+	if err := pipeline.consume(req.data); err != nil {
+		var perr *otlpPipelineError
+		if errors.As(err, &perr) {
+			metrics.Count(receiverErrorKey, 1, append(tags, "error:pipeline-error"), 1)
+			return nil, status.Errorf(codes.Unavailable, "error sending OTLP %s to the Agent: %v", s.name, err)
+		}
+		metrics.Count(receiverErrorKey, 1, append(tags, "error:decoding-error"), 1)
+		return nil, status.Errorf(codes.InvalidArgument, "cannot decode OTLP %s: %v", s.name, err)
+	}
+	return &otlpExportResponse{}, nil
+}
+
+// otlpSignalServiceDesc returns the description of the gRPC service of the signal s.
+func otlpSignalServiceDesc(s *otlpSignal) *grpc.ServiceDesc {
+	handler := func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := new(otlpExportRequest)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return srv.(otlpSignalServer).ExportSignal(ctx, s, in)
+		}
+		serverInfo := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: "/" + s.service + "/Export",
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return srv.(otlpSignalServer).ExportSignal(ctx, s, req.(*otlpExportRequest))
+		}
+		return interceptor(ctx, in, serverInfo, handler)
+	}
+	return &grpc.ServiceDesc{
+		ServiceName: s.service,
+		HandlerType: (*otlpSignalServer)(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "Export",
+				Handler:    handler,
+			},
+		},
+		Streams:  []grpc.StreamDesc{},
+		Metadata: s.metadata,
+	}
+}
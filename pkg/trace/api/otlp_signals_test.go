@@ -0,0 +1,213 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOTLPSignalRejected(t *testing.T) {
+	r := newTestReceiverFromConfig(newTestReceiverConfig())
+	server := httptest.NewServer(r.handleOTLPSignal(otlpLogs))
+	defer server.Close()
+
+	t.Run("protobuf", func(t *testing.T) {
+		assert := assert.New(t)
+		resp, err := http.Post(server.URL, "application/x-protobuf", bytes.NewReader([]byte{0x0a, 0x00}))
+		assert.NoError(err)
+		defer resp.Body.Close()
+		assert.Equal(http.StatusNotImplemented, resp.StatusCode)
+		assert.Equal("application/x-protobuf", resp.Header.Get("Content-Type"))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(err)
+		var (
+			code uint64
+			msg  string
+		)
+		assert.NoError(walkProto(body, func(key, v uint64, b []byte) error {
+			switch key {
+			case protoKey(1, wireVarint):
+				code = v
+			case protoKey(2, wireBytes):
+				msg = string(b)
+			}
+			return nil
+		}))
+		assert.EqualValues(codes.Unimplemented, code)
+		assert.Contains(msg, "/v1/traces")
+		assert.Contains(msg, "apm_config.otlp_pipelines.logs_port")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		assert := assert.New(t)
+		resp, err := http.Post(server.URL, "application/json", bytes.NewReader([]byte("{}")))
+		assert.NoError(err)
+		defer resp.Body.Close()
+		assert.Equal(http.StatusNotImplemented, resp.StatusCode)
+		var st struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		assert.NoError(json.NewDecoder(resp.Body).Decode(&st))
+		assert.Equal(int(codes.Unimplemented), st.Code)
+		assert.Contains(st.Message, "OTLP logs")
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		_, err := (&otlpIntake{r: r}).ExportSignal(context.Background(), otlpMetrics, &otlpExportRequest{})
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+		assert.Contains(t, status.Convert(err).Message(), "DogStatsD")
+	})
+}
+
+func TestOTLPMetricsPipeline(t *testing.T) {
+	assert := assert.New(t)
+	client := &testutil.TestStatsClient{}
+	r := newTestReceiverFromConfig(newTestReceiverConfig())
+	r.otlpPipelines = map[string]otlpPipeline{otlpMetrics.name: newOTLPMetricsPipeline(client)}
+	server := httptest.NewServer(r.handleOTLPSignal(otlpMetrics))
+	defer server.Close()
+
+	request := func(cumulative float64) []byte {
+		point := func(value float64, attrs ...interface{}) []byte {
+			fields := []interface{}{2, wireFixed64, uint64(1), 4, wireFixed64, math.Float64bits(value)}
+			for _, attr := range attrs {
+				fields = append(fields, 7, wireBytes, attr)
+			}
+			return protoMessage(fields...)
+		}
+		metrics := protoMessage(
+			2, wireBytes, protoMessage(
+				1, wireBytes, "queue.size",
+				otlpMetricGauge, wireBytes, protoMessage(1, wireBytes, point(12, otlpAttr("queue", "jobs"))),
+			),
+			2, wireBytes, protoMessage(
+				1, wireBytes, "jobs.done",
+				otlpMetricSum, wireBytes, protoMessage(1, wireBytes, point(3), 2, wireVarint, uint64(1), 3, wireVarint, uint64(1)),
+			),
+			2, wireBytes, protoMessage(
+				1, wireBytes, "jobs.total",
+				otlpMetricSum, wireBytes, protoMessage(1, wireBytes, point(cumulative), 2, wireVarint, uint64(otlpTemporalityCumulative), 3, wireVarint, uint64(1)),
+			),
+		)
+		resource := protoMessage(1, wireBytes, otlpAttr("service.name", "worker"))
+		return protoMessage(1, wireBytes, protoMessage(1, wireBytes, resource, 2, wireBytes, metrics))
+	}
+
+	resp, err := http.Post(server.URL, "application/x-protobuf", bytes.NewReader(request(10)))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	_, err = (&otlpIntake{r: r}).ExportSignal(context.Background(), otlpMetrics, &otlpExportRequest{data: request(14)})
+	assert.NoError(err)
+
+	assert.Equal([]testutil.MetricsArgs{
+		{Name: "queue.size", Value: 12, Tags: []string{"service.name:worker", "queue:jobs"}, Rate: 1},
+		{Name: "queue.size", Value: 12, Tags: []string{"service.name:worker", "queue:jobs"}, Rate: 1},
+	}, client.GaugeCalls)
+	// the first point of the cumulative sum only starts its series
+	assert.Equal([]testutil.MetricsArgs{
+		{Name: "jobs.done", Value: 3, Tags: []string{"service.name:worker"}, Rate: 1},
+		{Name: "jobs.done", Value: 3, Tags: []string{"service.name:worker"}, Rate: 1},
+		{Name: "jobs.total", Value: 4, Tags: []string{"service.name:worker"}, Rate: 1},
+	}, client.CountCalls)
+
+	resp, err = http.Post(server.URL, "application/x-protobuf", bytes.NewReader([]byte{0x0a, 0x05}))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Post(server.URL, "application/json", bytes.NewReader([]byte("{}")))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+func TestOTLPLogsPipeline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	conf := newTestReceiverConfig()
+	conf.OTLPLogsPipelinePort = ln.Addr().(*net.TCPAddr).Port
+	r := newTestReceiverFromConfig(conf)
+	assert.Len(t, r.otlpPipelines, 1)
+	server := httptest.NewServer(r.handleOTLPSignal(otlpLogs))
+	defer server.Close()
+
+	request := func() []byte {
+		record := protoMessage(
+			1, wireFixed64, uint64(1604232000123000000),
+			2, wireVarint, uint64(17),
+			5, wireBytes, protoMessage(1, wireBytes, "job failed"),
+			6, wireBytes, otlpAttr("job", "backup"),
+			9, wireBytes, []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 42},
+			10, wireBytes, []byte{0, 0, 0, 0, 0, 0, 0, 7},
+		)
+		resource := protoMessage(1, wireBytes, otlpAttr("service.name", "worker"), 1, wireBytes, otlpAttr("env", "prod"))
+		return protoMessage(1, wireBytes, protoMessage(1, wireBytes, resource, 2, wireBytes, protoMessage(2, wireBytes, record)))
+	}
+
+	t.Run("sent", func(t *testing.T) {
+		assert := assert.New(t)
+		resp, err := http.Post(server.URL, "application/x-protobuf", bytes.NewReader(request()))
+		assert.NoError(err)
+		resp.Body.Close()
+		assert.Equal(http.StatusOK, resp.StatusCode)
+
+		select {
+		case line := <-lines:
+			assert.JSONEq(`{
+				"message": "job failed",
+				"status": "error",
+				"timestamp": 1604232000123,
+				"service": "worker",
+				"ddtags": "service.name:worker,env:prod",
+				"job": "backup",
+				"dd.trace_id": "42",
+				"dd.span_id": "7"
+			}`, line)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no log received")
+		}
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		ln.Close()
+		r.otlpPipelines[otlpLogs.name].(*otlpLogsPipeline).Close()
+		_, err := (&otlpIntake{r: r}).ExportSignal(context.Background(), otlpLogs, &otlpExportRequest{data: request()})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
@@ -0,0 +1,233 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// protoMessage encodes the given fields, each made of a field number, a wire type and a value,
+// as a protobuf message. The values are uint64 for varint and fixed64 fields, and []byte or
+// string for length-delimited ones.
+func protoMessage(fields ...interface{}) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i += 3 {
+		num, wire := fields[i].(int), fields[i+1].(int)
+		b = appendUvarint(b, protoKey(uint64(num), uint64(wire)))
+		switch v := fields[i+2].(type) {
+		case uint64:
+			if wire == wireFixed64 {
+				var buf [8]byte
+				binary.LittleEndian.PutUint64(buf[:], v)
+				b = append(b, buf[:]...)
+			} else {
+				b = appendUvarint(b, v)
+			}
+		case string:
+			b = appendUvarint(b, uint64(len(v)))
+			b = append(b, v...)
+		case []byte:
+			b = appendUvarint(b, uint64(len(v)))
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// otlpAttr encodes an OTLP KeyValue holding a string, bool, int64 or float64.
+func otlpAttr(key string, value interface{}) []byte {
+	var v []byte
+	switch value := value.(type) {
+	case string:
+		v = protoMessage(1, wireBytes, value)
+	case bool:
+		var n uint64
+		if value {
+			n = 1
+		}
+		v = protoMessage(2, wireVarint, n)
+	case int:
+		v = protoMessage(3, wireVarint, uint64(value))
+	case float64:
+		v = protoMessage(4, wireFixed64, math.Float64bits(value))
+	}
+	return protoMessage(1, wireBytes, key, 2, wireBytes, v)
+}
+
+func testOTLPRequest() []byte {
+	traceID := []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 42}
+	start := uint64(time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC).UnixNano())
+	server := protoMessage(
+		1, wireBytes, traceID,
+		2, wireBytes, []byte{0, 0, 0, 0, 0, 0, 0, 1},
+		5, wireBytes, "GET /users",
+		6, wireVarint, uint64(otlpSpanKindServer),
+		7, wireFixed64, start,
+		8, wireFixed64, start+uint64(50*time.Millisecond),
+		9, wireBytes, otlpAttr("http.method", "GET"),
+		9, wireBytes, otlpAttr("http.status_code", 500),
+		9, wireBytes, otlpAttr("sampled", true),
+		11, wireBytes, protoMessage(
+			2, wireBytes, "exception",
+			3, wireBytes, otlpAttr("exception.type", "RuntimeError"),
+			3, wireBytes, otlpAttr("exception.message", "boom"),
+		),
+		15, wireBytes, protoMessage(2, wireBytes, "internal error", 3, wireVarint, uint64(otlpStatusCodeError)),
+	)
+	client := protoMessage(
+		1, wireBytes, traceID,
+		2, wireBytes, []byte{0, 0, 0, 0, 0, 0, 0, 2},
+		4, wireBytes, []byte{0, 0, 0, 0, 0, 0, 0, 1},
+		5, wireBytes, "SELECT users",
+		6, wireVarint, uint64(otlpSpanKindClient),
+		7, wireFixed64, start+uint64(10*time.Millisecond),
+		8, wireFixed64, start+uint64(20*time.Millisecond),
+		9, wireBytes, otlpAttr("db.system", "postgresql"),
+		9, wireBytes, otlpAttr("db.rows", 1.5),
+	)
+	other := protoMessage(
+		1, wireBytes, []byte{0, 0, 0, 0, 0, 0, 0, 7},
+		2, wireBytes, []byte{0, 0, 0, 0, 0, 0, 0, 3},
+		5, wireBytes, "cleanup",
+		7, wireFixed64, start,
+		8, wireFixed64, start+1000,
+	)
+	resource := protoMessage(
+		1, wireBytes, otlpAttr("service.name", "users"),
+		1, wireBytes, otlpAttr("deployment.environment", "prod"),
+		1, wireBytes, otlpAttr("service.version", "1.2.3"),
+		1, wireBytes, otlpAttr("telemetry.sdk.language", "go"),
+		1, wireBytes, otlpAttr("telemetry.sdk.version", "0.13.0"),
+	)
+	library := protoMessage(1, wireBytes, "net/http", 2, wireBytes, "0.13.0")
+	return protoMessage(1, wireBytes, protoMessage(
+		1, wireBytes, resource,
+		2, wireBytes, protoMessage(1, wireBytes, library, 2, wireBytes, server, 2, wireBytes, client),
+		2, wireBytes, protoMessage(2, wireBytes, other),
+	))
+}
+
+func TestConvertOTLP(t *testing.T) {
+	assert := assert.New(t)
+	rss, err := decodeOTLPRequest(testOTLPRequest())
+	assert.NoError(err)
+	assert.Len(rss, 1)
+
+	tp, err := convertOTLPResourceSpans(rss[0])
+	assert.NoError(err)
+	assert.Equal("go", tp.LanguageName)
+	assert.Equal("0.13.0", tp.TracerVersion)
+	assert.Equal("prod", tp.Env)
+	assert.Len(tp.Chunks, 2)
+	assert.Len(tp.Chunks[0].Spans, 2)
+	assert.Len(tp.Chunks[1].Spans, 1)
+
+	server := tp.Chunks[0].Spans[0]
+	assert.Equal(&pb.Span{
+		Service:  "users",
+		Name:     "net/http.server",
+		Resource: "GET /users",
+		TraceID:  42,
+		SpanID:   1,
+		Start:    time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC).UnixNano(),
+		Duration: int64(50 * time.Millisecond),
+		Error:    1,
+		Meta: map[string]string{
+			"service.name":           "users",
+			"deployment.environment": "prod",
+			"service.version":        "1.2.3",
+			"telemetry.sdk.language": "go",
+			"telemetry.sdk.version":  "0.13.0",
+			"http.method":            "GET",
+			"sampled":                "true",
+			"span.kind":              "server",
+			"otel.library.name":      "net/http",
+			"otel.library.version":   "0.13.0",
+			"env":                    "prod",
+			"version":                "1.2.3",
+			"error.type":             "RuntimeError",
+			"error.msg":              "boom",
+		},
+		Metrics: map[string]float64{"http.status_code": 500},
+		Type:    "web",
+	}, server)
+
+	client := tp.Chunks[0].Spans[1]
+	assert.EqualValues(1, client.ParentID)
+	assert.Equal("net/http.client", client.Name)
+	assert.Equal("db", client.Type)
+	assert.Equal(1.5, client.Metrics["db.rows"])
+	assert.EqualValues(0, client.Error)
+
+	other := tp.Chunks[1].Spans[0]
+	assert.Equal("opentelemetry.unspecified", other.Name)
+	assert.Equal("custom", other.Type)
+	assert.EqualValues(1000, other.Duration)
+}
+
+func TestDecodeOTLPErrors(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"truncated":       testOTLPRequest()[:100],
+		"wire-type":       {0x0b},
+		"invalid-span-id": protoMessage(1, wireBytes, protoMessage(2, wireBytes, protoMessage(2, wireBytes, protoMessage(2, wireBytes, []byte{1, 2, 3})))),
+		// a valid resource followed by an invalid one is not sent either
+		"partial": append(testOTLPRequest(), protoMessage(1, wireBytes, protoMessage(2, wireBytes, protoMessage(2, wireBytes, protoMessage(2, wireBytes, []byte{1, 2, 3}))))...),
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := newTestReceiverFromConfig(newTestReceiverConfig())
+			assert.Error(t, r.processOTLP(data, "", "", nil))
+			assert.Len(t, r.out, 0)
+		})
+	}
+}
+
+func TestHandleOTLPTraces(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestReceiverFromConfig(newTestReceiverConfig())
+	server := httptest.NewServer(http.HandlerFunc(r.handleOTLPTraces))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/x-protobuf", bytes.NewReader(testOTLPRequest()))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	select {
+	case p := <-r.out:
+		assert.Len(p.TracerPayload.Chunks, 2)
+	case <-time.After(time.Second):
+		t.Fatal("no payload received")
+	}
+	ts := r.Stats.GetTagStats(info.Tags{Lang: "go", TracerVersion: "0.13.0", EndpointVersion: otlpEndpointVersion})
+	assert.EqualValues(2, ts.TracesReceived)
+	assert.EqualValues(1, ts.PayloadAccepted)
+
+	resp, err = http.Post(server.URL, "application/json", bytes.NewReader([]byte("{}")))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	resp, err = http.Post(server.URL, "application/x-protobuf", bytes.NewReader([]byte{0x0a, 0xff}))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package api
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// cgroupContainerRe matches the container IDs found in the cgroup paths of a process.
+var cgroupContainerRe = regexp.MustCompile("[0-9a-f]{64}")
+
+// connOrigin returns the origin of the connection c for the rate limiter, as found from the
+// credentials of the peer process when c is a UDS connection: the ID of the container it runs
+// in, or else its PID. Unlike the headers of the requests, they can't be forged by the client.
+// It returns an empty string for the other connections, or when the peer is in another PID
+// namespace than the agent.
+func connOrigin(c net.Conn) string {
+	if hc, ok := c.(*hardenedConn); ok {
+		c = hc.Conn
+	}
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return ""
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return ""
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil || credErr != nil || cred.Pid == 0 {
+		return ""
+	}
+	if id := cgroupContainerID(int(cred.Pid)); id != "" {
+		return id
+	}
+	return "pid:" + strconv.Itoa(int(cred.Pid))
+}
+
+// cgroupContainerID returns the ID of the container the process pid runs in, as found in
+// its cgroup paths, or an empty string if it doesn't run in a container.
+func cgroupContainerID(pid int) string {
+	root := os.Getenv("HOST_PROC")
+	if root == "" {
+		root = "/proc"
+	}
+	f, err := os.Open(filepath.Join(root, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if id := cgroupContainerRe.FindString(parts[2]); id != "" {
+			return id
+		}
+	}
+	return ""
+}
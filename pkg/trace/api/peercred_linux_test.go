@@ -0,0 +1,64 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build linux
+
+package api
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnOrigin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peercred")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sock := filepath.Join(dir, "apm.socket")
+	ln, err := net.Listen("unix", sock)
+	assert.NoError(t, err)
+	defer ln.Close()
+	client, err := net.Dial("unix", sock)
+	assert.NoError(t, err)
+	defer client.Close()
+	conn, err := ln.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	pid := strconv.Itoa(os.Getpid())
+	procDir := filepath.Join(dir, "proc", pid)
+	assert.NoError(t, os.MkdirAll(procDir, 0700))
+	defer os.Unsetenv("HOST_PROC")
+	os.Setenv("HOST_PROC", filepath.Join(dir, "proc"))
+
+	t.Run("pid", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(procDir, "cgroup"), []byte("0::/user.slice\n"), 0600))
+		assert.Equal(t, "pid:"+pid, connOrigin(conn))
+	})
+
+	t.Run("container", func(t *testing.T) {
+		id := "3c4bd9d35d42b6bce1d3e1d9b0a9e5c0b8f2e8b4a1c2d3e4f5a6b7c8d9e0f1a2"
+		cgroup := "12:memory:/kubepods/besteffort/pod3d274242/" + id + "\n"
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(procDir, "cgroup"), []byte(cgroup), 0600))
+		assert.Equal(t, id, connOrigin(conn))
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		tcpln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer tcpln.Close()
+		c, err := net.Dial("tcp", tcpln.Addr().String())
+		assert.NoError(t, err)
+		defer c.Close()
+		assert.Equal(t, "", connOrigin(c))
+	})
+}
@@ -0,0 +1,16 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build !linux
+
+package api
+
+import "net"
+
+// connOrigin returns an empty string on non-linux hosts, where the origin of the payloads
+// is given by the address of the client.
+func connOrigin(c net.Conn) string {
+	return ""
+}
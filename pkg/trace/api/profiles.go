@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
+	traceconfig "github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/info"
 	"github.com/DataDog/datadog-agent/pkg/trace/logutil"
 	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
@@ -68,8 +69,27 @@ func (r *HTTPReceiver) profileProxyHandler() http.Handler {
 	if err != nil {
 		return errorHandler(err)
 	}
+	transport, err := profilingTransport(r.conf)
+	if err != nil {
+		return errorHandler(err)
+	}
 	tags := fmt.Sprintf("host:%s,default_env:%s", r.conf.Hostname, r.conf.DefaultEnv)
-	return newProfileProxy(r.conf.NewHTTPTransport(), targets, keys, tags)
+	return newProfileProxy(transport, targets, keys, tags)
+}
+
+// profilingTransport returns the transport used to upload the profiles. It goes through the
+// proxy set in apm_config.profiling_proxy when there is one, instead of the proxy of the agent,
+// so that profiles can be sent through a dedicated egress.
+func profilingTransport(conf *traceconfig.AgentConfig) (*http.Transport, error) {
+	transport := conf.NewHTTPTransport()
+	if v := config.Datadog.GetString("apm_config.profiling_proxy"); v != "" {
+		u, err := url.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing profiling proxy URL %s: %v", v, err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	return transport, nil
 }
 
 func errorHandler(err error) http.Handler {
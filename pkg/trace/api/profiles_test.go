@@ -229,4 +229,44 @@ func TestProfileProxyHandler(t *testing.T) {
 		}
 		assert.Equal(t, expected, called, "The request should be proxied to all valid targets")
 	})
+
+	t.Run("egress_proxy", func(t *testing.T) {
+		var called bool
+		egress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Host != "intake.profile.example" {
+				t.Fatalf("invalid proxied host: %q", req.Host)
+			}
+			if v := req.Header.Get("DD-API-KEY"); v != "test" {
+				t.Fatalf("got invalid API key: %q", v)
+			}
+			called = true
+		}))
+		defer egress.Close()
+		defer mockConfigMap(map[string]interface{}{
+			"apm_config.profiling_dd_url": "http://intake.profile.example/v1/input",
+			"apm_config.profiling_proxy":  egress.URL,
+		})()
+		req, err := http.NewRequest("POST", "/some/path", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		receiver := newTestReceiverFromConfig(newTestReceiverConfig())
+		receiver.profileProxyHandler().ServeHTTP(httptest.NewRecorder(), req)
+		if !called {
+			t.Fatal("request not sent through the profiling proxy")
+		}
+	})
+
+	t.Run("egress_proxy_error", func(t *testing.T) {
+		defer mockConfig("apm_config.profiling_proxy", "http://proxy:\r\n")()
+		req, err := http.NewRequest("POST", "/some/path", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		newTestReceiverFromConfig(newTestReceiverConfig()).profileProxyHandler().ServeHTTP(rec, req)
+		if res := rec.Result(); res.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("invalid response: %s", res.Status)
+		}
+	})
 }
@@ -6,6 +6,8 @@
 package api
 
 import (
+	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -20,10 +22,16 @@ import (
 //
 // The rateLimiter also uses a decay mechanism to ensure that older entries have
 // lesser impact on the rate computation.
+//
+// The traces are limited by origin, so that an origin sending many traces can not
+// starve the others: the traces which can be kept at the target rate are shared
+// fairly among the origins, see (*rateLimiter).updateTargetRates.
 type rateLimiter struct {
 	mu sync.RWMutex
 	// stats keeps track of all the internal counters used by the rate limiter.
 	stats info.RateLimiterStats
+	// origins holds the counters of each origin of the payloads.
+	origins map[string]*originStats
 	// decayPeriod specifies the interval at which the counters should be decayed.
 	decayPeriod time.Duration
 	// decayFactor specifies the factor using which the counters are decayed. See
@@ -40,12 +48,51 @@ func newRateLimiter() *rateLimiter {
 		stats: info.RateLimiterStats{
 			TargetRate: 1,
 		},
+		origins:     make(map[string]*originStats),
 		decayPeriod: 5 * time.Second,
 		decayFactor: decayFactor,
 		exit:        make(chan struct{}),
 	}
 }
 
+// maxRateLimiterOrigins is the maximum number of origins which are limited separately, the
+// payloads of any other origin being limited together.
+const maxRateLimiterOrigins = 1000
+
+// originStats holds the counters of the traces of an origin.
+type originStats struct {
+	// recentTracesSeen and recentTracesDropped are decayed along with the counters of
+	// the rate limiter.
+	recentTracesSeen    float64
+	recentTracesDropped float64
+	// targetRate is the rate at which the traces of the origin are kept.
+	targetRate float64
+	// dropped is the total number of traces dropped.
+	dropped int64
+}
+
+func (o *originStats) realRate() float64 {
+	if o.recentTracesSeen <= 0 {
+		// avoid division by zero
+		return o.targetRate
+	}
+	return 1 - (o.recentTracesDropped / o.recentTracesSeen)
+}
+
+// payloadOrigin returns the origin of a payload for the rate limiter: the origin of the
+// connection it was received on when known from the credentials of the client (see connOrigin),
+// or else the IP address of the client. The container IDs reported by the clients themselves
+// are not used, as any client could claim the ID of another to use up its rate.
+func payloadOrigin(peer, addr string) string {
+	if peer != "" {
+		return peer
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
 // Run runs the rate limiter, occasionally decaying the score.
 func (ps *rateLimiter) Run() {
 	info.UpdateRateLimiter(*ps.Stats())
@@ -68,9 +115,44 @@ func (ps *rateLimiter) decayScore() {
 	ps.stats.RecentPayloadsSeen /= ps.decayFactor
 	ps.stats.RecentTracesSeen /= ps.decayFactor
 	ps.stats.RecentTracesDropped /= ps.decayFactor
+	for origin, o := range ps.origins {
+		o.recentTracesSeen /= ps.decayFactor
+		o.recentTracesDropped /= ps.decayFactor
+		if o.recentTracesSeen < 0.01 {
+			// the origin stopped sending traces
+			delete(ps.origins, origin)
+		}
+	}
+	ps.updateTargetRates()
 	ps.mu.Unlock()
 }
 
+// updateTargetRates shares the traces which can be kept at the target rate fairly among
+// the origins: those sending fewer traces than their share are not limited, while the
+// others are limited to the same number of traces. It must be called with the lock held.
+func (ps *rateLimiter) updateTargetRates() {
+	origins := make([]*originStats, 0, len(ps.origins))
+	var seen float64
+	for _, o := range ps.origins {
+		origins = append(origins, o)
+		seen += o.recentTracesSeen
+	}
+	sort.Slice(origins, func(i, j int) bool {
+		return origins[i].recentTracesSeen < origins[j].recentTracesSeen
+	})
+	budget := ps.stats.TargetRate * seen
+	for i, o := range origins {
+		share := budget / float64(len(origins)-i)
+		if ps.stats.TargetRate >= 1 || o.recentTracesSeen <= share {
+			o.targetRate = 1
+			budget -= o.recentTracesSeen
+			continue
+		}
+		o.targetRate = share / o.recentTracesSeen
+		budget -= share
+	}
+}
+
 // Stop stops the rate limiter.
 func (ps *rateLimiter) Stop() { close(ps.exit) }
 
@@ -79,6 +161,7 @@ func (ps *rateLimiter) Stop() { close(ps.exit) }
 func (ps *rateLimiter) SetTargetRate(rate float64) {
 	ps.mu.Lock()
 	ps.stats.TargetRate = rate
+	ps.updateTargetRates()
 	ps.mu.Unlock()
 }
 
@@ -120,14 +203,24 @@ func (ps *rateLimiter) Active() bool {
 func (ps *rateLimiter) Stats() *info.RateLimiterStats {
 	ps.mu.RLock()
 	stats := ps.stats
+	for origin, o := range ps.origins {
+		if o.dropped == 0 {
+			continue
+		}
+		if stats.DroppedByOrigin == nil {
+			stats.DroppedByOrigin = make(map[string]int64)
+		}
+		stats.DroppedByOrigin[origin] = o.dropped
+	}
 	ps.mu.RUnlock()
 	return &stats
 }
 
-// Permits reports wether the rate limiter should allow n more traces to
-// enter the pipeline. Permits calls alter internal statistics which affect
-// the result of calling RealRate(). It should only be called once per payload.
-func (ps *rateLimiter) Permits(n int64) bool {
+// Permits reports wether the rate limiter should allow n more traces from the
+// given origin to enter the pipeline. Permits calls alter internal statistics
+// which affect the result of calling RealRate(). It should only be called once
+// per payload.
+func (ps *rateLimiter) Permits(origin string, n int64) bool {
 	if n <= 0 {
 		return true // no sensible value in n, disable rate limiting
 	}
@@ -136,21 +229,35 @@ func (ps *rateLimiter) Permits(n int64) bool {
 
 	ps.mu.Lock()
 
-	if ps.realRateLocked() > ps.stats.TargetRate {
-		// we're keeping more than the target rate, drop
+	o, ok := ps.origins[origin]
+	if !ok {
+		if len(ps.origins) >= maxRateLimiterOrigins {
+			origin = ""
+		}
+		if o, ok = ps.origins[origin]; !ok {
+			// the origin is limited at the target rate until its share is known
+			o = &originStats{targetRate: ps.stats.TargetRate}
+			ps.origins[origin] = o
+		}
+	}
+	if o.realRate() > o.targetRate {
+		// we're keeping more than the target rate of the origin, drop
 		keep = false
+		o.recentTracesDropped += float64(n)
+		o.dropped += n
 		ps.stats.RecentTracesDropped += float64(n)
 	}
 
 	// this should be done *after* testing the real rate against the target rate,
 	// otherwise we could end up systematically dropping the first payload.
+	o.recentTracesSeen += float64(n)
 	ps.stats.RecentPayloadsSeen++
 	ps.stats.RecentTracesSeen += float64(n)
 
 	ps.mu.Unlock()
 
 	if !keep {
-		log.Debugf("Rate limiting at rate %.2f dropped payload with %d traces", ps.TargetRate(), n)
+		log.Debugf("Rate limiting at rate %.2f dropped payload with %d traces from %q", ps.TargetRate(), n, origin)
 	}
 	return keep
 }
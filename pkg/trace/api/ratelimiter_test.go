@@ -6,6 +6,8 @@
 package api
 
 import (
+	"context"
+	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
@@ -111,7 +113,7 @@ func TestRateLimiterRace(t *testing.T) {
 	}()
 	go func() {
 		for i := 0; i < N; i++ {
-			_ = ps.Permits(42)
+			_ = ps.Permits("", 42)
 			time.Sleep(time.Microsecond)
 		}
 		wg.Done()
@@ -130,11 +132,11 @@ func TestRateLimiterActive(t *testing.T) {
 	assert := assert.New(t)
 
 	ps := newRateLimiter()
-	ps.Permits(0)
+	ps.Permits("", 0)
 	assert.False(ps.Active(), "no traces should be seen")
-	ps.Permits(-1)
+	ps.Permits("", -1)
 	assert.False(ps.Active(), "still nothing")
-	ps.Permits(10)
+	ps.Permits("", 10)
 	assert.True(ps.Active(), "we should now be active")
 }
 
@@ -144,22 +146,22 @@ func TestRateLimiterPermits(t *testing.T) {
 	ps := newRateLimiter()
 	ps.SetTargetRate(0.2)
 	assert.Equal(0.2, ps.RealRate(), "by default, RealRate returns wished rate")
-	assert.True(ps.Permits(100), "always accept first payload")
+	assert.True(ps.Permits("", 100), "always accept first payload")
 	ps.decayScore()
-	assert.False(ps.Permits(10), "refuse as this accepting this would make 100%")
+	assert.False(ps.Permits("", 10), "refuse as this accepting this would make 100%")
 	ps.decayScore()
 	assert.Equal(0.898876404494382, ps.RealRate())
-	assert.False(ps.Permits(290), "still refuse")
+	assert.False(ps.Permits("", 290), "still refuse")
 	ps.decayScore()
-	assert.False(ps.Permits(99), "just below the limit")
+	assert.False(ps.Permits("", 99), "just below the limit")
 	ps.decayScore()
-	assert.True(ps.Permits(1), "should there be no decay, this one would be dropped, but with decay, the rate decreased as the recently dropped gain importance over the old initially accepted")
+	assert.True(ps.Permits("", 1), "should there be no decay, this one would be dropped, but with decay, the rate decreased as the recently dropped gain importance over the old initially accepted")
 	ps.decayScore()
 	assert.Equal(0.16365162139216005, ps.RealRate(), "well below 20%, again, decay speaks")
-	assert.True(ps.Permits(1000000), "accepting payload with many traces")
+	assert.True(ps.Permits("", 1000000), "accepting payload with many traces")
 	ps.decayScore()
 	assert.Equal(0.9997119577953764, ps.RealRate(), "real rate is almost 1, as we accepted a hudge payload")
-	assert.False(ps.Permits(100000), "rejecting, real rate is too high now")
+	assert.False(ps.Permits("", 100000), "rejecting, real rate is too high now")
 	ps.decayScore()
 	assert.Equal(0.8986487877795845, ps.RealRate(), "real rate should be now around 90%")
 	assert.Equal(info.RateLimiterStats{
@@ -169,3 +171,56 @@ func TestRateLimiterPermits(t *testing.T) {
 		RecentTracesDropped: 89116.55620097058,
 	}, ps.stats)
 }
+
+func TestRateLimiterOrigins(t *testing.T) {
+	assert := assert.New(t)
+
+	ps := newRateLimiter()
+	ps.SetTargetRate(0.5)
+	var quietDropped int
+	for i := 0; i < 100; i++ {
+		ps.Permits("noisy", 100)
+		if i%10 == 0 {
+			if !ps.Permits("quiet", 1) {
+				quietDropped++
+			}
+			ps.decayScore()
+		}
+	}
+	// the quiet origin sends fewer traces than its share and is never limited
+	assert.Equal(0, quietDropped)
+	assert.Equal(1.0, ps.origins["quiet"].targetRate)
+	assert.InDelta(0.5, ps.origins["noisy"].targetRate, 0.01)
+	assert.InDelta(0.5, ps.origins["noisy"].realRate(), 0.05)
+
+	stats := ps.Stats()
+	assert.Len(stats.DroppedByOrigin, 1)
+	assert.True(stats.DroppedByOrigin["noisy"] > 0)
+
+	// origins are forgotten once they stop sending traces
+	for i := 0; i < 200; i++ {
+		ps.decayScore()
+	}
+	assert.Empty(ps.origins)
+}
+
+func TestPayloadOrigin(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("pid:42", payloadOrigin("pid:42", "@"))
+	assert.Equal("10.0.0.1", payloadOrigin("", "10.0.0.1:4242"))
+	assert.Equal("::1", payloadOrigin("", "[::1]:4242"))
+	assert.Equal("@", payloadOrigin("", "@"))
+}
+
+func TestTracerOrigin(t *testing.T) {
+	assert := assert.New(t)
+	req := httptest.NewRequest("POST", "/v0.4/traces", nil)
+	req.RemoteAddr = "10.0.0.1:4242"
+	req.Header.Set(headerLang, "go")
+	// the container ID claimed by the client is ignored
+	req.Header.Set(headerContainerID, "3c4bd9d35d42")
+	assert.Equal("10.0.0.1|go", tracerOrigin(req))
+
+	req = req.WithContext(context.WithValue(req.Context(), connOriginKey{}, "pid:42"))
+	assert.Equal("pid:42|go", tracerOrigin(req))
+}
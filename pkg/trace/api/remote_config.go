@@ -0,0 +1,186 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/logutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+)
+
+const (
+	// remoteConfigClientTTL is the time after which a tracer which stopped polling its
+	// configuration is forgotten.
+	remoteConfigClientTTL = 5 * time.Minute
+	// remoteConfigMaxRequestBytes is the maximum size of the requests of the tracers.
+	remoteConfigMaxRequestBytes = 64 * 1024
+	// remoteConfigMaxClients is the maximum number of tracers whose state is tracked. The
+	// other ones are served their configuration without being tracked.
+	remoteConfigMaxClients = 10000
+)
+
+// TracerConfig is the configuration pushed to the tracers by remote configuration.
+type TracerConfig struct {
+	// Version identifies the configuration. It increases every time the configuration changes.
+	Version uint64 `json:"version"`
+	// SamplingRules are the trace sampling rules applied by the tracers.
+	SamplingRules []TracerSamplingRule `json:"sampling_rules,omitempty"`
+	// LogInjection enables or disables the injection of the trace IDs in the logs of the
+	// applications. It is left to the tracers when nil.
+	LogInjection *bool `json:"log_injection_enabled,omitempty"`
+	// HeaderTags are the HTTP headers the tracers set as span tags, as "header:tag" pairs.
+	HeaderTags []string `json:"header_tags,omitempty"`
+}
+
+// TracerSamplingRule is a trace sampling rule, applying its rate to the traces whose root span
+// matches its service and operation name. Empty fields match any value.
+type TracerSamplingRule struct {
+	Service    string  `json:"service,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	SampleRate float64 `json:"sample_rate"`
+}
+
+// RemoteConfigClient provides the configurations pushed to the tracers by remote configuration.
+type RemoteConfigClient interface {
+	// TracerConfig returns the latest configuration of the tracers of the given service and env,
+	// or nil if there is none.
+	TracerConfig(service, env string) (*TracerConfig, error)
+}
+
+// remoteConfigRequest is the body of the requests sent by the tracers polling their configuration.
+type remoteConfigRequest struct {
+	RuntimeID     string `json:"runtime_id"`
+	Language      string `json:"language"`
+	TracerVersion string `json:"tracer_version"`
+	Service       string `json:"service"`
+	Env           string `json:"env"`
+
+	// CachedVersion is the version of the configuration the tracer already has, 0 if none.
+	CachedVersion uint64 `json:"cached_version"`
+	// AppliedVersion is the version of the configuration the tracer applies, and Error the
+	// error it got applying its latest configuration, if any.
+	AppliedVersion uint64 `json:"applied_version"`
+	Error          string `json:"error,omitempty"`
+}
+
+// remoteConfigClientState is the state of a tracer polling its configuration.
+type remoteConfigClientState struct {
+	remoteConfigRequest
+	lastSeen time.Time
+}
+
+// remoteConfigServer serves the configurations provided by a RemoteConfigClient to the tracers,
+// keeping track of the state of each of them by runtime ID.
+type remoteConfigServer struct {
+	client    RemoteConfigClient
+	now       func() time.Time
+	errlog    *logutil.ThrottledLogger
+	mu        sync.Mutex
+	clients   map[string]*remoteConfigClientState // by runtime ID
+	untracked int64                               // requests of the tracers not tracked since the last report
+}
+
+func newRemoteConfigServer(client RemoteConfigClient) *remoteConfigServer {
+	return &remoteConfigServer{
+		client:  client,
+		now:     time.Now,
+		errlog:  logutil.NewThrottled(5, 10*time.Second), // limit to 5 messages every 10 seconds
+		clients: make(map[string]*remoteConfigClientState),
+	}
+}
+
+// ServeHTTP implements http.Handler. The tracers post their state, and are replied the latest
+// configuration of their service and env, or http.StatusNoContent if they already have it.
+func (s *remoteConfigServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var cr remoteConfigRequest
+	if err := json.NewDecoder(NewLimitedReader(req.Body, remoteConfigMaxRequestBytes)).Decode(&cr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		metrics.Count(receiverErrorKey, 1, []string{"handler:remote_config", "error:decoding-error"}, 1)
+		return
+	}
+	if cr.RuntimeID == "" {
+		http.Error(w, "runtime_id is required", http.StatusBadRequest)
+		return
+	}
+	s.track(&cr)
+
+	cfg, err := s.client.TracerConfig(cr.Service, cr.Env)
+	if err != nil {
+		s.errlog.Error("Failed to get the remote configuration of service %q (env %q): %v", cr.Service, cr.Env, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if cfg == nil || cfg.Version <= cr.CachedVersion {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cfg); err != nil {
+		s.errlog.Error("Failed to write the remote configuration response: %v", err)
+	}
+}
+
+// track records the state reported by a tracer.
+func (s *remoteConfigServer) track(cr *remoteConfigRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[cr.RuntimeID]; !ok && len(s.clients) >= remoteConfigMaxClients {
+		s.untracked++
+		return
+	}
+	s.clients[cr.RuntimeID] = &remoteConfigClientState{
+		remoteConfigRequest: *cr,
+		lastSeen:            s.now(),
+	}
+}
+
+// report forgets the tracers which stopped polling their configuration and submits the metrics
+// about the others.
+func (s *remoteConfigServer) report() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var errored int
+	for id, c := range s.clients {
+		if now.Sub(c.lastSeen) > remoteConfigClientTTL {
+			delete(s.clients, id)
+			continue
+		}
+		if c.Error != "" {
+			errored++
+		}
+	}
+	metrics.Gauge("datadog.trace_agent.remote_config.clients", float64(len(s.clients)), nil, 1)
+	metrics.Gauge("datadog.trace_agent.remote_config.clients_errored", float64(errored), nil, 1)
+	if s.untracked > 0 {
+		metrics.Count("datadog.trace_agent.remote_config.clients_untracked", s.untracked, nil, 1)
+		s.untracked = 0
+	}
+}
+
+// SetRemoteConfigClient enables the /v0.7/config endpoint, from which the tracers poll the
+// configurations provided by c. It must be called before Start.
+func (r *HTTPReceiver) SetRemoteConfigClient(c RemoteConfigClient) {
+	r.remoteConfig = newRemoteConfigServer(c)
+}
+
+// remoteConfigHandler returns the handler of the /v0.7/config endpoint.
+func (r *HTTPReceiver) remoteConfigHandler() http.Handler {
+	if r.remoteConfig == nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, "remote configuration is disabled", http.StatusNotFound)
+		})
+	}
+	return r.remoteConfig
+}
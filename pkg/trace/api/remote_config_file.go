@@ -0,0 +1,77 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileTracerConfig is an entry of the file read by a fileRemoteConfigClient, holding the
+// configuration of the tracers of a service and env. Empty fields match any value.
+type fileTracerConfig struct {
+	Service string        `json:"service"`
+	Env     string        `json:"env"`
+	Config  *TracerConfig `json:"config"`
+}
+
+// fileRemoteConfigClient is a RemoteConfigClient serving the configurations of a local JSON
+// file, holding a list of fileTracerConfig entries. The file is read again when it is modified.
+type fileRemoteConfigClient struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	configs []fileTracerConfig
+}
+
+// NewFileRemoteConfigClient returns a RemoteConfigClient serving the configurations of the
+// JSON file at path. The first entry of the file matching the service and env of a tracer
+// is its configuration.
+func NewFileRemoteConfigClient(path string) RemoteConfigClient {
+	return &fileRemoteConfigClient{path: path}
+}
+
+// TracerConfig implements RemoteConfigClient.
+func (c *fileRemoteConfigClient) TracerConfig(service, env string) (*TracerConfig, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	for _, fc := range c.configs {
+		if (fc.Service == "" || fc.Service == service) && (fc.Env == "" || fc.Env == env) {
+			return fc.Config, nil
+		}
+	}
+	return nil, nil
+}
+
+// load reads the file again if it was modified since it was last read.
+func (c *fileRemoteConfigClient) load() error {
+	fi, err := os.Stat(c.path)
+	if err != nil {
+		return err
+	}
+	if fi.ModTime().Equal(c.modTime) && c.configs != nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return err
+	}
+	configs := make([]fileTracerConfig, 0)
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return fmt.Errorf("invalid tracer configuration file %s: %v", c.path, err)
+	}
+	c.configs = configs
+	c.modTime = fi.ModTime()
+	return nil
+}
@@ -0,0 +1,151 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testRemoteConfigClient serves the configurations of its map, by service.
+type testRemoteConfigClient map[string]*TracerConfig
+
+func (c testRemoteConfigClient) TracerConfig(service, env string) (*TracerConfig, error) {
+	if service == "broken" {
+		return nil, errors.New("unavailable")
+	}
+	return c[service], nil
+}
+
+func TestRemoteConfigServer(t *testing.T) {
+	enabled := true
+	cfg := &TracerConfig{
+		Version:       2,
+		SamplingRules: []TracerSamplingRule{{Service: "web", Name: "http.request", SampleRate: 0.5}},
+		LogInjection:  &enabled,
+		HeaderTags:    []string{"X-User-Id:user.id"},
+	}
+	s := newRemoteConfigServer(testRemoteConfigClient{"web": cfg})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	post := func(method string, cr *remoteConfigRequest) *httptest.ResponseRecorder {
+		body, err := json.Marshal(cr)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(method, "/v0.7/config", bytes.NewReader(body)))
+		return rec
+	}
+
+	t.Run("config", func(t *testing.T) {
+		rec := post("POST", &remoteConfigRequest{RuntimeID: "1", Language: "go", Service: "web", Env: "prod"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got TracerConfig
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+		assert.Equal(t, cfg, &got)
+	})
+
+	t.Run("up-to-date", func(t *testing.T) {
+		rec := post("POST", &remoteConfigRequest{RuntimeID: "1", Service: "web", CachedVersion: 2, AppliedVersion: 2})
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		rec = post("POST", &remoteConfigRequest{RuntimeID: "2", Service: "db"})
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusMethodNotAllowed, post("GET", &remoteConfigRequest{RuntimeID: "1"}).Code)
+		assert.Equal(t, http.StatusBadRequest, post("POST", &remoteConfigRequest{Service: "web"}).Code)
+		assert.Equal(t, http.StatusInternalServerError, post("POST", &remoteConfigRequest{RuntimeID: "3", Service: "broken"}).Code)
+	})
+
+	t.Run("clients", func(t *testing.T) {
+		post("POST", &remoteConfigRequest{RuntimeID: "1", Service: "web", CachedVersion: 2, AppliedVersion: 1, Error: "invalid rule"})
+		assert.Len(t, s.clients, 3)
+		assert.Equal(t, "invalid rule", s.clients["1"].Error)
+		assert.EqualValues(t, 1, s.clients["1"].AppliedVersion)
+
+		now = now.Add(remoteConfigClientTTL)
+		post("POST", &remoteConfigRequest{RuntimeID: "2", Service: "db"})
+		now = now.Add(time.Second)
+		s.report()
+		assert.Len(t, s.clients, 1)
+		assert.Contains(t, s.clients, "2")
+	})
+
+	t.Run("max-clients", func(t *testing.T) {
+		for i := len(s.clients); i < remoteConfigMaxClients; i++ {
+			s.clients[strconv.Itoa(-i)] = &remoteConfigClientState{lastSeen: now}
+		}
+		post("POST", &remoteConfigRequest{RuntimeID: "new", Service: "web"})
+		assert.Len(t, s.clients, remoteConfigMaxClients)
+		assert.NotContains(t, s.clients, "new")
+		assert.EqualValues(t, 1, s.untracked)
+		// the tracers already tracked are still updated
+		post("POST", &remoteConfigRequest{RuntimeID: "2", Service: "db", AppliedVersion: 3})
+		assert.EqualValues(t, 3, s.clients["2"].AppliedVersion)
+		s.report()
+		assert.Zero(t, s.untracked)
+	})
+}
+
+func TestFileRemoteConfigClient(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "tracer-config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tracer_config.json")
+	c := NewFileRemoteConfigClient(path)
+
+	_, err = c.TracerConfig("web", "prod")
+	assert.Error(err)
+
+	write := func(data string, modTime time.Time) {
+		assert.NoError(ioutil.WriteFile(path, []byte(data), 0600))
+		assert.NoError(os.Chtimes(path, modTime, modTime))
+	}
+	now := time.Now()
+	write(`[
+		{"service": "web", "env": "prod", "config": {"version": 2, "header_tags": ["X-User-Id:user.id"]}},
+		{"service": "web", "config": {"version": 1}}
+	]`, now)
+	cfg, err := c.TracerConfig("web", "prod")
+	assert.NoError(err)
+	assert.Equal(&TracerConfig{Version: 2, HeaderTags: []string{"X-User-Id:user.id"}}, cfg)
+	cfg, err = c.TracerConfig("web", "staging")
+	assert.NoError(err)
+	assert.Equal(&TracerConfig{Version: 1}, cfg)
+	cfg, err = c.TracerConfig("db", "prod")
+	assert.NoError(err)
+	assert.Nil(cfg)
+
+	// the file is read again once modified
+	write(`[{"config": {"version": 3}}]`, now.Add(time.Second))
+	cfg, err = c.TracerConfig("db", "prod")
+	assert.NoError(err)
+	assert.Equal(&TracerConfig{Version: 3}, cfg)
+
+	write(`{`, now.Add(2*time.Second))
+	_, err = c.TracerConfig("db", "prod")
+	assert.Error(err)
+}
+
+func TestRemoteConfigDisabled(t *testing.T) {
+	receiver := newTestReceiverFromConfig(newTestReceiverConfig())
+	rec := httptest.NewRecorder()
+	receiver.remoteConfigHandler().ServeHTTP(rec, httptest.NewRequest("POST", "/v0.7/config", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
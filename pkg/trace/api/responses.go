@@ -26,6 +26,9 @@ const (
 type traceResponse struct {
 	// All the sampling rates recommended, by service
 	Rates map[string]float64 `json:"rate_by_service"`
+	// The sampling rates recommended by resource of the services, only sent to the
+	// tracers supporting them
+	ResourceRates map[string]float64 `json:"rate_by_resource,omitempty"`
 }
 
 // httpFormatError is used for payload format errors
@@ -67,12 +70,16 @@ func httpOK(w http.ResponseWriter) {
 	io.WriteString(w, "OK\n")
 }
 
-// httpRateByService outputs, as a JSON, the recommended sampling rates for all services.
-func httpRateByService(w http.ResponseWriter, dynConf *sampler.DynamicConfig) {
+// httpRateByService outputs, as a JSON, the recommended sampling rates for all services,
+// along with the ones of their resources if byResource is set.
+func httpRateByService(w http.ResponseWriter, dynConf *sampler.DynamicConfig, byResource bool) {
 	w.Header().Set("Content-Type", "application/json")
 	response := traceResponse{
 		Rates: dynConf.RateByService.GetAll(), // this is thread-safe
 	}
+	if byResource {
+		response.ResourceRates = dynConf.RateByService.GetAllByResource()
+	}
 	encoder := json.NewEncoder(w)
 	if err := encoder.Encode(response); err != nil {
 		tags := []string{"error:response-error"}
@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+)
+
+// newTLSConfig returns the TLS configuration with which the receiver serves the traces, or
+// nil if TLS is disabled. Clients have to present a certificate signed by one of the
+// authorities of the client CA file when it is set.
+func newTLSConfig(conf *config.AgentConfig) (*tls.Config, error) {
+	if conf.ReceiverTLSCertFile == "" {
+		if conf.ReceiverTLSKeyFile != "" || conf.ReceiverTLSClientCAFile != "" {
+			return nil, errors.New("TLS is enabled without a certificate file")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(conf.ReceiverTLSCertFile, conf.ReceiverTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the TLS certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if path := conf.ReceiverTLSClientCAFile; path != "" {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the TLS client CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in the TLS client CA file %q", path)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1, usable by both servers and
+// clients, and its key to dir, returning their paths.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "trace-agent-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace-agent-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir)
+
+	t.Run("disabled", func(t *testing.T) {
+		tlsConfig, err := newTLSConfig(config.New())
+		assert.NoError(t, err)
+		assert.Nil(t, tlsConfig)
+	})
+
+	t.Run("no-cert", func(t *testing.T) {
+		conf := config.New()
+		conf.ReceiverTLSKeyFile = keyFile
+		_, err := newTLSConfig(conf)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		conf := config.New()
+		conf.ReceiverTLSCertFile = keyFile
+		conf.ReceiverTLSKeyFile = keyFile
+		_, err := newTLSConfig(conf)
+		assert.Error(t, err)
+
+		conf.ReceiverTLSCertFile = certFile
+		conf.ReceiverTLSClientCAFile = keyFile
+		_, err = newTLSConfig(conf)
+		assert.Error(t, err)
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		assert := assert.New(t)
+		conf := config.New()
+		conf.ReceiverTLSCertFile = certFile
+		conf.ReceiverTLSKeyFile = keyFile
+		tlsConfig, err := newTLSConfig(conf)
+		assert.NoError(err)
+		assert.Len(tlsConfig.Certificates, 1)
+		assert.Equal(tls.NoClientCert, tlsConfig.ClientAuth)
+	})
+
+	t.Run("mtls", func(t *testing.T) {
+		assert := assert.New(t)
+		conf := config.New()
+		conf.ReceiverTLSCertFile = certFile
+		conf.ReceiverTLSKeyFile = keyFile
+		conf.ReceiverTLSClientCAFile = certFile
+		tlsConfig, err := newTLSConfig(conf)
+		assert.NoError(err)
+		assert.Equal(tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+		server.TLS = tlsConfig
+		server.StartTLS()
+		defer server.Close()
+
+		pool := x509.NewCertPool()
+		pem, err := ioutil.ReadFile(certFile)
+		assert.NoError(err)
+		pool.AppendCertsFromPEM(pem)
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		assert.NoError(err)
+
+		// clients without a certificate are refused
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+		_, err = client.Get(server.URL)
+		assert.Error(err)
+
+		client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      pool,
+			Certificates: []tls.Certificate{cert},
+		}}}
+		resp, err := client.Get(server.URL)
+		assert.NoError(err)
+		if resp != nil {
+			resp.Body.Close()
+			assert.Equal(http.StatusOK, resp.StatusCode)
+		}
+	})
+}
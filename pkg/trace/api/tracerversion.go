@@ -0,0 +1,80 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// outdatedTracerError is the error reported to the tracers older than the minimum version
+// configured for their language.
+type outdatedTracerError struct {
+	lang, version, min string
+}
+
+func (e *outdatedTracerError) Error() string {
+	return fmt.Sprintf("%s tracer version %s is older than the minimum version %s required by the Datadog Agent "+
+		"(apm_config.min_tracer_versions): please upgrade the tracer to %s or later", e.lang, e.version, e.min, e.min)
+}
+
+// checkTracerVersion returns an error if the given tracer version is older than the minimum
+// version configured for the language and outdated tracers are rejected. Outdated tracers are
+// otherwise reported in the logs. Payloads with no or an unknown version are always accepted.
+func (r *HTTPReceiver) checkTracerVersion(lang, version string) error {
+	min, ok := r.conf.MinTracerVersions[strings.ToLower(lang)]
+	if !ok || len(tracerVersionParts(version)) == 0 || compareTracerVersions(version, min) >= 0 {
+		return nil
+	}
+	err := &outdatedTracerError{lang: lang, version: version, min: min}
+	if r.conf.RejectOutdatedTracers {
+		r.tracerVersionLogger.Warn("Rejected a payload: %v", err)
+		return err
+	}
+	r.tracerVersionLogger.Warn("Received a payload from an outdated tracer: %v", err)
+	return nil
+}
+
+// compareTracerVersions compares the numeric parts of two tracer versions, such as "0.45.1",
+// "v1.28.0" or "0.38.0.dev", returning -1, 0 or 1 if a is respectively older than, the same
+// as or newer than b. Pre-release and build suffixes are ignored.
+func compareTracerVersions(a, b string) int {
+	va, vb := tracerVersionParts(a), tracerVersionParts(b)
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y uint64
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// tracerVersionParts returns the leading numeric parts of the version v.
+func tracerVersionParts(v string) []uint64 {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+ "); i >= 0 {
+		v = v[:i]
+	}
+	var parts []uint64
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareTracerVersions(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		out  int
+	}{
+		{"0.45.0", "0.45.0", 0},
+		{"0.44.1", "0.45.0", -1},
+		{"0.45.1", "0.45.0", 1},
+		{"1.0", "0.45.0", 1},
+		{"0.45", "0.45.0", 0},
+		{"v1.28.0", "1.27.0", 1},
+		{"0.38.0.dev", "0.40.0", -1},
+		{"2.0.0-beta1", "2.0.0", 0},
+		{"0.9.0", "0.10.0", -1},
+	} {
+		assert.Equal(t, tt.out, compareTracerVersions(tt.a, tt.b), "%s vs %s", tt.a, tt.b)
+	}
+}
+
+func TestCheckTracerVersion(t *testing.T) {
+	assert := assert.New(t)
+	conf := newTestReceiverConfig()
+	conf.MinTracerVersions = map[string]string{"python": "0.40.0"}
+	r := newTestReceiverFromConfig(conf)
+
+	assert.NoError(r.checkTracerVersion("python", "0.44.0"))
+	assert.NoError(r.checkTracerVersion("java", "0.1.0"))
+	assert.NoError(r.checkTracerVersion("python", ""))
+	assert.NoError(r.checkTracerVersion("python", "unknown"))
+	// outdated tracers are only reported by default
+	assert.NoError(r.checkTracerVersion("python", "0.38.0"))
+
+	conf.RejectOutdatedTracers = true
+	assert.NoError(r.checkTracerVersion("python", "0.44.0"))
+	err := r.checkTracerVersion("Python", "0.38.0")
+	assert.Error(err)
+	assert.Contains(err.Error(), "0.40.0")
+}
+
+func TestReceiverOutdatedTracer(t *testing.T) {
+	assert := assert.New(t)
+	conf := newTestReceiverConfig()
+	conf.MinTracerVersions = map[string]string{"python": "0.40.0"}
+	conf.RejectOutdatedTracers = true
+	r := newTestReceiverFromConfig(conf)
+	server := httptest.NewServer(http.HandlerFunc(r.handleWithVersion(v04, r.handleTraces)))
+	defer server.Close()
+
+	post := func(version string) *http.Response {
+		traces := pb.Traces{testutil.RandomTrace(3, 1)}
+		req, err := http.NewRequest("POST", server.URL, bytes.NewReader(msgpTraces(t, traces)))
+		assert.NoError(err)
+		req.Header.Set("Content-Type", "application/msgpack")
+		req.Header.Set(headerTraceCount, "1")
+		req.Header.Set(headerLang, "python")
+		req.Header.Set(headerTracerVersion, version)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(err)
+		return resp
+	}
+
+	resp := post("0.38.0")
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(string(body), "please upgrade the tracer to 0.40.0 or later")
+	ts := r.Stats.GetTagStats(info.Tags{Lang: "python", TracerVersion: "0.38.0", EndpointVersion: "v0.4"})
+	assert.EqualValues(1, ts.TracesDropped.OutdatedTracer)
+	assert.Len(r.out, 0)
+
+	resp = post("0.44.0")
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Len(r.out, 1)
+}
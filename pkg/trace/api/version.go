@@ -68,4 +68,28 @@ const (
 	// 		The dictionary in this case would be []string{""}, having only the empty string at index 0.
 	//
 	v05 Version = "v0.5"
+
+	// v07
+	//
+	// Content-Type: application/msgpack
+	// Payload: Traces with strings interned into a string table built while the payload is read.
+	// Response: Service sampling rates.
+	//
+	// The payload is an array of traces, where each trace is an array of spans. A span is encoded as
+	// an array having exactly 12 elements, in the same order and with the same types as for v05, except
+	// that "Service", "Name", "Resource", "Type" and the keys and values of "Meta" and the keys of
+	// "Metrics" are string references.
+	//
+	// 	Considerations:
+	//
+	// 	- A string reference is either a string or an unsigned integer. A string is appended to the string
+	// 	  table, while an integer is the index at which the referred string is found in it. The string table
+	// 	  starts with the empty string at index 0 and is shared by all the spans of the payload.
+	//
+	// 	- Unlike v05, the payload can be encoded in a single pass, sending each string the first time it is
+	// 	  encountered, and decoded while it is read.
+	//
+	// 	- None of the elements can be nil, the same as for v05.
+	//
+	v07 Version = "v0.7"
 )
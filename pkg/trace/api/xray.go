@@ -0,0 +1,430 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// xrayEndpointVersion is the endpoint version under which the stats of the traces received
+// as AWS X-Ray segment documents are reported.
+const xrayEndpointVersion = "xray"
+
+// maxXRayPacketSize is the maximum size of the UDP packets sent by the X-Ray SDKs.
+const maxXRayPacketSize = 64 * 1024
+
+// xraySegment is an AWS X-Ray segment or subsegment document, see:
+// https://docs.aws.amazon.com/xray/latest/devguide/xray-api-segmentdocuments.html
+type xraySegment struct {
+	Name        string                 `json:"name"`
+	ID          string                 `json:"id"`
+	TraceID     string                 `json:"trace_id"`
+	ParentID    string                 `json:"parent_id"`
+	Type        string                 `json:"type"`
+	StartTime   float64                `json:"start_time"`
+	EndTime     float64                `json:"end_time"`
+	InProgress  bool                   `json:"in_progress"`
+	Namespace   string                 `json:"namespace"`
+	Origin      string                 `json:"origin"`
+	Error       bool                   `json:"error"`
+	Fault       bool                   `json:"fault"`
+	Throttle    bool                   `json:"throttle"`
+	HTTP        *xrayHTTP              `json:"http"`
+	SQL         *xraySQL               `json:"sql"`
+	AWS         map[string]interface{} `json:"aws"`
+	Annotations map[string]interface{} `json:"annotations"`
+	Cause       json.RawMessage        `json:"cause"`
+	Subsegments []*xraySegment         `json:"subsegments"`
+}
+
+type xrayHTTP struct {
+	Request *struct {
+		Method    string `json:"method"`
+		URL       string `json:"url"`
+		UserAgent string `json:"user_agent"`
+		ClientIP  string `json:"client_ip"`
+	} `json:"request"`
+	Response *struct {
+		Status int `json:"status"`
+	} `json:"response"`
+}
+
+type xraySQL struct {
+	URL            string `json:"url"`
+	SanitizedQuery string `json:"sanitized_query"`
+	DatabaseType   string `json:"database_type"`
+	User           string `json:"user"`
+}
+
+// xrayCause holds the exceptions of a segment. A cause can also be the ID of an exception
+// of another subsegment, in which case it is not decoded.
+type xrayCause struct {
+	Exceptions []struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Stack   []struct {
+			Path  string `json:"path"`
+			Line  int    `json:"line"`
+			Label string `json:"label"`
+		} `json:"stack"`
+	} `json:"exceptions"`
+}
+
+// xrayDocument holds the fields needed to tell apart the JSON documents accepted by the X-Ray
+// intake: the header of the daemon protocol ({"format": "json", "version": 1}), the body of a
+// PutTraceSegments API call, or a segment.
+type xrayDocument struct {
+	Format                string   `json:"format"`
+	ID                    string   `json:"id"`
+	TraceSegmentDocuments []string `json:"TraceSegmentDocuments"`
+}
+
+// decodeXRaySegments decodes the X-Ray segments of a payload, which can be a UDP packet of the
+// X-Ray daemon protocol (a header followed by a segment), the body of a PutTraceSegments API
+// call, or segments, either alone, concatenated or in an array.
+func decodeXRaySegments(data []byte) ([]*xraySegment, error) {
+	var segments []*xraySegment
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 && raw[0] == '[' {
+			var array []*xraySegment
+			if err := json.Unmarshal(raw, &array); err != nil {
+				return nil, err
+			}
+			segments = append(segments, array...)
+			continue
+		}
+		var doc xrayDocument
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		switch {
+		case doc.TraceSegmentDocuments != nil:
+			for _, d := range doc.TraceSegmentDocuments {
+				var s xraySegment
+				if err := json.Unmarshal([]byte(d), &s); err != nil {
+					return nil, err
+				}
+				segments = append(segments, &s)
+			}
+		case doc.Format != "" && doc.ID == "":
+			// daemon protocol header
+			if doc.Format != "json" {
+				return nil, fmt.Errorf("unsupported X-Ray document format %q", doc.Format)
+			}
+		default:
+			var s xraySegment
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, err
+			}
+			segments = append(segments, &s)
+		}
+	}
+	return segments, nil
+}
+
+// xrayTraceID converts an X-Ray trace ID (1-<8 hex digits of epoch time>-<24 hex digits>) to
+// a Datadog trace ID, made of the 64 lower bits of the 96-bit identifier.
+func xrayTraceID(id string) (uint64, error) {
+	parts := strings.Split(id, "-")
+	if len(parts) != 3 || parts[0] != "1" || len(parts[1]) != 8 || len(parts[2]) != 24 {
+		return 0, fmt.Errorf("invalid X-Ray trace ID %q", id)
+	}
+	v, err := strconv.ParseUint(parts[2][8:], 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid X-Ray trace ID %q", id)
+	}
+	return v, nil
+}
+
+// xraySpanID converts an X-Ray segment ID (16 hex digits) to a Datadog span ID.
+func xraySpanID(id string) (uint64, error) {
+	if len(id) != 16 {
+		return 0, fmt.Errorf("invalid X-Ray segment ID %q", id)
+	}
+	v, err := strconv.ParseUint(id, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid X-Ray segment ID %q", id)
+	}
+	return v, nil
+}
+
+// xrayNanos converts an X-Ray timestamp, in seconds since the epoch, to nanoseconds. X-Ray
+// timestamps have a precision of a microsecond at best, which is kept to avoid rounding errors.
+func xrayNanos(t float64) int64 {
+	return int64(math.Round(t*1e6)) * 1e3
+}
+
+// tracesFromXRaySegments converts X-Ray segments, along with their subsegments, to traces.
+// Segments which are still in progress are skipped: they are sent again once complete.
+func tracesFromXRaySegments(segments []*xraySegment) (pb.Traces, error) {
+	var traces pb.Traces
+	index := make(map[uint64]int)
+	for _, s := range segments {
+		if s.InProgress {
+			continue
+		}
+		traceID, err := xrayTraceID(s.TraceID)
+		if err != nil {
+			return nil, err
+		}
+		var parentID uint64
+		if s.ParentID != "" {
+			if parentID, err = xraySpanID(s.ParentID); err != nil {
+				return nil, err
+			}
+		}
+		i, ok := index[traceID]
+		if !ok {
+			i = len(traces)
+			index[traceID] = i
+			traces = append(traces, nil)
+		}
+		if traces[i], err = appendXRaySpans(traces[i], s, s.Name, traceID, parentID, false); err != nil {
+			return nil, err
+		}
+	}
+	return traces, nil
+}
+
+// appendXRaySpans appends the span of segment s and the spans of its subsegments to t.
+func appendXRaySpans(t pb.Trace, s *xraySegment, service string, traceID, parentID uint64, sub bool) (pb.Trace, error) {
+	if s.InProgress {
+		return t, nil
+	}
+	spanID, err := xraySpanID(s.ID)
+	if err != nil {
+		return nil, err
+	}
+	span := xraySpan(s, service, sub)
+	span.TraceID = traceID
+	span.SpanID = spanID
+	span.ParentID = parentID
+	t = append(t, span)
+	for _, sub := range s.Subsegments {
+		if t, err = appendXRaySpans(t, sub, service, traceID, spanID, true); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// xraySpan returns the span of segment s, without its identifiers. Subsegments are reported
+// under the service of their segment.
+func xraySpan(s *xraySegment, service string, sub bool) *pb.Span {
+	span := &pb.Span{
+		Service:  service,
+		Name:     "xray.segment",
+		Resource: s.Name,
+		Start:    xrayNanos(s.StartTime),
+		Duration: xrayNanos(s.EndTime) - xrayNanos(s.StartTime),
+		Meta:     make(map[string]string),
+	}
+	if sub || s.Type == "subsegment" {
+		span.Name = "xray.subsegment"
+	}
+	if s.Fault {
+		span.Error = 1
+	}
+	if s.Error {
+		span.Meta["xray.error"] = "true"
+	}
+	if s.Throttle {
+		span.Meta["xray.throttle"] = "true"
+	}
+	if s.Origin != "" {
+		span.Meta["xray.origin"] = s.Origin
+	}
+	switch {
+	case s.Namespace == "aws":
+		span.Name = "aws.request"
+		span.Type = "http"
+	case s.SQL != nil:
+		span.Name = "sql.query"
+		span.Type = "sql"
+	case s.Namespace == "remote":
+		span.Name = "http.request"
+		span.Type = "http"
+	case s.HTTP != nil && s.HTTP.Request != nil:
+		span.Type = "web"
+	}
+	if s.HTTP != nil {
+		if req := s.HTTP.Request; req != nil {
+			span.Meta["http.method"] = req.Method
+			span.Meta["http.url"] = req.URL
+			if req.UserAgent != "" {
+				span.Meta["http.useragent"] = req.UserAgent
+			}
+			if req.ClientIP != "" {
+				span.Meta["http.client_ip"] = req.ClientIP
+			}
+			if span.Type == "web" {
+				span.Resource = req.Method
+				if u, err := url.Parse(req.URL); err == nil && u.Path != "" {
+					span.Resource += " " + u.Path
+				}
+			}
+		}
+		if resp := s.HTTP.Response; resp != nil && resp.Status != 0 {
+			span.Meta["http.status_code"] = strconv.Itoa(resp.Status)
+		}
+	}
+	if s.SQL != nil {
+		if s.SQL.SanitizedQuery != "" {
+			span.Resource = s.SQL.SanitizedQuery
+			span.Meta["sql.query"] = s.SQL.SanitizedQuery
+		}
+		if s.SQL.DatabaseType != "" {
+			span.Meta["db.type"] = s.SQL.DatabaseType
+		}
+		if s.SQL.User != "" {
+			span.Meta["db.user"] = s.SQL.User
+		}
+	}
+	for k, v := range s.AWS {
+		switch v.(type) {
+		case string, float64, bool:
+			span.Meta["aws."+k] = fmt.Sprint(v)
+		}
+	}
+	if op, ok := s.AWS["operation"].(string); ok && s.Namespace == "aws" {
+		span.Resource = s.Name + "." + op
+	}
+	for k, v := range s.Annotations {
+		span.Meta["xray.annotations."+k] = fmt.Sprint(v)
+	}
+	if len(s.Cause) > 0 && s.Cause[0] == '{' {
+		var cause xrayCause
+		if err := json.Unmarshal(s.Cause, &cause); err == nil && len(cause.Exceptions) > 0 {
+			ex := cause.Exceptions[0]
+			if ex.Type != "" {
+				span.Meta["error.type"] = ex.Type
+			}
+			if ex.Message != "" {
+				span.Meta["error.msg"] = ex.Message
+			}
+			if len(ex.Stack) > 0 {
+				var stack strings.Builder
+				for _, f := range ex.Stack {
+					fmt.Fprintf(&stack, "%s (%s:%d)\n", f.Label, f.Path, f.Line)
+				}
+				span.Meta["error.stack"] = stack.String()
+			}
+		}
+	}
+	return span
+}
+
+// xrayTagStats returns the stats of the traces received as X-Ray segments on the given transport.
+func (r *HTTPReceiver) xrayTagStats(transport string) *info.TagStats {
+	return r.Stats.GetTagStats(info.Tags{EndpointVersion: xrayEndpointVersion, Transport: transport})
+}
+
+// handleXRaySegments handles the X-Ray segments received on the HTTP endpoint.
+func (r *HTTPReceiver) handleXRaySegments(w http.ResponseWriter, req *http.Request) {
+	ts := r.xrayTagStats(requestTransport(req))
+	body := NewLimitedReader(req.Body, r.conf.MaxRequestBytes)
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		httpDecodingError(err, []string{"handler:xray"}, w)
+		if err == ErrLimitedReaderLimitReached {
+			atomic.AddInt64(&ts.TracesDropped.PayloadTooLarge, 1)
+		}
+		return
+	}
+	if err := r.processXRaySegments(ts, data, req.RemoteAddr, req.Header); err != nil {
+		httpDecodingError(err, []string{"handler:xray"}, w)
+		log.Errorf("Cannot decode X-Ray segments payload: %v", err)
+		return
+	}
+	httpOK(w)
+}
+
+// processXRaySegments converts the X-Ray segments of a payload sent from the given address
+// with the given headers to traces and sends them to the agent.
+func (r *HTTPReceiver) processXRaySegments(ts *info.TagStats, data []byte, addr string, header http.Header) error {
+	segments, err := decodeXRaySegments(data)
+	if err == nil {
+		var traces pb.Traces
+		if traces, err = tracesFromXRaySegments(segments); err == nil {
+			if len(traces) == 0 {
+				return nil
+			}
+			if r.rateLimited(payloadOrigin("", addr), int64(len(traces))) {
+				atomic.AddInt64(&ts.PayloadRefused, 1)
+				return nil
+			}
+			atomic.AddInt64(&ts.TracesReceived, int64(len(traces)))
+			atomic.AddInt64(&ts.TracesBytes, int64(len(data)))
+			atomic.AddInt64(&ts.PayloadAccepted, 1)
+			r.sendPayload(&Payload{
+				Source:        ts,
+				TracerPayload: &pb.TracerPayload{Chunks: traceChunksFromTraces(traces)},
+			}, header)
+			return nil
+		}
+	}
+	atomic.AddInt64(&ts.TracesDropped.DecodingError, 1)
+	return err
+}
+
+// listenXRayUDP starts receiving X-Ray segments sent using the daemon protocol on the given
+// UDP address.
+func (r *HTTPReceiver) listenXRayUDP(addr string) error {
+	conn, err := net.ListenPacket(r.conf.Network("udp"), addr)
+	if err != nil {
+		return err
+	}
+	r.xrayConn = conn
+	go func() {
+		defer watchdog.LogOnPanic()
+		r.serveXRayUDP(conn)
+	}()
+	return nil
+}
+
+func (r *HTTPReceiver) serveXRayUDP(conn net.PacketConn) {
+	ts := r.xrayTagStats(transport(conn.LocalAddr().Network()))
+	buf := make([]byte, maxXRayPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			if !strings.Contains(err.Error(), "use of closed network connection") {
+				log.Errorf("Stopped receiving X-Ray segments: %v", err)
+			}
+			return
+		}
+		if err := r.processXRaySegments(ts, buf[:n], addr.String(), nil); err != nil {
+			log.Debugf("Cannot decode X-Ray segments packet: %v", err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testXRaySegment = `{
+	"name": "web-app",
+	"id": "70de5b6f19ff9a0a",
+	"trace_id": "1-581cf771-a006649127e371903a2de979",
+	"start_time": 1478293361.271,
+	"end_time": 1478293361.449,
+	"fault": true,
+	"http": {
+		"request": {"method": "GET", "url": "http://example.com/users/42", "client_ip": "78.255.233.48"},
+		"response": {"status": 500}
+	},
+	"annotations": {"customer": 42},
+	"cause": {"exceptions": [{"type": "NullPointerException", "message": "oops", "stack": [{"path": "App.java", "line": 12, "label": "App.run"}]}]},
+	"subsegments": [
+		{
+			"name": "DynamoDB",
+			"id": "53995c3f42cd8ad8",
+			"start_time": 1478293361.3,
+			"end_time": 1478293361.4,
+			"namespace": "aws",
+			"aws": {"operation": "GetItem", "table_name": "users"}
+		},
+		{
+			"name": "users@db",
+			"id": "53995c3f42cd8ad9",
+			"start_time": 1478293361.4,
+			"end_time": 1478293361.44,
+			"namespace": "remote",
+			"sql": {"sanitized_query": "SELECT * FROM users WHERE id = ?", "database_type": "PostgreSQL"}
+		},
+		{
+			"name": "pending",
+			"id": "53995c3f42cd8ada",
+			"start_time": 1478293361.44,
+			"in_progress": true
+		}
+	]
+}`
+
+func TestXRayIDs(t *testing.T) {
+	assert := assert.New(t)
+
+	id, err := xrayTraceID("1-581cf771-a006649127e371903a2de979")
+	assert.NoError(err)
+	assert.EqualValues(0x27e371903a2de979, id)
+
+	for _, id := range []string{"", "1-581cf771", "2-581cf771-a006649127e371903a2de979", "1-581cf771-a006649127e371903a2de97z"} {
+		_, err := xrayTraceID(id)
+		assert.Error(err, id)
+	}
+
+	id, err = xraySpanID("70de5b6f19ff9a0a")
+	assert.NoError(err)
+	assert.EqualValues(0x70de5b6f19ff9a0a, id)
+
+	_, err = xraySpanID("70de5b6f")
+	assert.Error(err)
+}
+
+func TestDecodeXRaySegments(t *testing.T) {
+	for name, payload := range map[string]string{
+		"segment":          testXRaySegment,
+		"daemon packet":    "{\"format\": \"json\", \"version\": 1}\n" + testXRaySegment,
+		"array":            "[" + testXRaySegment + "]",
+		"PutTraceSegments": `{"TraceSegmentDocuments": [` + jsonString(testXRaySegment) + `]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			segments, err := decodeXRaySegments([]byte(payload))
+			assert.NoError(t, err)
+			assert.Len(t, segments, 1)
+			assert.Equal(t, "70de5b6f19ff9a0a", segments[0].ID)
+			assert.Len(t, segments[0].Subsegments, 3)
+		})
+	}
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := decodeXRaySegments([]byte(`{"format": "binary", "version": 1}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestTracesFromXRaySegments(t *testing.T) {
+	assert := assert.New(t)
+
+	segments, err := decodeXRaySegments([]byte(testXRaySegment))
+	assert.NoError(err)
+	traces, err := tracesFromXRaySegments(segments)
+	assert.NoError(err)
+	assert.Len(traces, 1)
+	trace := traces[0]
+	assert.Len(trace, 3, "in progress subsegments are skipped")
+
+	root := trace[0]
+	assert.EqualValues(0x27e371903a2de979, root.TraceID)
+	assert.EqualValues(0x70de5b6f19ff9a0a, root.SpanID)
+	assert.EqualValues(0, root.ParentID)
+	assert.Equal("web-app", root.Service)
+	assert.Equal("xray.segment", root.Name)
+	assert.Equal("GET /users/42", root.Resource)
+	assert.Equal("web", root.Type)
+	assert.EqualValues(1478293361271000000, root.Start)
+	assert.InDelta(178000000, root.Duration, 1000)
+	assert.EqualValues(1, root.Error)
+	assert.Equal("500", root.Meta["http.status_code"])
+	assert.Equal("42", root.Meta["xray.annotations.customer"])
+	assert.Equal("NullPointerException", root.Meta["error.type"])
+	assert.Equal("oops", root.Meta["error.msg"])
+	assert.Equal("App.run (App.java:12)\n", root.Meta["error.stack"])
+
+	dynamo := trace[1]
+	assert.Equal(root.SpanID, dynamo.ParentID)
+	assert.Equal(root.TraceID, dynamo.TraceID)
+	assert.Equal("web-app", dynamo.Service)
+	assert.Equal("aws.request", dynamo.Name)
+	assert.Equal("DynamoDB.GetItem", dynamo.Resource)
+	assert.Equal("users", dynamo.Meta["aws.table_name"])
+
+	sql := trace[2]
+	assert.Equal("sql.query", sql.Name)
+	assert.Equal("sql", sql.Type)
+	assert.Equal("SELECT * FROM users WHERE id = ?", sql.Resource)
+	assert.Equal("PostgreSQL", sql.Meta["db.type"])
+}
+
+func TestHandleXRaySegments(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestReceiverFromConfig(newTestReceiverConfig())
+	server := httptest.NewServer(http.HandlerFunc(r.handleXRaySegments))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(testXRaySegment))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	p := <-r.out
+	assert.Len(p.TracerPayload.Chunks, 1)
+	assert.Len(p.TracerPayload.Chunks[0].Spans, 3)
+	assert.EqualValues(1, r.xrayTagStats("").TracesReceived)
+
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader(`{"id": "bad"`))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	assert.EqualValues(1, r.xrayTagStats("").TracesDropped.DecodingError)
+}
+
+// jsonString returns s encoded as a JSON string.
+func jsonString(s string) string {
+	r := strings.NewReplacer(`"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
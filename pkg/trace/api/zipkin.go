@@ -0,0 +1,284 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// zipkinEndpointVersion is the endpoint version under which the stats of the traces received
+// as Zipkin v2 spans are reported.
+const zipkinEndpointVersion = "zipkin"
+
+// zipkinSpan is a span of the Zipkin v2 API, see:
+// https://zipkin.io/zipkin-api/#/default/post_spans
+type zipkinSpan struct {
+	TraceID        string            `json:"traceId"`
+	ID             string            `json:"id"`
+	ParentID       string            `json:"parentId"`
+	Name           string            `json:"name"`
+	Kind           string            `json:"kind"`
+	Timestamp      uint64            `json:"timestamp"`
+	Duration       uint64            `json:"duration"`
+	LocalEndpoint  *zipkinEndpoint   `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint   `json:"remoteEndpoint"`
+	Tags           map[string]string `json:"tags"`
+	Shared         bool              `json:"shared"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+// zipkinID converts a Zipkin trace or span ID (up to 32 hex digits) to a Datadog ID, made of
+// the 64 lower bits of 128-bit trace IDs.
+func zipkinID(id string) (uint64, error) {
+	if len(id) == 0 || len(id) > 32 {
+		return 0, fmt.Errorf("invalid Zipkin ID %q", id)
+	}
+	if len(id) > 16 {
+		if _, err := strconv.ParseUint(id[:len(id)-16], 16, 64); err != nil {
+			return 0, fmt.Errorf("invalid Zipkin ID %q", id)
+		}
+		id = id[len(id)-16:]
+	}
+	v, err := strconv.ParseUint(id, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Zipkin ID %q", id)
+	}
+	return v, nil
+}
+
+// zipkinSpanToDD converts a Zipkin span to a Datadog span. Zipkin timestamps and durations
+// are in microseconds.
+func zipkinSpanToDD(s *zipkinSpan) (*pb.Span, error) {
+	span := &pb.Span{
+		Resource: s.Name,
+		Start:    int64(s.Timestamp) * 1e3,
+		Duration: int64(s.Duration) * 1e3,
+		Meta:     make(map[string]string, len(s.Tags)+4),
+	}
+	var err error
+	if span.TraceID, err = zipkinID(s.TraceID); err != nil {
+		return nil, err
+	}
+	if span.SpanID, err = zipkinID(s.ID); err != nil {
+		return nil, err
+	}
+	if s.ParentID != "" {
+		if span.ParentID, err = zipkinID(s.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	if s.LocalEndpoint != nil {
+		span.Service = s.LocalEndpoint.ServiceName
+	}
+	if span.Service == "" {
+		span.Service = "unknown_service"
+	}
+
+	// the operation name is made of the span kind, such as "zipkin.server", while the Zipkin
+	// span name is the resource
+	kind := strings.ToLower(s.Kind)
+	if kind == "" {
+		kind = "internal"
+	}
+	span.Name = "zipkin." + kind
+	span.Meta["span.kind"] = kind
+
+	for k, v := range s.Tags {
+		span.Meta[k] = v
+	}
+	if e := s.RemoteEndpoint; e != nil {
+		if e.ServiceName != "" {
+			span.Meta["peer.service"] = e.ServiceName
+		}
+		if host := e.IPv4; host != "" || e.IPv6 != "" {
+			if host == "" {
+				host = e.IPv6
+			}
+			span.Meta["out.host"] = host
+		}
+		if e.Port != 0 {
+			span.Meta["out.port"] = strconv.Itoa(e.Port)
+		}
+	}
+	if s.Shared {
+		span.Meta["zipkin.shared"] = "true"
+	}
+
+	switch {
+	case span.Meta["sql.query"] != "" || span.Meta["db.type"] != "" || span.Meta["db.system"] != "":
+		span.Type = "db"
+	case kind == "server":
+		span.Type = "web"
+	case kind == "client" && span.Meta["http.method"] != "":
+		span.Type = "http"
+	default:
+		span.Type = "custom"
+	}
+
+	// the error tag marks failed spans, its value being the error message when known, see:
+	// https://github.com/openzipkin/zipkin-api/blob/master/thrift/zipkinCore.thrift
+	if msg, ok := s.Tags["error"]; ok {
+		span.Error = 1
+		delete(span.Meta, "error")
+		if msg != "" && msg != "true" {
+			span.Meta["error.msg"] = msg
+		}
+	}
+	return span, nil
+}
+
+// tracesFromZipkinSpans converts Zipkin spans to traces.
+func tracesFromZipkinSpans(spans []*zipkinSpan) (pb.Traces, error) {
+	var traces pb.Traces
+	byID := make(map[uint64]int)
+	for _, s := range spans {
+		span, err := zipkinSpanToDD(s)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := byID[span.TraceID]
+		if !ok {
+			i = len(traces)
+			byID[span.TraceID] = i
+			traces = append(traces, nil)
+		}
+		traces[i] = append(traces[i], span)
+	}
+	for _, trace := range traces {
+		splitSharedSpans(trace)
+	}
+	return traces, nil
+}
+
+// splitSharedSpans gives a distinct ID to the server side of the RPC spans of trace of which
+// the ID is shared with the client side, as done with B3 propagation, since Datadog spans
+// can't share IDs. The server side becomes a child of the client side, and the parent of the
+// spans of its service which referred to the shared ID. Its new ID is derived from the shared
+// one, so that it is the same whichever payload the spans referring to it are received in.
+func splitSharedSpans(trace pb.Trace) {
+	var shared map[uint64]*pb.Span
+	for _, span := range trace {
+		if span.Meta["zipkin.shared"] != "true" {
+			continue
+		}
+		if shared == nil {
+			shared = make(map[uint64]*pb.Span)
+		}
+		shared[span.SpanID] = span
+	}
+	if shared == nil {
+		return
+	}
+	for id, span := range shared {
+		span.ParentID = id
+		span.SpanID = sharedSpanID(id)
+	}
+	for _, span := range trace {
+		if server, ok := shared[span.ParentID]; ok && server != span && server.Service == span.Service {
+			span.ParentID = server.SpanID
+		}
+	}
+}
+
+// sharedSpanID returns the ID given to the server side of a span of which the ID id is shared
+// with the client side.
+func sharedSpanID(id uint64) uint64 {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	h := fnv.New64a()
+	h.Write(b[:])
+	return h.Sum64()
+}
+
+// zipkinTagStats returns the stats of the traces received as Zipkin spans on the given transport.
+func (r *HTTPReceiver) zipkinTagStats(transport string) *info.TagStats {
+	return r.Stats.GetTagStats(info.Tags{EndpointVersion: zipkinEndpointVersion, Transport: transport})
+}
+
+// handleZipkinSpans handles the Zipkin v2 spans encoded in JSON, optionally gzipped as done by
+// most Zipkin reporters.
+func (r *HTTPReceiver) handleZipkinSpans(w http.ResponseWriter, req *http.Request) {
+	if mediaType := getMediaType(req); mediaType != "application/json" {
+		httpFormatError(w, zipkinEndpointVersion, fmt.Errorf("unsupported media type: %q", mediaType))
+		return
+	}
+	ts := r.zipkinTagStats(requestTransport(req))
+	var body io.ReadCloser = NewLimitedReader(req.Body, r.conf.MaxRequestBytes)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			atomic.AddInt64(&ts.TracesDropped.DecodingError, 1)
+			httpDecodingError(err, []string{"handler:zipkin"}, w)
+			return
+		}
+		defer gz.Close()
+		// the limit also applies to the decompressed spans
+		body = NewLimitedReader(gz, r.conf.MaxRequestBytes)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		httpDecodingError(err, []string{"handler:zipkin"}, w)
+		if err == ErrLimitedReaderLimitReached {
+			atomic.AddInt64(&ts.TracesDropped.PayloadTooLarge, 1)
+		}
+		return
+	}
+	if err := r.processZipkinSpans(ts, data, req.RemoteAddr, req.Header); err != nil {
+		httpDecodingError(err, []string{"handler:zipkin"}, w)
+		log.Errorf("Cannot decode Zipkin spans payload: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// processZipkinSpans converts the Zipkin spans of a payload sent from the given address with
+// the given headers to traces and sends them to the agent.
+func (r *HTTPReceiver) processZipkinSpans(ts *info.TagStats, data []byte, addr string, header http.Header) error {
+	var spans []*zipkinSpan
+	err := json.Unmarshal(data, &spans)
+	if err == nil {
+		var traces pb.Traces
+		if traces, err = tracesFromZipkinSpans(spans); err == nil {
+			if len(traces) == 0 {
+				return nil
+			}
+			if r.rateLimited(payloadOrigin("", addr), int64(len(traces))) {
+				atomic.AddInt64(&ts.PayloadRefused, 1)
+				return nil
+			}
+			atomic.AddInt64(&ts.TracesReceived, int64(len(traces)))
+			atomic.AddInt64(&ts.TracesBytes, int64(len(data)))
+			atomic.AddInt64(&ts.PayloadAccepted, 1)
+			r.sendPayload(&Payload{
+				Source:        ts,
+				TracerPayload: &pb.TracerPayload{Chunks: traceChunksFromTraces(traces)},
+			}, header)
+			return nil
+		}
+	}
+	atomic.AddInt64(&ts.TracesDropped.DecodingError, 1)
+	return err
+}
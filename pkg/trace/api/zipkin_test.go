@@ -0,0 +1,177 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testZipkinSpans = `[
+	{
+		"traceId": "5af7183fb1d4cf5f463acb4ea3f4b9c2",
+		"id": "352bff9a74ca9ad2",
+		"kind": "SERVER",
+		"name": "get /api",
+		"timestamp": 1556604172355737,
+		"duration": 1431,
+		"localEndpoint": {"serviceName": "backend", "ipv4": "192.168.99.1", "port": 3306},
+		"remoteEndpoint": {"ipv4": "172.19.0.2", "port": 58648},
+		"tags": {"http.method": "GET", "http.path": "/api"}
+	},
+	{
+		"traceId": "5af7183fb1d4cf5f463acb4ea3f4b9c2",
+		"parentId": "352bff9a74ca9ad2",
+		"id": "6b221d5bc9e6496c",
+		"kind": "CLIENT",
+		"name": "select",
+		"timestamp": 1556604172355900,
+		"duration": 800,
+		"localEndpoint": {"serviceName": "backend"},
+		"remoteEndpoint": {"serviceName": "mysql", "ipv4": "172.19.0.3", "port": 3306},
+		"tags": {"sql.query": "select * from users", "error": "connection reset"}
+	},
+	{
+		"traceId": "463ac35c9f6413ad",
+		"id": "463ac35c9f6413ad",
+		"name": "cron",
+		"timestamp": 1556604172355000,
+		"duration": 10
+	}
+]`
+
+func TestZipkinID(t *testing.T) {
+	assert := assert.New(t)
+
+	id, err := zipkinID("5af7183fb1d4cf5f463acb4ea3f4b9c2")
+	assert.NoError(err)
+	assert.EqualValues(0x463acb4ea3f4b9c2, id)
+
+	id, err = zipkinID("352bff9a74ca9ad2")
+	assert.NoError(err)
+	assert.EqualValues(0x352bff9a74ca9ad2, id)
+
+	for _, id := range []string{"", "zzz", "5af7183fb1d4cf5z463acb4ea3f4b9c2", "5af7183fb1d4cf5f463acb4ea3f4b9c2a"} {
+		_, err := zipkinID(id)
+		assert.Error(err, id)
+	}
+}
+
+func TestTracesFromZipkinSpans(t *testing.T) {
+	assert := assert.New(t)
+	var spans []*zipkinSpan
+	assert.NoError(json.Unmarshal([]byte(testZipkinSpans), &spans))
+
+	traces, err := tracesFromZipkinSpans(spans)
+	assert.NoError(err)
+	assert.Len(traces, 2)
+	assert.Len(traces[0], 2)
+	assert.Len(traces[1], 1)
+
+	server := traces[0][0]
+	assert.Equal("backend", server.Service)
+	assert.Equal("zipkin.server", server.Name)
+	assert.Equal("get /api", server.Resource)
+	assert.Equal("web", server.Type)
+	assert.EqualValues(0x463acb4ea3f4b9c2, server.TraceID)
+	assert.EqualValues(0x352bff9a74ca9ad2, server.SpanID)
+	assert.EqualValues(0, server.ParentID)
+	assert.EqualValues(1556604172355737000, server.Start)
+	assert.EqualValues(1431000, server.Duration)
+	assert.EqualValues(0, server.Error)
+	assert.Equal("GET", server.Meta["http.method"])
+	assert.Equal("172.19.0.2", server.Meta["out.host"])
+	assert.Equal("58648", server.Meta["out.port"])
+
+	client := traces[0][1]
+	assert.Equal("zipkin.client", client.Name)
+	assert.Equal("db", client.Type)
+	assert.EqualValues(server.SpanID, client.ParentID)
+	assert.EqualValues(1, client.Error)
+	assert.Equal("connection reset", client.Meta["error.msg"])
+	assert.Equal("mysql", client.Meta["peer.service"])
+	assert.NotContains(client.Meta, "error")
+
+	local := traces[1][0]
+	assert.Equal("zipkin.internal", local.Name)
+	assert.Equal("custom", local.Type)
+	assert.Equal("unknown_service", local.Service)
+
+	_, err = tracesFromZipkinSpans([]*zipkinSpan{{TraceID: "463ac35c9f6413ad", ID: "xyz"}})
+	assert.Error(err)
+}
+
+func TestTracesFromZipkinSharedSpans(t *testing.T) {
+	assert := assert.New(t)
+	var spans []*zipkinSpan
+	assert.NoError(json.Unmarshal([]byte(`[
+		{"traceId": "463ac35c9f6413ad", "id": "a2fb4a1d1a96d312", "kind": "CLIENT", "name": "get", "localEndpoint": {"serviceName": "frontend"}},
+		{"traceId": "463ac35c9f6413ad", "id": "a2fb4a1d1a96d312", "kind": "SERVER", "name": "get", "shared": true, "localEndpoint": {"serviceName": "backend"}},
+		{"traceId": "463ac35c9f6413ad", "parentId": "a2fb4a1d1a96d312", "id": "0020000000000001", "kind": "CLIENT", "name": "query", "localEndpoint": {"serviceName": "backend"}}
+	]`), &spans))
+
+	traces, err := tracesFromZipkinSpans(spans)
+	assert.NoError(err)
+	assert.Len(traces, 1)
+	client, server, query := traces[0][0], traces[0][1], traces[0][2]
+	assert.EqualValues(0xa2fb4a1d1a96d312, client.SpanID)
+	assert.NotEqual(client.SpanID, server.SpanID)
+	assert.Equal(sharedSpanID(client.SpanID), server.SpanID)
+	assert.Equal(client.SpanID, server.ParentID)
+	assert.Equal(server.SpanID, query.ParentID)
+}
+
+func TestHandleZipkinSpans(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestReceiverFromConfig(newTestReceiverConfig())
+	server := httptest.NewServer(http.HandlerFunc(r.handleZipkinSpans))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(testZipkinSpans))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusAccepted, resp.StatusCode)
+
+	p := <-r.out
+	assert.Len(p.TracerPayload.Chunks, 2)
+	assert.Len(p.TracerPayload.Chunks[0].Spans, 2)
+	assert.EqualValues(2, r.zipkinTagStats("").TracesReceived)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(testZipkinSpans))
+	gz.Close()
+	req, err := http.NewRequest("POST", server.URL, &buf)
+	assert.NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusAccepted, resp.StatusCode)
+
+	p = <-r.out
+	assert.Len(p.TracerPayload.Chunks, 2)
+	assert.EqualValues(4, r.zipkinTagStats("").TracesReceived)
+
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader(`[{"traceId": "bad"`))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	assert.EqualValues(1, r.zipkinTagStats("").TracesDropped.DecodingError)
+
+	resp, err = http.Post(server.URL, "application/x-thrift", strings.NewReader(testZipkinSpans))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusUnsupportedMediaType, resp.StatusCode)
+}
@@ -0,0 +1,23 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package api
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// newZstdReader returns a reader decompressing the zstd stream r. It uses a pure Go
+// decoder, so that zstd requests are supported by all the builds of the agent.
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	// a single goroutine is enough to decode the bodies, which are read as they arrive
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
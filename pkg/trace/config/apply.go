@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -35,17 +36,113 @@ type ObfuscationConfig struct {
 	// HTTP holds the obfuscation settings for HTTP URLs.
 	HTTP HTTPObfuscationConfig `mapstructure:"http"`
 
+	// SQL holds the obfuscation settings for SQL queries.
+	SQL SQLObfuscationConfig `mapstructure:"sql"`
+
 	// RemoveStackTraces specifies whether stack traces should be removed.
 	// More specifically "error.stack" tag values will be cleared.
 	RemoveStackTraces bool `mapstructure:"remove_stack_traces"`
 
 	// Redis holds the configuration for obfuscating the "redis.raw_command" tag
 	// for spans of type "redis".
-	Redis Enablable `mapstructure:"redis"`
+	Redis RedisObfuscationConfig `mapstructure:"redis"`
 
 	// Memcached holds the configuration for obfuscating the "memcached.command" tag
 	// for spans of type "memcached".
 	Memcached Enablable `mapstructure:"memcached"`
+
+	// PII holds the configuration for masking credit card numbers, email addresses and IP
+	// addresses in the meta values of all spans.
+	PII PIIObfuscationConfig `mapstructure:"pii"`
+
+	// ScrubRules lists user-defined rules redacting the matches of regular expressions in the
+	// meta values of all spans.
+	ScrubRules []*ScrubRule `mapstructure:"scrub_rules"`
+
+	// Bypass lists services for which some obfuscators should not be applied.
+	Bypass []ObfuscationBypass `mapstructure:"bypass"`
+
+	// ServiceOverrides lists services for which some of the settings above are changed.
+	ServiceOverrides []ObfuscationOverride `mapstructure:"service_overrides"`
+}
+
+// ObfuscationOverride changes some obfuscation settings for the spans of a given service.
+// Settings which are not set keep their global value.
+type ObfuscationOverride struct {
+	// Service specifies the name of the service.
+	Service string `mapstructure:"service"`
+
+	// SQLQuantizeLiterals specifies whether the literals of SQL queries are replaced
+	// with "?". They are by default.
+	SQLQuantizeLiterals *bool `mapstructure:"sql_quantize_literals"`
+
+	// ESEnabled specifies whether ElasticSearch bodies are obfuscated.
+	ESEnabled *bool `mapstructure:"elasticsearch_enabled"`
+
+	// HTTPRemoveQueryString specifies whether query strings are removed from HTTP URLs.
+	HTTPRemoveQueryString *bool `mapstructure:"http_remove_query_string"`
+}
+
+// ObfuscationBypass disables a set of obfuscators for the spans of a given service.
+type ObfuscationBypass struct {
+	// Service specifies the name of the service.
+	Service string `mapstructure:"service"`
+
+	// Obfuscators lists the obfuscators which should not be applied to the spans of
+	// Service. Known values are "sql", "redis", "memcached", "http", "mongodb" and
+	// "elasticsearch". The "*" value disables all of them.
+	Obfuscators []string `mapstructure:"obfuscators"`
+}
+
+// MetaLimitConfig holds the configuration for handling spans carrying too many meta entries.
+type MetaLimitConfig struct {
+	// MaxEntries is the maximum number of meta entries of a span. 0 disables the limit.
+	MaxEntries int `mapstructure:"max_entries"`
+
+	// Policy specifies what happens to the entries over the limit. With "spill", they are
+	// moved to a compressed blob in the "_dd.meta.overflow" tag. With "drop", they are removed.
+	Policy string `mapstructure:"policy"`
+
+	// KeyPriority lists the meta keys kept first when a span is over the limit, in order of
+	// priority. A key ending with "*" matches all the keys starting with it.
+	KeyPriority []string `mapstructure:"key_priority"`
+}
+
+// ArchiveConfig holds the configuration of the archive of the sampled traces, uploaded in
+// batches to an object store compatible with the S3 API.
+type ArchiveConfig struct {
+	// Enabled specifies that the sampled traces are archived.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Bucket is the bucket in which the batches of traces are uploaded.
+	Bucket string `mapstructure:"bucket"`
+
+	// Prefix is prepended to the keys of the batches, which are partitioned by date and hour.
+	Prefix string `mapstructure:"prefix"`
+
+	// Region is the region of the bucket.
+	Region string `mapstructure:"region"`
+
+	// Endpoint is the URL of the object store. When empty, the AWS S3 endpoint of the region
+	// is used.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// ForcePathStyle specifies that the bucket is part of the path of the requests instead
+	// of their host, as required by most S3 compatible object stores.
+	ForcePathStyle bool `mapstructure:"force_path_style"`
+
+	// AccessKeyID and SecretAccessKey are the credentials used to upload the batches. When
+	// empty, the credentials are found the same way as by the AWS SDK (environment, shared
+	// credentials file, instance role).
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key" json:"-"`
+
+	// FlushPeriodSeconds specifies how often the traces are uploaded, in seconds.
+	FlushPeriodSeconds float64 `mapstructure:"flush_period_seconds"`
+
+	// MaxBatchBytes specifies the estimated size of the traces from which a batch is uploaded
+	// without waiting for the flush period, in bytes.
+	MaxBatchBytes int `mapstructure:"max_batch_bytes"`
 }
 
 // HTTPObfuscationConfig holds the configuration settings for HTTP obfuscation.
@@ -57,6 +154,42 @@ type HTTPObfuscationConfig struct {
 	RemovePathDigits bool `mapstructure:"remove_paths_with_digits"`
 }
 
+// SQLObfuscationConfig holds the configuration settings for SQL obfuscation.
+type SQLObfuscationConfig struct {
+	// KeepComments specifies whether the block comments of queries ("/* ... */"), such as the
+	// ones added by sqlcommenter, are kept. Line comments are always removed.
+	KeepComments bool `mapstructure:"keep_comments"`
+
+	// TableNames specifies whether the names of the tables addressed by queries are extracted
+	// into the "sql.tables" tag.
+	TableNames bool `mapstructure:"table_names"`
+}
+
+// RedisObfuscationConfig holds the configuration settings for Redis obfuscation.
+type RedisObfuscationConfig struct {
+	// Enabled specifies whether the arguments of Redis commands are obfuscated.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Commands lists rules changing how the arguments of families of commands are obfuscated.
+	// The commands which are not listed are obfuscated as by default.
+	Commands []*RedisCommandRule `mapstructure:"commands"`
+}
+
+// RedisCommandRule specifies how the arguments of a family of Redis commands are obfuscated.
+type RedisCommandRule struct {
+	// Names lists the commands of the family, such as "GET" and "SET". They are not
+	// case-sensitive.
+	Names []string `mapstructure:"names"`
+
+	// KeepArgs is the number of leading arguments which are kept, such as the key of "GET"
+	// and "SET". The remaining arguments are replaced with a single "?".
+	KeepArgs int `mapstructure:"keep_args"`
+
+	// CommandOnly specifies that all the arguments are removed, only keeping the name of
+	// the command.
+	CommandOnly bool `mapstructure:"command_only"`
+}
+
 // Enablable can represent any option that has an "enabled" boolean sub-field.
 type Enablable struct {
 	Enabled bool `mapstructure:"enabled"`
@@ -73,6 +206,20 @@ type JSONObfuscationConfig struct {
 	KeepValues []string `mapstructure:"keep_values"`
 }
 
+// PIIObfuscationConfig holds the configuration for masking personal data found in the meta
+// values of spans, whatever their tag.
+type PIIObfuscationConfig struct {
+	// Enabled specifies whether the meta values of spans are scanned.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Kinds lists the kinds of data masked. Known values are "credit_card", "email" and "ip".
+	// All of them are masked when empty.
+	Kinds []string `mapstructure:"kinds"`
+
+	// KeepTags lists the tags whose values are not scanned.
+	KeepTags []string `mapstructure:"keep_tags"`
+}
+
 // ReplaceRule specifies a replace rule.
 type ReplaceRule struct {
 	// Name specifies the name of the tag that the replace rule addresses. However,
@@ -91,6 +238,58 @@ type ReplaceRule struct {
 	Repl string `mapstructure:"repl"`
 }
 
+// ScrubRule is a user-defined obfuscation rule replacing the matches of a regular expression
+// in the meta values of spans.
+type ScrubRule struct {
+	// Name identifies the rule. It must be unique.
+	Name string `mapstructure:"name"`
+
+	// Pattern specifies the regexp pattern to be used when replacing. It must compile.
+	Pattern string `mapstructure:"pattern"`
+
+	// Re holds the compiled Pattern and is only used internally.
+	Re *regexp.Regexp `mapstructure:"-"`
+
+	// Repl specifies the replacement string to be used when Pattern matches. It may refer
+	// to the submatches of Pattern (e.g. "${1}").
+	Repl string `mapstructure:"repl"`
+
+	// Tags lists the glob patterns of the meta keys the rule applies to (e.g. "http.*").
+	// When empty, the rule applies to all meta keys except the internal "_dd." ones.
+	Tags []string `mapstructure:"tags"`
+}
+
+// SamplingRule specifies the rate at which the traces whose root span matches it are sampled,
+// bypassing the adaptive logic of the samplers.
+type SamplingRule struct {
+	// Service and Name are the service and operation name of the root span. Empty values match all.
+	Service string `mapstructure:"service"`
+	Name    string `mapstructure:"name"`
+
+	// Resource specifies a regexp pattern the resource of the root span must match. Empty matches all.
+	Resource string `mapstructure:"resource"`
+
+	// SampleRate is the rate at which the matching traces are sampled, between 0 and 1.
+	SampleRate float64 `mapstructure:"sample_rate"`
+
+	// ResourceRe holds the compiled Resource and is only used internally.
+	ResourceRe *regexp.Regexp `mapstructure:"-"`
+}
+
+// SpanSamplingRule specifies the rate at which the spans matching it are kept by single-span
+// sampling when the trace they belong to is dropped.
+type SpanSamplingRule struct {
+	// Service and Name are the service and operation name of the span. Empty values match all.
+	Service string `mapstructure:"service"`
+	Name    string `mapstructure:"name"`
+
+	// SampleRate is the rate at which the matching spans are kept, between 0 and 1.
+	SampleRate float64 `mapstructure:"sample_rate"`
+
+	// MaxPerSecond is the maximum number of matching spans kept per second. 0 means unlimited.
+	MaxPerSecond float64 `mapstructure:"max_per_second"`
+}
+
 // WriterConfig specifies configuration for an API writer.
 type WriterConfig struct {
 	// ConnectionLimit specifies the maximum number of concurrent outgoing
@@ -104,6 +303,11 @@ type WriterConfig struct {
 	// FlushPeriodSeconds specifies the frequency at which the writer's buffer
 	// will be flushed to the sender, in seconds. Fractions are permitted.
 	FlushPeriodSeconds float64 `mapstructure:"flush_period_seconds"`
+
+	// HeartbeatIntervalSeconds specifies after how long without flushing anything the
+	// writer sends an empty payload to the intake, in seconds, so that an idle agent
+	// can be told apart from a stopped one. Heartbeats are disabled when 0.
+	HeartbeatIntervalSeconds float64 `mapstructure:"heartbeat_interval_seconds"`
 }
 
 func (c *AgentConfig) applyDatadogConfig() error {
@@ -146,6 +350,30 @@ func (c *AgentConfig) applyDatadogConfig() error {
 		}
 	}
 
+	if config.Datadog.IsSet("apm_config.api_key_aliases") {
+		var aliases map[string]struct {
+			APIKey string `mapstructure:"api_key"`
+			Host   string `mapstructure:"dd_url"`
+		}
+		if err := config.Datadog.UnmarshalKey("apm_config.api_key_aliases", &aliases); err != nil {
+			log.Errorf("Failed to parse apm_config.api_key_aliases: %v", err)
+		}
+		c.APIKeyAliases = make(map[string]*Endpoint, len(aliases))
+		for alias, e := range aliases {
+			if e.APIKey == "" {
+				log.Errorf("'api_key_aliases' entry %q must have an API key, it is ignored", alias)
+				continue
+			}
+			if e.Host == "" {
+				e.Host = c.Endpoints[0].Host
+			}
+			c.APIKeyAliases[alias] = &Endpoint{Host: e.Host, APIKey: config.SanitizeAPIKey(e.APIKey)}
+		}
+	}
+	if config.Datadog.IsSet("apm_config.accept_tracer_api_keys") {
+		c.AcceptTracerAPIKeys = config.Datadog.GetBool("apm_config.accept_tracer_api_keys")
+	}
+
 	if config.Datadog.IsSet("proxy.no_proxy") {
 		proxyList := config.Datadog.GetStringSlice("proxy.no_proxy")
 		noProxy := make(map[string]bool, len(proxyList))
@@ -156,6 +384,9 @@ func (c *AgentConfig) applyDatadogConfig() error {
 		for _, e := range c.Endpoints {
 			e.NoProxy = noProxy[e.Host]
 		}
+		for _, e := range c.APIKeyAliases {
+			e.NoProxy = noProxy[e.Host]
+		}
 	}
 	if addr := config.Datadog.GetString("proxy.https"); addr != "" {
 		url, err := url.Parse(addr)
@@ -196,6 +427,63 @@ func (c *AgentConfig) applyDatadogConfig() error {
 	if config.Datadog.IsSet("apm_config.receiver_socket") {
 		c.ReceiverSocket = config.Datadog.GetString("apm_config.receiver_socket")
 	}
+	if config.Datadog.IsSet("apm_config.receiver_reuse_port") {
+		c.ReceiverReusePort = config.Datadog.GetBool("apm_config.receiver_reuse_port")
+	}
+	if config.Datadog.IsSet("apm_config.receiver_handoff") {
+		c.ReceiverHandoff = config.Datadog.GetBool("apm_config.receiver_handoff")
+	}
+	if config.Datadog.IsSet("apm_config.receiver_hardened") {
+		c.ReceiverHardened = config.Datadog.GetBool("apm_config.receiver_hardened")
+	}
+	if config.Datadog.IsSet("apm_config.receiver_max_header_bytes") {
+		c.ReceiverMaxHeaderBytes = config.Datadog.GetInt("apm_config.receiver_max_header_bytes")
+	}
+	if config.Datadog.IsSet("apm_config.receiver_max_connections") {
+		c.ReceiverMaxConnections = config.Datadog.GetInt("apm_config.receiver_max_connections")
+	}
+	if config.Datadog.IsSet("apm_config.receiver_read_timeout") {
+		c.ReceiverReadTimeout = time.Duration(config.Datadog.GetInt("apm_config.receiver_read_timeout")) * time.Second
+	}
+	if config.Datadog.IsSet("apm_config.receiver_write_timeout") {
+		c.ReceiverWriteTimeout = time.Duration(config.Datadog.GetInt("apm_config.receiver_write_timeout")) * time.Second
+	}
+	if config.Datadog.IsSet("apm_config.receiver_idle_timeout") {
+		c.ReceiverIdleTimeout = time.Duration(config.Datadog.GetInt("apm_config.receiver_idle_timeout")) * time.Second
+	}
+	if config.Datadog.IsSet("apm_config.receiver_keep_alives") {
+		c.ReceiverDisableKeepAlives = !config.Datadog.GetBool("apm_config.receiver_keep_alives")
+	}
+	if config.Datadog.IsSet("apm_config.receiver_auth_token") {
+		c.ReceiverAuthToken = strings.TrimSpace(config.Datadog.GetString("apm_config.receiver_auth_token"))
+	}
+	if config.Datadog.IsSet("apm_config.receiver_tls.cert_file") {
+		c.ReceiverTLSCertFile = config.Datadog.GetString("apm_config.receiver_tls.cert_file")
+	}
+	if config.Datadog.IsSet("apm_config.receiver_tls.key_file") {
+		c.ReceiverTLSKeyFile = config.Datadog.GetString("apm_config.receiver_tls.key_file")
+	}
+	if config.Datadog.IsSet("apm_config.receiver_tls.client_ca_file") {
+		c.ReceiverTLSClientCAFile = config.Datadog.GetString("apm_config.receiver_tls.client_ca_file")
+	}
+	if config.Datadog.IsSet("apm_config.access_log.path") {
+		c.AccessLogPath = config.Datadog.GetString("apm_config.access_log.path")
+	}
+	if config.Datadog.IsSet("apm_config.access_log.sample_rate") {
+		rate := config.Datadog.GetFloat64("apm_config.access_log.sample_rate")
+		if rate < 0 || rate > 1 {
+			log.Warnf("Invalid apm_config.access_log.sample_rate %f: must be between 0 and 1, using 1", rate)
+			rate = 1
+		}
+		c.AccessLogSampleRate = rate
+	}
+	if config.Datadog.IsSet("apm_config.access_log.max_size") {
+		if size := config.Datadog.GetSizeInBytes("apm_config.access_log.max_size"); size > 0 {
+			c.AccessLogMaxSize = int64(size)
+		} else {
+			log.Warnf("Invalid apm_config.access_log.max_size: must be greater than 0, using %d", c.AccessLogMaxSize)
+		}
+	}
 	if config.Datadog.IsSet("apm_config.connection_limit") {
 		c.ConnectionLimit = config.Datadog.GetInt("apm_config.connection_limit")
 	}
@@ -208,12 +496,33 @@ func (c *AgentConfig) applyDatadogConfig() error {
 	if config.Datadog.IsSet("apm_config.max_traces_per_second") {
 		c.MaxTPS = config.Datadog.GetFloat64("apm_config.max_traces_per_second")
 	}
+	if k := "apm_config.max_error_traces_per_second_per_service"; config.Datadog.IsSet(k) {
+		c.MaxErrorTPSPerService = config.Datadog.GetFloat64(k)
+	}
+	if k := "apm_config.target_traces_per_second"; config.Datadog.IsSet(k) {
+		c.TargetTPS = config.Datadog.GetFloat64(k)
+	}
+	if k := "apm_config.priority_sampling_by_resource"; config.Datadog.IsSet(k) {
+		c.PrioritySamplingByResource = config.Datadog.GetBool(k)
+	}
 	if k := "apm_config.ignore_resources"; config.Datadog.IsSet(k) {
 		c.Ignore["resource"] = config.Datadog.GetStringSlice(k)
 	}
 	if k := "apm_config.max_payload_size"; config.Datadog.IsSet(k) {
 		c.MaxRequestBytes = config.Datadog.GetInt64(k)
 	}
+	if k := "apm_config.otlp_pipelines.metrics"; config.Datadog.IsSet(k) {
+		c.OTLPMetricsPipeline = config.Datadog.GetBool(k)
+	}
+	if k := "apm_config.otlp_pipelines.logs_port"; config.Datadog.IsSet(k) {
+		c.OTLPLogsPipelinePort = config.Datadog.GetInt(k)
+	}
+	if k := "apm_config.trace_reassembly.window_ms"; config.Datadog.IsSet(k) {
+		c.ReassemblyWindow = time.Duration(config.Datadog.GetInt(k)) * time.Millisecond
+	}
+	if k := "apm_config.trace_reassembly.max_spans"; config.Datadog.IsSet(k) {
+		c.ReassemblyMaxSpans = config.Datadog.GetInt(k)
+	}
 	if k := "apm_config.replace_tags"; config.Datadog.IsSet(k) {
 		rt := make([]*ReplaceRule, 0)
 		if err := config.Datadog.UnmarshalKey(k, &rt); err != nil {
@@ -226,15 +535,50 @@ func (c *AgentConfig) applyDatadogConfig() error {
 			c.ReplaceTags = rt
 		}
 	}
+	if k := "apm_config.sampling_rules"; config.Datadog.IsSet(k) {
+		rules := make([]*SamplingRule, 0)
+		if err := config.Datadog.UnmarshalKey(k, &rules); err != nil {
+			log.Errorf("Bad format for %q it should be of the form '[{\"service\": \"service_name\",\"name\":\"operation_name\",\"resource\":\"pattern\",\"sample_rate\":1}]', error: %v", k, err)
+		} else {
+			if err := compileSamplingRules(rules); err != nil {
+				osutil.Exitf("sampling_rules: %s", err)
+			}
+			c.SamplingRules = rules
+		}
+	}
+	if k := "apm_config.span_sampling_rules"; config.Datadog.IsSet(k) {
+		rules := make([]*SpanSamplingRule, 0)
+		if err := config.Datadog.UnmarshalKey(k, &rules); err != nil {
+			log.Errorf("Bad format for %q it should be of the form '[{\"service\": \"service_name\",\"name\":\"operation_name\",\"sample_rate\":1,\"max_per_second\":10}]', error: %v", k, err)
+		} else {
+			if err := validateSpanSamplingRules(rules); err != nil {
+				osutil.Exitf("span_sampling_rules: %s", err)
+			}
+			c.SpanSamplingRules = rules
+		}
+	}
 
+	if k := "apm_config.address_family"; config.Datadog.IsSet(k) {
+		switch family := strings.ToLower(strings.TrimSpace(config.Datadog.GetString(k))); family {
+		case "", "ipv4", "ipv6":
+			c.AddressFamily = family
+		default:
+			log.Errorf("Invalid value for %s: %q, it should be \"ipv4\" or \"ipv6\". Using both.", k, family)
+		}
+	}
 	if config.Datadog.IsSet("bind_host") {
-		host := config.Datadog.GetString("bind_host")
+		// IPv6 addresses may be enclosed in brackets, as in URLs
+		host := strings.TrimSuffix(strings.TrimPrefix(config.Datadog.GetString("bind_host"), "["), "]")
 		c.StatsdHost = host
 		c.ReceiverHost = host
 	}
 	if config.Datadog.IsSet("apm_config.apm_non_local_traffic") {
 		if config.Datadog.GetBool("apm_config.apm_non_local_traffic") {
+			// the unspecified address listens on both families, unless restricted to IPv4
 			c.ReceiverHost = "0.0.0.0"
+			if c.AddressFamily == "ipv6" {
+				c.ReceiverHost = "::"
+			}
 		}
 	}
 
@@ -242,12 +586,161 @@ func (c *AgentConfig) applyDatadogConfig() error {
 		var o ObfuscationConfig
 		err := config.Datadog.UnmarshalKey("apm_config.obfuscation", &o)
 		if err == nil {
+			if err := compileScrubRules(o.ScrubRules); err != nil {
+				osutil.Exitf("obfuscation.scrub_rules: %s", err)
+			}
+			if err := validateRedisCommandRules(o.Redis.Commands); err != nil {
+				osutil.Exitf("obfuscation.redis.commands: %s", err)
+			}
 			c.Obfuscation = &o
 			if c.Obfuscation.RemoveStackTraces {
 				c.addReplaceRule("error.stack", `(?s).*`, "?")
 			}
 		}
 	}
+	// the settings of the JSON obfuscators can be set from the environment, which the
+	// obfuscation object above doesn't include
+	es, mongo := "apm_config.obfuscation.elasticsearch", "apm_config.obfuscation.mongodb"
+	if c.Obfuscation == nil && (jsonObfuscationIsSet(es) || jsonObfuscationIsSet(mongo)) {
+		c.Obfuscation = new(ObfuscationConfig)
+	}
+	if c.Obfuscation != nil {
+		applyJSONObfuscation(es, &c.Obfuscation.ES)
+		applyJSONObfuscation(mongo, &c.Obfuscation.Mongo)
+	}
+	if k := "apm_config.obfuscation.pii.enabled"; config.Datadog.IsSet(k) {
+		if c.Obfuscation == nil {
+			c.Obfuscation = new(ObfuscationConfig)
+		}
+		c.Obfuscation.PII.Enabled = config.Datadog.GetBool(k)
+	}
+	if k := "apm_config.obfuscation.sql.keep_comments"; config.Datadog.IsSet(k) {
+		if c.Obfuscation == nil {
+			c.Obfuscation = new(ObfuscationConfig)
+		}
+		c.Obfuscation.SQL.KeepComments = config.Datadog.GetBool(k)
+	}
+	if k := "apm_config.obfuscation.sql.table_names"; config.Datadog.IsSet(k) {
+		if c.Obfuscation == nil {
+			c.Obfuscation = new(ObfuscationConfig)
+		}
+		c.Obfuscation.SQL.TableNames = config.Datadog.GetBool(k)
+	}
+
+	if config.Datadog.IsSet("apm_config.meta_limit") {
+		var m MetaLimitConfig
+		if err := config.Datadog.UnmarshalKey("apm_config.meta_limit", &m); err == nil {
+			c.MetaLimit = &m
+		} else {
+			log.Errorf("Failed to parse apm_config.meta_limit: %v", err)
+		}
+	}
+
+	if config.Datadog.GetBool("apm_config.archive.enabled") {
+		a := ArchiveConfig{
+			FlushPeriodSeconds: 60,
+			MaxBatchBytes:      10 * 1024 * 1024,
+		}
+		if err := config.Datadog.UnmarshalKey("apm_config.archive", &a); err != nil {
+			log.Errorf("Failed to parse apm_config.archive: %v", err)
+		} else if a.Bucket == "" {
+			log.Error("The archive of the sampled traces is enabled without apm_config.archive.bucket, it is disabled")
+		} else {
+			c.Archive = &a
+		}
+	}
+
+	if config.Datadog.IsSet("apm_config.inject_container_runtime_id") {
+		c.InjectContainerRuntimeID = config.Datadog.GetBool("apm_config.inject_container_runtime_id")
+	}
+
+	if config.Datadog.IsSet("apm_config.error_fingerprinting") {
+		c.ErrorFingerprinting = config.Datadog.GetBool("apm_config.error_fingerprinting")
+	}
+
+	if config.Datadog.IsSet("apm_config.tracer_config_file") {
+		c.TracerConfigFile = config.Datadog.GetString("apm_config.tracer_config_file")
+	}
+
+	if config.Datadog.IsSet("apm_config.language_quirks") {
+		c.LanguageQuirks = config.Datadog.GetStringSlice("apm_config.language_quirks")
+	}
+
+	if config.Datadog.IsSet("apm_config.client_computed_stats") {
+		c.ClientComputedStats = config.Datadog.GetBool("apm_config.client_computed_stats")
+	}
+
+	if config.Datadog.IsSet("apm_config.xray_udp_port") {
+		c.XRayUDPPort = config.Datadog.GetInt("apm_config.xray_udp_port")
+	}
+
+	if config.Datadog.IsSet("apm_config.jaeger_udp_port") {
+		c.JaegerUDPPort = config.Datadog.GetInt("apm_config.jaeger_udp_port")
+	}
+
+	if config.Datadog.IsSet("apm_config.receiver_grpc_port") {
+		c.ReceiverGRPCPort = config.Datadog.GetInt("apm_config.receiver_grpc_port")
+	}
+
+	if config.Datadog.IsSet("apm_config.min_tracer_versions") {
+		c.MinTracerVersions = make(map[string]string)
+		for lang, version := range config.Datadog.GetStringMapString("apm_config.min_tracer_versions") {
+			c.MinTracerVersions[strings.ToLower(lang)] = version
+		}
+	}
+
+	if config.Datadog.IsSet("apm_config.reject_outdated_tracers") {
+		c.RejectOutdatedTracers = config.Datadog.GetBool("apm_config.reject_outdated_tracers")
+	}
+
+	if config.Datadog.IsSet("apm_config.fine_stats.services") {
+		c.FineStatsServices = config.Datadog.GetStringSlice("apm_config.fine_stats.services")
+	}
+	if config.Datadog.IsSet("apm_config.fine_stats.bucket_size_ms") {
+		c.FineStatsBucketInterval = time.Duration(config.Datadog.GetInt("apm_config.fine_stats.bucket_size_ms")) * time.Millisecond
+	}
+	if config.Datadog.IsSet("apm_config.fine_stats.max_grains") {
+		c.FineStatsMaxGrains = config.Datadog.GetInt("apm_config.fine_stats.max_grains")
+	}
+	if k := "apm_config.stats_by_version"; config.Datadog.IsSet(k) {
+		c.StatsByVersion = config.Datadog.GetBool(k)
+	}
+	if k := "apm_config.stats_max_versions_per_service"; config.Datadog.IsSet(k) {
+		c.StatsMaxVersionsPerService = config.Datadog.GetInt(k)
+	}
+
+	if k := "apm_config.sampling_mode"; config.Datadog.IsSet(k) {
+		switch mode := strings.ToLower(strings.TrimSpace(config.Datadog.GetString(k))); mode {
+		case "", "adaptive":
+			c.SamplingMode = ""
+		case SamplingModeTraceIDHash:
+			c.SamplingMode = mode
+		default:
+			log.Errorf("Invalid value for %s: %q, it should be \"adaptive\" or %q. Using the adaptive samplers.", k, mode, SamplingModeTraceIDHash)
+		}
+	}
+	if k := "apm_config.trace_id_hash_sample_rate"; config.Datadog.IsSet(k) {
+		if rate := config.Datadog.GetFloat64(k); rate >= 0 && rate <= 1 {
+			c.TraceIDHashSampleRate = rate
+		} else {
+			log.Errorf("Invalid value for %s: %v, it should be between 0 and 1. Using %v.", k, rate, c.TraceIDHashSampleRate)
+		}
+	}
+	if config.Datadog.IsSet("apm_config.exception_sampler.enabled") {
+		c.ExceptionSamplerEnabled = config.Datadog.GetBool("apm_config.exception_sampler.enabled")
+	}
+	if config.Datadog.IsSet("apm_config.exception_sampler.ttl") {
+		c.ExceptionSamplerTTL = getDuration(config.Datadog.GetInt("apm_config.exception_sampler.ttl"))
+	}
+	if config.Datadog.IsSet("apm_config.exception_sampler.priority_ttl") {
+		c.ExceptionSamplerPriorityTTL = getDuration(config.Datadog.GetInt("apm_config.exception_sampler.priority_ttl"))
+	}
+	if config.Datadog.IsSet("apm_config.exception_sampler.cardinality_limit") {
+		c.ExceptionSamplerCardinalityLimit = config.Datadog.GetInt("apm_config.exception_sampler.cardinality_limit")
+	}
+	if config.Datadog.IsSet("apm_config.exception_sampler.signature") {
+		c.ExceptionSamplerSignature = config.Datadog.GetStringSlice("apm_config.exception_sampler.signature")
+	}
 
 	// undocumented
 	if config.Datadog.IsSet("apm_config.max_cpu_percent") {
@@ -368,6 +861,21 @@ func (c *AgentConfig) addReplaceRule(tag, pattern, repl string) {
 	})
 }
 
+// jsonObfuscationIsSet reports whether any setting of the JSON obfuscator under key is set.
+func jsonObfuscationIsSet(key string) bool {
+	return config.Datadog.IsSet(key+".enabled") || config.Datadog.IsSet(key+".keep_values")
+}
+
+// applyJSONObfuscation sets the settings of the JSON obfuscator under key to o.
+func applyJSONObfuscation(key string, o *JSONObfuscationConfig) {
+	if k := key + ".enabled"; config.Datadog.IsSet(k) {
+		o.Enabled = config.Datadog.GetBool(k)
+	}
+	if k := key + ".keep_values"; config.Datadog.IsSet(k) {
+		o.KeepValues = config.Datadog.GetStringSlice(k)
+	}
+}
+
 // compileReplaceRules compiles the regular expressions found in the replace rules.
 // If it fails it returns the first error.
 func compileReplaceRules(rules []*ReplaceRule) error {
@@ -387,6 +895,93 @@ func compileReplaceRules(rules []*ReplaceRule) error {
 	return nil
 }
 
+// compileScrubRules validates the scrub rules and compiles their patterns. If it fails it
+// returns the first error.
+func compileScrubRules(rules []*ScrubRule) error {
+	names := make(map[string]bool, len(rules))
+	for i, r := range rules {
+		if r.Name == "" {
+			return fmt.Errorf("rule %d: all rules must have a \"name\"", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rule %q: names must be unique", r.Name)
+		}
+		names[r.Name] = true
+		if r.Pattern == "" {
+			return fmt.Errorf("rule %q: all rules must have a \"pattern\"", r.Name)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("rule %q: %s", r.Name, err)
+		}
+		for _, tag := range r.Tags {
+			if _, err := path.Match(tag, ""); err != nil {
+				return fmt.Errorf("rule %q: bad tag pattern %q: %s", r.Name, tag, err)
+			}
+		}
+		r.Re = re
+	}
+	return nil
+}
+
+// validateRedisCommandRules validates the Redis command rules. If it fails it returns the
+// first error.
+func validateRedisCommandRules(rules []*RedisCommandRule) error {
+	names := make(map[string]bool)
+	for i, r := range rules {
+		if len(r.Names) == 0 {
+			return fmt.Errorf("rule %d: all rules must have \"names\"", i)
+		}
+		if r.KeepArgs < 0 {
+			return fmt.Errorf("rule %d: keep_args must not be negative, got %d", i, r.KeepArgs)
+		}
+		if r.CommandOnly && r.KeepArgs > 0 {
+			return fmt.Errorf("rule %d: keep_args and command_only are mutually exclusive", i)
+		}
+		for _, name := range r.Names {
+			name = strings.ToUpper(name)
+			if names[name] {
+				return fmt.Errorf("rule %d: command %s is already part of another rule", i, name)
+			}
+			names[name] = true
+		}
+	}
+	return nil
+}
+
+// compileSamplingRules validates the sampling rules and compiles their resource patterns.
+// If it fails it returns the first error.
+func compileSamplingRules(rules []*SamplingRule) error {
+	for i, r := range rules {
+		if r.SampleRate < 0 || r.SampleRate > 1 {
+			return fmt.Errorf("rule %d: sample_rate must be between 0 and 1, got %v", i, r.SampleRate)
+		}
+		if r.Resource == "" {
+			continue
+		}
+		re, err := regexp.Compile(r.Resource)
+		if err != nil {
+			return fmt.Errorf("rule %d: %s", i, err)
+		}
+		r.ResourceRe = re
+	}
+	return nil
+}
+
+// validateSpanSamplingRules validates the single-span sampling rules.
+// If it fails it returns the first error.
+func validateSpanSamplingRules(rules []*SpanSamplingRule) error {
+	for i, r := range rules {
+		if r.SampleRate < 0 || r.SampleRate > 1 {
+			return fmt.Errorf("rule %d: sample_rate must be between 0 and 1, got %v", i, r.SampleRate)
+		}
+		if r.MaxPerSecond < 0 {
+			return fmt.Errorf("rule %d: max_per_second must be positive, got %v", i, r.MaxPerSecond)
+		}
+	}
+	return nil
+}
+
 // getDuration returns the duration of the provided value in seconds
 func getDuration(seconds int) time.Duration {
 	return time.Duration(seconds) * time.Second
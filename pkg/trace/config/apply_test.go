@@ -27,3 +27,44 @@ func TestParseRepaceRules(t *testing.T) {
 		assert.Equal(r.Pattern, r.Re.String())
 	}
 }
+
+func TestCompileScrubRules(t *testing.T) {
+	assert := assert.New(t)
+	rules := []*ScrubRule{
+		{Name: "token", Pattern: "(token=)[^&]*", Repl: "${1}?", Tags: []string{"http.*"}},
+		{Name: "account", Pattern: "acct-[0-9]+", Repl: "acct-?"},
+	}
+	assert.NoError(compileScrubRules(rules))
+	for _, r := range rules {
+		assert.Equal(r.Pattern, r.Re.String())
+	}
+
+	for name, rules := range map[string][]*ScrubRule{
+		"no-name":     {{Pattern: "a"}},
+		"no-pattern":  {{Name: "a"}},
+		"bad-pattern": {{Name: "a", Pattern: "("}},
+		"bad-tag":     {{Name: "a", Pattern: "a", Tags: []string{"[http"}}},
+		"duplicate":   {{Name: "a", Pattern: "a"}, {Name: "a", Pattern: "b"}},
+	} {
+		assert.Error(compileScrubRules(rules), name)
+	}
+}
+
+func TestValidateRedisCommandRules(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(validateRedisCommandRules([]*RedisCommandRule{
+		{Names: []string{"GET", "SET"}, KeepArgs: 1},
+		{Names: []string{"AUTH"}, CommandOnly: true},
+		{Names: []string{"LPUSH"}},
+	}))
+
+	for name, rules := range map[string][]*RedisCommandRule{
+		"no-names":      {{KeepArgs: 1}},
+		"negative":      {{Names: []string{"GET"}, KeepArgs: -1}},
+		"exclusive":     {{Names: []string{"GET"}, KeepArgs: 1, CommandOnly: true}},
+		"duplicate":     {{Names: []string{"GET"}}, {Names: []string{"SET", "get"}}},
+		"duplicate-one": {{Names: []string{"GET", "GET"}}},
+	} {
+		assert.Error(validateRedisCommandRules(rules), name)
+	}
+}
@@ -7,6 +7,7 @@ package config
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"net"
@@ -31,6 +32,10 @@ var (
 	ErrMissingHostname = errors.New("failed to automatically set the hostname, you must specify it via configuration for or the DD_HOSTNAME env var")
 )
 
+// SamplingModeTraceIDHash is the sampling mode in which the traces are kept at a fixed rate from a
+// hash of their trace ID, so that all the agents make the same decision for a same trace.
+const SamplingModeTraceIDHash = "trace_id_hash"
+
 // Endpoint specifies an endpoint that the trace agent will write data (traces, stats & services) to.
 type Endpoint struct {
 	APIKey string `json:"-"` // never marshal this
@@ -60,14 +65,73 @@ type AgentConfig struct {
 	// configuration file, if present.
 	Endpoints []*Endpoint
 
+	// APIKeyAliases maps the aliases which tracers may send in the Datadog-API-Key-Alias header
+	// to the endpoints their traces are written to, allowing a single agent to serve several
+	// organizations. It is read from 'apm_config.api_key_aliases'.
+	APIKeyAliases map[string]*Endpoint `json:"-"` // never marshal this
+	// AcceptTracerAPIKeys allows tracers to send the API key their traces are written with
+	// in the DD-API-KEY header. The traces are written to the host of the main endpoint.
+	AcceptTracerAPIKeys bool
+
 	// Concentrator
 	BucketInterval   time.Duration // the size of our pre-aggregation per bucket
 	ExtraAggregators []string
 
+	// FineStatsServices lists the services whose stats are aggregated in buckets of
+	// FineStatsBucketInterval instead of BucketInterval.
+	FineStatsServices []string
+	// FineStatsBucketInterval is the size of the buckets of the services in FineStatsServices.
+	// It must divide BucketInterval.
+	FineStatsBucketInterval time.Duration
+	// FineStatsMaxGrains caps the number of grains aggregated in fine buckets awaiting to be
+	// flushed. Once reached, stats are aggregated in regular buckets.
+	FineStatsMaxGrains int
+
+	// StatsByVersion adds the version of the application, given by the version tag of the root
+	// span of a trace, to the grains of all its spans.
+	StatsByVersion bool
+	// StatsMaxVersionsPerService caps the number of versions the stats of each service are
+	// aggregated on in each bucket. Stats beyond it are aggregated without version.
+	StatsMaxVersionsPerService int
+
 	// Sampler configuration
 	ExtraSampleRate float64
 	MaxTPS          float64
 	MaxEPS          float64
+	// MaxErrorTPSPerService limits the traces per second sampled by the errors sampler for
+	// each service, in addition to MaxTPS. 0 disables the limit.
+	MaxErrorTPSPerService float64
+	// TargetTPS is the number of traces per second the rates sent back to the tracers aim to
+	// keep for the whole agent. 0 disables it, the rates then follow MaxTPS.
+	TargetTPS float64
+	// PrioritySamplingByResource enables the rates sent back to the tracers for each resource
+	// of the services, in addition to the rates of the services. They are only sent to the
+	// tracers supporting them.
+	PrioritySamplingByResource bool
+
+	// SamplingMode selects the samplers making the sampling decisions: the adaptive samplers
+	// when empty, or SamplingModeTraceIDHash.
+	SamplingMode string
+	// TraceIDHashSampleRate is the rate at which the traces are kept in the
+	// SamplingModeTraceIDHash sampling mode.
+	TraceIDHashSampleRate float64
+
+	// ExceptionSamplerEnabled enables the exception sampler, which keeps the traces with rare
+	// top-level or measured spans not kept by the other samplers.
+	ExceptionSamplerEnabled bool
+	// ExceptionSamplerTTL is the period during which a span sampled by the exception sampler is
+	// not sampled again.
+	ExceptionSamplerTTL time.Duration
+	// ExceptionSamplerPriorityTTL is the period during which the spans of the traces kept by the
+	// priority sampler are not sampled by the exception sampler.
+	ExceptionSamplerPriorityTTL time.Duration
+	// ExceptionSamplerCardinalityLimit limits the number of spans considered by the exception
+	// sampler per env and service.
+	ExceptionSamplerCardinalityLimit int
+	// ExceptionSamplerSignature lists the span attributes which, along with the service, the
+	// operation name and the error of the spans, tell them apart in the exception sampler.
+	// The defaults apply when empty.
+	ExceptionSamplerSignature []string
 
 	// Receiver
 	ReceiverHost    string
@@ -77,6 +141,76 @@ type AgentConfig struct {
 	ReceiverTimeout int
 	MaxRequestBytes int64 // specifies the maximum allowed request size for incoming trace payloads
 
+	// AddressFamily restricts the IP address family used by the receiver listeners and the
+	// outgoing connections to "ipv4" or "ipv6". When empty, both are used (dual-stack).
+	AddressFamily string
+
+	// ReceiverReusePort sets SO_REUSEPORT on the receiver TCP listener, allowing a new agent to
+	// listen on the same port before the previous one stops, such as during an upgrade.
+	ReceiverReusePort bool
+
+	// ReceiverHandoff enables handing off the listeners of the receiver to a new agent process
+	// started when receiving SIGUSR2.
+	ReceiverHandoff bool
+
+	// ReceiverHardened enables strict validation of the framing and headers of the requests received
+	// over TCP, rejecting the ambiguous ones which could be used for request smuggling. It is meant
+	// for deployments where the receiver is reachable from beyond localhost.
+	ReceiverHardened bool
+	// ReceiverMaxHeaderBytes is the maximum size of the request headers in hardened mode.
+	ReceiverMaxHeaderBytes int
+
+	// ReceiverMaxConnections is the maximum number of connections open at once on the receiver.
+	// The requests received on connections past the limit are refused with HTTP status 429
+	// and their connection is closed. It is unlimited when 0.
+	ReceiverMaxConnections int
+	// ReceiverReadTimeout and ReceiverWriteTimeout are the maximum durations for reading a
+	// request and writing its response. They default to ReceiverTimeout when 0.
+	ReceiverReadTimeout  time.Duration
+	ReceiverWriteTimeout time.Duration
+	// ReceiverIdleTimeout is the maximum duration a keep-alive connection waits for the next
+	// request. It defaults to the read timeout when 0.
+	ReceiverIdleTimeout time.Duration
+	// ReceiverDisableKeepAlives closes the connections of the receiver after each request.
+	ReceiverDisableKeepAlives bool
+
+	// ReceiverAuthToken, when set, is the shared secret which non-local clients must present as a
+	// bearer token ("Authorization: Bearer <token>") for their requests to be accepted by the receiver.
+	// Requests coming from the loopback interface, UDS or Windows pipes are exempt.
+	ReceiverAuthToken string `json:"-"` // never marshal this
+
+	// ReceiverTLSCertFile and ReceiverTLSKeyFile, when set, are the PEM encoded certificate and
+	// private key with which the receiver serves TLS on its TCP and gRPC ports.
+	ReceiverTLSCertFile string
+	ReceiverTLSKeyFile  string
+	// ReceiverTLSClientCAFile, when set, is the PEM encoded bundle of certificate authorities
+	// which must have signed the certificates of the clients of the receiver when TLS is enabled.
+	ReceiverTLSClientCAFile string
+
+	// OTLPMetricsPipeline specifies whether the OTLP metrics sent to the receiver are sent to
+	// DogStatsD. OTLPLogsPipelinePort, when set, is the port of the TCP logs source of the Agent
+	// to which the OTLP logs sent to the receiver are sent. When disabled, they are rejected with
+	// an error pointing to the right endpoints.
+	OTLPMetricsPipeline  bool
+	OTLPLogsPipelinePort int
+
+	// AccessLogPath, when set, is the file where a structured (JSON) log of the requests received
+	// by the receiver is written, separately from the agent logs.
+	AccessLogPath string
+	// AccessLogSampleRate is the proportion of requests written to the access log, between 0 and 1.
+	AccessLogSampleRate float64
+	// AccessLogMaxSize is the size in bytes beyond which the access log is rotated, the previous
+	// one being kept with the ".1" suffix.
+	AccessLogMaxSize int64
+
+	// ReassemblyWindow is the duration for which the chunks of a trace are held so that the chunks
+	// of the same trace received in other payloads are merged with them before sampling. The
+	// reassembly is disabled when 0.
+	ReassemblyWindow time.Duration
+	// ReassemblyMaxSpans is the maximum number of spans held for reassembly. The chunks received
+	// past the limit are processed right away.
+	ReassemblyMaxSpans int
+
 	// Writers
 	StatsWriter             *WriterConfig
 	TraceWriter             *WriterConfig
@@ -107,6 +241,14 @@ type AgentConfig struct {
 	// It maps tag keys to a set of replacements. Only supported in A6.
 	ReplaceTags []*ReplaceRule
 
+	// SamplingRules are consulted by the samplers before their adaptive logic, in order. The
+	// first rule matching the root span of a trace sets its sampling rate.
+	SamplingRules []*SamplingRule
+
+	// SpanSamplingRules select the spans kept on their own when the trace they belong to is
+	// dropped by the samplers. The first rule matching a span applies.
+	SpanSamplingRules []*SpanSamplingRule
+
 	// transaction analytics
 	AnalyzedRateByServiceLegacy map[string]float64
 	AnalyzedSpansByService      map[string]map[string]float64
@@ -116,6 +258,56 @@ type AgentConfig struct {
 
 	// Obfuscation holds sensitive data obufscator's configuration.
 	Obfuscation *ObfuscationConfig
+
+	// MetaLimit holds the configuration limiting the number of meta entries of spans.
+	MetaLimit *MetaLimitConfig
+
+	// Archive holds the configuration of the archive of the sampled traces. It is nil when
+	// the archive is disabled.
+	Archive *ArchiveConfig
+
+	// InjectContainerRuntimeID sets a runtime ID derived from the container ID on the payloads
+	// coming from containers which do not specify one, so that they can be correlated with the
+	// runtime metrics of the container.
+	InjectContainerRuntimeID bool
+
+	// ErrorFingerprinting enables the classification of span errors and the computation of
+	// their fingerprint, which is also used by the samplers to keep traces of each distinct error.
+	ErrorFingerprinting bool
+
+	// TracerConfigFile is the path of a JSON file holding the configurations served to the
+	// tracers on the /v0.7/config endpoint. The endpoint is disabled when it is empty.
+	TracerConfigFile string
+
+	// LanguageQuirks lists the names of the opt-in language quirks applied to the spans of the
+	// tracers of their language during normalization.
+	LanguageQuirks []string
+
+	// ClientComputedStats specifies that the stats of the payloads which the tracers flagged as
+	// having their stats computed client-side, including those of the traces they dropped, are
+	// not computed again by the agent, so that they are not counted twice.
+	ClientComputedStats bool
+
+	// XRayUDPPort is the UDP port on which AWS X-Ray segments are received using the
+	// protocol of the X-Ray daemon. The listener is disabled when 0.
+	XRayUDPPort int
+
+	// JaegerUDPPort is the UDP port on which Jaeger batches are received using the compact
+	// Thrift protocol, as the Jaeger agent does. The listener is disabled when 0.
+	JaegerUDPPort int
+
+	// ReceiverGRPCPort is the TCP port on which tracers can stream their payloads to the
+	// gRPC intake, as an alternative to the HTTP endpoints. The intake is disabled when 0.
+	ReceiverGRPCPort int
+
+	// MinTracerVersions maps languages, as reported by the tracers in the Datadog-Meta-Lang
+	// header, to the oldest tracer version expected for them. Payloads of older tracers are
+	// reported in the logs, or rejected when RejectOutdatedTracers is set.
+	MinTracerVersions map[string]string
+
+	// RejectOutdatedTracers specifies that the payloads of the tracers older than the minimum
+	// version of their language are rejected instead of only being reported.
+	RejectOutdatedTracers bool
 }
 
 // New returns a configuration with the default values.
@@ -128,14 +320,34 @@ func New() *AgentConfig {
 		BucketInterval:   time.Duration(10) * time.Second,
 		ExtraAggregators: []string{"http.status_code", "version", "_dd.hostname"},
 
+		FineStatsBucketInterval: time.Second,
+		FineStatsMaxGrains:      1000,
+
+		StatsByVersion:             true,
+		StatsMaxVersionsPerService: 10,
+
 		ExtraSampleRate: 1.0,
 		MaxTPS:          10,
 		MaxEPS:          200,
 
+		TraceIDHashSampleRate: 1,
+
+		ExceptionSamplerEnabled:          true,
+		ExceptionSamplerTTL:              2 * time.Minute,
+		ExceptionSamplerPriorityTTL:      10 * time.Minute,
+		ExceptionSamplerCardinalityLimit: 1000,
+
 		ReceiverHost:    "localhost",
 		ReceiverPort:    8126,
 		MaxRequestBytes: 50 * 1024 * 1024, // 50MB
 
+		ReceiverMaxHeaderBytes: 16 * 1024, // 16KB
+
+		AccessLogSampleRate: 1,
+		AccessLogMaxSize:    10 * 1024 * 1024, // 10MB
+
+		ReassemblyMaxSpans: 100000,
+
 		StatsWriter:             new(WriterConfig),
 		TraceWriter:             new(WriterConfig),
 		ConnectionResetInterval: 0, // disabled
@@ -222,11 +434,11 @@ func (c *AgentConfig) NewHTTPTransport() *http.Transport {
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: c.SkipSSLValidation},
 		// below field values are from http.DefaultTransport (go1.12)
 		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
+		DialContext: c.dialContext(&net.Dialer{
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
 			DualStack: true,
-		}).DialContext,
+		}),
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
@@ -238,6 +450,32 @@ func (c *AgentConfig) NewHTTPTransport() *http.Transport {
 	return transport
 }
 
+// dialContext returns the DialContext function of d, restricted to the configured address family.
+func (c *AgentConfig) dialContext(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c.AddressFamily == "" {
+		return d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, c.Network(network), addr)
+	}
+}
+
+// Network returns the network to use for listening or connecting over the given network
+// ("tcp" or "udp"), restricted to the configured address family (e.g. "tcp6" for "tcp"
+// if AddressFamily is "ipv6"). Other networks are returned unchanged.
+func (c *AgentConfig) Network(network string) string {
+	if network != "tcp" && network != "udp" {
+		return network
+	}
+	switch c.AddressFamily {
+	case "ipv4":
+		return network + "4"
+	case "ipv6":
+		return network + "6"
+	}
+	return network
+}
+
 // Load returns a new configuration based on the given path. The path must not necessarily exist
 // and a valid configuration can be returned based on defaults and environment variables. If a
 // valid configuration can not be obtained, an error is returned.
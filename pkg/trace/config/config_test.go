@@ -214,9 +214,49 @@ func TestFullYamlConfig(t *testing.T) {
 	assert.EqualValues([]string{"uid", "cat_id"}, o.Mongo.KeepValues)
 	assert.True(o.HTTP.RemoveQueryString)
 	assert.True(o.HTTP.RemovePathDigits)
+	assert.True(o.SQL.KeepComments)
+	assert.True(o.SQL.TableNames)
 	assert.True(o.RemoveStackTraces)
 	assert.True(c.Obfuscation.Redis.Enabled)
+	assert.Equal([]*RedisCommandRule{
+		{Names: []string{"GET", "SET"}, KeepArgs: 1},
+		{Names: []string{"AUTH"}, CommandOnly: true},
+	}, c.Obfuscation.Redis.Commands)
 	assert.True(c.Obfuscation.Memcached.Enabled)
+	assert.Equal([]ObfuscationBypass{
+		{Service: "billing-db", Obfuscators: []string{"sql"}},
+		{Service: "internal-cache", Obfuscators: []string{"*"}},
+	}, o.Bypass)
+	no := false
+	assert.Equal([]ObfuscationOverride{
+		{Service: "reporting-db", SQLQuantizeLiterals: &no},
+		{Service: "search", ESEnabled: &no, HTTPRemoveQueryString: &no},
+	}, o.ServiceOverrides)
+	assert.Equal([]*ScrubRule{
+		{
+			Name:    "session-token",
+			Pattern: "(session=)[a-z0-9]+",
+			Re:      regexp.MustCompile("(session=)[a-z0-9]+"),
+			Repl:    "${1}?",
+			Tags:    []string{"http.*", "error.msg"},
+		},
+	}, o.ScrubRules)
+
+	assert.Equal(&MetaLimitConfig{
+		MaxEntries:  64,
+		Policy:      "drop",
+		KeyPriority: []string{"http.*", "db.statement"},
+	}, c.MetaLimit)
+
+	assert.Equal(&ArchiveConfig{
+		Enabled:            true,
+		Bucket:             "traces",
+		Prefix:             "archive",
+		Endpoint:           "https://storage.googleapis.com",
+		ForcePathStyle:     true,
+		FlushPeriodSeconds: 60,
+		MaxBatchBytes:      1048576,
+	}, c.Archive)
 }
 
 func TestUndocumentedYamlConfig(t *testing.T) {
@@ -262,6 +302,18 @@ func TestUndocumentedYamlConfig(t *testing.T) {
 	assert.Equal(0.05, c.AnalyzedSpansByService["db"]["intake"])
 }
 
+func TestNetwork(t *testing.T) {
+	for family, want := range map[string][3]string{
+		"":     {"tcp", "udp", "unix"},
+		"ipv4": {"tcp4", "udp4", "unix"},
+		"ipv6": {"tcp6", "udp6", "unix"},
+	} {
+		c := New()
+		c.AddressFamily = family
+		assert.Equal(t, want, [3]string{c.Network("tcp"), c.Network("udp"), c.Network("unix")}, family)
+	}
+}
+
 func TestAcquireHostname(t *testing.T) {
 	c := New()
 	err := c.acquireHostname()
@@ -9,6 +9,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/cihub/seelog"
@@ -186,6 +187,471 @@ func TestLoadEnv(t *testing.T) {
 		assert.Equal("0.0.0.0", cfg.ReceiverHost)
 	})
 
+	env = "DD_APM_RECEIVER_AUTH_TOKEN"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "s3cr3t")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal("s3cr3t", cfg.ReceiverAuthToken)
+	})
+
+	env = "DD_APM_RECEIVER_REUSE_PORT"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "true")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.ReceiverReusePort)
+	})
+
+	env = "DD_APM_RECEIVER_HANDOFF"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "true")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.ReceiverHandoff)
+	})
+
+	env = "DD_APM_ADDRESS_FAMILY"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "IPv6")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal("ipv6", cfg.AddressFamily)
+		assert.Equal("::", cfg.ReceiverHost)
+	})
+
+	env = "DD_APM_RECEIVER_MAX_CONNECTIONS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "500")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(500, cfg.ReceiverMaxConnections)
+	})
+
+	for env, timeout := range map[string]func(*AgentConfig) time.Duration{
+		"DD_APM_RECEIVER_READ_TIMEOUT":  func(c *AgentConfig) time.Duration { return c.ReceiverReadTimeout },
+		"DD_APM_RECEIVER_WRITE_TIMEOUT": func(c *AgentConfig) time.Duration { return c.ReceiverWriteTimeout },
+		"DD_APM_RECEIVER_IDLE_TIMEOUT":  func(c *AgentConfig) time.Duration { return c.ReceiverIdleTimeout },
+	} {
+		t.Run(env, func(t *testing.T) {
+			defer cleanConfig()()
+			assert := assert.New(t)
+			err := os.Setenv(env, "30")
+			assert.NoError(err)
+			defer os.Unsetenv(env)
+			cfg, err := Load("./testdata/full.yaml")
+			assert.NoError(err)
+			assert.Equal(30*time.Second, timeout(cfg))
+		})
+	}
+
+	env = "DD_APM_RECEIVER_KEEP_ALIVES"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "false")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.ReceiverDisableKeepAlives)
+	})
+
+	env = "DD_APM_API_KEY_ALIASES"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, `{"team-a":{"api_key":"key-a"},"team-b":{"api_key":"key-b","dd_url":"https://trace.agent.datadoghq.eu"},"team-c":{}}`)
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(map[string]*Endpoint{
+			"team-a": {APIKey: "key-a", Host: cfg.Endpoints[0].Host},
+			"team-b": {APIKey: "key-b", Host: "https://trace.agent.datadoghq.eu"},
+		}, cfg.APIKeyAliases)
+	})
+
+	env = "DD_APM_ACCEPT_TRACER_API_KEYS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "true")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.AcceptTracerAPIKeys)
+	})
+
+	env = "DD_APM_RECEIVER_HARDENED"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "true")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.ReceiverHardened)
+	})
+
+	env = "DD_APM_RECEIVER_MAX_HEADER_BYTES"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "4096")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(4096, cfg.ReceiverMaxHeaderBytes)
+	})
+
+	env = "DD_APM_INJECT_CONTAINER_RUNTIME_ID"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "true")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.InjectContainerRuntimeID)
+	})
+
+	env = "DD_APM_ERROR_FINGERPRINTING"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "true")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.ErrorFingerprinting)
+	})
+
+	env = "DD_APM_LANGUAGE_QUIRKS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "namespace_separator")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal([]string{"namespace_separator"}, cfg.LanguageQuirks)
+	})
+
+	env = "DD_APM_CLIENT_COMPUTED_STATS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "true")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.ClientComputedStats)
+	})
+
+	env = "DD_APM_XRAY_UDP_PORT"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "2000")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(2000, cfg.XRayUDPPort)
+	})
+
+	env = "DD_APM_JAEGER_UDP_PORT"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "6831")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(6831, cfg.JaegerUDPPort)
+	})
+
+	env = "DD_APM_RECEIVER_GRPC_PORT"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "8127")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(8127, cfg.ReceiverGRPCPort)
+	})
+
+	env = "DD_APM_RECEIVER_TLS_CERT_FILE"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		for env, value := range map[string]string{
+			"DD_APM_RECEIVER_TLS_CERT_FILE":      "/etc/datadog-agent/receiver.crt",
+			"DD_APM_RECEIVER_TLS_KEY_FILE":       "/etc/datadog-agent/receiver.key",
+			"DD_APM_RECEIVER_TLS_CLIENT_CA_FILE": "/etc/datadog-agent/clients.crt",
+		} {
+			err := os.Setenv(env, value)
+			assert.NoError(err)
+			defer os.Unsetenv(env)
+		}
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal("/etc/datadog-agent/receiver.crt", cfg.ReceiverTLSCertFile)
+		assert.Equal("/etc/datadog-agent/receiver.key", cfg.ReceiverTLSKeyFile)
+		assert.Equal("/etc/datadog-agent/clients.crt", cfg.ReceiverTLSClientCAFile)
+	})
+
+	env = "DD_APM_MIN_TRACER_VERSIONS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, `{"python": "0.40.0", "Java": "0.70.0"}`)
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(map[string]string{"python": "0.40.0", "java": "0.70.0"}, cfg.MinTracerVersions)
+	})
+
+	env = "DD_APM_REJECT_OUTDATED_TRACERS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "true")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.RejectOutdatedTracers)
+	})
+
+	env = "DD_APM_FINE_STATS_SERVICES"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "web-store checkout")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		err = os.Setenv("DD_APM_FINE_STATS_BUCKET_SIZE_MS", "2000")
+		assert.NoError(err)
+		defer os.Unsetenv("DD_APM_FINE_STATS_BUCKET_SIZE_MS")
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal([]string{"web-store", "checkout"}, cfg.FineStatsServices)
+		assert.Equal(2*time.Second, cfg.FineStatsBucketInterval)
+		assert.Equal(1000, cfg.FineStatsMaxGrains)
+	})
+
+	env = "DD_APM_STATS_BY_VERSION"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "false")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		err = os.Setenv("DD_APM_STATS_MAX_VERSIONS_PER_SERVICE", "3")
+		assert.NoError(err)
+		defer os.Unsetenv("DD_APM_STATS_MAX_VERSIONS_PER_SERVICE")
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.False(cfg.StatsByVersion)
+		assert.Equal(3, cfg.StatsMaxVersionsPerService)
+	})
+
+	env = "DD_APM_SAMPLING_MODE"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "trace_id_hash")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		err = os.Setenv("DD_APM_TRACE_ID_HASH_SAMPLE_RATE", "0.25")
+		assert.NoError(err)
+		defer os.Unsetenv("DD_APM_TRACE_ID_HASH_SAMPLE_RATE")
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(SamplingModeTraceIDHash, cfg.SamplingMode)
+		assert.Equal(0.25, cfg.TraceIDHashSampleRate)
+	})
+
+	env = "DD_APM_EXCEPTION_SAMPLER_ENABLED"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		for k, v := range map[string]string{
+			env:                                     "false",
+			"DD_APM_EXCEPTION_SAMPLER_TTL":          "300",
+			"DD_APM_EXCEPTION_SAMPLER_PRIORITY_TTL": "900",
+			"DD_APM_EXCEPTION_SAMPLER_CARDINALITY_LIMIT": "200",
+			"DD_APM_EXCEPTION_SAMPLER_SIGNATURE":         "resource customer.tier",
+		} {
+			assert.NoError(os.Setenv(k, v))
+			defer os.Unsetenv(k)
+		}
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.False(cfg.ExceptionSamplerEnabled)
+		assert.Equal(5*time.Minute, cfg.ExceptionSamplerTTL)
+		assert.Equal(15*time.Minute, cfg.ExceptionSamplerPriorityTTL)
+		assert.Equal(200, cfg.ExceptionSamplerCardinalityLimit)
+		assert.Equal([]string{"resource", "customer.tier"}, cfg.ExceptionSamplerSignature)
+	})
+
+	env = "DD_APM_OBFUSCATION_ELASTICSEARCH_KEEP_VALUES"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		for k, v := range map[string]string{
+			env:                                      "took hits.total",
+			"DD_APM_OBFUSCATION_MONGODB_ENABLED":     "false",
+			"DD_APM_OBFUSCATION_MONGODB_KEEP_VALUES": "document_id",
+		} {
+			assert.NoError(os.Setenv(k, v))
+			defer os.Unsetenv(k)
+		}
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.Obfuscation.ES.Enabled)
+		assert.Equal([]string{"took", "hits.total"}, cfg.Obfuscation.ES.KeepValues)
+		assert.False(cfg.Obfuscation.Mongo.Enabled)
+		assert.Equal([]string{"document_id"}, cfg.Obfuscation.Mongo.KeepValues)
+	})
+
+	env = "DD_APM_OBFUSCATION_ELASTICSEARCH_ENABLED"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		assert.NoError(os.Setenv(env, "true"))
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/no_apm_config.yaml")
+		assert.NoError(err)
+		assert.True(cfg.Obfuscation.ES.Enabled)
+		assert.Empty(cfg.Obfuscation.ES.KeepValues)
+		assert.False(cfg.Obfuscation.Mongo.Enabled)
+	})
+
+	env = "DD_APM_OBFUSCATION_PII_ENABLED"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		assert.NoError(os.Setenv(env, "true"))
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.Obfuscation.PII.Enabled)
+		assert.True(cfg.Obfuscation.ES.Enabled)
+	})
+
+	env = "DD_APM_OTLP_PIPELINES_LOGS_PORT"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		assert.NoError(os.Setenv(env, "10518"))
+		defer os.Unsetenv(env)
+		assert.NoError(os.Setenv("DD_APM_OTLP_PIPELINES_METRICS", "true"))
+		defer os.Unsetenv("DD_APM_OTLP_PIPELINES_METRICS")
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(10518, cfg.OTLPLogsPipelinePort)
+		assert.True(cfg.OTLPMetricsPipeline)
+	})
+
+	env = "DD_APM_TRACE_REASSEMBLY_WINDOW_MS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		assert.NoError(os.Setenv(env, "250"))
+		defer os.Unsetenv(env)
+		assert.NoError(os.Setenv("DD_APM_TRACE_REASSEMBLY_MAX_SPANS", "5000"))
+		defer os.Unsetenv("DD_APM_TRACE_REASSEMBLY_MAX_SPANS")
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(250*time.Millisecond, cfg.ReassemblyWindow)
+		assert.Equal(5000, cfg.ReassemblyMaxSpans)
+	})
+
+	env = "DD_APM_OBFUSCATION_SQL_KEEP_COMMENTS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		assert.NoError(os.Setenv(env, "false"))
+		defer os.Unsetenv(env)
+		assert.NoError(os.Setenv("DD_APM_OBFUSCATION_SQL_TABLE_NAMES", "false"))
+		defer os.Unsetenv("DD_APM_OBFUSCATION_SQL_TABLE_NAMES")
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.False(cfg.Obfuscation.SQL.KeepComments)
+		assert.False(cfg.Obfuscation.SQL.TableNames)
+	})
+
+	env = "DD_APM_ACCESS_LOG_PATH"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "/var/log/datadog/trace-agent-access.log")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal("/var/log/datadog/trace-agent-access.log", cfg.AccessLogPath)
+		assert.Equal(1.0, cfg.AccessLogSampleRate)
+	})
+
+	env = "DD_APM_ACCESS_LOG_SAMPLE_RATE"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "0.25")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(0.25, cfg.AccessLogSampleRate)
+	})
+
+	env = "DD_APM_ACCESS_LOG_MAX_SIZE"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "1Mb")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.EqualValues(1024*1024, cfg.AccessLogMaxSize)
+	})
+
 	for _, envKey := range []string{
 		"DD_IGNORE_RESOURCE", // deprecated
 		"DD_APM_IGNORE_RESOURCES",
@@ -253,6 +719,38 @@ func TestLoadEnv(t *testing.T) {
 		assert.Contains(cfg.ReplaceTags, rule2)
 	})
 
+	env = "DD_APM_SAMPLING_RULES"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, `[{"service":"billing","resource":"^POST /charge","sample_rate":1}, {"name":"db.query","sample_rate":0.1}]`)
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		rules := []*SamplingRule{
+			{Service: "billing", Resource: "^POST /charge", SampleRate: 1},
+			{Name: "db.query", SampleRate: 0.1},
+		}
+		assert.NoError(compileSamplingRules(rules))
+		assert.Equal(rules, cfg.SamplingRules)
+	})
+
+	env = "DD_APM_SPAN_SAMPLING_RULES"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, `[{"service":"billing","name":"charge","sample_rate":1,"max_per_second":50}, {"name":"db.query","sample_rate":0.1}]`)
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal([]*SpanSamplingRule{
+			{Service: "billing", Name: "charge", SampleRate: 1, MaxPerSecond: 50},
+			{Name: "db.query", SampleRate: 0.1},
+		}, cfg.SpanSamplingRules)
+	})
+
 	for _, envKey := range []string{
 		"DD_CONNECTION_LIMIT", // deprecated
 		"DD_APM_CONNECTION_LIMIT",
@@ -285,6 +783,42 @@ func TestLoadEnv(t *testing.T) {
 		})
 	}
 
+	env = "DD_APM_MAX_ERROR_TPS_PER_SERVICE"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "2.5")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(2.5, cfg.MaxErrorTPSPerService)
+	})
+
+	env = "DD_APM_TARGET_TPS"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "50")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(50., cfg.TargetTPS)
+	})
+
+	env = "DD_APM_PRIORITY_SAMPLING_BY_RESOURCE"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "true")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.True(cfg.PrioritySamplingByResource)
+	})
+
 	for _, envKey := range []string{
 		"DD_MAX_EPS", // deprecated
 		"DD_APM_MAX_EPS",
@@ -5,7 +5,10 @@
 
 package flags
 
-import "flag"
+import (
+	"flag"
+	"time"
+)
 
 var (
 	// ConfigPath specifies the path to the configuration file.
@@ -41,6 +44,21 @@ var Win = struct {
 	Foreground       bool
 }{}
 
+// LoadGen holds the flags of the synthetic load generator. When URL is set, the agent sends
+// synthetic traces to the receiver at this URL and exits, instead of starting.
+var LoadGen = struct {
+	URL              string
+	Rate             float64
+	Duration         time.Duration
+	Concurrency      int
+	TracesPerPayload int
+	Services         int
+	MaxDepth         int
+	ErrorRate        float64
+	MetaSize         int
+	PID              int
+}{}
+
 func init() {
 	flag.StringVar(&ConfigPath, "config", DefaultConfigPath, "Datadog Agent config file location")
 	flag.StringVar(&PIDFilePath, "pid", "", "Path to set pidfile for process")
@@ -51,5 +69,17 @@ func init() {
 	flag.StringVar(&CPUProfile, "cpuprofile", "", "Write cpu profile to file")
 	flag.StringVar(&MemProfile, "memprofile", "", "Write memory profile to `file`")
 
+	// synthetic load generator
+	flag.StringVar(&LoadGen.URL, "loadgen", "", "Send synthetic traces to the receiver at this URL (e.g. http://localhost:8126) and exit")
+	flag.Float64Var(&LoadGen.Rate, "loadgen-rate", 100, "Number of traces sent per second by the load generator")
+	flag.DurationVar(&LoadGen.Duration, "loadgen-duration", time.Minute, "Duration of the load generation")
+	flag.IntVar(&LoadGen.Concurrency, "loadgen-concurrency", 4, "Maximum number of payloads sent at the same time by the load generator")
+	flag.IntVar(&LoadGen.TracesPerPayload, "loadgen-traces-per-payload", 10, "Number of traces of each payload sent by the load generator")
+	flag.IntVar(&LoadGen.Services, "loadgen-services", 5, "Number of distinct services of the generated spans")
+	flag.IntVar(&LoadGen.MaxDepth, "loadgen-depth", 4, "Maximum depth of the generated traces")
+	flag.Float64Var(&LoadGen.ErrorRate, "loadgen-error-rate", 0.05, "Proportion of generated spans flagged as errors")
+	flag.IntVar(&LoadGen.MetaSize, "loadgen-meta-size", 0, "Size in bytes of an additional tag of each generated span")
+	flag.IntVar(&LoadGen.PID, "loadgen-pid", 0, "PID of the trace-agent to report the resource usage of (not supported on Windows)")
+
 	registerOSSpecificFlags()
 }
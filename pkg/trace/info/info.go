@@ -39,7 +39,9 @@ var (
 	prioritySamplerInfo SamplerInfo
 	errorsSamplerInfo   SamplerInfo
 	rateByService       map[string]float64
+	samplersDump        func() interface{}
 	rateLimiterStats    RateLimiterStats
+	statsLag            map[string]float64
 	start               = time.Now()
 	once                sync.Once
 	infoTmpl            *template.Template
@@ -66,7 +68,7 @@ const (
   --- Receiver stats (1 min) ---
 
   {{ range $i, $ts := .Status.Receiver }}
-  From {{if $ts.Tags.Lang}}{{ $ts.Tags.Lang }} {{ $ts.Tags.LangVersion }} ({{ $ts.Tags.Interpreter }}), client {{ $ts.Tags.TracerVersion }}{{else}}unknown clients{{end}}
+  From {{if $ts.Tags.Lang}}{{ $ts.Tags.Lang }} {{ $ts.Tags.LangVersion }} ({{ $ts.Tags.Interpreter }}), client {{ $ts.Tags.TracerVersion }}{{else}}unknown clients{{end}}{{if $ts.Tags.Transport}} over {{ $ts.Tags.Transport }}{{end}}
     Traces received: {{ $ts.Stats.TracesReceived }} ({{ $ts.Stats.TracesBytes }} bytes)
     Spans received: {{ $ts.Stats.SpansReceived }}
     {{ with $ts.WarnString }}
@@ -79,7 +81,8 @@ const (
   {{ end }}
   {{if lt .Status.RateLimiter.TargetRate 1.0}}
   WARNING: Rate-limiter keep percentage: {{percent .Status.RateLimiter.TargetRate}} %
-  {{end}}
+  {{ range $origin, $dropped := .Status.RateLimiter.DroppedByOrigin }}Rate-limiter dropped {{ $dropped }} traces from {{if $origin}}{{ $origin }}{{else}}unknown origins{{end}}
+  {{ end }}{{end}}
 
   --- Writer stats (1 min) ---
 
@@ -87,6 +90,8 @@ const (
   {{if gt .Status.TraceWriter.Errors 0}}WARNING: Traces API errors (1 min): {{.Status.TraceWriter.Errors}}{{end}}
   Stats: {{.Status.StatsWriter.Payloads}} payloads, {{.Status.StatsWriter.StatsBuckets}} stats buckets, {{.Status.StatsWriter.Bytes}} bytes
   {{if gt .Status.StatsWriter.Errors 0}}WARNING: Stats API errors (1 min): {{.Status.StatsWriter.Errors}}{{end}}
+  {{ range $service, $lag := .Status.StatsLag }}Stats lag for '{{ $service }}': {{ printf "%.1f" $lag }} s
+  {{ end }}
 `
 
 	notRunningTmplSrc = `{{.Banner}}
@@ -218,6 +223,9 @@ type RateLimiterStats struct {
 	RecentTracesSeen float64
 	// RecentTracesDropped is the number of traces that were dropped.
 	RecentTracesDropped float64
+	// DroppedByOrigin is the number of traces that were dropped from each origin, which is
+	// the ID of the container sending them when known, or else the IP address of the client.
+	DroppedByOrigin map[string]int64 `json:",omitempty"`
 }
 
 // UpdateRateLimiter updates internal stats about the rate limiting.
@@ -233,14 +241,52 @@ func publishRateLimiterStats() interface{} {
 	return rateLimiterStats
 }
 
+// UpdateStatsLag updates the lag of the stats flushed last, by service: the time elapsed
+// between the end of the oldest span of the service and the flush of its stats, in seconds.
+func UpdateStatsLag(lag map[string]float64) {
+	infoMu.Lock()
+	defer infoMu.Unlock()
+	statsLag = lag
+}
+
+func publishStatsLag() interface{} {
+	infoMu.RLock()
+	defer infoMu.RUnlock()
+	return statsLag
+}
+
 func publishUptime() interface{} {
-	return int(time.Since(start) / time.Second)
+	return int(Uptime() / time.Second)
+}
+
+// Uptime returns the time elapsed since the agent started.
+func Uptime() time.Duration {
+	return time.Since(start)
 }
 
 type infoString string
 
 func (s infoString) String() string { return string(s) }
 
+// SetSamplersDump sets the function returning the detailed state of the samplers, published
+// as "samplers" and served by the /debug/samplers endpoint of the receiver.
+func SetSamplersDump(f func() interface{}) {
+	infoMu.Lock()
+	defer infoMu.Unlock()
+	samplersDump = f
+}
+
+// SamplersDump returns the detailed state of the samplers, or nil if it is unknown.
+func SamplersDump() interface{} {
+	infoMu.RLock()
+	f := samplersDump
+	infoMu.RUnlock()
+	if f == nil {
+		return nil
+	}
+	return f()
+}
+
 // InitInfo initializes the info structure. It should be called only once.
 func InitInfo(conf *config.AgentConfig) error {
 	var err error
@@ -265,8 +311,10 @@ func InitInfo(conf *config.AgentConfig) error {
 		expvar.Publish("prioritysampler", expvar.Func(publishPrioritySamplerInfo))
 		expvar.Publish("errorssampler", expvar.Func(publishErrorsSamplerInfo))
 		expvar.Publish("ratebyservice", expvar.Func(publishRateByService))
+		expvar.Publish("samplers", expvar.Func(SamplersDump))
 		expvar.Publish("watchdog", expvar.Func(publishWatchdogInfo))
 		expvar.Publish("ratelimiter", expvar.Func(publishRateLimiterStats))
+		expvar.Publish("stats_lag", expvar.Func(publishStatsLag))
 
 		// copy the config to ensure we don't expose sensitive data such as API keys
 		c := *conf
@@ -324,6 +372,7 @@ type StatusInfo struct {
 	StatsWriter   StatsWriterInfo    `json:"stats_writer"`
 	Watchdog      watchdog.Info      `json:"watchdog"`
 	RateLimiter   RateLimiterStats   `json:"ratelimiter"`
+	StatsLag      map[string]float64 `json:"stats_lag"`
 	Config        config.AgentConfig `json:"config"`
 }
 
@@ -92,6 +92,45 @@ func (rs *ReceiverStats) Reset() {
 	rs.Unlock()
 }
 
+// TagStatsSummary is a point-in-time copy of the TagStats of a set of tags, suitable for
+// JSON encoding.
+type TagStatsSummary struct {
+	Lang            string `json:"lang"`
+	LangVersion     string `json:"lang_version,omitempty"`
+	LangVendor      string `json:"lang_vendor,omitempty"`
+	Interpreter     string `json:"interpreter,omitempty"`
+	TracerVersion   string `json:"tracer_version,omitempty"`
+	EndpointVersion string `json:"endpoint_version,omitempty"`
+	Transport       string `json:"transport,omitempty"`
+
+	TracesReceived  int64 `json:"traces_received"`
+	TracesFiltered  int64 `json:"traces_filtered"`
+	TracesBytes     int64 `json:"traces_bytes"`
+	SpansReceived   int64 `json:"spans_received"`
+	SpansDropped    int64 `json:"spans_dropped"`
+	PayloadAccepted int64 `json:"payload_accepted"`
+	PayloadRefused  int64 `json:"payload_refused"`
+	// TracesDropped and SpansMalformed hold the non-zero counts by reason.
+	TracesDropped  map[string]int64 `json:"traces_dropped"`
+	SpansMalformed map[string]int64 `json:"spans_malformed"`
+}
+
+// Summary returns the summaries of the stats of every set of tags, ordered by decreasing
+// amount of bytes received.
+func (rs *ReceiverStats) Summary() []TagStatsSummary {
+	rs.RLock()
+	summaries := make([]TagStatsSummary, 0, len(rs.Stats))
+	for _, ts := range rs.Stats {
+		summaries = append(summaries, ts.summary())
+	}
+	rs.RUnlock()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].TracesBytes > summaries[j].TracesBytes
+	})
+	return summaries
+}
+
 // LogStats logs one-line summaries of ReceiverStats. Problematic stats are logged as warnings.
 func (rs *ReceiverStats) LogStats() {
 	rs.RLock()
@@ -124,6 +163,27 @@ func newTagStats(tags Tags) *TagStats {
 	return &TagStats{tags, Stats{TracesDropped: &TracesDropped{}, SpansMalformed: &SpansMalformed{}}}
 }
 
+func (ts *TagStats) summary() TagStatsSummary {
+	return TagStatsSummary{
+		Lang:            ts.Lang,
+		LangVersion:     ts.LangVersion,
+		LangVendor:      ts.LangVendor,
+		Interpreter:     ts.Interpreter,
+		TracerVersion:   ts.TracerVersion,
+		EndpointVersion: ts.EndpointVersion,
+		Transport:       ts.Transport,
+		TracesReceived:  atomic.LoadInt64(&ts.TracesReceived),
+		TracesFiltered:  atomic.LoadInt64(&ts.TracesFiltered),
+		TracesBytes:     atomic.LoadInt64(&ts.TracesBytes),
+		SpansReceived:   atomic.LoadInt64(&ts.SpansReceived),
+		SpansDropped:    atomic.LoadInt64(&ts.SpansDropped),
+		PayloadAccepted: atomic.LoadInt64(&ts.PayloadAccepted),
+		PayloadRefused:  atomic.LoadInt64(&ts.PayloadRefused),
+		TracesDropped:   nonZero(ts.TracesDropped.tagValues()),
+		SpansMalformed:  nonZero(ts.SpansMalformed.tagValues()),
+	}
+}
+
 func (ts *TagStats) publish() {
 	// Atomically load the stats from ts
 	tracesReceived := atomic.LoadInt64(&ts.TracesReceived)
@@ -141,6 +201,10 @@ func (ts *TagStats) publish() {
 	eventsSampled := atomic.LoadInt64(&ts.EventsSampled)
 	requestsMade := atomic.LoadInt64(&ts.PayloadAccepted)
 	requestsRejected := atomic.LoadInt64(&ts.PayloadRefused)
+	tracesClientStats := atomic.LoadInt64(&ts.TracesClientComputedStats)
+	tracesManualKeep := atomic.LoadInt64(&ts.TracesManualKeep)
+	tracesManualDrop := atomic.LoadInt64(&ts.TracesManualDrop)
+	spansSingleSampled := atomic.LoadInt64(&ts.SpansSingleSampled)
 
 	// Publish the stats
 	tags := ts.Tags.toArray()
@@ -161,6 +225,10 @@ func (ts *TagStats) publish() {
 	metrics.Count("datadog.trace_agent.receiver.events_sampled", eventsSampled, tags, 1)
 	metrics.Count("datadog.trace_agent.receiver.payload_accepted", requestsMade, tags, 1)
 	metrics.Count("datadog.trace_agent.receiver.payload_refused", requestsRejected, tags, 1)
+	metrics.Count("datadog.trace_agent.receiver.traces_client_computed_stats", tracesClientStats, tags, 1)
+	metrics.Count("datadog.trace_agent.receiver.traces_manual", tracesManualKeep, append(tags, "decision:keep"), 1)
+	metrics.Count("datadog.trace_agent.receiver.traces_manual", tracesManualDrop, append(tags, "decision:drop"), 1)
+	metrics.Count("datadog.trace_agent.receiver.spans_single_sampled", spansSingleSampled, tags, 1)
 
 	for reason, count := range ts.TracesDropped.tagValues() {
 		metrics.Count("datadog.trace_agent.normalizer.traces_dropped", count, append(tags, "reason:"+reason), 1)
@@ -170,6 +238,16 @@ func (ts *TagStats) publish() {
 	}
 }
 
+// nonZero returns the entries of m which have a non-zero value.
+func nonZero(m map[string]int64) map[string]int64 {
+	for k, v := range m {
+		if v == 0 {
+			delete(m, k)
+		}
+	}
+	return m
+}
+
 // mapToString serializes the entries in this map into format "key1: value1, key2: value2, ...", sorted by
 // key to ensure consistent output order. Only non-zero values are included.
 func mapToString(m map[string]int64) string {
@@ -209,6 +287,13 @@ type TracesDropped struct {
 	// EOF is when an unexpected EOF is encountered, this can happen because the client has aborted
 	// or because a bad payload (i.e. shorter than claimed in Content-Length) was sent.
 	EOF int64
+	// OutdatedTracer is when a payload is rejected because its tracer is older than the minimum
+	// version configured for its language.
+	OutdatedTracer int64
+	// PreSampled is when a payload is refused by the rate limiter because the agent uses too
+	// much CPU or memory. The payloads setting the X-Datadog-Trace-Count header are refused
+	// before being decoded, and their traces counted from it.
+	PreSampled int64
 }
 
 // tagValues converts TracesDropped into a map representation with keys matching standardized names for all reasons
@@ -222,6 +307,8 @@ func (s *TracesDropped) tagValues() map[string]int64 {
 		"foreign_span":      atomic.LoadInt64(&s.ForeignSpan),
 		"timeout":           atomic.LoadInt64(&s.Timeout),
 		"unexpected_eof":    atomic.LoadInt64(&s.EOF),
+		"outdated_tracer":   atomic.LoadInt64(&s.OutdatedTracer),
+		"pre_sampled":       atomic.LoadInt64(&s.PreSampled),
 	}
 }
 
@@ -316,16 +403,32 @@ type Stats struct {
 	PayloadAccepted int64
 	// PayloadRefused counts the number of payloads that have been rejected by the rate limiter.
 	PayloadRefused int64
+	// TracesClientComputedStats is the number of traces of which the stats were computed by the
+	// tracer, and which were thus not passed to the concentrator.
+	TracesClientComputedStats int64
+	// TracesManualKeep is the number of traces of which the sampling priority was overridden to keep
+	// them because of a manual.keep tag set on one of their spans.
+	TracesManualKeep int64
+	// TracesManualDrop is the number of traces of which the sampling priority was overridden to drop
+	// them because of a manual.drop tag set on one of their spans.
+	TracesManualDrop int64
+	// SpansSingleSampled is the number of spans kept by single-span sampling out of dropped traces.
+	SpansSingleSampled int64
 }
 
 func (s *Stats) update(recent *Stats) {
 	atomic.AddInt64(&s.TracesReceived, atomic.LoadInt64(&recent.TracesReceived))
 
+	atomic.AddInt64(&s.TracesDropped.PayloadTooLarge, atomic.LoadInt64(&recent.TracesDropped.PayloadTooLarge))
 	atomic.AddInt64(&s.TracesDropped.DecodingError, atomic.LoadInt64(&recent.TracesDropped.DecodingError))
 	atomic.AddInt64(&s.TracesDropped.EmptyTrace, atomic.LoadInt64(&recent.TracesDropped.EmptyTrace))
 	atomic.AddInt64(&s.TracesDropped.TraceIDZero, atomic.LoadInt64(&recent.TracesDropped.TraceIDZero))
 	atomic.AddInt64(&s.TracesDropped.SpanIDZero, atomic.LoadInt64(&recent.TracesDropped.SpanIDZero))
 	atomic.AddInt64(&s.TracesDropped.ForeignSpan, atomic.LoadInt64(&recent.TracesDropped.ForeignSpan))
+	atomic.AddInt64(&s.TracesDropped.Timeout, atomic.LoadInt64(&recent.TracesDropped.Timeout))
+	atomic.AddInt64(&s.TracesDropped.EOF, atomic.LoadInt64(&recent.TracesDropped.EOF))
+	atomic.AddInt64(&s.TracesDropped.OutdatedTracer, atomic.LoadInt64(&recent.TracesDropped.OutdatedTracer))
+	atomic.AddInt64(&s.TracesDropped.PreSampled, atomic.LoadInt64(&recent.TracesDropped.PreSampled))
 	atomic.AddInt64(&s.SpansMalformed.DuplicateSpanID, atomic.LoadInt64(&recent.SpansMalformed.DuplicateSpanID))
 	atomic.AddInt64(&s.SpansMalformed.ServiceEmpty, atomic.LoadInt64(&recent.SpansMalformed.ServiceEmpty))
 	atomic.AddInt64(&s.SpansMalformed.ServiceTruncate, atomic.LoadInt64(&recent.SpansMalformed.ServiceTruncate))
@@ -353,6 +456,10 @@ func (s *Stats) update(recent *Stats) {
 	atomic.AddInt64(&s.EventsSampled, atomic.LoadInt64(&recent.EventsSampled))
 	atomic.AddInt64(&s.PayloadAccepted, atomic.LoadInt64(&recent.PayloadAccepted))
 	atomic.AddInt64(&s.PayloadRefused, atomic.LoadInt64(&recent.PayloadRefused))
+	atomic.AddInt64(&s.TracesClientComputedStats, atomic.LoadInt64(&recent.TracesClientComputedStats))
+	atomic.AddInt64(&s.TracesManualKeep, atomic.LoadInt64(&recent.TracesManualKeep))
+	atomic.AddInt64(&s.TracesManualDrop, atomic.LoadInt64(&recent.TracesManualDrop))
+	atomic.AddInt64(&s.SpansSingleSampled, atomic.LoadInt64(&recent.SpansSingleSampled))
 }
 
 func (s *Stats) reset() {
@@ -365,6 +472,8 @@ func (s *Stats) reset() {
 	atomic.StoreInt64(&s.TracesDropped.ForeignSpan, 0)
 	atomic.StoreInt64(&s.TracesDropped.Timeout, 0)
 	atomic.StoreInt64(&s.TracesDropped.EOF, 0)
+	atomic.StoreInt64(&s.TracesDropped.OutdatedTracer, 0)
+	atomic.StoreInt64(&s.TracesDropped.PreSampled, 0)
 	atomic.StoreInt64(&s.SpansMalformed.DuplicateSpanID, 0)
 	atomic.StoreInt64(&s.SpansMalformed.ServiceEmpty, 0)
 	atomic.StoreInt64(&s.SpansMalformed.ServiceTruncate, 0)
@@ -391,6 +500,10 @@ func (s *Stats) reset() {
 	atomic.StoreInt64(&s.EventsSampled, 0)
 	atomic.StoreInt64(&s.PayloadAccepted, 0)
 	atomic.StoreInt64(&s.PayloadRefused, 0)
+	atomic.StoreInt64(&s.TracesClientComputedStats, 0)
+	atomic.StoreInt64(&s.TracesManualKeep, 0)
+	atomic.StoreInt64(&s.TracesManualDrop, 0)
+	atomic.StoreInt64(&s.SpansSingleSampled, 0)
 }
 
 func (s *Stats) isEmpty() bool {
@@ -441,12 +554,14 @@ func (ts *TagStats) WarnString() string {
 type Tags struct {
 	Lang, LangVersion, LangVendor, Interpreter, TracerVersion string
 	EndpointVersion                                           string
+	// Transport is the network the payload was received on, such as "tcp", "unix" or "pipe".
+	Transport string
 }
 
 // toArray will transform the Tags struct into a slice of string.
 // We only publish the non-empty tags.
 func (t *Tags) toArray() []string {
-	tags := make([]string, 0, 5)
+	tags := make([]string, 0, 7)
 
 	if t.Lang != "" {
 		tags = append(tags, "lang:"+t.Lang)
@@ -466,6 +581,9 @@ func (t *Tags) toArray() []string {
 	if t.EndpointVersion != "" {
 		tags = append(tags, "endpoint_version:"+t.EndpointVersion)
 	}
+	if t.Transport != "" {
+		tags = append(tags, "transport:"+t.Transport)
+	}
 
 	return tags
 }
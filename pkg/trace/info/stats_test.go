@@ -29,6 +29,8 @@ func TestTracesDropped(t *testing.T) {
 			"span_id_zero":      1,
 			"timeout":           0,
 			"unexpected_eof":    0,
+			"outdated_tracer":   0,
+			"pre_sampled":       0,
 		}, s.tagValues())
 	})
 
@@ -76,6 +78,7 @@ func TestStatsTags(t *testing.T) {
 		Interpreter:     "goi",
 		TracerVersion:   "1.21.0",
 		EndpointVersion: "v0.4",
+		Transport:       "unix",
 	}).toArray(), []string{
 		"lang:go",
 		"lang_version:1.14",
@@ -83,5 +86,6 @@ func TestStatsTags(t *testing.T) {
 		"interpreter:goi",
 		"tracer_version:1.21.0",
 		"endpoint_version:v0.4",
+		"transport:unix",
 	})
 }
@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package loadgen
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+)
+
+// maxChildren is the maximum number of children of a generated span.
+const maxChildren = 3
+
+var (
+	operations = []string{"http.request", "grpc.server", "postgres.query", "redis.command", "kafka.produce"}
+	spanTypes  = []string{"web", "rpc", "sql", "cache", "queue"}
+	letters    = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+)
+
+// generator generates traces according to a Config. It is not safe for concurrent use.
+type generator struct {
+	cfg *Config
+	r   *rand.Rand
+}
+
+func newGenerator(cfg *Config, seed int64) *generator {
+	return &generator{cfg: cfg, r: rand.New(rand.NewSource(seed))}
+}
+
+// payload generates n traces.
+func (g *generator) payload(n int) pb.Traces {
+	traces := make(pb.Traces, 0, n)
+	for i := 0; i < n; i++ {
+		traces = append(traces, g.trace())
+	}
+	return traces
+}
+
+// trace generates a trace: a tree of at most MaxDepth levels, where children may belong to
+// another service than their parent.
+func (g *generator) trace() pb.Trace {
+	now := time.Now().UnixNano()
+	duration := int64(time.Millisecond) + g.r.Int63n(int64(time.Second))
+	service := g.r.Intn(g.cfg.Services)
+	root := g.span(service, 0, now-duration, duration)
+	root.TraceID = root.SpanID
+	root.Metrics[sampler.KeySamplingPriority] = float64(sampler.PriorityAutoKeep)
+
+	trace := pb.Trace{root}
+	g.children(&trace, root, service, 1)
+	return trace
+}
+
+// children appends the descendants of parent, a span of the given service, to trace.
+func (g *generator) children(trace *pb.Trace, parent *pb.Span, parentService, depth int) {
+	if depth >= g.cfg.MaxDepth {
+		return
+	}
+	n := g.r.Intn(maxChildren + 1)
+	for i := 0; i < n; i++ {
+		service := parentService
+		if g.r.Intn(2) == 0 {
+			service = g.r.Intn(g.cfg.Services)
+		}
+		duration := 1 + g.r.Int63n(parent.Duration)
+		start := parent.Start + g.r.Int63n(parent.Duration-duration+1)
+		child := g.span(service, depth, start, duration)
+		child.TraceID = parent.TraceID
+		child.ParentID = parent.SpanID
+		*trace = append(*trace, child)
+		g.children(trace, child, service, depth+1)
+	}
+}
+
+// span generates a span of the given service, at the given depth of its trace.
+func (g *generator) span(service, depth int, start, duration int64) *pb.Span {
+	op := depth % len(operations)
+	s := &pb.Span{
+		Service:  fmt.Sprintf("loadgen-service-%d", service),
+		Name:     operations[op],
+		Resource: fmt.Sprintf("resource-%d", g.r.Intn(20)),
+		Type:     spanTypes[op],
+		SpanID:   uint64(g.r.Int63()),
+		Start:    start,
+		Duration: duration,
+		Meta:     map[string]string{"env": "loadgen"},
+		Metrics:  make(map[string]float64),
+	}
+	if g.cfg.ErrorRate > 0 && g.r.Float64() < g.cfg.ErrorRate {
+		s.Error = 1
+		s.Meta["error.msg"] = "synthetic error"
+	}
+	if g.cfg.MetaSize > 0 {
+		s.Meta["loadgen.payload"] = g.randomString(g.cfg.MetaSize)
+	}
+	return s
+}
+
+func (g *generator) randomString(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[g.r.Intn(len(letters))]
+	}
+	return string(b)
+}
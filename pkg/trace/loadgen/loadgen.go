@@ -0,0 +1,224 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// Package loadgen implements a synthetic load generator sending traces to a trace-agent
+// receiver, which is useful to size an agent before rolling it out.
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config configures the load generator.
+type Config struct {
+	// URL is the base URL of the receiver, such as http://localhost:8126.
+	URL string
+	// Rate is the number of traces sent per second.
+	Rate float64
+	// Duration is for how long traces are sent.
+	Duration time.Duration
+	// Concurrency is the number of payloads which can be sent at the same time.
+	Concurrency int
+	// TracesPerPayload is the number of traces of each payload.
+	TracesPerPayload int
+	// Services is the number of distinct services of the generated spans.
+	Services int
+	// MaxDepth is the maximum depth of the generated traces.
+	MaxDepth int
+	// ErrorRate is the proportion of spans flagged as errors.
+	ErrorRate float64
+	// MetaSize is the size, in bytes, of an additional tag of each span, used to control
+	// the size of the payloads.
+	MetaSize int
+	// PID is the process ID of the agent, whose resource usage is reported when not 0.
+	PID int32
+}
+
+func (cfg *Config) validate() error {
+	switch {
+	case cfg.URL == "":
+		return errors.New("no receiver URL")
+	case cfg.Rate <= 0:
+		return errors.New("rate must be positive")
+	case cfg.Duration <= 0:
+		return errors.New("duration must be positive")
+	case cfg.Concurrency <= 0:
+		return errors.New("concurrency must be positive")
+	case cfg.TracesPerPayload <= 0:
+		return errors.New("traces per payload must be positive")
+	case cfg.Services <= 0:
+		return errors.New("number of services must be positive")
+	case cfg.MaxDepth <= 0:
+		return errors.New("max depth must be positive")
+	case cfg.ErrorRate < 0 || cfg.ErrorRate > 1:
+		return errors.New("error rate must be between 0 and 1")
+	case cfg.MetaSize < 0:
+		return errors.New("meta size can not be negative")
+	}
+	return nil
+}
+
+// Report holds the results of a run of the load generator.
+type Report struct {
+	// Elapsed is the duration of the run.
+	Elapsed time.Duration
+	// Payloads, Traces, Spans and Bytes count what was accepted by the receiver.
+	Payloads int64
+	Traces   int64
+	Spans    int64
+	Bytes    int64
+	// Errors counts the payloads which could not be sent or were rejected.
+	Errors int64
+	// Latency is the average time taken by the receiver to reply.
+	Latency time.Duration
+
+	// CPU is the average CPU usage of the agent, in percent of a core.
+	CPU float64
+	// MaxRSS is the highest resident memory of the agent seen, in bytes.
+	MaxRSS uint64
+}
+
+// WriteTo writes a human readable version of the report to w.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	secs := r.Elapsed.Seconds()
+	if secs == 0 {
+		secs = 1
+	}
+	fmt.Fprintf(&b, "Elapsed:    %s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(&b, "Payloads:   %d (%.1f/s), %d errors\n", r.Payloads, float64(r.Payloads)/secs, r.Errors)
+	fmt.Fprintf(&b, "Traces:     %d (%.1f/s)\n", r.Traces, float64(r.Traces)/secs)
+	fmt.Fprintf(&b, "Spans:      %d (%.1f/s)\n", r.Spans, float64(r.Spans)/secs)
+	fmt.Fprintf(&b, "Bytes:      %d (%.1f KB/s)\n", r.Bytes, float64(r.Bytes)/secs/1024)
+	fmt.Fprintf(&b, "Latency:    %s\n", r.Latency.Round(time.Microsecond))
+	if r.MaxRSS > 0 {
+		fmt.Fprintf(&b, "Agent CPU:  %.1f%%\n", r.CPU)
+		fmt.Fprintf(&b, "Agent RSS:  %.1f MB (max)\n", float64(r.MaxRSS)/1024/1024)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Run sends traces to the receiver as configured by cfg, until the configured duration is
+// elapsed or ctx is done, and reports the achieved throughput.
+func Run(ctx context.Context, cfg *Config) (*Report, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	var mon *monitor
+	if cfg.PID != 0 {
+		var err error
+		if mon, err = newMonitor(cfg.PID); err != nil {
+			return nil, fmt.Errorf("can not monitor agent process %d: %v", cfg.PID, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	var (
+		report  Report
+		latency int64
+		wg      sync.WaitGroup
+		client  = &http.Client{Timeout: 10 * time.Second}
+		url     = strings.TrimSuffix(cfg.URL, "/") + "/v0.4/traces"
+		ticks   = make(chan struct{})
+	)
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			g := newGenerator(cfg, seed)
+			for range ticks {
+				traces := g.payload(cfg.TracesPerPayload)
+				body, err := traces.MarshalMsg(nil)
+				if err != nil {
+					atomic.AddInt64(&report.Errors, 1)
+					continue
+				}
+				start := time.Now()
+				if err := send(client, url, body, len(traces)); err != nil {
+					atomic.AddInt64(&report.Errors, 1)
+					continue
+				}
+				atomic.AddInt64(&latency, int64(time.Since(start)))
+				atomic.AddInt64(&report.Payloads, 1)
+				atomic.AddInt64(&report.Traces, int64(len(traces)))
+				atomic.AddInt64(&report.Bytes, int64(len(body)))
+				for _, t := range traces {
+					atomic.AddInt64(&report.Spans, int64(len(t)))
+				}
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+
+	interval := time.Duration(float64(time.Second) * float64(cfg.TracesPerPayload) / cfg.Rate)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	monTicker := time.NewTicker(time.Second)
+	defer monTicker.Stop()
+
+	start := time.Now()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-monTicker.C:
+			if mon != nil {
+				mon.sample()
+			}
+		case <-ticker.C:
+			select {
+			case ticks <- struct{}{}:
+			default:
+				// all senders are busy, the receiver can't keep up with the configured rate
+			}
+		}
+	}
+	close(ticks)
+	wg.Wait()
+
+	report.Elapsed = time.Since(start)
+	if report.Payloads > 0 {
+		report.Latency = time.Duration(latency / report.Payloads)
+	}
+	if mon != nil {
+		report.CPU, report.MaxRSS = mon.usage()
+	}
+	return &report, nil
+}
+
+// send posts a payload of n traces to the receiver.
+func send(client *http.Client, url string, body []byte, n int) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("X-Datadog-Trace-Count", strconv.Itoa(n))
+	req.Header.Set("Datadog-Meta-Lang", "loadgen")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body) //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("receiver replied with status %d", resp.StatusCode)
+	}
+	return nil
+}
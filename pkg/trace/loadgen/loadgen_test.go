@@ -0,0 +1,116 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfig(url string) *Config {
+	return &Config{
+		URL:              url,
+		Rate:             200,
+		Duration:         500 * time.Millisecond,
+		Concurrency:      2,
+		TracesPerPayload: 10,
+		Services:         3,
+		MaxDepth:         3,
+		ErrorRate:        0.5,
+		MetaSize:         16,
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	cfg := testConfig("")
+	g := newGenerator(cfg, 1)
+	var spans, errors int
+	for _, trace := range g.payload(100) {
+		root := trace[0]
+		assert.EqualValues(t, 0, root.ParentID)
+		ids := map[uint64]int{root.SpanID: 0}
+		for _, s := range trace {
+			spans++
+			errors += int(s.Error)
+			assert.Equal(t, root.TraceID, s.TraceID)
+			assert.Len(t, s.Meta["loadgen.payload"], 16)
+			if s == root {
+				continue
+			}
+			depth, ok := ids[s.ParentID]
+			assert.True(t, ok, "parent of span should be in the trace")
+			assert.True(t, depth+1 < cfg.MaxDepth, "trace is too deep")
+			assert.True(t, s.Start >= root.Start && s.Start+s.Duration <= root.Start+root.Duration)
+			ids[s.SpanID] = depth + 1
+		}
+	}
+	assert.InDelta(t, 0.5, float64(errors)/float64(spans), 0.1)
+}
+
+func TestRun(t *testing.T) {
+	var traces int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/v0.4/traces", req.URL.Path)
+		body, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+		var payload pb.Traces
+		_, err = payload.UnmarshalMsg(body)
+		assert.NoError(t, err)
+		assert.Equal(t, req.Header.Get("X-Datadog-Trace-Count"), "10")
+		atomic.AddInt64(&traces, int64(len(payload)))
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(srv.URL)
+	if runtime.GOOS != "windows" {
+		cfg.PID = int32(os.Getpid())
+	}
+	report, err := Run(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.Zero(t, report.Errors)
+	assert.True(t, report.Traces > 0)
+	assert.Equal(t, atomic.LoadInt64(&traces), report.Traces)
+	assert.Equal(t, report.Traces, report.Payloads*10)
+	assert.True(t, report.Spans >= report.Traces)
+	if runtime.GOOS == "windows" {
+		return
+	}
+	assert.True(t, report.MaxRSS > 0)
+
+	var buf bytes.Buffer
+	_, err = report.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(buf.String(), "Agent RSS:"))
+}
+
+func TestRunErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}))
+	defer srv.Close()
+
+	report, err := Run(context.Background(), testConfig(srv.URL))
+	assert.NoError(t, err)
+	assert.Zero(t, report.Payloads)
+	assert.True(t, report.Errors > 0)
+
+	cfg := testConfig(srv.URL)
+	cfg.ErrorRate = 2
+	_, err = Run(context.Background(), cfg)
+	assert.Error(t, err)
+}
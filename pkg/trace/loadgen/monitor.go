@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// +build !windows
+
+package loadgen
+
+import (
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// monitor measures the resource usage of a process.
+type monitor struct {
+	p      *process.Process
+	start  time.Time
+	cpu    float64 // CPU time at start, in seconds
+	maxRSS uint64
+}
+
+func newMonitor(pid int32) (*monitor, error) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+	times, err := p.Times()
+	if err != nil {
+		return nil, err
+	}
+	m := &monitor{p: p, start: time.Now(), cpu: times.User + times.System}
+	m.sample()
+	return m, nil
+}
+
+// sample records the memory usage of the process.
+func (m *monitor) sample() {
+	if mem, err := m.p.MemoryInfo(); err == nil && mem.RSS > m.maxRSS {
+		m.maxRSS = mem.RSS
+	}
+}
+
+// usage returns the average CPU usage of the process since the monitor was created, in percent
+// of a core, and the highest resident memory seen.
+func (m *monitor) usage() (float64, uint64) {
+	m.sample()
+	times, err := m.p.Times()
+	if err != nil {
+		return 0, m.maxRSS
+	}
+	elapsed := time.Since(m.start).Seconds()
+	if elapsed == 0 {
+		return 0, m.maxRSS
+	}
+	return (times.User + times.System - m.cpu) / elapsed * 100, m.maxRSS
+}
@@ -0,0 +1,20 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package loadgen
+
+import "errors"
+
+// monitor would measure the resource usage of a process, which is not supported on Windows,
+// as gopsutil/process can't be imported there.
+type monitor struct{}
+
+func newMonitor(pid int32) (*monitor, error) {
+	return nil, errors.New("monitoring a process is not supported on Windows")
+}
+
+func (m *monitor) sample() {}
+
+func (m *monitor) usage() (float64, uint64) { return 0, 0 }
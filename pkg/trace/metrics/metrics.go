@@ -10,7 +10,8 @@
 package metrics
 
 import (
-	"fmt"
+	"net"
+	"strconv"
 
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-go/statsd"
@@ -18,7 +19,8 @@ import (
 
 // Configure creates a statsd client for the given agent's configuration, using the specified global tags.
 func Configure(conf *config.AgentConfig, tags []string) error {
-	client, err := statsd.New(fmt.Sprintf("%s:%d", conf.StatsdHost, conf.StatsdPort), statsd.WithTags(tags))
+	addr := net.JoinHostPort(conf.StatsdHost, strconv.Itoa(conf.StatsdPort))
+	client, err := statsd.New(addr, statsd.WithTags(tags))
 	if err != nil {
 		return err
 	}
@@ -58,10 +58,21 @@ func (p *jsonObfuscator) setKey() {
 	p.wiped = false
 }
 
+// reset clears the state left by the previous input, which may have been truncated in the
+// middle of a value that was kept.
+func (p *jsonObfuscator) reset() {
+	p.scan.reset()
+	p.closures = p.closures[:0]
+	p.key = false
+	p.wiped = false
+	p.keeping = false
+	p.keepDepth = 0
+}
+
 func (p *jsonObfuscator) obfuscate(data []byte) (string, error) {
 	var out strings.Builder
 	buf := make([]byte, 0, 10) // recording key token
-	p.scan.reset()
+	p.reset()
 	for _, c := range data {
 		p.scan.bytes++
 		op := p.scan.step(p.scan, c)
@@ -98,6 +98,20 @@ func TestObfuscateJSON(t *testing.T) {
 	}
 }
 
+func TestObfuscateJSONReuse(t *testing.T) {
+	assert := assert.New(t)
+	o := newJSONObfuscator(&config.JSONObfuscationConfig{KeepValues: []string{"highlight"}})
+
+	// the input is truncated in the middle of a value which is kept
+	out, err := o.obfuscate([]byte(`{"highlight": {"fields": "title`))
+	assert.Error(err)
+	assert.Equal(`{"highlight":{"fields":"title...`, out)
+
+	out, err = o.obfuscate([]byte(`{"query": {"match": {"user": "kimchy"}}}`))
+	assert.NoError(err)
+	assert.Equal(`{"query":{"match":{"user":"?"}}}`, out)
+}
+
 func BenchmarkObfuscateJSON(b *testing.B) {
 	cfg := &config.JSONObfuscationConfig{KeepValues: []string{"highlight"}}
 	if len(jsonSuite) == 0 {
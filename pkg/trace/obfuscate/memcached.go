@@ -19,8 +19,33 @@ func (*Obfuscator) obfuscateMemcached(span *pb.Span) {
 	// All memcached commands end with new lines [1]. In the case of storage
 	// commands, key values follow after. Knowing this, all we have to do
 	// to obfuscate sensitive information is to remove everything that follows
-	// a new line. For non-storage commands, this will have no effect.
+	// a new line. For non-storage commands, this will have no effect, but
+	// the values passed as arguments, such as the delta of "incr", are
+	// replaced instead.
 	// [1]: https://github.com/memcached/memcached/blob/master/doc/protocol.txt
 	cmd := strings.SplitN(span.Meta[k], "\r\n", 2)[0]
-	span.Meta[k] = strings.TrimSpace(cmd)
+	span.Meta[k] = obfuscateMemcachedCmd(strings.Fields(cmd))
+}
+
+// obfuscateMemcachedCmd returns the command made of the given fields, keeping the command name,
+// the keys and the parameters of the command while replacing the values with "?".
+func obfuscateMemcachedCmd(fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	switch strings.ToLower(fields[0]) {
+	case "incr", "decr":
+		// incr <key> <value> [noreply]
+		if len(fields) > 2 {
+			fields[2] = "?"
+		}
+	case "ma":
+		// ma <key> <flag>*, where the D and J flags hold the delta and the initial value
+		for i := 2; i < len(fields); i++ {
+			if f := fields[i]; len(f) > 1 && (f[0] == 'D' || f[0] == 'J') {
+				fields[i] = f[:1] + "?"
+			}
+		}
+	}
+	return strings.Join(fields, " ")
 }
@@ -35,7 +35,31 @@ func TestObfuscateMemcached(t *testing.T) {
 		},
 		{
 			"decr mykey 5",
-			"decr mykey 5",
+			"decr mykey ?",
+		},
+		{
+			"INCR mykey 42 noreply",
+			"INCR mykey ? noreply",
+		},
+		{
+			"cas mykey 0 60 5 12\r\nvalue\r\n",
+			"cas mykey 0 60 5 12",
+		},
+		{
+			"ma mykey D10 J0 N60 q",
+			"ma mykey D? J? N60 q",
+		},
+		{
+			"get  key1 key2 ",
+			"get key1 key2",
+		},
+		{
+			"incr",
+			"incr",
+		},
+		{
+			"\r\nvalue",
+			"",
 		},
 	} {
 		span := pb.Span{
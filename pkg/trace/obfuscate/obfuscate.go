@@ -21,6 +21,11 @@ type Obfuscator struct {
 	opts  *config.ObfuscationConfig
 	es    *jsonObfuscator // nil if disabled
 	mongo *jsonObfuscator // nil if disabled
+	pii   *piiScanner     // nil if disabled
+	scrub *scrubber       // nil if there are no scrub rules
+	// redisRules holds the rules of the Redis commands whose arguments are obfuscated
+	// differently, keyed by upper-cased command name.
+	redisRules map[string]*config.RedisCommandRule
 	// sqlLiteralEscapes reports whether we should treat escape characters literally or as escape characters.
 	// A non-zero value means 'yes'. Different SQL engines behave in different ways and the tokenizer needs
 	// to be generic.
@@ -28,6 +33,12 @@ type Obfuscator struct {
 	sqlLiteralEscapes int32
 	// queryCache keeps a cache of already obfuscated queries.
 	queryCache *measuredCache
+	// sqlKeepLiterals reports whether the literals of SQL queries are kept instead of being
+	// replaced with "?".
+	sqlKeepLiterals bool
+	// services holds the obfuscators of the services overriding some of the settings, keyed
+	// by service name.
+	services map[string]*Obfuscator
 }
 
 // SetSQLLiteralEscapes sets whether or not escape characters should be treated literally by the SQL obfuscator.
@@ -59,15 +70,63 @@ func NewObfuscator(cfg *config.ObfuscationConfig) *Obfuscator {
 	if cfg.Mongo.Enabled {
 		o.mongo = newJSONObfuscator(&cfg.Mongo)
 	}
+	if cfg.PII.Enabled {
+		o.pii = newPIIScanner(&cfg.PII)
+	}
+	o.scrub = newScrubber(cfg.ScrubRules)
+	o.redisRules = newRedisRules(cfg.Redis.Commands)
+	for _, override := range cfg.ServiceOverrides {
+		if o.services == nil {
+			o.services = make(map[string]*Obfuscator, len(cfg.ServiceOverrides))
+		}
+		o.services[override.Service] = o.withOverride(override)
+	}
 	return &o
 }
 
+// withOverride returns a copy of o using the settings changed by override.
+func (o *Obfuscator) withOverride(override config.ObfuscationOverride) *Obfuscator {
+	opts := *o.opts
+	opts.ServiceOverrides = nil
+	so := Obfuscator{
+		opts:       &opts,
+		es:         o.es,
+		mongo:      o.mongo,
+		pii:        o.pii,
+		scrub:      o.scrub,
+		redisRules: o.redisRules,
+		queryCache: o.queryCache,
+	}
+	if override.SQLQuantizeLiterals != nil && !*override.SQLQuantizeLiterals {
+		so.sqlKeepLiterals = true
+		// the shared cache holds queries with their literals replaced
+		so.queryCache = &measuredCache{}
+	}
+	if override.ESEnabled != nil {
+		opts.ES.Enabled = *override.ESEnabled
+		so.es = nil
+		if opts.ES.Enabled {
+			so.es = o.es
+			if so.es == nil {
+				so.es = newJSONObfuscator(&opts.ES)
+			}
+		}
+	}
+	if override.HTTPRemoveQueryString != nil {
+		opts.HTTP.RemoveQueryString = *override.HTTPRemoveQueryString
+	}
+	return &so
+}
+
 // Stop cleans up after a finished Obfuscator.
 func (o *Obfuscator) Stop() { o.queryCache.Close() }
 
 // Obfuscate may obfuscate span's properties based on its type and on the Obfuscator's
-// configuration.
+// configuration, as overridden for the span's service.
 func (o *Obfuscator) Obfuscate(span *pb.Span) {
+	if so, ok := o.services[span.Service]; ok {
+		o = so
+	}
 	switch span.Type {
 	case "sql", "cassandra":
 		o.obfuscateSQL(span)
@@ -87,6 +146,34 @@ func (o *Obfuscator) Obfuscate(span *pb.Span) {
 	case "elasticsearch":
 		o.obfuscateJSON(span, "elasticsearch.body", o.es)
 	}
+	o.Scrub(span)
+}
+
+// Scrub masks the personal data found in the meta values of span and applies the scrub rules
+// to them, as Obfuscate does. The spans bypassing the obfuscator of their type are still
+// scrubbed.
+func (o *Obfuscator) Scrub(span *pb.Span) {
+	if o.pii != nil {
+		o.pii.scrub(span)
+	}
+	if o.scrub != nil {
+		o.scrub.scrub(span)
+	}
+}
+
+// SpanObfuscator returns the name of the obfuscator which Obfuscate applies to spans
+// of the given type, or an empty string if there is none. These names are the ones
+// used when bypassing obfuscation for specific services.
+func SpanObfuscator(spanType string) string {
+	switch spanType {
+	case "sql", "cassandra":
+		return "sql"
+	case "web", "http":
+		return "http"
+	case "redis", "memcached", "mongodb", "elasticsearch":
+		return spanType
+	}
+	return ""
 }
 
 // compactWhitespaces compacts all whitespaces in t.
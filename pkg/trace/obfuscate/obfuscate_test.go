@@ -60,6 +60,41 @@ func TestNewObfuscator(t *testing.T) {
 	assert.NotNil(o.mongo)
 }
 
+func TestObfuscateServiceOverrides(t *testing.T) {
+	no, yes := false, true
+	o := NewObfuscator(&config.ObfuscationConfig{
+		ES:   config.JSONObfuscationConfig{Enabled: true},
+		HTTP: config.HTTPObfuscationConfig{RemoveQueryString: true},
+		ServiceOverrides: []config.ObfuscationOverride{
+			{Service: "reporting-db", SQLQuantizeLiterals: &no},
+			{Service: "search", ESEnabled: &no, HTTPRemoveQueryString: &no},
+			{Service: "audit", SQLQuantizeLiterals: &yes},
+		},
+	})
+	defer o.Stop()
+
+	for _, tt := range []struct {
+		service, typ, tag, in, out string
+	}{
+		{"reporting-db", "sql", "sql.query", "SELECT * FROM users WHERE name = 'O''Brien' AND id IN (1, 2) -- all", "SELECT * FROM users WHERE name = 'O''Brien' AND id IN ( 1, 2 )"},
+		{"reporting-db", "sql", "sql.query", "SELECT * FROM users WHERE name = ''", "SELECT * FROM users WHERE name = ''"},
+		{"audit", "sql", "sql.query", "SELECT * FROM users WHERE id IN (1, 2)", "SELECT * FROM users WHERE id IN ( ? )"},
+		{"web-store", "sql", "sql.query", "SELECT * FROM users WHERE name = 'O''Brien' AND id IN (1, 2) -- all", "SELECT * FROM users WHERE name = ? AND id IN ( ? )"},
+		{"search", "elasticsearch", "elasticsearch.body", `{"role": "database"}`, `{"role": "database"}`},
+		{"web-store", "elasticsearch", "elasticsearch.body", `{"role": "database"}`, `{"role":"?"}`},
+		{"search", "http", "http.url", "http://mysite.mydomain/search?q=asd", "http://mysite.mydomain/search?q=asd"},
+		{"web-store", "http", "http.url", "http://mysite.mydomain/search?q=asd", "http://mysite.mydomain/search?"},
+	} {
+		span := &pb.Span{Service: tt.service, Type: tt.typ, Resource: tt.in, Meta: map[string]string{tt.tag: tt.in}}
+		o.Obfuscate(span)
+		if tt.typ == "sql" {
+			assert.Equal(t, tt.out, span.Resource, "%s: %s", tt.service, tt.in)
+			continue
+		}
+		assert.Equal(t, tt.out, span.Meta[tt.tag], "%s: %s", tt.service, tt.in)
+	}
+}
+
 func TestCompactWhitespaces(t *testing.T) {
 	assert := assert.New(t)
 
@@ -136,7 +171,7 @@ func TestObfuscateConfig(t *testing.T) {
 		"redis.raw_command",
 		"SET key val",
 		"SET key ?",
-		&config.ObfuscationConfig{Redis: config.Enablable{Enabled: true}},
+		&config.ObfuscationConfig{Redis: config.RedisObfuscationConfig{Enabled: true}},
 	))
 
 	t.Run("redis/disabled", testConfig(
@@ -0,0 +1,171 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package obfuscate
+
+import (
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Kinds of personal data masked by the PII scanner.
+const (
+	piiCreditCard = "credit_card"
+	piiEmail      = "email"
+	piiIP         = "ip"
+)
+
+var (
+	// cardPattern matches 13 to 19 digits, optionally separated by spaces or dashes
+	cardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	// emailPattern matches email addresses
+	emailPattern = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9-]+(?:\.[a-zA-Z0-9-]+)*\.[a-zA-Z]{2,}`)
+	// ipv4Pattern matches the words holding four numbers separated by dots, which are
+	// validated as addresses
+	ipv4Pattern = regexp.MustCompile(`[\w.]*\d+\.\d+\.\d+\.\d+[\w.]*`)
+	// ipv6Pattern matches the words holding colons, which are validated as addresses
+	ipv6Pattern = regexp.MustCompile(`[\w.]*:[\w:.]*`)
+)
+
+// piiScanner masks the credit card numbers, email addresses and IP addresses found in the meta
+// values of spans with "?". Credit card numbers are validated with the Luhn algorithm to limit
+// false positives.
+type piiScanner struct {
+	cards, emails, ips bool
+	keep               map[string]bool // tags which are not scanned
+}
+
+func newPIIScanner(cfg *config.PIIObfuscationConfig) *piiScanner {
+	s := &piiScanner{keep: make(map[string]bool, len(cfg.KeepTags))}
+	for _, tag := range cfg.KeepTags {
+		s.keep[tag] = true
+	}
+	if len(cfg.Kinds) == 0 {
+		s.cards, s.emails, s.ips = true, true, true
+		return s
+	}
+	for _, kind := range cfg.Kinds {
+		switch kind {
+		case piiCreditCard:
+			s.cards = true
+		case piiEmail:
+			s.emails = true
+		case piiIP:
+			s.ips = true
+		default:
+			log.Warnf("Ignoring unknown kind of personal data %q in PII obfuscation", kind)
+		}
+	}
+	return s
+}
+
+// scrub masks the personal data found in the meta values of span.
+func (s *piiScanner) scrub(span *pb.Span) {
+	for k, v := range span.Meta {
+		if s.keep[k] || strings.HasPrefix(k, "_dd.") {
+			// internal tags are set by the tracers and the agent
+			continue
+		}
+		if out := s.scrubValue(v); out != v {
+			span.Meta[k] = out
+		}
+	}
+}
+
+// scrubValue returns v with the personal data it holds masked.
+func (s *piiScanner) scrubValue(v string) string {
+	if s.cards && countDigits(v) >= 13 {
+		v = maskMatches(cardPattern, v, isCreditCard)
+	}
+	if s.emails && strings.IndexByte(v, '@') >= 0 {
+		v = emailPattern.ReplaceAllLiteralString(v, "?")
+	}
+	if s.ips {
+		// IPv6 first, as they may embed an IPv4 address
+		if strings.Count(v, ":") >= 2 {
+			v = maskMatches(ipv6Pattern, v, isIPv6)
+		}
+		if strings.Count(v, ".") >= 3 {
+			v = maskMatches(ipv4Pattern, v, isIPv4)
+		}
+	}
+	return v
+}
+
+// maskMatches replaces the matches of re in v for which mask returns true with "?".
+func maskMatches(re *regexp.Regexp, v string, mask func(string) bool) string {
+	var (
+		out  strings.Builder
+		last int
+	)
+	for _, loc := range re.FindAllStringIndex(v, -1) {
+		if !mask(v[loc[0]:loc[1]]) {
+			continue
+		}
+		out.WriteString(v[last:loc[0]])
+		out.WriteString("?")
+		last = loc[1]
+	}
+	if last == 0 {
+		return v
+	}
+	out.WriteString(v[last:])
+	return out.String()
+}
+
+// isCreditCard reports whether s, made of digits optionally separated by spaces or dashes,
+// is a credit card number: it has the length and the prefix of the numbers of the major
+// networks, and a valid Luhn checksum.
+func isCreditCard(s string) bool {
+	digits := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i])
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 || digits[0] < '2' || digits[0] > '6' {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func isIPv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+}
+
+// isIPv6 reports whether s is an IPv6 address. Addresses without digits are not masked, as
+// they are more likely names such as "cafe::beef".
+func isIPv6(s string) bool {
+	return strings.Count(s, ":") >= 2 && countDigits(s) > 0 && net.ParseIP(s) != nil
+}
+
+func countDigits(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package obfuscate
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPIIScanner(t *testing.T) {
+	s := newPIIScanner(&config.PIIObfuscationConfig{})
+	for _, tt := range []struct {
+		in, out string
+	}{
+		// credit cards
+		{"card 4111 1111 1111 1111 declined", "card ? declined"},
+		{"4111-1111-1111-1111", "?"},
+		{"5500005555555559", "?"},
+		{"378282246310005", "?"},
+		{"4111111111111112", "4111111111111112"},         // bad checksum
+		{"1234567812345670", "1234567812345670"},         // unknown network
+		{"94111111111111111111", "94111111111111111111"}, // too long
+		{"id:4111111111111111abc", "id:4111111111111111abc"},
+		// emails
+		{"sent to john.doe+test@example.co.uk", "sent to ?"},
+		{"user@localhost", "user@localhost"},
+		// IPs
+		{"client 192.168.1.10 connected", "client ? connected"},
+		{"10.0.0.1:8080", "?:8080"},
+		{"version 1.2.3.4.5", "version 1.2.3.4.5"},
+		{"v1.2.3.4", "v1.2.3.4"},
+		{"999.1.1.1", "999.1.1.1"},
+		{"from fe80::1ff:fe23:4567:890a", "from ?"},
+		{"[2001:db8::1]:443", "[?]:443"},
+		{"::ffff:10.0.0.1", "?"},
+		{"Foo::Bar at 12:30:45", "Foo::Bar at 12:30:45"},
+		{"cafe::beef", "cafe::beef"},
+		{"nothing to see", "nothing to see"},
+	} {
+		assert.Equal(t, tt.out, s.scrubValue(tt.in), tt.in)
+	}
+}
+
+func TestPIIScannerConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := &config.ObfuscationConfig{PII: config.PIIObfuscationConfig{
+		Enabled:  true,
+		Kinds:    []string{"email", "unknown"},
+		KeepTags: []string{"usr.email"},
+	}}
+	span := &pb.Span{
+		Type: "web",
+		Meta: map[string]string{
+			"usr.email":      "john@example.com",
+			"error.msg":      "no account for john@example.com",
+			"peer.ipv4":      "10.0.0.1",
+			"_dd.p.reporter": "ops@example.com",
+		},
+	}
+	NewObfuscator(cfg).Obfuscate(span)
+	assert.Equal(map[string]string{
+		"usr.email":      "john@example.com",
+		"error.msg":      "no account for ?",
+		"peer.ipv4":      "10.0.0.1",
+		"_dd.p.reporter": "ops@example.com",
+	}, span.Meta)
+
+	// disabled by default
+	span.Meta["error.msg"] = "no account for john@example.com"
+	NewObfuscator(&config.ObfuscationConfig{}).Obfuscate(span)
+	assert.Equal("no account for john@example.com", span.Meta["error.msg"])
+}
+
+func BenchmarkPIIScanner(b *testing.B) {
+	s := newPIIScanner(&config.PIIObfuscationConfig{})
+	span := &pb.Span{Meta: map[string]string{
+		"http.url":    "http://example.com/users/1234?page=2",
+		"error.msg":   "payment of 4111 1111 1111 1111 for john@example.com failed",
+		"peer.ipv4":   "10.0.0.1",
+		"http.method": "GET",
+		"component":   "net/http",
+	}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, v := range span.Meta {
+			s.scrubValue(v)
+		}
+	}
+}
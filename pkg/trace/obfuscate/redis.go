@@ -8,6 +8,7 @@ package obfuscate
 import (
 	"strings"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 )
 
@@ -88,7 +89,7 @@ const redisRawCommand = "redis.raw_command"
 
 // obfuscateRedis obfuscates arguments inside the given span's "redis.raw_command" tag, if it exists
 // and is non-empty.
-func (*Obfuscator) obfuscateRedis(span *pb.Span) {
+func (o *Obfuscator) obfuscateRedis(span *pb.Span) {
 	if span.Meta == nil || span.Meta[redisRawCommand] == "" {
 		// nothing to do
 		return
@@ -106,7 +107,7 @@ func (*Obfuscator) obfuscateRedis(span *pb.Span) {
 			// new command starting
 			if cmd != "" {
 				// a previous command was buffered, obfuscate it
-				obfuscateRedisCmd(&str, cmd, args...)
+				o.obfuscateRedisCmd(&str, cmd, args...)
 				str.WriteByte('\n')
 			}
 			cmd = tok
@@ -116,13 +117,47 @@ func (*Obfuscator) obfuscateRedis(span *pb.Span) {
 		}
 		if done {
 			// last command
-			obfuscateRedisCmd(&str, cmd, args...)
+			o.obfuscateRedisCmd(&str, cmd, args...)
 			break
 		}
 	}
 	span.Meta[redisRawCommand] = str.String()
 }
 
+// newRedisRules returns the given Redis command rules keyed by upper-cased command name, or
+// nil if there are none.
+func newRedisRules(rules []*config.RedisCommandRule) map[string]*config.RedisCommandRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	m := make(map[string]*config.RedisCommandRule)
+	for _, r := range rules {
+		for _, name := range r.Names {
+			m[strings.ToUpper(name)] = r
+		}
+	}
+	return m
+}
+
+// obfuscateRedisCmd writes cmd to out along with its obfuscated args, as specified by the
+// rule of the command if there is one.
+func (o *Obfuscator) obfuscateRedisCmd(out *strings.Builder, cmd string, args ...string) {
+	r, ok := o.redisRules[strings.ToUpper(cmd)]
+	if !ok {
+		obfuscateRedisCmd(out, cmd, args...)
+		return
+	}
+	out.WriteString(cmd)
+	if r.CommandOnly || len(args) == 0 {
+		return
+	}
+	if len(args) > r.KeepArgs {
+		args = append(args[:r.KeepArgs], "?")
+	}
+	out.WriteByte(' ')
+	out.WriteString(strings.Join(args, " "))
+}
+
 func obfuscateRedisCmd(out *strings.Builder, cmd string, args ...string) {
 	out.WriteString(cmd)
 	if len(args) == 0 {
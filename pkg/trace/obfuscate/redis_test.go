@@ -11,6 +11,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/stretchr/testify/assert"
 )
@@ -377,6 +378,41 @@ SET k ?`,
 	}
 }
 
+func TestRedisObfuscatorCommandRules(t *testing.T) {
+	o := NewObfuscator(&config.ObfuscationConfig{
+		Redis: config.RedisObfuscationConfig{
+			Enabled: true,
+			Commands: []*config.RedisCommandRule{
+				{Names: []string{"get", "SET"}, KeepArgs: 1},
+				{Names: []string{"HSET"}, KeepArgs: 2},
+				{Names: []string{"AUTH", "MSET"}, CommandOnly: true},
+				{Names: []string{"LPUSH"}},
+			},
+		},
+	})
+	for ti, tt := range [...]struct {
+		in, out string
+	}{
+		{"GET key", "GET key"},
+		{"set key value EX 10", "set key ?"},
+		{"HSET key field value", "HSET key field ?"},
+		{"HSET key field", "HSET key field"},
+		{"AUTH user my-secret-password", "AUTH"},
+		{"MSET k1 v1 k2 v2", "MSET"},
+		{"LPUSH key a b c", "LPUSH ?"},
+		{"LPUSH", "LPUSH"},
+		// commands without rules are obfuscated as by default
+		{"APPEND key value", "APPEND key ?"},
+		{"SET k v\nAPPEND k v", "SET k ?\nAPPEND k ?"},
+	} {
+		t.Run(strconv.Itoa(ti), func(t *testing.T) {
+			span := redisSpan(tt.in)
+			o.obfuscateRedis(span)
+			assert.Equal(t, tt.out, span.Meta[redisRawCommand], tt.in)
+		})
+	}
+}
+
 func BenchmarkRedisObfuscator(b *testing.B) {
 	cmd := strings.Repeat("GEOADD key longitude latitude member longitude latitude member longitude latitude member\n", 5)
 	span := redisSpan(cmd)
@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package obfuscate
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// scrubber applies the user-defined scrub rules to the meta values of spans, in order.
+type scrubber struct {
+	rules []*config.ScrubRule
+}
+
+// newScrubber returns a scrubber applying the given rules, or nil if there are none. The
+// patterns of the rules are compiled when the configuration did not do it already, and the
+// rules which fail to compile are ignored.
+func newScrubber(rules []*config.ScrubRule) *scrubber {
+	s := &scrubber{rules: make([]*config.ScrubRule, 0, len(rules))}
+	for _, r := range rules {
+		if r.Re == nil {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				log.Errorf("Ignoring scrub rule %q: %v", r.Name, err)
+				continue
+			}
+			rc := *r
+			rc.Re = re
+			r = &rc
+		}
+		s.rules = append(s.rules, r)
+	}
+	if len(s.rules) == 0 {
+		return nil
+	}
+	return s
+}
+
+// scrub applies the rules to the meta values of span.
+func (s *scrubber) scrub(span *pb.Span) {
+	for k, v := range span.Meta {
+		out := v
+		for _, r := range s.rules {
+			if ruleAppliesTo(r, k) {
+				out = r.Re.ReplaceAllString(out, r.Repl)
+			}
+		}
+		if out != v {
+			span.Meta[k] = out
+		}
+	}
+}
+
+// ruleAppliesTo reports whether the rule r applies to the meta key k.
+func ruleAppliesTo(r *config.ScrubRule, k string) bool {
+	if len(r.Tags) == 0 {
+		// internal tags are set by the tracers and the agent
+		return !strings.HasPrefix(k, "_dd.")
+	}
+	for _, tag := range r.Tags {
+		if ok, _ := path.Match(tag, k); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package obfuscate
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrubRules(t *testing.T) {
+	assert := assert.New(t)
+	cfg := &config.ObfuscationConfig{ScrubRules: []*config.ScrubRule{
+		{Name: "session", Pattern: "(session=)[a-z0-9]+", Repl: "${1}?", Tags: []string{"http.*"}},
+		{Name: "account", Pattern: "acct-[0-9]+", Repl: "acct-?"},
+		{Name: "invalid", Pattern: "("},
+	}}
+	o := NewObfuscator(cfg)
+	span := &pb.Span{
+		Type: "custom",
+		Meta: map[string]string{
+			"http.url":       "/cart?session=abc123&acct-42",
+			"error.msg":      "session=abc123 for acct-42",
+			"_dd.p.account":  "acct-42",
+			"custom.account": "acct-42",
+		},
+	}
+	o.Obfuscate(span)
+	assert.Equal(map[string]string{
+		"http.url":       "/cart?session=?&acct-?",
+		"error.msg":      "session=abc123 for acct-?",
+		"_dd.p.account":  "acct-42",
+		"custom.account": "acct-?",
+	}, span.Meta)
+	assert.Len(o.scrub.rules, 2)
+
+	// no rules
+	assert.Nil(NewObfuscator(&config.ObfuscationConfig{}).scrub)
+}
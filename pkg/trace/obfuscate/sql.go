@@ -43,7 +43,11 @@ type tokenFilter interface {
 
 // discardFilter is a token filter which discards certain elements from a query, such as
 // comments and AS aliases by returning a nil buffer.
-type discardFilter struct{}
+type discardFilter struct {
+	// keepComments specifies that block comments ("/* ... */") are kept. Line comments are
+	// always discarded, as they would comment out the rest of the single line query.
+	keepComments bool
+}
 
 // Filter the given token so that a `nil` slice is returned if the token is in the token filtered list.
 func (f *discardFilter) Filter(token, lastToken TokenKind, buffer []byte) (TokenKind, []byte, error) {
@@ -75,7 +79,12 @@ func (f *discardFilter) Filter(token, lastToken TokenKind, buffer []byte) (Token
 	switch token {
 	case As:
 		return As, nil, nil
-	case Comment, ';':
+	case Comment:
+		if f.keepComments && bytes.HasPrefix(buffer, []byte("/*")) {
+			return token, buffer, nil
+		}
+		return FilteredGroupable, nil, nil
+	case ';':
 		return FilteredGroupable, nil, nil
 	default:
 		return token, buffer, nil
@@ -112,6 +121,26 @@ func (f *replaceFilter) Filter(token, lastToken TokenKind, buffer []byte) (token
 // Reset implements tokenFilter.
 func (f *replaceFilter) Reset() {}
 
+// literalFilter is a token filter used instead of the replaceFilter when literals are kept. It
+// restores the quotes which the tokenizer removes from strings.
+type literalFilter struct{}
+
+// Filter quotes the given token if it is a string.
+func (f *literalFilter) Filter(token, lastToken TokenKind, buffer []byte) (TokenKind, []byte, error) {
+	if token != String || bytes.Equal(buffer, []byte("''")) {
+		// empty strings are already quoted by the tokenizer
+		return token, buffer, nil
+	}
+	quoted := make([]byte, 0, len(buffer)+2)
+	quoted = append(quoted, '\'')
+	quoted = append(quoted, bytes.Replace(buffer, []byte("'"), []byte("''"), -1)...)
+	quoted = append(quoted, '\'')
+	return token, quoted, nil
+}
+
+// Reset implements tokenFilter.
+func (f *literalFilter) Reset() {}
+
 // groupingFilter is a token filter which groups together items replaced by the replaceFilter. It is meant
 // to run immediately after it.
 type groupingFilter struct {
@@ -177,14 +206,34 @@ func (o *Obfuscator) ObfuscateSQLString(in string) (*ObfuscatedQuery, error) {
 }
 
 func (o *Obfuscator) obfuscateSQLString(in string) (*ObfuscatedQuery, error) {
+	opts := sqlOptions{
+		keepLiterals: o.sqlKeepLiterals,
+		tableNames:   o.opts.SQL.TableNames || config.HasFeature("table_names"),
+	}
+	oq, err := o.obfuscateSQLWithOptions(in, opts)
+	if err != nil || !o.opts.SQL.KeepComments {
+		return oq, err
+	}
+	// the comments are kept apart from the query, so that the queries which only differ by
+	// their comments, such as the trace context added by sqlcommenter, share their resource
+	opts.keepComments = true
+	opts.tableNames = false
+	if commented, err := o.obfuscateSQLWithOptions(in, opts); err == nil && commented.Query != oq.Query {
+		oq.QueryWithComments = commented.Query
+	}
+	return oq, nil
+}
+
+// obfuscateSQLWithOptions obfuscates the SQL query in as configured by opts.
+func (o *Obfuscator) obfuscateSQLWithOptions(in string, opts sqlOptions) (*ObfuscatedQuery, error) {
 	lesc := o.SQLLiteralEscapes()
 	tok := NewSQLTokenizer(in, lesc)
-	out, err := attemptObfuscation(tok)
+	out, err := attemptObfuscation(tok, opts)
 	if err != nil && tok.SeenEscape() {
 		// If the tokenizer failed, but saw an escape character in the process,
 		// try again treating escapes differently
 		tok = NewSQLTokenizer(in, !lesc)
-		if out, err2 := attemptObfuscation(tok); err2 == nil {
+		if out, err2 := attemptObfuscation(tok, opts); err2 == nil {
 			// If the second attempt succeeded, change the default behavior so that
 			// on the next run we get it right in the first run.
 			o.SetSQLLiteralEscapes(!lesc)
@@ -254,26 +303,37 @@ func (f *tableFinderFilter) Reset() {
 type ObfuscatedQuery struct {
 	Query     string // the obfuscated SQL query
 	TablesCSV string // comma-separated list of tables that the query addresses
+
+	// QueryWithComments is the obfuscated SQL query with its block comments, when they are
+	// kept and the query has any.
+	QueryWithComments string
 }
 
 // Cost returns the number of bytes needed to store all the fields
 // of this ObfuscatedQuery.
 func (oq *ObfuscatedQuery) Cost() int64 {
-	return int64(len(oq.Query) + len(oq.TablesCSV))
+	return int64(len(oq.Query) + len(oq.TablesCSV) + len(oq.QueryWithComments))
+}
+
+// sqlOptions holds the settings of the obfuscation of a SQL query.
+type sqlOptions struct {
+	keepLiterals bool // literals are quantized but not replaced
+	keepComments bool // block comments are kept
+	tableNames   bool // the names of the tables are extracted
 }
 
 // attemptObfuscation attempts to obfuscate the SQL query loaded into the tokenizer, using the
-// given set of filters.
-func attemptObfuscation(tokenizer *SQLTokenizer) (*ObfuscatedQuery, error) {
+// set of filters matching opts.
+func attemptObfuscation(tokenizer *SQLTokenizer, opts sqlOptions) (*ObfuscatedQuery, error) {
 	var (
-		tableFinder    = &tableFinderFilter{}
-		useTableFinder = config.HasFeature("table_names")
-		out            = *bytes.NewBuffer(make([]byte, 0, len(tokenizer.buf)))
-		err            error
-		lastToken      TokenKind
-		discard        discardFilter
-		replace        replaceFilter
-		grouping       groupingFilter
+		tableFinder = &tableFinderFilter{}
+		out         = *bytes.NewBuffer(make([]byte, 0, len(tokenizer.buf)))
+		err         error
+		lastToken   TokenKind
+		discard     = discardFilter{keepComments: opts.keepComments}
+		replace     replaceFilter
+		grouping    groupingFilter
+		literal     literalFilter
 	)
 	// call Scan() function until tokens are available or if a LEX_ERROR is raised. After
 	// retrieving a token, send it to the tokenFilter chains so that the token is discarded
@@ -290,13 +350,19 @@ func attemptObfuscation(tokenizer *SQLTokenizer) (*ObfuscatedQuery, error) {
 		if token, buff, err = discard.Filter(token, lastToken, buff); err != nil {
 			return nil, err
 		}
-		if token, buff, err = replace.Filter(token, lastToken, buff); err != nil {
-			return nil, err
-		}
-		if token, buff, err = grouping.Filter(token, lastToken, buff); err != nil {
-			return nil, err
+		if opts.keepLiterals {
+			if token, buff, err = literal.Filter(token, lastToken, buff); err != nil {
+				return nil, err
+			}
+		} else {
+			if token, buff, err = replace.Filter(token, lastToken, buff); err != nil {
+				return nil, err
+			}
+			if token, buff, err = grouping.Filter(token, lastToken, buff); err != nil {
+				return nil, err
+			}
 		}
-		if useTableFinder {
+		if opts.tableNames {
 			if token, buff, err = tableFinder.Filter(token, lastToken, buff); err != nil {
 				return nil, err
 			}
@@ -356,5 +422,9 @@ func (o *Obfuscator) obfuscateSQL(span *pb.Span) {
 		// "sql.query" tag already set by user, do not change it.
 		return
 	}
+	if oq.QueryWithComments != "" {
+		traceutil.SetMeta(span, sqlQueryTag, oq.QueryWithComments)
+		return
+	}
 	traceutil.SetMeta(span, sqlQueryTag, oq.Query)
 }
@@ -11,9 +11,11 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/stretchr/testify/assert"
 )
@@ -68,6 +70,15 @@ func TestSQLTableNames(t *testing.T) {
 
 	})
 
+	t.Run("config", func(t *testing.T) {
+		span := &pb.Span{
+			Resource: "SELECT * FROM users JOIN orders ON users.id = orders.user_id",
+			Type:     "sql",
+		}
+		NewObfuscator(&config.ObfuscationConfig{SQL: config.SQLObfuscationConfig{TableNames: true}}).Obfuscate(span)
+		assert.Equal(t, "users,orders", span.Meta["sql.tables"])
+	})
+
 	t.Run("off", func(t *testing.T) {
 		span := &pb.Span{
 			Resource: "SELECT * FROM users WHERE id = 42",
@@ -78,6 +89,48 @@ func TestSQLTableNames(t *testing.T) {
 	})
 }
 
+func TestSQLKeepComments(t *testing.T) {
+	for _, tt := range []struct {
+		in, out string
+	}{
+		{
+			"SELECT * FROM users WHERE id = 42 /*traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'*/",
+			"SELECT * FROM users WHERE id = ? /*traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'*/",
+		},
+		{
+			"/* controller='index' */ UPDATE users SET name = 'x' WHERE id = 1",
+			"/* controller='index' */ UPDATE users SET name = ? WHERE id = ?",
+		},
+		{
+			// line comments would comment out the rest of the query
+			"SELECT 1 -- first\nFROM dual",
+			"SELECT ? FROM dual",
+		},
+	} {
+		o := NewObfuscator(&config.ObfuscationConfig{SQL: config.SQLObfuscationConfig{KeepComments: true}})
+		oq, err := o.ObfuscateSQLString(tt.in)
+		assert.NoError(t, err)
+		assert.NotContains(t, oq.Query, "/*")
+		if strings.Contains(tt.out, "/*") {
+			assert.Equal(t, tt.out, oq.QueryWithComments)
+		} else {
+			assert.Equal(t, tt.out, oq.Query)
+			assert.Empty(t, oq.QueryWithComments)
+		}
+
+		// the comments are only kept in the query tag, not in the resource
+		span := &pb.Span{Resource: tt.in, Type: "sql"}
+		o.Obfuscate(span)
+		assert.NotContains(t, span.Resource, "/*")
+		assert.Equal(t, tt.out, span.Meta["sql.query"])
+
+		// removed by default
+		oq, err = NewObfuscator(nil).ObfuscateSQLString(tt.in)
+		assert.NoError(t, err)
+		assert.NotContains(t, oq.Query, "/*")
+	}
+}
+
 func TestSQLResourceWithoutQuery(t *testing.T) {
 	assert := assert.New(t)
 	span := &pb.Span{
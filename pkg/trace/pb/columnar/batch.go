@@ -0,0 +1,177 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// Package columnar contains an experimental columnar representation of the spans, used by the
+// trace agent to hold the sampled spans between their processing and their writing. The
+// values of each field of the spans are stored contiguously, and the strings are
+// dictionary-encoded per batch: the services, operation names and tag keys repeated by all
+// the spans of a payload are stored once. The spans are converted back to their wire format
+// by the writers.
+//
+// Appending to a Batch is not safe for concurrent use. A complete Batch can be decoded
+// concurrently.
+package columnar
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// Batch holds traces in columnar form. The spans of all the traces are stored in the same
+// columns, in order, and each trace is delimited by the offset of its last span.
+type Batch struct {
+	dict  []string          // strings by index, the index 0 being the empty string
+	index map[string]uint32 // indexes of the strings of dict
+
+	traceEnds []uint32 // end offset of the spans of each trace
+
+	service, name, resource, typ []uint32 // indexes in dict
+	traceID, spanID, parentID    []uint64
+	start, duration              []int64
+	errors                       []int32
+
+	metaEnds             []uint32 // end offset of the meta entries of each span
+	metaKeys, metaValues []uint32 // indexes in dict
+
+	metricsEnds   []uint32 // end offset of the metrics of each span
+	metricsKeys   []uint32 // indexes in dict
+	metricsValues []float64
+
+	dictSize int // sum of the lengths of the strings of dict
+}
+
+// NewBatch returns an empty Batch.
+func NewBatch() *Batch {
+	return &Batch{
+		dict:  []string{""},
+		index: map[string]uint32{"": 0},
+	}
+}
+
+// str returns the index of s in the dictionary, adding it if needed.
+func (b *Batch) str(s string) uint32 {
+	if i, ok := b.index[s]; ok {
+		return i
+	}
+	i := uint32(len(b.dict))
+	b.dict = append(b.dict, s)
+	b.index[s] = i
+	b.dictSize += len(s)
+	return i
+}
+
+// Append appends the trace t to the batch. An empty trace can be appended, to keep the traces
+// of the batch aligned with another list, such as the chunks of a payload.
+func (b *Batch) Append(t pb.Trace) {
+	for _, s := range t {
+		b.service = append(b.service, b.str(s.Service))
+		b.name = append(b.name, b.str(s.Name))
+		b.resource = append(b.resource, b.str(s.Resource))
+		b.typ = append(b.typ, b.str(s.Type))
+		b.traceID = append(b.traceID, s.TraceID)
+		b.spanID = append(b.spanID, s.SpanID)
+		b.parentID = append(b.parentID, s.ParentID)
+		b.start = append(b.start, s.Start)
+		b.duration = append(b.duration, s.Duration)
+		b.errors = append(b.errors, s.Error)
+		for k, v := range s.Meta {
+			b.metaKeys = append(b.metaKeys, b.str(k))
+			b.metaValues = append(b.metaValues, b.str(v))
+		}
+		b.metaEnds = append(b.metaEnds, uint32(len(b.metaKeys)))
+		for k, v := range s.Metrics {
+			b.metricsKeys = append(b.metricsKeys, b.str(k))
+			b.metricsValues = append(b.metricsValues, v)
+		}
+		b.metricsEnds = append(b.metricsEnds, uint32(len(b.metricsKeys)))
+	}
+	b.traceEnds = append(b.traceEnds, uint32(len(b.service)))
+}
+
+// NumTraces returns the number of traces of the batch, empty ones included.
+func (b *Batch) NumTraces() int {
+	return len(b.traceEnds)
+}
+
+// NumSpans returns the number of spans of the batch.
+func (b *Batch) NumSpans() int {
+	return len(b.service)
+}
+
+// traceBounds returns the offsets of the first and past the last span of the trace i.
+func (b *Batch) traceBounds(i int) (uint32, uint32) {
+	var from uint32
+	if i > 0 {
+		from = b.traceEnds[i-1]
+	}
+	return from, b.traceEnds[i]
+}
+
+// TraceLen returns the number of spans of the trace i.
+func (b *Batch) TraceLen(i int) int {
+	from, to := b.traceBounds(i)
+	return int(to - from)
+}
+
+// Trace returns the spans of the trace i in their wire format, or nil if it is empty.
+func (b *Batch) Trace(i int) pb.Trace {
+	from, to := b.traceBounds(i)
+	if from == to {
+		return nil
+	}
+	// the spans of the trace are allocated at once for locality
+	spans := make([]pb.Span, to-from)
+	t := make(pb.Trace, to-from)
+	for j := range spans {
+		t[j] = b.span(from+uint32(j), &spans[j])
+	}
+	return t
+}
+
+// span decodes the span at offset i into s.
+func (b *Batch) span(i uint32, s *pb.Span) *pb.Span {
+	*s = pb.Span{
+		Service:  b.dict[b.service[i]],
+		Name:     b.dict[b.name[i]],
+		Resource: b.dict[b.resource[i]],
+		Type:     b.dict[b.typ[i]],
+		TraceID:  b.traceID[i],
+		SpanID:   b.spanID[i],
+		ParentID: b.parentID[i],
+		Start:    b.start[i],
+		Duration: b.duration[i],
+		Error:    b.errors[i],
+	}
+	var metaFrom, metricsFrom uint32
+	if i > 0 {
+		metaFrom, metricsFrom = b.metaEnds[i-1], b.metricsEnds[i-1]
+	}
+	if metaTo := b.metaEnds[i]; metaTo > metaFrom {
+		s.Meta = make(map[string]string, metaTo-metaFrom)
+		for j := metaFrom; j < metaTo; j++ {
+			s.Meta[b.dict[b.metaKeys[j]]] = b.dict[b.metaValues[j]]
+		}
+	}
+	if metricsTo := b.metricsEnds[i]; metricsTo > metricsFrom {
+		s.Metrics = make(map[string]float64, metricsTo-metricsFrom)
+		for j := metricsFrom; j < metricsTo; j++ {
+			s.Metrics[b.dict[b.metricsKeys[j]]] = b.metricsValues[j]
+		}
+	}
+	return s
+}
+
+// Size returns an estimation of the memory used by the batch, in bytes.
+func (b *Batch) Size() int {
+	const (
+		stringHeader = 16 // data pointer and length
+		indexEntry   = 2*stringHeader + 4
+	)
+	size := b.dictSize + len(b.dict)*indexEntry
+	size += 4 * (len(b.traceEnds) + 4*len(b.service) + len(b.errors))
+	size += 8 * (3*len(b.traceID) + 2*len(b.start))
+	size += 4 * (len(b.metaEnds) + len(b.metaKeys) + len(b.metaValues))
+	size += 4*(len(b.metricsEnds)+len(b.metricsKeys)) + 8*len(b.metricsValues)
+	return size
+}
@@ -0,0 +1,99 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package columnar
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// withoutEmptyMaps returns t with its empty meta and metrics set to nil, as they are decoded.
+func withoutEmptyMaps(t pb.Trace) pb.Trace {
+	for _, s := range t {
+		if len(s.Meta) == 0 {
+			s.Meta = nil
+		}
+		if len(s.Metrics) == 0 {
+			s.Metrics = nil
+		}
+	}
+	return t
+}
+
+func TestBatch(t *testing.T) {
+	assert := assert.New(t)
+
+	traces := []pb.Trace{
+		withoutEmptyMaps(testutil.RandomTrace(3, 10)),
+		nil,
+		{{
+			Service:  "web",
+			Name:     "http.request",
+			Resource: "GET /",
+			Type:     "web",
+			TraceID:  1,
+			SpanID:   1,
+			Start:    42,
+			Duration: 100,
+			Error:    1,
+			Meta:     map[string]string{"env": "prod", "http.method": "GET"},
+			Metrics:  map[string]float64{"_sampling_priority_v1": 1},
+		}, {
+			Service:  "web",
+			Name:     "db.query",
+			TraceID:  1,
+			SpanID:   2,
+			ParentID: 1,
+		}},
+		withoutEmptyMaps(testutil.RandomTrace(3, 10)),
+	}
+	b := NewBatch()
+	var spans int
+	for _, trace := range traces {
+		b.Append(trace)
+		spans += len(trace)
+	}
+
+	assert.Equal(len(traces), b.NumTraces())
+	assert.Equal(spans, b.NumSpans())
+	for i, trace := range traces {
+		assert.Equal(len(trace), b.TraceLen(i))
+		if len(trace) == 0 {
+			assert.Nil(b.Trace(i))
+			continue
+		}
+		assert.Equal(trace, b.Trace(i))
+	}
+}
+
+func TestBatchDictionary(t *testing.T) {
+	b := NewBatch()
+	span := testutil.GetTestSpan()
+	trace := make(pb.Trace, 100)
+	for i := range trace {
+		s := *span
+		s.SpanID = uint64(i + 1)
+		trace[i] = &s
+	}
+	b.Append(trace)
+	// the strings repeated by the spans are stored once
+	assert.Equal(t, 1+4+2*len(span.Meta)+len(span.Metrics), len(b.dict))
+	assert.True(t, b.Size() < trace.Msgsize(), b.Size())
+}
+
+func BenchmarkBatch(b *testing.B) {
+	trace := testutil.RandomTrace(5, 50)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch := NewBatch()
+		batch.Append(trace)
+		batch.Trace(0)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"unicode/utf8"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/pb/validate"
 	"github.com/tinylib/msgp/msgp"
 )
 
@@ -36,7 +37,7 @@ func parseStringBytes(bts []byte) (string, []byte, error) {
 	if utf8.Valid(i) {
 		return string(i), bts, nil
 	}
-	return repairUTF8(msgp.UnsafeString(i)), bts, nil
+	return validate.RepairUTF8(msgp.UnsafeString(i)), bts, nil
 }
 
 // parseFloat64Bytes parses a float64 even if the sent value is an int64 or an uint64;
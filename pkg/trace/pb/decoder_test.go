@@ -7,6 +7,7 @@ package pb
 
 import (
 	"bytes"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -116,6 +117,54 @@ func TestDecodeMsgDictionary(t *testing.T) {
 	})
 }
 
+func TestUnmarshalMsgDictionary(t *testing.T) {
+	b, err := vmsgp.Marshal(&data)
+	assert.NoError(t, err)
+
+	var traces Traces
+	if err := traces.UnmarshalMsgDictionary(b); err != nil {
+		t.Fatal(err)
+	}
+	span := traces[0][0]
+	assert.EqualValues(t, span, &Span{
+		Service:  "my-service",
+		Name:     "my-name",
+		Resource: "my-resource",
+		TraceID:  1,
+		SpanID:   2,
+		ParentID: 3,
+		Start:    123,
+		Duration: 456,
+		Error:    1,
+		Meta: map[string]string{
+			"baggage":                    "item",
+			"elasticsearch.version":      "7.0",
+			"_dd.sampling_rate_whatever": "value whatever",
+		},
+		Metrics: map[string]float64{"X": 1.2},
+		Type:    "sql",
+	})
+
+	t.Run("zero-copy", func(t *testing.T) {
+		// strings reference the payload, so altering it alters them
+		idx := bytes.Index(b, []byte("my-service"))
+		assert.NotEqual(t, -1, idx)
+		cp := CopyString(span.Service)
+		b[idx] = 'M'
+		assert.Equal(t, "My-service", span.Service)
+		assert.Equal(t, "my-service", cp)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		b, err := vmsgp.Marshal(&data)
+		assert.NoError(t, err)
+		for i := 0; i < len(b)-1; i++ {
+			var traces Traces
+			assert.Error(t, traces.UnmarshalMsgDictionary(b[:i]))
+		}
+	})
+}
+
 var benchOut Traces
 
 func BenchmarkDecodeMsgDictionary(b *testing.B) {
@@ -132,3 +181,39 @@ func BenchmarkDecodeMsgDictionary(b *testing.B) {
 		assert.NoError(b, benchOut.DecodeMsgDictionary(dc))
 	}
 }
+
+func BenchmarkUnmarshalMsgDictionary(b *testing.B) {
+	bb, err := vmsgp.Marshal(&data)
+	assert.NoError(b, err)
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(bb)))
+	for i := 0; i < b.N; i++ {
+		assert.NoError(b, benchOut.UnmarshalMsgDictionary(bb))
+	}
+}
+
+func TestDecode(t *testing.T) {
+	want := Traces{
+		{{Service: "A", Name: "op", Meta: map[string]string{"k": "v"}, Metrics: map[string]float64{"m": 1}}},
+		{{Service: "B"}, nil},
+		{{Service: "C", Name: "op\x99\xbf"}},
+	}
+	bts, err := want.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Traces
+	dc := NewMsgpReader(bytes.NewReader(bts))
+	defer FreeMsgpReader(dc)
+	if err := got.DecodeMsg(dc); err != nil {
+		t.Fatal(err)
+	}
+	want[2][0].Name = "op��"
+	assert.Equal(t, want, got)
+
+	// truncated payloads fail with an unexpected EOF
+	dc.Reset(bytes.NewReader(bts[:len(bts)-2]))
+	err = got.DecodeMsg(dc)
+	assert.Equal(t, io.ErrUnexpectedEOF, msgp.Cause(err))
+}
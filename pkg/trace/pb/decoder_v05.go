@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"unicode/utf8"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/pb/validate"
 	"github.com/philhofer/fwd"
 	"github.com/tinylib/msgp/msgp"
 )
@@ -24,6 +26,111 @@ func dictionaryString(dc *msgp.Reader, dict []string) (string, error) {
 	return dict[idx], nil
 }
 
+// dictionaryStringBytes reads an int from bts and returns the string
+// at that index from dict, along with the remaining bytes.
+func dictionaryStringBytes(bts []byte, dict []string) (string, []byte, error) {
+	ui, bts, err := msgp.ReadUint32Bytes(bts)
+	if err != nil {
+		return "", bts, err
+	}
+	idx := int(ui)
+	if idx >= len(dict) {
+		return "", bts, fmt.Errorf("dictionary index %d out of range", idx)
+	}
+	return dict[idx], bts, nil
+}
+
+// parseStringBytesZC reads the next string in the msgpack payload bts without
+// copying it. The returned string references the memory of bts, unless it contained
+// invalid UTF-8, in which case a repaired copy is returned.
+func parseStringBytesZC(bts []byte) (string, []byte, error) {
+	var (
+		err error
+		i   []byte
+	)
+	switch t := msgp.NextType(bts); t {
+	case msgp.BinType:
+		i, bts, err = msgp.ReadBytesZC(bts)
+	case msgp.StrType:
+		i, bts, err = msgp.ReadStringZC(bts)
+	default:
+		return "", bts, msgp.TypeError{Encoded: t, Method: msgp.StrType}
+	}
+	if err != nil {
+		return "", bts, err
+	}
+	if utf8.Valid(i) {
+		return msgp.UnsafeString(i), bts, nil
+	}
+	return validate.RepairUTF8(msgp.UnsafeString(i)), bts, nil
+}
+
+// UnmarshalMsgDictionary decodes a trace using the specification from the v0.5 endpoint,
+// reading from the complete payload bts.
+//
+// Unlike DecodeMsgDictionary, strings from the dictionary are not copied: all the spans
+// reference the memory of bts directly, and every occurrence of a dictionary entry shares
+// the same string. This means that bts acts as an arena which lives as long as any of the
+// decoded spans does: it must not be modified or reused (e.g. returned to a pool) after
+// this call. Callers keeping strings beyond the processing of the payload should copy them
+// (see CopyString), so that the whole payload is not retained in memory.
+//
+// For details, see the documentation for endpoint v0.5 in pkg/trace/api/version.go
+func (t *Traces) UnmarshalMsgDictionary(bts []byte) error {
+	var err error
+	if _, bts, err = msgp.ReadArrayHeaderBytes(bts); err != nil {
+		return err
+	}
+	// read dictionary
+	var sz uint32
+	if sz, bts, err = msgp.ReadArrayHeaderBytes(bts); err != nil {
+		return err
+	}
+	if uint64(sz) > uint64(len(bts)) {
+		// every dictionary entry needs at least one byte
+		return msgp.ErrShortBytes
+	}
+	dict := make([]string, sz)
+	for i := range dict {
+		var str string
+		str, bts, err = parseStringBytesZC(bts)
+		if err != nil {
+			return err
+		}
+		dict[i] = str
+	}
+	// read traces
+	sz, bts, err = msgp.ReadArrayHeaderBytes(bts)
+	if err != nil {
+		return err
+	}
+	if cap(*t) >= int(sz) {
+		*t = (*t)[:sz]
+	} else {
+		*t = make(Traces, sz)
+	}
+	for i := range *t {
+		sz, bts, err = msgp.ReadArrayHeaderBytes(bts)
+		if err != nil {
+			return err
+		}
+		if cap((*t)[i]) >= int(sz) {
+			(*t)[i] = (*t)[i][:sz]
+		} else {
+			(*t)[i] = make(Trace, sz)
+		}
+		for j := range (*t)[i] {
+			if (*t)[i][j] == nil {
+				(*t)[i][j] = new(Span)
+			}
+			if bts, err = (*t)[i][j].UnmarshalMsgDictionary(bts, dict); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // DecodeMsgDictionary decodes a trace using the specification from the v0.5 endpoint.
 // For details, see the documentation for endpoint v0.5 in pkg/trace/api/version.go
 func (t *Traces) DecodeMsgDictionary(dc *msgp.Reader) error {
@@ -191,6 +298,135 @@ func (z *Span) DecodeMsgDictionary(dc *msgp.Reader, dict []string) error {
 	return nil
 }
 
+// UnmarshalMsgDictionary decodes a span from the given payload bts, looking up strings
+// in the given dictionary dict, and returns the remaining bytes. The same memory
+// considerations as for (*Traces).UnmarshalMsgDictionary apply.
+func (z *Span) UnmarshalMsgDictionary(bts []byte, dict []string) ([]byte, error) {
+	sz, bts, err := msgp.ReadArrayHeaderBytes(bts)
+	if err != nil {
+		return bts, err
+	}
+	if sz != spanPropertyCount {
+		return bts, errors.New("encoded span needs exactly 12 elements in array")
+	}
+	// Service (0)
+	z.Service, bts, err = dictionaryStringBytes(bts, dict)
+	if err != nil {
+		return bts, err
+	}
+	// Name (1)
+	z.Name, bts, err = dictionaryStringBytes(bts, dict)
+	if err != nil {
+		return bts, err
+	}
+	// Resource (2)
+	z.Resource, bts, err = dictionaryStringBytes(bts, dict)
+	if err != nil {
+		return bts, err
+	}
+	// TraceID (3)
+	z.TraceID, bts, err = parseUint64Bytes(bts)
+	if err != nil {
+		return bts, err
+	}
+	// SpanID (4)
+	z.SpanID, bts, err = parseUint64Bytes(bts)
+	if err != nil {
+		return bts, err
+	}
+	// ParentID (5)
+	z.ParentID, bts, err = parseUint64Bytes(bts)
+	if err != nil {
+		return bts, err
+	}
+	// Start (6)
+	z.Start, bts, err = parseInt64Bytes(bts)
+	if err != nil {
+		return bts, err
+	}
+	// Duration (7)
+	z.Duration, bts, err = parseInt64Bytes(bts)
+	if err != nil {
+		return bts, err
+	}
+	// Error (8)
+	z.Error, bts, err = parseInt32Bytes(bts)
+	if err != nil {
+		return bts, err
+	}
+	// Meta (9)
+	sz, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		return bts, err
+	}
+	if z.Meta == nil && sz > 0 {
+		z.Meta = make(map[string]string, sz)
+	} else if len(z.Meta) > 0 {
+		for key := range z.Meta {
+			delete(z.Meta, key)
+		}
+	}
+	for sz > 0 {
+		sz--
+		var key, val string
+		key, bts, err = dictionaryStringBytes(bts, dict)
+		if err != nil {
+			return bts, err
+		}
+		val, bts, err = dictionaryStringBytes(bts, dict)
+		if err != nil {
+			return bts, err
+		}
+		z.Meta[key] = val
+	}
+	// Metrics (10)
+	sz, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		return bts, err
+	}
+	if z.Metrics == nil && sz > 0 {
+		z.Metrics = make(map[string]float64, sz)
+	} else if len(z.Metrics) > 0 {
+		for key := range z.Metrics {
+			delete(z.Metrics, key)
+		}
+	}
+	for sz > 0 {
+		sz--
+		var (
+			key string
+			val float64
+		)
+		key, bts, err = dictionaryStringBytes(bts, dict)
+		if err != nil {
+			return bts, err
+		}
+		val, bts, err = parseFloat64Bytes(bts)
+		if err != nil {
+			return bts, err
+		}
+		z.Metrics[key] = val
+	}
+	// Type (11)
+	z.Type, bts, err = dictionaryStringBytes(bts, dict)
+	if err != nil {
+		return bts, err
+	}
+	return bts, nil
+}
+
+// CopyString returns a copy of s which does not share its memory. It should be used
+// to retain strings obtained from payloads decoded without copying (such as via
+// UnmarshalMsgDictionary) beyond the lifetime of the payload.
+func CopyString(s string) string {
+	if s == "" {
+		return ""
+	}
+	b := make([]byte, len(s))
+	copy(b, s)
+	return msgp.UnsafeString(b)
+}
+
 var readerPool = sync.Pool{New: func() interface{} { return &msgp.Reader{} }}
 
 // NewMsgpReader returns a *msgp.Reader that
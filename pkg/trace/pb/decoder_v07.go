@@ -0,0 +1,185 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package pb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// stringTable holds the strings of a v0.7 payload being decoded, in the order in which
+// they were first sent. The empty string is always at index 0.
+type stringTable []string
+
+// readString reads a string reference from dc. A string is added to the table and returned,
+// while an integer refers to the string at that index of the table.
+func (t *stringTable) readString(dc *msgp.Reader) (string, error) {
+	typ, err := dc.NextType()
+	if err != nil {
+		return "", err
+	}
+	if typ == msgp.StrType || typ == msgp.BinType {
+		s, err := parseString(dc)
+		if err != nil {
+			return "", err
+		}
+		*t = append(*t, s)
+		return s, nil
+	}
+	ui, err := dc.ReadUint32()
+	if err != nil {
+		return "", err
+	}
+	idx := int(ui)
+	if idx >= len(*t) {
+		return "", fmt.Errorf("string table index %d out of range", idx)
+	}
+	return (*t)[idx], nil
+}
+
+// DecodeMsgStringTable decodes traces using the specification from the v0.7 endpoint.
+// For details, see the documentation for endpoint v0.7 in pkg/trace/api/version.go
+func (t *Traces) DecodeMsgStringTable(dc *msgp.Reader) error {
+	sz, err := dc.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	if cap(*t) >= int(sz) {
+		*t = (*t)[:sz]
+	} else {
+		*t = make(Traces, sz)
+	}
+	table := stringTable{""}
+	for i := range *t {
+		sz, err := dc.ReadArrayHeader()
+		if err != nil {
+			return err
+		}
+		if cap((*t)[i]) >= int(sz) {
+			(*t)[i] = (*t)[i][:sz]
+		} else {
+			(*t)[i] = make(Trace, sz)
+		}
+		for j := range (*t)[i] {
+			if (*t)[i][j] == nil {
+				(*t)[i][j] = new(Span)
+			}
+			if err := (*t)[i][j].decodeMsgStringTable(dc, &table); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeMsgStringTable decodes a span from dc, resolving its strings using table
+func (z *Span) decodeMsgStringTable(dc *msgp.Reader, table *stringTable) error {
+	sz, err := dc.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	if sz != spanPropertyCount {
+		return errors.New("encoded span needs exactly 12 elements in array")
+	}
+	// Service (0)
+	z.Service, err = table.readString(dc)
+	if err != nil {
+		return err
+	}
+	// Name (1)
+	z.Name, err = table.readString(dc)
+	if err != nil {
+		return err
+	}
+	// Resource (2)
+	z.Resource, err = table.readString(dc)
+	if err != nil {
+		return err
+	}
+	// TraceID (3)
+	z.TraceID, err = parseUint64(dc)
+	if err != nil {
+		return err
+	}
+	// SpanID (4)
+	z.SpanID, err = parseUint64(dc)
+	if err != nil {
+		return err
+	}
+	// ParentID (5)
+	z.ParentID, err = parseUint64(dc)
+	if err != nil {
+		return err
+	}
+	// Start (6)
+	z.Start, err = parseInt64(dc)
+	if err != nil {
+		return err
+	}
+	// Duration (7)
+	z.Duration, err = parseInt64(dc)
+	if err != nil {
+		return err
+	}
+	// Error (8)
+	z.Error, err = parseInt32(dc)
+	if err != nil {
+		return err
+	}
+	// Meta (9)
+	sz, err = dc.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	if z.Meta == nil && sz > 0 {
+		z.Meta = make(map[string]string, sz)
+	} else if len(z.Meta) > 0 {
+		for key := range z.Meta {
+			delete(z.Meta, key)
+		}
+	}
+	for sz > 0 {
+		sz--
+		key, err := table.readString(dc)
+		if err != nil {
+			return err
+		}
+		val, err := table.readString(dc)
+		if err != nil {
+			return err
+		}
+		z.Meta[key] = val
+	}
+	// Metrics (10)
+	sz, err = dc.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	if z.Metrics == nil && sz > 0 {
+		z.Metrics = make(map[string]float64, sz)
+	} else if len(z.Metrics) > 0 {
+		for key := range z.Metrics {
+			delete(z.Metrics, key)
+		}
+	}
+	for sz > 0 {
+		sz--
+		key, err := table.readString(dc)
+		if err != nil {
+			return err
+		}
+		val, err := parseFloat64(dc)
+		if err != nil {
+			return err
+		}
+		z.Metrics[key] = val
+	}
+	// Type (11)
+	z.Type, err = table.readString(dc)
+	return err
+}
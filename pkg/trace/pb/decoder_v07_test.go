@@ -0,0 +1,99 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package pb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestDecodeMsgStringTable(t *testing.T) {
+	assert := assert.New(t)
+	want := Traces{
+		{
+			{
+				Service:  "my-service",
+				Name:     "my-name",
+				Resource: "my-resource",
+				TraceID:  1,
+				SpanID:   2,
+				ParentID: 3,
+				Start:    123,
+				Duration: 456,
+				Error:    1,
+				Meta:     map[string]string{"env": "prod", "version": "prod"},
+				Metrics:  map[string]float64{"_sampling_priority_v1": 1},
+				Type:     "web",
+			},
+			{
+				Service:  "my-service",
+				Name:     "my-name",
+				Resource: "SELECT 1",
+				TraceID:  1,
+				SpanID:   4,
+				ParentID: 2,
+				Meta:     map[string]string{"env": "prod"},
+				Type:     "sql",
+			},
+		},
+		{
+			{Service: "my-service", Name: "op\x99\xbf", TraceID: 5, SpanID: 6},
+		},
+	}
+	b := want.MarshalMsgStringTable(nil)
+
+	dc := NewMsgpReader(bytes.NewReader(b))
+	defer FreeMsgpReader(dc)
+	var got Traces
+	assert.NoError(got.DecodeMsgStringTable(dc))
+	want[1][0].Name = "op��"
+	assert.Equal(want, got)
+
+	// interned strings are only sent once
+	assert.Equal(1, bytes.Count(b, []byte("my-service")))
+	assert.Equal(1, bytes.Count(b, []byte("prod")))
+}
+
+func TestDecodeMsgStringTableInvalidIndex(t *testing.T) {
+	b := msgp.AppendArrayHeader(nil, 1)
+	b = msgp.AppendArrayHeader(b, 1)
+	b = msgp.AppendArrayHeader(b, spanPropertyCount)
+	b = msgp.AppendString(b, "my-service")
+	b = msgp.AppendUint32(b, 2) // only "" and "my-service" are in the table
+
+	dc := NewMsgpReader(bytes.NewReader(b))
+	defer FreeMsgpReader(dc)
+	var traces Traces
+	assert.EqualError(t, traces.DecodeMsgStringTable(dc), "string table index 2 out of range")
+}
+
+func BenchmarkDecodeMsgStringTable(b *testing.B) {
+	var traces Traces
+	for i := 0; i < 100; i++ {
+		traces = append(traces, Trace{
+			{Service: "my-service", Name: "my-name", Resource: "my-resource", TraceID: uint64(i), SpanID: 1, Meta: map[string]string{"env": "prod"}},
+			{Service: "my-service", Name: "my-name", Resource: "my-resource", TraceID: uint64(i), SpanID: 2, ParentID: 1, Metrics: map[string]float64{"m": 1}},
+		})
+	}
+	payload := traces.MarshalMsgStringTable(nil)
+	r := bytes.NewReader(payload)
+	dc := NewMsgpReader(r)
+	defer FreeMsgpReader(dc)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(payload)
+		dc.Reset(r)
+		var out Traces
+		if err := out.DecodeMsgStringTable(dc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package pb
+
+import (
+	"github.com/tinylib/msgp/msgp"
+)
+
+// stringIndex holds the indexes in the string table of the strings of a v0.7 payload
+// being encoded
+type stringIndex map[string]uint32
+
+// appendString appends a reference to s to b: its index if it was already sent, or the
+// string itself, in which case it is added to the table.
+func (idx stringIndex) appendString(b []byte, s string) []byte {
+	if i, ok := idx[s]; ok {
+		return msgp.AppendUint32(b, i)
+	}
+	idx[s] = uint32(len(idx))
+	return msgp.AppendString(b, s)
+}
+
+// MarshalMsgStringTable appends the traces encoded using the specification from the v0.7
+// endpoint to b. For details, see the documentation for endpoint v0.7 in
+// pkg/trace/api/version.go
+func (t Traces) MarshalMsgStringTable(b []byte) []byte {
+	idx := stringIndex{"": 0}
+	b = msgp.AppendArrayHeader(b, uint32(len(t)))
+	for _, trace := range t {
+		b = msgp.AppendArrayHeader(b, uint32(len(trace)))
+		for _, span := range trace {
+			if span == nil {
+				span = &Span{}
+			}
+			b = span.appendMsgStringTable(b, idx)
+		}
+	}
+	return b
+}
+
+// appendMsgStringTable appends the span to b, interning its strings in idx
+func (z *Span) appendMsgStringTable(b []byte, idx stringIndex) []byte {
+	b = msgp.AppendArrayHeader(b, spanPropertyCount)
+	b = idx.appendString(b, z.Service)
+	b = idx.appendString(b, z.Name)
+	b = idx.appendString(b, z.Resource)
+	b = msgp.AppendUint64(b, z.TraceID)
+	b = msgp.AppendUint64(b, z.SpanID)
+	b = msgp.AppendUint64(b, z.ParentID)
+	b = msgp.AppendInt64(b, z.Start)
+	b = msgp.AppendInt64(b, z.Duration)
+	b = msgp.AppendInt32(b, z.Error)
+	b = msgp.AppendMapHeader(b, uint32(len(z.Meta)))
+	for k, v := range z.Meta {
+		b = idx.appendString(b, k)
+		b = idx.appendString(b, v)
+	}
+	b = msgp.AppendMapHeader(b, uint32(len(z.Metrics)))
+	for k, v := range z.Metrics {
+		b = idx.appendString(b, k)
+		b = msgp.AppendFloat64(b, v)
+	}
+	return idx.appendString(b, z.Type)
+}
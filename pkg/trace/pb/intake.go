@@ -0,0 +1,127 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package pb
+
+import (
+	"context"
+
+	proto "github.com/gogo/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+// This file holds the types of the TraceIntake gRPC service defined in intake.proto. They are
+// written by hand in the shape of the generated code: the request messages are TracerPayload,
+// which have their own Marshal and Unmarshal methods, and IntakeResponse is encoded from its
+// struct tags.
+
+// IntakeResponse is sent by the trace agent in reply to each payload streamed to the intake.
+type IntakeResponse struct {
+	// RateByService specifies the recommended sampling rates of the services, keyed by "service:X,env:Y".
+	RateByService map[string]float64 `protobuf:"bytes,1,rep,name=rateByService" json:"rateByService,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"fixed64,2,opt,name=value,proto3"`
+	// Refused specifies whether the payload was refused by the rate limiter of the agent.
+	Refused bool `protobuf:"varint,2,opt,name=refused,proto3" json:"refused,omitempty"`
+}
+
+func (m *IntakeResponse) Reset()         { *m = IntakeResponse{} }
+func (m *IntakeResponse) String() string { return proto.CompactTextString(m) }
+func (*IntakeResponse) ProtoMessage()    {}
+
+// TraceIntakeClient is the client API of the TraceIntake service.
+type TraceIntakeClient interface {
+	SendTraces(ctx context.Context, opts ...grpc.CallOption) (TraceIntake_SendTracesClient, error)
+}
+
+type traceIntakeClient struct {
+	cc *grpc.ClientConn
+}
+
+// NewTraceIntakeClient returns a client of the TraceIntake service using the given connection.
+func NewTraceIntakeClient(cc *grpc.ClientConn) TraceIntakeClient {
+	return &traceIntakeClient{cc}
+}
+
+func (c *traceIntakeClient) SendTraces(ctx context.Context, opts ...grpc.CallOption) (TraceIntake_SendTracesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TraceIntake_serviceDesc.Streams[0], c.cc, "/pb.TraceIntake/SendTraces", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &traceIntakeSendTracesClient{stream}, nil
+}
+
+// TraceIntake_SendTracesClient is the client side of a SendTraces stream.
+type TraceIntake_SendTracesClient interface {
+	Send(*TracerPayload) error
+	Recv() (*IntakeResponse, error)
+	grpc.ClientStream
+}
+
+type traceIntakeSendTracesClient struct {
+	grpc.ClientStream
+}
+
+func (x *traceIntakeSendTracesClient) Send(m *TracerPayload) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *traceIntakeSendTracesClient) Recv() (*IntakeResponse, error) {
+	m := new(IntakeResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TraceIntakeServer is the server API of the TraceIntake service.
+type TraceIntakeServer interface {
+	SendTraces(TraceIntake_SendTracesServer) error
+}
+
+// RegisterTraceIntakeServer registers the implementation of the TraceIntake service on s.
+func RegisterTraceIntakeServer(s *grpc.Server, srv TraceIntakeServer) {
+	s.RegisterService(&_TraceIntake_serviceDesc, srv)
+}
+
+func _TraceIntake_SendTraces_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TraceIntakeServer).SendTraces(&traceIntakeSendTracesServer{stream})
+}
+
+// TraceIntake_SendTracesServer is the server side of a SendTraces stream.
+type TraceIntake_SendTracesServer interface {
+	Send(*IntakeResponse) error
+	Recv() (*TracerPayload, error)
+	grpc.ServerStream
+}
+
+type traceIntakeSendTracesServer struct {
+	grpc.ServerStream
+}
+
+func (x *traceIntakeSendTracesServer) Send(m *IntakeResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *traceIntakeSendTracesServer) Recv() (*TracerPayload, error) {
+	m := new(TracerPayload)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _TraceIntake_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.TraceIntake",
+	HandlerType: (*TraceIntakeServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SendTraces",
+			Handler:       _TraceIntake_SendTraces_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "intake.proto",
+}
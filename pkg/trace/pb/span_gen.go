@@ -64,6 +64,263 @@ func (z *Span) MarshalMsg(b []byte) (o []byte, err error) {
 	return
 }
 
+// DecodeMsg implements msgp.Decodable
+func (z *Span) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "service":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "Service")
+					return
+				}
+				z.Service = ""
+				break
+			}
+			z.Service, err = parseString(dc)
+			if err != nil {
+				err = msgp.WrapError(err, "Service")
+				return
+			}
+		case "name":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "Name")
+					return
+				}
+				z.Name = ""
+				break
+			}
+			z.Name, err = parseString(dc)
+			if err != nil {
+				err = msgp.WrapError(err, "Name")
+				return
+			}
+		case "resource":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "Resource")
+					return
+				}
+				z.Resource = ""
+				break
+			}
+			z.Resource, err = parseString(dc)
+			if err != nil {
+				err = msgp.WrapError(err, "Resource")
+				return
+			}
+		case "trace_id":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "TraceID")
+					return
+				}
+				z.TraceID = 0
+				break
+			}
+			z.TraceID, err = parseUint64(dc)
+			if err != nil {
+				err = msgp.WrapError(err, "TraceID")
+				return
+			}
+		case "span_id":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "SpanID")
+					return
+				}
+				z.SpanID = 0
+				break
+			}
+			z.SpanID, err = parseUint64(dc)
+			if err != nil {
+				err = msgp.WrapError(err, "SpanID")
+				return
+			}
+		case "parent_id":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "ParentID")
+					return
+				}
+				z.ParentID = 0
+				break
+			}
+			z.ParentID, err = parseUint64(dc)
+			if err != nil {
+				err = msgp.WrapError(err, "ParentID")
+				return
+			}
+		case "start":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "Start")
+					return
+				}
+				z.Start = 0
+				break
+			}
+			z.Start, err = parseInt64(dc)
+			if err != nil {
+				err = msgp.WrapError(err, "Start")
+				return
+			}
+		case "duration":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "Duration")
+					return
+				}
+				z.Duration = 0
+				break
+			}
+			z.Duration, err = parseInt64(dc)
+			if err != nil {
+				err = msgp.WrapError(err, "Duration")
+				return
+			}
+		case "error":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "Error")
+					return
+				}
+				z.Error = 0
+				break
+			}
+			z.Error, err = parseInt32(dc)
+			if err != nil {
+				err = msgp.WrapError(err, "Error")
+				return
+			}
+		case "meta":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "Meta")
+					return
+				}
+				z.Meta = nil
+				break
+			}
+			var zb0002 uint32
+			zb0002, err = dc.ReadMapHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Meta")
+				return
+			}
+			if z.Meta == nil && zb0002 > 0 {
+				z.Meta = make(map[string]string, zb0002)
+			} else if len(z.Meta) > 0 {
+				for key := range z.Meta {
+					delete(z.Meta, key)
+				}
+			}
+			for zb0002 > 0 {
+				var za0001 string
+				var za0002 string
+				zb0002--
+				za0001, err = parseString(dc)
+				if err != nil {
+					err = msgp.WrapError(err, "Meta")
+					return
+				}
+				za0002, err = parseString(dc)
+				if err != nil {
+					err = msgp.WrapError(err, "Meta", za0001)
+					return
+				}
+				z.Meta[za0001] = za0002
+			}
+		case "metrics":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "Metrics")
+					return
+				}
+				z.Metrics = nil
+				break
+			}
+			var zb0003 uint32
+			zb0003, err = dc.ReadMapHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Metrics")
+				return
+			}
+			if z.Metrics == nil && zb0003 > 0 {
+				z.Metrics = make(map[string]float64, zb0003)
+			} else if len(z.Metrics) > 0 {
+				for key := range z.Metrics {
+					delete(z.Metrics, key)
+				}
+			}
+			for zb0003 > 0 {
+				var za0003 string
+				var za0004 float64
+				zb0003--
+				za0003, err = parseString(dc)
+				if err != nil {
+					err = msgp.WrapError(err, "Metrics")
+					return
+				}
+				za0004, err = parseFloat64(dc)
+				if err != nil {
+					err = msgp.WrapError(err, "Metrics", za0003)
+					return
+				}
+				z.Metrics[za0003] = za0004
+			}
+		case "type":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "Type")
+					return
+				}
+				z.Type = ""
+				break
+			}
+			z.Type, err = parseString(dc)
+			if err != nil {
+				err = msgp.WrapError(err, "Type")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
 // UnmarshalMsg implements msgp.Unmarshaler
 func (z *Span) UnmarshalMsg(bts []byte) (o []byte, err error) {
 	var field []byte
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package pb
+
+import (
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracerPayloadMarshalling(t *testing.T) {
+	assert := assert.New(t)
+	want := &TracerPayload{
+		ContainerID:     "abcdef",
+		LanguageName:    "go",
+		LanguageVersion: "1.15",
+		TracerVersion:   "1.27.0",
+		RuntimeID:       "2b0e9a4a-fe6e-4c2a-9c8e-3f8a9c1e7f2d",
+		Chunks: []*TraceChunk{
+			{
+				Priority: 2,
+				Origin:   "synthetics",
+				Spans: []*Span{
+					{Service: "web", Name: "http.request", TraceID: 1, SpanID: 1, Meta: map[string]string{"env": "prod"}},
+					{Service: "db", Name: "sql.query", TraceID: 1, SpanID: 2, ParentID: 1},
+				},
+				Tags: map[string]string{"_dd.hostname": "host"},
+			},
+			{Priority: -1, DroppedTrace: true},
+		},
+		Tags:       map[string]string{"_dd.tags.container": "image:web"},
+		Env:        "prod",
+		Hostname:   "host",
+		AppVersion: "v1",
+	}
+
+	b, err := proto.Marshal(want)
+	assert.NoError(err)
+	assert.Len(b, want.Size())
+
+	var got TracerPayload
+	assert.NoError(proto.Unmarshal(b, &got))
+	assert.Equal(want, &got)
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+// Package validate contains the validation of the fields of the spans received by the
+// trace agent: IDs, timestamps and UTF-8 strings. It is shared by the normalization of the
+// agent, by the decoders of the pb package and by the test tooling, and can be used by the
+// programs embedding the trace agent to check spans the same way it does.
+//
+// The validators work on the values of the fields rather than on spans, so that the pb
+// package can depend on it. They are allocation free, except RepairUTF8 on invalid input.
+package validate
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Year2000NanosecTS is an arbitrary cutoff to spot weird-looking start timestamps, which are
+// most likely not in nanoseconds.
+var Year2000NanosecTS = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+
+var (
+	// ErrTraceIDZero is returned when the trace ID of a span is zero.
+	ErrTraceIDZero = errors.New("trace ID is zero")
+	// ErrSpanIDZero is returned when the ID of a span is zero.
+	ErrSpanIDZero = errors.New("span ID is zero")
+	// ErrStartTooOld is returned when the start of a span is before Year2000NanosecTS.
+	ErrStartTooOld = errors.New("start is before year 2000")
+	// ErrDurationNegative is returned when the duration of a span is negative.
+	ErrDurationNegative = errors.New("duration is negative")
+	// ErrDurationOverflow is returned when the end of a span, its start plus its duration,
+	// does not fit in an int64.
+	ErrDurationOverflow = errors.New("duration overflows the end of the span")
+)
+
+// TraceID checks that a trace ID is set.
+func TraceID(id uint64) error {
+	if id == 0 {
+		return ErrTraceIDZero
+	}
+	return nil
+}
+
+// SpanID checks that a span ID is set.
+func SpanID(id uint64) error {
+	if id == 0 {
+		return ErrSpanIDZero
+	}
+	return nil
+}
+
+// Start checks that the start of a span is a nanosecond timestamp after year 2000.
+func Start(start int64) error {
+	if start < Year2000NanosecTS {
+		return ErrStartTooOld
+	}
+	return nil
+}
+
+// Duration checks that the duration of a span starting at start is positive and that its end
+// does not overflow.
+func Duration(start, duration int64) error {
+	if duration < 0 {
+		return ErrDurationNegative
+	}
+	if duration > math.MaxInt64-start {
+		return ErrDurationOverflow
+	}
+	return nil
+}
+
+// UTF8 reports whether s is valid UTF-8.
+func UTF8(s string) bool {
+	return utf8.ValidString(s)
+}
+
+// RepairUTF8 returns s with each byte which is not part of a valid UTF-8 sequence replaced by
+// utf8.RuneError. It returns s as is when it is valid.
+func RepairUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	var out strings.Builder
+	out.Grow(len(s) + 2*utf8.UTFMax)
+	// ranging over a string yields utf8.RuneError for each invalid byte
+	for _, r := range s {
+		out.WriteRune(r)
+	}
+	return out.String()
+}
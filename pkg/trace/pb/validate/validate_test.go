@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package validate
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDs(t *testing.T) {
+	assert.Equal(t, ErrTraceIDZero, TraceID(0))
+	assert.NoError(t, TraceID(42))
+	assert.Equal(t, ErrSpanIDZero, SpanID(0))
+	assert.NoError(t, SpanID(42))
+}
+
+func TestStart(t *testing.T) {
+	assert.NoError(t, Start(time.Now().UnixNano()))
+	assert.NoError(t, Start(Year2000NanosecTS))
+	assert.Equal(t, ErrStartTooOld, Start(Year2000NanosecTS-1))
+	// seconds instead of nanoseconds
+	assert.Equal(t, ErrStartTooOld, Start(time.Now().Unix()))
+}
+
+func TestDuration(t *testing.T) {
+	now := time.Now().UnixNano()
+	assert.NoError(t, Duration(now, 0))
+	assert.NoError(t, Duration(now, int64(time.Second)))
+	assert.NoError(t, Duration(now, math.MaxInt64-now))
+	assert.Equal(t, ErrDurationNegative, Duration(now, -1))
+	assert.Equal(t, ErrDurationOverflow, Duration(now, math.MaxInt64-now+1))
+}
+
+func TestRepairUTF8(t *testing.T) {
+	for in, out := range map[string]string{
+		"":                     "",
+		"hello":                "hello",
+		"日本語":                  "日本語",
+		"a\xffb":               "a\uFFFDb",
+		"\xff\xfe":             "\uFFFD\uFFFD",
+		"truncated \xe6\x97":   "truncated \uFFFD\uFFFD",
+		"valid \uFFFD already": "valid \uFFFD already",
+	} {
+		assert.Equal(t, out, RepairUTF8(in), in)
+		assert.True(t, UTF8(RepairUTF8(in)), in)
+	}
+	assert.False(t, UTF8("a\xffb"))
+}
+
+func BenchmarkRepairUTF8(b *testing.B) {
+	b.Run("valid", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			RepairUTF8("GET /api/v1/users/:id")
+		}
+	})
+	b.Run("invalid", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			RepairUTF8("GET /api/v1/users/\xff\xfe")
+		}
+	})
+}
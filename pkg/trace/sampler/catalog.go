@@ -5,17 +5,26 @@
 
 package sampler
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
 
 const defaultServiceRateKey = "service:,env:"
 
 // serviceKeyCatalog reverse-maps service signatures to their generated hashes for
 // easy look up.
 type serviceKeyCatalog struct {
-	mu     sync.Mutex
-	lookup map[ServiceSignature]Signature
+	mu        sync.Mutex
+	lookup    map[ServiceSignature]Signature
+	resources int // number of signatures of the lookup with a resource
 }
 
+// maxCatalogResources is the maximum number of resources which get their own rates, the
+// traces of the other resources being sampled with the rates of their services.
+const maxCatalogResources = 1000
+
 // newServiceLookup returns a new serviceKeyCatalog.
 func newServiceLookup() *serviceKeyCatalog {
 	return &serviceKeyCatalog{
@@ -24,13 +33,43 @@ func newServiceLookup() *serviceKeyCatalog {
 }
 
 func (cat *serviceKeyCatalog) register(svcSig ServiceSignature) Signature {
-	hash := svcSig.Hash()
 	cat.mu.Lock()
-	cat.lookup[svcSig] = hash
-	cat.mu.Unlock()
+	defer cat.mu.Unlock()
+	if svcSig.Resource != "" {
+		if _, ok := cat.lookup[svcSig]; !ok {
+			if cat.resources >= maxCatalogResources {
+				svcSig.Resource = ""
+			} else {
+				cat.resources++
+			}
+		}
+	}
+	hash, ok := cat.lookup[svcSig]
+	if !ok {
+		// the signature outlives the payload its strings come from, make sure it
+		// does not reference its memory (see pb.CopyString)
+		svcSig = ServiceSignature{
+			Name:     pb.CopyString(svcSig.Name),
+			Env:      pb.CopyString(svcSig.Env),
+			Resource: pb.CopyString(svcSig.Resource),
+		}
+		hash = svcSig.Hash()
+		cat.lookup[svcSig] = hash
+	}
 	return hash
 }
 
+// names returns the service signatures of the catalog by hash.
+func (cat *serviceKeyCatalog) names() map[Signature]ServiceSignature {
+	cat.mu.Lock()
+	defer cat.mu.Unlock()
+	names := make(map[Signature]ServiceSignature, len(cat.lookup))
+	for svcSig, sig := range cat.lookup {
+		names[sig] = svcSig
+	}
+	return names
+}
+
 // ratesByService returns a map of service signatures mapping to the rates identified using
 // the signatures.
 func (cat *serviceKeyCatalog) ratesByService(rates map[Signature]float64, totalScore float64) map[ServiceSignature]float64 {
@@ -42,6 +81,9 @@ func (cat *serviceKeyCatalog) ratesByService(rates map[Signature]float64, totalS
 			rbs[key] = rate
 		} else {
 			delete(cat.lookup, key)
+			if key.Resource != "" {
+				cat.resources--
+			}
 		}
 	}
 	rbs[ServiceSignature{}] = totalScore
@@ -6,8 +6,10 @@
 package sampler
 
 import (
+	"strconv"
 	"sync"
 	"testing"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -34,8 +36,8 @@ func TestCatalogRegression(t *testing.T) {
 		defer wg.Done()
 		for i := 0; i < n; i++ {
 			cat.ratesByService(map[Signature]float64{
-				ServiceSignature{}.Hash():                 0.3,
-				ServiceSignature{"web", "staging"}.Hash(): 0.4,
+				ServiceSignature{}.Hash():                            0.3,
+				ServiceSignature{Name: "web", Env: "staging"}.Hash(): 0.4,
 			}, 0.2)
 		}
 	}()
@@ -47,7 +49,8 @@ func TestServiceSignatureString(t *testing.T) {
 	assert := assert.New(t)
 
 	assert.Equal(defaultServiceRateKey, ServiceSignature{}.String())
-	assert.Equal("service:mcnulty,env:test", ServiceSignature{"mcnulty", "test"}.String())
+	assert.Equal("service:mcnulty,env:test", ServiceSignature{Name: "mcnulty", Env: "test"}.String())
+	assert.Equal("service:mcnulty,env:test,resource:GET /users", ServiceSignature{Name: "mcnulty", Env: "test", Resource: "GET /users"}.String())
 }
 
 func TestNewServiceLookup(t *testing.T) {
@@ -62,25 +65,38 @@ func TestServiceKeyCatalogRegister(t *testing.T) {
 	s := getTestPriorityEngine()
 
 	_, root1 := getTestTraceWithService(t, "service1", s)
-	sig1 := cat.register(ServiceSignature{root1.Service, defaultEnv})
+	sig1 := cat.register(ServiceSignature{Name: root1.Service, Env: defaultEnv})
 	assert.Equal(
 		map[ServiceSignature]Signature{
-			{"service1", "none"}: sig1,
+			{Name: "service1", Env: "none"}: sig1,
 		},
 		cat.lookup,
 	)
 
 	_, root2 := getTestTraceWithService(t, "service2", s)
-	sig2 := cat.register(ServiceSignature{root2.Service, defaultEnv})
+	sig2 := cat.register(ServiceSignature{Name: root2.Service, Env: defaultEnv})
 	assert.Equal(
 		map[ServiceSignature]Signature{
-			{"service1", "none"}: sig1,
-			{"service2", "none"}: sig2,
+			{Name: "service1", Env: "none"}: sig1,
+			{Name: "service2", Env: "none"}: sig2,
 		},
 		cat.lookup,
 	)
 }
 
+func TestServiceKeyCatalogRegisterCopy(t *testing.T) {
+	cat := newServiceLookup()
+	// the strings of decoded payloads may share their memory, which is reused
+	buf := []byte("service-1")
+	name := *(*string)(unsafe.Pointer(&buf))
+	sig := cat.register(ServiceSignature{Name: name, Env: "env-1"})
+	copy(buf, "service-2")
+
+	assert.Equal(t, map[ServiceSignature]Signature{
+		{Name: "service-1", Env: "env-1"}: sig,
+	}, cat.lookup)
+}
+
 func TestServiceKeyCatalogRatesByService(t *testing.T) {
 	assert := assert.New(t)
 
@@ -88,9 +104,9 @@ func TestServiceKeyCatalogRatesByService(t *testing.T) {
 	s := getTestPriorityEngine()
 
 	_, root1 := getTestTraceWithService(t, "service1", s)
-	sig1 := cat.register(ServiceSignature{root1.Service, defaultEnv})
+	sig1 := cat.register(ServiceSignature{Name: root1.Service, Env: defaultEnv})
 	_, root2 := getTestTraceWithService(t, "service2", s)
-	sig2 := cat.register(ServiceSignature{root2.Service, defaultEnv})
+	sig2 := cat.register(ServiceSignature{Name: root2.Service, Env: defaultEnv})
 
 	rates := map[Signature]float64{
 		sig1: 0.3,
@@ -100,17 +116,17 @@ func TestServiceKeyCatalogRatesByService(t *testing.T) {
 
 	rateByService := cat.ratesByService(rates, totalRate)
 	assert.Equal(map[ServiceSignature]float64{
-		{"service1", "none"}: 0.3,
-		{"service2", "none"}: 0.7,
-		{}:                   0.2,
+		{Name: "service1", Env: "none"}: 0.3,
+		{Name: "service2", Env: "none"}: 0.7,
+		{}:                              0.2,
 	}, rateByService)
 
 	delete(rates, sig1)
 
 	rateByService = cat.ratesByService(rates, totalRate)
 	assert.Equal(map[ServiceSignature]float64{
-		{"service2", "none"}: 0.7,
-		{}:                   0.2,
+		{Name: "service2", Env: "none"}: 0.7,
+		{}:                              0.2,
 	}, rateByService)
 
 	delete(rates, sig2)
@@ -120,3 +136,25 @@ func TestServiceKeyCatalogRatesByService(t *testing.T) {
 		{}: 0.2,
 	}, rateByService)
 }
+
+func TestServiceKeyCatalogMaxResources(t *testing.T) {
+	assert := assert.New(t)
+
+	cat := newServiceLookup()
+	rates := make(map[Signature]float64)
+	for i := 0; i < maxCatalogResources; i++ {
+		sig := cat.register(ServiceSignature{Name: "web", Env: "prod", Resource: strconv.Itoa(i)})
+		rates[sig] = 0.5
+	}
+	// the resources above the limit are keyed by their service
+	sig := cat.register(ServiceSignature{Name: "web", Env: "prod", Resource: "extra"})
+	assert.Equal(ServiceSignature{Name: "web", Env: "prod"}.Hash(), sig)
+	assert.Equal(maxCatalogResources, cat.resources)
+
+	// until some resources expire
+	delete(rates, ServiceSignature{Name: "web", Env: "prod", Resource: "0"}.Hash())
+	cat.ratesByService(rates, 1)
+	assert.Equal(maxCatalogResources-1, cat.resources)
+	sig = cat.register(ServiceSignature{Name: "web", Env: "prod", Resource: "extra"})
+	assert.Equal(ServiceSignature{Name: "web", Env: "prod", Resource: "extra"}.Hash(), sig)
+}
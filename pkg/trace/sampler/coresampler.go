@@ -39,6 +39,8 @@ const (
 	ErrorsScoreEngineType
 	// PriorityEngineType is type of the priority sampler engine type.
 	PriorityEngineType
+	// HashEngineType is the type of the HashEngine sampling traces from their trace ID.
+	HashEngineType
 )
 
 // Engine is a common basic interface for sampler engines.
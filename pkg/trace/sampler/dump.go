@@ -0,0 +1,192 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package sampler
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// String returns the name of the engine type.
+func (t EngineType) String() string {
+	switch t {
+	case NormalScoreEngineType:
+		return "score"
+	case ErrorsScoreEngineType:
+		return "errors"
+	case PriorityEngineType:
+		return "priority"
+	case HashEngineType:
+		return "hash"
+	default:
+		return "unknown"
+	}
+}
+
+// EngineDump is the detailed state of a sampler engine, exposed to help figuring out why a
+// trace was kept or not.
+type EngineDump struct {
+	// Type is the name of the type of the engine.
+	Type string `json:"type"`
+	// State is the state returned by GetState.
+	State interface{} `json:"state"`
+	// Backend holds the counters of the backend of adaptive engines.
+	Backend *BackendDump `json:"backend,omitempty"`
+	// DefaultRate is the rate applied to the signatures not seen yet.
+	DefaultRate float64 `json:"default_rate,omitempty"`
+	// MaxTPSRate is the extra rate currently applied to stay below the maximum traces per second.
+	MaxTPSRate float64 `json:"max_tps_rate,omitempty"`
+	// Signatures holds the signatures seen recently, by decreasing throughput.
+	Signatures []SignatureDump `json:"signatures,omitempty"`
+	// RatesByService holds the rates sent back to the tracers by service and env.
+	RatesByService map[string]float64 `json:"rates_by_service,omitempty"`
+}
+
+// BackendDump holds the counters of a Backend, normalized to traces per second.
+type BackendDump struct {
+	TotalTPS    float64 `json:"total_tps"`
+	SampledTPS  float64 `json:"sampled_tps"`
+	Cardinality int64   `json:"cardinality"`
+}
+
+// SignatureDump is the state of a signature in an EngineDump.
+type SignatureDump struct {
+	// Signature is the hash of the signature. For the priority engine, it is the hash of
+	// the service and env.
+	Signature Signature `json:"signature"`
+	// Service, Env, Name and Resource describe the signature: the service, env and resource
+	// of the priority engine, or the ones of the root span for the score engines. They are
+	// empty when the signature is not known anymore.
+	Service  string `json:"service,omitempty"`
+	Env      string `json:"env,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Resource string `json:"resource,omitempty"`
+	// TPS is the recent throughput of the signature.
+	TPS float64 `json:"tps"`
+	// Rate is the rate the traces of the signature are sampled at.
+	Rate float64 `json:"rate"`
+}
+
+// DumpEngine returns the detailed state of the engine e. Only the type and the state of the
+// engines other than the ones of this package are returned.
+func DumpEngine(e Engine) EngineDump {
+	d := EngineDump{
+		Type:  e.GetType().String(),
+		State: e.GetState(),
+	}
+	switch e := e.(type) {
+	case *ScoreEngine:
+		e.Sampler.dump(&d)
+		d.MaxTPSRate = e.Sampler.GetMaxTPSSampleRate()
+		names := e.names.prune(e.Sampler.Backend.GetSignatureScores())
+		for i, sig := range d.Signatures {
+			// the score engines combine the extra rate to the rate of the signature
+			d.Signatures[i].Rate = e.Sampler.GetSampleRate(nil, nil, sig.Signature)
+			d.Signatures[i].describe(names[sig.Signature])
+		}
+	case *PriorityEngine:
+		e.Sampler.dump(&d)
+		names := e.catalog.names()
+		for i, sig := range d.Signatures {
+			svcSig := names[sig.Signature]
+			d.Signatures[i].describe(signatureName{Service: svcSig.Name, Env: svcSig.Env, Resource: svcSig.Resource})
+		}
+		rates := e.ratesByService()
+		d.RatesByService = make(map[string]float64, len(rates))
+		for sig, rate := range rates {
+			d.RatesByService[sig.String()] = rate
+		}
+	}
+	return d
+}
+
+// dump fills d with the counters and the signatures of s.
+func (s *Sampler) dump(d *EngineDump) {
+	d.Backend = &BackendDump{
+		TotalTPS:    s.Backend.GetTotalScore(),
+		SampledTPS:  s.Backend.GetSampledScore(),
+		Cardinality: s.Backend.GetCardinality(),
+	}
+	d.DefaultRate = s.GetDefaultSampleRate()
+	scores := s.Backend.GetSignatureScores()
+	d.Signatures = make([]SignatureDump, 0, len(scores))
+	for sig, tps := range scores {
+		d.Signatures = append(d.Signatures, SignatureDump{
+			Signature: sig,
+			TPS:       tps,
+			Rate:      s.loadRate(s.backendScoreToSamplerScore(tps)),
+		})
+	}
+	sort.Slice(d.Signatures, func(i, j int) bool {
+		if d.Signatures[i].TPS != d.Signatures[j].TPS {
+			return d.Signatures[i].TPS > d.Signatures[j].TPS
+		}
+		return d.Signatures[i].Signature < d.Signatures[j].Signature
+	})
+}
+
+// describe sets the readable description of the signature.
+func (d *SignatureDump) describe(n signatureName) {
+	d.Service, d.Env, d.Name, d.Resource = n.Service, n.Env, n.Name, n.Resource
+}
+
+// maxSignatureNames is the maximum number of signatures a signatureNames describes, the
+// other signatures being dumped without their description.
+const maxSignatureNames = 2000
+
+// signatureName is the readable description of a signature.
+type signatureName struct{ Service, Env, Name, Resource string }
+
+// signatureNames keeps the description of the signatures of a ScoreEngine, so that they
+// can be dumped. The signatures not scored anymore are removed on each dump.
+type signatureNames struct {
+	mu    sync.RWMutex
+	names map[Signature]signatureName
+}
+
+func newSignatureNames() *signatureNames {
+	return &signatureNames{names: make(map[Signature]signatureName)}
+}
+
+// register records the description of the signature of a trace with the given root and env.
+func (n *signatureNames) register(sig Signature, root *pb.Span, env string) {
+	n.mu.RLock()
+	_, ok := n.names[sig]
+	full := len(n.names) >= maxSignatureNames
+	n.mu.RUnlock()
+	if ok || full {
+		return
+	}
+	// the description outlives the payload its strings come from (see pb.CopyString)
+	name := signatureName{
+		Service:  pb.CopyString(root.Service),
+		Env:      pb.CopyString(env),
+		Name:     pb.CopyString(root.Name),
+		Resource: pb.CopyString(root.Resource),
+	}
+	n.mu.Lock()
+	if len(n.names) < maxSignatureNames {
+		n.names[sig] = name
+	}
+	n.mu.Unlock()
+}
+
+// prune removes the signatures missing from scores and returns a copy of the remaining ones.
+func (n *signatureNames) prune(scores map[Signature]float64) map[Signature]signatureName {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	names := make(map[Signature]signatureName, len(n.names))
+	for sig, name := range n.names {
+		if _, ok := scores[sig]; !ok {
+			delete(n.names, sig)
+			continue
+		}
+		names[sig] = name
+	}
+	return names
+}
@@ -0,0 +1,97 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package sampler
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpScoreEngine(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestScoreEngine()
+	var signature Signature
+	for i := 0; i < 1000; i++ {
+		trace, root := getTestTrace()
+		signature = computeSignatureWithRootAndEnv(trace, root, defaultEnv)
+		s.Sample(trace, root, defaultEnv)
+	}
+
+	d := DumpEngine(s)
+	assert.Equal("errors", d.Type)
+	assert.Equal(s.GetState(), d.State)
+	assert.Equal(&BackendDump{
+		TotalTPS:    s.Sampler.Backend.GetTotalScore(),
+		SampledTPS:  s.Sampler.Backend.GetSampledScore(),
+		Cardinality: 1,
+	}, d.Backend)
+	assert.Equal(1.0, d.MaxTPSRate)
+	if assert.Len(d.Signatures, 1) {
+		assert.Equal(signature, d.Signatures[0].Signature)
+		assert.Equal(s.Sampler.Backend.GetSignatureScore(signature), d.Signatures[0].TPS)
+		assert.Equal(s.Sampler.GetSampleRate(nil, nil, signature), d.Signatures[0].Rate)
+		assert.Equal("mcnulty", d.Signatures[0].Service)
+		assert.Equal(defaultEnv, d.Signatures[0].Env)
+	}
+	assert.Nil(d.RatesByService)
+}
+
+func TestSignatureNames(t *testing.T) {
+	assert := assert.New(t)
+	n := newSignatureNames()
+	root := &pb.Span{Service: "svc", Name: "op", Resource: "res"}
+	n.register(1, root, "env")
+	n.register(2, root, "env")
+	root.Service = "other"
+	n.register(1, root, "env")
+
+	names := n.prune(map[Signature]float64{1: 1})
+	assert.Equal(map[Signature]signatureName{
+		1: {Service: "svc", Env: "env", Name: "op", Resource: "res"},
+	}, names)
+	assert.Len(n.names, 1)
+
+	for i := 0; i < maxSignatureNames+10; i++ {
+		n.register(Signature(i), root, "env")
+	}
+	assert.Len(n.names, maxSignatureNames)
+}
+
+func TestDumpPriorityEngine(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestPriorityEngine()
+	for i := 0; i < 1000; i++ {
+		service := testServiceA
+		if i%10 == 0 {
+			service = testServiceB
+		}
+		trace, root := getTestTraceWithService(t, service, s)
+		s.Sample(trace, root, defaultEnv)
+	}
+
+	d := DumpEngine(s)
+	assert.Equal("priority", d.Type)
+	assert.EqualValues(2, d.Backend.Cardinality)
+	if assert.Len(d.Signatures, 2) {
+		// by decreasing throughput
+		assert.Equal(ServiceSignature{Name: testServiceA, Env: defaultEnv}.Hash(), d.Signatures[0].Signature)
+		assert.Equal(ServiceSignature{Name: testServiceB, Env: defaultEnv}.Hash(), d.Signatures[1].Signature)
+		assert.True(d.Signatures[0].TPS > d.Signatures[1].TPS)
+		assert.Equal(testServiceA, d.Signatures[0].Service)
+		assert.Equal(testServiceB, d.Signatures[1].Service)
+		assert.Equal(defaultEnv, d.Signatures[1].Env)
+	}
+	assert.Len(d.RatesByService, 3)
+	assert.Equal(d.Signatures[0].Rate, d.RatesByService["service:"+testServiceA+",env:"+defaultEnv])
+	assert.Equal(d.DefaultRate, d.RatesByService["service:,env:"])
+}
+
+func TestDumpHashEngine(t *testing.T) {
+	d := DumpEngine(NewHashEngine(0.5))
+	assert.Equal(t, EngineDump{Type: "hash", State: HashState{Rate: 0.5}}, d)
+}
@@ -24,13 +24,15 @@ func NewDynamicConfig(env string) *DynamicConfig {
 	return &DynamicConfig{RateByService: RateByService{defaultEnv: env}}
 }
 
-// RateByService stores the sampling rate per service. It is thread-safe, so
-// one can read/write on it concurrently, using getters and setters.
+// RateByService stores the sampling rate per service, and per resource of the services when
+// the priority sampler keys its rates by resource. It is thread-safe, so one can read/write
+// on it concurrently, using getters and setters.
 type RateByService struct {
 	defaultEnv string // env. to use for service defaults
 
-	mu    sync.RWMutex // guards rates
-	rates map[string]float64
+	mu            sync.RWMutex // guards rates and resourceRates
+	rates         map[string]float64
+	resourceRates map[string]float64 // rates by resource, kept apart for the tracers supporting them
 }
 
 // SetAll the sampling rate for all services. If a service/env is not
@@ -39,12 +41,8 @@ func (rbs *RateByService) SetAll(rates map[ServiceSignature]float64) {
 	rbs.mu.Lock()
 	defer rbs.mu.Unlock()
 
-	if rbs.rates == nil {
-		rbs.rates = make(map[string]float64, len(rates))
-	}
-	for k := range rbs.rates {
-		delete(rbs.rates, k)
-	}
+	rbs.rates = make(map[string]float64, len(rbs.rates))
+	rbs.resourceRates = make(map[string]float64, len(rbs.resourceRates))
 	for k, v := range rates {
 		if v < 0 {
 			v = 0
@@ -52,11 +50,15 @@ func (rbs *RateByService) SetAll(rates map[ServiceSignature]float64) {
 		if v > 1 {
 			v = 1
 		}
-		rbs.rates[k.String()] = v
+		dst := rbs.rates
+		if k.Resource != "" {
+			dst = rbs.resourceRates
+		}
+		dst[k.String()] = v
 		if k.Env == rbs.defaultEnv {
 			// if this is the default env, then this is also the
 			// service's default rate unbound to any env.
-			rbs.rates[ServiceSignature{Name: k.Name}.String()] = v
+			dst[ServiceSignature{Name: k.Name, Resource: k.Resource}.String()] = v
 		}
 	}
 }
@@ -66,10 +68,23 @@ func (rbs *RateByService) GetAll() map[string]float64 {
 	rbs.mu.RLock()
 	defer rbs.mu.RUnlock()
 
-	ret := make(map[string]float64, len(rbs.rates))
-	for k, v := range rbs.rates {
+	return copyRates(rbs.rates)
+}
+
+// GetAllByResource returns the sampling rates of the resources of the services, keyed by
+// "service:X,env:Y,resource:Z", when the priority sampler keys its rates by resource. They
+// are only sent to the tracers which support them, on top of the rates of the services.
+func (rbs *RateByService) GetAllByResource() map[string]float64 {
+	rbs.mu.RLock()
+	defer rbs.mu.RUnlock()
+
+	return copyRates(rbs.resourceRates)
+}
+
+func copyRates(rates map[string]float64) map[string]float64 {
+	ret := make(map[string]float64, len(rates))
+	for k, v := range rates {
 		ret[k] = v
 	}
-
 	return ret
 }
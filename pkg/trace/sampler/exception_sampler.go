@@ -12,11 +12,11 @@ import (
 )
 
 const (
-	// cardinalityLimit limits the number of spans considered per combination of (env, service).
+	// cardinalityLimit is the default limit of the number of spans considered per combination of (env, service).
 	cardinalityLimit = 1000
-	// defaultTTL limits the frequency at which we sample a same span (env, service, name, rsc, ...).
+	// defaultTTL is the default period during which a same span (env, service, name, rsc, ...) is not sampled again.
 	defaultTTL = 2 * time.Minute
-	// priorityTTL allows to blacklist p1 spans that are sampled entirely, for this period.
+	// priorityTTL is the default period during which the p1 spans that are sampled entirely are blacklisted.
 	priorityTTL = 10 * time.Minute
 	// ttlRenewalPeriod specifies the frequency at which we will upload cached entries.
 	ttlRenewalPeriod = 1 * time.Minute
@@ -27,6 +27,24 @@ const (
 	exceptionKey          = "_dd.exception"
 )
 
+// defaultSignature lists the span attributes making the signatures of the spans by default.
+var defaultSignature = []string{"resource", KeyHTTPStatusCode, KeyErrorType, KeyErrorFingerprint}
+
+// ExceptionSamplerConfig configures an ExceptionSampler. Its zero values stand for the defaults.
+type ExceptionSamplerConfig struct {
+	// TTL is the period during which a span sampled by the sampler is not sampled again.
+	TTL time.Duration
+	// PriorityTTL is the period during which the spans of the traces kept by the priority
+	// sampler are not sampled.
+	PriorityTTL time.Duration
+	// CardinalityLimit limits the number of spans considered per combination of (env, service).
+	CardinalityLimit int
+	// Signature lists the span attributes which, along with the service, the operation name
+	// and the error of the spans, make their signature. An attribute is either "resource" or
+	// the key of a meta tag.
+	Signature []string
+}
+
 // ExceptionSampler samples traces that are not caught by the Priority sampler.
 // It ensures that we sample traces for each combination of
 // (env, service, name, resource, error type, http status) seen on a top level or measured span
@@ -43,15 +61,38 @@ type ExceptionSampler struct {
 	tickStats *time.Ticker
 	limiter   *rate.Limiter
 	seen      map[Signature]*seenSpans
+
+	ttl              time.Duration
+	priorityTTL      time.Duration
+	cardinalityLimit int
+	signature        []string
 }
 
 // NewExceptionSampler returns a NewExceptionSampler that ensures that we sample combinations
-// of env, service, name, resource, http-status, error type for each top level or measured spans
-func NewExceptionSampler() *ExceptionSampler {
+// of env, service, name, resource, http-status, error type for each top level or measured spans,
+// or of the attributes of the signature of conf.
+func NewExceptionSampler(conf ExceptionSamplerConfig) *ExceptionSampler {
 	e := &ExceptionSampler{
 		limiter:   rate.NewLimiter(exceptionSamplerTPS, exceptionSamplerBurst),
 		seen:      make(map[Signature]*seenSpans),
 		tickStats: time.NewTicker(10 * time.Second),
+
+		ttl:              conf.TTL,
+		priorityTTL:      conf.PriorityTTL,
+		cardinalityLimit: conf.CardinalityLimit,
+		signature:        conf.Signature,
+	}
+	if e.ttl <= 0 {
+		e.ttl = defaultTTL
+	}
+	if e.priorityTTL <= 0 {
+		e.priorityTTL = priorityTTL
+	}
+	if e.cardinalityLimit <= 0 {
+		e.cardinalityLimit = cardinalityLimit
+	}
+	if len(e.signature) == 0 {
+		e.signature = defaultSignature
 	}
 	go func() {
 		for range e.tickStats.C {
@@ -80,7 +121,7 @@ func (e *ExceptionSampler) Stop() {
 }
 
 func (e *ExceptionSampler) handlePriorityTrace(now time.Time, env string, t pb.Trace) {
-	expire := now.Add(priorityTTL)
+	expire := now.Add(e.priorityTTL)
 	for _, s := range t {
 		if !traceutil.HasTopLevel(s) && !traceutil.IsMeasured(s) {
 			continue
@@ -91,7 +132,7 @@ func (e *ExceptionSampler) handlePriorityTrace(now time.Time, env string, t pb.T
 
 func (e *ExceptionSampler) handleTrace(now time.Time, env string, t pb.Trace) bool {
 	var sampled bool
-	expire := now.Add(defaultTTL)
+	expire := now.Add(e.ttl)
 	for _, s := range t {
 		if !traceutil.HasTopLevel(s) && !traceutil.IsMeasured(s) {
 			continue
@@ -107,7 +148,7 @@ func (e *ExceptionSampler) handleTrace(now time.Time, env string, t pb.Trace) bo
 
 // addSpan adds a span to the seenSpans with an expire time.
 func (e *ExceptionSampler) addSpan(expire time.Time, env string, s *pb.Span) {
-	shardSig := ServiceSignature{env, s.Service}.Hash()
+	shardSig := ServiceSignature{Name: env, Env: s.Service}.Hash()
 	ss := e.loadSeenSpans(shardSig)
 	ss.add(expire, s)
 }
@@ -116,14 +157,14 @@ func (e *ExceptionSampler) addSpan(expire time.Time, env string, s *pb.Span) {
 // it's added to the seenSpans set.
 func (e *ExceptionSampler) sampleSpan(now time.Time, env string, s *pb.Span) bool {
 	var sampled bool
-	shardSig := ServiceSignature{env, s.Service}.Hash()
+	shardSig := ServiceSignature{Name: env, Env: s.Service}.Hash()
 	ss := e.loadSeenSpans(shardSig)
 	sig := ss.sign(s)
 	expire, ok := ss.getExpire(sig)
 	if now.After(expire) || !ok {
 		sampled = e.limiter.Allow()
 		if sampled {
-			ss.add(now.Add(defaultTTL), s)
+			ss.add(now.Add(e.ttl), s)
 			atomic.AddInt64(&e.hits, 1)
 			traceutil.SetMetric(s, exceptionKey, 1)
 		} else {
@@ -140,7 +181,12 @@ func (e *ExceptionSampler) loadSeenSpans(shardSig Signature) *seenSpans {
 	if ok {
 		return s
 	}
-	s = &seenSpans{expires: make(map[spanHash]time.Time), totalSamplerShrinks: &e.shrinks}
+	s = &seenSpans{
+		expires:             make(map[spanHash]time.Time),
+		totalSamplerShrinks: &e.shrinks,
+		cardinalityLimit:    e.cardinalityLimit,
+		signature:           e.signature,
+	}
 	e.mu.Lock()
 	e.seen[shardSig] = s
 	e.mu.Unlock()
@@ -162,6 +208,10 @@ type seenSpans struct {
 	shrunk bool
 	// totalSamplerShrinks is the reference to the total number of shrinks reported by ExceptionSampler.
 	totalSamplerShrinks *int64
+	// cardinalityLimit limits the number of spans recorded.
+	cardinalityLimit int
+	// signature lists the attributes making the signatures of the spans.
+	signature []string
 }
 
 func (ss *seenSpans) add(expire time.Time, s *pb.Span) {
@@ -176,7 +226,7 @@ func (ss *seenSpans) add(expire time.Time, s *pb.Span) {
 
 	// if cardinality limit reached, shrink
 	size := len(ss.expires)
-	if size > cardinalityLimit {
+	if size > ss.cardinalityLimit {
 		ss.shrink()
 	}
 	ss.mu.Unlock()
@@ -187,9 +237,9 @@ func (ss *seenSpans) add(expire time.Time, s *pb.Span) {
 // all sampling tokens. The cardinality limit matches a backend limit.
 // This function is not thread safe and should be called between locks
 func (ss *seenSpans) shrink() {
-	newExpires := make(map[spanHash]time.Time, cardinalityLimit)
+	newExpires := make(map[spanHash]time.Time, ss.cardinalityLimit)
 	for h, expire := range ss.expires {
-		newExpires[h%spanHash(cardinalityLimit)] = expire
+		newExpires[h%spanHash(ss.cardinalityLimit)] = expire
 	}
 	ss.expires = newExpires
 	ss.shrunk = true
@@ -204,9 +254,28 @@ func (ss *seenSpans) getExpire(h spanHash) (time.Time, bool) {
 }
 
 func (ss *seenSpans) sign(s *pb.Span) spanHash {
-	h := computeSpanHash(s, "", true)
+	h := computeSignatureHash(s, ss.signature)
 	if ss.shrunk {
-		h = h % spanHash(cardinalityLimit)
+		h = h % spanHash(ss.cardinalityLimit)
 	}
 	return h
 }
+
+// computeSignatureHash returns the hash of the service, operation name and error of a span,
+// and of the given attributes: "resource" or the keys of meta tags.
+func computeSignatureHash(span *pb.Span, attributes []string) spanHash {
+	h := new32a()
+	h.Write([]byte(span.Service))
+	h.Write([]byte(span.Name))
+	h.WriteChar(byte(span.Error))
+	for _, attr := range attributes {
+		if attr == "resource" {
+			h.Write([]byte(span.Resource))
+			continue
+		}
+		if v, ok := traceutil.GetMeta(span, attr); ok {
+			h.Write([]byte(v))
+		}
+	}
+	return spanHash(h.Sum32())
+}
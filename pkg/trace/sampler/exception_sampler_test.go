@@ -27,7 +27,7 @@ func TestSpanSeenTTLExpiration(t *testing.T) {
 		{"p0-ttl-expired", true, testTime.Add(priorityTTL + defaultTTL + 2*time.Nanosecond), map[string]float64{"_dd.measured": 1}},
 	}
 
-	e := NewExceptionSampler()
+	e := NewExceptionSampler(ExceptionSamplerConfig{})
 	e.Stop()
 
 	for _, tc := range testCases {
@@ -56,7 +56,7 @@ func TestConsideredSpans(t *testing.T) {
 		{"p0-non-top-non-measured-blocked", false, "s4", nil},
 	}
 
-	e := NewExceptionSampler()
+	e := NewExceptionSampler(ExceptionSamplerConfig{})
 	e.Stop()
 
 	for _, tc := range testCases {
@@ -71,7 +71,7 @@ func TestConsideredSpans(t *testing.T) {
 }
 
 func TestExceptionSamplerRace(t *testing.T) {
-	e := NewExceptionSampler()
+	e := NewExceptionSampler(ExceptionSamplerConfig{})
 	e.Stop()
 	for i := 0; i < 2; i++ {
 		go func() {
@@ -87,7 +87,7 @@ func TestExceptionSamplerRace(t *testing.T) {
 
 func TestCardinalityLimit(t *testing.T) {
 	assert := assert.New(t)
-	e := NewExceptionSampler()
+	e := NewExceptionSampler(ExceptionSamplerConfig{})
 	e.Stop()
 	for j := 1; j <= cardinalityLimit; j++ {
 		tr := pb.Trace{
@@ -108,3 +108,50 @@ func TestCardinalityLimit(t *testing.T) {
 		assert.True(len(set.expires) <= cardinalityLimit)
 	}
 }
+
+func TestExceptionSamplerConfig(t *testing.T) {
+	assert := assert.New(t)
+	e := NewExceptionSampler(ExceptionSamplerConfig{
+		TTL:              time.Minute,
+		PriorityTTL:      time.Minute,
+		CardinalityLimit: 2,
+		Signature:        []string{"customer.tier"},
+	})
+	e.Stop()
+
+	now := time.Unix(13829192398, 0)
+	span := func(resource, tier string) pb.Trace {
+		return pb.Trace{&pb.Span{
+			Service:  "s1",
+			Resource: resource,
+			Meta:     map[string]string{"customer.tier": tier},
+			Metrics:  map[string]float64{"_top_level": 1},
+		}}
+	}
+	add := func(now time.Time, tr pb.Trace) bool { return e.add(now, "", tr[0], tr) }
+
+	// the resource is not part of the signature
+	assert.True(add(now, span("r1", "gold")))
+	assert.False(add(now, span("r2", "gold")))
+	assert.True(add(now, span("r2", "silver")))
+	// sampled again once the TTL is over
+	assert.True(add(now.Add(time.Minute+time.Nanosecond), span("r3", "gold")))
+
+	assert.True(add(now, span("r1", "bronze")))
+	for _, set := range e.seen {
+		assert.True(len(set.expires) <= 2)
+	}
+}
+
+func TestComputeSignatureHash(t *testing.T) {
+	span := &pb.Span{
+		Service:  "s1",
+		Name:     "web.request",
+		Resource: "GET /",
+		Error:    1,
+		Meta:     map[string]string{KeyHTTPStatusCode: "500", KeyErrorType: "timeout"},
+	}
+	// the default signature is the one of the spans of the other samplers
+	assert.Equal(t, computeSpanHash(span, "", true), computeSignatureHash(span, defaultSignature))
+	assert.NotEqual(t, computeSignatureHash(span, defaultSignature), computeSignatureHash(span, []string{"resource"}))
+}
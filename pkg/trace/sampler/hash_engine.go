@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package sampler
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// HashState is the state of a HashEngine.
+type HashState struct {
+	Rate float64
+}
+
+// HashEngine samples the traces at a fixed rate from a hash of their trace ID. Unlike the
+// adaptive engines, its decisions depend on nothing but the trace ID, so that the agents behind
+// a load balancer, each receiving a part of the spans of a trace, all keep or all drop it
+// without relying on the propagation of the sampling priority between the services.
+//
+// The decisions made by users in the tracers are respected.
+type HashEngine struct {
+	rate float64
+	exit chan struct{}
+}
+
+// NewHashEngine returns a HashEngine sampling the traces at the given rate.
+func NewHashEngine(rate float64) *HashEngine {
+	return &HashEngine{
+		rate: rate,
+		exit: make(chan struct{}),
+	}
+}
+
+// Run blocks until the engine is stopped. The engine has no state to maintain.
+func (s *HashEngine) Run() {
+	<-s.exit
+}
+
+// Stop stops the main Run loop.
+func (s *HashEngine) Stop() {
+	close(s.exit)
+}
+
+// Sample returns the sampling decision of the trace, and the rate it was sampled at.
+func (s *HashEngine) Sample(trace pb.Trace, root *pb.Span, env string) (sampled bool, rate float64) {
+	if len(trace) == 0 {
+		return false, 0
+	}
+	if priority, ok := GetSamplingPriority(root); ok {
+		// the decisions of the users are the same in all the agents
+		if priority < 0 {
+			return false, 0
+		}
+		if priority > 1 {
+			return true, 1
+		}
+	}
+	sampled = SampleByRate(root.TraceID, s.rate)
+	if sampled {
+		SetSamplingPriority(root, PriorityAutoKeep)
+	} else {
+		SetSamplingPriority(root, PriorityAutoDrop)
+	}
+	setMetric(root, SamplingPriorityRateKey, s.rate)
+	return sampled, s.rate
+}
+
+// GetState returns the state of the engine, a HashState.
+func (s *HashEngine) GetState() interface{} {
+	return HashState{Rate: s.rate}
+}
+
+// GetType returns the type of the engine.
+func (s *HashEngine) GetType() EngineType {
+	return HashEngineType
+}
@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package sampler
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashEngine(t *testing.T) {
+	assert := assert.New(t)
+	e1, e2 := NewHashEngine(0.3), NewHashEngine(0.3)
+
+	var kept int
+	const n = 10000
+	for i := 0; i < n; i++ {
+		traceID := rand.Uint64()
+		root1 := &pb.Span{TraceID: traceID, Service: "web"}
+		root2 := &pb.Span{TraceID: traceID, Service: "db", Metrics: map[string]float64{KeySamplingPriority: 1}}
+		sampled1, rate := e1.Sample(pb.Trace{root1}, root1, "prod")
+		sampled2, _ := e2.Sample(pb.Trace{root2}, root2, "staging")
+		// the decisions only depend on the trace ID
+		assert.Equal(sampled1, sampled2)
+		assert.Equal(0.3, rate)
+		assert.Equal(0.3, root1.Metrics[SamplingPriorityRateKey])
+		priority, _ := GetSamplingPriority(root1)
+		if sampled1 {
+			kept++
+			assert.Equal(PriorityAutoKeep, priority)
+		} else {
+			assert.Equal(PriorityAutoDrop, priority)
+		}
+	}
+	assert.InEpsilon(0.3*n, kept, 0.1)
+}
+
+func TestHashEngineUserPriority(t *testing.T) {
+	assert := assert.New(t)
+	e := NewHashEngine(0)
+
+	root := &pb.Span{TraceID: 1}
+	SetSamplingPriority(root, PriorityUserKeep)
+	sampled, rate := e.Sample(pb.Trace{root}, root, "")
+	assert.True(sampled)
+	assert.Equal(1.0, rate)
+
+	e = NewHashEngine(1)
+	root = &pb.Span{TraceID: 1}
+	SetSamplingPriority(root, PriorityUserDrop)
+	sampled, _ = e.Sample(pb.Trace{root}, root, "")
+	assert.False(sampled)
+}
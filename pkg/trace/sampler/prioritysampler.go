@@ -42,15 +42,20 @@ type PriorityEngine struct {
 
 	rateByService *RateByService
 	catalog       *serviceKeyCatalog
+	rules         []Rule
+	targetTPS     *targetTPSController // nil unless a target TPS is set
+	byResource    bool                 // whether the rates are keyed by resource
 	exit          chan struct{}
 }
 
-// NewPriorityEngine returns an initialized Sampler
-func NewPriorityEngine(extraRate float64, maxTPS float64, rateByService *RateByService) *PriorityEngine {
+// NewPriorityEngine returns an initialized Sampler. The traces matching rules and whose priority
+// was set automatically by the client are sampled at the rate of the rule instead.
+func NewPriorityEngine(extraRate float64, maxTPS float64, rateByService *RateByService, rules []Rule) *PriorityEngine {
 	s := &PriorityEngine{
 		Sampler:       newSampler(extraRate, maxTPS),
 		rateByService: rateByService,
 		catalog:       newServiceLookup(),
+		rules:         rules,
 		exit:          make(chan struct{}),
 	}
 	s.Sampler.setRateThresholdTo1(prioritySamplingRateThresholdTo1)
@@ -58,6 +63,21 @@ func NewPriorityEngine(extraRate float64, maxTPS float64, rateByService *RateByS
 	return s
 }
 
+// SetTargetTPS makes the engine adjust the rates sent back to the tracers so that the traces
+// they keep add up to targetTPS, instead of following the signature scores. The target is
+// lowered by the rate returned by pressure, if not nil, when the agent is under CPU or memory
+// pressure. It must be called before Run.
+func (s *PriorityEngine) SetTargetTPS(targetTPS float64, pressure func() float64) {
+	s.targetTPS = newTargetTPSController(targetTPS, pressure)
+}
+
+// SetRatesByResource makes the engine compute a rate for each resource of the services, in
+// addition to the rates of the services, so that the endpoints of a service with very
+// different throughputs are sampled with distinct rates. It must be called before Run.
+func (s *PriorityEngine) SetRatesByResource() {
+	s.byResource = true
+}
+
 // Run runs and block on the Sampler main loop
 func (s *PriorityEngine) Run() {
 	var wg sync.WaitGroup
@@ -75,6 +95,9 @@ func (s *PriorityEngine) Run() {
 		for {
 			select {
 			case <-t.C:
+				if s.targetTPS != nil {
+					s.targetTPS.adjust(s.Sampler.Backend.GetSignatureScores(), s.Sampler.Backend.GetSampledScore())
+				}
 				s.rateByService.SetAll(s.ratesByService())
 			case <-s.exit:
 				wg.Done()
@@ -116,7 +139,24 @@ func (s *PriorityEngine) Sample(trace pb.Trace, root *pb.Span, env string) (samp
 		return sampled, 1
 	}
 
-	signature := s.catalog.register(ServiceSignature{root.Service, env})
+	// The rules take precedence over the priority computed from the agent hints, and the
+	// traces matching them are left out of the feedback loop.
+	if r := matchRule(s.rules, root); r != nil {
+		sampled = SampleByRate(root.TraceID, r.Rate)
+		if sampled {
+			SetSamplingPriority(root, PriorityAutoKeep)
+		} else {
+			SetSamplingPriority(root, PriorityAutoDrop)
+		}
+		root.Metrics[SamplingPriorityRateKey] = r.Rate
+		return sampled, r.Rate
+	}
+
+	svcSig := ServiceSignature{Name: root.Service, Env: env}
+	if s.byResource {
+		svcSig.Resource = root.Resource
+	}
+	signature := s.catalog.register(svcSig)
 
 	// Update sampler state by counting this trace
 	s.Sampler.Backend.CountSignature(signature)
@@ -125,7 +165,7 @@ func (s *PriorityEngine) Sample(trace pb.Trace, root *pb.Span, env string) (samp
 	var ok bool
 	rate, ok = root.Metrics[SamplingPriorityRateKey]
 	if !ok || rate > prioritySamplingRateThresholdTo1 {
-		rate = s.Sampler.GetSignatureSampleRate(signature)
+		rate = s.signatureSampleRate(signature)
 		root.Metrics[SamplingPriorityRateKey] = rate
 	}
 
@@ -146,7 +186,51 @@ func (s *PriorityEngine) GetState() interface{} {
 // ratesByService returns all rates by service, this information is useful for
 // agents to pick the right service rate.
 func (s *PriorityEngine) ratesByService() map[ServiceSignature]float64 {
-	return s.catalog.ratesByService(s.Sampler.GetAllSignatureSampleRates(), s.Sampler.GetDefaultSampleRate())
+	var rates map[ServiceSignature]float64
+	if s.targetTPS != nil {
+		rates = s.catalog.ratesByService(s.targetTPS.getRates())
+	} else {
+		rates = s.catalog.ratesByService(s.Sampler.GetAllSignatureSampleRates(), s.Sampler.GetDefaultSampleRate())
+	}
+	if s.byResource {
+		addServiceRates(rates, s.Sampler.Backend.GetSignatureScores())
+	}
+	return rates
+}
+
+// addServiceRates sets the rate of each service of the rates keyed by resource to the average
+// of the rates of its resources weighted by their throughput, for the tracers which do not
+// look up the rates by resource and for the resources not seen yet.
+func addServiceRates(rates map[ServiceSignature]float64, scores map[Signature]float64) {
+	type serviceScore struct{ sampled, total float64 }
+	services := make(map[ServiceSignature]*serviceScore)
+	for sig, rate := range rates {
+		if sig == (ServiceSignature{}) {
+			continue // the default rate
+		}
+		score := scores[sig.Hash()]
+		svc := ServiceSignature{Name: sig.Name, Env: sig.Env}
+		ss, ok := services[svc]
+		if !ok {
+			ss = &serviceScore{}
+			services[svc] = ss
+		}
+		ss.sampled += rate * score
+		ss.total += score
+	}
+	for svc, ss := range services {
+		if ss.total > 0 {
+			rates[svc] = ss.sampled / ss.total
+		}
+	}
+}
+
+// signatureSampleRate returns the rate of the signature sent back to the tracers.
+func (s *PriorityEngine) signatureSampleRate(signature Signature) float64 {
+	if s.targetTPS != nil {
+		return s.targetTPS.getRate(signature)
+	}
+	return s.Sampler.GetSignatureSampleRate(signature)
 }
 
 // GetType return the type of the sampler engine
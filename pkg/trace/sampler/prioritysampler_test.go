@@ -30,7 +30,7 @@ func getTestPriorityEngine() *PriorityEngine {
 	maxTPS := 0.0
 
 	rateByService := RateByService{}
-	return NewPriorityEngine(extraRate, maxTPS, &rateByService)
+	return NewPriorityEngine(extraRate, maxTPS, &rateByService, nil)
 }
 
 func getTestTraceWithService(t *testing.T, service string, s *PriorityEngine) (pb.Trace, *pb.Span) {
@@ -42,7 +42,7 @@ func getTestTraceWithService(t *testing.T, service string, s *PriorityEngine) (p
 	r := rand.Float64()
 	priority := PriorityAutoDrop
 	rates := s.ratesByService()
-	key := ServiceSignature{trace[0].Service, defaultEnv}
+	key := ServiceSignature{Name: trace[0].Service, Env: defaultEnv}
 	var rate float64
 	if r, ok := rates[key]; ok {
 		rate = r
@@ -156,6 +156,35 @@ func TestPrioritySampleThresholdTo1(t *testing.T) {
 	}
 }
 
+func TestPrioritySampleByResource(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestPriorityEngine()
+	s.SetRatesByResource()
+
+	trace, root := getTestTraceWithService(t, testServiceA, s)
+	root.Resource = "GET /users"
+	s.Sample(trace, root, defaultEnv)
+
+	sig := ServiceSignature{Name: testServiceA, Env: defaultEnv, Resource: "GET /users"}
+	assert.Contains(s.catalog.lookup, sig)
+	rates := s.ratesByService()
+	assert.Contains(rates, sig)
+	assert.Contains(rates, ServiceSignature{Name: testServiceA, Env: defaultEnv})
+}
+
+func TestAddServiceRates(t *testing.T) {
+	assert := assert.New(t)
+	a := ServiceSignature{Name: "web", Env: "prod", Resource: "a"}
+	b := ServiceSignature{Name: "web", Env: "prod", Resource: "b"}
+	rates := map[ServiceSignature]float64{a: 0.1, b: 1, {}: 0.5}
+
+	addServiceRates(rates, map[Signature]float64{a.Hash(): 90, b.Hash(): 10})
+	assert.Len(rates, 4)
+	// the rate of the service is the rate of its traces
+	assert.InDelta((90*0.1+10*1)/100., rates[ServiceSignature{Name: "web", Env: "prod"}], 1e-9)
+	assert.Equal(0.5, rates[ServiceSignature{}])
+}
+
 func TestMaxTPSByService(t *testing.T) {
 	rand.Seed(1)
 	// Test the "effectiveness" of the maxTPS option.
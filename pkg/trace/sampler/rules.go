@@ -0,0 +1,45 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package sampler
+
+import (
+	"regexp"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// Rule sets the sampling rate of the traces whose root span matches it, bypassing the
+// adaptive logic of the engines. It guarantees the retention of low-traffic services
+// which would otherwise compete with the busier ones.
+type Rule struct {
+	// Service and Name match the service and operation name of the root span. Empty values match all.
+	Service string
+	Name    string
+	// Resource matches the resource of the root span. nil matches all.
+	Resource *regexp.Regexp
+	// Rate is the sampling rate of the matching traces.
+	Rate float64
+}
+
+func (r *Rule) match(root *pb.Span) bool {
+	if r.Service != "" && r.Service != root.Service {
+		return false
+	}
+	if r.Name != "" && r.Name != root.Name {
+		return false
+	}
+	return r.Resource == nil || r.Resource.MatchString(root.Resource)
+}
+
+// matchRule returns the first of the rules matching root, or nil if none does.
+func matchRule(rules []Rule, root *pb.Span) *Rule {
+	for i := range rules {
+		if rules[i].match(root) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package sampler
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchRule(t *testing.T) {
+	rules := []Rule{
+		{Service: "billing", Resource: regexp.MustCompile(`^POST /charge`), Rate: 1},
+		{Service: "billing", Name: "http.request", Rate: 0.5},
+		{Name: "db.query", Rate: 0.1},
+	}
+	for _, tt := range []struct {
+		root *pb.Span
+		rate float64 // -1 when no rule matches
+	}{
+		{&pb.Span{Service: "billing", Name: "http.request", Resource: "POST /charge/42"}, 1},
+		{&pb.Span{Service: "billing", Name: "http.request", Resource: "GET /invoices"}, 0.5},
+		{&pb.Span{Service: "billing", Name: "grpc.server", Resource: "GET /invoices"}, -1},
+		{&pb.Span{Service: "users", Name: "db.query", Resource: "SELECT ?"}, 0.1},
+		{&pb.Span{Service: "users", Name: "http.request", Resource: "POST /charge"}, -1},
+	} {
+		r := matchRule(rules, tt.root)
+		if tt.rate < 0 {
+			assert.Nil(t, r, tt.root.Resource)
+			continue
+		}
+		if assert.NotNil(t, r, tt.root.Resource) {
+			assert.Equal(t, tt.rate, r.Rate, tt.root.Resource)
+		}
+	}
+	assert.Nil(t, matchRule(nil, &pb.Span{Service: "billing"}))
+}
+
+func TestScoreEngineRules(t *testing.T) {
+	assert := assert.New(t)
+	// a maxTPS low enough to drop most traces
+	s := NewScoreEngine(1, 0.001, []Rule{{Service: "mcnulty", Rate: 1}})
+	for i := 0; i < 100; i++ {
+		trace, root := getTestTrace()
+		sampled, rate := s.Sample(trace, root, defaultEnv)
+		assert.True(sampled)
+		assert.Equal(1.0, rate)
+	}
+	assert.Equal(0.0, s.Sampler.Backend.GetTotalScore(), "the traces matching a rule should *NOT* impact sampler backend")
+
+	s = NewScoreEngine(1, 0, []Rule{{Service: "mcnulty", Rate: 0}})
+	trace, root := getTestTrace()
+	sampled, rate := s.Sample(trace, root, defaultEnv)
+	assert.False(sampled)
+	assert.Equal(0.0, rate)
+}
+
+func TestPriorityEngineRules(t *testing.T) {
+	assert := assert.New(t)
+	s := NewPriorityEngine(1, 0, &RateByService{}, []Rule{{Service: "mcnulty", Rate: 1}})
+
+	// the traces with an automatic priority are kept
+	trace, root := getTestTrace()
+	SetSamplingPriority(root, PriorityAutoDrop)
+	sampled, rate := s.Sample(trace, root, defaultEnv)
+	assert.True(sampled)
+	assert.Equal(1.0, rate)
+	priority, _ := GetSamplingPriority(root)
+	assert.Equal(PriorityAutoKeep, priority)
+	assert.Equal(1.0, root.Metrics[SamplingPriorityRateKey])
+	assert.Equal(0.0, s.Sampler.Backend.GetTotalScore(), "the traces matching a rule should *NOT* impact sampler backend")
+
+	// the user choice is respected
+	trace, root = getTestTrace()
+	SetSamplingPriority(root, PriorityUserDrop)
+	sampled, _ = s.Sample(trace, root, defaultEnv)
+	assert.False(sampled)
+
+	s = NewPriorityEngine(1, 0, &RateByService{}, []Rule{{Service: "mcnulty", Rate: 0}})
+	trace, root = getTestTrace()
+	SetSamplingPriority(root, PriorityAutoKeep)
+	sampled, _ = s.Sample(trace, root, defaultEnv)
+	assert.False(sampled)
+	priority, _ = GetSamplingPriority(root)
+	assert.Equal(PriorityAutoDrop, priority)
+}
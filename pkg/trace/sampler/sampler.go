@@ -46,6 +46,9 @@ const (
 	// KeyErrorType is the key of the error type in the meta map
 	KeyErrorType = "error.type"
 
+	// KeyErrorFingerprint is the key of the error fingerprint in the meta map
+	KeyErrorFingerprint = "error.fingerprint"
+
 	// KeyHTTPStatusCode is the key of the http status code in the meta map
 	KeyHTTPStatusCode = "http.status_code"
 )
@@ -5,7 +5,10 @@
 
 package sampler
 
-import "github.com/DataDog/datadog-agent/pkg/trace/pb"
+import (
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+)
 
 const (
 	// errorSamplingRateThresholdTo1 defines the maximum allowed sampling rate below 1.
@@ -18,13 +21,25 @@ type ScoreEngine struct {
 	// Sampler is the underlying sampler used by this engine, sharing logic among various engines.
 	Sampler    *Sampler
 	engineType EngineType
+	rules      []Rule
+
+	// maxTPSPerService is the maximum number of traces per second sampled for each service,
+	// on top of the maxTPS limit of the Sampler. 0 disables the limit.
+	maxTPSPerService float64
+	// sampledByService scores the traces sampled by service, nil if maxTPSPerService is 0.
+	sampledByService *MemoryBackend
+	// names describes the signatures scored by the Sampler, for DumpEngine.
+	names *signatureNames
 }
 
-// NewScoreEngine returns an initialized Sampler
-func NewScoreEngine(extraRate float64, maxTPS float64) *ScoreEngine {
+// NewScoreEngine returns an initialized Sampler, sampling the traces matching rules at their
+// rate instead of the adaptive one.
+func NewScoreEngine(extraRate float64, maxTPS float64, rules []Rule) *ScoreEngine {
 	s := &ScoreEngine{
 		Sampler:    newSampler(extraRate, maxTPS),
 		engineType: NormalScoreEngineType,
+		rules:      rules,
+		names:      newSignatureNames(),
 	}
 
 	return s
@@ -32,11 +47,18 @@ func NewScoreEngine(extraRate float64, maxTPS float64) *ScoreEngine {
 
 // NewErrorsEngine returns an initialized Sampler dedicate to errors. It behaves
 // just like the the normal ScoreEngine except for its GetType method (useful
-// for reporting).
-func NewErrorsEngine(extraRate float64, maxTPS float64) *ScoreEngine {
+// for reporting). When maxTPSPerService is not 0, the traces sampled for each service are
+// also limited to maxTPSPerService, so that a service emitting an error storm cannot crowd
+// out the errors of the other services.
+func NewErrorsEngine(extraRate float64, maxTPS float64, maxTPSPerService float64) *ScoreEngine {
 	s := &ScoreEngine{
-		Sampler:    newSampler(extraRate, maxTPS),
-		engineType: ErrorsScoreEngineType,
+		Sampler:          newSampler(extraRate, maxTPS),
+		engineType:       ErrorsScoreEngineType,
+		maxTPSPerService: maxTPSPerService,
+		names:            newSignatureNames(),
+	}
+	if maxTPSPerService > 0 {
+		s.sampledByService = NewMemoryBackend(defaultDecayPeriod, defaultDecayFactor)
 	}
 	s.Sampler.setRateThresholdTo1(errorSamplingRateThresholdTo1)
 
@@ -45,11 +67,20 @@ func NewErrorsEngine(extraRate float64, maxTPS float64) *ScoreEngine {
 
 // Run runs and block on the Sampler main loop
 func (s *ScoreEngine) Run() {
+	if s.sampledByService != nil {
+		go func() {
+			defer watchdog.LogOnPanic()
+			s.sampledByService.Run()
+		}()
+	}
 	s.Sampler.Run()
 }
 
 // Stop stops the main Run loop
 func (s *ScoreEngine) Stop() {
+	if s.sampledByService != nil {
+		s.sampledByService.Stop()
+	}
 	s.Sampler.Stop()
 }
 
@@ -67,7 +98,14 @@ func (s *ScoreEngine) Sample(trace pb.Trace, root *pb.Span, env string) (sampled
 		return false, 0
 	}
 
+	// The traces matching a rule are neither counted in the adaptive rates nor limited
+	// by maxTPS, so that the rules are applied as they are.
+	if r := matchRule(s.rules, root); r != nil {
+		return applySampleRate(root, r.Rate), r.Rate
+	}
+
 	signature := computeSignatureWithRootAndEnv(trace, root, env)
+	s.names.register(signature, root, env)
 
 	// Update sampler state by counting this trace
 	s.Sampler.Backend.CountSignature(signature)
@@ -76,6 +114,12 @@ func (s *ScoreEngine) Sample(trace pb.Trace, root *pb.Span, env string) (sampled
 
 	sampled = applySampleRate(root, rate)
 
+	// The per-service limit is applied first, so that the traces it drops are not counted
+	// in the global score, which would lower the maxTPS rate of the other services.
+	if sampled && s.sampledByService != nil {
+		sampled = s.sampleService(root, env)
+	}
+
 	if sampled {
 		// Count the trace to allow us to check for the maxTPS limit.
 		// It has to happen before the maxTPS sampling.
@@ -92,6 +136,20 @@ func (s *ScoreEngine) Sample(trace pb.Trace, root *pb.Span, env string) (sampled
 	return sampled, rate
 }
 
+// sampleService counts a sampled trace in the score of its service, and tells if it is kept
+// under the maxTPSPerService limit.
+func (s *ScoreEngine) sampleService(root *pb.Span, env string) bool {
+	signature := ServiceSignature{Name: root.Service, Env: env}.Hash()
+	s.sampledByService.CountSignature(signature)
+
+	// Overestimate the real score with the high limit of the backend bias, like maxTPS.
+	currentTPS := s.sampledByService.GetSignatureScore(signature) * defaultDecayFactor
+	if currentTPS <= s.maxTPSPerService {
+		return true
+	}
+	return applySampleRate(root, s.maxTPSPerService/currentTPS)
+}
+
 // GetState collects and return internal statistics and coefficients for indication purposes
 // It returns an interface{}, as other samplers might return other informations.
 func (s *ScoreEngine) GetState() interface{} {
@@ -26,7 +26,7 @@ func getTestScoreEngine() *ScoreEngine {
 	extraRate := 1.0
 	maxTPS := 0.0
 
-	return NewErrorsEngine(extraRate, maxTPS)
+	return NewErrorsEngine(extraRate, maxTPS, 0)
 }
 
 func getTestTrace() (pb.Trace, *pb.Span) {
@@ -122,6 +122,49 @@ func TestMaxTPS(t *testing.T) {
 		0.01+defaultDecayFactor-1)
 }
 
+func TestMaxTPSPerService(t *testing.T) {
+	assert := assert.New(t)
+	maxTPSPerService := 5.0
+	s := NewErrorsEngine(1, 0, maxTPSPerService)
+
+	// one service emits an error storm, the other a few errors
+	tps := map[string]float64{"storm": 100, "quiet": 2}
+	initPeriods := 20
+	periods := 50
+	periodSeconds := defaultDecayPeriod.Seconds()
+	// Set signature score offset high enough not to kick in during the test.
+	s.Sampler.signatureScoreOffset.Store(1000)
+	s.Sampler.signatureScoreFactor.Store(math.Pow(s.Sampler.signatureScoreSlope.Load(), math.Log10(s.Sampler.signatureScoreOffset.Load())))
+
+	sampledCount := map[string]int{}
+	for period := 0; period < initPeriods+periods; period++ {
+		s.Sampler.Backend.(*MemoryBackend).decayScore()
+		s.sampledByService.decayScore()
+		for service, serviceTPS := range tps {
+			for i := 0; i < int(serviceTPS*periodSeconds); i++ {
+				trace, root := getTestTrace()
+				for _, span := range trace {
+					span.Service = service
+				}
+				sampled, _ := s.Sample(trace, root, defaultEnv)
+				if period > initPeriods && sampled {
+					sampledCount[service]++
+				}
+			}
+		}
+	}
+
+	// the error storm is limited to maxTPSPerService
+	stormTPS := float64(sampledCount["storm"]) / (float64(periods) * periodSeconds)
+	assert.True(maxTPSPerService >= stormTPS, stormTPS)
+	assert.InEpsilon(maxTPSPerService, stormTPS, 0.01+defaultDecayFactor-1)
+	// the errors of the other service are all kept
+	assert.Equal(int(tps["quiet"]*periodSeconds)*(periods-1), sampledCount["quiet"])
+	// the traces dropped by the per-service limit are not counted against maxTPS
+	sampledScore := s.Sampler.Backend.(*MemoryBackend).GetUpperSampledScore()
+	assert.True(sampledScore < 2*(maxTPSPerService+tps["quiet"]), sampledScore)
+}
+
 func BenchmarkSampler(b *testing.B) {
 	// Benchmark the resource consumption of many traces sampling
 
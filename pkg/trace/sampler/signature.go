@@ -52,8 +52,9 @@ func computeSignatureWithRootAndEnv(trace pb.Trace, root *pb.Span, env string) S
 	return Signature(traceHash)
 }
 
-// ServiceSignature represents a unique way to identify a service.
-type ServiceSignature struct{ Name, Env string }
+// ServiceSignature represents a unique way to identify a service. Resource is only set
+// when the priority sampler keys its rates by resource.
+type ServiceSignature struct{ Name, Env, Resource string }
 
 // Hash generates the signature of a trace with minimal information such as
 // service and env, this is typically used by distributed sampling based on
@@ -64,10 +65,17 @@ func (s ServiceSignature) Hash() Signature {
 	h.Write([]byte(s.Name))
 	h.WriteChar(',')
 	h.Write([]byte(s.Env))
+	if s.Resource != "" {
+		h.WriteChar(',')
+		h.Write([]byte(s.Resource))
+	}
 	return Signature(h.Sum32())
 }
 
 func (s ServiceSignature) String() string {
+	if s.Resource != "" {
+		return "service:" + s.Name + ",env:" + s.Env + ",resource:" + s.Resource
+	}
 	return "service:" + s.Name + ",env:" + s.Env
 }
 
@@ -88,6 +96,10 @@ func computeSpanHash(span *pb.Span, env string, withResource bool) spanHash {
 	if ok {
 		h.Write([]byte(typ))
 	}
+	fingerprint, ok := traceutil.GetMeta(span, KeyErrorFingerprint)
+	if ok {
+		h.Write([]byte(fingerprint))
+	}
 	return spanHash(h.Sum32())
 }
 
@@ -58,6 +58,21 @@ func TestSignatureDifferentError(t *testing.T) {
 	assert.NotEqual(testComputeSignature(t1), testComputeSignature(t2))
 }
 
+func TestSignatureDifferentErrorFingerprint(t *testing.T) {
+	assert := assert.New(t)
+
+	trace := func(fingerprint string) pb.Trace {
+		return pb.Trace{
+			&pb.Span{TraceID: 101, SpanID: 1011, Service: "x1", Name: "y1", Resource: "z1", Duration: 26965},
+			&pb.Span{TraceID: 101, SpanID: 1012, ParentID: 1011, Service: "x1", Name: "y1", Resource: "z1", Error: 1, Duration: 197884,
+				Meta: map[string]string{KeyErrorType: "ValueError", KeyErrorFingerprint: fingerprint}},
+		}
+	}
+
+	assert.Equal(testComputeSignature(trace("a1b2")), testComputeSignature(trace("a1b2")))
+	assert.NotEqual(testComputeSignature(trace("a1b2")), testComputeSignature(trace("c3d4")))
+}
+
 func TestSignatureDifferentRoot(t *testing.T) {
 	assert := assert.New(t)
 
@@ -103,7 +118,7 @@ func TestSignatureDifference(t *testing.T) {
 func testComputeServiceSignature(trace pb.Trace) Signature {
 	root := traceutil.GetRoot(trace)
 	env := traceutil.GetEnv(trace)
-	return ServiceSignature{root.Service, env}.Hash()
+	return ServiceSignature{Name: root.Service, Env: env}.Hash()
 }
 
 func TestServiceSignatureSimilar(t *testing.T) {
@@ -180,7 +195,7 @@ func BenchmarkServiceSignature_Hash(b *testing.B) {
 	s2 := rand.String(10)
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		ServiceSignature{s1, s2}.Hash()
+		ServiceSignature{Name: s1, Env: s2}.Hash()
 	}
 }
 
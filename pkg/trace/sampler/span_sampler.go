@@ -0,0 +1,110 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package sampler
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// KeySpanSamplingMechanism is the metric key set on the spans kept by single-span sampling.
+	KeySpanSamplingMechanism = "_dd.span_sampling.mechanism"
+	// KeySpanSamplingRuleRate is the metric key holding the rate of the rule which kept a span.
+	KeySpanSamplingRuleRate = "_dd.span_sampling.rule_rate"
+	// KeySpanSamplingMaxPerSecond is the metric key holding the limit of the rule which kept a span.
+	KeySpanSamplingMaxPerSecond = "_dd.span_sampling.max_per_second"
+
+	// spanSamplingMechanism is the value of KeySpanSamplingMechanism identifying single-span sampling.
+	spanSamplingMechanism = 8
+)
+
+// SpanRule sets the rate at which the spans matching it are kept by single-span sampling.
+type SpanRule struct {
+	// Service and Name match the service and operation name of the span. Empty values match all.
+	Service string
+	Name    string
+	// Rate is the sampling rate of the matching spans.
+	Rate float64
+	// MaxPerSecond limits the number of matching spans kept per second. 0 means unlimited.
+	MaxPerSecond float64
+}
+
+func (r *SpanRule) match(span *pb.Span) bool {
+	if r.Service != "" && r.Service != span.Service {
+		return false
+	}
+	return r.Name == "" || r.Name == span.Name
+}
+
+type spanRule struct {
+	SpanRule
+	limiter *rate.Limiter // nil when unlimited
+}
+
+// SpanSampler keeps individual spans of the traces dropped by the samplers, so that the spans
+// which matter the most, such as the ones of a critical operation, are not lost with their traces.
+// The kept spans carry the rate they were sampled at, for the backend to weigh them.
+type SpanSampler struct {
+	rules []spanRule
+}
+
+// NewSpanSampler returns a SpanSampler applying the given rules, in order.
+func NewSpanSampler(rules []SpanRule) *SpanSampler {
+	s := &SpanSampler{rules: make([]spanRule, 0, len(rules))}
+	for _, r := range rules {
+		sr := spanRule{SpanRule: r}
+		if r.MaxPerSecond > 0 {
+			burst := int(r.MaxPerSecond)
+			if burst < 1 {
+				burst = 1
+			}
+			sr.limiter = rate.NewLimiter(rate.Limit(r.MaxPerSecond), burst)
+		}
+		s.rules = append(s.rules, sr)
+	}
+	return s
+}
+
+// Sample returns the spans of the dropped trace t which are kept by single-span sampling: the
+// spans already selected by the tracers, and the ones sampled by the first rule they match.
+func (s *SpanSampler) Sample(t pb.Trace) []*pb.Span {
+	var sampled []*pb.Span
+	for _, span := range t {
+		if _, ok := span.Metrics[KeySpanSamplingMechanism]; ok {
+			// selected by the single-span sampling of the tracer
+			sampled = append(sampled, span)
+			continue
+		}
+		if s.sampleSpan(span) {
+			sampled = append(sampled, span)
+		}
+	}
+	return sampled
+}
+
+// sampleSpan applies the first rule matching span, tagging the span when it is kept.
+func (s *SpanSampler) sampleSpan(span *pb.Span) bool {
+	for i := range s.rules {
+		r := &s.rules[i]
+		if !r.match(span) {
+			continue
+		}
+		if !SampleByRate(span.SpanID, r.Rate) {
+			return false
+		}
+		if r.limiter != nil && !r.limiter.Allow() {
+			return false
+		}
+		setMetric(span, KeySpanSamplingMechanism, spanSamplingMechanism)
+		setMetric(span, KeySpanSamplingRuleRate, r.Rate)
+		if r.MaxPerSecond > 0 {
+			setMetric(span, KeySpanSamplingMaxPerSecond, r.MaxPerSecond)
+		}
+		return true
+	}
+	return false
+}
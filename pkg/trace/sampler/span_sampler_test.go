@@ -0,0 +1,62 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package sampler
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpanSampler(t *testing.T) {
+	s := NewSpanSampler([]SpanRule{
+		{Service: "billing", Name: "charge", Rate: 1},
+		{Name: "db.query", Rate: 0},
+		{Service: "billing", Rate: 1, MaxPerSecond: 2},
+	})
+
+	trace := pb.Trace{
+		{Service: "billing", Name: "http.request", SpanID: 1},
+		{Service: "billing", Name: "charge", SpanID: 2},
+		{Service: "billing", Name: "db.query", SpanID: 3},
+		{Service: "users", Name: "cache", SpanID: 4, Metrics: map[string]float64{KeySpanSamplingMechanism: 8}},
+		{Service: "users", Name: "http.request", SpanID: 5},
+	}
+	sampled := s.Sample(trace)
+	if !assert.Len(t, sampled, 3) {
+		return
+	}
+
+	// matching the limited rule
+	assert.EqualValues(t, 1, sampled[0].SpanID)
+	assert.Equal(t, map[string]float64{
+		KeySpanSamplingMechanism:    8,
+		KeySpanSamplingRuleRate:     1,
+		KeySpanSamplingMaxPerSecond: 2,
+	}, sampled[0].Metrics)
+	// matching the first rule
+	assert.EqualValues(t, 2, sampled[1].SpanID)
+	assert.Equal(t, map[string]float64{
+		KeySpanSamplingMechanism: 8,
+		KeySpanSamplingRuleRate:  1,
+	}, sampled[1].Metrics)
+	// selected by the tracer
+	assert.EqualValues(t, 4, sampled[2].SpanID)
+	assert.Equal(t, map[string]float64{KeySpanSamplingMechanism: 8}, sampled[2].Metrics)
+	// the span matching the rule with a zero rate isn't kept by the next ones
+	assert.Nil(t, trace[2].Metrics)
+}
+
+func TestSpanSamplerMaxPerSecond(t *testing.T) {
+	s := NewSpanSampler([]SpanRule{{Name: "db.query", Rate: 1, MaxPerSecond: 5}})
+	var kept int
+	for i := 0; i < 100; i++ {
+		kept += len(s.Sample(pb.Trace{{Name: "db.query", SpanID: uint64(i + 1)}}))
+	}
+	// the burst of the limiter is its rate, a few more tokens may be added while the loop runs
+	assert.True(t, kept >= 5 && kept < 10, kept)
+}
@@ -0,0 +1,137 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package sampler
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+)
+
+const (
+	// minTargetTPSCorrection and maxTargetTPSCorrection bound the correction applied to the
+	// budget from the feedback of the tracers.
+	minTargetTPSCorrection = 0.1
+	maxTargetTPSCorrection = 10
+)
+
+// targetTPSController adjusts the rates sent back to the tracers so that the traces they keep
+// add up to a target number of traces per second for the whole agent. The budget of traces
+// is split fairly between the services: the services sending less than their share are kept
+// entirely, and the others share what is left.
+//
+// The loop is closed by comparing the traces actually kept by the tracers to the target: the
+// rates are not applied right away, and rules or manual decisions in the tracers keep more or
+// less traces than expected, so the budget is corrected until the target is met. Under CPU or
+// memory pressure, the target is lowered by the rate of the rate limiter of the receiver.
+type targetTPSController struct {
+	targetTPS float64
+	pressure  func() float64 // rate lowering the target under pressure, nil if none
+
+	mu          sync.RWMutex
+	correction  float64 // correction of the budget from the feedback of the tracers
+	binding     bool    // whether the last allocation sampled any service
+	rates       map[Signature]float64
+	defaultRate float64
+}
+
+func newTargetTPSController(targetTPS float64, pressure func() float64) *targetTPSController {
+	return &targetTPSController{
+		targetTPS:   targetTPS,
+		pressure:    pressure,
+		correction:  1,
+		rates:       make(map[Signature]float64),
+		defaultRate: 1,
+	}
+}
+
+// adjust computes the rates from the throughput of each signature, and the traces per second
+// kept by the tracers during the last period.
+func (c *targetTPSController) adjust(tps map[Signature]float64, keptTPS float64) {
+	target := c.targetTPS
+	if c.pressure != nil {
+		if p := c.pressure(); p >= 0 && p < 1 {
+			target *= p
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The correction is only raised while some services are sampled, to avoid accumulating
+	// a correction that would overshoot when the traffic increases.
+	if keptTPS > 0 && (c.binding || keptTPS > target) {
+		// the square root dampens the oscillations due to the delay of the tracers
+		c.correction *= math.Sqrt(target / keptTPS)
+		c.correction = math.Max(minTargetTPSCorrection, math.Min(maxTargetTPSCorrection, c.correction))
+	}
+	budget := target * c.correction
+	c.rates, c.defaultRate, c.binding = allocateRates(tps, budget)
+
+	metrics.Gauge("datadog.trace_agent.sampler.target_tps.target", target, nil, 1)
+	metrics.Gauge("datadog.trace_agent.sampler.target_tps.budget", budget, nil, 1)
+	metrics.Gauge("datadog.trace_agent.sampler.target_tps.kept", keptTPS, nil, 1)
+}
+
+// getRates returns the rate of each signature, and the rate of the signatures not seen yet.
+func (c *targetTPSController) getRates() (map[Signature]float64, float64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	rates := make(map[Signature]float64, len(c.rates))
+	for sig, rate := range c.rates {
+		rates[sig] = rate
+	}
+	return rates, c.defaultRate
+}
+
+// getRate returns the rate of the signature.
+func (c *targetTPSController) getRate(signature Signature) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if rate, ok := c.rates[signature]; ok {
+		return rate
+	}
+	return c.defaultRate
+}
+
+// allocateRates splits a budget of traces per second between signatures given their
+// throughput: the signatures below the fair share are kept entirely, and the ones above are
+// sampled down to the share left. It returns the rates by signature, the rate of the
+// signatures not seen yet and whether any signature is sampled.
+func allocateRates(tps map[Signature]float64, budget float64) (rates map[Signature]float64, defaultRate float64, binding bool) {
+	sigs := make([]Signature, 0, len(tps))
+	total := 0.0
+	for sig, t := range tps {
+		sigs = append(sigs, sig)
+		total += t
+	}
+	sort.Slice(sigs, func(i, j int) bool { return tps[sigs[i]] < tps[sigs[j]] })
+
+	rates = make(map[Signature]float64, len(sigs))
+	left := budget
+	for i, sig := range sigs {
+		share := left / float64(len(sigs)-i)
+		if tps[sig] <= share {
+			rates[sig] = 1
+			left -= tps[sig]
+			continue
+		}
+		// this signature and the following ones, sending more, are all sampled down to the share
+		for _, sig := range sigs[i:] {
+			rates[sig] = share / tps[sig]
+		}
+		binding = true
+		break
+	}
+
+	defaultRate = 1
+	if total > budget {
+		defaultRate = budget / total
+	}
+	return rates, defaultRate, binding
+}
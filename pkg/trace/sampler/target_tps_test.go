@@ -0,0 +1,110 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package sampler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocateRates(t *testing.T) {
+	t.Run("below", func(t *testing.T) {
+		assert := assert.New(t)
+		rates, defaultRate, binding := allocateRates(map[Signature]float64{1: 2, 2: 3}, 10)
+		assert.Equal(map[Signature]float64{1: 1, 2: 1}, rates)
+		assert.Equal(1., defaultRate)
+		assert.False(binding)
+	})
+
+	t.Run("fair", func(t *testing.T) {
+		assert := assert.New(t)
+		tps := map[Signature]float64{1: 2, 2: 20, 3: 100}
+		rates, defaultRate, binding := allocateRates(tps, 30)
+		assert.True(binding)
+		// the small service is kept entirely, the others share what is left
+		assert.Equal(1., rates[1])
+		assert.InDelta(14./20, rates[2], 1e-9)
+		assert.InDelta(14./100, rates[3], 1e-9)
+		kept := 0.0
+		for sig, t := range tps {
+			kept += t * rates[sig]
+		}
+		assert.InDelta(30, kept, 1e-9)
+		assert.InDelta(30./122, defaultRate, 1e-9)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		assert := assert.New(t)
+		rates, defaultRate, binding := allocateRates(nil, 10)
+		assert.Len(rates, 0)
+		assert.Equal(1., defaultRate)
+		assert.False(binding)
+	})
+}
+
+func TestTargetTPSController(t *testing.T) {
+	tps := map[Signature]float64{1: 50, 2: 50}
+
+	t.Run("feedback", func(t *testing.T) {
+		assert := assert.New(t)
+		c := newTargetTPSController(10, nil)
+		c.adjust(tps, 0)
+		assert.Equal(0.1, c.getRate(1))
+
+		// the tracers keep more than the rates allow, the budget is lowered
+		c.adjust(tps, 40)
+		assert.Equal(0.5, c.correction)
+		assert.InDelta(0.05, c.getRate(1), 1e-9)
+
+		// the correction is bounded
+		for i := 0; i < 10; i++ {
+			c.adjust(tps, 1000)
+		}
+		assert.Equal(minTargetTPSCorrection, c.correction)
+		for i := 0; i < 20; i++ {
+			c.adjust(tps, 0.001)
+		}
+		assert.Equal(float64(maxTargetTPSCorrection), c.correction)
+	})
+
+	t.Run("not-binding", func(t *testing.T) {
+		assert := assert.New(t)
+		c := newTargetTPSController(1000, nil)
+		c.adjust(tps, 100)
+		c.adjust(tps, 100)
+		// the correction is not raised while all the traces are kept
+		assert.Equal(1., c.correction)
+		rates, defaultRate := c.getRates()
+		assert.Equal(map[Signature]float64{1: 1, 2: 1}, rates)
+		assert.Equal(1., defaultRate)
+	})
+
+	t.Run("pressure", func(t *testing.T) {
+		assert := assert.New(t)
+		pressure := 0.5
+		c := newTargetTPSController(10, func() float64 { return pressure })
+		c.adjust(tps, 0)
+		assert.Equal(0.05, c.getRate(1))
+		assert.Equal(0.05, c.getRate(3))
+
+		pressure = 1
+		c.adjust(tps, 0)
+		assert.Equal(0.1, c.getRate(1))
+	})
+}
+
+func TestPriorityEngineTargetTPS(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestPriorityEngine()
+	s.SetTargetTPS(10, nil)
+	sig := s.catalog.register(ServiceSignature{Name: testServiceA, Env: defaultEnv})
+	s.targetTPS.adjust(map[Signature]float64{sig: 100}, 0)
+
+	rates := s.ratesByService()
+	assert.Equal(0.1, rates[ServiceSignature{Name: testServiceA, Env: defaultEnv}])
+	assert.Equal(0.1, rates[ServiceSignature{}])
+}
@@ -6,10 +6,16 @@
 package stats
 
 import (
+	"fmt"
+	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -19,10 +25,60 @@ import (
 // units used by the concentrator.
 const defaultBufferLen = 2
 
+// concentratorShard holds the stats buckets of a subset of the grains aggregated by
+// a Concentrator.
+type concentratorShard struct {
+	mu          sync.Mutex
+	buckets     map[bucketKey]*RawBucket // buckets used to aggregate stats per timestamp and endpoint
+	fineBuckets map[bucketKey]*RawBucket // buckets of the services aggregated at the fine resolution
+}
+
+// bucketKey identifies the bucket starting at ts aggregating the stats written to an endpoint,
+// given by endpointKey.
+type bucketKey struct {
+	ts       int64
+	endpoint string
+}
+
+// endpointKey returns the key of the endpoint e chosen by a tracer, or the empty string for the
+// endpoints of the configuration when e is nil.
+func endpointKey(e *config.Endpoint) string {
+	if e == nil {
+		return ""
+	}
+	return e.Host + "|" + e.APIKey
+}
+
+// FineResolution configures the aggregation of the stats of some services in buckets
+// shorter than the ones of the concentrator.
+type FineResolution struct {
+	// Services lists the services aggregated at the fine resolution.
+	Services []string
+	// BucketSize is the duration of the fine buckets in nanoseconds. It must divide the
+	// bucket size of the concentrator.
+	BucketSize int64
+	// MaxGrains caps the number of grains held by the fine buckets not flushed yet. Once
+	// reached, spans are aggregated in the regular buckets until the next flush. There is
+	// no limit when it is 0.
+	MaxGrains int64
+}
+
+// fineResolution is the state of the fine resolution aggregation of a Concentrator.
+type fineResolution struct {
+	bsize     int64
+	services  map[string]struct{}
+	maxGrains int64
+	grains    int64 // atomic, number of grains held by fine buckets
+}
+
 // Concentrator produces time bucketed statistics from a stream of raw traces.
 // https://en.wikipedia.org/wiki/Knelson_concentrator
 // Gets an imperial shitton of traces, and outputs pre-computed data structures
 // allowing to find the gold (stats) amongst the traces.
+//
+// To allow aggregating in parallel, its state is sharded by grain: all the spans
+// aggregated on a given grain are handled by the same shard, and shards are merged
+// when flushing.
 type Concentrator struct {
 	// list of attributes to use for extra aggregation
 	aggregators []string
@@ -30,6 +86,7 @@ type Concentrator struct {
 	bsize int64
 	// Timestamp of the oldest time bucket for which we allow data.
 	// Any ingested stats older than it get added to this bucket.
+	// It is read while holding the lock of any shard, and written while holding all of them.
 	oldestTs int64
 	// bufferLen is the number of 10s stats bucket we keep in memory before flushing them.
 	// It means that we can compute stats only for the last `bufferLen * bsize` and that we
@@ -43,8 +100,12 @@ type Concentrator struct {
 	exit   chan struct{}
 	exitWG *sync.WaitGroup
 
-	buckets map[int64]*RawBucket // buckets used to aggregate stats per timestamp
-	mu      sync.Mutex
+	shards []*concentratorShard
+
+	// fine is set when some services are aggregated at a finer resolution.
+	fine *fineResolution
+	// versions limits the versions the stats of each service are aggregated on.
+	versions *versionLimiter
 }
 
 // NewConcentrator initializes a new concentrator ready to be started
@@ -52,7 +113,7 @@ func NewConcentrator(aggregators []string, bsize int64, out chan []Bucket) *Conc
 	c := Concentrator{
 		aggregators: aggregators,
 		bsize:       bsize,
-		buckets:     make(map[int64]*RawBucket),
+		shards:      make([]*concentratorShard, runtime.NumCPU()),
 		// At start, only allow stats for the current time bucket. Ensure we don't
 		// override buckets which could have been sent before an Agent restart.
 		oldestTs: alignTs(time.Now().UnixNano(), bsize),
@@ -65,10 +126,47 @@ func NewConcentrator(aggregators []string, bsize int64, out chan []Bucket) *Conc
 		exit:   make(chan struct{}),
 		exitWG: &sync.WaitGroup{},
 	}
+	for i := range c.shards {
+		c.shards[i] = &concentratorShard{
+			buckets:     make(map[bucketKey]*RawBucket),
+			fineBuckets: make(map[bucketKey]*RawBucket),
+		}
+	}
 	sort.Strings(c.aggregators)
 	return &c
 }
 
+// SetMaxVersions limits to max the number of versions the stats of each service are aggregated
+// on in each bucket, the spans of the other versions being aggregated without version.
+// 0 means no limit. It must be called before the concentrator is started.
+func (c *Concentrator) SetMaxVersions(max int) {
+	c.versions = newVersionLimiter(max)
+}
+
+// SetFineResolution makes the concentrator aggregate the stats of the given services at a
+// finer resolution. It must be called before the concentrator is started.
+func (c *Concentrator) SetFineResolution(f FineResolution) error {
+	if len(f.Services) == 0 {
+		return nil
+	}
+	if f.BucketSize <= 0 || c.bsize%f.BucketSize != 0 {
+		return fmt.Errorf("fine bucket size %s does not divide bucket size %s", time.Duration(f.BucketSize), time.Duration(c.bsize))
+	}
+	if f.BucketSize == c.bsize {
+		return nil
+	}
+	services := make(map[string]struct{}, len(f.Services))
+	for _, s := range f.Services {
+		services[s] = struct{}{}
+	}
+	c.fine = &fineResolution{
+		bsize:     f.BucketSize,
+		services:  services,
+		maxGrains: f.MaxGrains,
+	}
+	return nil
+}
+
 // Start starts the concentrator.
 func (c *Concentrator) Start() {
 	go func() {
@@ -89,20 +187,31 @@ func (c *Concentrator) Run() {
 
 	log.Debug("Starting concentrator")
 
-	go func() {
-		for {
-			select {
-			case inputs := <-c.In:
-				c.Add(inputs)
+	// aggregate with as many goroutines as there are shards
+	var workers sync.WaitGroup
+	for range c.shards {
+		workers.Add(1)
+		go func() {
+			defer watchdog.LogOnPanic()
+			defer workers.Done()
+			for {
+				select {
+				case inputs := <-c.In:
+					c.Add(inputs)
+				case <-c.exit:
+					return
+				}
 			}
-		}
-	}()
+		}()
+	}
 	for {
 		select {
 		case <-flushTicker.C:
 			c.Out <- c.Flush()
 		case <-c.exit:
 			log.Info("Exiting concentrator, computing remaining stats")
+			// the inputs being added are part of the last flush
+			workers.Wait()
 			c.Out <- c.Flush()
 			return
 		}
@@ -123,40 +232,79 @@ type Input struct {
 	Trace     WeightedTrace
 	Sublayers SublayerMap
 	Env       string
+	// RuntimeID is the ID of the tracer session the trace comes from, added to the grains
+	// of its spans when set.
+	RuntimeID string
+	// Version is the version of the application the trace comes from, given by the version
+	// tag of its root span, added to the grains of its spans when set.
+	Version string
+	// Endpoint specifies the endpoint chosen by the tracer which the stats of the trace are
+	// written to. When nil, they are written to the endpoints of the configuration.
+	Endpoint *config.Endpoint
 }
 
-// Add applies the given input to the concentrator.
+// Add applies the given input to the concentrator. It is safe for concurrent use.
 func (c *Concentrator) Add(inputs []Input) {
-	c.mu.Lock()
 	for i := range inputs {
 		c.addNow(&inputs[i])
 	}
-	c.mu.Unlock()
 }
 
 // addNow adds the given input into the concentrator.
-// Callers must guard!
 func (c *Concentrator) addNow(i *Input) {
 	for _, s := range i.Trace {
 		if !(s.TopLevel || s.Measured) {
 			continue
 		}
 		end := s.Start + s.Duration
-		btime := end - end%c.bsize
+		version := i.Version
+		// the versions are limited in the bucket the span ends in
+		if version != "" && c.versions != nil && !c.versions.allows(alignTs(end, c.bsize), s.Service, version) {
+			version = ""
+		}
+		shard := c.shards[0]
+		if len(c.shards) > 1 {
+			shard = c.shards[grainHash(i.Env, i.RuntimeID, version, s, c.aggregators)%uint32(len(c.shards))]
+		}
+		subs, _ := i.Sublayers[s.Span]
+		ekey := endpointKey(i.Endpoint)
+
+		shard.mu.Lock()
+		if c.fine != nil && c.fine.accepts(s) {
+			btime := end - end%c.fine.bsize
+			if btime < c.oldestTs {
+				btime = c.oldestTs
+			}
+			key := bucketKey{ts: btime, endpoint: ekey}
+			b, ok := shard.fineBuckets[key]
+			if !ok {
+				b = NewRawBucket(btime, c.fine.bsize)
+				b.endpoint = i.Endpoint
+				shard.fineBuckets[key] = b
+			}
+			n := len(b.data)
+			b.handleSpan(s, i.Env, i.RuntimeID, version, c.aggregators, subs)
+			atomic.AddInt64(&c.fine.grains, int64(len(b.data)-n))
+			shard.mu.Unlock()
+			continue
+		}
 
+		btime := end - end%c.bsize
 		// If too far in the past, count in the oldest-allowed time bucket instead.
 		if btime < c.oldestTs {
 			btime = c.oldestTs
 		}
 
-		b, ok := c.buckets[btime]
+		key := bucketKey{ts: btime, endpoint: ekey}
+		b, ok := shard.buckets[key]
 		if !ok {
 			b = NewRawBucket(btime, c.bsize)
-			c.buckets[btime] = b
+			b.endpoint = i.Endpoint
+			shard.buckets[key] = b
 		}
 
-		subs, _ := i.Sublayers[s.Span]
-		b.HandleSpan(s, i.Env, c.aggregators, subs)
+		b.handleSpan(s, i.Env, i.RuntimeID, version, c.aggregators, subs)
+		shard.mu.Unlock()
 	}
 }
 
@@ -166,34 +314,166 @@ func (c *Concentrator) Flush() []Bucket {
 }
 
 func (c *Concentrator) flushNow(now int64) []Bucket {
-	var sb []Bucket
+	// all shards are locked during the flush so that no stats get added to an
+	// already-flushed bucket before oldestTs is updated
+	for _, shard := range c.shards {
+		shard.mu.Lock()
+	}
 
-	c.mu.Lock()
-	for ts, srb := range c.buckets {
-		// Always keep `bufferLen` buckets (default is 2: current + previous one).
-		// This is a trade-off: we accept slightly late traces (clock skew and stuff)
-		// but we delay flushing by at most `bufferLen` buckets.
-		if ts > now-int64(c.bufferLen)*c.bsize {
-			continue
+	flushed := make(map[bucketKey]Bucket)
+	lag := make(map[string]float64)
+	for _, shard := range c.shards {
+		for key, srb := range shard.buckets {
+			// Always keep `bufferLen` buckets (default is 2: current + previous one).
+			// This is a trade-off: we accept slightly late traces (clock skew and stuff)
+			// but we delay flushing by at most `bufferLen` buckets.
+			if key.ts > now-int64(c.bufferLen)*c.bsize {
+				continue
+			}
+			// shards hold distinct grains, merging them is only a matter of
+			// gathering their stats in the same bucket.
+			if b, ok := flushed[key]; ok {
+				srb.exportTo(b)
+			} else {
+				log.Debugf("flushing bucket %d", key.ts)
+				flushed[key] = srb.Export()
+			}
+			srb.addLag(lag, now)
+			delete(shard.buckets, key)
 		}
-		log.Debugf("flushing bucket %d", ts)
-		sb = append(sb, srb.Export())
-		delete(c.buckets, ts)
 	}
 
 	// After flushing, update the oldest timestamp allowed to prevent having stats for
 	// an already-flushed bucket.
 	newOldestTs := alignTs(now, c.bsize) - int64(c.bufferLen-1)*c.bsize
+
+	// Fine buckets are flushed once they end before the oldest timestamp allowed, so that
+	// no stats can be added to them anymore.
+	fineFlushed := make(map[bucketKey]Bucket)
+	if c.fine != nil {
+		for _, shard := range c.shards {
+			for key, srb := range shard.fineBuckets {
+				if key.ts+c.fine.bsize > newOldestTs {
+					continue
+				}
+				if b, ok := fineFlushed[key]; ok {
+					srb.exportTo(b)
+				} else {
+					fineFlushed[key] = srb.Export()
+				}
+				srb.addLag(lag, now)
+				atomic.AddInt64(&c.fine.grains, -int64(len(srb.data)))
+				delete(shard.fineBuckets, key)
+			}
+		}
+	}
 	if newOldestTs > c.oldestTs {
 		log.Debugf("update oldestTs to %d", newOldestTs)
 		c.oldestTs = newOldestTs
 	}
 
-	c.mu.Unlock()
+	for _, shard := range c.shards {
+		shard.mu.Unlock()
+	}
+	if c.versions != nil {
+		c.versions.reset(newOldestTs)
+	}
+
+	reportLag(lag)
 
+	var sb []Bucket
+	for _, b := range flushed {
+		sb = append(sb, b)
+	}
+	for _, b := range fineFlushed {
+		sb = append(sb, b)
+	}
 	return sb
 }
 
+// addLag sets the lag of the services of the bucket, flushed at now, in lag: the time elapsed
+// between the end of their oldest span and the flush, in seconds. The greatest lag is kept
+// for services found in several buckets.
+func (sb *RawBucket) addLag(lag map[string]float64, now int64) {
+	for service, end := range sb.oldestEnds {
+		if l := time.Duration(now - end).Seconds(); l > lag[service] {
+			lag[service] = l
+		}
+	}
+}
+
+// reportLag reports the lag of the stats of each service flushed, telling how long it takes
+// for the stats of a span to be sent.
+func reportLag(lag map[string]float64) {
+	if len(lag) == 0 {
+		return
+	}
+	for service, l := range lag {
+		metrics.Histogram("datadog.trace_agent.stats.lag", l, []string{"service:" + service}, 1)
+	}
+	info.UpdateStatsLag(lag)
+}
+
+// accepts returns whether s is aggregated at the fine resolution.
+func (f *fineResolution) accepts(s *WeightedSpan) bool {
+	if _, ok := f.services[s.Service]; !ok {
+		return false
+	}
+	return f.maxGrains <= 0 || atomic.LoadInt64(&f.grains) < f.maxGrains
+}
+
+// grainHash returns a hash of the values making up the grain s is aggregated on
+// (see assembleGrain), so that all the spans of a grain get the same hash.
+func grainHash(env, runtimeID, version string, s *WeightedSpan, aggregators []string) uint32 {
+	h := newFNV32a()
+	h = h.addString(env)
+	h = h.addString(s.Resource)
+	h = h.addString(s.Service)
+	if runtimeID != "" {
+		h = h.addString(tagRuntimeID)
+		h = h.addString(runtimeID)
+	}
+	if version != "" {
+		h = h.addString(tagVersion)
+		h = h.addString(version)
+	}
+	for _, agg := range aggregators {
+		if agg == "env" || agg == "resource" || agg == "service" {
+			continue
+		}
+		if agg == tagVersion && version != "" {
+			continue // the version of the application takes precedence, see handleSpan
+		}
+		if v, ok := s.Meta[agg]; ok {
+			h = h.addString(agg)
+			h = h.addString(v)
+		}
+	}
+	return uint32(h)
+}
+
+// fnv32a is an allocation-free FNV-1a hash.
+type fnv32a uint32
+
+const (
+	fnv32aOffset = 2166136261
+	fnv32aPrime  = 16777619
+)
+
+func newFNV32a() fnv32a { return fnv32aOffset }
+
+// addString adds str to the hash, followed by a separator so that ("ab", "c") and
+// ("a", "bc") do not collide.
+func (h fnv32a) addString(str string) fnv32a {
+	for i := 0; i < len(str); i++ {
+		h ^= fnv32a(str[i])
+		h *= fnv32aPrime
+	}
+	h ^= 0xff
+	h *= fnv32aPrime
+	return h
+}
+
 // alignTs returns the provided timestamp truncated to the bucket size.
 // It gives us the start time of the time bucket in which such timestamp falls.
 func alignTs(ts int64, bsize int64) int64 {
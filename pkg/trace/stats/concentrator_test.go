@@ -8,9 +8,13 @@ package stats
 import (
 	"fmt"
 	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
 
@@ -589,3 +593,302 @@ func TestConcentratorAdd(t *testing.T) {
 		})
 	}
 }
+
+// TestConcentratorShards tests that stats aggregated concurrently over several shards
+// are merged back in a single bucket per time window.
+func TestConcentratorShards(t *testing.T) {
+	assert := assert.New(t)
+	statsChan := make(chan []Bucket)
+	c := NewConcentrator([]string{}, testBucketInterval, statsChan)
+	c.shards = make([]*concentratorShard, 8)
+	for i := range c.shards {
+		c.shards[i] = &concentratorShard{buckets: make(map[bucketKey]*RawBucket)}
+	}
+
+	now := time.Now().UnixNano()
+	alignedNow := now - now%c.bsize
+
+	var trace pb.Trace
+	for i := 0; i < 20; i++ {
+		trace = append(trace, newMeasuredSpan(uint64(i+1), 0, 100, 0, "query", "A1", fmt.Sprintf("resource%d", i), 0))
+	}
+	traceutil.ComputeTopLevel(trace)
+	wt := NewWeightedTrace(trace, traceutil.GetRoot(trace))
+
+	const workers, iterations = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				c.Add([]Input{{Env: "none", Trace: wt}})
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := c.flushNow(alignedNow + int64(c.bufferLen)*c.bsize)
+	if !assert.Equal(1, len(stats), "We should get exactly 1 Bucket") {
+		t.FailNow()
+	}
+	assert.Equal(alignedNow, stats[0].Start)
+
+	expected := make(map[string]float64)
+	for i := 0; i < 20; i++ {
+		expected[fmt.Sprintf("query|hits|env:none,resource:resource%d,service:A1", i)] = workers * iterations
+		expected[fmt.Sprintf("query|errors|env:none,resource:resource%d,service:A1", i)] = 0
+		expected[fmt.Sprintf("query|duration|env:none,resource:resource%d,service:A1", i)] = 100 * workers * iterations
+	}
+	countValsEq(t, expected, stats[0].Counts)
+	assert.Len(stats[0].Distributions, 20)
+}
+
+func TestConcentratorStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+	statsChan := make(chan []Bucket, 1)
+	c := NewConcentrator([]string{}, testBucketInterval, statsChan)
+	c.Start()
+	c.In <- []Input{}
+	c.Stop()
+	<-statsChan
+
+	// the aggregating goroutines exit along with the concentrator
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func TestGrainHash(t *testing.T) {
+	assert := assert.New(t)
+
+	span := func(resource string, meta map[string]string) *WeightedSpan {
+		return &WeightedSpan{Span: &pb.Span{Service: "A1", Resource: resource, Meta: meta}}
+	}
+	aggregators := []string{"env", "version"}
+
+	h := grainHash("none", "", "", span("resource1", nil), aggregators)
+	assert.Equal(h, grainHash("none", "", "", span("resource1", map[string]string{"other": "tag"}), aggregators))
+	assert.NotEqual(h, grainHash("none", "", "", span("resource2", nil), aggregators))
+	assert.NotEqual(h, grainHash("prod", "", "", span("resource1", nil), aggregators))
+	assert.NotEqual(h, grainHash("none", "", "", span("resource1", map[string]string{"version": "v1"}), aggregators))
+	assert.NotEqual(grainHash("ab", "", "", span("c", nil), nil), grainHash("a", "", "", span("bc", nil), nil))
+	assert.NotEqual(h, grainHash("none", "7c0e2c36-9b3b-4a7b-8a30-2f7f7d1f6b5a", "", span("resource1", nil), aggregators))
+}
+
+func TestConcentratorRuntimeID(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now().UnixNano()
+	c := NewConcentrator([]string{}, testBucketInterval, nil)
+
+	span := &pb.Span{Service: "A1", Name: "query", Resource: "resource1", Start: now, Duration: 1}
+	trace := pb.Trace{span}
+	traceutil.ComputeTopLevel(trace)
+	wt := NewWeightedTrace(trace, span)
+
+	c.Add([]Input{
+		{Trace: wt, Env: "none", RuntimeID: "7c0e2c36-9b3b-4a7b-8a30-2f7f7d1f6b5a"},
+		{Trace: wt, Env: "none"},
+	})
+	stats := c.flushNow(now + int64(c.bufferLen)*testBucketInterval)
+	if !assert.Len(stats, 1) {
+		return
+	}
+	var grains []string
+	for key := range stats[0].Counts {
+		if strings.HasPrefix(key, "query|hits|") {
+			grains = append(grains, strings.TrimPrefix(key, "query|hits|"))
+		}
+	}
+	assert.ElementsMatch([]string{
+		"env:none,resource:resource1,service:A1",
+		"env:none,resource:resource1,service:A1,runtime-id:7c0e2c36-9b3b-4a7b-8a30-2f7f7d1f6b5a",
+	}, grains)
+}
+
+func TestConcentratorEndpoint(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now().UnixNano()
+	c := NewConcentrator([]string{}, testBucketInterval, nil)
+
+	span := &pb.Span{Service: "A1", Name: "query", Resource: "resource1", Start: now, Duration: 1}
+	trace := pb.Trace{span}
+	traceutil.ComputeTopLevel(trace)
+	wt := NewWeightedTrace(trace, span)
+
+	tenant := &config.Endpoint{Host: "https://trace.agent.datadoghq.com", APIKey: "456"}
+	c.Add([]Input{
+		{Trace: wt, Env: "none"},
+		{Trace: wt, Env: "none", Endpoint: tenant},
+		{Trace: wt, Env: "none", Endpoint: &config.Endpoint{Host: tenant.Host, APIKey: tenant.APIKey}},
+	})
+	stats := c.flushNow(now + int64(c.bufferLen)*testBucketInterval)
+	if !assert.Len(stats, 2) {
+		return
+	}
+	hits := make(map[string]float64)
+	for _, b := range stats {
+		hits[endpointKey(b.Endpoint)] = b.Counts["query|hits|env:none,resource:resource1,service:A1"].Value
+	}
+	// the stats written to an endpoint chosen by the tracers are never mixed with the others
+	assert.Equal(map[string]float64{"": 1, endpointKey(tenant): 2}, hits)
+}
+
+func TestConcentratorVersion(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now().UnixNano()
+	c := NewConcentrator([]string{}, testBucketInterval, nil)
+	c.SetMaxVersions(1)
+
+	span := &pb.Span{Service: "A1", Name: "query", Resource: "resource1", Start: now, Duration: 1}
+	trace := pb.Trace{span}
+	traceutil.ComputeTopLevel(trace)
+	wt := NewWeightedTrace(trace, span)
+
+	grains := func(stats []Bucket) []string {
+		var grains []string
+		for _, b := range stats {
+			for key := range b.Counts {
+				if strings.HasPrefix(key, "query|hits|") {
+					grains = append(grains, strings.TrimPrefix(key, "query|hits|"))
+				}
+			}
+		}
+		return grains
+	}
+
+	// the versions over the limit are aggregated without version
+	c.Add([]Input{
+		{Trace: wt, Env: "none", Version: "v1"},
+		{Trace: wt, Env: "none", Version: "v2"},
+		{Trace: wt, Env: "none", Version: "v1"},
+	})
+	stats := c.flushNow(now + int64(c.bufferLen)*testBucketInterval)
+	assert.ElementsMatch([]string{
+		"env:none,resource:resource1,service:A1",
+		"env:none,resource:resource1,service:A1,version:v1",
+	}, grains(stats))
+
+	// in each bucket
+	span.Start += testBucketInterval
+	c.Add([]Input{{Trace: wt, Env: "none", Version: "v2"}})
+	stats = c.flushNow(now + int64(c.bufferLen+1)*testBucketInterval)
+	assert.ElementsMatch([]string{
+		"env:none,resource:resource1,service:A1,version:v2",
+	}, grains(stats))
+
+	// whatever the flushes happening while the bucket is not flushed yet
+	span.Start += testBucketInterval
+	c.Add([]Input{{Trace: wt, Env: "none", Version: "v1"}})
+	c.flushNow(now + int64(c.bufferLen+1)*testBucketInterval)
+	c.Add([]Input{{Trace: wt, Env: "none", Version: "v2"}})
+	stats = c.flushNow(now + int64(c.bufferLen+2)*testBucketInterval)
+	assert.ElementsMatch([]string{
+		"env:none,resource:resource1,service:A1",
+		"env:none,resource:resource1,service:A1,version:v1",
+	}, grains(stats))
+}
+
+func TestConcentratorLag(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now().UnixNano()
+	c := NewConcentrator([]string{}, testBucketInterval, nil)
+
+	for _, span := range []*pb.Span{
+		{Service: "A1", Name: "query", Resource: "resource1", Start: now, Duration: 1},
+		{Service: "A1", Name: "query", Resource: "resource2", Start: now - 10, Duration: 1},
+		{Service: "B1", Name: "query", Resource: "resource1", Start: now + 1e9, Duration: 1},
+	} {
+		trace := pb.Trace{span}
+		traceutil.ComputeTopLevel(trace)
+		c.Add([]Input{{Trace: NewWeightedTrace(trace, span), Env: "none"}})
+	}
+
+	flushTs := now + int64(c.bufferLen)*testBucketInterval
+	lag := make(map[string]float64)
+	for _, shard := range c.shards {
+		for _, b := range shard.buckets {
+			b.addLag(lag, flushTs)
+		}
+	}
+	// the lag of a service is measured from its oldest span
+	assert.InDelta(time.Duration(flushTs-now+9).Seconds(), lag["A1"], 1e-9)
+	assert.InDelta(time.Duration(flushTs-now-1e9-1).Seconds(), lag["B1"], 1e-9)
+}
+
+func TestConcentratorFineResolution(t *testing.T) {
+	fineBucketSize := (500 * time.Millisecond).Nanoseconds()
+
+	add := func(c *Concentrator, end int64, service, resource string) {
+		span := &pb.Span{Service: service, Name: "query", Resource: resource, Start: end - 1, Duration: 1}
+		trace := pb.Trace{span}
+		traceutil.ComputeTopLevel(trace)
+		c.Add([]Input{{Trace: NewWeightedTrace(trace, span), Env: "none"}})
+	}
+	hits := func(b Bucket) map[string]float64 {
+		m := make(map[string]float64)
+		for key, count := range b.Counts {
+			if strings.HasPrefix(key, "query|hits|") {
+				m[strings.TrimPrefix(key, "query|hits|")] = count.Value
+			}
+		}
+		return m
+	}
+	byDuration := func(stats []Bucket) map[int64][]Bucket {
+		m := make(map[int64][]Bucket)
+		for _, b := range stats {
+			m[b.Duration] = append(m[b.Duration], b)
+		}
+		return m
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		c := NewConcentrator([]string{}, testBucketInterval, nil)
+		assert.Error(t, c.SetFineResolution(FineResolution{Services: []string{"A1"}, BucketSize: (300 * time.Millisecond).Nanoseconds()}))
+		assert.Nil(t, c.fine)
+	})
+
+	t.Run("services", func(t *testing.T) {
+		assert := assert.New(t)
+		c := NewConcentrator([]string{}, testBucketInterval, nil)
+		assert.NoError(c.SetFineResolution(FineResolution{Services: []string{"A1"}, BucketSize: fineBucketSize}))
+		alignedNow := alignTs(time.Now().UnixNano(), c.bsize)
+		c.oldestTs = alignedNow
+
+		add(c, alignedNow+100, "A1", "resource1")
+		add(c, alignedNow+fineBucketSize+100, "A1", "resource1")
+		add(c, alignedNow+fineBucketSize+100, "B1", "resource1")
+
+		// fine buckets are flushed with the regular ones
+		assert.Empty(c.flushNow(alignedNow + c.bsize))
+		buckets := byDuration(c.flushNow(alignedNow + int64(c.bufferLen)*c.bsize))
+		if !assert.Len(buckets[c.bsize], 1) || !assert.Len(buckets[fineBucketSize], 2) {
+			return
+		}
+		assert.Equal(map[string]float64{"env:none,resource:resource1,service:B1": 1}, hits(buckets[c.bsize][0]))
+		for _, b := range buckets[fineBucketSize] {
+			assert.True(b.Start == alignedNow || b.Start == alignedNow+fineBucketSize)
+			assert.Equal(map[string]float64{"env:none,resource:resource1,service:A1": 1}, hits(b))
+		}
+		assert.EqualValues(0, c.fine.grains)
+	})
+
+	t.Run("max-grains", func(t *testing.T) {
+		assert := assert.New(t)
+		c := NewConcentrator([]string{}, testBucketInterval, nil)
+		assert.NoError(c.SetFineResolution(FineResolution{Services: []string{"A1"}, BucketSize: fineBucketSize, MaxGrains: 1}))
+		alignedNow := alignTs(time.Now().UnixNano(), c.bsize)
+		c.oldestTs = alignedNow
+
+		add(c, alignedNow+100, "A1", "resource1")
+		add(c, alignedNow+100, "A1", "resource2")
+
+		buckets := byDuration(c.flushNow(alignedNow + int64(c.bufferLen)*c.bsize))
+		if !assert.Len(buckets[c.bsize], 1) || !assert.Len(buckets[fineBucketSize], 1) {
+			return
+		}
+		assert.Equal(map[string]float64{"env:none,resource:resource1,service:A1": 1}, hits(buckets[fineBucketSize][0]))
+		assert.Equal(map[string]float64{"env:none,resource:resource2,service:A1": 1}, hits(buckets[c.bsize][0]))
+	})
+}
@@ -8,6 +8,7 @@ package stats
 import (
 	"fmt"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/stats/quantile"
 )
 
@@ -142,6 +143,10 @@ type Bucket struct {
 	Counts           map[string]Count        // All the counts
 	Distributions    map[string]Distribution // All the distributions (e.g.: for quantile queries)
 	ErrDistributions map[string]Distribution // All the error distributions (e.g.: for apdex, as they account for frustrated)
+
+	// Endpoint specifies the endpoint chosen by the tracers which the bucket is written to.
+	// When nil, it is written to the endpoints of the configuration.
+	Endpoint *config.Endpoint `json:"-"`
 }
 
 // NewBucket opens a new bucket for time ts and initializes it properly
@@ -9,6 +9,8 @@ import (
 	"bytes"
 	"sort"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/DataDog/datadog-agent/pkg/trace/stats/quantile"
 )
 
@@ -67,13 +69,18 @@ type statsSubKey struct {
 type RawBucket struct {
 	// This should really have no public fields. At all.
 
-	start    int64 // timestamp of start in our format
-	duration int64 // duration of a bucket in nanoseconds
+	start    int64            // timestamp of start in our format
+	duration int64            // duration of a bucket in nanoseconds
+	endpoint *config.Endpoint // endpoint chosen by the tracers, nil for the configured endpoints
 
 	// this should really remain private as it's subject to refactoring
 	data         map[statsKey]groupedStats
 	sublayerData map[statsSubKey]sublayerStats
 
+	// oldestEnds holds the end of the oldest span of each service, in nanoseconds, from
+	// which the lag of the stats is measured when the bucket is flushed
+	oldestEnds map[string]int64
+
 	// internal buffer for aggregate strings - not threadsafe
 	keyBuf bytes.Buffer
 }
@@ -86,6 +93,7 @@ func NewRawBucket(ts, d int64) *RawBucket {
 		duration:     d,
 		data:         make(map[statsKey]groupedStats),
 		sublayerData: make(map[statsSubKey]sublayerStats),
+		oldestEnds:   make(map[string]int64),
 	}
 }
 
@@ -94,6 +102,13 @@ func NewRawBucket(ts, d int64) *RawBucket {
 // type while Bucket is the public, shared one.
 func (sb *RawBucket) Export() Bucket {
 	ret := NewBucket(sb.start, sb.duration)
+	ret.Endpoint = sb.endpoint
+	sb.exportTo(ret)
+	return ret
+}
+
+// exportTo adds the stats of the RawBucket to ret.
+func (sb *RawBucket) exportTo(ret Bucket) {
 	for k, v := range sb.data {
 		hitsKey := GrainKey(k.name, HITS, k.aggr)
 		ret.Counts[hitsKey] = Count{
@@ -150,9 +165,12 @@ func (sb *RawBucket) Export() Bucket {
 			Value:    float64(v.value),
 		}
 	}
-	return ret
 }
 
+// tagRuntimeID is the tag holding the ID of the tracer session in the grains of the spans
+// it sent.
+const tagRuntimeID = "runtime-id"
+
 func assembleGrain(b *bytes.Buffer, env, resource, service string, m map[string]string) (string, TagSet) {
 	b.Reset()
 
@@ -191,10 +209,20 @@ func assembleGrain(b *bytes.Buffer, env, resource, service string, m map[string]
 
 // HandleSpan adds the span to this bucket stats, aggregated with the finest grain matching given aggregators
 func (sb *RawBucket) HandleSpan(s *WeightedSpan, env string, aggregators []string, sublayers []SublayerValue) {
+	sb.handleSpan(s, env, "", "", aggregators, sublayers)
+}
+
+// handleSpan works like HandleSpan, additionally aggregating on the runtime ID and on the
+// version of the application when they are set.
+func (sb *RawBucket) handleSpan(s *WeightedSpan, env, runtimeID, version string, aggregators []string, sublayers []SublayerValue) {
 	if env == "" {
 		panic("env should never be empty")
 	}
 
+	if end, ok := sb.oldestEnds[s.Service]; !ok || s.Start+s.Duration < end {
+		sb.oldestEnds[s.Service] = s.Start + s.Duration
+	}
+
 	m := make(map[string]string)
 
 	for _, agg := range aggregators {
@@ -204,6 +232,12 @@ func (sb *RawBucket) HandleSpan(s *WeightedSpan, env string, aggregators []strin
 			}
 		}
 	}
+	if runtimeID != "" {
+		m[tagRuntimeID] = runtimeID
+	}
+	if version != "" {
+		m[tagVersion] = version
+	}
 
 	grain, tags := assembleGrain(&sb.keyBuf, env, s.Resource, s.Service, m)
 	sb.add(s, grain, tags)
@@ -219,7 +253,10 @@ func (sb *RawBucket) add(s *WeightedSpan, aggr string, tags TagSet) {
 
 	key := statsKey{name: s.Name, aggr: aggr}
 	if gs, ok = sb.data[key]; !ok {
-		gs = newGroupedStats(tags)
+		// the key outlives the payload that s belongs to, make sure it
+		// does not reference its memory (see pb.CopyString)
+		key.name = pb.CopyString(key.name)
+		gs = newGroupedStats(tags.copy())
 	}
 
 	if s.TopLevel {
@@ -259,7 +296,9 @@ func (sb *RawBucket) addSublayer(s *WeightedSpan, aggr string, tags TagSet, sub
 
 	key := statsSubKey{name: s.Name, metric: sub.Metric, tag: sub.Tag, aggr: aggr}
 	if ss, ok = sb.sublayerData[key]; !ok {
-		ss = newSublayerStats(subTags)
+		key.name = pb.CopyString(key.name)
+		key.tag = sub.Tag.copy()
+		ss = newSublayerStats(subTags.copy())
 	}
 
 	if s.TopLevel {
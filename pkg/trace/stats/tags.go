@@ -8,6 +8,8 @@ package stats
 import (
 	"sort"
 	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 )
 
 // Tag represents a key / value dimension on traces and stats.
@@ -21,6 +23,11 @@ func (t Tag) String() string {
 	return t.Name + ":" + t.Value
 }
 
+// copy returns a copy of the tag which does not share memory with the original.
+func (t Tag) copy() Tag {
+	return Tag{Name: pb.CopyString(t.Name), Value: pb.CopyString(t.Value)}
+}
+
 // SplitTag splits the tag into group and value. If it doesn't have a separator
 // the empty string will be used for the group.
 func SplitTag(tag string) (group, value string) {
@@ -72,6 +79,16 @@ func (t TagSet) TagKey(m string) string {
 	return m + "|" + strings.Join(tagStrings, ",")
 }
 
+// copy returns a deep copy of the tag set, with none of its strings sharing
+// memory with the original.
+func (t TagSet) copy() TagSet {
+	cp := make(TagSet, len(t))
+	for i, tag := range t {
+		cp[i] = tag.copy()
+	}
+	return cp
+}
+
 func (t TagSet) Len() int      { return len(t) }
 func (t TagSet) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
 func (t TagSet) Less(i, j int) bool {
@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// tagVersion is the tag holding the version of the application in the grains of the spans
+// of its traces.
+const tagVersion = "version"
+
+// versionLimiter bounds the number of versions the stats of each service are aggregated on
+// in each bucket, so that an application setting a new version on every deployment, or worse
+// on every run, can not blow up the cardinality of the stats. The spans of the versions over
+// the limit are aggregated without a version.
+type versionLimiter struct {
+	max int
+
+	mu       sync.RWMutex
+	versions map[int64]map[string]map[string]struct{} // versions by service, by bucket timestamp
+	overflow int64                                    // spans aggregated without their version
+}
+
+func newVersionLimiter(max int) *versionLimiter {
+	return &versionLimiter{
+		max:      max,
+		versions: make(map[int64]map[string]map[string]struct{}),
+	}
+}
+
+// allows reports whether the spans of service can be aggregated on version in the bucket
+// starting at ts.
+func (l *versionLimiter) allows(ts int64, service, version string) bool {
+	if l.max <= 0 {
+		return true
+	}
+	l.mu.RLock()
+	_, ok := l.versions[ts][service][version]
+	l.mu.RUnlock()
+	if ok {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	services, ok := l.versions[ts]
+	if !ok {
+		services = make(map[string]map[string]struct{})
+		l.versions[ts] = services
+	}
+	versions, ok := services[service]
+	if !ok {
+		versions = make(map[string]struct{})
+		services[pb.CopyString(service)] = versions
+	}
+	if _, ok := versions[version]; ok {
+		return true
+	}
+	if len(versions) >= l.max {
+		atomic.AddInt64(&l.overflow, 1)
+		return false
+	}
+	// the keys outlive the payload they come from (see pb.CopyString)
+	versions[pb.CopyString(version)] = struct{}{}
+	return true
+}
+
+// reset forgets the versions seen in the buckets starting before the given timestamp, which
+// were flushed, and reports the number of spans aggregated without their version since the
+// last reset.
+func (l *versionLimiter) reset(before int64) {
+	l.mu.Lock()
+	for ts := range l.versions {
+		if ts < before {
+			delete(l.versions, ts)
+		}
+	}
+	l.mu.Unlock()
+	if n := atomic.SwapInt64(&l.overflow, 0); n > 0 {
+		metrics.Count("datadog.trace_agent.stats.version_overflow", n, nil, 1)
+	}
+}
@@ -10,8 +10,8 @@ import (
 	"testing"
 	"time"
 
-	"github.com/DataDog/datadog-agent/pkg/trace/agent"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb/validate"
 	"github.com/DataDog/datadog-agent/pkg/trace/test"
 )
 
@@ -35,7 +35,7 @@ func TestSublayerDurations(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer r.KillAgent()
-	baseStart := agent.Year2000NanosecTS
+	baseStart := validate.Year2000NanosecTS
 	span := func(id, parentId uint64, service, spanType string, start, duration int64) *pb.Span {
 		return &pb.Span{
 			Name:     "foo",
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb/validate"
 	"github.com/DataDog/datadog-agent/pkg/trace/stats"
 	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
 )
@@ -261,9 +262,13 @@ func RandomSpanName() string {
 	return stringRandomChoice(names)
 }
 
-// RandomSpanID generates a random span ID
+// RandomSpanID generates a random span ID, never zero
 func RandomSpanID() uint64 {
-	return uint64(rand.Int63())
+	for {
+		if id := uint64(rand.Int63()); validate.SpanID(id) == nil {
+			return id
+		}
+	}
 }
 
 // RandomSpanStart generates a span start timestamp
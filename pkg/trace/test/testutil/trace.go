@@ -115,3 +115,12 @@ func GetTestTraces(traceN, size int, realisticIDs bool) pb.Traces {
 	}
 	return traces
 }
+
+// TracerPayload returns a tracer payload holding a chunk for each of the given traces.
+func TracerPayload(traces pb.Traces) *pb.TracerPayload {
+	chunks := make([]*pb.TraceChunk, 0, len(traces))
+	for _, trace := range traces {
+		chunks = append(chunks, &pb.TraceChunk{Spans: trace})
+	}
+	return &pb.TracerPayload{Chunks: chunks}
+}
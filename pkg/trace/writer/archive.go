@@ -0,0 +1,272 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package writer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/logutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// maxConcurrentUploads is the maximum number of batches uploaded at the same time by the
+// archive writer. Batches flushed while it is reached are dropped.
+const maxConcurrentUploads = 4
+
+// objectUploader uploads objects to an object store.
+type objectUploader interface {
+	upload(key string, body []byte) error
+}
+
+// s3Uploader is an objectUploader uploading to a bucket using the S3 API.
+type s3Uploader struct {
+	client *s3.S3
+	bucket string
+}
+
+func newS3Uploader(cfg *config.AgentConfig) (*s3Uploader, error) {
+	acfg := cfg.Archive
+	awsCfg := aws.NewConfig().
+		WithRegion(acfg.Region).
+		WithS3ForcePathStyle(acfg.ForcePathStyle).
+		WithHTTPClient(cfg.NewHTTPClient())
+	if acfg.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(acfg.Endpoint)
+	}
+	if acfg.AccessKeyID != "" {
+		awsCfg = awsCfg.WithCredentials(credentials.NewStaticCredentials(acfg.AccessKeyID, acfg.SecretAccessKey, ""))
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Uploader{client: s3.New(sess), bucket: acfg.Bucket}, nil
+}
+
+// upload implements objectUploader.
+func (u *s3Uploader) upload(key string, body []byte) error {
+	_, err := u.client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(u.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/gzip"),
+	})
+	return err
+}
+
+// ArchiveWriter uploads the sampled traces in batches to an object store compatible with
+// the S3 API, in parallel to the TraceWriter, so that they can be kept beyond the retention
+// of Datadog. A batch is a gzip compressed file holding a trace per line, as a JSON array of
+// spans. Its key is partitioned by the date and the hour (UTC) of its upload:
+//
+//	<prefix>/dt=2006-01-02/hour=15/<hostname>-<unix time in ns>.json.gz
+type ArchiveWriter struct {
+	// In receives sampled spans to be archived.
+	In chan *SampledSpans
+
+	hostname string
+	prefix   string
+	uploader objectUploader
+	maxSize  int           // estimated size from which a batch is uploaded
+	tick     time.Duration // flush frequency
+	flushC   chan struct{} // receives when a flush is due
+	stop     chan struct{}
+	uploads  chan struct{} // holds a token per upload in progress
+	wg       sync.WaitGroup
+
+	traces       []pb.Trace // traces buffered
+	bufferedSize int        // estimated buffer size
+
+	stats struct {
+		traces, uploads, bytes, errors, dropped int64
+	}
+	easylog *logutil.ThrottledLogger
+}
+
+// NewArchiveWriter returns a new ArchiveWriter for the given agent configuration, which
+// must have the archive enabled. Its flushes are scheduled by the flusher f.
+func NewArchiveWriter(cfg *config.AgentConfig, f *Flusher) (*ArchiveWriter, error) {
+	uploader, err := newS3Uploader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	w := newArchiveWriter(cfg, uploader)
+	f.schedule(w.tick, func() {
+		w.report()
+		select {
+		case w.flushC <- struct{}{}:
+		default:
+			// a flush is already pending
+		}
+	})
+	log.Infof("Archiving sampled traces to bucket %q", cfg.Archive.Bucket)
+	return w, nil
+}
+
+func newArchiveWriter(cfg *config.AgentConfig, uploader objectUploader) *ArchiveWriter {
+	w := &ArchiveWriter{
+		In:       make(chan *SampledSpans, 1000),
+		hostname: cfg.Hostname,
+		prefix:   cfg.Archive.Prefix,
+		uploader: uploader,
+		maxSize:  cfg.Archive.MaxBatchBytes,
+		tick:     time.Minute,
+		flushC:   make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		uploads:  make(chan struct{}, maxConcurrentUploads),
+		easylog:  logutil.NewThrottled(5, 10*time.Second), // no more than 5 messages every 10 seconds
+	}
+	if s := cfg.Archive.FlushPeriodSeconds; s > 0 {
+		w.tick = time.Duration(s*1000) * time.Millisecond
+	}
+	return w
+}
+
+// Run starts the ArchiveWriter.
+func (w *ArchiveWriter) Run() {
+	defer close(w.stop)
+	for {
+		select {
+		case ss := <-w.In:
+			w.add(ss)
+		case <-w.stop:
+			// drain the input channel before stopping
+		outer:
+			for {
+				select {
+				case ss := <-w.In:
+					w.add(ss)
+				default:
+					break outer
+				}
+			}
+			w.flush(time.Now())
+			return
+		case <-w.flushC:
+			w.flush(time.Now())
+		}
+	}
+}
+
+// Stop stops the ArchiveWriter, uploading the traces it has left.
+func (w *ArchiveWriter) Stop() {
+	w.stop <- struct{}{}
+	<-w.stop
+	w.wg.Wait()
+}
+
+func (w *ArchiveWriter) add(ss *SampledSpans) {
+	for i, chunk := range ss.TracerPayload.GetChunks() {
+		if chunk.DroppedTrace {
+			continue
+		}
+		spans := ss.chunkSpans(i)
+		if len(spans) == 0 {
+			continue
+		}
+		w.traces = append(w.traces, spans)
+		w.bufferedSize += pb.Trace(spans).Msgsize()
+	}
+	if w.maxSize > 0 && w.bufferedSize >= w.maxSize {
+		w.flush(time.Now())
+	}
+}
+
+// objectKey returns the key of the batch uploaded at the given time.
+func (w *ArchiveWriter) objectKey(now time.Time) string {
+	now = now.UTC()
+	return path.Join(
+		w.prefix,
+		"dt="+now.Format("2006-01-02"),
+		"hour="+now.Format("15"),
+		fmt.Sprintf("%s-%d.json.gz", w.hostname, now.UnixNano()),
+	)
+}
+
+func (w *ArchiveWriter) flush(now time.Time) {
+	if len(w.traces) == 0 {
+		return
+	}
+	traces := w.traces
+	w.traces = nil
+	w.bufferedSize = 0
+
+	select {
+	case w.uploads <- struct{}{}:
+	default:
+		atomic.AddInt64(&w.stats.dropped, int64(len(traces)))
+		w.easylog.Warn("Too many archive uploads in progress, %d traces dropped.", len(traces))
+		return
+	}
+	key := w.objectKey(now)
+	w.wg.Add(1)
+	go func() {
+		defer watchdog.LogOnPanic()
+		defer func() {
+			<-w.uploads
+			w.wg.Done()
+		}()
+		body, err := encodeArchive(traces)
+		if err != nil {
+			log.Errorf("Failed to encode the traces archive, %d traces dropped: %v", len(traces), err)
+			atomic.AddInt64(&w.stats.errors, 1)
+			return
+		}
+		if err := w.uploader.upload(key, body); err != nil {
+			w.easylog.Error("Failed to upload the traces archive %s: %v", key, err)
+			atomic.AddInt64(&w.stats.errors, 1)
+			return
+		}
+		log.Debugf("Uploaded %d traces to the archive %s (%d bytes).", len(traces), key, len(body))
+		atomic.AddInt64(&w.stats.traces, int64(len(traces)))
+		atomic.AddInt64(&w.stats.bytes, int64(len(body)))
+		atomic.AddInt64(&w.stats.uploads, 1)
+	}()
+}
+
+// encodeArchive returns the gzip compressed archive of the traces, holding a JSON array of
+// spans per line.
+func encodeArchive(traces []pb.Trace) ([]byte, error) {
+	var buf bytes.Buffer
+	gzipw, err := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(gzipw)
+	for _, t := range traces {
+		if err := enc.Encode(t); err != nil {
+			return nil, err
+		}
+	}
+	if err := gzipw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (w *ArchiveWriter) report() {
+	metrics.Count("datadog.trace_agent.archive_writer.traces", atomic.SwapInt64(&w.stats.traces, 0), nil, 1)
+	metrics.Count("datadog.trace_agent.archive_writer.uploads", atomic.SwapInt64(&w.stats.uploads, 0), nil, 1)
+	metrics.Count("datadog.trace_agent.archive_writer.bytes", atomic.SwapInt64(&w.stats.bytes, 0), nil, 1)
+	metrics.Count("datadog.trace_agent.archive_writer.errors", atomic.SwapInt64(&w.stats.errors, 0), nil, 1)
+	metrics.Count("datadog.trace_agent.archive_writer.dropped", atomic.SwapInt64(&w.stats.dropped, 0), nil, 1)
+}
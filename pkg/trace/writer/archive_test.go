@@ -0,0 +1,140 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+// testUploader is an objectUploader keeping the uploaded objects in memory.
+type testUploader struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	err     error
+}
+
+func (u *testUploader) upload(key string, body []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.err != nil {
+		return u.err
+	}
+	if u.objects == nil {
+		u.objects = make(map[string][]byte)
+	}
+	u.objects[key] = body
+	return nil
+}
+
+// decodeArchive returns the span IDs of each of the traces of the archive body.
+func decodeArchive(t *testing.T, body []byte) [][]uint64 {
+	gzipr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var traces [][]uint64
+	scanner := bufio.NewScanner(gzipr)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var trace pb.Trace
+		if err := json.Unmarshal(scanner.Bytes(), &trace); err != nil {
+			t.Fatal(err)
+		}
+		var ids []uint64
+		for _, span := range trace {
+			ids = append(ids, span.SpanID)
+		}
+		traces = append(traces, ids)
+	}
+	assert.NoError(t, scanner.Err())
+	return traces
+}
+
+func spanIDs(ss *SampledSpans) [][]uint64 {
+	var traces [][]uint64
+	for _, chunk := range ss.TracerPayload.Chunks {
+		var ids []uint64
+		for _, span := range chunk.Spans {
+			ids = append(ids, span.SpanID)
+		}
+		traces = append(traces, ids)
+	}
+	return traces
+}
+
+func TestArchiveWriter(t *testing.T) {
+	cfg := &config.AgentConfig{
+		Hostname: testHostname,
+		Archive:  &config.ArchiveConfig{Bucket: "traces", Prefix: "archive"},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		assert := assert.New(t)
+		uploader := &testUploader{}
+		w := newArchiveWriter(cfg, uploader)
+		go w.Run()
+		s1, s2 := randomSampledSpans(20, 0), randomSampledSpans(10, 0)
+		dropped := randomSampledSpans(5, 0)
+		dropped.TracerPayload.Chunks[0].DroppedTrace = true
+		w.In <- s1
+		w.In <- dropped
+		w.In <- s2
+		w.Stop()
+
+		assert.Len(uploader.objects, 1)
+		for key, body := range uploader.objects {
+			assert.Regexp(`^archive/dt=\d{4}-\d{2}-\d{2}/hour=\d{2}/`+testHostname+`-\d+\.json\.gz$`, key)
+			assert.Equal(append(spanIDs(s1), spanIDs(s2)...), decodeArchive(t, body))
+		}
+		assert.EqualValues(2, w.stats.traces)
+		assert.EqualValues(1, w.stats.uploads)
+	})
+
+	t.Run("max-batch-bytes", func(t *testing.T) {
+		uploader := &testUploader{}
+		s1, s2 := randomSampledSpans(20, 0), randomSampledSpans(10, 0)
+		cfg := *cfg
+		cfg.Archive = &config.ArchiveConfig{MaxBatchBytes: s1.Size}
+		w := newArchiveWriter(&cfg, uploader)
+		go w.Run()
+		w.In <- s1
+		w.In <- s2
+		w.Stop()
+		// the first batch is uploaded when it is full, the second one when stopping
+		assert.EqualValues(t, 2, w.stats.uploads)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		uploader := &testUploader{err: errors.New("access denied")}
+		w := newArchiveWriter(cfg, uploader)
+		go w.Run()
+		w.In <- randomSampledSpans(20, 0)
+		w.Stop()
+		assert.EqualValues(t, 1, w.stats.errors)
+		assert.EqualValues(t, 0, w.stats.uploads)
+	})
+}
+
+func TestArchiveObjectKey(t *testing.T) {
+	cfg := &config.AgentConfig{
+		Hostname: "host",
+		Archive:  &config.ArchiveConfig{Prefix: "traces/"},
+	}
+	w := newArchiveWriter(cfg, &testUploader{})
+	now := time.Date(2020, 10, 2, 15, 4, 5, 6, time.FixedZone("", 3600))
+	assert.Equal(t, "traces/dt=2020-10-02/hour=14/host-1601647445000000006.json.gz", w.objectKey(now))
+}
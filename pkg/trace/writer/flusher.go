@@ -0,0 +1,214 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package writer
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+	httputils "github.com/DataDog/datadog-agent/pkg/util/http"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Flusher holds what the writers sending payloads to the Datadog API have in common: it
+// runs their periodic tasks (flushes, metric reports) from a single timer, and provides
+// the HTTP client and the retry policy used by their senders.
+type Flusher struct {
+	client *httputils.ResetClient
+	retry  retryPolicy
+
+	mu      sync.Mutex
+	tasks   []*flushTask
+	started bool
+
+	exit chan struct{}
+	done chan struct{}
+}
+
+// flushTask is a function called periodically by a Flusher.
+type flushTask struct {
+	period time.Duration
+	next   time.Time
+	fn     func()
+}
+
+// NewFlusher returns a new Flusher for the given agent configuration. It must be started
+// using Start once all the writers are created.
+func NewFlusher(cfg *config.AgentConfig) *Flusher {
+	return &Flusher{
+		client: httputils.NewResetClient(cfg.ConnectionResetInterval, newHTTPClient(cfg)),
+		retry:  defaultRetryPolicy,
+		exit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// newHTTPClient returns a function creating the HTTP clients of the flusher, which count the
+// connections they open by IP address family.
+func newHTTPClient(cfg *config.AgentConfig) func() *http.Client {
+	return func() *http.Client {
+		client := cfg.NewHTTPClient()
+		t, ok := client.Transport.(*http.Transport)
+		if !ok || t.DialContext == nil {
+			return client
+		}
+		dial := t.DialContext
+		t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err == nil {
+				metrics.Count("datadog.trace_agent.writer.connections", 1, []string{"family:" + connFamily(conn)}, 1)
+			}
+			return conn, err
+		}
+		return client
+	}
+}
+
+// connFamily returns the IP address family of the remote address of conn, "ipv4" or "ipv6",
+// or "unknown" if it isn't an IP address.
+func connFamily(conn net.Conn) string {
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	switch {
+	case !ok:
+		return "unknown"
+	case addr.IP.To4() != nil:
+		return "ipv4"
+	default:
+		return "ipv6"
+	}
+}
+
+// schedule calls fn every period once the flusher is started. fn is called from the
+// goroutine of the flusher, it must not block.
+func (f *Flusher) schedule(period time.Duration, fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.started {
+		// the timer period is computed from the tasks when starting
+		log.Errorf("Flusher already started, ignoring task scheduled every %s", period)
+		return
+	}
+	f.tasks = append(f.tasks, &flushTask{period: period, fn: fn})
+}
+
+// tick returns the period of the timer running the tasks, the greatest common divisor
+// of their periods in milliseconds, so that tasks running at the same period or at
+// multiples of each other are run together.
+func (f *Flusher) tick() time.Duration {
+	gcd := func(a, b int64) int64 {
+		for b != 0 {
+			a, b = b, a%b
+		}
+		return a
+	}
+	var ms int64
+	for _, t := range f.tasks {
+		ms = gcd(ms, int64(math.Max(1, float64(t.period/time.Millisecond))))
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// Start starts running the scheduled tasks.
+func (f *Flusher) Start() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.started {
+		return
+	}
+	f.started = true
+	if len(f.tasks) == 0 {
+		close(f.done)
+		return
+	}
+	tick := f.tick()
+	now := time.Now()
+	for _, t := range f.tasks {
+		t.next = now.Add(t.period)
+	}
+	log.Debugf("Starting flusher (tasks=%d tick=%s)", len(f.tasks), tick)
+	go func() {
+		defer watchdog.LogOnPanic()
+		defer close(f.done)
+		f.run(tick)
+	}()
+}
+
+func (f *Flusher) run(tick time.Duration) {
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			for _, task := range f.tasks {
+				if now.Before(task.next) {
+					continue
+				}
+				task.fn()
+				// ticks missed while the task was running are not caught up with
+				for !now.Before(task.next) {
+					task.next = task.next.Add(task.period)
+				}
+			}
+		case <-f.exit:
+			return
+		}
+	}
+}
+
+// Stop stops running the scheduled tasks. The writers are responsible for flushing what
+// they have left when they are stopped.
+func (f *Flusher) Stop() {
+	f.mu.Lock()
+	started := f.started
+	f.mu.Unlock()
+	if !started {
+		return
+	}
+	close(f.exit)
+	<-f.done
+}
+
+// retryPolicy decides how long senders wait before retrying to send payloads.
+type retryPolicy interface {
+	// backoff returns how long to wait before the given retry attempt, attempt 0 being
+	// the first try.
+	backoff(attempt int) time.Duration
+}
+
+// defaultRetryPolicy is the retry policy used by the senders of all writers.
+var defaultRetryPolicy retryPolicy = &exponentialBackoff{
+	base: 100 * time.Millisecond,
+	max:  10 * time.Second,
+}
+
+// exponentialBackoff is a retryPolicy waiting exponentially longer between retries.
+type exponentialBackoff struct {
+	// base specifies the multiplier base for the backoff duration algorithm.
+	base time.Duration
+	// max is the maximum permitted backoff duration.
+	max time.Duration
+}
+
+// backoff implements retryPolicy. The formula is "Full Jitter":
+//   random_between(0, min(cap, base * 2 ** attempt))
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+func (b *exponentialBackoff) backoff(attempt int) time.Duration {
+	if attempt == 0 {
+		return 0
+	}
+	maxPow := float64(b.max / b.base)
+	pow := math.Min(math.Pow(2, float64(attempt)), maxPow)
+	ns := int64(float64(b.base) * pow)
+	return time.Duration(rand.Int63n(ns))
+}
@@ -0,0 +1,82 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package writer
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+)
+
+func TestFlusher(t *testing.T) {
+	t.Run("tick", func(t *testing.T) {
+		f := NewFlusher(&config.AgentConfig{})
+		f.schedule(5*time.Second, func() {})
+		f.schedule(5*time.Second, func() {})
+		f.schedule(10*time.Second, func() {})
+		assert.Equal(t, 5*time.Second, f.tick())
+		f.schedule(1500*time.Millisecond, func() {})
+		assert.Equal(t, 500*time.Millisecond, f.tick())
+	})
+
+	t.Run("run", func(t *testing.T) {
+		f := NewFlusher(&config.AgentConfig{})
+		var fast, slow int64
+		f.schedule(10*time.Millisecond, func() { atomic.AddInt64(&fast, 1) })
+		f.schedule(40*time.Millisecond, func() { atomic.AddInt64(&slow, 1) })
+		f.Start()
+		time.Sleep(200 * time.Millisecond)
+		f.Stop()
+
+		assert.True(t, atomic.LoadInt64(&slow) > 0)
+		assert.True(t, atomic.LoadInt64(&fast) >= 2*atomic.LoadInt64(&slow))
+
+		// tasks are not scheduled once started
+		f.schedule(time.Millisecond, func() { t.Fatal("unexpected call") })
+		assert.Len(t, f.tasks, 2)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		f := NewFlusher(&config.AgentConfig{})
+		f.Start()
+		f.Stop()
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		srv := newTestServer()
+		defer srv.Close()
+		cfg := &config.AgentConfig{
+			Endpoints:   []*config.Endpoint{{Host: srv.URL, APIKey: "123"}},
+			TraceWriter: &config.WriterConfig{ConnectionLimit: 200, QueueSize: 40},
+			StatsWriter: &config.WriterConfig{ConnectionLimit: 20, QueueSize: 20},
+		}
+		f := NewFlusher(cfg)
+		tw := NewTraceWriter(cfg, f)
+		sw := NewStatsWriter(cfg, nil, f)
+		assert.Len(t, f.tasks, 2)
+		assert.Equal(t, 5*time.Second, f.tick())
+		assert.Equal(t, f.client, tw.senders[0].cfg.client)
+		assert.Equal(t, f.client, sw.senders[0].cfg.client)
+		stopSenders(tw.senders)
+		stopSenders(sw.senders)
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := &exponentialBackoff{base: 100 * time.Millisecond, max: 10 * time.Second}
+	assert.Zero(t, b.backoff(0))
+	for attempt := 1; attempt < 20; attempt++ {
+		d := b.backoff(attempt)
+		assert.True(t, d >= 0 && d < b.max, "attempt %d: %s", attempt, d)
+		if attempt < 6 {
+			assert.True(t, d < b.base<<uint(attempt), "attempt %d: %s", attempt, d)
+		}
+	}
+}
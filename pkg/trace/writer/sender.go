@@ -12,7 +12,6 @@ import (
 	"io"
 	"io/ioutil"
 	"math"
-	"math/rand"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -28,22 +27,29 @@ import (
 )
 
 // newSenders returns a list of senders based on the given agent configuration, using climit
-// as the maximum number of concurrent outgoing connections, writing to path.
-func newSenders(cfg *config.AgentConfig, r eventRecorder, path string, climit, qsize int) []*sender {
+// as the maximum number of concurrent outgoing connections, writing to path. The senders
+// use the HTTP client and the retry policy of the flusher f.
+func newSenders(cfg *config.AgentConfig, f *Flusher, r eventRecorder, path string, climit, qsize int) []*sender {
 	if e := cfg.Endpoints; len(e) == 0 || e[0].Host == "" || e[0].APIKey == "" {
 		panic(errors.New("config was not properly validated"))
 	}
-	client := httputils.NewResetClient(cfg.ConnectionResetInterval, cfg.NewHTTPClient)
+	return newEndpointSenders(cfg.Endpoints, f, r, path, climit, qsize)
+}
+
+// newEndpointSenders returns a sender for each of the given endpoints, spreading the maximum
+// number of concurrent outgoing connections climit between them.
+func newEndpointSenders(endpoints []*config.Endpoint, f *Flusher, r eventRecorder, path string, climit, qsize int) []*sender {
 	// spread out the the maximum connection limit (climit) between senders
-	maxConns := math.Max(1, float64(climit/len(cfg.Endpoints)))
-	senders := make([]*sender, len(cfg.Endpoints))
-	for i, endpoint := range cfg.Endpoints {
+	maxConns := math.Max(1, float64(climit/len(endpoints)))
+	senders := make([]*sender, len(endpoints))
+	for i, endpoint := range endpoints {
 		url, err := url.Parse(endpoint.Host + path)
 		if err != nil {
 			osutil.Exitf("Invalid host endpoint: %q", endpoint.Host)
 		}
 		senders[i] = newSender(&senderConfig{
-			client:    client,
+			client:    f.client,
+			retry:     f.retry,
 			maxConns:  int(maxConns),
 			maxQueued: qsize,
 			url:       url,
@@ -117,6 +123,9 @@ type senderConfig struct {
 	url *url.URL
 	// apiKey specifies the Datadog API key to use.
 	apiKey string
+	// retry specifies the policy deciding how long to wait before retrying failed sends.
+	// When nil, defaultRetryPolicy is used.
+	retry retryPolicy
 	// maxConns specifies the maximum number of allowed concurrent ougoing
 	// connections.
 	maxConns int
@@ -167,8 +176,12 @@ func (s *sender) loop() {
 
 // backoff triggers a sleep period proportional to the retry attempt, if any.
 func (s *sender) backoff() {
+	retry := s.cfg.retry
+	if retry == nil {
+		retry = defaultRetryPolicy
+	}
 	attempt := atomic.LoadInt32(&s.attempt)
-	delay := backoffDuration(int(attempt))
+	delay := retry.backoff(int(attempt))
 	if delay == 0 {
 		return
 	}
@@ -421,23 +434,24 @@ func sendPayloads(senders []*sender, p *payload) {
 	}
 }
 
-const (
-	// backoffBase specifies the multiplier base for the backoff duration algorithm.
-	backoffBase = 100 * time.Millisecond
-	// backoffMaxDuration is the maximum permitted backoff duration.
-	backoffMaxDuration = 10 * time.Second
-)
+// maxTenants is the maximum number of endpoints chosen by the tracers which the traces and
+// stats are written to, besides the ones of the configuration.
+const maxTenants = 32
 
-// backoffDuration returns the backoff duration necessary for the given attempt.
-// The formula is "Full Jitter":
-//   random_between(0, min(cap, base * 2 ** attempt))
-// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
-var backoffDuration = func(attempt int) time.Duration {
-	if attempt == 0 {
-		return 0
-	}
-	maxPow := float64(backoffMaxDuration / backoffBase)
-	pow := math.Min(math.Pow(2, float64(attempt)), maxPow)
-	ns := int64(float64(backoffBase) * pow)
-	return time.Duration(rand.Int63n(ns))
+// tenantTTL is the time after which the senders of an endpoint chosen by the tracers are
+// stopped when nothing was written to it.
+const tenantTTL = 10 * time.Minute
+
+// endpointKey returns the key identifying the endpoint e chosen by a tracer.
+func endpointKey(e *config.Endpoint) string {
+	return e.Host + "|" + e.APIKey
+}
+
+// newTenantSenders returns the senders writing to path on the endpoint e chosen by a tracer.
+// The tenants share as many connections and as large a queue as the configured endpoints,
+// given by climit and qsize.
+func newTenantSenders(e *config.Endpoint, f *Flusher, r eventRecorder, path string, climit, qsize int) []*sender {
+	climit = int(math.Max(1, float64(climit/maxTenants)))
+	qsize = int(math.Max(1, float64(qsize/maxTenants)))
+	return newEndpointSenders([]*config.Endpoint{e}, f, r, path, climit, qsize)
 }
@@ -135,14 +135,14 @@ func TestSender(t *testing.T) {
 		assert := assert.New(t)
 		server := newTestServer()
 		defer server.Close()
-		defer func(old func(int) time.Duration) { backoffDuration = old }(backoffDuration)
 		var backoffCalls []int
-		backoffDuration = func(d int) time.Duration {
+		cfg := testSenderConfig(server.URL)
+		cfg.retry = retryFunc(func(d int) time.Duration {
 			backoffCalls = append(backoffCalls, d)
 			return time.Nanosecond
-		}
+		})
 
-		s := newSender(testSenderConfig(server.URL))
+		s := newSender(cfg)
 		s.Push(expectResponses(503, 503, 200))
 		s.Stop()
 
@@ -283,12 +283,18 @@ func TestPayload(t *testing.T) {
 	})
 }
 
-// useBackoffDuration replaces the current backoff duration with d and returns a
-// function which restores it.
+// retryFunc is a retryPolicy calling a function.
+type retryFunc func(attempt int) time.Duration
+
+// backoff implements retryPolicy.
+func (f retryFunc) backoff(attempt int) time.Duration { return f(attempt) }
+
+// useBackoffDuration replaces the default retry policy with one always waiting d and
+// returns a function which restores it.
 func useBackoffDuration(d time.Duration) func() {
-	old := backoffDuration
-	backoffDuration = func(attempt int) time.Duration { return d }
-	return func() { backoffDuration = old }
+	old := defaultRetryPolicy
+	defaultRetryPolicy = retryFunc(func(attempt int) time.Duration { return d })
+	return func() { defaultRetryPolicy = old }
 }
 
 // mockRecorder is a mock eventRecorder which records all calls to recordEvent.
@@ -43,17 +43,30 @@ type StatsWriter struct {
 	stop     chan struct{}
 	stats    *info.StatsWriterInfo
 
+	tenants map[string]*statsTenant // senders of the endpoints chosen by the tracers
+
+	// newTenantSenders returns the senders writing to an endpoint chosen by the tracers.
+	newTenantSenders func(e *config.Endpoint) []*sender
+
 	easylog *logutil.ThrottledLogger
 }
 
-// NewStatsWriter returns a new StatsWriter. It must be started using Run.
-func NewStatsWriter(cfg *config.AgentConfig, in <-chan []stats.Bucket) *StatsWriter {
+// statsTenant holds the senders writing stats to an endpoint chosen by the tracers.
+type statsTenant struct {
+	senders  []*sender
+	lastUsed time.Time // last time stats were written to the endpoint
+}
+
+// NewStatsWriter returns a new StatsWriter. It must be started using Run. Its metrics are
+// reported by the flusher f.
+func NewStatsWriter(cfg *config.AgentConfig, in <-chan []stats.Bucket, f *Flusher) *StatsWriter {
 	sw := &StatsWriter{
 		in:       in,
 		hostname: cfg.Hostname,
 		env:      cfg.DefaultEnv,
 		stats:    &info.StatsWriterInfo{},
 		stop:     make(chan struct{}),
+		tenants:  make(map[string]*statsTenant),
 		easylog:  logutil.NewThrottled(5, 10*time.Second), // no more than 5 messages every 10 seconds
 	}
 	climit := cfg.StatsWriter.ConnectionLimit
@@ -74,21 +87,21 @@ func NewStatsWriter(cfg *config.AgentConfig, in <-chan []stats.Bucket) *StatsWri
 		qsize = int(math.Max(1, maxmem/payloadSize))
 	}
 	log.Debugf("Stats writer initialized (climit=%d qsize=%d)", climit, qsize)
-	sw.senders = newSenders(cfg, sw, pathStats, climit, qsize)
+	sw.senders = newSenders(cfg, f, sw, pathStats, climit, qsize)
+	sw.newTenantSenders = func(e *config.Endpoint) []*sender {
+		return newTenantSenders(e, f, sw, pathStats, climit, qsize)
+	}
+	f.schedule(5*time.Second, sw.report)
 	return sw
 }
 
-// Run starts the StatsWriter, making it ready to receive stats and report metrics.
+// Run starts the StatsWriter, making it ready to receive stats.
 func (w *StatsWriter) Run() {
-	t := time.NewTicker(5 * time.Second)
-	defer t.Stop()
 	defer close(w.stop)
 	for {
 		select {
 		case stats := <-w.in:
 			w.addStats(stats)
-		case <-t.C:
-			w.report()
 		case <-w.stop:
 			return
 		}
@@ -100,9 +113,64 @@ func (w *StatsWriter) Stop() {
 	w.stop <- struct{}{}
 	<-w.stop
 	stopSenders(w.senders)
+	for _, t := range w.tenants {
+		stopSenders(t.senders)
+	}
 }
 
 func (w *StatsWriter) addStats(s []stats.Bucket) {
+	// the buckets are written to the endpoints chosen by the tracers they were computed for
+	var configured []stats.Bucket
+	byTenant := make(map[string][]stats.Bucket)
+	for _, b := range s {
+		if b.Endpoint == nil {
+			configured = append(configured, b)
+		} else {
+			key := endpointKey(b.Endpoint)
+			byTenant[key] = append(byTenant[key], b)
+		}
+	}
+	w.sendStats(w.senders, configured)
+	for _, buckets := range byTenant {
+		if senders := w.sendersFor(buckets[0].Endpoint); senders != nil {
+			w.sendStats(senders, buckets)
+		}
+	}
+	w.evictTenants(time.Now())
+}
+
+// sendersFor returns the senders writing to the endpoint e chosen by a tracer. It returns nil
+// when e is a new endpoint and the maximum number of tenants is reached.
+func (w *StatsWriter) sendersFor(e *config.Endpoint) []*sender {
+	key := endpointKey(e)
+	t, ok := w.tenants[key]
+	if !ok {
+		if len(w.tenants) >= maxTenants {
+			w.easylog.Warn("Too many API keys in use (max %d), dropping the stats written to %s.", maxTenants, e.Host)
+			metrics.Count("datadog.trace_agent.stats_writer.tenants_dropped", 1, nil, 1)
+			return nil
+		}
+		t = &statsTenant{senders: w.newTenantSenders(e)}
+		w.tenants[key] = t
+	}
+	t.lastUsed = time.Now()
+	return t.senders
+}
+
+// evictTenants stops writing to the endpoints chosen by the tracers which no stats were
+// written to for tenantTTL, as of now.
+func (w *StatsWriter) evictTenants(now time.Time) {
+	for key, t := range w.tenants {
+		if now.Sub(t.lastUsed) < tenantTTL {
+			continue
+		}
+		delete(w.tenants, key)
+		go stopSenders(t.senders)
+	}
+}
+
+// sendStats sends the stats buckets s to the given senders.
+func (w *StatsWriter) sendStats(senders []*sender, s []stats.Bucket) {
 	defer timing.Since("datadog.trace_agent.stats_writer.encode_ms", time.Now())
 
 	payloads, bucketCount, entryCount := w.buildPayloads(s, maxEntriesPerPayload)
@@ -127,7 +195,7 @@ func (w *StatsWriter) addStats(s []stats.Bucket) {
 		}
 		atomic.AddInt64(&w.stats.Bytes, int64(req.body.Len()))
 
-		sendPayloads(w.senders, req)
+		sendPayloads(senders, req)
 	}
 }
 
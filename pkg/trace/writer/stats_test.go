@@ -12,6 +12,7 @@ import (
 	"math/rand"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/info"
@@ -59,6 +60,40 @@ func TestStatsWriter(t *testing.T) {
 		assertPayload(assert, expectedHeaders, testSets, srv.Payloads())
 	})
 
+	t.Run("routing", func(t *testing.T) {
+		assert := assert.New(t)
+		sw, statsChannel, srv := testStatsWriter()
+		go sw.Run()
+
+		tenant := testutil.RandomBucket(3)
+		tenant.Endpoint = &config.Endpoint{Host: srv.URL, APIKey: "456"}
+		statsChannel <- []stats.Bucket{testutil.RandomBucket(3), tenant}
+		sw.Stop()
+
+		keys := make(map[string]int)
+		for _, p := range srv.Payloads() {
+			keys[p.headers["Dd-Api-Key"]]++
+		}
+		assert.Equal(map[string]int{"123": 1, "456": 1}, keys)
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		assert := assert.New(t)
+		sw, _, _ := testStatsWriter()
+		defer sw.Stop()
+		go sw.Run()
+
+		e := &config.Endpoint{Host: "https://example.com", APIKey: "456"}
+		senders := sw.sendersFor(e)
+		assert.Len(sw.tenants, 1)
+		assert.Equal(senders, sw.sendersFor(e))
+
+		sw.evictTenants(time.Now())
+		assert.Len(sw.tenants, 1)
+		sw.evictTenants(time.Now().Add(tenantTTL))
+		assert.Len(sw.tenants, 0)
+	})
+
 	t.Run("buildPayloads", func(t *testing.T) {
 		t.Run("ok", func(t *testing.T) {
 			assert := assert.New(t)
@@ -194,7 +229,7 @@ func testStatsWriter() (*StatsWriter, chan []stats.Bucket, *testServer) {
 		Endpoints:   []*config.Endpoint{{Host: srv.URL, APIKey: "123"}},
 		StatsWriter: &config.WriterConfig{ConnectionLimit: 20, QueueSize: 20},
 	}
-	return NewStatsWriter(cfg, in), in, srv
+	return NewStatsWriter(cfg, in, NewFlusher(cfg)), in, srv
 }
 
 func removeDuplicateEntries(stats []stats.Bucket) int {
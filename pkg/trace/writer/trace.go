@@ -8,6 +8,7 @@ package writer
 import (
 	"compress/gzip"
 	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,6 +20,9 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
 	"github.com/DataDog/datadog-agent/pkg/trace/metrics/timing"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb/columnar"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
 	"github.com/gogo/protobuf/proto"
@@ -27,20 +31,91 @@ import (
 // pathTraces is the target host API path for delivering traces.
 const pathTraces = "/api/v0.2/traces"
 
+// tagOrigin is the name of the tag holding the origin of a trace.
+const tagOrigin = "_dd.origin"
+
 // MaxPayloadSize specifies the maximum accumulated payload size that is allowed before
 // a flush is triggered; replaced in tests.
 var MaxPayloadSize = 3200000 // 3.2MB is the maximum allowed by the Datadog API
 
 // SampledSpans represents the result of a trace sampling operation.
 type SampledSpans struct {
-	// Trace will contain a trace if it was sampled or be empty if it wasn't.
-	Traces []*pb.APITrace
-	// Events contains all APM events extracted from a trace. If no events were extracted, it will be empty.
+	// TracerPayload holds the traces kept by the samplers, grouped in chunks, along with
+	// information about the tracer which sent them. The chunks of dropped traces only
+	// carry sampling metadata, and the spans kept by single-span sampling if any.
+	TracerPayload *pb.TracerPayload
+	// Events contains all APM events extracted from the traces. If no events were extracted, it will be empty.
 	Events []*pb.Span
 	// Size represents the approximated message size in bytes.
 	Size int
-	// SpanCount specifies the total number of spans found in Traces.
+	// SpanCount specifies the total number of spans found in the chunks of TracerPayload.
 	SpanCount int64
+	// Endpoint specifies the endpoint chosen by the tracer which the spans are written to.
+	// When nil, they are written to the endpoints of the configuration.
+	Endpoint *config.Endpoint
+	// Columnar holds the spans of the chunks of TracerPayload in columnar form when the
+	// experimental "columnar_spans" feature is enabled, its trace i holding the spans of the
+	// chunk i. The chunks then carry no spans.
+	Columnar *columnar.Batch
+}
+
+// chunkSpans returns the spans of the chunk i of the tracer payload.
+func (ss *SampledSpans) chunkSpans(i int) []*pb.Span {
+	if ss.Columnar != nil {
+		return ss.Columnar.Trace(i)
+	}
+	return ss.TracerPayload.Chunks[i].Spans
+}
+
+// traceBuffer holds the traces and APM events buffered for a set of senders.
+type traceBuffer struct {
+	senders  []*sender
+	lastUsed time.Time // last time traces were added to the buffer
+
+	traces       []*pb.APITrace   // traces buffered
+	batches      []columnarChunks // traces buffered in columnar form, decoded when flushed
+	events       []*pb.Span       // events buffered
+	bufferedSize int              // estimated buffer size
+}
+
+// columnarChunks holds the chunks of a tracer payload with their spans in columnar form.
+type columnarChunks struct {
+	batch  *columnar.Batch
+	chunks []*pb.TraceChunk
+}
+
+func (b *traceBuffer) reset() {
+	b.bufferedSize = 0
+	b.traces = b.traces[:0]
+	b.batches = b.batches[:0]
+	b.events = b.events[:0]
+}
+
+// decodeBatches appends the traces of the buffered batches to the buffered traces.
+func (b *traceBuffer) decodeBatches() {
+	for _, cc := range b.batches {
+		for i := 0; i < cc.batch.NumTraces(); i++ {
+			if t := cc.batch.Trace(i); len(t) > 0 {
+				b.traces = append(b.traces, chunkTrace(cc.chunks[i], t))
+			}
+		}
+	}
+	b.batches = b.batches[:0]
+}
+
+// chunkTrace returns the trace written for the spans of chunk. As the payload written carries
+// no chunk, the sampling priority and the origin of the chunk are set on the root of its spans,
+// unless it has its own. This matters for the chunks of the dropped traces, which only hold the
+// spans kept by single-span sampling.
+func chunkTrace(chunk *pb.TraceChunk, spans []*pb.Span) *pb.APITrace {
+	root := traceutil.GetRoot(spans)
+	if _, ok := sampler.GetSamplingPriority(root); !ok && chunk.Priority != int32(sampler.PriorityNone) {
+		sampler.SetSamplingPriority(root, sampler.SamplingPriority(chunk.Priority))
+	}
+	if _, ok := root.Meta[tagOrigin]; !ok && chunk.Origin != "" {
+		traceutil.SetMeta(root, tagOrigin, chunk.Origin)
+	}
+	return traceutil.APITrace(spans)
 }
 
 // TraceWriter buffers traces and APM events, flushing them to the Datadog API.
@@ -56,17 +131,23 @@ type TraceWriter struct {
 	stats    *info.TraceWriterInfo
 	wg       sync.WaitGroup // waits for gzippers
 	tick     time.Duration  // flush frequency
+	flushC   chan struct{}  // receives when a flush is due
 
-	traces       []*pb.APITrace // traces buffered
-	events       []*pb.Span     // events buffered
-	bufferedSize int            // estimated buffer size
+	heartbeat time.Duration // interval of the heartbeats sent when idle, 0 if disabled
+	lastFlush time.Time     // time of the last payload sent, heartbeats included
+
+	buffer  *traceBuffer            // buffer of the traces written to the configured endpoints
+	tenants map[string]*traceBuffer // buffers of the traces written to the endpoints chosen by the tracers
+
+	// newTenantSenders returns the senders writing to an endpoint chosen by the tracers.
+	newTenantSenders func(e *config.Endpoint) []*sender
 
 	easylog *logutil.ThrottledLogger
 }
 
 // NewTraceWriter returns a new TraceWriter. It is created for the given agent configuration and
-// will accept incoming spans via the in channel.
-func NewTraceWriter(cfg *config.AgentConfig) *TraceWriter {
+// will accept incoming spans via the in channel. Its flushes are scheduled by the flusher f.
+func NewTraceWriter(cfg *config.AgentConfig, f *Flusher) *TraceWriter {
 	tw := &TraceWriter{
 		In:       make(chan *SampledSpans, 1000),
 		hostname: cfg.Hostname,
@@ -74,7 +155,12 @@ func NewTraceWriter(cfg *config.AgentConfig) *TraceWriter {
 		stats:    &info.TraceWriterInfo{},
 		stop:     make(chan struct{}),
 		tick:     5 * time.Second,
+		flushC:   make(chan struct{}, 1),
+		tenants:  make(map[string]*traceBuffer),
 		easylog:  logutil.NewThrottled(5, 10*time.Second), // no more than 5 messages every 10 seconds
+
+		heartbeat: time.Duration(cfg.TraceWriter.HeartbeatIntervalSeconds*1000) * time.Millisecond,
+		lastFlush: time.Now(),
 	}
 	climit := cfg.TraceWriter.ConnectionLimit
 	if climit == 0 {
@@ -97,7 +183,19 @@ func NewTraceWriter(cfg *config.AgentConfig) *TraceWriter {
 		tw.tick = time.Duration(s*1000) * time.Millisecond
 	}
 	log.Debugf("Trace writer initialized (climit=%d qsize=%d)", climit, qsize)
-	tw.senders = newSenders(cfg, tw, pathTraces, climit, qsize)
+	tw.senders = newSenders(cfg, f, tw, pathTraces, climit, qsize)
+	tw.buffer = &traceBuffer{senders: tw.senders}
+	tw.newTenantSenders = func(e *config.Endpoint) []*sender {
+		return newTenantSenders(e, f, tw, pathTraces, climit, qsize)
+	}
+	f.schedule(tw.tick, func() {
+		tw.report()
+		select {
+		case tw.flushC <- struct{}{}:
+		default:
+			// a flush is already pending
+		}
+	})
 	return tw
 }
 
@@ -108,12 +206,13 @@ func (w *TraceWriter) Stop() {
 	<-w.stop
 	w.wg.Wait()
 	stopSenders(w.senders)
+	for _, b := range w.tenants {
+		stopSenders(b.senders)
+	}
 }
 
 // Run starts the TraceWriter.
 func (w *TraceWriter) Run() {
-	t := time.NewTicker(w.tick)
-	defer t.Stop()
 	defer close(w.stop)
 	for {
 		select {
@@ -132,57 +231,125 @@ func (w *TraceWriter) Run() {
 			}
 			w.flush()
 			return
-		case <-t.C:
-			w.report()
+		case <-w.flushC:
 			w.flush()
+			w.sendHeartbeat(time.Now())
 		}
 	}
 }
 
 func (w *TraceWriter) addSpans(pkg *SampledSpans) {
 	atomic.AddInt64(&w.stats.Spans, pkg.SpanCount)
-	atomic.AddInt64(&w.stats.Traces, int64(len(pkg.Traces)))
-	atomic.AddInt64(&w.stats.Events, int64(len(pkg.Events)))
 
+	b := w.bufferFor(pkg.Endpoint)
+	if b == nil {
+		return
+	}
+	b.lastUsed = time.Now()
 	size := pkg.Size
-	if size+w.bufferedSize > MaxPayloadSize {
+	if size+b.bufferedSize > MaxPayloadSize {
 		// reached maximum allowed buffered size
-		w.flush()
+		w.flushBuffer(b)
 	}
-	if len(pkg.Traces) > 0 {
-		log.Tracef("Handling new trace with %d spans: %v", pkg.SpanCount, pkg.Traces)
-		w.traces = append(w.traces, pkg.Traces...)
+	if pkg.Columnar != nil {
+		// the batch is decoded when flushed
+		for i := 0; i < pkg.Columnar.NumTraces(); i++ {
+			if pkg.Columnar.TraceLen(i) > 0 {
+				atomic.AddInt64(&w.stats.Traces, 1)
+			}
+		}
+		b.batches = append(b.batches, columnarChunks{batch: pkg.Columnar, chunks: pkg.TracerPayload.Chunks})
+	} else {
+		for _, chunk := range pkg.TracerPayload.GetChunks() {
+			if len(chunk.Spans) == 0 {
+				continue
+			}
+			log.Tracef("Handling new trace with %d spans (priority=%d, origin=%q): %v", len(chunk.Spans), chunk.Priority, chunk.Origin, chunk.Spans)
+			atomic.AddInt64(&w.stats.Traces, 1)
+			b.traces = append(b.traces, chunkTrace(chunk, chunk.Spans))
+		}
 	}
 	if len(pkg.Events) > 0 {
 		log.Tracef("Handling new package with %d events: %v", len(pkg.Events), pkg.Events)
-		w.events = append(w.events, pkg.Events...)
+		atomic.AddInt64(&w.stats.Events, int64(len(pkg.Events)))
+		b.events = append(b.events, pkg.Events...)
 	}
-	w.bufferedSize += size
+	b.bufferedSize += size
 }
 
-func (w *TraceWriter) resetBuffer() {
-	w.bufferedSize = 0
-	w.traces = w.traces[:0]
-	w.events = w.events[:0]
+// bufferFor returns the buffer of the traces written to the endpoint e chosen by a tracer, or
+// to the configured endpoints if e is nil. It returns nil when e is a new endpoint and the
+// maximum number of tenants is reached.
+func (w *TraceWriter) bufferFor(e *config.Endpoint) *traceBuffer {
+	if e == nil {
+		return w.buffer
+	}
+	key := endpointKey(e)
+	if b, ok := w.tenants[key]; ok {
+		return b
+	}
+	if len(w.tenants) >= maxTenants {
+		w.easylog.Warn("Too many API keys in use (max %d), dropping the traces written to %s.", maxTenants, e.Host)
+		metrics.Count("datadog.trace_agent.trace_writer.tenants_dropped", 1, nil, 1)
+		return nil
+	}
+	log.Debugf("Writing traces to a new API key on %s.", e.Host)
+	b := &traceBuffer{senders: w.newTenantSenders(e)}
+	w.tenants[key] = b
+	return b
 }
 
-const headerLanguages = "X-Datadog-Reported-Languages"
+const (
+	headerLanguages = "X-Datadog-Reported-Languages"
 
+	// headerHeartbeat is set on the empty payloads sent when the agent has no traces to flush.
+	headerHeartbeat = "X-Datadog-Heartbeat"
+	// headerAgentUptime specifies the time elapsed since the agent started, in seconds.
+	headerAgentUptime = "X-Datadog-Agent-Uptime"
+	// headerAgentIdle specifies the time elapsed since the previous payload sent by the
+	// agent, in seconds.
+	headerAgentIdle = "X-Datadog-Agent-Idle"
+)
+
+// flush flushes the traces of all the buffers.
 func (w *TraceWriter) flush() {
-	if len(w.traces) == 0 && len(w.events) == 0 {
+	w.flushBuffer(w.buffer)
+	for _, b := range w.tenants {
+		w.flushBuffer(b)
+	}
+	w.evictTenants(time.Now())
+}
+
+// evictTenants stops writing to the endpoints chosen by the tracers which no traces were
+// written to for tenantTTL, as of now, so that their senders do not pile up.
+func (w *TraceWriter) evictTenants(now time.Time) {
+	for key, b := range w.tenants {
+		if now.Sub(b.lastUsed) < tenantTTL {
+			continue
+		}
+		log.Debugf("No traces written to an API key for %s, stopping its senders.", tenantTTL)
+		delete(w.tenants, key)
+		go stopSenders(b.senders)
+	}
+}
+
+func (w *TraceWriter) flushBuffer(buf *traceBuffer) {
+	if len(buf.traces) == 0 && len(buf.batches) == 0 && len(buf.events) == 0 {
 		// nothing to do
 		return
 	}
 
 	defer timing.Since("datadog.trace_agent.trace_writer.encode_ms", time.Now())
-	defer w.resetBuffer()
+	defer buf.reset()
+	w.lastFlush = time.Now()
+	buf.decodeBatches()
 
-	log.Debugf("Serializing %d traces and %d APM events.", len(w.traces), len(w.events))
+	log.Debugf("Serializing %d traces and %d APM events.", len(buf.traces), len(buf.events))
 	tracePayload := pb.TracePayload{
 		HostName:     w.hostname,
 		Env:          w.env,
-		Traces:       w.traces,
-		Transactions: w.events,
+		Traces:       buf.traces,
+		Transactions: buf.events,
 	}
 	b, err := proto.Marshal(&tracePayload)
 	if err != nil {
@@ -191,7 +358,7 @@ func (w *TraceWriter) flush() {
 	}
 
 	atomic.AddInt64(&w.stats.BytesUncompressed, int64(len(b)))
-	atomic.AddInt64(&w.stats.BytesEstimated, int64(w.bufferedSize))
+	atomic.AddInt64(&w.stats.BytesEstimated, int64(buf.bufferedSize))
 
 	w.wg.Add(1)
 	go func() {
@@ -216,10 +383,41 @@ func (w *TraceWriter) flush() {
 			log.Errorf("Error closing gzip stream when writing trace payload: %v", err)
 		}
 
-		sendPayloads(w.senders, p)
+		sendPayloads(buf.senders, p)
 	}()
 }
 
+// sendHeartbeat sends an empty payload to the intake when nothing was flushed for the
+// heartbeat interval, carrying health information about the agent, so that an agent
+// receiving no traces can be told apart from one which is down.
+func (w *TraceWriter) sendHeartbeat(now time.Time) {
+	idle := now.Sub(w.lastFlush)
+	if w.heartbeat <= 0 || idle < w.heartbeat {
+		return
+	}
+	w.lastFlush = now
+
+	b, err := proto.Marshal(&pb.TracePayload{
+		HostName: w.hostname,
+		Env:      w.env,
+	})
+	if err != nil {
+		log.Errorf("Failed to serialize heartbeat payload: %v", err)
+		return
+	}
+	p := newPayload(map[string]string{
+		"Content-Type":    "application/x-protobuf",
+		headerLanguages:   strings.Join(info.Languages(), "|"),
+		headerHeartbeat:   "true",
+		headerAgentUptime: strconv.Itoa(int(info.Uptime() / time.Second)),
+		headerAgentIdle:   strconv.Itoa(int(idle / time.Second)),
+	})
+	p.body.Write(b)
+	log.Debugf("No traces flushed for %s, sending a heartbeat.", idle)
+	metrics.Count("datadog.trace_agent.trace_writer.heartbeats", 1, nil, 1)
+	sendPayloads(w.senders, p)
+}
+
 func (w *TraceWriter) report() {
 	metrics.Count("datadog.trace_agent.trace_writer.payloads", atomic.SwapInt64(&w.stats.Payloads, 0), nil, 1)
 	metrics.Count("datadog.trace_agent.trace_writer.bytes_uncompressed", atomic.SwapInt64(&w.stats.BytesUncompressed, 0), nil, 1)
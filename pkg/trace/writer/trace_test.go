@@ -9,11 +9,15 @@ import (
 	"compress/gzip"
 	"io/ioutil"
 	"reflect"
+	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb/columnar"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
 	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
 	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
 	"github.com/gogo/protobuf/proto"
@@ -41,7 +45,7 @@ func TestTraceWriter(t *testing.T) {
 		// Use a flush threshold that allows the first two entries to not overflow,
 		// but overflow on the third.
 		defer useFlushThreshold(testSpans[0].Size + testSpans[1].Size + 10)()
-		tw := NewTraceWriter(cfg)
+		tw := NewTraceWriter(cfg, NewFlusher(cfg))
 		tw.In = make(chan *SampledSpans)
 		go tw.Run()
 		for _, ss := range testSpans {
@@ -55,6 +59,173 @@ func TestTraceWriter(t *testing.T) {
 	})
 }
 
+func TestTraceWriterColumnar(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	cfg := &config.AgentConfig{
+		Hostname:   testHostname,
+		DefaultEnv: testEnv,
+		Endpoints: []*config.Endpoint{{
+			APIKey: "123",
+			Host:   srv.URL,
+		}},
+		TraceWriter: &config.WriterConfig{ConnectionLimit: 200, QueueSize: 40},
+	}
+
+	testSpans := []*SampledSpans{
+		randomSampledSpans(20, 8),
+		randomSampledSpans(10, 0),
+	}
+	tw := NewTraceWriter(cfg, NewFlusher(cfg))
+	tw.In = make(chan *SampledSpans)
+	go tw.Run()
+	for _, ss := range testSpans {
+		columnarSpans := *ss
+		columnarSpans.TracerPayload = &pb.TracerPayload{}
+		columnarSpans.Columnar = columnar.NewBatch()
+		for _, chunk := range ss.TracerPayload.Chunks {
+			columnarSpans.TracerPayload.Chunks = append(columnarSpans.TracerPayload.Chunks, &pb.TraceChunk{Priority: chunk.Priority})
+			columnarSpans.Columnar.Append(chunk.Spans)
+			// the priority of the chunk is written on the root of the decoded spans
+			sampler.SetSamplingPriority(traceutil.GetRoot(chunk.Spans), sampler.SamplingPriority(chunk.Priority))
+		}
+		tw.In <- &columnarSpans
+	}
+	tw.Stop()
+
+	assert.Equal(t, 1, srv.Accepted())
+	assert.EqualValues(t, 2, tw.stats.Traces)
+	payloadsContain(t, srv.Payloads(), testSpans)
+}
+
+func TestTraceWriterDroppedTrace(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	cfg := &config.AgentConfig{
+		Hostname:   testHostname,
+		DefaultEnv: testEnv,
+		Endpoints: []*config.Endpoint{{
+			APIKey: "123",
+			Host:   srv.URL,
+		}},
+		TraceWriter: &config.WriterConfig{ConnectionLimit: 200, QueueSize: 40},
+	}
+
+	trace := testutil.GetTestTraces(1, 10, true)[0]
+	ss := &SampledSpans{
+		TracerPayload: &pb.TracerPayload{
+			Chunks: []*pb.TraceChunk{{
+				Priority:     int32(sampler.PriorityAutoDrop),
+				DroppedTrace: true,
+			}},
+		},
+		Events: trace[:2],
+		Size:   pb.Trace(trace[:2]).Msgsize(),
+	}
+	tw := NewTraceWriter(cfg, NewFlusher(cfg))
+	tw.In = make(chan *SampledSpans)
+	go tw.Run()
+	tw.In <- ss
+	tw.Stop()
+
+	assert.Equal(t, 1, srv.Accepted())
+	payloads := srv.Payloads()
+	assert.Len(t, payloads, 1)
+	gzipr, err := gzip.NewReader(payloads[0].body)
+	assert.NoError(t, err)
+	slurp, err := ioutil.ReadAll(gzipr)
+	assert.NoError(t, err)
+	var payload pb.TracePayload
+	assert.NoError(t, proto.Unmarshal(slurp, &payload))
+	assert.Empty(t, payload.Traces)
+	assert.Len(t, payload.Transactions, 2)
+}
+
+func TestTraceWriterSingleSpans(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	cfg := &config.AgentConfig{
+		Hostname:   testHostname,
+		DefaultEnv: testEnv,
+		Endpoints: []*config.Endpoint{{
+			APIKey: "123",
+			Host:   srv.URL,
+		}},
+		TraceWriter: &config.WriterConfig{ConnectionLimit: 200, QueueSize: 40},
+	}
+
+	trace := testutil.GetTestTraces(1, 10, true)[0]
+	ss := &SampledSpans{
+		TracerPayload: &pb.TracerPayload{
+			Chunks: []*pb.TraceChunk{{
+				Priority:     int32(sampler.PriorityAutoDrop),
+				Origin:       "rum",
+				DroppedTrace: true,
+				Spans:        trace[1:2],
+			}},
+		},
+		Size:      pb.Trace(trace[1:2]).Msgsize(),
+		SpanCount: 1,
+	}
+	tw := NewTraceWriter(cfg, NewFlusher(cfg))
+	tw.In = make(chan *SampledSpans)
+	go tw.Run()
+	tw.In <- ss
+	tw.Stop()
+
+	payloads := srv.Payloads()
+	if !assert.Len(t, payloads, 1) {
+		return
+	}
+	gzipr, err := gzip.NewReader(payloads[0].body)
+	assert.NoError(t, err)
+	slurp, err := ioutil.ReadAll(gzipr)
+	assert.NoError(t, err)
+	var payload pb.TracePayload
+	assert.NoError(t, proto.Unmarshal(slurp, &payload))
+	if assert.Len(t, payload.Traces, 1) && assert.Len(t, payload.Traces[0].Spans, 1) {
+		// the sampling metadata of the chunk is kept on its spans
+		span := payload.Traces[0].Spans[0]
+		priority, ok := sampler.GetSamplingPriority(span)
+		assert.True(t, ok)
+		assert.Equal(t, sampler.PriorityAutoDrop, priority)
+		assert.Equal(t, "rum", span.Meta[tagOrigin])
+	}
+}
+
+func TestTraceWriterHeartbeat(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	cfg := &config.AgentConfig{
+		Hostname:   testHostname,
+		DefaultEnv: testEnv,
+		Endpoints: []*config.Endpoint{{
+			APIKey: "123",
+			Host:   srv.URL,
+		}},
+		TraceWriter: &config.WriterConfig{ConnectionLimit: 200, QueueSize: 40, HeartbeatIntervalSeconds: 60},
+	}
+	tw := NewTraceWriter(cfg, NewFlusher(cfg))
+	start := tw.lastFlush
+	tw.sendHeartbeat(start.Add(30 * time.Second))
+	tw.sendHeartbeat(start.Add(90 * time.Second))
+	// the heartbeat counts as a flush
+	tw.sendHeartbeat(start.Add(100 * time.Second))
+	go tw.Run()
+	tw.Stop()
+
+	assert.Equal(t, 1, srv.Accepted())
+	p := srv.Payloads()[0]
+	assert.Equal(t, "true", p.headers[headerHeartbeat])
+	assert.Equal(t, "90", p.headers[headerAgentIdle])
+	assert.NotEmpty(t, p.headers[headerAgentUptime])
+	var payload pb.TracePayload
+	assert.NoError(t, proto.Unmarshal(p.body.Bytes(), &payload))
+	assert.Equal(t, testHostname, payload.HostName)
+	assert.Equal(t, testEnv, payload.Env)
+	assert.Empty(t, payload.Traces)
+}
+
 func TestTraceWriterMultipleEndpointsConcurrent(t *testing.T) {
 	var (
 		srv = newTestServer()
@@ -82,7 +253,7 @@ func TestTraceWriterMultipleEndpointsConcurrent(t *testing.T) {
 		randomSampledSpans(10, 0),
 		randomSampledSpans(40, 5),
 	}
-	tw := NewTraceWriter(cfg)
+	tw := NewTraceWriter(cfg, NewFlusher(cfg))
 	tw.In = make(chan *SampledSpans, 100)
 	go tw.Run()
 
@@ -104,6 +275,91 @@ func TestTraceWriterMultipleEndpointsConcurrent(t *testing.T) {
 	payloadsContain(t, srv.Payloads(), testSpans)
 }
 
+func TestTraceWriterTenants(t *testing.T) {
+	newConfig := func(srv *testServer) *config.AgentConfig {
+		return &config.AgentConfig{
+			Hostname:   testHostname,
+			DefaultEnv: testEnv,
+			Endpoints: []*config.Endpoint{{
+				APIKey: "123",
+				Host:   srv.URL,
+			}},
+			TraceWriter: &config.WriterConfig{ConnectionLimit: 200, QueueSize: 40},
+		}
+	}
+
+	t.Run("routing", func(t *testing.T) {
+		assert := assert.New(t)
+		srv := newTestServer()
+		defer srv.Close()
+		cfg := newConfig(srv)
+		tw := NewTraceWriter(cfg, NewFlusher(cfg))
+		tw.In = make(chan *SampledSpans)
+		go tw.Run()
+		spans := map[string][]*SampledSpans{
+			"123": {randomSampledSpans(10, 0), randomSampledSpans(5, 2)},
+			"456": {randomSampledSpans(20, 3)},
+			"789": {randomSampledSpans(7, 0), randomSampledSpans(3, 1)},
+		}
+		for key, sss := range spans {
+			for _, ss := range sss {
+				if key != "123" {
+					ss.Endpoint = &config.Endpoint{APIKey: key, Host: srv.URL}
+				}
+				tw.In <- ss
+			}
+		}
+		tw.Stop()
+
+		byKey := make(map[string][]*payload)
+		for _, p := range srv.Payloads() {
+			byKey[p.headers["Dd-Api-Key"]] = append(byKey[p.headers["Dd-Api-Key"]], p)
+		}
+		assert.Len(byKey, 3)
+		for key, sss := range spans {
+			assert.Len(byKey[key], 1)
+			payloadsContain(t, byKey[key], sss)
+		}
+	})
+
+	t.Run("max", func(t *testing.T) {
+		srv := newTestServer()
+		defer srv.Close()
+		cfg := newConfig(srv)
+		tw := NewTraceWriter(cfg, NewFlusher(cfg))
+		tw.In = make(chan *SampledSpans)
+		go tw.Run()
+		for i := 0; i <= maxTenants; i++ {
+			ss := randomSampledSpans(2, 0)
+			ss.Endpoint = &config.Endpoint{APIKey: strconv.Itoa(i), Host: srv.URL}
+			tw.In <- ss
+		}
+		tw.Stop()
+		assert.Len(t, srv.Payloads(), maxTenants)
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		assert := assert.New(t)
+		srv := newTestServer()
+		defer srv.Close()
+		cfg := newConfig(srv)
+		tw := NewTraceWriter(cfg, NewFlusher(cfg))
+
+		e := &config.Endpoint{APIKey: "456", Host: srv.URL}
+		b := tw.bufferFor(e)
+		b.lastUsed = time.Now()
+		assert.Equal(b, tw.bufferFor(e))
+		// the tenants share the connections of the configured endpoints
+		assert.Equal(200/maxTenants, cap(b.senders[0].climit))
+
+		tw.evictTenants(time.Now())
+		assert.Len(tw.tenants, 1)
+		tw.evictTenants(time.Now().Add(tenantTTL))
+		assert.Len(tw.tenants, 0)
+		assert.NotEqual(b, tw.bufferFor(e))
+	})
+}
+
 // useFlushThreshold sets n as the number of bytes to be used as the flush threshold
 // and returns a function to restore it.
 func useFlushThreshold(n int) func() {
@@ -117,7 +373,12 @@ func randomSampledSpans(spans, events int) *SampledSpans {
 	realisticIDs := true
 	trace := testutil.GetTestTraces(1, spans, realisticIDs)[0]
 	return &SampledSpans{
-		Traces:    []*pb.APITrace{traceutil.APITrace(trace)},
+		TracerPayload: &pb.TracerPayload{
+			Chunks: []*pb.TraceChunk{{
+				Priority: int32(sampler.PriorityAutoKeep),
+				Spans:    trace,
+			}},
+		},
 		Events:    trace[:events],
 		Size:      trace.Msgsize() + pb.Trace(trace[:events]).Msgsize(),
 		SpanCount: int64(len(trace)),
@@ -145,7 +406,7 @@ func payloadsContain(t *testing.T, payloads []*payload, sampledSpans []*SampledS
 	for _, ss := range sampledSpans {
 		var found bool
 		for _, trace := range all.Traces {
-			if reflect.DeepEqual(trace.Spans, ss.Traces[0].Spans) {
+			if reflect.DeepEqual(trace.Spans, ss.TracerPayload.Chunks[0].Spans) {
 				found = true
 				break
 			}